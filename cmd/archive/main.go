@@ -0,0 +1,132 @@
+// archive move old rows of sql database in the project configuration to object storage, and restore them back
+// the result of every command is printed as json lines
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/kothak"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/archive"
+)
+
+const (
+	usage = `Usage:
+	archive -config_file=./project.config.toml -database=events -storage=archive \
+		-table=events -key_column=id -time_column=created_at -retention=2160h archive
+	archive -config_file=./project.config.toml -database=events -storage=archive -partition=events_2020_01 archive-partition
+	archive -config_file=./project.config.toml -database=events -storage=archive -key=archive/events/2020/04/05/1-1000.ndjson.gz verify
+	archive -config_file=./project.config.toml -database=events -storage=archive -table=events -key_column=id \
+		-key=archive/events/2020/04/05/1-1000.ndjson.gz restore
+	`
+)
+
+type flags struct {
+	ConfigurationFile string
+	Database          string
+	Storage           string
+	Table             string
+	KeyColumn         string
+	TimeColumn        string
+	Retention         time.Duration
+	BatchSize         int
+	Prefix            string
+	Partition         string
+	Key               string
+}
+
+func main() {
+	f := flags{}
+	flag.Usage = func() { fmt.Fprintf(os.Stderr, "%s\n", usage) }
+	flag.StringVar(&f.ConfigurationFile, "config_file", "./project.config.toml", "configuration file of the project")
+	flag.StringVar(&f.Database, "database", "", "name of sql database in the configuration")
+	flag.StringVar(&f.Storage, "storage", "", "name of object storage in the configuration")
+	flag.StringVar(&f.Table, "table", "", "table to archive or restore")
+	flag.StringVar(&f.KeyColumn, "key_column", "id", "unique column of the table")
+	flag.StringVar(&f.TimeColumn, "time_column", "created_at", "column compared with the retention")
+	flag.DurationVar(&f.Retention, "retention", 0, "rows older than retention are archived, for example 2160h")
+	flag.IntVar(&f.BatchSize, "batch_size", archive.DefaultBatchSize, "number of rows in one object")
+	flag.StringVar(&f.Prefix, "prefix", archive.DefaultPrefix, "prefix of object key")
+	flag.StringVar(&f.Partition, "partition", "", "partition to archive and drop, postgres only")
+	flag.StringVar(&f.Key, "key", "", "object key to verify or restore")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(f, flag.Arg(0), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "archive: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(f flags, command string, out io.Writer) error {
+	ctx := context.Background()
+	projectConfig := config.DefaultConfig{}
+	if err := config.ParseFile(f.ConfigurationFile, &projectConfig); err != nil {
+		return err
+	}
+	logger, err := std.New(nil)
+	if err != nil {
+		return err
+	}
+	resources, err := kothak.New(ctx, projectConfig.ResourcesConfig(), logger)
+	if err != nil {
+		return err
+	}
+	defer resources.CloseAll()
+
+	db, err := resources.GetSQLDB(f.Database)
+	if err != nil {
+		return err
+	}
+	storage, err := resources.GetObjectStorage(f.Storage)
+	if err != nil {
+		return err
+	}
+	archiver, err := archive.New(db, storage, &archive.Options{Prefix: f.Prefix, BatchSize: f.BatchSize})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	table := archive.Table{Name: f.Table, KeyColumn: f.KeyColumn, TimeColumn: f.TimeColumn, Retention: f.Retention}
+	switch command {
+	case "archive":
+		results, err := archiver.Archive(ctx, table)
+		for _, result := range results {
+			enc.Encode(result)
+		}
+		return err
+
+	case "archive-partition":
+		result, err := archiver.ArchivePartition(ctx, f.Partition)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(result)
+
+	case "verify":
+		result, err := archiver.Verify(ctx, f.Key)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(result)
+
+	case "restore":
+		restored, err := archiver.Restore(ctx, table, f.Key)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(map[string]interface{}{"key": f.Key, "restored": restored})
+	}
+	return fmt.Errorf("command %q is not supported", command)
+}
@@ -0,0 +1,199 @@
+// loadtest drive load to a real resource through the wrappers in internal/pkg
+// the result is printed in go benchmark format, so it can be compared with benchstat
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/loadtest"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/local"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+const (
+	usage = `Usage:
+	loadtest -target=redis -redis_address=localhost:6379 -concurrency=16 -duration=30s
+	loadtest -target=sqldb -driver=postgres -dsn="postgres://..." -query="SELECT 1"
+	loadtest -target=objectstorage -bucket=./loadtest -size=1048576
+	`
+)
+
+type flags struct {
+	Target       string
+	Concurrency  int
+	Duration     time.Duration
+	Requests     int
+	Warmup       int
+	RedisAddress string
+	Driver       string
+	DSN          string
+	Query        string
+	Bucket       string
+	Size         int
+}
+
+type scenario struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func main() {
+	f := flags{}
+	flag.Usage = func() { fmt.Fprintf(os.Stderr, "%s\n", usage) }
+	flag.StringVar(&f.Target, "target", "", "resource to load, redis|sqldb|objectstorage")
+	flag.IntVar(&f.Concurrency, "concurrency", 8, "number of concurrent workers")
+	flag.DurationVar(&f.Duration, "duration", time.Second*10, "duration of each scenario")
+	flag.IntVar(&f.Requests, "requests", 0, "total requests of each scenario, the scenario stop when either duration or requests is reached")
+	flag.IntVar(&f.Warmup, "warmup", 10, "warmup calls of each worker before measuring")
+	flag.StringVar(&f.RedisAddress, "redis_address", "localhost:6379", "address of redis")
+	flag.StringVar(&f.Driver, "driver", sqldb.DriverPostgres, "driver of sql database")
+	flag.StringVar(&f.DSN, "dsn", "", "dsn of sql database")
+	flag.StringVar(&f.Query, "query", "SELECT 1", "query of sql database scenario")
+	flag.StringVar(&f.Bucket, "bucket", "", "directory of local object storage bucket, default to temporary directory which is removed after the test")
+	flag.IntVar(&f.Size, "size", 1<<20, "object size of object storage scenario in bytes")
+	flag.Parse()
+
+	if err := run(f, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(f flags, out io.Writer) error {
+	ctx := context.Background()
+	scenarios, closeFn, err := newScenarios(ctx, f)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	opts := loadtest.Options{
+		Concurrency: f.Concurrency,
+		Duration:    f.Duration,
+		Requests:    f.Requests,
+		Warmup:      f.Warmup,
+	}
+	for _, s := range scenarios {
+		result, err := loadtest.Run(ctx, &opts, s.fn)
+		if err != nil {
+			return err
+		}
+		if err := result.WriteBenchmark(out, s.name, f.Concurrency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newScenarios(ctx context.Context, f flags) (scenarios []scenario, closeFn func(), err error) {
+	switch f.Target {
+	case "redis":
+		rdg, err := redigo.New(ctx, f.RedisAddress, &redigo.Config{MaxActive: f.Concurrency, MaxIdle: f.Concurrency})
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := rdg.Set(ctx, "loadtest:key", "value"); err != nil {
+			rdg.Close()
+			return nil, nil, err
+		}
+		var counter int64
+		scenarios = []scenario{
+			{name: "RedisGet", fn: func(ctx context.Context) error {
+				_, err := rdg.Get(ctx, "loadtest:key")
+				return err
+			}},
+			{name: "RedisSet", fn: func(ctx context.Context) error {
+				key := "loadtest:" + strconv.FormatInt(atomic.AddInt64(&counter, 1)%1000, 10)
+				_, err := rdg.Set(ctx, key, "value")
+				return err
+			}},
+		}
+		return scenarios, func() { rdg.Close() }, nil
+
+	case "sqldb":
+		conn, err := sqldb.Connect(ctx, f.Driver, f.DSN, &sqldb.ConnectOptions{MaxOpenConnections: f.Concurrency, MaxIdleConnections: f.Concurrency})
+		if err != nil {
+			return nil, nil, err
+		}
+		db, err := sqldb.Wrap(ctx, conn, conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		scenarios = []scenario{
+			{name: "SQLDBQueryFollower", fn: func(ctx context.Context) error {
+				rows, err := db.QueryContext(ctx, f.Query)
+				if err != nil {
+					return err
+				}
+				return rows.Close()
+			}},
+		}
+		return scenarios, func() { db.Close() }, nil
+
+	case "objectstorage":
+		bucket := f.Bucket
+		if bucket == "" {
+			dir, err := ioutil.TempDir("", "loadtest")
+			if err != nil {
+				return nil, nil, err
+			}
+			defer func() {
+				if closeFn == nil {
+					os.RemoveAll(dir)
+				}
+			}()
+			bucket = dir
+		}
+		provider, err := local.New(ctx, bucket, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		storage := objectstorage.New(provider)
+		content := make([]byte, f.Size)
+		if _, err := storage.UploadByte(ctx, content, "loadtest", nil); err != nil {
+			storage.Close()
+			return nil, nil, err
+		}
+		stream, err := storage.Stream(ctx, "loadtest", nil)
+		if err != nil {
+			storage.Close()
+			return nil, nil, err
+		}
+		var counter int64
+		scenarios = []scenario{
+			{name: "ObjectStorageStreamRead", fn: func(ctx context.Context) error {
+				r, err := stream.Reader(ctx, "loadtest", nil)
+				if err != nil {
+					return err
+				}
+				defer r.Close()
+				_, err = io.Copy(ioutil.Discard, r)
+				return err
+			}},
+			{name: "ObjectStorageUploadByte", fn: func(ctx context.Context) error {
+				key := "loadtest_" + strconv.FormatInt(atomic.AddInt64(&counter, 1)%100, 10)
+				_, err := storage.UploadByte(ctx, content, key, nil)
+				return err
+			}},
+		}
+		closeFn = func() {
+			storage.Close()
+			if f.Bucket == "" {
+				os.RemoveAll(bucket)
+			}
+		}
+		return scenarios, closeFn, nil
+	}
+	return nil, nil, fmt.Errorf("target %q is not supported", f.Target)
+}
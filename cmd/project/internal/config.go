@@ -0,0 +1,77 @@
+package project
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/pkg/buildinfo"
+	"github.com/albertwidi/go-project-example/internal/pkg/defaults"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/deadline"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/tracing"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"go.opencensus.io/trace"
+)
+
+// newConfigRegistry register configuration of all subsystems
+// the registry is used to set default value and print the effective configuration
+func newConfigRegistry(c *Config) (*defaults.Registry, error) {
+	registry := defaults.NewRegistry()
+	if err := registry.Register("servers", &c.Servers); err != nil {
+		return nil, err
+	}
+	if err := registry.Register("log", &c.Log); err != nil {
+		return nil, err
+	}
+	if err := registry.Register("resources", &c.Resources); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// newDeadlineOptions return request timeout options of the servers
+func newDeadlineOptions(requestTimeout string, routeTimeouts map[string]string) (*deadline.Options, error) {
+	opts := deadline.Options{
+		Routes: make(map[string]time.Duration),
+	}
+	if requestTimeout != "" {
+		timeout, err := time.ParseDuration(requestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("project: invalid request_timeout: %w", err)
+		}
+		opts.Timeout = timeout
+	}
+	for prefix, t := range routeTimeouts {
+		timeout, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("project: invalid route timeout of %s: %w", prefix, err)
+		}
+		opts.Routes[prefix] = timeout
+	}
+	return &opts, nil
+}
+
+// newTracingMiddleware return tracing middleware of the servers
+func newTracingMiddleware(c config.TracingConfig) router.MiddlewareFunc {
+	opts := tracing.Options{DebugToken: c.DebugToken}
+	if c.SampleRate > 0 {
+		opts.Sampler = trace.ProbabilitySampler(c.SampleRate)
+	}
+	return tracing.Middleware(&opts)
+}
+
+// enableSubsystems mark subsystems that are configured in buildinfo
+func enableSubsystems(c Config) {
+	if len(c.Resources.DBConfig.SQLDBs) > 0 {
+		buildinfo.EnableSubsystem("sqldb")
+	}
+	if len(c.Resources.RedisConfig.Rds) > 0 {
+		buildinfo.EnableSubsystem("redis")
+	}
+	if len(c.Resources.ObjectStorageConfig) > 0 {
+		buildinfo.EnableSubsystem("objectstorage")
+	}
+	if c.Servers.Debug.Address != "" {
+		buildinfo.EnableSubsystem("debugserver")
+	}
+}
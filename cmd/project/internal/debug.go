@@ -1,15 +1,74 @@
 package project
 
 import (
+	"github.com/albertwidi/go-project-example/debug/scenario"
+	"github.com/albertwidi/go-project-example/debug/seed"
+	"github.com/albertwidi/go-project-example/debug/user"
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/kothak"
+	lg "github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
 	debugserver "github.com/albertwidi/go-project-example/internal/server/debug"
+	"github.com/albertwidi/go-project-example/internal/usecase/notification"
+	userusecase "github.com/albertwidi/go-project-example/internal/usecase/user"
 )
 
-func newDebugServer(address string, r *Repositories) (*debugserver.Server, error) {
-	usecases := debugserver.Usecases{}
-	s, err := debugserver.New(address, usecases)
+func newDebugServer(debugConfig config.DebugServerConfig, r *Repositories, resources *kothak.Kothak, logger lg.Logger) (*debugserver.Server, error) {
+	scenarios, err := newDebugScenarios(debugConfig, r, resources, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	usecases := debugserver.Usecases{
+		Scenarios: scenarios,
+	}
+	// email adapter is not available until email provider is wired into the project
+	// the preview can only be rendered until the adapter is passed as the email sender
+	if debugConfig.Email.Templates != "" {
+		usecases.EmailTemplates, err = notification.LoadEmailTemplate(debugConfig.Email.Templates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s, err := debugserver.New(debugConfig.Address, usecases, &debugserver.Options{
+		EmailAllowlist: debugConfig.Email.Allowlist,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return s, nil
 }
+
+// newDebugScenarios register all debug scenarios, only scenarios enabled in the config can be run
+func newDebugScenarios(debugConfig config.DebugServerConfig, r *Repositories, resources *kothak.Kothak, logger lg.Logger) (*scenario.Registry, error) {
+	registry := scenario.New(logger, &scenario.Options{
+		Enabled: debugConfig.Scenarios,
+	})
+
+	// otp usecase is not available until otp repository is wired into the project
+	// simulate_otp_success scenario is registered when the otp usecase is passed here
+	userDebug := user.New(userusecase.New(nil), nil)
+	if err := registry.Register(userDebug.Scenarios()...); err != nil {
+		return nil, err
+	}
+
+	if debugConfig.Seed.Database != "" {
+		db, err := resources.GetSQLDB(debugConfig.Seed.Database)
+		if err != nil {
+			return nil, err
+		}
+		var storage *objectstorage.Storage
+		if debugConfig.Seed.ObjectStorage != "" {
+			storage, err = resources.GetObjectStorage(debugConfig.Seed.ObjectStorage)
+			if err != nil {
+				return nil, err
+			}
+		}
+		seedDebug := seed.New(db, storage)
+		if err := registry.Register(seedDebug.Scenarios()...); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
@@ -0,0 +1,32 @@
+package project
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/pkg/alert"
+	lg "github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego/monitor"
+)
+
+// newGoroutineMonitor return the monitor of goroutines per subsystem, the growth and limit alerts are written to the log
+func newGoroutineMonitor(c config.GoroutineMonitorConfig, logger lg.Logger) (*monitor.Monitor, error) {
+	opts := monitor.Options{
+		Window:    c.Window,
+		MinGrowth: c.MinGrowth,
+		Limits:    c.Limits,
+		Notifiers: []alert.Notifier{alert.Logger(logger)},
+		OnError: func(err error) {
+			logger.Errorf("goroutine monitor: %v", err)
+		},
+	}
+	if c.Interval != "" {
+		interval, err := time.ParseDuration(c.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("project: invalid goroutine_monitor interval: %w", err)
+		}
+		opts.Interval = interval
+	}
+	return monitor.New(&opts), nil
+}
@@ -0,0 +1,77 @@
+package project
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/cache"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/deadline"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/server"
+)
+
+// httpMiddlewares is the list of middleware which can be used by name in named http servers
+// every server get its own instance of the middleware
+var httpMiddlewares = map[string]func() router.MiddlewareFunc{
+	// cache GET responses in memory by their Cache-Control
+	"cache": func() router.MiddlewareFunc {
+		return cache.New(nil).Middleware()
+	},
+}
+
+// newHTTPServers create the named http servers in the config, handlers of the server is registered by the server name in routes
+func newHTTPServers(c config.DefaultServers, routes map[string]func(r *router.Router)) ([]server.Runner, error) {
+	var runners []server.Runner
+	closeAll := func() {
+		for _, r := range runners {
+			r.Shutdown(context.Background())
+		}
+	}
+
+	for _, httpConfig := range c.HTTP {
+		options, err := newHTTPOptions(httpConfig)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		options.Register = routes[httpConfig.Name]
+		s, err := server.NewHTTPServer(httpConfig.Name, httpConfig.Address, options)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("project: http server %s: %w", httpConfig.Name, err)
+		}
+		runners = append(runners, s)
+	}
+	return runners, nil
+}
+
+// newHTTPOptions return the middlewares and tls of the named http server
+func newHTTPOptions(httpConfig config.HTTPServerConfig) (*server.HTTPOptions, error) {
+	options := server.HTTPOptions{}
+	if httpConfig.RequestTimeout != "" || len(httpConfig.RouteTimeouts) > 0 {
+		deadlineOpts, err := newDeadlineOptions(httpConfig.RequestTimeout, httpConfig.RouteTimeouts)
+		if err != nil {
+			return nil, fmt.Errorf("project: http server %s: %w", httpConfig.Name, err)
+		}
+		options.Middlewares = append(options.Middlewares, deadline.Middleware(deadlineOpts))
+	}
+	for _, name := range httpConfig.Middlewares {
+		newMiddleware, ok := httpMiddlewares[name]
+		if !ok {
+			return nil, fmt.Errorf("project: http server %s: middleware %s is not found", httpConfig.Name, name)
+		}
+		options.Middlewares = append(options.Middlewares, newMiddleware())
+	}
+
+	tlsConfig, err := server.NewTLSConfig(server.TLSOptions{
+		CertFile:     httpConfig.TLS.CertFile,
+		KeyFile:      httpConfig.TLS.KeyFile,
+		ClientCAFile: httpConfig.TLS.ClientCAFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("project: http server %s: %w", httpConfig.Name, err)
+	}
+	options.TLS = tlsConfig
+	return &options, nil
+}
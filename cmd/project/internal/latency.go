@@ -0,0 +1,20 @@
+package project
+
+import (
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/pkg/latency"
+	"go.opencensus.io/trace"
+)
+
+// newLatencyAnalyzer return the analyzer of the sampled spans, which is registered as opencensus exporter
+// the observed rate is scaled by the sample rate of the tracing to estimate the pool sizes
+func newLatencyAnalyzer(c config.LatencyReportConfig, tracing config.TracingConfig) *latency.Analyzer {
+	analyzer := latency.New(&latency.Options{
+		Percentile: c.Percentile,
+		Multiplier: c.Multiplier,
+		MinSamples: c.MinSamples,
+		SampleRate: tracing.SampleRate,
+	})
+	trace.RegisterExporter(analyzer)
+	return analyzer
+}
@@ -0,0 +1,56 @@
+package project
+
+import (
+	"sync"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/lifecycle"
+	lg "github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+)
+
+// lifecycleLog write every lifecycle phase to the log
+// the phases which end before the logger is created, for example config load, are written when the logger is set
+type lifecycleLog struct {
+	mu      sync.Mutex
+	logger  lg.Logger
+	pending []lifecycle.Phase
+}
+
+// newLifecycleRecorder return the recorder of the lifecycle phases and its log
+func newLifecycleRecorder() (*lifecycle.Recorder, *lifecycleLog) {
+	l := &lifecycleLog{}
+	return lifecycle.New(&lifecycle.Options{OnPhase: l.write}), l
+}
+
+func (l *lifecycleLog) setLogger(logger lg.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger = logger
+	for _, phase := range l.pending {
+		logPhase(logger, phase)
+	}
+	l.pending = nil
+}
+
+func (l *lifecycleLog) write(phase lifecycle.Phase) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.logger == nil {
+		l.pending = append(l.pending, phase)
+		return
+	}
+	logPhase(l.logger, phase)
+}
+
+func logPhase(logger lg.Logger, phase lifecycle.Phase) {
+	kv := lg.KV{
+		"phase":       phase.Name,
+		"start":       phase.Start,
+		"duration_ms": phase.Duration.Milliseconds(),
+	}
+	if phase.Error != "" {
+		kv["error"] = phase.Error
+		logger.Warnw("project: lifecycle phase failed", kv)
+		return
+	}
+	logger.Infow("project: lifecycle phase", kv)
+}
@@ -11,9 +11,17 @@ import (
 
 	"github.com/albertwidi/go-project-example/internal/config"
 	"github.com/albertwidi/go-project-example/internal/kothak"
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
+	"github.com/albertwidi/go-project-example/internal/pkg/buildinfo"
+	"github.com/albertwidi/go-project-example/internal/pkg/graceful"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/deadline"
+	"github.com/albertwidi/go-project-example/internal/pkg/lifecycle"
 	lg "github.com/albertwidi/go-project-example/internal/pkg/log/logger"
 	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/zap"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
 	"github.com/albertwidi/go-project-example/internal/server"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Flags of project
@@ -24,6 +32,8 @@ type Flags struct {
 	ConfigurationFile string
 	LogFile           string
 	Version           bool
+	ValidateConfig    bool
+	PrintConfig       bool
 }
 
 // Config of project
@@ -35,13 +45,34 @@ type Config struct {
 func Run(f Flags) error {
 	// set default timezone
 	os.Setenv("TZ", f.TimeZone)
+	// every phase of the startup and shutdown is recorded with its duration, and served in /debug/lifecycle
+	recorder, lifecycleLog := newLifecycleRecorder()
 
 	// load project configuration
+	endConfig := recorder.Begin("config/load")
 	projectConfig := Config{}
 	if err := config.ParseFile(f.ConfigurationFile, &projectConfig, f.EnvironmentFile.envFiles...); err != nil {
 		return err
 	}
 
+	registry, err := newConfigRegistry(&projectConfig)
+	if err != nil {
+		return err
+	}
+	if err := registry.SetDefault(); err != nil {
+		return err
+	}
+	endConfig(nil)
+	// only print the effective configuration, secrets are redacted
+	if f.PrintConfig {
+		return registry.Print(os.Stdout)
+	}
+
+	// only validate the configuration without connecting to any resources
+	if f.ValidateConfig {
+		return kothak.Validate(projectConfig.ResourcesConfig())
+	}
+
 	// initiate project logger
 	logger, err := zap.New(&lg.Config{
 		Level:    lg.StringToLevel(projectConfig.Log.Level),
@@ -51,6 +82,10 @@ func Run(f Flags) error {
 	if err != nil {
 		return fmt.Errorf("run: error when initiating logger: %w", err)
 	}
+	lifecycleLog.setLogger(logger)
+
+	enableSubsystems(projectConfig)
+	logger.Infof("starting project, %s", buildinfo.Get())
 
 	if f.Debug.TestConfig {
 		logger.Infof("testing config with flags and configurations:")
@@ -58,12 +93,21 @@ func Run(f Flags) error {
 		logger.Infof("config:\n%+v", projectConfig)
 	}
 
-	resources, err := kothak.New(context.TODO(), projectConfig.Resources, logger)
+	endResources := recorder.Begin("resources/init")
+	resources, err := kothak.New(lifecycle.WithRecorder(context.TODO(), recorder), projectConfig.ResourcesConfig(), logger)
+	endResources(err)
 	if err != nil {
 		return err
 	}
 	// close all connections when program exiting
-	defer resources.CloseAll()
+	defer func() {
+		endClose := recorder.Begin("resources/close")
+		endClose(resources.CloseAll())
+	}()
+	// resources metrics is exported via admin server
+	if err := prometheus.DefaultRegisterer.Register(resources.Collector()); err != nil {
+		return err
+	}
 
 	// repositories
 	repo, err := newRepositories(resources)
@@ -71,45 +115,100 @@ func Run(f Flags) error {
 		return err
 	}
 
+	endServers := recorder.Begin("servers/init")
+	// listeners are inherited from the old process on graceful restart
+	graceful.Configure(graceful.Options{ReusePort: projectConfig.Servers.ReusePort})
+
 	// initiate new servers
 	newMainServer()
-	debugServerAddr := projectConfig.Servers.Debug.Address
-	debugServer, err := newDebugServer(debugServerAddr, repo)
+	debugServer, err := newDebugServer(projectConfig.Servers.Debug, repo, resources, logger)
+	if err != nil {
+		return err
+	}
+
+	// handlers of named http servers are registered by server name when the main server is implemented
+	runners, err := newHTTPServers(projectConfig.Servers, nil)
 	if err != nil {
 		return err
 	}
 
-	s, err := server.New(projectConfig.Servers.Admin.Address, debugServer)
+	s, err := server.New(projectConfig.Servers.Admin.Address, append(runners, debugServer)...)
+	if err != nil {
+		return err
+	}
+	bundleHandler, err := newSupportBundleHandler(projectConfig.Servers.SupportBundle, registry, resources)
+	if err != nil {
+		return err
+	}
+	s.HandleAdmin("/debug/support-bundle", bundleHandler)
+	s.HandleAdmin("/debug/lifecycle", recorder.Handler())
+	goroutineMonitor, err := newGoroutineMonitor(projectConfig.Servers.GoroutineMonitor, logger)
+	if err != nil {
+		return err
+	}
+	if err := prometheus.DefaultRegisterer.Register(goroutineMonitor.Collector()); err != nil {
+		return err
+	}
+	goroutineMonitor.Start(context.Background())
+	defer goroutineMonitor.Close()
+	s.HandleAdmin("/debug/goroutines", goroutineMonitor.Handler())
+	if c := projectConfig.Servers.LatencyReport; c.Enabled {
+		s.HandleAdmin("/debug/latency", newLatencyAnalyzer(c, projectConfig.Servers.Tracing).Handler())
+	}
+	deadlineOpts, err := newDeadlineOptions(projectConfig.Servers.RequestTimeout, projectConfig.Servers.RouteTimeouts)
 	if err != nil {
 		return err
 	}
+	s.Use(newTracingMiddleware(projectConfig.Servers.Tracing), attribution.Middleware(nil), deadline.Middleware(deadlineOpts))
+	if budget := projectConfig.Servers.RetryBudget; budget.Retries > 0 {
+		s.Use(retry.Middleware(&retry.BudgetOptions{Retries: budget.Retries, Ratio: budget.Ratio, Max: budget.Max}))
+	}
+	endServers(nil)
 	// run the server
+	endStart := recorder.Begin("servers/start")
 	errChan := s.Run()
+	endStart(nil)
+	recorder.Ready()
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	if projectConfig.Servers.GracefulRestart {
+		signal.Notify(sigChan, syscall.SIGUSR2)
+	}
 	// exit early if we only test config
 	testChan := make(chan struct{}, 1)
 	if f.Debug.TestConfig {
 		logger.Infoln("testing: giving time for server to run")
-		go func() {
+		safego.Go(context.Background(), "project/testconfig", func(ctx context.Context) error {
 			time.Sleep(time.Second * 5)
 			testChan <- struct{}{}
-		}()
+			return nil
+		})
 	}
 
-	select {
-	case err := <-errChan:
-		return err
-	case sig := <-sigChan:
-		switch sig {
-		case syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT:
-			return errors.New("project: receive signal to terminate program")
+	for {
+		select {
+		case err := <-errChan:
+			return err
+		case sig := <-sigChan:
+			switch sig {
+			case syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT:
+				// in-flight requests are drained before the resources are closed
+				endDrain := recorder.Begin("servers/drain")
+				endDrain(shutdownServers(s))
+				return errors.New("project: receive signal to terminate program")
+			case syscall.SIGUSR2:
+				// keep running when the new process cannot be started
+				if err := gracefulRestart(logger, recorder, s); err != nil {
+					logger.Errorf("project: graceful restart failed: %v", err)
+					continue
+				}
+				return nil
+			}
+		case <-testChan:
+			logger.Infoln("testing: test completed successfully")
+			return nil
 		}
-	case <-testChan:
-		logger.Infoln("testing: test completed successfully")
-		return nil
 	}
-	return nil
 }
 
 func newMainServer() {
@@ -0,0 +1,44 @@
+package project
+
+import (
+	"context"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/graceful"
+	"github.com/albertwidi/go-project-example/internal/pkg/lifecycle"
+	lg "github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/server"
+)
+
+// shutdownTimeout is the maximum time to drain in-flight requests
+const shutdownTimeout = time.Second * 30
+
+// gracefulRestart start a new process with the current listeners and shutdown the servers
+// the new process accept connections from the same listeners while the servers drain in-flight requests
+// resources are closed after the servers are stopped
+func gracefulRestart(logger lg.Logger, recorder *lifecycle.Recorder, s *server.Server) error {
+	endRestart := recorder.Begin("servers/restart")
+	listeners, err := graceful.Default()
+	if err != nil {
+		endRestart(err)
+		return err
+	}
+	proc, err := listeners.Restart()
+	endRestart(err)
+	if err != nil {
+		return err
+	}
+	logger.Infof("project: new process started with pid %d, shutting down servers", proc.Pid)
+
+	endDrain := recorder.Begin("servers/drain")
+	err = shutdownServers(s)
+	endDrain(err)
+	return err
+}
+
+// shutdownServers stop the servers after the in-flight requests are done or the shutdown timeout
+func shutdownServers(s *server.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
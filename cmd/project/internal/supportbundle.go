@@ -0,0 +1,35 @@
+package project
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/kothak"
+	"github.com/albertwidi/go-project-example/internal/pkg/defaults"
+	"github.com/albertwidi/go-project-example/internal/pkg/supportbundle"
+)
+
+// newSupportBundleHandler return the handler of the support bundle
+// the bundle contains the effective configuration with secrets redacted, the resources diagnostic and the runtime profiles
+func newSupportBundleHandler(c config.SupportBundleConfig, registry *defaults.Registry, resources *kothak.Kothak) (http.Handler, error) {
+	b := supportbundle.New(nil)
+	if err := b.AddJSON("config.json", func(ctx context.Context) (interface{}, error) {
+		return registry.Effective(), nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := resources.AddSupportBundle(b); err != nil {
+		return nil, err
+	}
+
+	opts := supportbundle.HandlerOptions{Prefix: c.Prefix}
+	if c.ObjectStorage != "" {
+		storage, err := resources.GetObjectStorage(c.ObjectStorage)
+		if err != nil {
+			return nil, err
+		}
+		opts.Storage = storage
+	}
+	return supportbundle.Handler(b, &opts), nil
+}
@@ -6,11 +6,7 @@ import (
 	"os"
 
 	project "github.com/albertwidi/go-project-example/cmd/project/internal"
-)
-
-var (
-	buildVersion string
-	buildCommit  string
+	"github.com/albertwidi/go-project-example/internal/pkg/buildinfo"
 )
 
 const (
@@ -29,10 +25,13 @@ func main() {
 	flag.StringVar(&f.TimeZone, "tz", "", "time zone of the project")
 	flag.BoolVar(&f.Version, "version", false, "to print version of the prgoram")
 	flag.Var(&f.Debug, "debug", "turn on debug mode, this will set log level to debug")
+	flag.BoolVar(&f.ValidateConfig, "validate_config", false, "validate the configuration without connecting to any resources")
+	flag.BoolVar(&f.PrintConfig, "print_config", false, "print the effective configuration with default values, secrets are redacted")
 	flag.Parse()
 
 	if f.Version {
-		fmt.Fprintf(os.Stderr, "version: %s\ncommit: %s\n", buildVersion, buildCommit)
+		info := buildinfo.Get()
+		fmt.Fprintf(os.Stderr, "version: %s\ncommit: %s\nbuild date: %s\ngo: %s %s\n", info.Version, info.Commit, info.BuildDate, info.GoVersion, info.Platform)
 		return
 	}
 	if err := project.Run(f); err != nil {
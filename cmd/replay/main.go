@@ -0,0 +1,158 @@
+// replay publish the events of the outbox table in the sql database of the project configuration to nsqd again
+// in the order they are written, the result is printed as json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/config"
+	"github.com/albertwidi/go-project-example/internal/kothak"
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus/replay"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/nsq/nsqio"
+)
+
+const (
+	usage = `Usage:
+	replay -config_file=./project.config.toml -database=orders -aggregate_id=order-1 -dry_run
+	replay -config_file=./project.config.toml -database=orders -nsqd=127.0.0.1:4150 \
+		-from=2020-05-01T00:00:00Z -to=2020-05-02T00:00:00Z -topics=order.paid -target_topic=order.paid.rebuild
+	replay -config_file=./project.config.toml -database=orders -nsqd=127.0.0.1:4150 -table=outbox_archive \
+		-from=2020-05-01T00:00:00Z -to=2020-05-02T00:00:00Z -cursor=<cursor of the failed replay>
+	`
+)
+
+type flags struct {
+	ConfigurationFile string
+	Database          string
+	Table             string
+	NSQD              string
+	From              string
+	To                string
+	AggregateID       string
+	Topics            string
+	TargetTopic       string
+	Cursor            string
+	Limit             int
+	BatchSize         int
+	DryRun            bool
+}
+
+func main() {
+	f := flags{}
+	flag.Usage = func() { fmt.Fprintf(os.Stderr, "%s\n", usage) }
+	flag.StringVar(&f.ConfigurationFile, "config_file", "./project.config.toml", "configuration file of the project")
+	flag.StringVar(&f.Database, "database", "", "name of sql database in the configuration")
+	flag.StringVar(&f.Table, "table", replay.DefaultTable, "table of the events, for example the archive table of outbox")
+	flag.StringVar(&f.NSQD, "nsqd", "", "address of nsqd which the events are published to, not needed in dry run")
+	flag.StringVar(&f.From, "from", "", "inclusive start of created_at in RFC3339, for example 2020-05-01T00:00:00Z")
+	flag.StringVar(&f.To, "to", "", "exclusive end of created_at in RFC3339")
+	flag.StringVar(&f.AggregateID, "aggregate_id", "", "replay only the events of the aggregate")
+	flag.StringVar(&f.Topics, "topics", "", "comma separated topics to replay, all topics when empty")
+	flag.StringVar(&f.TargetTopic, "target_topic", "", "publish all events to this topic instead of the topic of the event")
+	flag.StringVar(&f.Cursor, "cursor", "", "continue after the cursor of the previous result")
+	flag.IntVar(&f.Limit, "limit", 0, "maximum number of replayed events, all events when zero")
+	flag.IntVar(&f.BatchSize, "batch_size", replay.DefaultBatchSize, "number of events selected in one query")
+	flag.BoolVar(&f.DryRun, "dry_run", false, "count the events without publishing them")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(f, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (f flags) request() (replay.Request, error) {
+	req := replay.Request{
+		AggregateID: f.AggregateID,
+		TargetTopic: f.TargetTopic,
+		Cursor:      f.Cursor,
+		Limit:       f.Limit,
+		DryRun:      f.DryRun,
+	}
+	var err error
+	if f.From != "" {
+		if req.From, err = time.Parse(time.RFC3339, f.From); err != nil {
+			return req, fmt.Errorf("from: %w", err)
+		}
+	}
+	if f.To != "" {
+		if req.To, err = time.Parse(time.RFC3339, f.To); err != nil {
+			return req, fmt.Errorf("to: %w", err)
+		}
+	}
+	for _, topic := range strings.Split(f.Topics, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			req.Topics = append(req.Topics, topic)
+		}
+	}
+	return req, nil
+}
+
+func run(f flags, out io.Writer) error {
+	ctx := context.Background()
+	req, err := f.request()
+	if err != nil {
+		return err
+	}
+	projectConfig := config.DefaultConfig{}
+	if err := config.ParseFile(f.ConfigurationFile, &projectConfig); err != nil {
+		return err
+	}
+	logger, err := std.New(nil)
+	if err != nil {
+		return err
+	}
+	resources, err := kothak.New(ctx, projectConfig.ResourcesConfig(), logger)
+	if err != nil {
+		return err
+	}
+	defer resources.CloseAll()
+
+	db, err := resources.GetSQLDB(f.Database)
+	if err != nil {
+		return err
+	}
+	var publisher replay.Publisher = replay.PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+		return errors.New("events are not published in dry run")
+	})
+	if !f.DryRun {
+		if f.NSQD == "" {
+			return errors.New("nsqd address is required when it is not a dry run")
+		}
+		producer, err := nsqio.NewProducer(ctx, nsqio.ProducerConfig{Address: f.NSQD})
+		if err != nil {
+			return err
+		}
+		defer producer.Stop()
+		if err := producer.Ping(); err != nil {
+			return err
+		}
+		publisher = replay.PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+			return producer.Publish(topic, payload)
+		})
+	}
+	replayer, err := replay.New(db, publisher, &replay.Options{Table: f.Table, BatchSize: f.BatchSize})
+	if err != nil {
+		return err
+	}
+
+	result, err := replayer.Replay(ctx, req)
+	// the result is printed even when the replay is failed, so it can be continued with the cursor
+	if encErr := json.NewEncoder(out).Encode(result); encErr != nil && err == nil {
+		err = encErr
+	}
+	return err
+}
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS notification_preferences;
+CREATE TABLE notification_preferences (
+    user_id bigint NOT NULL,
+    -- channel of notification, email, sms or push
+    channel varchar(10) NOT NULL,
+    enabled boolean NOT NULL,
+    -- quiet hours in minutes since midnight in user timezone
+    quiet_hours_start int NOT NULL DEFAULT 0,
+    quiet_hours_end int NOT NULL DEFAULT 0,
+    time_zone varchar(40) NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY(user_id, channel)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_details;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,45 @@
+DROP TABLE IF EXISTS orders;
+CREATE TABLE orders(
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id bigint NOT NULL,
+    order_type smallint NOT NULL,
+    total bigint NOT NULL,
+    discount_total bigint NOT NULL DEFAULT 0,
+    refund_total bigint NOT NULL DEFAULT 0,
+    cashback_total bigint NOT NULL DEFAULT 0,
+    grand_total bigint NOT NULL,
+    status smallint NOT NULL,
+    is_refundable boolean NOT NULL,
+    refundable_before timestamp,
+    created_at timestamp NOT NULL,
+    updated_at timestamp,
+    updated_by bigint,
+    is_test boolean NOT NULL,
+    is_deleted boolean NOT NULL DEFAULT false
+);
+
+DROP INDEX IF EXISTS idx_orders_user_id;
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+
+DROP TABLE IF EXISTS order_details;
+CREATE TABLE order_details(
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id uuid NOT NULL,
+    item_id varchar(36) NOT NULL,
+    item_name varchar(100) NOT NULL,
+    item_price bigint NOT NULL,
+    item_quantity bigint NOT NULL,
+    item_price_total bigint NOT NULL,
+    discount_amount bigint NOT NULL DEFAULT 0,
+    refund_amount bigint NOT NULL DEFAULT 0,
+    cashback_amount bigint NOT NULL DEFAULT 0,
+    total bigint NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp,
+    updated_by bigint,
+    is_test boolean NOT NULL,
+    is_deleted boolean NOT NULL DEFAULT false
+);
+
+DROP INDEX IF EXISTS idx_order_details_order_id;
+CREATE INDEX idx_order_details_order_id ON order_details(order_id);
@@ -0,0 +1,192 @@
+// Package scenario is a registry of debug scenarios
+// a scenario is a named and parameterized action to prepare test data, for example create a test user
+// scenarios replace ad-hoc bypass endpoints, every run is logged for audit
+//
+// scenario is not enabled by default, the program must explicitly enable it by name in the configuration
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+)
+
+// EnableAll enable all registered scenarios
+const EnableAll = "*"
+
+// list of error
+var (
+	ErrNameEmpty     = errors.New("scenario: name is empty")
+	ErrRunNil        = errors.New("scenario: run function is nil")
+	ErrAlreadyExists = errors.New("scenario: already registered")
+	ErrNotFound      = errors.New("scenario: not found")
+	ErrDisabled      = errors.New("scenario: disabled")
+	ErrParamRequired = errors.New("scenario: parameter is required")
+	ErrParamUnknown  = errors.New("scenario: unknown parameter")
+)
+
+// Param of scenario
+type Param struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	// Default value of the parameter when not set
+	Default string `json:"default,omitempty"`
+}
+
+// RunFunc run the scenario with the parameters and return result to the caller
+type RunFunc func(ctx context.Context, params map[string]string) (interface{}, error)
+
+// Scenario of debug
+type Scenario struct {
+	Name        string
+	Description string
+	Params      []Param
+	Run         RunFunc
+}
+
+// Info of scenario, used to list the scenarios
+type Info struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params"`
+	Enabled     bool    `json:"enabled"`
+}
+
+// Options of registry
+type Options struct {
+	// Enabled list of scenario name which can be run, use * to enable all scenarios
+	Enabled []string
+}
+
+// Registry of scenarios
+type Registry struct {
+	logger  logger.Logger
+	enabled map[string]bool
+
+	mu        sync.RWMutex
+	scenarios map[string]Scenario
+}
+
+// New registry of scenarios
+func New(logger logger.Logger, options *Options) *Registry {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	r := Registry{
+		logger:    logger,
+		enabled:   make(map[string]bool),
+		scenarios: make(map[string]Scenario),
+	}
+	for _, name := range opts.Enabled {
+		r.enabled[name] = true
+	}
+	return &r
+}
+
+// Register scenarios, scenario name must be unique
+func (r *Registry) Register(scenarios ...Scenario) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range scenarios {
+		if s.Name == "" {
+			return ErrNameEmpty
+		}
+		if s.Run == nil {
+			return fmt.Errorf("%w: %s", ErrRunNil, s.Name)
+		}
+		if _, ok := r.scenarios[s.Name]; ok {
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, s.Name)
+		}
+		r.scenarios[s.Name] = s
+	}
+	return nil
+}
+
+func (r *Registry) isEnabled(name string) bool {
+	return r.enabled[EnableAll] || r.enabled[name]
+}
+
+// List all registered scenarios sorted by name
+func (r *Registry) List() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]Info, 0, len(r.scenarios))
+	for _, s := range r.scenarios {
+		infos = append(infos, Info{
+			Name:        s.Name,
+			Description: s.Description,
+			Params:      s.Params,
+			Enabled:     r.isEnabled(s.Name),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Run the scenario by name, actor is the one who run the scenario and written to the audit log
+func (r *Registry) Run(ctx context.Context, name, actor string, params map[string]string) (interface{}, error) {
+	r.mu.RLock()
+	s, ok := r.scenarios[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	kv := logger.KV{
+		"scenario": name,
+		"actor":    actor,
+		"params":   params,
+	}
+	if !r.isEnabled(name) {
+		r.logger.Warnw("scenario: rejected disabled scenario", kv)
+		return nil, fmt.Errorf("%w: %s", ErrDisabled, name)
+	}
+
+	p, err := bindParams(s.Params, params)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := s.Run(ctx, p)
+	kv["duration"] = time.Since(start).String()
+	if err != nil {
+		kv["error"] = err.Error()
+		r.logger.Errorw("scenario: run failed", kv)
+		return nil, err
+	}
+	r.logger.Infow("scenario: run succeeded", kv)
+	return result, nil
+}
+
+// bindParams check the parameters and set the default value
+func bindParams(defs []Param, params map[string]string) (map[string]string, error) {
+	known := make(map[string]bool, len(defs))
+	p := make(map[string]string, len(defs))
+	for _, def := range defs {
+		known[def.Name] = true
+		value, ok := params[def.Name]
+		if !ok || value == "" {
+			if def.Required {
+				return nil, fmt.Errorf("%w: %s", ErrParamRequired, def.Name)
+			}
+			value = def.Default
+		}
+		p[def.Name] = value
+	}
+	for name := range params {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: %s", ErrParamUnknown, name)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,66 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+)
+
+func newScenario(name string) Scenario {
+	return Scenario{
+		Name: name,
+		Params: []Param{
+			{Name: "phone_number", Required: true},
+			{Name: "full_name", Default: "Test User"},
+		},
+		Run: func(ctx context.Context, params map[string]string) (interface{}, error) {
+			return params, nil
+		},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(logger, &Options{Enabled: []string{"create_test_user"}})
+	if err := r.Register(newScenario("create_test_user"), newScenario("seed_balance")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(newScenario("create_test_user")); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expecting error %v but got %v", ErrAlreadyExists, err)
+	}
+
+	infos := r.List()
+	if len(infos) != 2 || infos[0].Name != "create_test_user" || !infos[0].Enabled || infos[1].Enabled {
+		t.Fatalf("unexpected scenario list %+v", infos)
+	}
+
+	result, err := r.Run(context.Background(), "create_test_user", "tester", map[string]string{"phone_number": "0812"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := result.(map[string]string)
+	if params["full_name"] != "Test User" {
+		t.Fatalf("expecting default full_name but got %q", params["full_name"])
+	}
+
+	cases := []struct {
+		name   string
+		params map[string]string
+		err    error
+	}{
+		{name: "seed_balance", params: map[string]string{"phone_number": "0812"}, err: ErrDisabled},
+		{name: "unknown", err: ErrNotFound},
+		{name: "create_test_user", err: ErrParamRequired},
+		{name: "create_test_user", params: map[string]string{"phone_number": "0812", "pin": "1234"}, err: ErrParamUnknown},
+	}
+	for _, c := range cases {
+		if _, err := r.Run(context.Background(), c.name, "tester", c.params); !errors.Is(err, c.err) {
+			t.Errorf("%s: expecting error %v but got %v", c.name, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/albertwidi/go-project-example/debug/scenario"
+	orderentity "github.com/albertwidi/go-project-example/internal/entity/order"
+	userentity "github.com/albertwidi/go-project-example/internal/entity/user"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/seed"
+)
+
+// maxCount of entities created in a single run
+const maxCount = 100
+
+// ErrCountInvalid is returned when count is not between 1 and 100
+var ErrCountInvalid = errors.New("seed: count must be between 1 and 100")
+
+// DebugUsecase for seed
+type DebugUsecase struct {
+	db      *sqldb.DB
+	storage *objectstorage.Storage
+}
+
+// New seed debug usecase, storage can be nil and user avatar is not uploaded
+func New(db *sqldb.DB, storage *objectstorage.Storage) *DebugUsecase {
+	du := DebugUsecase{
+		db:      db,
+		storage: storage,
+	}
+	return &du
+}
+
+// Scenarios of seed debug
+func (du *DebugUsecase) Scenarios() []scenario.Scenario {
+	return []scenario.Scenario{
+		{
+			Name:        "seed_users",
+			Description: "create test users with fake data, the same seed always create the same users",
+			Params: []scenario.Param{
+				{Name: "count", Description: "number of users, maximum 100", Default: "1"},
+				{Name: "seed", Description: "seed of the fake data", Default: "1"},
+			},
+			Run: du.seedUsers,
+		},
+		{
+			Name:        "seed_orders",
+			Description: "create test orders with fake data for a user, the same seed always create the same orders",
+			Params: []scenario.Param{
+				{Name: "user_id", Description: "id of the order owner", Required: true},
+				{Name: "count", Description: "number of orders, maximum 100", Default: "1"},
+				{Name: "seed", Description: "seed of the fake data", Default: "1"},
+			},
+			Run: du.seedOrders,
+		},
+	}
+}
+
+// newSeeder return seeder and number of entities to create from the parameters
+func (du *DebugUsecase) newSeeder(params map[string]string) (*seed.Seeder, int, error) {
+	count, err := strconv.Atoi(params["count"])
+	if err != nil {
+		return nil, 0, err
+	}
+	if count < 1 || count > maxCount {
+		return nil, 0, ErrCountInvalid
+	}
+	seedValue, err := strconv.ParseInt(params["seed"], 10, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+	s, err := seed.New(du.db, du.storage, &seed.Options{Seed: seedValue})
+	if err != nil {
+		return nil, 0, err
+	}
+	return s, count, nil
+}
+
+func (du *DebugUsecase) seedUsers(ctx context.Context, params map[string]string) (interface{}, error) {
+	s, count, err := du.newSeeder(params)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]userentity.User, 0, count)
+	for i := 0; i < count; i++ {
+		u, err := s.CreateUser(ctx)
+		if err != nil {
+			return users, err
+		}
+		if du.storage != nil {
+			if _, err := s.CreateUserBio(ctx, u.ID); err != nil {
+				return users, err
+			}
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (du *DebugUsecase) seedOrders(ctx context.Context, params map[string]string) (interface{}, error) {
+	userID, err := strconv.ParseInt(params["user_id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	s, count, err := du.newSeeder(params)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]orderentity.Order, 0, count)
+	for i := 0; i < count; i++ {
+		o, err := s.CreateOrder(ctx, userID)
+		if err != nil {
+			return orders, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
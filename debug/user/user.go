@@ -1,18 +1,89 @@
 package user
 
-import "context"
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/albertwidi/go-project-example/debug/scenario"
+	otpentity "github.com/albertwidi/go-project-example/internal/entity/otp"
+	userentity "github.com/albertwidi/go-project-example/internal/entity/user"
+	userusecase "github.com/albertwidi/go-project-example/internal/usecase/user"
+)
 
 // DebugUsecase for user
 type DebugUsecase struct {
+	userUsecase userUsecase
+	otpUsecase  otpUsecase
+}
+
+type userUsecase interface {
+	Register(ctx context.Context, data userusecase.RegisterData) error
+}
+
+type otpUsecase interface {
+	Create(ctx context.Context, uniqueID string, codeLength otpentity.CodeLength, expire time.Duration) (*otpentity.OTP, error)
 }
 
-// New user debug usecase
-func New() *DebugUsecase {
-	du := DebugUsecase{}
+// New user debug usecase, scenario which usecase is nil is not available
+func New(userUsecase userUsecase, otpUsecase otpUsecase) *DebugUsecase {
+	du := DebugUsecase{
+		userUsecase: userUsecase,
+		otpUsecase:  otpUsecase,
+	}
 	return &du
 }
 
-// BypassLogin for bypassing log in to the project
-func (du *DebugUsecase) BypassLogin(ctx context.Context) error {
-	return nil
+// Scenarios of user debug
+func (du *DebugUsecase) Scenarios() []scenario.Scenario {
+	var scenarios []scenario.Scenario
+	if du.userUsecase != nil {
+		scenarios = append(scenarios, scenario.Scenario{
+			Name:        "create_test_user",
+			Description: "register a new user without otp confirmation",
+			Params: []scenario.Param{
+				{Name: "phone_number", Description: "phone number of the user", Required: true},
+				{Name: "full_name", Description: "full name of the user", Default: "Test User"},
+				{Name: "country", Description: "country of the user", Default: string(userentity.CountryID)},
+			},
+			Run: du.createTestUser,
+		})
+	}
+	if du.otpUsecase != nil {
+		scenarios = append(scenarios, scenario.Scenario{
+			Name:        "simulate_otp_success",
+			Description: "create otp and return the code, so the otp can be validated without receiving sms",
+			Params: []scenario.Param{
+				{Name: "unique_id", Description: "unique id of the otp, for example phone number", Required: true},
+				{Name: "code_length", Description: "length of the otp code, 4 or 6", Default: "6"},
+				{Name: "expire", Description: "expiry time of the otp", Default: "5m"},
+			},
+			Run: du.simulateOTPSuccess,
+		})
+	}
+	return scenarios
+}
+
+func (du *DebugUsecase) createTestUser(ctx context.Context, params map[string]string) (interface{}, error) {
+	data := userusecase.RegisterData{
+		Country:     userentity.Country(params["country"]),
+		PhoneNumber: params["phone_number"],
+		FullName:    params["full_name"],
+	}
+	if err := du.userUsecase.Register(ctx, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (du *DebugUsecase) simulateOTPSuccess(ctx context.Context, params map[string]string) (interface{}, error) {
+	length, err := strconv.Atoi(params["code_length"])
+	if err != nil {
+		return nil, err
+	}
+	expire, err := time.ParseDuration(params["expire"])
+	if err != nil {
+		return nil, err
+	}
+	return du.otpUsecase.Create(ctx, params["unique_id"], otpentity.CodeLength(length), expire)
 }
@@ -3,47 +3,59 @@ module github.com/albertwidi/go-project-example
 go 1.13
 
 require (
+	filippo.io/age v1.1.1
 	firebase.google.com/go v3.9.0+incompatible
 	github.com/BurntSushi/toml v0.3.1
-	github.com/aws/aws-sdk-go v1.25.21
+	github.com/ClickHouse/clickhouse-go/v2 v2.10.1
+	github.com/DATA-DOG/go-sqlmock v1.4.1
+	github.com/alicebob/miniredis/v2 v2.11.1
+	github.com/aws/aws-sdk-go v1.30.19
 	github.com/coreos/bbolt v1.3.3 // indirect
 	github.com/coreos/etcd v3.3.18+incompatible // indirect
 	github.com/coreos/go-semver v0.3.0 // indirect
 	github.com/coreos/pkg v0.0.0-20180928190104-399ea9e2e55f // indirect
 	github.com/cucumber/godog v0.9.0
-	github.com/go-sql-driver/mysql v1.4.1
-	github.com/golang/mock v1.3.1
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/gobuffalo/genny v0.1.1 // indirect
+	github.com/gobuffalo/gogen v0.1.1 // indirect
+	github.com/golang/mock v1.6.0
 	github.com/gomodule/redigo v2.0.0+incompatible
-	github.com/google/go-cmp v0.3.0
-	github.com/google/uuid v1.1.1
+	github.com/google/go-cmp v0.5.9
+	github.com/google/uuid v1.3.0
 	github.com/gorilla/mux v1.7.3
-	github.com/gorilla/websocket v1.4.1 // indirect
-	github.com/grpc-ecosystem/go-grpc-middleware v1.1.0 // indirect
 	github.com/grpc-ecosystem/go-grpc-prometheus v1.2.0 // indirect
+	github.com/jackc/pgconn v1.14.3
+	github.com/jackc/pgproto3/v2 v2.3.3
+	github.com/jackc/pgx/v4 v4.18.3
 	github.com/jmoiron/sqlx v1.2.0
-	github.com/jonboulle/clockwork v0.1.0 // indirect
-	github.com/lib/pq v1.1.1
+	github.com/karrick/godirwalk v1.10.3 // indirect
+	github.com/lib/pq v1.10.2
+	github.com/mattn/go-sqlite3 v1.9.0
+	github.com/microsoft/go-mssqldb v1.6.0
 	github.com/nsqio/go-nsq v1.0.8
 	github.com/oklog/ulid v1.3.1
-	github.com/prometheus/client_golang v1.0.0
-	github.com/prometheus/common v0.7.0 // indirect
+	github.com/prometheus/client_golang v1.11.1
+	github.com/prometheus/client_model v0.2.0
+	github.com/redis/go-redis/v9 v9.7.3
 	github.com/rs/zerolog v1.16.0
-	github.com/sirupsen/logrus v1.4.2
-	github.com/soheilhy/cmux v0.1.4 // indirect
-	github.com/stretchr/testify v1.5.1
-	github.com/tmc/grpc-websocket-proxy v0.0.0-20190109142713-0ad062ec5ee5 // indirect
+	github.com/sirupsen/logrus v1.8.1
+	github.com/stretchr/testify v1.8.4
+	github.com/xdg/scram v0.0.0-20180814205039-7eeb5667e42c // indirect
+	github.com/xdg/stringprep v0.0.0-20180714160509-73f8eece6fdc // indirect
 	github.com/xiang90/probing v0.0.0-20190116061207-43a291ad63a2 // indirect
-	go.etcd.io/bbolt v1.3.3 // indirect
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
 	go.etcd.io/etcd v3.3.18+incompatible
-	go.opencensus.io v0.22.1
-	go.uber.org/multierr v1.2.0 // indirect
-	go.uber.org/zap v1.11.0
+	go.mongodb.org/mongo-driver v1.11.1
+	go.opencensus.io v0.23.0
+	go.uber.org/zap v1.24.0
 	gocloud.dev v0.17.0
-	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	golang.org/x/crypto v0.20.0
+	golang.org/x/net v0.21.0
+	golang.org/x/oauth2 v0.0.0-20210819190943-2bc19b11175f
+	golang.org/x/sys v0.17.0
 	golang.org/x/tools/gopls v0.2.2 // indirect
-	google.golang.org/api v0.13.0
-	google.golang.org/grpc v1.24.0 // indirect
-	gopkg.in/yaml.v2 v2.2.8
-	sigs.k8s.io/yaml v1.1.0 // indirect
+	google.golang.org/api v0.43.0
+	google.golang.org/grpc v1.47.0
+	gopkg.in/yaml.v2 v2.4.0
 )
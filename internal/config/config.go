@@ -14,9 +14,19 @@ import (
 
 // DefaultConfig for the project
 type DefaultConfig struct {
-	Servers   DefaultServers `json:"servers" yaml:"servers" toml:"servers"`
-	Log       DefaultLog     `json:"log" yaml:"log" toml:"log"`
-	Resources kothak.Config  `json:"resources" yaml:"resources" toml:"resources"`
+	// Environment of the program, for example production, staging, dev, test or local
+	// it is only used to allow the dangerous redis commands in dev, test and local, see kothak.Config.Environment
+	Environment string         `json:"environment" yaml:"environment" toml:"environment"`
+	Servers     DefaultServers `json:"servers" yaml:"servers" toml:"servers"`
+	Log         DefaultLog     `json:"log" yaml:"log" toml:"log"`
+	Resources   kothak.Config  `json:"resources" yaml:"resources" toml:"resources"`
+}
+
+// ResourcesConfig return the configuration of the resources with the environment of the program
+func (c DefaultConfig) ResourcesConfig() kothak.Config {
+	resources := c.Resources
+	resources.Environment = c.Environment
+	return resources
 }
 
 // DefaultLog config for the project
@@ -28,14 +38,133 @@ type DefaultLog struct {
 
 // DefaultServers struct
 type DefaultServers struct {
-	Main  ServerConfig `json:"main" yaml:"main" toml:"main"`
-	Debug ServerConfig `json:"debug" yaml:"debug" toml:"debug"`
-	Admin ServerConfig `json:"admin" yaml:"admin" toml:"admin"`
+	Main  ServerConfig      `json:"main" yaml:"main" toml:"main"`
+	Debug DebugServerConfig `json:"debug" yaml:"debug" toml:"debug"`
+	Admin ServerConfig      `json:"admin" yaml:"admin" toml:"admin"`
+	// Tracing of server requests
+	Tracing TracingConfig `json:"tracing" yaml:"tracing" toml:"tracing"`
+	// HTTP is the list of named http servers run in the same process, for example public, internal and admin
+	HTTP []HTTPServerConfig `json:"http" yaml:"http" toml:"http"`
+	// ReusePort set SO_REUSEPORT to all listeners, so more than one process can listen to the same address
+	ReusePort bool `json:"reuse_port" yaml:"reuse_port" toml:"reuse_port"`
+	// GracefulRestart restart the program on SIGUSR2 without closing the listeners
+	GracefulRestart bool `json:"graceful_restart" yaml:"graceful_restart" toml:"graceful_restart"`
+	// RequestTimeout of all routes, the timeout is shortened by X-Request-Timeout header from the caller
+	RequestTimeout string `json:"request_timeout" yaml:"request_timeout" toml:"request_timeout"`
+	// RouteTimeouts override the request timeout by route path prefix, for example {"/v1/booking" = "10s"}
+	RouteTimeouts map[string]string `json:"route_timeouts" yaml:"route_timeouts" toml:"route_timeouts"`
+	// RetryBudget of every request, the sql, redis and http client retries of the request draw from the budget
+	RetryBudget RetryBudgetConfig `json:"retry_budget" yaml:"retry_budget" toml:"retry_budget"`
+	// SupportBundle of the admin server
+	SupportBundle SupportBundleConfig `json:"support_bundle" yaml:"support_bundle" toml:"support_bundle"`
+	// GoroutineMonitor of GET /debug/goroutines in admin server
+	GoroutineMonitor GoroutineMonitorConfig `json:"goroutine_monitor" yaml:"goroutine_monitor" toml:"goroutine_monitor"`
+	// LatencyReport of GET /debug/latency in admin server
+	LatencyReport LatencyReportConfig `json:"latency_report" yaml:"latency_report" toml:"latency_report"`
+}
+
+// LatencyReportConfig of the timeout and pool size recommendations from the latency of the sampled traces
+type LatencyReportConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" toml:"enabled"`
+	// Percentile of the latency which the suggested timeout is based on, default to 0.99
+	Percentile float64 `json:"percentile" yaml:"percentile" toml:"percentile"`
+	// Multiplier of the percentile latency to the suggested timeout, default to 1.5
+	Multiplier float64 `json:"multiplier" yaml:"multiplier" toml:"multiplier"`
+	// MinSamples of the operation before the timeout is suggested, default to 100
+	MinSamples int64 `json:"min_samples" yaml:"min_samples" toml:"min_samples"`
+}
+
+// GoroutineMonitorConfig of the goroutines per subsystem, the growth is alerted to the log
+type GoroutineMonitorConfig struct {
+	// Interval of snapshot, for example 30s
+	Interval string `json:"interval" yaml:"interval" toml:"interval" default:"30s"`
+	// Window is the number of recent snapshots to detect the growth
+	Window int `json:"window" yaml:"window" toml:"window" default:"10"`
+	// MinGrowth is the minimum increase of goroutines in the window which is alerted
+	MinGrowth int64 `json:"min_growth" yaml:"min_growth" toml:"min_growth" default:"100"`
+	// Limits of running goroutines per subsystem, for example {"eventbus" = 1000}
+	Limits map[string]int64 `json:"limits" yaml:"limits" toml:"limits"`
+}
+
+// RetryBudgetConfig of the retries per request, the budget is disabled when retries is zero
+type RetryBudgetConfig struct {
+	// Retries allowed before any call of the request succeed
+	Retries int `json:"retries" yaml:"retries" toml:"retries"`
+	// Ratio is the retries earned by every successful call, default to 0.1
+	Ratio float64 `json:"ratio" yaml:"ratio" toml:"ratio"`
+	// Max of the retries which can be earned, default to 10
+	Max int `json:"max" yaml:"max" toml:"max"`
+}
+
+// SupportBundleConfig of POST /debug/support-bundle in admin server
+type SupportBundleConfig struct {
+	// ObjectStorage is the name of object storage in resources to upload the bundle, the bundle is returned in the response when empty
+	ObjectStorage string `json:"object_storage" yaml:"object_storage" toml:"object_storage"`
+	// Prefix of the bundle key in the object storage
+	Prefix string `json:"prefix" yaml:"prefix" toml:"prefix" default:"support-bundle/"`
 }
 
 // ServerConfig struct
 type ServerConfig struct {
+	// Address of the server, tcp address, unix:<path> or systemd:<name>
+	Address string `yaml:"address" toml:"address"`
+}
+
+// TracingConfig of server requests
+type TracingConfig struct {
+	// SampleRate of requests between 0 and 1, default to opencensus default sampler
+	SampleRate float64 `json:"sample_rate" yaml:"sample_rate" toml:"sample_rate"`
+	// DebugToken force the sampling of request with X-Debug-Trace header of the token, the header is ignored when empty
+	DebugToken string `json:"debug_token" yaml:"debug_token" toml:"debug_token" protected:"1"`
+}
+
+// HTTPServerConfig of named http server
+type HTTPServerConfig struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// Address of the server, tcp address, unix:<path> or systemd:<name>
+	Address string `json:"address" yaml:"address" toml:"address"`
+	// Middlewares of the server by name, chained in order after the middlewares of all servers
+	Middlewares []string `json:"middlewares" yaml:"middlewares" toml:"middlewares"`
+	// RequestTimeout and RouteTimeouts of the server, the timeout can only be shorter than request_timeout of servers
+	RequestTimeout string            `json:"request_timeout" yaml:"request_timeout" toml:"request_timeout"`
+	RouteTimeouts  map[string]string `json:"route_timeouts" yaml:"route_timeouts" toml:"route_timeouts"`
+	TLS            TLSConfig         `json:"tls" yaml:"tls" toml:"tls"`
+}
+
+// TLSConfig of server, plain http is served when cert_file and key_file are empty
+type TLSConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file" toml:"key_file" protected:"1"`
+	// ClientCAFile require and verify client certificate with the ca
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file" toml:"client_ca_file"`
+}
+
+// DebugServerConfig struct
+type DebugServerConfig struct {
 	Address string `yaml:"address" toml:"address"`
+	// Scenarios is the list of debug scenario which can be run, use * to enable all scenarios
+	Scenarios []string `json:"scenarios" yaml:"scenarios" toml:"scenarios"`
+	// Seed resources used by seed scenarios, seed scenarios are not registered when the database is empty
+	Seed DebugSeedConfig `json:"seed" yaml:"seed" toml:"seed"`
+	// Email template preview, the email routes are not registered when the templates is empty
+	Email DebugEmailConfig `json:"email" yaml:"email" toml:"email"`
+}
+
+// DebugEmailConfig struct
+type DebugEmailConfig struct {
+	// Templates is the directory of email template json files, for example files/templates/email
+	Templates string `json:"templates" yaml:"templates" toml:"templates"`
+	// Allowlist of address or domain which can receive the preview, for example @example.com
+	// the preview cannot be sent when empty
+	Allowlist []string `json:"allowlist" yaml:"allowlist" toml:"allowlist"`
+}
+
+// DebugSeedConfig struct
+type DebugSeedConfig struct {
+	// Database is the name of sql database in resources
+	Database string `json:"database" yaml:"database" toml:"database"`
+	// ObjectStorage is the name of object storage in resources, user avatar is not uploaded when empty
+	ObjectStorage string `json:"object_storage" yaml:"object_storage" toml:"object_storage"`
 }
 
 // ParseFile for parsing config file and return DefaultConfig struct
@@ -54,13 +183,19 @@ func ParseFile(configFile string, dest interface{}, envFiles ...string) error {
 	if err != nil {
 		return err
 	}
+	ext := filepath.Ext(configFile)
+	s := secrets{}
+	// sops only encrypt the values of yaml config, toml config use age armored values instead
+	out, err = s.decryptSops(ext, out)
+	if err != nil {
+		return err
+	}
 	// replacing with environment variables
 	out, err = t.ReplaceBytes(out)
 	if err != nil {
 		return err
 	}
 
-	ext := filepath.Ext(configFile)
 	switch ext {
 	case ".toml":
 		err = toml.Unmarshal(out, dest)
@@ -73,7 +208,8 @@ func ParseFile(configFile string, dest interface{}, envFiles ...string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	// decrypt age armored values, for example password = """-----BEGIN AGE ENCRYPTED FILE-----..."""
+	return s.decryptValues(dest)
 }
 
 // Print configuration in json schema
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/kothak"
+)
+
+func TestResourcesConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.toml")
+	// environment in the resources is not the environment of the program
+	content := "environment = \"${TEST_ENVIRONMENT}\"\n\n[resources]\nenvironment = \"dev\"\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TEST_ENVIRONMENT", kothak.EnvironmentTest)
+	defer os.Unsetenv("TEST_ENVIRONMENT")
+
+	var c DefaultConfig
+	if err := ParseFile(file, &c); err != nil {
+		t.Fatal(err)
+	}
+	if environment := c.ResourcesConfig().Environment; environment != kothak.EnvironmentTest {
+		t.Fatalf("expecting the environment of the resources is %s but got %s", kothak.EnvironmentTest, environment)
+	}
+	if c.Resources.Environment != "" {
+		t.Fatalf("expecting the environment of the resources is not from the config but got %s", c.Resources.Environment)
+	}
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/albertwidi/go-project-example/internal/pkg/age"
+	"github.com/albertwidi/go-project-example/internal/pkg/sops"
+)
+
+// list of environment variable of the age identities, the same as sops
+const (
+	EnvAgeKey     = "SOPS_AGE_KEY"
+	EnvAgeKeyFile = "SOPS_AGE_KEY_FILE"
+)
+
+// list of secret error
+var (
+	// ErrNoAgeKey returned when the config is encrypted but no age identity is available
+	ErrNoAgeKey = errors.New("config: encrypted config needs age identity from SOPS_AGE_KEY or SOPS_AGE_KEY_FILE")
+	// ErrSopsNotSupported returned when the config of the format which is not supported by sops has sops metadata
+	ErrSopsNotSupported = errors.New("config: sops encrypted config is only supported in yaml, use age armored values instead")
+)
+
+// secrets decrypt the encrypted values of the config, the identities are loaded on the first encrypted value
+// so the identities are not needed when the config is not encrypted
+type secrets struct {
+	identities []*age.Identity
+}
+
+// loadIdentities from SOPS_AGE_KEY, SOPS_AGE_KEY_FILE and the default keys.txt of sops
+func (s *secrets) loadIdentities() ([]*age.Identity, error) {
+	if s.identities != nil {
+		return s.identities, nil
+	}
+
+	var content []string
+	if key := os.Getenv(EnvAgeKey); key != "" {
+		content = append(content, key)
+	}
+	keyFile := os.Getenv(EnvAgeKeyFile)
+	if keyFile == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			if _, err := os.Stat(filepath.Join(dir, "sops", "age", "keys.txt")); err == nil {
+				keyFile = filepath.Join(dir, "sops", "age", "keys.txt")
+			}
+		}
+	}
+	if keyFile != "" {
+		out, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read age key file: %w", err)
+		}
+		content = append(content, string(out))
+	}
+	if len(content) == 0 {
+		return nil, ErrNoAgeKey
+	}
+
+	identities, err := age.ParseIdentities(strings.NewReader(strings.Join(content, "\n")))
+	if err != nil {
+		return nil, err
+	}
+	s.identities = identities
+	return identities, nil
+}
+
+// decryptSops decrypt the config when it is encrypted by sops, ext is the file extension of the config
+// sops cannot encrypt toml, so toml config with sops metadata is rejected instead of used with the encrypted values
+func (s *secrets) decryptSops(ext string, content []byte) ([]byte, error) {
+	switch ext {
+	case ".yaml":
+		if !sops.IsEncrypted(content) {
+			return content, nil
+		}
+		identities, err := s.loadIdentities()
+		if err != nil {
+			return nil, err
+		}
+		return sops.DecryptYAML(content, identities...)
+	case ".toml":
+		// the invalid toml is returned as is, so the error is returned when the config is parsed
+		var doc map[string]interface{}
+		if _, err := toml.Decode(string(content), &doc); err == nil {
+			if _, ok := doc["sops"]; ok {
+				return nil, fmt.Errorf("%w: %s", ErrSopsNotSupported, ext)
+			}
+		}
+	}
+	return content, nil
+}
+
+// decryptValues decrypt all string values of dest which are age armored file
+func (s *secrets) decryptValues(dest interface{}) error {
+	return s.decryptValue(reflect.ValueOf(dest), "")
+}
+
+func (s *secrets) decryptValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return s.decryptValue(v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		for idx := 0; idx < v.NumField(); idx++ {
+			if t.Field(idx).PkgPath != "" {
+				continue
+			}
+			if err := s.decryptValue(v.Field(idx), strings.TrimPrefix(path+"."+t.Field(idx).Name, ".")); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < v.Len(); idx++ {
+			if err := s.decryptValue(v.Index(idx), fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			for _, key := range v.MapKeys() {
+				if err := s.decryptValue(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		// map value is not addressable, so the decrypted value is set to the map
+		for _, key := range v.MapKeys() {
+			plaintext, ok, err := s.decryptString(v.MapIndex(key).String(), fmt.Sprintf("%s[%v]", path, key))
+			if err != nil {
+				return err
+			}
+			if ok {
+				v.SetMapIndex(key, reflect.ValueOf(plaintext).Convert(v.Type().Elem()))
+			}
+		}
+	case reflect.String:
+		plaintext, ok, err := s.decryptString(v.String(), path)
+		if err != nil {
+			return err
+		}
+		if ok && v.CanSet() {
+			v.SetString(plaintext)
+		}
+	}
+	return nil
+}
+
+// decryptString return the plaintext and true when the value is age armored file
+func (s *secrets) decryptString(value, path string) (string, bool, error) {
+	if !age.IsArmored([]byte(value)) {
+		return "", false, nil
+	}
+	identities, err := s.loadIdentities()
+	if err != nil {
+		return "", false, err
+	}
+	plaintext, err := age.Decrypt([]byte(value), identities...)
+	if err != nil {
+		return "", false, fmt.Errorf("config: failed to decrypt %s: %w", path, err)
+	}
+	return string(plaintext), true, nil
+}
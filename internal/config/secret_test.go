@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileSops(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv(EnvAgeKeyFile, "../pkg/sops/testdata/key.txt")
+	defer os.Unsetenv(EnvAgeKeyFile)
+
+	type config struct {
+		Resources struct {
+			Databases []struct {
+				Password string `yaml:"password" toml:"password"`
+			} `yaml:"databases" toml:"databases"`
+		} `yaml:"resources" toml:"resources"`
+	}
+
+	var yamlConfig config
+	if err := ParseFile("../pkg/sops/testdata/secrets.enc.yaml", &yamlConfig); err != nil {
+		t.Fatal(err)
+	}
+	if len(yamlConfig.Resources.Databases) != 1 || yamlConfig.Resources.Databases[0].Password != "secret" {
+		t.Fatalf("expecting decrypted password but got %+v", yamlConfig.Resources)
+	}
+
+	// sops cannot encrypt toml, so the sops metadata in toml is rejected instead of using the encrypted values
+	tomlFile := filepath.Join(dir, "config.toml")
+	content := "[[resources.databases]]\npassword = \"ENC[AES256_GCM,data:KdQtKtAy,iv:USV6geTEUlBDXCh/kIDrmnu2nQyoxWWRAUVgZ541osQ=,tag:9tNRL+GjXbZzrZXKAklTAg==,type:str]\"\n\n[sops]\nversion = \"3.8.1\"\n"
+	if err := ioutil.WriteFile(tomlFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var tomlConfig config
+	if err := ParseFile(tomlFile, &tomlConfig); !errors.Is(err, ErrSopsNotSupported) {
+		t.Fatalf("expecting error %v but got %v", ErrSopsNotSupported, err)
+	}
+}
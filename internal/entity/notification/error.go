@@ -0,0 +1,12 @@
+package notification
+
+import "errors"
+
+// list of notification errors
+var (
+	ErrChannelInvalid  = errors.New("notification: channel is not valid")
+	ErrUserIDEmpty     = errors.New("notification: user id is empty")
+	ErrRecipientsEmpty = errors.New("notification: recipients is empty")
+	ErrAdapterNotFound = errors.New("notification: adapter for channel not found")
+	ErrDeliveryFailed  = errors.New("notification: delivery failed")
+)
@@ -0,0 +1,131 @@
+package notification
+
+import (
+	"time"
+)
+
+// Channel of notification delivery
+type Channel string
+
+// list of notification channel
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+)
+
+// Validate channel value
+func (c Channel) Validate() error {
+	switch c {
+	case ChannelEmail, ChannelSMS, ChannelPush:
+		return nil
+	}
+	return ErrChannelInvalid
+}
+
+// IsUrgent return true if the notification purpose should bypass quiet hours
+func (p Purpose) IsUrgent() bool {
+	switch p {
+	case PurposeAuthenticationOTP, PurposeAuthenticationPayment, PurposeAuthenticationWithdraw:
+		return true
+	}
+	return false
+}
+
+// DeliveryStatus of a notification delivery
+type DeliveryStatus int
+
+// list of delivery status
+const (
+	DeliveryStatusPending DeliveryStatus = iota
+	DeliveryStatusSent
+	DeliveryStatusDelivered
+	DeliveryStatusFailed
+	// skipped because user disable the channel
+	DeliveryStatusSkippedPreference
+	// skipped because the message arrived in user quiet hours
+	DeliveryStatusSkippedQuietHours
+	// skipped because the same message already sent in dedup window
+	DeliveryStatusSkippedDuplicate
+)
+
+// Preference of user notification per channel
+type Preference struct {
+	UserID  int64   `db:"user_id"`
+	Channel Channel `db:"channel"`
+	Enabled bool    `db:"enabled"`
+	// quiet hours is written in minutes since midnight, in user timezone.
+	// start and end with the same value means no quiet hours
+	QuietHoursStart int       `db:"quiet_hours_start"`
+	QuietHoursEnd   int       `db:"quiet_hours_end"`
+	TimeZone        string    `db:"time_zone"`
+	CreatedAt       time.Time `db:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at"`
+}
+
+// InQuietHours return true if t is within the quiet hours of the preference
+// quiet hours is allowed to pass midnight, for example 22:00 - 06:00
+func (p Preference) InQuietHours(t time.Time) bool {
+	if p.QuietHoursStart == p.QuietHoursEnd {
+		return false
+	}
+
+	if p.TimeZone != "" {
+		loc, err := time.LoadLocation(p.TimeZone)
+		if err == nil {
+			t = t.In(loc)
+		}
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+	if p.QuietHoursStart < p.QuietHoursEnd {
+		return minutes >= p.QuietHoursStart && minutes < p.QuietHoursEnd
+	}
+	return minutes >= p.QuietHoursStart || minutes < p.QuietHoursEnd
+}
+
+// RouteMessage is a message to be routed to user preferred channels
+type RouteMessage struct {
+	UserID  int64
+	Purpose Purpose
+	Title   string
+	Message string
+	// Recipients of the message per channel
+	// for example device token for push, msisdn for sms and email address for email
+	Recipients map[Channel]string
+	// DedupKey is used to identify the same message within dedup window
+	// dedup is not checked when the key is empty
+	DedupKey string
+}
+
+// Validate route message
+func (rm RouteMessage) Validate() error {
+	if rm.UserID == 0 {
+		return ErrUserIDEmpty
+	}
+	if len(rm.Recipients) == 0 {
+		return ErrRecipientsEmpty
+	}
+	for channel := range rm.Recipients {
+		if err := channel.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delivery of a message to one channel
+type Delivery struct {
+	ID                string
+	UserID            int64
+	Channel           Channel
+	Purpose           Purpose
+	Recipient         string
+	Title             string
+	Message           string
+	Status            DeliveryStatus
+	ProviderMessageID string
+	Error             string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
@@ -0,0 +1,56 @@
+package notification_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/entity/notification"
+)
+
+func TestInQuietHours(t *testing.T) {
+	cases := []struct {
+		name   string
+		pref   notification.Preference
+		time   time.Time
+		expect bool
+	}{
+		{
+			name:   "no quiet hours",
+			pref:   notification.Preference{},
+			time:   time.Date(2020, 1, 1, 23, 0, 0, 0, time.UTC),
+			expect: false,
+		},
+		{
+			name:   "within same day quiet hours",
+			pref:   notification.Preference{QuietHoursStart: 12 * 60, QuietHoursEnd: 13 * 60},
+			time:   time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC),
+			expect: true,
+		},
+		{
+			name:   "outside same day quiet hours",
+			pref:   notification.Preference{QuietHoursStart: 12 * 60, QuietHoursEnd: 13 * 60},
+			time:   time.Date(2020, 1, 1, 13, 0, 0, 0, time.UTC),
+			expect: false,
+		},
+		{
+			name:   "quiet hours passing midnight",
+			pref:   notification.Preference{QuietHoursStart: 22 * 60, QuietHoursEnd: 6 * 60},
+			time:   time.Date(2020, 1, 1, 2, 0, 0, 0, time.UTC),
+			expect: true,
+		},
+		{
+			name:   "quiet hours in user timezone",
+			pref:   notification.Preference{QuietHoursStart: 22 * 60, QuietHoursEnd: 6 * 60, TimeZone: "Asia/Jakarta"},
+			time:   time.Date(2020, 1, 1, 16, 0, 0, 0, time.UTC),
+			expect: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.pref.InQuietHours(c.time); got != c.expect {
+				t.Errorf("expecting %v but got %v", c.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package kothak
+
+import (
+	"fmt"
+	"sync"
+)
+
+// AliasConfig of resource alias, the alias is another name of the resource while the code is migrated to the new name
+// for example userdb which point to userdb-v2, the use of the alias is logged as deprecated
+type AliasConfig struct {
+	// Kind of the resource, the same as the kind of depends_on, for example database or redis
+	Kind string `json:"kind" yaml:"kind" toml:"kind"`
+	// Name of the alias, for example the old name of the resource
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// Target is the name of the resource which the alias point to, the target must not be an alias
+	Target string `json:"target" yaml:"target" toml:"target"`
+	// Namespace of the alias and the target
+	Namespace string `json:"namespace" yaml:"namespace" toml:"namespace"`
+}
+
+// aliasKinds is the list of resource kind which can have alias
+var aliasKinds = map[string]bool{
+	kindObjectStorage: true,
+	kindRedis:         true,
+	kindDatabase:      true,
+	kindMongoDB:       true,
+	kindSearch:        true,
+	kindGRPCClient:    true,
+	kindHTTPClient:    true,
+}
+
+// aliases of the resources, keyed by the kind and the qualified name of the alias
+type aliases struct {
+	targets map[resourceKey]string
+	mu      sync.Mutex
+	warned  map[resourceKey]bool
+}
+
+func newAliases(configs []AliasConfig) *aliases {
+	a := aliases{
+		targets: make(map[resourceKey]string, len(configs)),
+		warned:  make(map[resourceKey]bool),
+	}
+	for _, c := range configs {
+		a.targets[resourceKey{kind: c.Kind, name: QualifiedName(c.Namespace, c.Name)}] = QualifiedName(c.Namespace, c.Target)
+	}
+	return &a
+}
+
+// resolveAlias return the name of the resource which the alias point to, or the name when it is not an alias
+// the deprecation is logged once per alias, and every use is counted in kothak_resource_alias_usage_total
+func (k *Kothak) resolveAlias(kind, name string) string {
+	if k.aliases == nil {
+		return name
+	}
+	key := resourceKey{kind: kind, name: name}
+	target, ok := k.aliases.targets[key]
+	if !ok {
+		return name
+	}
+	k.metrics.aliasUsed(kind, name)
+
+	k.aliases.mu.Lock()
+	warned := k.aliases.warned[key]
+	k.aliases.warned[key] = true
+	k.aliases.mu.Unlock()
+	if !warned {
+		k.logger.Warnf("kothak: %s %s is deprecated, use %s instead", kind, name, target)
+	}
+	return target
+}
+
+// validateAliases check the kind and the target of the aliases, names is the set of resource names by kind
+func validateAliases(configs []AliasConfig, names map[string]string) []error {
+	var (
+		errs  []error
+		alias = make(map[string]bool, len(configs))
+	)
+	for _, c := range configs {
+		alias[c.Kind+"/"+QualifiedName(c.Namespace, c.Name)] = true
+	}
+	for idx, c := range configs {
+		switch {
+		case !aliasKinds[c.Kind]:
+			errs = append(errs, fmt.Errorf("aliases[%d]: kind %q is not supported", idx, c.Kind))
+		case c.Name == "" || c.Target == "":
+			errs = append(errs, fmt.Errorf("aliases[%d]: name and target must not be empty", idx))
+		case alias[c.Kind+"/"+QualifiedName(c.Namespace, c.Target)]:
+			errs = append(errs, fmt.Errorf("%s %s: alias target %s is an alias", c.Kind, c.Name, c.Target))
+		default:
+			if _, ok := names[c.Kind+"/"+QualifiedName(c.Namespace, c.Target)]; !ok {
+				errs = append(errs, fmt.Errorf("%s %s: alias target %s does not exists", c.Kind, c.Name, c.Target))
+			}
+		}
+	}
+	return errs
+}
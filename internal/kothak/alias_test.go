@@ -0,0 +1,58 @@
+package kothak
+
+import (
+	"context"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+func TestResolveAlias(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewFromResources(Resources{SQLDBs: map[string]*sqldb.DB{"userdb-v2": db, QualifiedName("tenant-a", "userdb-v2"): db}}, logger)
+	defer k.CloseAll()
+	k.aliases = newAliases([]AliasConfig{
+		{Kind: kindDatabase, Name: "userdb", Target: "userdb-v2"},
+		{Kind: kindDatabase, Name: "userdb", Namespace: "tenant-a", Target: "userdb-v2"},
+	})
+
+	for i := 0; i < 2; i++ {
+		got, err := k.GetSQLDB("userdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != db {
+			t.Fatal("expecting the database of the alias target")
+		}
+	}
+	if _, err := k.Namespace("tenant-a").GetSQLDB("userdb"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.GetRedis("userdb"); err == nil {
+		t.Fatal("expecting the alias is only resolved for its kind")
+	}
+
+	key := resourceKey{kind: kindDatabase, name: "userdb"}
+	if !k.aliases.warned[key] {
+		t.Fatal("expecting the deprecation of the alias is logged")
+	}
+	if usage := k.metrics.aliasUsage[key]; usage != 2 {
+		t.Fatalf("expecting 2 usages of the alias but got %v", usage)
+	}
+	if usage := k.metrics.aliasUsage[resourceKey{kind: kindDatabase, name: QualifiedName("tenant-a", "userdb")}]; usage != 1 {
+		t.Fatalf("expecting 1 usage of the namespaced alias but got %v", usage)
+	}
+}
@@ -0,0 +1,36 @@
+package kothak
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// sqlAttributionHook record the queries of the database, the operation is read or write
+// every retry attempt is recorded, as every attempt is executed in the database
+func sqlAttributionHook(m *metrics, name string) sqldb.Hook {
+	return func(ctx context.Context, event sqldb.QueryEvent) {
+		operation := "write"
+		if sqldb.IsReadQuery(event.Query) {
+			operation = "read"
+		}
+		m.observeOperation(ctx, kindDatabase, name, operation, event.Duration)
+	}
+}
+
+// redisAttributionHook record the commands of redis, the operation is the lowercase command
+func redisAttributionHook(m *metrics, name string) func(ctx context.Context, cmd string, duration time.Duration, err error) {
+	return func(ctx context.Context, cmd string, duration time.Duration, err error) {
+		m.observeOperation(ctx, kindRedis, name, strings.ToLower(cmd), duration)
+	}
+}
+
+// objectStorageAttributionHook record the operations of object storage
+func objectStorageAttributionHook(m *metrics, name string) objectstorage.Hook {
+	return func(ctx context.Context, event objectstorage.OperationEvent) {
+		m.observeOperation(ctx, kindObjectStorage, name, event.Operation, event.Duration)
+	}
+}
@@ -0,0 +1,58 @@
+package kothak
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+func TestAttributionHooks(t *testing.T) {
+	m := newMetrics()
+	labels := attribution.Labels{Endpoint: "/v1/booking", Tenant: "acme", Feature: "checkout"}
+	ctx := attribution.WithLabels(context.Background(), labels)
+
+	sqlHook := sqlAttributionHook(m, "users")
+	sqlHook(ctx, sqldb.QueryEvent{Query: "SELECT id FROM users", Duration: time.Second})
+	sqlHook(ctx, sqldb.QueryEvent{Query: "SELECT id FROM users", Duration: time.Second})
+	sqlHook(ctx, sqldb.QueryEvent{Query: "UPDATE users SET name = $1", Duration: time.Second})
+	redisAttributionHook(m, "session")(ctx, "GET", time.Millisecond, nil)
+	objectStorageAttributionHook(m, "image")(context.Background(), objectstorage.OperationEvent{Operation: objectstorage.OperationUpload})
+
+	cases := []struct {
+		key      operationKey
+		count    float64
+		duration float64
+	}{
+		{
+			key:      operationKey{resourceKey{kindDatabase, "users"}, "read", labels},
+			count:    2,
+			duration: 2,
+		},
+		{
+			key:      operationKey{resourceKey{kindDatabase, "users"}, "write", labels},
+			count:    1,
+			duration: 1,
+		},
+		{
+			key:      operationKey{resourceKey{kindRedis, "session"}, "get", labels},
+			count:    1,
+			duration: 0.001,
+		},
+		{
+			key:   operationKey{resourceKey{kindObjectStorage, "image"}, objectstorage.OperationUpload, attribution.Labels{}},
+			count: 1,
+		},
+	}
+	for _, c := range cases {
+		if m.operations[c.key] != c.count {
+			t.Errorf("%+v: expecting %v operations but got %v", c.key, c.count, m.operations[c.key])
+		}
+		if m.operationDuration[c.key] != c.duration {
+			t.Errorf("%+v: expecting %v seconds but got %v", c.key, c.duration, m.operationDuration[c.key])
+		}
+	}
+}
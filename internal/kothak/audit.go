@@ -0,0 +1,89 @@
+package kothak
+
+import (
+	"context"
+	"sync"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// SQLDBAuditConfig of the audit of the write queries, see sqldb.Auditor
+type SQLDBAuditConfig struct {
+	// Enabled record the write queries of the database to the audit sink of kothak
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+}
+
+// options of the audit, the labels are the endpoint and the tenant of the attribution labels
+func (auditConfig SQLDBAuditConfig) options(name string, sink sqldb.AuditSink, log logger.Logger) *sqldb.AuditOptions {
+	return &sqldb.AuditOptions{
+		Database: name,
+		Sink:     sink,
+		Labels: func(ctx context.Context) map[string]string {
+			labels := attribution.FromContext(ctx)
+			return map[string]string{"route": labels.Endpoint, "tenant": labels.Tenant}
+		},
+		OnError: func(entry sqldb.AuditEntry, err error) {
+			log.Errorw("kothak: failed to audit query", logger.KV{
+				"resource":  name,
+				"statement": entry.Statement,
+				"actor":     entry.Actor,
+				"error":     err.Error(),
+			})
+		},
+	}
+}
+
+// auditSink of all audited databases, the entries are written to the log until the sink is replaced by SetAuditSink
+type auditSink struct {
+	mu   sync.RWMutex
+	sink sqldb.AuditSink
+}
+
+func newAuditSink(log logger.Logger) *auditSink {
+	return &auditSink{sink: logAuditSink(log)}
+}
+
+// Audit implements sqldb.AuditSink
+func (as *auditSink) Audit(ctx context.Context, entry sqldb.AuditEntry) error {
+	as.mu.RLock()
+	sink := as.sink
+	as.mu.RUnlock()
+	return sink.Audit(ctx, entry)
+}
+
+// logAuditSink write the entries to the log
+func logAuditSink(log logger.Logger) sqldb.AuditSink {
+	return sqldb.AuditSinkFunc(func(ctx context.Context, entry sqldb.AuditEntry) error {
+		kv := logger.KV{
+			"resource":      entry.Database,
+			"actor":         entry.Actor,
+			"statement":     entry.Statement,
+			"args":          entry.Args,
+			"tx":            entry.Tx,
+			"rows_affected": entry.RowsAffected,
+			"duration":      entry.Duration.String(),
+			"created_at":    entry.CreatedAt,
+		}
+		for k, v := range entry.Labels {
+			kv[k] = v
+		}
+		if entry.Error != "" {
+			kv["error"] = entry.Error
+		}
+		log.Infow("kothak: sql audit", kv)
+		return nil
+	})
+}
+
+// SetAuditSink replace the sink of the audited databases, for example to ship the entries to the compliance storage
+// the entries are written to the log when the sink is nil
+func (k *Kothak) SetAuditSink(sink sqldb.AuditSink) {
+	if sink == nil {
+		sink = logAuditSink(k.logger)
+	}
+	k.audit.mu.Lock()
+	k.audit.sink = sink
+	k.audit.mu.Unlock()
+}
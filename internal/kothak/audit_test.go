@@ -0,0 +1,40 @@
+package kothak
+
+import (
+	"context"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+func TestAuditSink(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewFromResources(Resources{}, logger)
+
+	opts := SQLDBAuditConfig{Enabled: true}.options("billing", k.audit, logger)
+	ctx := attribution.WithLabels(context.Background(), attribution.Labels{Endpoint: "/invoices", Tenant: "acme"})
+	if labels := opts.Labels(ctx); labels["route"] != "/invoices" || labels["tenant"] != "acme" {
+		t.Fatalf("expecting route and tenant from the attribution labels but got %v", labels)
+	}
+	// the entries are written to the log by default
+	if err := k.audit.Audit(ctx, sqldb.AuditEntry{Database: "billing", Statement: "DELETE FROM invoices"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []sqldb.AuditEntry
+	k.SetAuditSink(sqldb.AuditSinkFunc(func(ctx context.Context, entry sqldb.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	if err := k.audit.Audit(ctx, sqldb.AuditEntry{Database: "billing", Statement: "DELETE FROM invoices"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Database != "billing" {
+		t.Fatalf("expecting the entry in the replaced sink but got %+v", entries)
+	}
+}
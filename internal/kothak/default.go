@@ -0,0 +1,131 @@
+package kothak
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// list of default resource error
+var (
+	ErrNoDefaultSQLDB         = errors.New("kothak: default sql database is not set")
+	ErrNoDefaultRedis         = errors.New("kothak: default redis is not set")
+	ErrNoDefaultObjectStorage = errors.New("kothak: default object storage is not set")
+)
+
+// setDefaultResources set name of default resources from config
+// validation of multiple default is done in Validate
+func (k *Kothak) setDefaultResources(config Config) {
+	for _, dbconfig := range config.DBConfig.SQLDBs {
+		if dbconfig.Default {
+			k.defaultDB = dbconfig.Name
+		}
+	}
+	for _, redisconfig := range config.RedisConfig.Rds {
+		if redisconfig.Default {
+			k.defaultRedis = redisconfig.Name
+		}
+	}
+	for _, objconfig := range config.ObjectStorageConfig {
+		if objconfig.Default {
+			k.defaultObjStorage = objconfig.Name
+		}
+	}
+}
+
+// defaultName return the default resource name of a kind
+// when default is not set and there is only one resource of the kind, the resource is used as default
+func defaultName(name string, names []string) (string, bool) {
+	if name != "" {
+		return name, true
+	}
+	if len(names) == 1 {
+		return names[0], true
+	}
+	return "", false
+}
+
+// GetDefaultSQLDB return the sql database marked as default
+// if no database is marked as default and only one database exists, that database is returned
+func (k *Kothak) GetDefaultSQLDB() (*sqldb.DB, error) {
+	k.mutex.Lock()
+	names := make([]string, 0, len(k.dbs))
+	for name := range k.dbs {
+		names = append(names, name)
+	}
+	name, ok := defaultName(k.defaultDB, names)
+	k.mutex.Unlock()
+
+	if !ok {
+		return nil, ErrNoDefaultSQLDB
+	}
+	return k.GetSQLDB(name)
+}
+
+// MustGetDefaultSQLDB return the default sql database or exit the program
+func (k *Kothak) MustGetDefaultSQLDB() *sqldb.DB {
+	db, err := k.GetDefaultSQLDB()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return db
+}
+
+// GetDefaultRedis return the redis marked as default
+// if no redis is marked as default and only one redis exists, that redis is returned
+func (k *Kothak) GetDefaultRedis() (redis.Redis, error) {
+	k.mutex.Lock()
+	names := make([]string, 0, len(k.rds))
+	for name := range k.rds {
+		names = append(names, name)
+	}
+	name, ok := defaultName(k.defaultRedis, names)
+	k.mutex.Unlock()
+
+	if !ok {
+		return nil, ErrNoDefaultRedis
+	}
+	return k.GetRedis(name)
+}
+
+// MustGetDefaultRedis return the default redis or exit the program
+func (k *Kothak) MustGetDefaultRedis() redis.Redis {
+	r, err := k.GetDefaultRedis()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return r
+}
+
+// GetDefaultObjectStorage return the object storage marked as default
+// if no object storage is marked as default and only one object storage exists, that object storage is returned
+func (k *Kothak) GetDefaultObjectStorage() (*objectstorage.Storage, error) {
+	k.mutex.Lock()
+	names := make([]string, 0, len(k.objStorages))
+	for name := range k.objStorages {
+		names = append(names, name)
+	}
+	name, ok := defaultName(k.defaultObjStorage, names)
+	k.mutex.Unlock()
+
+	if !ok {
+		return nil, ErrNoDefaultObjectStorage
+	}
+	return k.GetObjectStorage(name)
+}
+
+// MustGetDefaultObjectStorage return the default object storage or exit the program
+func (k *Kothak) MustGetDefaultObjectStorage() *objectstorage.Storage {
+	o, err := k.GetDefaultObjectStorage()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return o
+}
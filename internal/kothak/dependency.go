@@ -0,0 +1,244 @@
+package kothak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+)
+
+// list of resource kind, used as prefix of depends_on
+const (
+	kindObjectStorage = "object_storage"
+	kindRedis         = "redis"
+	kindDatabase      = "database"
+	kindMongoDB       = "mongodb"
+	kindSearch        = "elasticsearch"
+	kindGRPCClient    = "grpc_clients"
+	kindHTTPClient    = "http_clients"
+)
+
+// list of dependency error
+var (
+	ErrDependencyNotFound = errors.New("kothak: dependency not found")
+	ErrDependencyCycle    = errors.New("kothak: dependency cycle")
+)
+
+// initTask initialize a single resource
+type initTask struct {
+	kind      string
+	name      string
+	dependsOn []string
+	fn        func(ctx context.Context) error
+}
+
+func (t initTask) key() string {
+	return t.kind + "/" + t.name
+}
+
+type initTasks []initTask
+
+func (tasks *initTasks) add(kind, name string, dependsOn []string, fn func(ctx context.Context) error) {
+	*tasks = append(*tasks, initTask{kind: kind, name: name, dependsOn: dependsOn, fn: fn})
+}
+
+// configTasks return tasks without init function, to check the dependencies of the configuration
+func configTasks(config Config) initTasks {
+	var tasks initTasks
+	for _, c := range config.ObjectStorageConfig {
+		tasks.add(kindObjectStorage, QualifiedName(c.Namespace, c.Name), c.dependsOn(), nil)
+	}
+	for _, c := range config.RedisConfig.Rds {
+		tasks.add(kindRedis, QualifiedName(c.Namespace, c.Name), c.DependsOn, nil)
+	}
+	for _, c := range config.DBConfig.databases() {
+		tasks.add(kindDatabase, QualifiedName(c.Namespace, c.Name), c.dependsOn(), nil)
+	}
+	for _, c := range config.MongoDBConfig.MongoDBs {
+		tasks.add(kindMongoDB, QualifiedName(c.Namespace, c.Name), c.DependsOn, nil)
+	}
+	for _, c := range config.SearchConfig {
+		tasks.add(kindSearch, QualifiedName(c.Namespace, c.Name), c.DependsOn, nil)
+	}
+	for _, c := range config.GRPCClientConfig {
+		tasks.add(kindGRPCClient, QualifiedName(c.Namespace, c.Name), c.DependsOn, nil)
+	}
+	for _, c := range config.HTTPClientConfig {
+		tasks.add(kindHTTPClient, QualifiedName(c.Namespace, c.Name), c.DependsOn, nil)
+	}
+	return tasks
+}
+
+// resolve the dependencies of namespaced resources
+// dependency is resolved to the resource in the same namespace first, then to the resource without namespace
+func (tasks initTasks) resolve() initTasks {
+	keys := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		keys[t.key()] = true
+	}
+	resolved := make(initTasks, len(tasks))
+	for i, t := range tasks {
+		resolved[i] = t
+		namespace, _ := splitQualifiedName(t.name)
+		if namespace == "" || len(t.dependsOn) == 0 {
+			continue
+		}
+		deps := make([]string, len(t.dependsOn))
+		for j, dep := range t.dependsOn {
+			deps[j] = dep
+			idx := strings.Index(dep, "/")
+			if idx < 0 {
+				continue
+			}
+			// resource can depend on the resource without namespace with the same name
+			if key := dep[:idx+1] + QualifiedName(namespace, dep[idx+1:]); keys[key] && key != t.key() {
+				deps[j] = key
+			}
+		}
+		resolved[i].dependsOn = deps
+	}
+	return resolved
+}
+
+// validate return error for each unknown dependency and dependency cycle
+func (tasks initTasks) validate() []error {
+	tasks = tasks.resolve()
+	var (
+		errs  []error
+		index = make(map[string]int, len(tasks))
+	)
+	for i, t := range tasks {
+		index[t.key()] = i
+	}
+	for _, t := range tasks {
+		for _, dep := range t.dependsOn {
+			if _, ok := index[dep]; !ok {
+				errs = append(errs, fmt.Errorf("%w: %s depends on %s", ErrDependencyNotFound, t.key(), dep))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// depth first search to find cycle, every cycle is reported once from its smallest key
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		state = make([]int, len(tasks))
+		path  []string
+		visit func(i int)
+	)
+	visit = func(i int) {
+		state[i] = visiting
+		path = append(path, tasks[i].key())
+		for _, dep := range tasks[i].dependsOn {
+			j := index[dep]
+			switch state[j] {
+			case visiting:
+				start := 0
+				for k, key := range path {
+					if key == dep {
+						start = k
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), dep)
+				errs = append(errs, fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> ")))
+			case unvisited:
+				visit(j)
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+	}
+
+	keys := make([]int, len(tasks))
+	for i := range tasks {
+		keys[i] = i
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		return tasks[keys[a]].key() < tasks[keys[b]].key()
+	})
+	for _, i := range keys {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return errs
+}
+
+// run the tasks in the group, a task is started when all of its dependencies are initialized
+// independent tasks run concurrently bounded by the group limit
+// observe is invoked with the duration and error of each task when not nil
+func (tasks initTasks) run(ctx context.Context, group *concurrent.Group, observe func(kind, name string, duration time.Duration, err error)) error {
+	if errs := tasks.validate(); len(errs) > 0 {
+		return errs[0]
+	}
+	tasks = tasks.resolve()
+
+	var (
+		pending    = make([]int, len(tasks))
+		dependents = make(map[string][]int)
+		// buffered so finished tasks never block when the scheduler stop receiving
+		done = make(chan string, len(tasks))
+	)
+	for i, t := range tasks {
+		deps := make(map[string]struct{})
+		for _, dep := range t.dependsOn {
+			deps[dep] = struct{}{}
+		}
+		pending[i] = len(deps)
+		for dep := range deps {
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+
+	start := func(t initTask) {
+		group.Go(func(ctx context.Context) error {
+			start := time.Now()
+			err := t.fn(ctx)
+			if observe != nil {
+				observe(t.kind, t.name, time.Since(start), err)
+			}
+			if err != nil {
+				return err
+			}
+			done <- t.key()
+			return nil
+		})
+	}
+
+	for i, t := range tasks {
+		if pending[i] == 0 {
+			start(t)
+		}
+	}
+
+	completed := 0
+	for completed < len(tasks) {
+		select {
+		case key := <-done:
+			completed++
+			for _, i := range dependents[key] {
+				pending[i]--
+				if pending[i] == 0 {
+					start(tasks[i])
+				}
+			}
+		case <-ctx.Done():
+			// the group context is canceled when a task failed
+			if err := group.Wait(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+	return group.Wait()
+}
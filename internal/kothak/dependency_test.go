@@ -0,0 +1,87 @@
+package kothak
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+)
+
+func TestInitTasksOrder(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(key string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, key)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	var tasks initTasks
+	// the dependent is added before its dependencies, with limit 1 to make sure the scheduler is not blocked
+	tasks.add(kindObjectStorage, "cache", []string{"database/users", "redis/session"}, record("object_storage/cache"))
+	tasks.add(kindRedis, "session", []string{"database/users"}, record("redis/session"))
+	tasks.add(kindDatabase, "users", nil, record("database/users"))
+	tasks.add(kindDatabase, "orders", nil, record("database/orders"))
+
+	group, ctx := concurrent.NewGroup(context.Background(), "test", 1)
+	if err := tasks.run(ctx, group, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	position := make(map[string]int)
+	for i, key := range order {
+		position[key] = i
+	}
+	if len(position) != 4 {
+		t.Fatalf("expecting 4 tasks to run but got %v", order)
+	}
+	if position["database/users"] > position["redis/session"] || position["redis/session"] > position["object_storage/cache"] {
+		t.Fatalf("tasks are not run in dependency order: %v", order)
+	}
+}
+
+func TestInitTasksFailedDependency(t *testing.T) {
+	errFailed := errors.New("failed")
+	var dependentRun bool
+
+	var tasks initTasks
+	tasks.add(kindDatabase, "users", nil, func(ctx context.Context) error {
+		return errFailed
+	})
+	tasks.add(kindRedis, "session", []string{"database/users"}, func(ctx context.Context) error {
+		dependentRun = true
+		return nil
+	})
+
+	group, ctx := concurrent.NewGroup(context.Background(), "test", 0)
+	if err := tasks.run(ctx, group, nil); !errors.Is(err, errFailed) {
+		t.Fatalf("expecting error %v but got %v", errFailed, err)
+	}
+	if dependentRun {
+		t.Fatal("dependent should not run when its dependency failed")
+	}
+}
+
+func TestInitTasksValidate(t *testing.T) {
+	var tasks initTasks
+	tasks.add(kindDatabase, "users", []string{"redis/session"}, nil)
+	tasks.add(kindRedis, "session", []string{"database/users"}, nil)
+	errs := tasks.validate()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrDependencyCycle) {
+		t.Fatalf("expecting one cycle error but got %v", errs)
+	}
+
+	tasks = nil
+	tasks.add(kindDatabase, "users", []string{"database/unknown"}, nil)
+	errs = tasks.validate()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrDependencyNotFound) {
+		t.Fatalf("expecting one not found error but got %v", errs)
+	}
+}
@@ -0,0 +1,125 @@
+package kothak
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/supportbundle"
+)
+
+// recentLogSize is the number of recent slow queries and health checks kept for the support bundle
+const recentLogSize = 100
+
+// healthCheckKey is read from redis to check the connection, the key doesn't need to exist
+const healthCheckKey = "kothak:health_check"
+
+// healthKindSchema is the kind of the schema drift check of the sql database with migrations
+const healthKindSchema = "schema"
+
+// HealthCheck result of a resource
+type HealthCheck struct {
+	Resource string        `json:"resource"`
+	Kind     string        `json:"kind"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// CheckHealth ping the leader of every sql database, every redis and every mongodb, and check the schema drift of every
+// sql database with migrations. the results are sorted by kind and name, and kept in the recent health checks of the support bundle
+func (k *Kothak) CheckHealth(ctx context.Context) []HealthCheck {
+	type check struct {
+		name, kind string
+		ping       func(ctx context.Context) error
+	}
+	var checks []check
+	k.mutex.Lock()
+	for name, db := range k.dbs {
+		checks = append(checks, check{name: name, kind: kindDatabase, ping: db.Leader().PingContext})
+	}
+	for name := range k.migrations {
+		name := name
+		checks = append(checks, check{name: name, kind: healthKindSchema, ping: func(ctx context.Context) error {
+			drift, err := k.SchemaDrift(ctx, name)
+			if errors.Is(err, sqldb.ErrDriverNotSupported) {
+				// the schema of clickhouse is not inspected
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if drift.Drifted() {
+				return driftError(drift)
+			}
+			return nil
+		}})
+	}
+	for name, rds := range k.rds {
+		rds := rds
+		checks = append(checks, check{name: name, kind: kindRedis, ping: func(ctx context.Context) error {
+			_, err := rds.Get(ctx, healthCheckKey)
+			if rds.IsErrNil(err) {
+				return nil
+			}
+			return err
+		}})
+	}
+	for name, mdb := range k.mdbs {
+		mdb := mdb
+		checks = append(checks, check{name: name, kind: kindMongoDB, ping: func(ctx context.Context) error {
+			return mdb.Client().Ping(ctx, nil)
+		}})
+	}
+	k.mutex.Unlock()
+
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].kind != checks[j].kind {
+			return checks[i].kind < checks[j].kind
+		}
+		return checks[i].name < checks[j].name
+	})
+	results := make([]HealthCheck, len(checks))
+	for idx, c := range checks {
+		start := time.Now()
+		err := c.ping(ctx)
+		results[idx] = HealthCheck{
+			Resource: c.name,
+			Kind:     c.kind,
+			Healthy:  err == nil,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			results[idx].Error = err.Error()
+		}
+	}
+	k.healthChecks.Append(results)
+	return results
+}
+
+// AddSupportBundle add the sections of the resources to the support bundle
+// the health of the resources is checked when the bundle is created
+func (k *Kothak) AddSupportBundle(b *supportbundle.Bundle) error {
+	if err := b.AddJSON("resources/stats.json", func(ctx context.Context) (interface{}, error) {
+		return k.Stats(), nil
+	}); err != nil {
+		return err
+	}
+	if err := b.AddJSON("resources/health_checks.json", func(ctx context.Context) (interface{}, error) {
+		k.CheckHealth(ctx)
+		return k.healthChecks.Entries(), nil
+	}); err != nil {
+		return err
+	}
+	if err := b.AddJSON("resources/slow_queries.json", func(ctx context.Context) (interface{}, error) {
+		return k.slowQueries.Entries(), nil
+	}); err != nil {
+		return err
+	}
+	// the statement of the digest label of the query metrics
+	return b.AddJSON("resources/query_digests.json", func(ctx context.Context) (interface{}, error) {
+		return k.metrics.queryDigests(), nil
+	})
+}
@@ -0,0 +1,78 @@
+package kothak
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/supportbundle"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestCheckHealth(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDB := func(pingErr error) *sqldb.DB {
+		mockdb, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.ExpectPing().WillReturnError(pingErr)
+		leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+		db, err := sqldb.Wrap(context.Background(), leader, leader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	users := newDB(nil)
+	defer users.Close()
+	orders := newDB(errors.New("connection refused"))
+	defer orders.Close()
+
+	k := NewFromResources(Resources{
+		SQLDBs: map[string]*sqldb.DB{"users": users, "orders": orders},
+	}, logger)
+	hook := slowQueryHook(logger, k.slowQueries, "users", time.Millisecond)
+	hook(context.Background(), sqldb.QueryEvent{Query: "SELECT * FROM users WHERE id = 1", Duration: time.Second, Leader: true})
+
+	b := supportbundle.New(&supportbundle.Options{NoProfiles: true})
+	if err := k.AddSupportBundle(b); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	manifest, err := b.Write(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Sections) != 4 || len(manifest.Errors) != 0 {
+		t.Fatalf("expecting 4 sections without error but got %v %v", manifest.Sections, manifest.Errors)
+	}
+
+	entries := k.healthChecks.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expecting 1 health check but got %d", len(entries))
+	}
+	results := entries[0].Value.([]HealthCheck)
+	if len(results) != 2 {
+		t.Fatalf("expecting 2 results but got %v", results)
+	}
+	if results[0].Resource != "orders" || results[0].Healthy || results[0].Error != "connection refused" {
+		t.Fatalf("expecting unhealthy orders but got %+v", results[0])
+	}
+	if results[1].Resource != "users" || !results[1].Healthy {
+		t.Fatalf("expecting healthy users but got %+v", results[1])
+	}
+
+	slow := k.slowQueries.Entries()
+	if len(slow) != 1 {
+		t.Fatalf("expecting 1 slow query but got %d", len(slow))
+	}
+}
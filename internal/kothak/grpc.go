@@ -0,0 +1,119 @@
+package kothak
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/grpcclient"
+	"google.golang.org/grpc"
+)
+
+// GRPCClientConfig of grpc client connection
+type GRPCClientConfig struct {
+	Name   string `json:"name" yaml:"name" toml:"name"`
+	Target string `json:"target" yaml:"target" toml:"target"`
+	// LoadBalancingPolicy pick_first|round_robin
+	LoadBalancingPolicy string              `json:"load_balancing_policy" yaml:"load_balancing_policy" toml:"load_balancing_policy"`
+	Block               bool                `json:"block" yaml:"block" toml:"block"`
+	DialTimeout         string              `json:"dial_timeout" yaml:"dial_timeout" toml:"dial_timeout"`
+	TLS                 GRPCTLSConfig       `json:"tls" yaml:"tls" toml:"tls"`
+	Keepalive           GRPCKeepaliveConfig `json:"keepalive" yaml:"keepalive" toml:"keepalive"`
+	// Namespace of the resource, for example tenant name, resource with namespace is retrieved via Kothak.Namespace
+	Namespace string `json:"namespace" yaml:"namespace" toml:"namespace"`
+	// DependsOn list resources initialized before this resource, formatted as kind/name, for example database/users
+	DependsOn []string `json:"depends_on" yaml:"depends_on" toml:"depends_on"`
+}
+
+// GRPCTLSConfig of grpc client connection
+type GRPCTLSConfig struct {
+	Enabled            bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	CAFile             string `json:"ca_file" yaml:"ca_file" toml:"ca_file"`
+	CertFile           string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile            string `json:"key_file" yaml:"key_file" toml:"key_file"`
+	ServerName         string `json:"server_name" yaml:"server_name" toml:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+}
+
+// GRPCKeepaliveConfig of grpc client connection
+type GRPCKeepaliveConfig struct {
+	Time                string `json:"time" yaml:"time" toml:"time"`
+	Timeout             string `json:"timeout" yaml:"timeout" toml:"timeout"`
+	PermitWithoutStream bool   `json:"permit_without_stream" yaml:"permit_without_stream" toml:"permit_without_stream"`
+}
+
+func (c GRPCClientConfig) toClientConfig() (grpcclient.Config, error) {
+	conf := grpcclient.Config{
+		Target:              c.Target,
+		LoadBalancingPolicy: c.LoadBalancingPolicy,
+		Block:               c.Block,
+		TLS: grpcclient.TLSConfig{
+			Enabled:            c.TLS.Enabled,
+			CAFile:             c.TLS.CAFile,
+			CertFile:           c.TLS.CertFile,
+			KeyFile:            c.TLS.KeyFile,
+			ServerName:         c.TLS.ServerName,
+			InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		},
+		Keepalive: grpcclient.KeepaliveConfig{
+			PermitWithoutStream: c.Keepalive.PermitWithoutStream,
+		},
+	}
+	durations := []struct {
+		value string
+		dest  *time.Duration
+	}{
+		{c.DialTimeout, &conf.DialTimeout},
+		{c.Keepalive.Time, &conf.Keepalive.Time},
+		{c.Keepalive.Timeout, &conf.Keepalive.Timeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.value)
+		if err != nil {
+			return conf, err
+		}
+		*d.dest = dur
+	}
+	return conf, nil
+}
+
+func dialGRPCClient(ctx context.Context, config GRPCClientConfig) (*grpc.ClientConn, error) {
+	conf, err := config.toClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return grpcclient.Dial(ctx, &conf)
+}
+
+func (k *Kothak) setGRPCClient(name string, conn *grpc.ClientConn) {
+	k.mutex.Lock()
+	k.grpcClients[name] = conn
+	k.mutex.Unlock()
+}
+
+// GetGRPCClient from kothak object
+func (k *Kothak) GetGRPCClient(clientName string) (*grpc.ClientConn, error) {
+	clientName = k.resolveAlias(kindGRPCClient, clientName)
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	i, ok := k.grpcClients[clientName]
+	if !ok {
+		err := fmt.Errorf("kothak: grpc client with name %s does not exists", clientName)
+		return nil, err
+	}
+	return i, nil
+}
+
+// MustGetGRPCClient from kothak object
+func (k *Kothak) MustGetGRPCClient(clientName string) *grpc.ClientConn {
+	conn, err := k.GetGRPCClient(clientName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return conn
+}
@@ -0,0 +1,117 @@
+package kothak
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	httpclient "github.com/albertwidi/go-project-example/internal/pkg/http/client"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+)
+
+// HTTPClientConfig of http client
+// duration is formatted as time.Duration string, for example 5s
+type HTTPClientConfig struct {
+	Name                  string `json:"name" yaml:"name" toml:"name"`
+	Timeout               string `json:"timeout" yaml:"timeout" toml:"timeout"`
+	DialTimeout           string `json:"dial_timeout" yaml:"dial_timeout" toml:"dial_timeout"`
+	KeepAlive             string `json:"keep_alive" yaml:"keep_alive" toml:"keep_alive"`
+	TLSHandshakeTimeout   string `json:"tls_handshake_timeout" yaml:"tls_handshake_timeout" toml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout string `json:"response_header_timeout" yaml:"response_header_timeout" toml:"response_header_timeout"`
+	IdleConnTimeout       string `json:"idle_conn_timeout" yaml:"idle_conn_timeout" toml:"idle_conn_timeout"`
+	MaxIdleConns          int    `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int    `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host" toml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int    `json:"max_conns_per_host" yaml:"max_conns_per_host" toml:"max_conns_per_host"`
+	DisableKeepAlives     bool   `json:"disable_keep_alives" yaml:"disable_keep_alives" toml:"disable_keep_alives"`
+	// Proxy url, proxy from environment variable is used when empty, set to direct to not use any proxy
+	Proxy string `json:"proxy" yaml:"proxy" toml:"proxy" protected:"1"`
+	// MaxRetry is the number of attempts of a request, the request is not retried when less than 2
+	MaxRetry      int    `json:"max_retry" yaml:"max_retry" toml:"max_retry"`
+	RetryInterval string `json:"retry_interval" yaml:"retry_interval" toml:"retry_interval"`
+	DisableTrace  bool   `json:"disable_trace" yaml:"disable_trace" toml:"disable_trace"`
+	// Namespace of the resource, for example tenant name, resource with namespace is retrieved via Kothak.Namespace
+	Namespace string `json:"namespace" yaml:"namespace" toml:"namespace"`
+	// DependsOn list resources initialized before this resource, formatted as kind/name, for example database/users
+	DependsOn []string `json:"depends_on" yaml:"depends_on" toml:"depends_on"`
+}
+
+func (c HTTPClientConfig) toClientConfig() (httpclient.Config, error) {
+	conf := httpclient.Config{
+		MaxIdleConns:        c.MaxIdleConns,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     c.MaxConnsPerHost,
+		DisableKeepAlives:   c.DisableKeepAlives,
+		Proxy:               c.Proxy,
+		DisableTracing:      c.DisableTrace,
+	}
+	retryInterval := time.Millisecond * 100
+	durations := []struct {
+		value string
+		dest  *time.Duration
+	}{
+		{c.Timeout, &conf.Timeout},
+		{c.DialTimeout, &conf.DialTimeout},
+		{c.KeepAlive, &conf.KeepAlive},
+		{c.TLSHandshakeTimeout, &conf.TLSHandshakeTimeout},
+		{c.ResponseHeaderTimeout, &conf.ResponseHeaderTimeout},
+		{c.IdleConnTimeout, &conf.IdleConnTimeout},
+		{c.RetryInterval, &retryInterval},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.value)
+		if err != nil {
+			return conf, err
+		}
+		*d.dest = dur
+	}
+	if c.MaxRetry > 1 {
+		conf.Retry = &retry.Policy{
+			MaxAttempts:     c.MaxRetry,
+			InitialInterval: retryInterval,
+			MaxInterval:     time.Second * 5,
+			Jitter:          0.2,
+		}
+	}
+	return conf, nil
+}
+
+func newHTTPClient(config HTTPClientConfig) (*http.Client, error) {
+	conf, err := config.toClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return httpclient.NewClient(&conf)
+}
+
+func (k *Kothak) setHTTPClient(name string, client *http.Client) {
+	k.mutex.Lock()
+	k.httpClients[name] = client
+	k.mutex.Unlock()
+}
+
+// GetHTTPClient from kothak object
+func (k *Kothak) GetHTTPClient(clientName string) (*http.Client, error) {
+	clientName = k.resolveAlias(kindHTTPClient, clientName)
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	i, ok := k.httpClients[clientName]
+	if !ok {
+		err := fmt.Errorf("kothak: http client with name %s does not exists", clientName)
+		return nil, err
+	}
+	return i, nil
+}
+
+// MustGetHTTPClient from kothak object
+func (k *Kothak) MustGetHTTPClient(clientName string) *http.Client {
+	c, err := k.GetHTTPClient(clientName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return c
+}
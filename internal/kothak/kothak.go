@@ -4,27 +4,89 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/albertwidi/go-project-example/internal/pkg/breaker"
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+	"github.com/albertwidi/go-project-example/internal/pkg/defaults"
+	"github.com/albertwidi/go-project-example/internal/pkg/lifecycle"
 	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/mongodb"
 	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
 	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/gcs"
 	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/local"
 	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/s3"
 	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/goredis"
 	redigo "github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/albertwidi/go-project-example/internal/pkg/search"
 	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/matview"
+	"github.com/albertwidi/go-project-example/internal/pkg/supportbundle"
 	"github.com/jmoiron/sqlx"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+)
+
+// list of environment where the dangerous redis commands are allowed by default
+// the commands are blocked in any other environment, including the empty environment
+const (
+	EnvironmentDev   = "dev"
+	EnvironmentTest  = "test"
+	EnvironmentLocal = "local"
 )
 
 // Config of kothak
 type Config struct {
+	// Environment of the program, set from the environment of the project configuration instead of the resources configuration
+	// the dangerous redis commands are blocked by default unless the environment is dev, test or local, see RedisConnConfig.AllowedCommands
+	Environment string `json:"-" yaml:"-" toml:"-"`
+	// MaxConcurrentInit is the maximum number of resources initialized at the same time, default to 10
+	MaxConcurrentInit   int                   `json:"max_concurrent_init" yaml:"max_concurrent_init" toml:"max_concurrent_init" default:"10"`
 	DBConfig            DBConfig              `json:"database" yaml:"database" toml:"database"`
 	RedisConfig         RedisConfig           `json:"redis" yaml:"redis" toml:"redis"`
 	ObjectStorageConfig []ObjectStorageConfig `json:"object_storage" yaml:"object_storage" toml:"object_storage"`
+	MongoDBConfig       MongoDBConfig         `json:"mongodb" yaml:"mongodb" toml:"mongodb"`
+	SearchConfig        []SearchConfig        `json:"elasticsearch" yaml:"elasticsearch" toml:"elasticsearch"`
+	GRPCClientConfig    []GRPCClientConfig    `json:"grpc_clients" yaml:"grpc_clients" toml:"grpc_clients"`
+	HTTPClientConfig    []HTTPClientConfig    `json:"http_clients" yaml:"http_clients" toml:"http_clients"`
+	// Attribution record the operations of sql database, redis and object storage by the attribution labels of the request
+	// see package attribution for the labels
+	Attribution bool `json:"attribution" yaml:"attribution" toml:"attribution"`
+	// Aliases of the resources, so the code can be migrated to the new name of the resource gradually
+	Aliases []AliasConfig `json:"aliases" yaml:"aliases" toml:"aliases"`
+}
+
+// SetDefault set default value of all resources configuration
+// connection config of database inherit the value from database config when not set
+func (c *Config) SetDefault() error {
+	if err := defaults.SetDefault(c); err != nil {
+		return err
+	}
+	if err := c.DBConfig.SetDefault(); err != nil {
+		return err
+	}
+	for i := range c.DBConfig.SQLDBs {
+		dbconfig := &c.DBConfig.SQLDBs[i]
+		if err := dbconfig.LeaderConnConfig.SetDefault(c.DBConfig); err != nil {
+			return err
+		}
+		if dbconfig.ReplicaConnConfig.configured() {
+			if err := dbconfig.ReplicaConnConfig.SetDefault(c.DBConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if err := defaults.SetDefault(&c.MongoDBConfig); err != nil {
+		return err
+	}
+	return nil
 }
 
 // Kothak struct
@@ -32,7 +94,30 @@ type Kothak struct {
 	objStorages map[string]*objectstorage.Storage
 	dbs         map[string]*sqldb.DB
 	rds         map[string]redis.Redis
+	mdbs        map[string]*mongodb.DB
+	search      map[string]*search.Client
+	grpcClients map[string]*grpc.ClientConn
+	httpClients map[string]*http.Client
 	logger      logger.Logger
+	metrics     *metrics
+	// attribution is true when the operations of resources are recorded by attribution labels
+	attribution bool
+	// migrations directory of sql database
+	migrations map[string]string
+	// materialized views of sql database, and their refresher
+	views      map[string][]matview.View
+	refreshers map[string]*matview.Refresher
+	// recent slow queries and health checks for the support bundle
+	slowQueries  *supportbundle.Log
+	healthChecks *supportbundle.Log
+	// audit sink of the databases with audit enabled
+	audit *auditSink
+	// aliases of the resources, nil when there is no alias
+	aliases *aliases
+	// name of default resources
+	defaultDB         string
+	defaultRedis      string
+	defaultObjStorage string
 	// this mutex is used in two place
 	// the usage shared because the usage is not collide
 	// 1. when we initialize all the connections
@@ -52,235 +137,590 @@ func (k *Kothak) setRedis(name string, rds redis.Redis) {
 	k.mutex.Unlock()
 }
 
-func (k *Kothak) setObjectStorage(name string, obj objectstorage.StorageProvider) {
+func (k *Kothak) setObjectStorage(name string, storage *objectstorage.Storage) {
 	k.mutex.Lock()
-	k.objStorages[name] = objectstorage.New(obj)
+	k.objStorages[name] = storage
 	k.mutex.Unlock()
 }
 
+// newObjectStorage return the storage of the provider, with the attribution hook when it is enabled
+func (k *Kothak) newObjectStorage(name string, obj objectstorage.StorageProvider) *objectstorage.Storage {
+	storage := objectstorage.New(obj)
+	if k.attribution {
+		storage.AddHook(objectStorageAttributionHook(k.metrics, name))
+	}
+	return storage
+}
+
 // New kothak instance
 func New(ctx context.Context, kothakConfig Config, logger logger.Logger) (*Kothak, error) {
 	ctx, span := trace.StartSpan(ctx, "ktohak/new")
 	defer span.End()
 
-	var (
-		kothak = Kothak{
-			objStorages: make(map[string]*objectstorage.Storage),
-			dbs:         make(map[string]*sqldb.DB),
-			rds:         make(map[string]redis.Redis),
-			logger:      logger,
-		}
-
-		group = sync.WaitGroup{}
-		errs  []error
-		err   error
-	)
+	kothak := Kothak{
+		objStorages: make(map[string]*objectstorage.Storage),
+		dbs:         make(map[string]*sqldb.DB),
+		rds:         make(map[string]redis.Redis),
+		mdbs:        make(map[string]*mongodb.DB),
+		search:      make(map[string]*search.Client),
+		grpcClients: make(map[string]*grpc.ClientConn),
+		httpClients: make(map[string]*http.Client),
+		migrations:  make(map[string]string),
+		views:       make(map[string][]matview.View),
+		refreshers:  make(map[string]*matview.Refresher),
+		logger:      logger,
+		metrics:     newMetrics(),
+		attribution: kothakConfig.Attribution,
+		aliases:     newAliases(kothakConfig.Aliases),
+
+		slowQueries:  supportbundle.NewLog(recentLogSize),
+		healthChecks: supportbundle.NewLog(recentLogSize),
+		audit:        newAuditSink(logger),
+	}
 
-	// set default configuration for DBConfig
-	if err := kothakConfig.DBConfig.SetDefault(); err != nil {
+	// set default configuration for all resources
+	if err := kothakConfig.SetDefault(); err != nil {
 		return nil, err
 	}
+	kothak.setDefaultResources(kothakConfig)
+
+	// resources are initialized in the order of their dependencies
+	var tasks initTasks
 
 	// connect to object storage
 	for _, objStorageConfig := range kothakConfig.ObjectStorageConfig {
-		group.Add(1)
-		go func(config ObjectStorageConfig) {
-			_, span = trace.StartSpan(ctx, fmt.Sprintf("object_storage/init/%s", config.Name))
-			defer func() {
-				span.End()
-				group.Done()
-			}()
+		config := objStorageConfig
+		name := QualifiedName(config.Namespace, config.Name)
+		spanName := fmt.Sprintf("object_storage/init/%s", name)
+		tasks.add(kindObjectStorage, name, config.dependsOn(), func(ctx context.Context) error {
+			ctx, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
+
+			var provider objectstorage.StorageProvider
+			policy := newRetryPolicy(config.MaxRetry)
+			policy.OnRetry = func(attempt int, err error, wait time.Duration) {
+				kothak.metrics.reconnect(kindObjectStorage, name)
+			}
+			err := retry.Do(ctx, policy, func(ctx context.Context) error {
+				var err error
+				// the provider might keep the context, for example to refresh gcs token
+				// so the context should not be canceled when all initialization is done
+				provider, err = newObjectStorageProvider(safego.Detach(ctx), config)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			storage := kothak.newObjectStorage(name, provider)
+			if config.Cache.Enabled {
+				opts, err := config.Cache.options(logger, name)
+				if err != nil {
+					provider.Close()
+					return fmt.Errorf("cache: %w", err)
+				}
+				if config.Cache.Redis != "" {
+					opts.Redis, err = kothak.namespacedRedis(config.Namespace, config.Cache.Redis)
+					if err != nil {
+						provider.Close()
+						return fmt.Errorf("cache: %w", err)
+					}
+				}
+				storage.SetCache(opts)
+			}
+
+			logger.Debugf("kothak: Connected to object_storage %s", name)
+
+			kothak.setObjectStorage(name, storage)
+			return nil
+		})
+	}
+
+	// connect to redis
+	for _, rdsConfig := range kothakConfig.RedisConfig.Rds {
+		redisconfig := rdsConfig
+		name := QualifiedName(redisconfig.Namespace, redisconfig.Name)
+		spanName := fmt.Sprintf("redis/init/%s", name)
+		tasks.add(kindRedis, name, redisconfig.DependsOn, func(ctx context.Context) error {
+			ctx, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
+
+			conf := redigo.Config{
+				MaxActive: kothakConfig.RedisConfig.MaxActive,
+				MaxIdle:   kothakConfig.RedisConfig.MaxIdle,
+				Timeout:   kothakConfig.RedisConfig.Timeout,
+				DialRetry: kothakConfig.RedisConfig.MaxRetry,
+				OnRetry: func(attempt int, err error) {
+					kothak.metrics.reconnect(kindRedis, name)
+				},
+				Username: redisconfig.Username,
+				Password: redisconfig.Password,
+				TLS:      redisconfig.TLS.tls(),
+
+				AllowedCommands: redisconfig.allowedCommands(kothakConfig.Environment),
+			}
+			if kothak.attribution {
+				conf.OnCommand = redisAttributionHook(kothak.metrics, name)
+			}
 
 			var (
-				provider objectstorage.StorageProvider
-				err      error
+				r   redis.Redis
+				err error
 			)
+			switch {
+			case redisconfig.Backend == redisBackendGoRedis:
+				var goredisConf *goredis.Config
+				if goredisConf, err = redisconfig.goredis(conf); err == nil {
+					r, err = goredis.New(ctx, goredisConf)
+				}
+			case redisconfig.Mode == redisModeCluster:
+				r, err = redigo.NewCluster(ctx, redisconfig.seeds(), &conf)
+			case redisconfig.Mode == redisModeSentinel:
+				r, err = redigo.NewSentinel(ctx, redisconfig.MasterName, redisconfig.seeds(), &conf)
+			default:
+				r, err = redigo.New(ctx, redisconfig.Address, &conf)
+			}
+			if err != nil {
+				return err
+			}
 
-			switch strings.ToLower(config.Provider) {
-			// local storage
-			case objectstorage.StorageLocal:
-				// defaulted to not delete local bucket when close the program
-				provider, err = local.New(ctx, fmt.Sprintf("./%s", config.Bucket), &local.Options{DeleteOnClose: false})
+			logger.Debugf("Kothak: Connected to Redis %s", name)
 
-			// gcs compatible storage
-			case objectstorage.StorageGCS:
-				gcsCreds, err := gcs.CredentialsFromFile(ctx, config.GCS.JSONKey)
-				if err != nil {
-					errs = append(errs, err)
-					return
-				}
+			kothak.setRedis(name, r)
+			return nil
+		})
+	}
 
-				gcsConfig, err := gcs.NewConfig(ctx, gcsCreds)
-				if err != nil {
-					errs = append(errs, err)
-					return
-				}
-				gcsConfig.
-					SetBucket(config.Bucket).
-					SetBucketProto(config.BucketProto).
-					SetBucketURL(config.BucketURL)
+	// connect to database, every pool of the database is connected as another database
+	for _, sqldbConfig := range kothakConfig.DBConfig.databases() {
+		dbconfig := sqldbConfig
+		name := QualifiedName(dbconfig.Namespace, dbconfig.Name)
+		spanName := fmt.Sprintf("database/connect/%s", name)
+		if dbconfig.Migrations != "" {
+			kothak.migrations[name] = dbconfig.Migrations
+		}
+		if len(dbconfig.MaterializedViews) > 0 {
+			views, err := dbconfig.views()
+			if err != nil {
+				return nil, fmt.Errorf("kothak: database %s: %w", name, err)
+			}
+			kothak.views[name] = views
+		}
+		tasks.add(kindDatabase, name, dbconfig.dependsOn(), func(ctx context.Context) error {
+			ctx, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
 
-				provider, err = gcs.New(ctx, gcsConfig)
+			db, err := connectSQLDB(ctx, kothakConfig.DBConfig, dbconfig)
+			if err != nil {
+				return err
+			}
+			db.SetTracing(&sqldb.TraceOptions{Name: name})
+			if dbconfig.SlowQueryThreshold != "" {
+				threshold, err := time.ParseDuration(dbconfig.SlowQueryThreshold)
 				if err != nil {
-					errs = append(errs, err)
-					return
+					db.Close()
+					return fmt.Errorf("slow_query_threshold: %w", err)
 				}
-
-			// s3 compatible storage
-			case objectstorage.StorageS3, objectstorage.StorageDO, objectstorage.StorageMinio:
-				s3Creds, err := s3.CredentialsFromClient(ctx, config.S3.ClientID, config.S3.ClientSecret, "")
+				db.AddHook(slowQueryHook(logger, kothak.slowQueries, name, threshold))
+			}
+			if kothak.attribution {
+				db.AddHook(sqlAttributionHook(kothak.metrics, name))
+			}
+			// the audit is the outermost middleware, so the statement is recorded before it is changed by other middlewares
+			if dbconfig.Audit.Enabled {
+				db.Use(sqldb.Auditor(dbconfig.Audit.options(name, kothak.audit, logger)))
+			}
+			if dbconfig.QueryComment.Enabled {
+				db.Use(sqldb.Commenter(dbconfig.Driver, dbconfig.QueryComment.options()))
+			}
+			if dbconfig.QueryMetrics {
+				db.AddHook(queryMetricsHook(kothak.metrics, name, sqldb.DriverName(dbconfig.Driver)))
+			}
+			if dbconfig.QueryCache.Redis != "" {
+				r, err := kothak.namespacedRedis(dbconfig.Namespace, dbconfig.QueryCache.Redis)
 				if err != nil {
-					errs = append(errs, err)
-					return
+					db.Close()
+					return fmt.Errorf("query_cache: %w", err)
 				}
+				db.SetQueryCache(r, dbconfig.QueryCache.options(logger, name))
+			}
 
-				s3Config, err := s3.NewConfig(ctx, s3Creds)
-				if err != nil {
-					errs = append(errs, err)
-					return
-				}
+			logger.Debugf("kothak: connected to DB %s", name)
 
-				s3Config.
-					SetBucket(config.Bucket).
-					SetBucketProto(config.BucketProto).
-					SetBucketURL(config.BucketURL).
-					SetRegion(config.Region).
-					SetEndpoint(config.Endpoint).
-					DisableSSL(config.S3.DisableSSL).
-					ForcePathStyle(config.S3.ForcePathStyle)
+			kothak.setSQLDB(name, db)
+			return nil
+		})
+	}
 
-				provider, err = s3.New(ctx, s3Config)
-				if err != nil {
-					errs = append(errs, err)
-					return
-				}
+	// connect to mongodb
+	for _, mdbConfig := range kothakConfig.MongoDBConfig.MongoDBs {
+		mongoconfig := mdbConfig
+		name := QualifiedName(mongoconfig.Namespace, mongoconfig.Name)
+		spanName := fmt.Sprintf("mongodb/connect/%s", name)
+		tasks.add(kindMongoDB, name, mongoconfig.DependsOn, func(ctx context.Context) error {
+			ctx, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
 
-			default:
-				err = errors.New("kothak: object storage provider not found")
-				errs = append(errs, err)
-				return
+			db, err := connectMongoDB(ctx, kothakConfig.MongoDBConfig, mongoconfig)
+			if err != nil {
+				return err
 			}
 
+			logger.Debugf("kothak: connected to MongoDB %s", name)
+
+			kothak.setMongoDB(name, db)
+			return nil
+		})
+	}
+
+	// connect to search engine
+	for _, srcConfig := range kothakConfig.SearchConfig {
+		searchconfig := srcConfig
+		name := QualifiedName(searchconfig.Namespace, searchconfig.Name)
+		spanName := fmt.Sprintf("search/connect/%s", name)
+		tasks.add(kindSearch, name, searchconfig.DependsOn, func(ctx context.Context) error {
+			ctx, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
+
+			client, err := newSearchClient(ctx, searchconfig)
 			if err != nil {
-				errs = append(errs, err)
-				return
+				return err
 			}
 
-			logger.Debugf("kothak: Connected to object_storage %s", config.Name)
+			logger.Debugf("kothak: connected to search %s", name)
 
-			kothak.setObjectStorage(config.Name, provider)
-		}(objStorageConfig)
+			kothak.setSearch(name, client)
+			return nil
+		})
 	}
 
-	// connect to redis
-	for _, redisconfig := range kothakConfig.RedisConfig.Rds {
-		group.Add(1)
-		go func(redisconfig RedisConnConfig) {
-			_, span = trace.StartSpan(ctx, fmt.Sprintf("redis/init/%s", redisconfig.Name))
-			defer func() {
-				group.Done()
-				span.End()
-			}()
+	// dial grpc clients
+	for _, grpcConfig := range kothakConfig.GRPCClientConfig {
+		clientconfig := grpcConfig
+		name := QualifiedName(clientconfig.Namespace, clientconfig.Name)
+		spanName := fmt.Sprintf("grpc_client/dial/%s", name)
+		tasks.add(kindGRPCClient, name, clientconfig.DependsOn, func(ctx context.Context) error {
+			ctx, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
 
-			conf := redigo.Config{
-				MaxActive: kothakConfig.RedisConfig.MaxActive,
-				MaxIdle:   kothakConfig.RedisConfig.MaxIdle,
-				Timeout:   kothakConfig.RedisConfig.Timeout,
+			conn, err := dialGRPCClient(ctx, clientconfig)
+			if err != nil {
+				return err
 			}
 
-			r, err := redigo.New(ctx, redisconfig.Address, &conf)
+			logger.Debugf("kothak: connected to grpc client %s", name)
+
+			kothak.setGRPCClient(name, conn)
+			return nil
+		})
+	}
+
+	// create http clients, no connection is made when creating the client
+	for _, httpConfig := range kothakConfig.HTTPClientConfig {
+		clientconfig := httpConfig
+		name := QualifiedName(clientconfig.Namespace, clientconfig.Name)
+		spanName := fmt.Sprintf("http_client/init/%s", name)
+		tasks.add(kindHTTPClient, name, clientconfig.DependsOn, func(ctx context.Context) error {
+			_, span := trace.StartSpan(ctx, spanName)
+			defer span.End()
+
+			client, err := newHTTPClient(clientconfig)
 			if err != nil {
-				errs = append(errs, err)
-				return
+				return err
 			}
 
-			logger.Debugf("Kothak: Connected to Redis %s", redisconfig.Name)
+			logger.Debugf("kothak: created http client %s", name)
+
+			kothak.setHTTPClient(name, client)
+			return nil
+		})
+	}
+
+	// limit the number of resources initialized at the same time
+	// to prevent too many dns lookup and open files when many resources are configured
+	group, ctx := concurrent.NewGroup(ctx, "kothak/init", kothakConfig.MaxConcurrentInit)
+	// wait for all connections and return the first error
+	// every resource init is recorded as lifecycle phase when the context has the recorder
+	recorder := lifecycle.FromContext(ctx)
+	err := tasks.run(ctx, group, func(kind, name string, duration time.Duration, err error) {
+		kothak.metrics.observeInit(kind, name, duration, err)
+		recorder.Record("resources/"+kind+"/"+name, time.Now().Add(-duration), err)
+	})
+	return &kothak, err
+}
 
-			kothak.setRedis(redisconfig.Name, r)
-		}(redisconfig)
+// newRetryPolicy return the retry policy used when initializing resources
+func newRetryPolicy(attempts int) *retry.Policy {
+	if attempts <= 0 {
+		attempts = 1
 	}
+	return &retry.Policy{
+		MaxAttempts:     attempts,
+		InitialInterval: time.Second,
+		MaxInterval:     time.Second * 30,
+		Jitter:          0.2,
+	}
+}
 
-	// connect to database
-	for _, dbconfig := range kothakConfig.DBConfig.SQLDBs {
-		group.Add(1)
-		go func(dbconfig SQLDBConfig) {
-			_, span = trace.StartSpan(ctx, fmt.Sprintf("database/connect/%s", dbconfig.Name))
-			defer func() {
-				group.Done()
-				span.End()
-			}()
+func newObjectStorageProvider(ctx context.Context, config ObjectStorageConfig) (objectstorage.StorageProvider, error) {
+	switch strings.ToLower(config.Provider) {
+	// local storage
+	case objectstorage.StorageLocal:
+		// defaulted to not delete local bucket when close the program
+		return local.New(ctx, fmt.Sprintf("./%s", config.Bucket), &local.Options{DeleteOnClose: false})
+
+	// gcs compatible storage
+	case objectstorage.StorageGCS:
+		gcsCreds, err := gcs.CredentialsFromFile(ctx, config.GCS.JSONKey)
+		if err != nil {
+			return nil, err
+		}
 
-			var (
-				err        error
-				leaderDB   *sqlx.DB
-				followerDB *sqlx.DB
-			)
+		gcsConfig, err := gcs.NewConfig(ctx, gcsCreds)
+		if err != nil {
+			return nil, err
+		}
+		gcsConfig.
+			SetBucket(config.Bucket).
+			SetBucketProto(config.BucketProto).
+			SetBucketURL(config.BucketURL)
+
+		return gcs.New(ctx, gcsConfig)
+
+	// s3 compatible storage
+	case objectstorage.StorageS3, objectstorage.StorageDO, objectstorage.StorageMinio:
+		s3Creds, err := s3.CredentialsFromClient(ctx, config.S3.ClientID, config.S3.ClientSecret, "")
+		if err != nil {
+			return nil, err
+		}
 
-			if dbconfig.Driver == "" {
+		s3Config, err := s3.NewConfig(ctx, s3Creds)
+		if err != nil {
+			return nil, err
+		}
 
-			}
+		s3Config.
+			SetBucket(config.Bucket).
+			SetBucketProto(config.BucketProto).
+			SetBucketURL(config.BucketURL).
+			SetRegion(config.Region).
+			SetEndpoint(config.Endpoint).
+			DisableSSL(config.S3.DisableSSL).
+			ForcePathStyle(config.S3.ForcePathStyle)
 
-			// setup leader connection
-			if err := dbconfig.LeaderConnConfig.SetDefault(kothakConfig.DBConfig); err != nil {
-				errs = append(errs, err)
-				return
-			}
-			// connect to leader
-			leaderDB, err = sqldb.Connect(ctx, dbconfig.Driver, dbconfig.LeaderConnConfig.DSN, &sqldb.ConnectOptions{
-				Retry:              dbconfig.LeaderConnConfig.MaxRetry,
-				MaxOpenConnections: dbconfig.LeaderConnConfig.MaxOpenConnections,
-				MaxIdleConnections: dbconfig.LeaderConnConfig.MaxIdleConnections,
-			})
-			if err != nil {
-				errs = append(errs, err)
-				return
-			}
-			// by default, set replica to leader
-			followerDB = leaderDB
-
-			// connect to replica
-			if dbconfig.ReplicaConnConfig.DSN != "" {
-				if err := dbconfig.ReplicaConnConfig.SetDefault(kothakConfig.DBConfig); err != nil {
-					errs = append(errs, err)
-					return
-				}
-				followerDB, err = sqldb.Connect(ctx, dbconfig.Driver, dbconfig.ReplicaConnConfig.DSN, &sqldb.ConnectOptions{
-					Retry:              dbconfig.ReplicaConnConfig.MaxRetry,
-					MaxOpenConnections: dbconfig.ReplicaConnConfig.MaxOpenConnections,
-					MaxIdleConnections: dbconfig.ReplicaConnConfig.MaxIdleConnections,
-				})
-				if err != nil {
-					errs = append(errs, err)
-					return
-				}
-			}
+		return s3.New(ctx, s3Config)
 
-			db, err := sqldb.Wrap(ctx, leaderDB, followerDB)
-			if err != nil {
-				errs = append(errs, err)
-				return
-			}
+	default:
+		return nil, retry.Permanent(errors.New("kothak: object storage provider not found"))
+	}
+}
+
+func connectSQLDB(ctx context.Context, defaultConfig DBConfig, dbconfig SQLDBConfig) (*sqldb.DB, error) {
+	var (
+		err         error
+		leaderDB    *sqlx.DB
+		followerDBs []*sqlx.DB
+	)
 
-			logger.Debugf("kothak: connected to DB %s", dbconfig.Name)
+	// setup leader connection
+	if err := dbconfig.LeaderConnConfig.SetDefault(defaultConfig); err != nil {
+		return nil, err
+	}
+	leaderDSN, err := dbconfig.LeaderConnConfig.dsn(dbconfig.Driver)
+	if err != nil {
+		return nil, err
+	}
+	password, err := dbconfig.IAM.password(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clickhouse, err := dbconfig.ClickHouse.clickhouse()
+	if err != nil {
+		return nil, err
+	}
+	// connect to leader
+	leaderOpts := &sqldb.ConnectOptions{
+		Retry:                 dbconfig.LeaderConnConfig.MaxRetry,
+		MaxOpenConnections:    dbconfig.LeaderConnConfig.MaxOpenConnections,
+		MaxIdleConnections:    dbconfig.LeaderConnConfig.MaxIdleConnections,
+		ConnectionMaxLifetime: dbconfig.LeaderConnConfig.connMaxLifeTime,
+		ConnectionMaxIdleTime: dbconfig.LeaderConnConfig.connMaxIdleTime,
+		TLS:                   dbconfig.TLS.tls(QualifiedName(dbconfig.Namespace, dbconfig.Name) + "/leader"),
+		Password:              password,
+		ClickHouse:            clickhouse,
+		SQLServer:             dbconfig.SQLServer.sqlserver(),
+		Backend:               dbconfig.Backend,
+	}
+	leaderDB, err = sqldb.Connect(ctx, dbconfig.Driver, leaderDSN, leaderOpts)
+	if err != nil {
+		return nil, err
+	}
+	closeAll := func() {
+		leaderDB.Close()
+		for _, db := range followerDBs {
+			db.Close()
+		}
+	}
 
-			kothak.setSQLDB(dbconfig.Name, db)
-		}(dbconfig)
+	// connect to replicas, by default the leader is used as the replica
+	for idx, replica := range dbconfig.replicas() {
+		if err := replica.SetDefault(defaultConfig); err != nil {
+			closeAll()
+			return nil, err
+		}
+		replicaDSN, err := replica.dsn(dbconfig.Driver)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		followerDB, err := sqldb.Connect(ctx, dbconfig.Driver, replicaDSN, &sqldb.ConnectOptions{
+			Retry:                 replica.MaxRetry,
+			MaxOpenConnections:    replica.MaxOpenConnections,
+			MaxIdleConnections:    replica.MaxIdleConnections,
+			ConnectionMaxLifetime: replica.connMaxLifeTime,
+			ConnectionMaxIdleTime: replica.connMaxIdleTime,
+			TLS:                   dbconfig.TLS.tls(fmt.Sprintf("%s/replicas/%d", QualifiedName(dbconfig.Namespace, dbconfig.Name), idx)),
+			Password:              password,
+			ClickHouse:            clickhouse,
+			SQLServer:             dbconfig.SQLServer.sqlserver(),
+			Backend:               dbconfig.Backend,
+		})
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("replica %d: %w", idx, err)
+		}
+		followerDBs = append(followerDBs, followerDB)
 	}
 
-	// wait for all connections
-	group.Wait()
-	// check for error, if error length is greater than 1
-	// set err to errs[0]
-	if len(errs) > 0 {
-		err = errs[0]
+	opts := sqldb.FollowerOptions{
+		Balancer: dbconfig.LoadBalancer,
+	}
+	if dbconfig.HealthCheckInterval != "" {
+		opts.HealthCheckInterval, err = time.ParseDuration(dbconfig.HealthCheckInterval)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("health_check_interval: %w", err)
+		}
+	}
+	if dbconfig.MaxReplicaLag != "" {
+		opts.MaxLag, err = time.ParseDuration(dbconfig.MaxReplicaLag)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("max_replica_lag: %w", err)
+		}
+	}
+	db, err := sqldb.WrapFollowers(ctx, leaderDB, followerDBs, &opts)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	db.SetRetryPolicy(sqldb.NewQueryRetryPolicy(dbconfig.QueryRetry))
+	db.SetStmtCache(dbconfig.StmtCacheSize)
+	if dbconfig.DefaultQueryTimeout != "" {
+		timeout, err := time.ParseDuration(dbconfig.DefaultQueryTimeout)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("default_query_timeout: %w", err)
+		}
+		db.SetQueryTimeout(timeout)
+	}
+	if dbconfig.CircuitBreakerThreshold > 0 {
+		cbOpts := breaker.Options{FailureThreshold: dbconfig.CircuitBreakerThreshold}
+		if dbconfig.CircuitBreakerTimeout != "" {
+			cbOpts.OpenTimeout, err = time.ParseDuration(dbconfig.CircuitBreakerTimeout)
+			if err != nil {
+				db.Close()
+				return nil, fmt.Errorf("circuit_breaker_timeout: %w", err)
+			}
+		}
+		db.SetCircuitBreaker(&cbOpts)
 	}
+	if dbconfig.FailoverThreshold > 0 {
+		// connect to the leader dsn again, so the dns of the cluster endpoint is looked up again
+		err := db.SetFailover(&sqldb.FailoverOptions{
+			Resolver:  sqldb.ReconnectResolver(dbconfig.Driver, leaderDSN, leaderOpts),
+			Threshold: dbconfig.FailoverThreshold,
+		})
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failover: %w", err)
+		}
+	}
+	return db, nil
+}
 
-	return &kothak, err
+// Resources is a list of already connected resources
+type Resources struct {
+	SQLDBs         map[string]*sqldb.DB
+	Redis          map[string]redis.Redis
+	ObjectStorages map[string]*objectstorage.Storage
+	MongoDBs       map[string]*mongodb.DB
+	Search         map[string]*search.Client
+	GRPCClients    map[string]*grpc.ClientConn
+	HTTPClients    map[string]*http.Client
+	// name of default resources, optional
+	DefaultSQLDB         string
+	DefaultRedis         string
+	DefaultObjectStorage string
+}
+
+// NewFromResources create kothak instance from already connected resources
+// this is useful for testing, where resources are created by test harness
+func NewFromResources(resources Resources, logger logger.Logger) *Kothak {
+	kothak := Kothak{
+		objStorages: make(map[string]*objectstorage.Storage),
+		dbs:         make(map[string]*sqldb.DB),
+		rds:         make(map[string]redis.Redis),
+		mdbs:        make(map[string]*mongodb.DB),
+		search:      make(map[string]*search.Client),
+		grpcClients: make(map[string]*grpc.ClientConn),
+		httpClients: make(map[string]*http.Client),
+		migrations:  make(map[string]string),
+		views:       make(map[string][]matview.View),
+		refreshers:  make(map[string]*matview.Refresher),
+		logger:      logger,
+		metrics:     newMetrics(),
+
+		slowQueries:  supportbundle.NewLog(recentLogSize),
+		healthChecks: supportbundle.NewLog(recentLogSize),
+		audit:        newAuditSink(logger),
+
+		defaultDB:         resources.DefaultSQLDB,
+		defaultRedis:      resources.DefaultRedis,
+		defaultObjStorage: resources.DefaultObjectStorage,
+	}
+	for name, db := range resources.SQLDBs {
+		kothak.dbs[name] = db
+	}
+	for name, rds := range resources.Redis {
+		kothak.rds[name] = rds
+	}
+	for name, objStorage := range resources.ObjectStorages {
+		kothak.objStorages[name] = objStorage
+	}
+	for name, mdb := range resources.MongoDBs {
+		kothak.mdbs[name] = mdb
+	}
+	for name, client := range resources.Search {
+		kothak.search[name] = client
+	}
+	for name, conn := range resources.GRPCClients {
+		kothak.grpcClients[name] = conn
+	}
+	for name, client := range resources.HTTPClients {
+		kothak.httpClients[name] = client
+	}
+	return &kothak
 }
 
 // CloseAll to close all connected resources
 // TODO: check error when closing connections and close connection concurrently
 func (k *Kothak) CloseAll() error {
+	// refreshers are closed before the database
+	for _, refresher := range k.refreshers {
+		refresher.Close()
+	}
+
 	for _, objStorage := range k.objStorages {
 		objStorage.Close()
 	}
@@ -292,11 +732,28 @@ func (k *Kothak) CloseAll() error {
 	for _, redis := range k.rds {
 		redis.Close()
 	}
+
+	for _, mdb := range k.mdbs {
+		mdb.Close()
+	}
+
+	for _, client := range k.search {
+		client.Close()
+	}
+
+	for _, conn := range k.grpcClients {
+		conn.Close()
+	}
+
+	for _, client := range k.httpClients {
+		client.CloseIdleConnections()
+	}
 	return nil
 }
 
 // GetSQLDB from kothak object
 func (k *Kothak) GetSQLDB(dbname string) (*sqldb.DB, error) {
+	dbname = k.resolveAlias(kindDatabase, dbname)
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 	i, ok := k.dbs[dbname]
@@ -319,6 +776,7 @@ func (k *Kothak) MustGetSQLDB(dbname string) *sqldb.DB {
 
 // GetRedis from kothak object
 func (k *Kothak) GetRedis(redisname string) (redis.Redis, error) {
+	redisname = k.resolveAlias(kindRedis, redisname)
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 	i, ok := k.rds[redisname]
@@ -329,6 +787,16 @@ func (k *Kothak) GetRedis(redisname string) (redis.Redis, error) {
 	return i, nil
 }
 
+// namespacedRedis return the redis in the namespace, or the redis without namespace with the same name
+func (k *Kothak) namespacedRedis(namespace, redisname string) (redis.Redis, error) {
+	if namespace != "" {
+		if r, err := k.GetRedis(QualifiedName(namespace, redisname)); err == nil {
+			return r, nil
+		}
+	}
+	return k.GetRedis(redisname)
+}
+
 // MustGetRedis from kothak object
 func (k *Kothak) MustGetRedis(redisname string) redis.Redis {
 	r, err := k.GetRedis(redisname)
@@ -341,6 +809,7 @@ func (k *Kothak) MustGetRedis(redisname string) redis.Redis {
 
 // GetObjectStorage from kothak object
 func (k *Kothak) GetObjectStorage(objStorageName string) (*objectstorage.Storage, error) {
+	objStorageName = k.resolveAlias(kindObjectStorage, objStorageName)
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 	i, ok := k.objStorages[objStorageName]
@@ -0,0 +1,139 @@
+// Package kothaktest provide in-memory kothak for testing
+// so unit tests of code that accepts *kothak.Kothak doesn't need real resources
+//
+// sql databases is backed by sqlmock, redis is backed by miniredis
+// and object storage is backed by in-memory bucket
+package kothaktest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/kothak"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/sqldbtest"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Options of kothaktest
+type Options struct {
+	// SQLDBs is the list of sql database name
+	SQLDBs []string
+	// SQLDriver is the driver name used for query rebinding, default to postgres
+	SQLDriver string
+	// Redis is the list of redis name
+	Redis []string
+	// ObjectStorages is the list of object storage name
+	ObjectStorages []string
+}
+
+// Kothak for testing
+type Kothak struct {
+	*kothak.Kothak
+	sqlmocks  map[string]sqlmock.Sqlmock
+	miniredis map[string]*miniredis.Miniredis
+}
+
+// New kothak for testing, populated with in-memory resources
+func New(options Options) (*Kothak, error) {
+	if options.SQLDriver == "" {
+		options.SQLDriver = sqldb.DriverPostgres
+	}
+
+	var (
+		resources = kothak.Resources{
+			SQLDBs:         make(map[string]*sqldb.DB),
+			Redis:          make(map[string]redis.Redis),
+			ObjectStorages: make(map[string]*objectstorage.Storage),
+		}
+		k = Kothak{
+			sqlmocks:  make(map[string]sqlmock.Sqlmock),
+			miniredis: make(map[string]*miniredis.Miniredis),
+		}
+		ctx = context.Background()
+	)
+
+	for _, name := range options.SQLDBs {
+		mock, err := sqldbtest.New(&sqldbtest.Options{Driver: options.SQLDriver, LeaderOnly: true})
+		if err != nil {
+			k.close(resources)
+			return nil, err
+		}
+		resources.SQLDBs[name] = mock.DB
+		k.sqlmocks[name] = mock.Leader()
+	}
+
+	for _, name := range options.Redis {
+		mr, err := miniredis.Run()
+		if err != nil {
+			k.close(resources)
+			return nil, err
+		}
+		k.miniredis[name] = mr
+
+		rds, err := redigo.New(ctx, mr.Addr(), nil)
+		if err != nil {
+			k.close(resources)
+			return nil, err
+		}
+		resources.Redis[name] = rds
+	}
+
+	for _, name := range options.ObjectStorages {
+		resources.ObjectStorages[name] = objectstorage.New(memory.New(name))
+	}
+
+	logger, err := std.New(nil)
+	if err != nil {
+		k.close(resources)
+		return nil, err
+	}
+	k.Kothak = kothak.NewFromResources(resources, logger)
+	return &k, nil
+}
+
+// SQLMock return sqlmock of sql database to set query expectations
+func (k *Kothak) SQLMock(name string) sqlmock.Sqlmock {
+	mock, ok := k.sqlmocks[name]
+	if !ok {
+		panic(fmt.Sprintf("kothaktest: sql database with name %s does not exists", name))
+	}
+	return mock
+}
+
+// Miniredis return miniredis server of redis to inspect or manipulate the data
+func (k *Kothak) Miniredis(name string) *miniredis.Miniredis {
+	mr, ok := k.miniredis[name]
+	if !ok {
+		panic(fmt.Sprintf("kothaktest: redis with name %s does not exists", name))
+	}
+	return mr
+}
+
+// Close all resources and stop miniredis servers
+func (k *Kothak) Close() error {
+	err := k.Kothak.CloseAll()
+	for _, mr := range k.miniredis {
+		mr.Close()
+	}
+	return err
+}
+
+// close is used to clean up resources when creation is failed
+func (k *Kothak) close(resources kothak.Resources) {
+	for _, db := range resources.SQLDBs {
+		db.Close()
+	}
+	for _, rds := range resources.Redis {
+		rds.Close()
+	}
+	for _, mr := range k.miniredis {
+		mr.Close()
+	}
+}
@@ -0,0 +1,54 @@
+package kothaktest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/kothak/kothaktest"
+)
+
+func TestNew(t *testing.T) {
+	k, err := kothaktest.New(kothaktest.Options{
+		SQLDBs:         []string{"users"},
+		Redis:          []string{"session"},
+		ObjectStorages: []string{"image"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+
+	ctx := context.Background()
+
+	// sql database
+	k.SQLMock("users").ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+	var one int
+	if err := k.MustGetSQLDB("users").GetContext(ctx, &one, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SQLMock("users").ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// redis
+	if _, err := k.MustGetRedis("session").Set(ctx, "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := k.Miniredis("session").Get("key"); got != "value" {
+		t.Errorf("expecting value but got %s", got)
+	}
+
+	// object storage
+	storage := k.MustGetObjectStorage("image")
+	if _, err := storage.UploadByte(ctx, []byte("hello"), "hello.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err := storage.DownloadByte(ctx, "hello.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hello" {
+		t.Errorf("expecting hello but got %s", out)
+	}
+}
@@ -0,0 +1,67 @@
+package kothak
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/matview"
+)
+
+// GetViewRefresher return refresher of materialized views of the sql database
+// the same refresher is returned for the database, so it can be triggered after it is started by StartViewRefreshers
+func (k *Kothak) GetViewRefresher(dbname string) (*matview.Refresher, error) {
+	dbname = k.resolveAlias(kindDatabase, dbname)
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if refresher, ok := k.refreshers[dbname]; ok {
+		return refresher, nil
+	}
+	views, ok := k.views[dbname]
+	if !ok {
+		return nil, fmt.Errorf("kothak: sql database with name %s has no materialized views", dbname)
+	}
+	db, ok := k.dbs[dbname]
+	if !ok {
+		return nil, fmt.Errorf("kothak: sql database with name %s does not exists", dbname)
+	}
+	refresher, err := matview.New(db, views, &matview.Options{
+		OnError: func(view string, err error) {
+			k.logger.Warnw("kothak: failed to refresh materialized view", logger.KV{
+				"resource": dbname,
+				"view":     view,
+				"error":    err.Error(),
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kothak: database %s: %w", dbname, err)
+	}
+	k.refreshers[dbname] = refresher
+	return refresher, nil
+}
+
+// StartViewRefreshers start refresh of materialized views of all sql databases, ordered by database name
+// the refreshers are stopped by CloseAll
+func (k *Kothak) StartViewRefreshers(ctx context.Context) error {
+	k.mutex.Lock()
+	names := make([]string, 0, len(k.views))
+	for name := range k.views {
+		names = append(names, name)
+	}
+	k.mutex.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		refresher, err := k.GetViewRefresher(name)
+		if err != nil {
+			return err
+		}
+		if err := refresher.Start(ctx); err != nil {
+			return fmt.Errorf("kothak: database %s: %w", name, err)
+		}
+		k.logger.Debugf("kothak: started materialized view refresher of DB %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package kothak
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestGetViewRefresher(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewFromResources(Resources{
+		SQLDBs: map[string]*sqldb.DB{"reports": db, "orders": db},
+	}, logger)
+	views, err := SQLDBConfig{MaterializedViews: []MaterializedViewConfig{
+		{Name: "daily_sales", RefreshInterval: "1h", Concurrently: true},
+		{Name: "monthly_sales", RefreshAfter: []string{"daily_sales"}},
+	}}.views()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if views[0].Interval != time.Hour || views[1].Interval != 0 {
+		t.Fatalf("unexpected views %+v", views)
+	}
+	k.views["reports"] = views
+
+	refresher, err := k.GetViewRefresher("reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := k.GetViewRefresher("reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refresher != again {
+		t.Error("expecting the same refresher for the database")
+	}
+	if _, err := k.GetViewRefresher("orders"); err == nil {
+		t.Error("expecting error for database without materialized views")
+	}
+}
@@ -0,0 +1,347 @@
+package kothak
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/migrate"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// list of metrics descriptor
+var (
+	initDurationDesc = prometheus.NewDesc(
+		"kothak_resource_init_duration_seconds",
+		"duration of the last initialization of the resource",
+		[]string{"kind", "name"}, nil,
+	)
+	initFailuresDesc = prometheus.NewDesc(
+		"kothak_resource_init_failures_total",
+		"number of failed resource initialization",
+		[]string{"kind", "name"}, nil,
+	)
+	reconnectsDesc = prometheus.NewDesc(
+		"kothak_resource_reconnects_total",
+		"number of reconnect to the resource, including dial retries and replaced resource",
+		[]string{"kind", "name"}, nil,
+	)
+	connectionsDesc = prometheus.NewDesc(
+		"kothak_resource_connections",
+		"current number of connections in the resource pool",
+		[]string{"kind", "name", "role", "state"}, nil,
+	)
+	poolSaturationDesc = prometheus.NewDesc(
+		"kothak_resource_pool_saturation",
+		"ratio of connections in use to the max open connections of the resource pool",
+		[]string{"kind", "name", "role"}, nil,
+	)
+	replicationLagDesc = prometheus.NewDesc(
+		"kothak_database_replication_lag_seconds",
+		"replication lag of the database replica from the last health check",
+		[]string{"name", "replica"}, nil,
+	)
+	stmtCacheDesc = prometheus.NewDesc(
+		"kothak_database_stmt_cache_total",
+		"number of prepared statement cache hits, misses and evictions of the database",
+		[]string{"name", "result"}, nil,
+	)
+	stmtCacheSizeDesc = prometheus.NewDesc(
+		"kothak_database_stmt_cache_size",
+		"current number of cached prepared statements of the database",
+		[]string{"name"}, nil,
+	)
+	poolWaitDesc = prometheus.NewDesc(
+		"kothak_database_pool_wait_total",
+		"number of connections waited for because the pool of the database is exhausted",
+		[]string{"name", "role"}, nil,
+	)
+	poolWaitDurationDesc = prometheus.NewDesc(
+		"kothak_database_pool_wait_seconds_total",
+		"total time waited for a connection from the pool of the database",
+		[]string{"name", "role"}, nil,
+	)
+	schemaDriftedDesc = prometheus.NewDesc(
+		"kothak_database_schema_drifted",
+		"1 when the schema of the database is changed outside of the migrations from the last drift check",
+		[]string{"name"}, nil,
+	)
+	schemaDriftChangesDesc = prometheus.NewDesc(
+		"kothak_database_schema_drift_changes",
+		"number of changed tables and columns of the database from the schema after the migrations",
+		[]string{"name"}, nil,
+	)
+	aliasUsageDesc = prometheus.NewDesc(
+		"kothak_resource_alias_usage_total",
+		"number of times the resource is retrieved by the deprecated alias name",
+		[]string{"kind", "name"}, nil,
+	)
+	operationsDesc = prometheus.NewDesc(
+		"kothak_resource_operations_total",
+		"number of resource operations by the attribution labels of the request",
+		[]string{"kind", "name", "operation", "endpoint", "tenant", "feature"}, nil,
+	)
+	operationDurationDesc = prometheus.NewDesc(
+		"kothak_resource_operation_duration_seconds_total",
+		"total duration of resource operations by the attribution labels of the request",
+		[]string{"kind", "name", "operation", "endpoint", "tenant", "feature"}, nil,
+	)
+)
+
+// maxQueryDigests is the maximum number of statement digests per database in the query metrics
+const maxQueryDigests = 500
+
+// otherDigest is the digest label of the statements after maxQueryDigests
+const otherDigest = "other"
+
+// queryDurationBuckets of the query duration histogram in seconds
+var queryDurationBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type resourceKey struct {
+	kind string
+	name string
+}
+
+type operationKey struct {
+	resourceKey
+	operation string
+	labels    attribution.Labels
+}
+
+// metrics of kothak resources, the metrics is exported by Collector
+type metrics struct {
+	mu                sync.Mutex
+	initDuration      map[resourceKey]float64
+	initFailures      map[resourceKey]float64
+	reconnects        map[resourceKey]float64
+	aliasUsage        map[resourceKey]float64
+	operations        map[operationKey]float64
+	operationDuration map[operationKey]float64
+	schemaDrift       map[string]migrate.Drift
+	// query metrics of statement digests, the sanitized statement of every digest is kept for the support bundle
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	digests       map[string]map[string]string
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		initDuration:      make(map[resourceKey]float64),
+		initFailures:      make(map[resourceKey]float64),
+		reconnects:        make(map[resourceKey]float64),
+		aliasUsage:        make(map[resourceKey]float64),
+		operations:        make(map[operationKey]float64),
+		operationDuration: make(map[operationKey]float64),
+		schemaDrift:       make(map[string]migrate.Drift),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kothak_database_query_duration_seconds",
+			Help:    "duration of the queries of the database by statement digest",
+			Buckets: queryDurationBuckets,
+		}, []string{"name", "digest"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kothak_database_query_errors_total",
+			Help: "number of failed queries of the database by statement digest and error class",
+		}, []string{"name", "digest", "class"}),
+		digests: make(map[string]map[string]string),
+	}
+}
+
+func (m *metrics) observeInit(kind, name string, duration time.Duration, err error) {
+	key := resourceKey{kind: kind, name: name}
+	m.mu.Lock()
+	m.initDuration[key] = duration.Seconds()
+	if err != nil {
+		m.initFailures[key]++
+	}
+	m.mu.Unlock()
+}
+
+func (m *metrics) reconnect(kind, name string) {
+	m.mu.Lock()
+	m.reconnects[resourceKey{kind: kind, name: name}]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) aliasUsed(kind, name string) {
+	m.mu.Lock()
+	m.aliasUsage[resourceKey{kind: kind, name: name}]++
+	m.mu.Unlock()
+}
+
+// observeSchemaDrift keep the last drift of the database
+func (m *metrics) observeSchemaDrift(name string, drift migrate.Drift) {
+	m.mu.Lock()
+	m.schemaDrift[name] = drift
+	m.mu.Unlock()
+}
+
+// forgetSchemaDrift remove the drift of the database when it cannot be checked, so the old drift is not exported
+func (m *metrics) forgetSchemaDrift(name string) {
+	m.mu.Lock()
+	delete(m.schemaDrift, name)
+	m.mu.Unlock()
+}
+
+// digest return the digest label of the query, the query after maxQueryDigests of the database is labeled as other
+func (m *metrics) digest(name, query string) string {
+	digest := sqldb.Digest(query)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	digests, ok := m.digests[name]
+	if !ok {
+		digests = make(map[string]string)
+		m.digests[name] = digests
+	}
+	if _, ok := digests[digest]; ok {
+		return digest
+	}
+	if len(digests) >= maxQueryDigests {
+		return otherDigest
+	}
+	digests[digest] = sqldb.SanitizeQuery(query)
+	return digest
+}
+
+// queryDigests return the sanitized statement of every digest of the databases
+func (m *metrics) queryDigests() map[string]map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[string]map[string]string, len(m.digests))
+	for name, digests := range m.digests {
+		result[name] = make(map[string]string, len(digests))
+		for digest, query := range digests {
+			result[name][digest] = query
+		}
+	}
+	return result
+}
+
+// queryMetricsHook record the duration and the error class of the queries of the database by statement digest
+// every retry attempt is recorded, as every attempt is executed in the database
+func queryMetricsHook(m *metrics, name, driver string) sqldb.Hook {
+	return func(ctx context.Context, event sqldb.QueryEvent) {
+		digest := m.digest(name, event.Query)
+		m.queryDuration.WithLabelValues(name, digest).Observe(event.Duration.Seconds())
+		if class := sqldb.ErrorClass(driver, event.Err); class != "" {
+			m.queryErrors.WithLabelValues(name, digest, class).Inc()
+		}
+	}
+}
+
+// observeOperation record the operation with the attribution labels of the context
+func (m *metrics) observeOperation(ctx context.Context, kind, name, operation string, duration time.Duration) {
+	key := operationKey{
+		resourceKey: resourceKey{kind: kind, name: name},
+		operation:   operation,
+		labels:      attribution.FromContext(ctx),
+	}
+	m.mu.Lock()
+	m.operations[key]++
+	m.operationDuration[key] += duration.Seconds()
+	m.mu.Unlock()
+}
+
+// Collector return prometheus collector of kothak resources
+// the collector can be registered directly, for example prometheus.MustRegister(k.Collector())
+func (k *Kothak) Collector() prometheus.Collector {
+	return &collector{k: k}
+}
+
+type collector struct {
+	k *Kothak
+}
+
+// Describe implements prometheus.Collector
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- initDurationDesc
+	ch <- initFailuresDesc
+	ch <- reconnectsDesc
+	ch <- aliasUsageDesc
+	ch <- connectionsDesc
+	ch <- poolSaturationDesc
+	ch <- replicationLagDesc
+	ch <- stmtCacheDesc
+	ch <- stmtCacheSizeDesc
+	ch <- poolWaitDesc
+	ch <- poolWaitDurationDesc
+	ch <- schemaDriftedDesc
+	ch <- schemaDriftChangesDesc
+	ch <- operationsDesc
+	ch <- operationDurationDesc
+	c.k.metrics.queryDuration.Describe(ch)
+	c.k.metrics.queryErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	m := c.k.metrics
+	m.mu.Lock()
+	for key, v := range m.initDuration {
+		ch <- prometheus.MustNewConstMetric(initDurationDesc, prometheus.GaugeValue, v, key.kind, key.name)
+	}
+	for key, v := range m.initFailures {
+		ch <- prometheus.MustNewConstMetric(initFailuresDesc, prometheus.CounterValue, v, key.kind, key.name)
+	}
+	for key, v := range m.reconnects {
+		ch <- prometheus.MustNewConstMetric(reconnectsDesc, prometheus.CounterValue, v, key.kind, key.name)
+	}
+	for key, v := range m.aliasUsage {
+		ch <- prometheus.MustNewConstMetric(aliasUsageDesc, prometheus.CounterValue, v, key.kind, key.name)
+	}
+	for key, v := range m.operations {
+		ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, v, key.kind, key.name, key.operation, key.labels.Endpoint, key.labels.Tenant, key.labels.Feature)
+	}
+	for key, v := range m.operationDuration {
+		ch <- prometheus.MustNewConstMetric(operationDurationDesc, prometheus.CounterValue, v, key.kind, key.name, key.operation, key.labels.Endpoint, key.labels.Tenant, key.labels.Feature)
+	}
+	for name, drift := range m.schemaDrift {
+		drifted := 0.0
+		if drift.Drifted() {
+			drifted = 1
+		}
+		ch <- prometheus.MustNewConstMetric(schemaDriftedDesc, prometheus.GaugeValue, drifted, name)
+		ch <- prometheus.MustNewConstMetric(schemaDriftChangesDesc, prometheus.GaugeValue, float64(len(drift.Changes)), name)
+	}
+	m.mu.Unlock()
+	m.queryDuration.Collect(ch)
+	m.queryErrors.Collect(ch)
+
+	stats := c.k.Stats()
+	for name, s := range stats.SQLDB {
+		for role, dbstats := range map[string]struct{ inUse, idle int }{
+			"leader":   {s.Leader.InUse, s.Leader.Idle},
+			"follower": {s.Follower.InUse, s.Follower.Idle},
+		} {
+			ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(dbstats.inUse), kindDatabase, name, role, "in_use")
+			ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(dbstats.idle), kindDatabase, name, role, "idle")
+		}
+		// saturation is unknown when the pool has no max open connections
+		for role, dbstats := range map[string]sql.DBStats{"leader": s.Leader, "follower": s.Follower} {
+			if dbstats.MaxOpenConnections > 0 {
+				saturation := float64(dbstats.InUse) / float64(dbstats.MaxOpenConnections)
+				ch <- prometheus.MustNewConstMetric(poolSaturationDesc, prometheus.GaugeValue, saturation, kindDatabase, name, role)
+			}
+			ch <- prometheus.MustNewConstMetric(poolWaitDesc, prometheus.CounterValue, float64(dbstats.WaitCount), name, role)
+			ch <- prometheus.MustNewConstMetric(poolWaitDurationDesc, prometheus.CounterValue, dbstats.WaitDuration.Seconds(), name, role)
+		}
+		for idx, follower := range s.Followers {
+			if follower.Lag >= 0 {
+				ch <- prometheus.MustNewConstMetric(replicationLagDesc, prometheus.GaugeValue, follower.Lag.Seconds(), name, strconv.Itoa(idx))
+			}
+		}
+		if sc := s.StmtCache; sc != nil {
+			ch <- prometheus.MustNewConstMetric(stmtCacheDesc, prometheus.CounterValue, float64(sc.Hits), name, "hit")
+			ch <- prometheus.MustNewConstMetric(stmtCacheDesc, prometheus.CounterValue, float64(sc.Misses), name, "miss")
+			ch <- prometheus.MustNewConstMetric(stmtCacheDesc, prometheus.CounterValue, float64(sc.Evictions), name, "eviction")
+			ch <- prometheus.MustNewConstMetric(stmtCacheSizeDesc, prometheus.GaugeValue, float64(sc.Size), name)
+		}
+	}
+	for name, s := range stats.Redis {
+		ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(s.ActiveCount-s.IdleCount), kindRedis, name, "", "in_use")
+		ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(s.IdleCount), kindRedis, name, "", "idle")
+	}
+}
@@ -0,0 +1,118 @@
+package kothak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	leader.SetMaxOpenConns(4)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetStmtCache(10)
+
+	k := NewFromResources(Resources{
+		SQLDBs: map[string]*sqldb.DB{"users": db},
+		ObjectStorages: map[string]*objectstorage.Storage{
+			"image": objectstorage.New(memory.New("image")),
+		},
+	}, logger)
+	k.metrics.observeInit(kindObjectStorage, "image", time.Second, nil)
+	k.metrics.observeInit(kindDatabase, "users", time.Second, errors.New("failed"))
+	if err := k.Replace("image", memory.New("image")); err != nil {
+		t.Fatal(err)
+	}
+	hook := queryMetricsHook(k.metrics, "users", sqldb.DriverPostgres)
+	hook(context.Background(), sqldb.QueryEvent{Query: "SELECT * FROM users WHERE id = 1", Duration: time.Millisecond})
+	hook(context.Background(), sqldb.QueryEvent{Query: "SELECT * FROM users WHERE id = 2", Duration: time.Millisecond, Err: context.DeadlineExceeded})
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(k.Collector()); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]bool)
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			name := family.GetName()
+			names[name] = true
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "name" {
+					name += "/" + label.GetValue()
+				}
+			}
+			switch {
+			case m.GetCounter() != nil:
+				values[name] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[name] = m.GetGauge().GetValue()
+			}
+		}
+	}
+
+	expect := map[string]float64{
+		"kothak_database_query_errors_total/users":    1,
+		"kothak_resource_init_duration_seconds/image": 1,
+		"kothak_resource_init_failures_total/users":   1,
+		"kothak_resource_reconnects_total/image":      1,
+	}
+	for name, v := range expect {
+		if values[name] != v {
+			t.Errorf("%s: expecting %v but got %v", name, v, values[name])
+		}
+	}
+	for _, name := range []string{
+		"kothak_resource_pool_saturation", "kothak_database_replication_lag_seconds", "kothak_database_stmt_cache_total",
+		"kothak_database_stmt_cache_size", "kothak_database_pool_wait_total", "kothak_database_pool_wait_seconds_total",
+	} {
+		if !names[name] {
+			t.Errorf("expecting metric %s", name)
+		}
+	}
+}
+
+func TestQueryDigests(t *testing.T) {
+	m := newMetrics()
+	hook := queryMetricsHook(m, "users", sqldb.DriverPostgres)
+	for i := 0; i < maxQueryDigests+10; i++ {
+		hook(context.Background(), sqldb.QueryEvent{Query: fmt.Sprintf("SELECT * FROM users_%d WHERE id = 1", i)})
+	}
+	digests := m.queryDigests()["users"]
+	if len(digests) != maxQueryDigests {
+		t.Fatalf("expecting %d digests but got %d", maxQueryDigests, len(digests))
+	}
+	if query := digests[sqldb.Digest("SELECT * FROM users_0 WHERE id = 2")]; query != "SELECT * FROM users_0 WHERE id = ?" {
+		t.Fatalf("unexpected statement of the digest %q", query)
+	}
+	if digest := m.digest("users", "SELECT * FROM orders"); digest != otherDigest {
+		t.Fatalf("expecting digest %s after the limit but got %s", otherDigest, digest)
+	}
+}
@@ -0,0 +1,79 @@
+package kothak
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/migrate"
+)
+
+// GetMigrator return migrator of the sql database with migrations directory
+func (k *Kothak) GetMigrator(dbname string) (*migrate.Migrator, error) {
+	dbname = k.resolveAlias(kindDatabase, dbname)
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	dir, ok := k.migrations[dbname]
+	if !ok {
+		return nil, fmt.Errorf("kothak: sql database with name %s has no migrations", dbname)
+	}
+	db, ok := k.dbs[dbname]
+	if !ok {
+		return nil, fmt.Errorf("kothak: sql database with name %s does not exists", dbname)
+	}
+	return migrate.New(db, migrate.Dir(dir), nil), nil
+}
+
+// MigrateAll apply migrations of all sql databases with migrations directory, ordered by database name
+// the migration is stopped at the first database which failed
+func (k *Kothak) MigrateAll(ctx context.Context) error {
+	k.mutex.Lock()
+	names := make([]string, 0, len(k.migrations))
+	for name := range k.migrations {
+		names = append(names, name)
+	}
+	k.mutex.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		migrator, err := k.GetMigrator(name)
+		if err != nil {
+			return err
+		}
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("kothak: database %s: %w", name, err)
+		}
+		k.logger.Debugf("kothak: applied %d migrations to DB %s", len(applied), name)
+	}
+	return nil
+}
+
+// SchemaDrift compare the live schema of the sql database with the snapshot after its migrations
+// the result is exported as kothak_database_schema_drifted, and the drift of every database is checked by CheckHealth
+func (k *Kothak) SchemaDrift(ctx context.Context, dbname string) (migrate.Drift, error) {
+	migrator, err := k.GetMigrator(dbname)
+	if err != nil {
+		return migrate.Drift{}, err
+	}
+	drift, err := migrator.Drift(ctx)
+	if err != nil {
+		k.metrics.forgetSchemaDrift(dbname)
+		return drift, fmt.Errorf("kothak: database %s: %w", dbname, err)
+	}
+	k.metrics.observeSchemaDrift(dbname, drift)
+	return drift, nil
+}
+
+// driftError describe the drift as the error of the health check
+func driftError(drift migrate.Drift) error {
+	changes := make([]string, 0, len(drift.Changes))
+	for _, change := range drift.Changes {
+		changes = append(changes, change.String())
+	}
+	if drift.SnapshotVersion != drift.Version {
+		changes = append(changes, fmt.Sprintf("snapshot of version %d is behind version %d", drift.SnapshotVersion, drift.Version))
+	}
+	return fmt.Errorf("kothak: schema is changed outside of the migrations: %s", strings.Join(changes, ", "))
+}
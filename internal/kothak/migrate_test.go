@@ -0,0 +1,94 @@
+package kothak
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestMigrateAll(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "kothak-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "1_users.up.sql"), []byte("CREATE TABLE users(id int)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewFromResources(Resources{
+		SQLDBs: map[string]*sqldb.DB{"users": db, "orders": db},
+	}, logger)
+	// only database with migrations directory is migrated
+	k.migrations["users"] = dir
+
+	schemaColumns := []string{"table_name", "column_name", "data_type", "length", "nullable"}
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_snapshots")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, applied_at FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE users(id int)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM information_schema.columns").WillReturnRows(sqlmock.NewRows(schemaColumns).AddRow("users", "id", "integer", 0, true))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM schema_snapshots").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_snapshots").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := k.MigrateAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the column is changed by hand after the migration
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_snapshots")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).AddRow(1, "users", time.Now()))
+	mock.ExpectQuery("SELECT version, definition, taken_at FROM schema_snapshots").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "definition", "taken_at"}).
+			AddRow(1, `{"tables":[{"name":"users","columns":[{"name":"id","type":"integer","nullable":true}]}]}`, time.Now()))
+	mock.ExpectQuery("FROM information_schema.columns").WillReturnRows(sqlmock.NewRows(schemaColumns).AddRow("users", "id", "bigint", 0, true))
+	drift, err := k.SchemaDrift(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !drift.Drifted() || len(drift.Changes) != 1 {
+		t.Fatalf("expecting the changed column is found but got %+v", drift)
+	}
+	if err := driftError(drift); err.Error() != "kothak: schema is changed outside of the migrations: column_changed users.id: integer -> bigint" {
+		t.Errorf("unexpected drift error %v", err)
+	}
+	if k.metrics.schemaDrift["users"].SnapshotVersion != 1 {
+		t.Errorf("expecting the drift is kept for the metrics but got %+v", k.metrics.schemaDrift)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if _, err := k.GetMigrator("orders"); err == nil {
+		t.Error("expecting error for database without migrations")
+	}
+}
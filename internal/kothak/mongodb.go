@@ -0,0 +1,92 @@
+package kothak
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/mongodb"
+)
+
+// MongoDBConfig define mongodb configuration
+type MongoDBConfig struct {
+	MaxRetry       int                 `json:"max_retry" yaml:"max_retry" toml:"max_retry" default:"1"`
+	MaxPoolSize    int                 `json:"max_pool_size" yaml:"max_pool_size" toml:"max_pool_size" default:"100"`
+	MinPoolSize    int                 `json:"min_pool_size" yaml:"min_pool_size" toml:"min_pool_size"`
+	ConnectTimeout string              `json:"connect_timeout" yaml:"connect_timeout" toml:"connect_timeout" default:"10s"`
+	MongoDBs       []MongoDBConnConfig `json:"connect" yaml:"connect" toml:"connect"`
+}
+
+// MongoDBConnConfig struct
+type MongoDBConnConfig struct {
+	Name     string `json:"name" yaml:"name" toml:"name"`
+	URI      string `json:"uri" yaml:"uri" toml:"uri" protected:"1"`
+	Database string `json:"database" yaml:"database" toml:"database"`
+	// ReadPreference of follower, primary|primaryPreferred|secondary|secondaryPreferred|nearest
+	ReadPreference string `json:"read_preference" yaml:"read_preference" toml:"read_preference"`
+	MaxPoolSize    int    `json:"max_pool_size" yaml:"max_pool_size" toml:"max_pool_size"`
+	MinPoolSize    int    `json:"min_pool_size" yaml:"min_pool_size" toml:"min_pool_size"`
+	// Namespace of the resource, for example tenant name, resource with namespace is retrieved via Kothak.Namespace
+	Namespace string `json:"namespace" yaml:"namespace" toml:"namespace"`
+	// DependsOn list resources initialized before this resource, formatted as kind/name, for example database/users
+	DependsOn []string `json:"depends_on" yaml:"depends_on" toml:"depends_on"`
+}
+
+func connectMongoDB(ctx context.Context, defaultConfig MongoDBConfig, config MongoDBConnConfig) (*mongodb.DB, error) {
+	var (
+		maxPoolSize    = defaultConfig.MaxPoolSize
+		minPoolSize    = defaultConfig.MinPoolSize
+		connectTimeout time.Duration
+	)
+	if config.MaxPoolSize > 0 {
+		maxPoolSize = config.MaxPoolSize
+	}
+	if config.MinPoolSize > 0 {
+		minPoolSize = config.MinPoolSize
+	}
+	if defaultConfig.ConnectTimeout != "" {
+		dur, err := time.ParseDuration(defaultConfig.ConnectTimeout)
+		if err != nil {
+			return nil, err
+		}
+		connectTimeout = dur
+	}
+
+	return mongodb.Connect(ctx, config.URI, config.Database, &mongodb.ConnectOptions{
+		Retry:          defaultConfig.MaxRetry,
+		MaxPoolSize:    uint64(maxPoolSize),
+		MinPoolSize:    uint64(minPoolSize),
+		ConnectTimeout: connectTimeout,
+		ReadPreference: config.ReadPreference,
+	})
+}
+
+func (k *Kothak) setMongoDB(name string, db *mongodb.DB) {
+	k.mutex.Lock()
+	k.mdbs[name] = db
+	k.mutex.Unlock()
+}
+
+// GetMongoDB from kothak object
+func (k *Kothak) GetMongoDB(dbname string) (*mongodb.DB, error) {
+	dbname = k.resolveAlias(kindMongoDB, dbname)
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	i, ok := k.mdbs[dbname]
+	if !ok {
+		err := fmt.Errorf("kothak: mongodb with name %s does not exists", dbname)
+		return nil, err
+	}
+	return i, nil
+}
+
+// MustGetMongoDB from kothak object
+func (k *Kothak) MustGetMongoDB(dbname string) *mongodb.DB {
+	db, err := k.GetMongoDB(dbname)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return db
+}
@@ -0,0 +1,209 @@
+package kothak
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/mongodb"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/search"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"google.golang.org/grpc"
+)
+
+// namespaceSeparator separate namespace and name of the resource
+const namespaceSeparator = "/"
+
+// QualifiedName return the name of resource in kothak, formatted as namespace/name
+// resource without namespace is stored with its name
+func QualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + namespaceSeparator + name
+}
+
+// splitQualifiedName return the namespace and name of the resource
+func splitQualifiedName(qualifiedName string) (namespace, name string) {
+	idx := strings.Index(qualifiedName, namespaceSeparator)
+	if idx < 0 {
+		return "", qualifiedName
+	}
+	return qualifiedName[:idx], qualifiedName[idx+1:]
+}
+
+// Namespace is a scoped view of kothak, resources are resolved within the namespace
+// resource without namespace is not visible from the namespace, so tenant never use resource of another tenant by mistake
+type Namespace struct {
+	kothak *Kothak
+	name   string
+}
+
+// Namespace return scoped view of resources in the namespace
+// the namespace is not checked, getting resource from unknown namespace return not found error
+func (k *Kothak) Namespace(name string) *Namespace {
+	ns := Namespace{
+		kothak: k,
+		name:   name,
+	}
+	return &ns
+}
+
+// Namespaces return sorted list of namespace which has at least one resource
+func (k *Kothak) Namespaces() []string {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	found := make(map[string]struct{})
+	add := func(qualifiedName string) {
+		if ns, _ := splitQualifiedName(qualifiedName); ns != "" {
+			found[ns] = struct{}{}
+		}
+	}
+	for name := range k.dbs {
+		add(name)
+	}
+	for name := range k.rds {
+		add(name)
+	}
+	for name := range k.objStorages {
+		add(name)
+	}
+	for name := range k.mdbs {
+		add(name)
+	}
+	for name := range k.search {
+		add(name)
+	}
+	for name := range k.grpcClients {
+		add(name)
+	}
+	for name := range k.httpClients {
+		add(name)
+	}
+
+	namespaces := make([]string, 0, len(found))
+	for ns := range found {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Name of the namespace
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+func (ns *Namespace) qualify(name string) string {
+	return QualifiedName(ns.name, name)
+}
+
+// GetSQLDB in the namespace
+func (ns *Namespace) GetSQLDB(dbname string) (*sqldb.DB, error) {
+	return ns.kothak.GetSQLDB(ns.qualify(dbname))
+}
+
+// MustGetSQLDB in the namespace
+func (ns *Namespace) MustGetSQLDB(dbname string) *sqldb.DB {
+	db, err := ns.GetSQLDB(dbname)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return db
+}
+
+// GetRedis in the namespace
+func (ns *Namespace) GetRedis(redisname string) (redis.Redis, error) {
+	return ns.kothak.GetRedis(ns.qualify(redisname))
+}
+
+// MustGetRedis in the namespace
+func (ns *Namespace) MustGetRedis(redisname string) redis.Redis {
+	r, err := ns.GetRedis(redisname)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return r
+}
+
+// GetObjectStorage in the namespace
+func (ns *Namespace) GetObjectStorage(objStorageName string) (*objectstorage.Storage, error) {
+	return ns.kothak.GetObjectStorage(ns.qualify(objStorageName))
+}
+
+// MustGetObjectStorage in the namespace
+func (ns *Namespace) MustGetObjectStorage(objStorageName string) *objectstorage.Storage {
+	o, err := ns.GetObjectStorage(objStorageName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return o
+}
+
+// GetMongoDB in the namespace
+func (ns *Namespace) GetMongoDB(dbname string) (*mongodb.DB, error) {
+	return ns.kothak.GetMongoDB(ns.qualify(dbname))
+}
+
+// MustGetMongoDB in the namespace
+func (ns *Namespace) MustGetMongoDB(dbname string) *mongodb.DB {
+	db, err := ns.GetMongoDB(dbname)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return db
+}
+
+// GetSearch in the namespace
+func (ns *Namespace) GetSearch(name string) (*search.Client, error) {
+	return ns.kothak.GetSearch(ns.qualify(name))
+}
+
+// MustGetSearch in the namespace
+func (ns *Namespace) MustGetSearch(name string) *search.Client {
+	client, err := ns.GetSearch(name)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return client
+}
+
+// GetGRPCClient in the namespace
+func (ns *Namespace) GetGRPCClient(name string) (*grpc.ClientConn, error) {
+	return ns.kothak.GetGRPCClient(ns.qualify(name))
+}
+
+// MustGetGRPCClient in the namespace
+func (ns *Namespace) MustGetGRPCClient(name string) *grpc.ClientConn {
+	conn, err := ns.GetGRPCClient(name)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return conn
+}
+
+// GetHTTPClient in the namespace
+func (ns *Namespace) GetHTTPClient(name string) (*http.Client, error) {
+	return ns.kothak.GetHTTPClient(ns.qualify(name))
+}
+
+// MustGetHTTPClient in the namespace
+func (ns *Namespace) MustGetHTTPClient(name string) *http.Client {
+	client, err := ns.GetHTTPClient(name)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return client
+}
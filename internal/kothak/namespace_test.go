@@ -0,0 +1,95 @@
+package kothak
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+)
+
+func TestNamespace(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		shared  = objectstorage.New(memory.New("shared"))
+		tenantA = objectstorage.New(memory.New("tenant-a"))
+		tenantB = objectstorage.New(memory.New("tenant-b"))
+	)
+	k := NewFromResources(Resources{
+		ObjectStorages: map[string]*objectstorage.Storage{
+			"image":                            shared,
+			QualifiedName("tenant-a", "image"): tenantA,
+			QualifiedName("tenant-b", "image"): tenantB,
+		},
+	}, logger)
+	defer k.CloseAll()
+
+	got, err := k.Namespace("tenant-a").GetObjectStorage("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != tenantA {
+		t.Errorf("expecting storage of tenant-a, got %s", got.BucketName())
+	}
+	got, err = k.GetObjectStorage("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != shared {
+		t.Errorf("expecting shared storage, got %s", got.BucketName())
+	}
+
+	// resource without namespace is not visible from the namespace
+	if _, err := k.Namespace("tenant-c").GetObjectStorage("image"); err == nil {
+		t.Error("expecting error when getting resource of unknown namespace")
+	}
+
+	expect := []string{"tenant-a", "tenant-b"}
+	if namespaces := k.Namespaces(); !reflect.DeepEqual(expect, namespaces) {
+		t.Errorf("expecting namespaces %v, got %v", expect, namespaces)
+	}
+}
+
+func TestInitTasksNamespaceDependency(t *testing.T) {
+	var (
+		tasks initTasks
+		order = make(chan string, 3)
+	)
+	// redis in tenant-a depends on database/users of tenant-a, not the shared one
+	tasks.add(kindRedis, QualifiedName("tenant-a", "session"), []string{"database/users"}, func(ctx context.Context) error {
+		order <- "redis/tenant-a/session"
+		return nil
+	})
+	tasks.add(kindDatabase, "users", nil, func(ctx context.Context) error {
+		order <- "database/users"
+		return nil
+	})
+	tasks.add(kindDatabase, QualifiedName("tenant-a", "users"), nil, func(ctx context.Context) error {
+		// the shared database is initialized first, redis must still wait for the tenant database
+		time.Sleep(time.Millisecond * 10)
+		order <- "database/tenant-a/users"
+		return nil
+	})
+
+	group, ctx := concurrent.NewGroup(context.Background(), "test", 0)
+	if err := tasks.run(ctx, group, nil); err != nil {
+		t.Fatal(err)
+	}
+	close(order)
+
+	var got []string
+	for key := range order {
+		got = append(got, key)
+	}
+	if got[len(got)-1] != "redis/tenant-a/session" {
+		t.Errorf("expecting redis of tenant-a initialized after tenant database, got %v", got)
+	}
+}
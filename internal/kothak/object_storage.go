@@ -1,5 +1,12 @@
 package kothak
 
+import (
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+)
+
 // ObjectStorageConfig struct
 type ObjectStorageConfig struct {
 	Name        string    `json:"name" yaml:"name" toml:"name"`
@@ -11,12 +18,64 @@ type ObjectStorageConfig struct {
 	BucketURL   string    `json:"bucket_url" yaml:"bucket_url" toml:"bucket_url"`
 	S3          S3Config  `json:"s3" yaml:"s3" toml:"s3"`
 	GCS         GCSConfig `json:"gcs" yaml:"gcs" toml:"gcs"`
+	Default     bool      `json:"default" yaml:"default" toml:"default"`
+	MaxRetry    int       `json:"max_retry" yaml:"max_retry" toml:"max_retry"`
+	// Namespace of the resource, for example tenant name, resource with namespace is retrieved via Kothak.Namespace
+	Namespace string `json:"namespace" yaml:"namespace" toml:"namespace"`
+	// DependsOn list resources initialized before this resource, formatted as kind/name, for example database/users
+	DependsOn []string `json:"depends_on" yaml:"depends_on" toml:"depends_on"`
+	// Cache of the small objects which are downloaded many times, for example templates and config
+	Cache ObjectStorageCacheConfig `json:"cache" yaml:"cache" toml:"cache"`
+}
+
+// dependsOn return the dependencies of the object storage, including the redis of the cache
+func (objconfig ObjectStorageConfig) dependsOn() []string {
+	if !objconfig.Cache.Enabled || objconfig.Cache.Redis == "" {
+		return objconfig.DependsOn
+	}
+	deps := append([]string(nil), objconfig.DependsOn...)
+	return append(deps, kindRedis+"/"+objconfig.Cache.Redis)
+}
+
+// ObjectStorageCacheConfig of the read-through cache, see objectstorage.Storage.SetCache
+type ObjectStorageCacheConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" toml:"enabled"`
+	// MaxObjectSize in bytes, larger object is not cached, default to 256KiB
+	MaxObjectSize int64 `json:"max_object_size" yaml:"max_object_size" toml:"max_object_size"`
+	// TTL of the cached object, for example 30s, default to 1m
+	TTL string `json:"ttl" yaml:"ttl" toml:"ttl"`
+	// MaxMemory in bytes of the cached objects in every instance, default to 64MiB
+	MaxMemory int64 `json:"max_memory" yaml:"max_memory" toml:"max_memory"`
+	// Redis is the name of the redis in kothak which is shared by the instances, the objects are only cached in memory when empty
+	Redis string `json:"redis" yaml:"redis" toml:"redis"`
+	// Prefix of the cache keys, default to objcache:<bucket>:
+	Prefix string `json:"prefix" yaml:"prefix" toml:"prefix"`
+}
+
+// options of the cache without redis, redis error is logged as warning as the object is read from the storage
+func (cacheConfig ObjectStorageCacheConfig) options(log logger.Logger, name string) (*objectstorage.CacheOptions, error) {
+	opts := &objectstorage.CacheOptions{
+		MaxObjectSize: cacheConfig.MaxObjectSize,
+		MaxBytes:      cacheConfig.MaxMemory,
+		Prefix:        cacheConfig.Prefix,
+		OnError: func(err error) {
+			log.Warnw("kothak: object storage cache", logger.KV{"resource": name, "error": err.Error()})
+		},
+	}
+	if cacheConfig.TTL != "" {
+		ttl, err := time.ParseDuration(cacheConfig.TTL)
+		if err != nil {
+			return nil, err
+		}
+		opts.TTL = ttl
+	}
+	return opts, nil
 }
 
 // S3Config for s3 storage
 type S3Config struct {
 	ClientID       string `json:"client_id" yaml:"client_id" toml:"client_id"`
-	ClientSecret   string `json:"client_secret" yaml:"client_secret" toml:"client_secret"`
+	ClientSecret   string `json:"client_secret" yaml:"client_secret" toml:"client_secret" protected:"1"`
 	DisableSSL     bool   `json:"disable_ssl" yaml:"disable_ssl" toml:"disable_ssl"`
 	ForcePathStyle bool   `json:"force_path_style" yaml:"force_path_style" toml:"force_path_style"`
 }
@@ -24,6 +83,6 @@ type S3Config struct {
 // GCSConfig for google cloud storage
 type GCSConfig struct {
 	ClientID     string `json:"client_id" yaml:"client_id" toml:"client_id"`
-	ClientSecret string `json:"client_secret" yaml:"client_secret" toml:"client_secret"`
+	ClientSecret string `json:"client_secret" yaml:"client_secret" toml:"client_secret" protected:"1"`
 	JSONKey      string `json:"json_key" yaml:"json_key" toml:"json_key"`
 }
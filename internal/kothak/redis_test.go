@@ -0,0 +1,64 @@
+package kothak
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisGuardEnvironment(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	cases := []struct {
+		environment string
+		allowed     []string
+		err         error
+	}{
+		{environment: "production", err: redis.ErrCommandBlocked},
+		{environment: "production", allowed: []string{"flushall"}},
+		{environment: "staging", err: redis.ErrCommandBlocked},
+		{environment: "", err: redis.ErrCommandBlocked},
+		{environment: EnvironmentDev},
+		{environment: EnvironmentTest},
+		{environment: EnvironmentLocal},
+	}
+
+	for _, c := range cases {
+		config := Config{
+			Environment: c.environment,
+			RedisConfig: RedisConfig{
+				Rds: []RedisConnConfig{{Name: "session", Address: mr.Addr(), AllowedCommands: c.allowed}},
+			},
+		}
+		k, err := New(context.Background(), config, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rds, err := k.GetRedis("session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		doer, ok := rds.(interface {
+			Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error)
+		})
+		if !ok {
+			t.Fatalf("expecting redis with Do but got %T", rds)
+		}
+		if _, err := doer.Do(context.Background(), redis.CommandFlushAll); !errors.Is(err, c.err) {
+			t.Errorf("environment %q allowed %v: expecting error %v but got %v", c.environment, c.allowed, c.err, err)
+		}
+		k.CloseAll()
+	}
+}
@@ -0,0 +1,256 @@
+package kothak
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/mongodb"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/search"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"google.golang.org/grpc"
+)
+
+// list of resource error
+var (
+	ErrResourceNotFound         = errors.New("kothak: resource not found")
+	ErrResourceNil              = errors.New("kothak: resource is nil")
+	ErrResourceTypeNotSupported = errors.New("kothak: resource type is not supported")
+)
+
+// CloseErrors is a list of errors found when closing the resources of one name
+type CloseErrors []error
+
+// Error return all errors as one string
+func (cerrs CloseErrors) Error() string {
+	errs := make([]string, len(cerrs))
+	for i, err := range cerrs {
+		errs[i] = err.Error()
+	}
+	return strings.Join(errs, "; ")
+}
+
+// Is return true when one of the errors matches target
+func (cerrs CloseErrors) Is(target error) bool {
+	for _, err := range cerrs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close a single resource by name and remove it from kothak
+// if resources of different kind share the same name, all of them are closed
+// and the errors of more than one resource are returned as CloseErrors
+func (k *Kothak) Close(name string) error {
+	k.mutex.Lock()
+	var (
+		db, dbok     = k.dbs[name]
+		rds, rdsok   = k.rds[name]
+		objs, objsok = k.objStorages[name]
+		mdb, mdbok   = k.mdbs[name]
+		src, srcok   = k.search[name]
+		gc, gcok     = k.grpcClients[name]
+		hc, hcok     = k.httpClients[name]
+	)
+	delete(k.dbs, name)
+	delete(k.rds, name)
+	delete(k.objStorages, name)
+	delete(k.mdbs, name)
+	delete(k.search, name)
+	delete(k.grpcClients, name)
+	delete(k.httpClients, name)
+	k.mutex.Unlock()
+
+	if !dbok && !rdsok && !objsok && !mdbok && !srcok && !gcok && !hcok {
+		return fmt.Errorf("%w: %s", ErrResourceNotFound, name)
+	}
+
+	var errs CloseErrors
+	if dbok {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("kothak: failed to close sql database %s: %w", name, err))
+		}
+	}
+	if rdsok {
+		if err := rds.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("kothak: failed to close redis %s: %w", name, err))
+		}
+	}
+	if objsok {
+		if err := objs.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("kothak: failed to close object storage %s: %w", name, err))
+		}
+	}
+	if mdbok {
+		if err := mdb.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("kothak: failed to close mongodb %s: %w", name, err))
+		}
+	}
+	if srcok {
+		if err := src.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("kothak: failed to close search %s: %w", name, err))
+		}
+	}
+	if gcok {
+		if err := gc.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("kothak: failed to close grpc client %s: %w", name, err))
+		}
+	}
+	if hcok {
+		hc.CloseIdleConnections()
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errs
+}
+
+// Replace an existing resource with a new one, for example after credential rotation
+// the resource type must be *sqldb.DB, *mongodb.DB, *search.Client, *grpc.ClientConn, *http.Client, redis.Redis, *objectstorage.Storage or objectstorage.StorageProvider
+// the old resource is closed after replaced, so caller should always get the resource from kothak instead of keeping it
+func (k *Kothak) Replace(name string, resource interface{}) error {
+	var (
+		closer interface{ Close() error }
+		kind   string
+	)
+
+	k.mutex.Lock()
+	switch r := resource.(type) {
+	case *sqldb.DB:
+		if r == nil {
+			k.mutex.Unlock()
+			return ErrResourceNil
+		}
+		old, ok := k.dbs[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: sql database %s", ErrResourceNotFound, name)
+		}
+		k.dbs[name] = r
+		closer = old
+		kind = kindDatabase
+
+	case *mongodb.DB:
+		if r == nil {
+			k.mutex.Unlock()
+			return ErrResourceNil
+		}
+		old, ok := k.mdbs[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: mongodb %s", ErrResourceNotFound, name)
+		}
+		k.mdbs[name] = r
+		closer = old
+		kind = kindMongoDB
+
+	case *search.Client:
+		if r == nil {
+			k.mutex.Unlock()
+			return ErrResourceNil
+		}
+		old, ok := k.search[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: search %s", ErrResourceNotFound, name)
+		}
+		k.search[name] = r
+		closer = old
+		kind = kindSearch
+
+	case *grpc.ClientConn:
+		if r == nil {
+			k.mutex.Unlock()
+			return ErrResourceNil
+		}
+		old, ok := k.grpcClients[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: grpc client %s", ErrResourceNotFound, name)
+		}
+		k.grpcClients[name] = r
+		closer = old
+		kind = kindGRPCClient
+
+	case *http.Client:
+		if r == nil {
+			k.mutex.Unlock()
+			return ErrResourceNil
+		}
+		old, ok := k.httpClients[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: http client %s", ErrResourceNotFound, name)
+		}
+		k.httpClients[name] = r
+		closer = httpClientCloser{old}
+		kind = kindHTTPClient
+
+	case *objectstorage.Storage:
+		if r == nil {
+			k.mutex.Unlock()
+			return ErrResourceNil
+		}
+		old, ok := k.objStorages[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: object storage %s", ErrResourceNotFound, name)
+		}
+		k.objStorages[name] = r
+		closer = old
+		kind = kindObjectStorage
+
+	case objectstorage.StorageProvider:
+		old, ok := k.objStorages[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: object storage %s", ErrResourceNotFound, name)
+		}
+		k.objStorages[name] = k.newObjectStorage(name, r)
+		closer = old
+		kind = kindObjectStorage
+
+	case redis.Redis:
+		old, ok := k.rds[name]
+		if !ok {
+			k.mutex.Unlock()
+			return fmt.Errorf("%w: redis %s", ErrResourceNotFound, name)
+		}
+		k.rds[name] = r
+		closer = old
+		kind = kindRedis
+
+	case nil:
+		k.mutex.Unlock()
+		return ErrResourceNil
+
+	default:
+		k.mutex.Unlock()
+		return fmt.Errorf("%w: %T", ErrResourceTypeNotSupported, resource)
+	}
+	k.mutex.Unlock()
+	k.metrics.reconnect(kind, name)
+
+	if err := closer.Close(); err != nil {
+		k.logger.Warnf("kothak: failed to close replaced resource %s: %v", name, err)
+	}
+	return nil
+}
+
+// httpClientCloser close idle connections of http client
+type httpClientCloser struct {
+	client *http.Client
+}
+
+func (hc httpClientCloser) Close() error {
+	hc.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,113 @@
+package kothak
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+)
+
+var (
+	errStorageClose = errors.New("storage close failed")
+	errRedisClose   = errors.New("redis close failed")
+)
+
+type failedCloseStorage struct {
+	objectstorage.StorageProvider
+}
+
+func (failedCloseStorage) Close() error {
+	return errStorageClose
+}
+
+type failedCloseRedis struct {
+	redis.Redis
+}
+
+func (failedCloseRedis) Close() error {
+	return errRedisClose
+}
+
+func TestCloseAndReplace(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx   = context.Background()
+		image = objectstorage.New(memory.New("image"))
+		video = objectstorage.New(memory.New("video"))
+	)
+	k := NewFromResources(Resources{
+		ObjectStorages: map[string]*objectstorage.Storage{
+			"image": image,
+			"video": video,
+		},
+	}, logger)
+
+	// replace image storage, the old storage should be closed
+	newImage := memory.New("image")
+	if err := k.Replace("image", newImage); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := image.UploadByte(ctx, []byte("test"), "test", nil); err == nil {
+		t.Error("expecting error when upload to replaced storage")
+	}
+	got, err := k.GetObjectStorage("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.UploadByte(ctx, []byte("test"), "test", nil); err != nil {
+		t.Errorf("failed to upload to new storage: %v", err)
+	}
+
+	if err := k.Replace("audio", memory.New("audio")); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("expecting error %v but got %v", ErrResourceNotFound, err)
+	}
+	if err := k.Replace("image", "not a resource"); !errors.Is(err, ErrResourceTypeNotSupported) {
+		t.Errorf("expecting error %v but got %v", ErrResourceTypeNotSupported, err)
+	}
+
+	// close video storage, other storage should still exists
+	if err := k.Close("video"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.GetObjectStorage("video"); err == nil {
+		t.Error("expecting error when get closed storage")
+	}
+	if _, err := k.GetObjectStorage("image"); err != nil {
+		t.Error(err)
+	}
+	if err := k.Close("video"); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("expecting error %v but got %v", ErrResourceNotFound, err)
+	}
+}
+
+func TestCloseErrors(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewFromResources(Resources{
+		ObjectStorages: map[string]*objectstorage.Storage{
+			"session": objectstorage.New(failedCloseStorage{memory.New("session")}),
+		},
+		Redis: map[string]redis.Redis{
+			"session": failedCloseRedis{},
+		},
+	}, logger)
+
+	err = k.Close("session")
+	var cerrs CloseErrors
+	if !errors.As(err, &cerrs) || len(cerrs) != 2 {
+		t.Fatalf("expecting 2 close errors but got %v", err)
+	}
+	if !errors.Is(err, errStorageClose) || !errors.Is(err, errRedisClose) {
+		t.Fatalf("expecting errors of all resources but got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package kothak
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/search"
+)
+
+// SearchConfig of elasticsearch/opensearch cluster
+type SearchConfig struct {
+	Name          string   `json:"name" yaml:"name" toml:"name"`
+	Addresses     []string `json:"addresses" yaml:"addresses" toml:"addresses"`
+	Username      string   `json:"username" yaml:"username" toml:"username"`
+	Password      string   `json:"password" yaml:"password" toml:"password" protected:"1"`
+	APIKey        string   `json:"api_key" yaml:"api_key" toml:"api_key" protected:"1"`
+	Sniff         bool     `json:"sniff" yaml:"sniff" toml:"sniff"`
+	SniffInterval string   `json:"sniff_interval" yaml:"sniff_interval" toml:"sniff_interval"`
+	MaxRetry      int      `json:"max_retry" yaml:"max_retry" toml:"max_retry"`
+	Timeout       string   `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// Namespace of the resource, for example tenant name, resource with namespace is retrieved via Kothak.Namespace
+	Namespace string `json:"namespace" yaml:"namespace" toml:"namespace"`
+	// DependsOn list resources initialized before this resource, formatted as kind/name, for example database/users
+	DependsOn []string `json:"depends_on" yaml:"depends_on" toml:"depends_on"`
+}
+
+func newSearchClient(ctx context.Context, config SearchConfig) (*search.Client, error) {
+	conf := search.Config{
+		Addresses: config.Addresses,
+		Username:  config.Username,
+		Password:  config.Password,
+		APIKey:    config.APIKey,
+		Sniff:     config.Sniff,
+		MaxRetry:  config.MaxRetry,
+	}
+	if config.SniffInterval != "" {
+		dur, err := time.ParseDuration(config.SniffInterval)
+		if err != nil {
+			return nil, err
+		}
+		conf.SniffInterval = dur
+	}
+	if config.Timeout != "" {
+		dur, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		conf.Timeout = dur
+	}
+	return search.New(ctx, &conf)
+}
+
+func (k *Kothak) setSearch(name string, client *search.Client) {
+	k.mutex.Lock()
+	k.search[name] = client
+	k.mutex.Unlock()
+}
+
+// GetSearch from kothak object
+func (k *Kothak) GetSearch(searchName string) (*search.Client, error) {
+	searchName = k.resolveAlias(kindSearch, searchName)
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	i, ok := k.search[searchName]
+	if !ok {
+		err := fmt.Errorf("kothak: search with name %s does not exists", searchName)
+		return nil, err
+	}
+	return i, nil
+}
+
+// MustGetSearch from kothak object
+func (k *Kothak) MustGetSearch(searchName string) *search.Client {
+	s, err := k.GetSearch(searchName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return s
+}
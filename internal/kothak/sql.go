@@ -1,9 +1,18 @@
 package kothak
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
 	"github.com/albertwidi/go-project-example/internal/pkg/defaults"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/iam"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/matview"
+	"github.com/albertwidi/go-project-example/internal/pkg/supportbundle"
 )
 
 // DBConfig define sql databases configuration
@@ -12,8 +21,10 @@ type DBConfig struct {
 	MaxOpenConnections    int           `json:"max_open_conns" yaml:"max_open_conns" toml:"max_open_conns" default:"10"`
 	MaxIdleConnections    int           `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns" default:"2"`
 	ConnectionMaxLifetime string        `json:"conn_max_lifetime" yaml:"conn_max_lifetime" toml:"conn_max_lifetime" default:"30s"`
+	ConnectionMaxIdleTime string        `json:"conn_max_idle_time" yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
 	SQLDBs                []SQLDBConfig `json:"connect" yaml:"connect" toml:"connect"`
 	connMaxLifetime       time.Duration
+	connMaxIdleTime       time.Duration
 }
 
 // SetDefault configuration
@@ -29,6 +40,13 @@ func (dbconf *DBConfig) SetDefault() error {
 		}
 		dbconf.connMaxLifetime = dur
 	}
+	if dbconf.ConnectionMaxIdleTime != "" {
+		dur, err := time.ParseDuration(dbconf.ConnectionMaxIdleTime)
+		if err != nil {
+			return err
+		}
+		dbconf.connMaxIdleTime = dur
+	}
 	return nil
 }
 
@@ -38,16 +56,387 @@ type SQLDBConfig struct {
 	Driver            string                `yaml:"driver" toml:"driver"`
 	LeaderConnConfig  SQLDBConnectionConfig `yaml:"leader" toml:"leader"`
 	ReplicaConnConfig SQLDBConnectionConfig `yaml:"replica" toml:"replica"`
+	// ReplicasConnConfig is the list of read replicas, used together with replica when both are set
+	ReplicasConnConfig []SQLDBConnectionConfig `yaml:"replicas" toml:"replicas"`
+	// LoadBalancer of read queries between replicas, round_robin|least_connections, default to round_robin
+	LoadBalancer string `yaml:"load_balancer" toml:"load_balancer"`
+	// HealthCheckInterval of replicas, replica which fail the check is skipped until it is healthy again, for example 10s
+	HealthCheckInterval string `yaml:"health_check_interval" toml:"health_check_interval"`
+	// MaxReplicaLag of replication, replica which lag behind more than this is skipped until it catch up, for example 5s
+	MaxReplicaLag string `yaml:"max_replica_lag" toml:"max_replica_lag"`
+	// QueryRetry is the number of attempts of a query which fail with transient error, for example deadlock
+	// the query is not retried when less than 2, see sqldb.IsTransient
+	QueryRetry int `yaml:"query_retry" toml:"query_retry"`
+	// CircuitBreakerThreshold is the number of consecutive connection errors to open the circuit of the leader or replica
+	// query fail fast with sqldb.ErrCircuitOpen when the circuit is open, no circuit breaker when zero
+	CircuitBreakerThreshold int `yaml:"circuit_breaker_threshold" toml:"circuit_breaker_threshold"`
+	// CircuitBreakerTimeout is the wait time before a probe query is allowed to open circuit, for example 10s
+	CircuitBreakerTimeout string `yaml:"circuit_breaker_timeout" toml:"circuit_breaker_timeout"`
+	// FailoverThreshold is the number of consecutive leader connection or read only errors before the leader is connected again
+	// the dsn of the leader is looked up again, for example cluster endpoint which point to the new leader, no failover when zero
+	FailoverThreshold int `yaml:"failover_threshold" toml:"failover_threshold"`
+	// DefaultQueryTimeout of every query, the caller deadline is kept when it is earlier, for example 30s
+	// query has no timeout when empty
+	DefaultQueryTimeout string `yaml:"default_query_timeout" toml:"default_query_timeout"`
+	// SlowQueryThreshold is the duration of query which is logged as slow query, for example 500ms
+	// slow query is not logged when empty
+	SlowQueryThreshold string `yaml:"slow_query_threshold" toml:"slow_query_threshold"`
+	// StmtCacheSize is the number of prepared statements cached per connection of the leader and replicas
+	// the statements are not cached when zero, see sqldb.DB.SetStmtCache
+	StmtCacheSize int `yaml:"stmt_cache_size" toml:"stmt_cache_size"`
+	// QueryMetrics export the duration and the errors of every statement digest of the database, see sqldb.Digest
+	// the number of digests per database is limited, and the other statements are exported as other
+	QueryMetrics bool `yaml:"query_metrics" toml:"query_metrics"`
+	// Migrations is the directory of sql migration files, applied with Kothak.MigrateAll, for example database/schema/user
+	Migrations string `yaml:"migrations" toml:"migrations"`
+	// TLS of the leader and replicas connection, for example mutual tls of payments database
+	TLS SQLDBTLSConfig `yaml:"tls" toml:"tls"`
+	// IAM authentication of the leader and replicas, the short lived token is used as password of every new connection
+	IAM SQLDBIAMConfig `yaml:"iam" toml:"iam"`
+	// ClickHouse options of clickhouse leader and replicas, for example async insert of analytics events
+	ClickHouse SQLDBClickHouseConfig `yaml:"clickhouse" toml:"clickhouse"`
+	// SQLServer options of sqlserver leader and replicas, for example encrypt of the legacy erp database
+	SQLServer SQLDBSQLServerConfig `yaml:"sqlserver" toml:"sqlserver"`
+	// Backend of postgres connection, pq|pgx, default to pq. pgx send sqldb.Batch in one round trip, see sqldb.BackendPgx
+	Backend string `yaml:"backend" toml:"backend"`
+	// MaterializedViews refreshed by Kothak.StartViewRefreshers, postgres only
+	MaterializedViews []MaterializedViewConfig `yaml:"materialized_views" toml:"materialized_views"`
+	// Pools of the same database with separate connection pools, every pool is a database named <name>-<pool>
+	// for example orders-batch for the background jobs, so they cannot exhaust the connections of the request traffic
+	Pools []SQLDBPoolConfig `yaml:"pools" toml:"pools"`
+	// QueryCache of sqldb.CachedSelect in the redis of kothak, the query result is not cached when redis is empty
+	QueryCache SQLDBQueryCacheConfig `yaml:"query_cache" toml:"query_cache"`
+	// QueryComment append sqlcommenter comment to every query, so the query in the database log can be traced to the request
+	QueryComment SQLDBQueryCommentConfig `yaml:"query_comment" toml:"query_comment"`
+	// Audit record who, what and when of every write query, for example of the billing database
+	Audit   SQLDBAuditConfig `yaml:"audit" toml:"audit"`
+	Default bool             `yaml:"default" toml:"default"`
+	// Namespace of the resource, for example tenant name, resource with namespace is retrieved via Kothak.Namespace
+	Namespace string `yaml:"namespace" toml:"namespace"`
+	// DependsOn list resources initialized before this resource, formatted as kind/name, for example database/users
+	DependsOn []string `yaml:"depends_on" toml:"depends_on"`
+}
+
+// replicas return all replica connection configuration
+func (dbconfig SQLDBConfig) replicas() []SQLDBConnectionConfig {
+	var replicas []SQLDBConnectionConfig
+	if dbconfig.ReplicaConnConfig.configured() {
+		replicas = append(replicas, dbconfig.ReplicaConnConfig)
+	}
+	return append(replicas, dbconfig.ReplicasConnConfig...)
+}
+
+// SQLDBPoolConfig of a separate connection pool of the database, the zero fields keep the value of the database
+type SQLDBPoolConfig struct {
+	// Name of the pool, the pool is retrieved as database <name>-<pool>, for example orders-batch
+	Name                  string `yaml:"name" toml:"name"`
+	MaxOpenConnections    int    `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConnections    int    `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnectionMaxLifetime string `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnectionMaxIdleTime string `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	// DefaultQueryTimeout of every query of the pool, for example longer timeout of the background jobs
+	DefaultQueryTimeout string `yaml:"default_query_timeout" toml:"default_query_timeout"`
+}
+
+// databases return the configuration of every database, followed by the databases of its pools
+func (dbconf DBConfig) databases() []SQLDBConfig {
+	databases := make([]SQLDBConfig, 0, len(dbconf.SQLDBs))
+	for _, dbconfig := range dbconf.SQLDBs {
+		databases = append(databases, dbconfig)
+		databases = append(databases, dbconfig.pools()...)
+	}
+	return databases
+}
+
+// pools return the configuration of the databases of the pools, the pools connect to the same leader and replicas
+// the migrations and materialized views are only run by the database itself, and the pool can't be the default
+func (dbconfig SQLDBConfig) pools() []SQLDBConfig {
+	pools := make([]SQLDBConfig, 0, len(dbconfig.Pools))
+	for _, pool := range dbconfig.Pools {
+		c := dbconfig
+		c.Name = dbconfig.Name + "-" + pool.Name
+		c.Pools = nil
+		c.Default = false
+		c.Migrations = ""
+		c.MaterializedViews = nil
+		if pool.DefaultQueryTimeout != "" {
+			c.DefaultQueryTimeout = pool.DefaultQueryTimeout
+		}
+		c.LeaderConnConfig = pool.apply(dbconfig.LeaderConnConfig)
+		c.ReplicaConnConfig = pool.apply(dbconfig.ReplicaConnConfig)
+		c.ReplicasConnConfig = make([]SQLDBConnectionConfig, len(dbconfig.ReplicasConnConfig))
+		for idx, replica := range dbconfig.ReplicasConnConfig {
+			c.ReplicasConnConfig[idx] = pool.apply(replica)
+		}
+		pools = append(pools, c)
+	}
+	return pools
+}
+
+// apply the pool options to the connection
+func (pool SQLDBPoolConfig) apply(connConfig SQLDBConnectionConfig) SQLDBConnectionConfig {
+	if pool.MaxOpenConnections != 0 {
+		connConfig.MaxOpenConnections = pool.MaxOpenConnections
+	}
+	if pool.MaxIdleConnections != 0 {
+		connConfig.MaxIdleConnections = pool.MaxIdleConnections
+	}
+	if pool.ConnectionMaxLifetime != "" {
+		connConfig.ConnectionMaxLifetime = pool.ConnectionMaxLifetime
+	}
+	if pool.ConnectionMaxIdleTime != "" {
+		connConfig.ConnectionMaxIdleTime = pool.ConnectionMaxIdleTime
+	}
+	return connConfig
+}
+
+// dependsOn return the dependencies of the database, including the redis of the query cache
+func (dbconfig SQLDBConfig) dependsOn() []string {
+	if dbconfig.QueryCache.Redis == "" {
+		return dbconfig.DependsOn
+	}
+	deps := append([]string(nil), dbconfig.DependsOn...)
+	return append(deps, kindRedis+"/"+dbconfig.QueryCache.Redis)
+}
+
+// SQLDBQueryCacheConfig of the query result cache
+type SQLDBQueryCacheConfig struct {
+	// Redis is the name of the redis in kothak, the redis in the same namespace is used first
+	Redis string `yaml:"redis" toml:"redis"`
+	// Prefix of the cache keys, default to sqlcache:
+	Prefix string `yaml:"prefix" toml:"prefix"`
+}
+
+// options of the query cache, redis error is logged as warning as the query is run against the database
+func (cacheConfig SQLDBQueryCacheConfig) options(log logger.Logger, name string) *sqldb.QueryCacheOptions {
+	return &sqldb.QueryCacheOptions{
+		Prefix: cacheConfig.Prefix,
+		OnError: func(err error) {
+			log.Warnw("kothak: query cache", logger.KV{"resource": name, "error": err.Error()})
+		},
+	}
+}
+
+// SQLDBQueryCommentConfig of the query comment, see sqldb.Commenter
+type SQLDBQueryCommentConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Service is the application tag of the comment, for example the name of the program
+	Service string `yaml:"service" toml:"service"`
+}
+
+// options of the query comment, the route is the endpoint of the attribution labels unless it is set by sqldb.CommentTags
+func (commentConfig SQLDBQueryCommentConfig) options() *sqldb.CommentOptions {
+	return &sqldb.CommentOptions{
+		Service: commentConfig.Service,
+		Tags: func(ctx context.Context) map[string]string {
+			return map[string]string{sqldb.CommentTagRoute: attribution.FromContext(ctx).Endpoint}
+		},
+	}
+}
+
+// SQLDBTLSConfig of database connection, tls is not used when all fields are empty
+type SQLDBTLSConfig struct {
+	CAFile     string `yaml:"ca_file" toml:"ca_file"`
+	CertFile   string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile    string `yaml:"key_file" toml:"key_file"`
+	ServerName string `yaml:"server_name" toml:"server_name"`
+	SkipVerify bool   `yaml:"skip_verify" toml:"skip_verify"`
+}
+
+// tls return the tls config of the connection, the name is registered to the driver
+func (tlsConfig SQLDBTLSConfig) tls(name string) *sqldb.TLSConfig {
+	if tlsConfig == (SQLDBTLSConfig{}) {
+		return nil
+	}
+	return &sqldb.TLSConfig{
+		Name:               name,
+		CAFile:             tlsConfig.CAFile,
+		CertFile:           tlsConfig.CertFile,
+		KeyFile:            tlsConfig.KeyFile,
+		ServerName:         tlsConfig.ServerName,
+		InsecureSkipVerify: tlsConfig.SkipVerify,
+	}
+}
+
+// SQLDBClickHouseConfig of clickhouse connection, the parameters of dsn are used when all fields are empty
+type SQLDBClickHouseConfig struct {
+	// AsyncInsert buffer the inserts in the server and write them in batch
+	AsyncInsert bool `yaml:"async_insert" toml:"async_insert"`
+	// AsyncInsertNoWait return after the insert is buffered, the insert error is not returned
+	AsyncInsertNoWait bool `yaml:"async_insert_no_wait" toml:"async_insert_no_wait"`
+	// AsyncInsertBusyTimeout is the maximum time the inserts are buffered, for example 200ms
+	AsyncInsertBusyTimeout string `yaml:"async_insert_busy_timeout" toml:"async_insert_busy_timeout"`
+	// Compression of the data, none|lz4|zstd|gzip|deflate|br
+	Compression      string `yaml:"compression" toml:"compression"`
+	CompressionLevel int    `yaml:"compression_level" toml:"compression_level"`
+}
+
+// clickhouse return the clickhouse options of the connection, nil when clickhouse is not configured
+func (chConfig SQLDBClickHouseConfig) clickhouse() (*sqldb.ClickHouseOptions, error) {
+	if chConfig == (SQLDBClickHouseConfig{}) {
+		return nil, nil
+	}
+	opts := &sqldb.ClickHouseOptions{
+		AsyncInsert:       chConfig.AsyncInsert,
+		AsyncInsertNoWait: chConfig.AsyncInsertNoWait,
+		Compression:       chConfig.Compression,
+		CompressionLevel:  chConfig.CompressionLevel,
+	}
+	if chConfig.AsyncInsertBusyTimeout != "" {
+		timeout, err := time.ParseDuration(chConfig.AsyncInsertBusyTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse: async_insert_busy_timeout: %w", err)
+		}
+		opts.AsyncInsertBusyTimeout = timeout
+	}
+	return opts, nil
+}
+
+// SQLDBSQLServerConfig of sqlserver connection, the parameters of dsn are used when all fields are empty
+type SQLDBSQLServerConfig struct {
+	// Encrypt of the connection, disable|false|true|strict
+	Encrypt string `yaml:"encrypt" toml:"encrypt"`
+	// AppName is sent to the server in the login, for example the name of the service
+	AppName string `yaml:"app_name" toml:"app_name"`
+}
+
+// sqlserver return the sqlserver options of the connection, nil when sqlserver is not configured
+func (msConfig SQLDBSQLServerConfig) sqlserver() *sqldb.SQLServerOptions {
+	if msConfig == (SQLDBSQLServerConfig{}) {
+		return nil
+	}
+	return &sqldb.SQLServerOptions{
+		Encrypt: msConfig.Encrypt,
+		AppName: msConfig.AppName,
+	}
+}
+
+// list of iam provider
+const (
+	iamProviderAWS = "aws"
+	iamProviderGCP = "gcp"
+)
+
+// SQLDBIAMConfig of database iam authentication, static password is used when provider is empty
+type SQLDBIAMConfig struct {
+	// Provider of the token, aws|gcp
+	Provider string `yaml:"provider" toml:"provider"`
+	// Region of aws rds
+	Region string `yaml:"region" toml:"region"`
+}
+
+// password return the password of new connection, nil when iam is not used
+func (iamConfig SQLDBIAMConfig) password(ctx context.Context) (sqldb.PasswordFunc, error) {
+	switch iamConfig.Provider {
+	case "":
+		return nil, nil
+	case iamProviderAWS:
+		return iam.AWS(&iam.AWSOptions{Region: iamConfig.Region})
+	case iamProviderGCP:
+		return iam.GCP(ctx, nil)
+	}
+	return nil, fmt.Errorf("iam provider %s is not supported", iamConfig.Provider)
+}
+
+// MaterializedViewConfig of materialized view refresh
+type MaterializedViewConfig struct {
+	Name string `yaml:"name" toml:"name"`
+	// RefreshInterval of the view, for example 1h, the view is only refreshed after refresh_after or on trigger when empty
+	RefreshInterval string `yaml:"refresh_interval" toml:"refresh_interval"`
+	// Concurrently refresh the view without blocking reads, the view must have a unique index
+	Concurrently bool `yaml:"concurrently" toml:"concurrently"`
+	// RefreshAfter list views which trigger refresh of this view after they are refreshed
+	RefreshAfter []string `yaml:"refresh_after" toml:"refresh_after"`
+}
+
+// views return materialized views of the configuration
+func (dbconfig SQLDBConfig) views() ([]matview.View, error) {
+	views := make([]matview.View, 0, len(dbconfig.MaterializedViews))
+	for _, viewconfig := range dbconfig.MaterializedViews {
+		view := matview.View{
+			Name:         viewconfig.Name,
+			Concurrently: viewconfig.Concurrently,
+			RefreshAfter: viewconfig.RefreshAfter,
+		}
+		if viewconfig.RefreshInterval != "" {
+			interval, err := time.ParseDuration(viewconfig.RefreshInterval)
+			if err != nil {
+				return nil, fmt.Errorf("materialized view %s: refresh_interval: %w", viewconfig.Name, err)
+			}
+			view.Interval = interval
+		}
+		views = append(views, view)
+	}
+	return views, nil
 }
 
 // SQLDBConnectionConfig struct
 type SQLDBConnectionConfig struct {
-	DSN                   string `json:"dsn" yaml:"dsn" toml:"dsn" protected:"1"`
-	MaxOpenConnections    int    `json:"max_open_conns" yaml:"max_open_conns" toml:"max_open_conns"`
-	MaxIdleConnections    int    `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"`
-	ConnectionMaxLifetime string `json:"conn_max_lifetime" yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
-	MaxRetry              int    `json:"max_retry" yaml:"max_retry" toml:"max_retry"`
+	DSN string `json:"dsn" yaml:"dsn" toml:"dsn" protected:"1"`
+	// structured dsn, used when dsn is empty
+	Host                  string            `json:"host" yaml:"host" toml:"host"`
+	Port                  int               `json:"port" yaml:"port" toml:"port"`
+	User                  string            `json:"user" yaml:"user" toml:"user"`
+	Password              string            `json:"password" yaml:"password" toml:"password" protected:"1"`
+	DBName                string            `json:"dbname" yaml:"dbname" toml:"dbname"`
+	SSLMode               string            `json:"sslmode" yaml:"sslmode" toml:"sslmode"`
+	Params                map[string]string `json:"params" yaml:"params" toml:"params"`
+	MaxOpenConnections    int               `json:"max_open_conns" yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConnections    int               `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnectionMaxLifetime string            `json:"conn_max_lifetime" yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnectionMaxIdleTime string            `json:"conn_max_idle_time" yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	MaxRetry              int               `json:"max_retry" yaml:"max_retry" toml:"max_retry"`
 	connMaxLifeTime       time.Duration
+	connMaxIdleTime       time.Duration
+}
+
+// configured return true when the connection has either dsn or structured dsn
+func (connConfig SQLDBConnectionConfig) configured() bool {
+	return connConfig.DSN != "" || connConfig.Host != ""
+}
+
+// dsn return the raw dsn, or build the dsn of the driver from the structured dsn
+func (connConfig SQLDBConnectionConfig) dsn(driver string) (string, error) {
+	if connConfig.DSN != "" {
+		return connConfig.DSN, nil
+	}
+	return sqldb.BuildDSN(driver, sqldb.DSNConfig{
+		Host:     connConfig.Host,
+		Port:     connConfig.Port,
+		User:     connConfig.User,
+		Password: connConfig.Password,
+		DBName:   connConfig.DBName,
+		SSLMode:  connConfig.SSLMode,
+		Params:   connConfig.Params,
+	})
+}
+
+// validateDSN return error when the dsn is invalid, or both dsn and structured dsn are set
+func (connConfig SQLDBConnectionConfig) validateDSN(driver string) error {
+	if connConfig.DSN != "" && connConfig.Host != "" {
+		return errors.New("dsn and host must not be set together")
+	}
+	if connConfig.DSN == "" && connConfig.Host != "" {
+		// the built dsn is escaped, only the structured fields need to be validated
+		_, err := connConfig.dsn(driver)
+		return err
+	}
+	return sqldb.ValidateDSN(driver, connConfig.DSN)
+}
+
+// validateDurations return error when conn_max_lifetime or conn_max_idle_time is invalid
+func (connConfig SQLDBConnectionConfig) validateDurations() error {
+	if connConfig.ConnectionMaxLifetime != "" {
+		if _, err := time.ParseDuration(connConfig.ConnectionMaxLifetime); err != nil {
+			return fmt.Errorf("conn_max_lifetime: %w", err)
+		}
+	}
+	if connConfig.ConnectionMaxIdleTime != "" {
+		if _, err := time.ParseDuration(connConfig.ConnectionMaxIdleTime); err != nil {
+			return fmt.Errorf("conn_max_idle_time: %w", err)
+		}
+	}
+	return nil
 }
 
 // SetDefault configuration
@@ -76,5 +465,41 @@ func (connConfig *SQLDBConnectionConfig) SetDefault(dbconfig DBConfig) error {
 		}
 		connConfig.connMaxLifeTime = dur
 	}
+
+	if connConfig.ConnectionMaxIdleTime == "" {
+		connConfig.ConnectionMaxIdleTime = dbconfig.ConnectionMaxIdleTime
+	}
+
+	connConfig.connMaxIdleTime = dbconfig.connMaxIdleTime
+	if connConfig.ConnectionMaxIdleTime != "" {
+		dur, err := time.ParseDuration(connConfig.ConnectionMaxIdleTime)
+		if err != nil {
+			return err
+		}
+		connConfig.connMaxIdleTime = dur
+	}
 	return nil
 }
+
+// slowQueryHook log query which take longer than threshold as warning, and keep it in the recent slow queries
+func slowQueryHook(log logger.Logger, recent *supportbundle.Log, name string, threshold time.Duration) sqldb.Hook {
+	return sqldb.SlowQueryHook(threshold, func(ctx context.Context, event sqldb.QueryEvent) {
+		target := "follower"
+		if event.Leader {
+			target = "leader"
+		}
+		kv := logger.KV{
+			"resource":      name,
+			"query":         sqldb.SanitizeQuery(event.Query),
+			"args":          event.Args,
+			"rows_affected": event.RowsAffected,
+			"duration":      event.Duration.String(),
+			"target":        target,
+		}
+		if event.Err != nil {
+			kv["error"] = event.Err.Error()
+		}
+		log.Warnw("kothak: slow query", kv)
+		recent.Append(kv)
+	})
+}
@@ -0,0 +1,113 @@
+package kothak
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/attribution"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+func TestSQLDBConnectionConfigSetDefault(t *testing.T) {
+	dbconfig := DBConfig{ConnectionMaxLifetime: "5m", ConnectionMaxIdleTime: "1m"}
+	if err := dbconfig.SetDefault(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name         string
+		connConfig   SQLDBConnectionConfig
+		maxLifetime  time.Duration
+		maxIdleTime  time.Duration
+		expectingErr bool
+	}{
+		{
+			name:        "inherit from database config",
+			maxLifetime: time.Minute * 5,
+			maxIdleTime: time.Minute,
+		},
+		{
+			name:        "override",
+			connConfig:  SQLDBConnectionConfig{ConnectionMaxLifetime: "30s", ConnectionMaxIdleTime: "10s"},
+			maxLifetime: time.Second * 30,
+			maxIdleTime: time.Second * 10,
+		},
+		{
+			name:         "invalid idle time",
+			connConfig:   SQLDBConnectionConfig{ConnectionMaxIdleTime: "often"},
+			expectingErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			connConfig := c.connConfig
+			err := connConfig.SetDefault(dbconfig)
+			if (err != nil) != c.expectingErr {
+				t.Fatalf("expecting error %v but got %v", c.expectingErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if connConfig.connMaxLifeTime != c.maxLifetime || connConfig.connMaxIdleTime != c.maxIdleTime {
+				t.Fatalf("expecting lifetime %s and idle time %s but got %s and %s", c.maxLifetime, c.maxIdleTime, connConfig.connMaxLifeTime, connConfig.connMaxIdleTime)
+			}
+		})
+	}
+}
+
+func TestDBConfigDatabases(t *testing.T) {
+	dbconf := DBConfig{
+		SQLDBs: []SQLDBConfig{
+			{
+				Name:                "orders",
+				Driver:              "postgres",
+				LeaderConnConfig:    SQLDBConnectionConfig{DSN: "postgres://leader/orders", MaxOpenConnections: 20},
+				ReplicasConnConfig:  []SQLDBConnectionConfig{{DSN: "postgres://replica/orders", MaxOpenConnections: 20}},
+				DefaultQueryTimeout: "5s",
+				Migrations:          "database/schema/orders",
+				Default:             true,
+				Pools: []SQLDBPoolConfig{
+					{Name: "batch", MaxOpenConnections: 4, DefaultQueryTimeout: "5m"},
+				},
+			},
+			{Name: "users", Driver: "postgres"},
+		},
+	}
+
+	databases := dbconf.databases()
+	if len(databases) != 3 {
+		t.Fatalf("expecting 3 databases but got %d", len(databases))
+	}
+	names := []string{databases[0].Name, databases[1].Name, databases[2].Name}
+	if names[0] != "orders" || names[1] != "orders-batch" || names[2] != "users" {
+		t.Fatalf("unexpected databases %v", names)
+	}
+
+	batch := databases[1]
+	if batch.LeaderConnConfig.DSN != "postgres://leader/orders" || batch.ReplicasConnConfig[0].DSN != "postgres://replica/orders" {
+		t.Fatalf("expecting the pool connect to the same database but got %+v", batch)
+	}
+	if batch.LeaderConnConfig.MaxOpenConnections != 4 || batch.ReplicasConnConfig[0].MaxOpenConnections != 4 {
+		t.Fatalf("expecting 4 max open connections of the pool but got %+v", batch)
+	}
+	if batch.DefaultQueryTimeout != "5m" || batch.Default || batch.Migrations != "" || len(batch.Pools) != 0 {
+		t.Fatalf("unexpected pool config %+v", batch)
+	}
+	// the configuration of the database is not changed by the pool
+	if dbconf.SQLDBs[0].ReplicasConnConfig[0].MaxOpenConnections != 20 {
+		t.Fatalf("expecting 20 max open connections of the database but got %d", dbconf.SQLDBs[0].ReplicasConnConfig[0].MaxOpenConnections)
+	}
+}
+
+func TestSQLDBQueryCommentConfig(t *testing.T) {
+	opts := SQLDBQueryCommentConfig{Enabled: true, Service: "project"}.options()
+	ctx := attribution.WithLabels(context.Background(), attribution.Labels{Endpoint: "/orders"})
+	if tags := opts.Tags(ctx); tags[sqldb.CommentTagRoute] != "/orders" {
+		t.Fatalf("expecting route /orders from the attribution endpoint but got %v", tags)
+	}
+	if opts.Service != "project" {
+		t.Fatalf("expecting service project but got %s", opts.Service)
+	}
+}
@@ -0,0 +1,35 @@
+package kothak
+
+import (
+	"github.com/albertwidi/go-project-example/internal/pkg/buildinfo"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// Stats of all resources connection pool, keyed by resource name
+// object storage is not listed as it doesn't expose any pool
+type Stats struct {
+	SQLDB     map[string]sqldb.Stats     `json:"sqldb"`
+	Redis     map[string]redis.PoolStats `json:"redis"`
+	BuildInfo buildinfo.Info             `json:"build_info"`
+}
+
+// Stats return the connection pool statistics of all resources
+// this is useful to observe pool saturation, for example via admin server
+func (k *Kothak) Stats() Stats {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	stats := Stats{
+		SQLDB:     make(map[string]sqldb.Stats, len(k.dbs)),
+		Redis:     make(map[string]redis.PoolStats, len(k.rds)),
+		BuildInfo: buildinfo.Get(),
+	}
+	for name, db := range k.dbs {
+		stats.SQLDB[name] = db.Stats()
+	}
+	for name, rds := range k.rds {
+		stats.Redis[name] = rds.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,113 @@
+package kothak
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestStats(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	ctx := context.Background()
+
+	config := Config{
+		DBConfig: DBConfig{
+			SQLDBs: []SQLDBConfig{
+				{
+					Name:             "orders",
+					Driver:           string(sqldb.DriverSQLite),
+					LeaderConnConfig: SQLDBConnectionConfig{DSN: sqldb.SQLiteMemory, MaxOpenConnections: 1, MaxIdleConnections: 1},
+				},
+			},
+		},
+		RedisConfig: RedisConfig{
+			MaxIdle: 2,
+			Rds: []RedisConnConfig{
+				{Name: "session", Address: mr.Addr()},
+				{Name: "cache", Namespace: "billing", Address: mr.Addr()},
+			},
+		},
+	}
+	k, err := New(ctx, config, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.CloseAll()
+
+	db, err := k.GetSQLDB("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the only connection is in use, so the ping wait until it is timed out
+	conn, err := db.Leader().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	if err := db.Leader().PingContext(pingCtx); err == nil {
+		t.Fatal("expecting the ping to wait for the connection in use")
+	}
+	rds, err := k.GetRedis("session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rds.Set(ctx, "session:1", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := k.Stats()
+	if names := statsNames(stats.SQLDB); !reflect.DeepEqual(names, []string{"orders"}) {
+		t.Fatalf("expecting sqldb stats of orders but got %v", names)
+	}
+	if names := statsNames(stats.Redis); !reflect.DeepEqual(names, []string{QualifiedName("billing", "cache"), "session"}) {
+		t.Fatalf("expecting redis stats of billing cache and session but got %v", names)
+	}
+	leader := stats.SQLDB["orders"].Leader
+	if leader.MaxOpenConnections != 1 || leader.OpenConnections != 1 || leader.InUse != 1 || leader.Idle != 0 {
+		t.Fatalf("expecting one open connection in use but got %+v", leader)
+	}
+	if leader.WaitCount != 1 || leader.WaitDuration <= 0 {
+		t.Fatalf("expecting the ping to wait for the connection but got %+v", leader)
+	}
+	if session := stats.Redis["session"]; session != (redis.PoolStats{ActiveCount: 1, IdleCount: 1}) {
+		t.Fatalf("expecting one idle connection of session but got %+v", session)
+	}
+	if cache := stats.Redis[QualifiedName("billing", "cache")]; cache != (redis.PoolStats{}) {
+		t.Fatalf("expecting no connection of billing cache but got %+v", cache)
+	}
+
+	// the connection is idle after it is returned to the pool
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	leader = k.Stats().SQLDB["orders"].Leader
+	if leader.OpenConnections != 1 || leader.InUse != 0 || leader.Idle != 1 {
+		t.Fatalf("expecting one idle connection but got %+v", leader)
+	}
+}
+
+// statsNames return the sorted resource names of the stats
+func statsNames(stats interface{}) []string {
+	var names []string
+	for _, key := range reflect.ValueOf(stats).MapKeys() {
+		names = append(names, key.String())
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,383 @@
+package kothak
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	httpclient "github.com/albertwidi/go-project-example/internal/pkg/http/client"
+	"github.com/albertwidi/go-project-example/internal/pkg/mongodb"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	redigo "github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/albertwidi/go-project-example/internal/pkg/search"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// ValidationErrors is a list of errors found when validating the config
+type ValidationErrors []error
+
+// Error return all errors as one string
+func (verrs ValidationErrors) Error() string {
+	errs := make([]string, len(verrs))
+	for i, err := range verrs {
+		errs[i] = err.Error()
+	}
+	return fmt.Sprintf("kothak: config is not valid:\n%s", strings.Join(errs, "\n"))
+}
+
+// Validate kothak configuration without connecting to any resources
+// this is useful to check the configuration in CI before deploying the program
+func Validate(config Config) error {
+	var (
+		errs     ValidationErrors
+		names    = make(map[string]string)
+		defaults = make(map[string]string)
+	)
+
+	checkDefault := func(kind, namespace, name string, isDefault bool) {
+		if !isDefault {
+			return
+		}
+		if namespace != "" {
+			errs = append(errs, fmt.Errorf("%s %s: resource with namespace cannot be default", kind, QualifiedName(namespace, name)))
+			return
+		}
+		if current, ok := defaults[kind]; ok {
+			errs = append(errs, fmt.Errorf("%s: multiple default resources, %s and %s", kind, current, name))
+			return
+		}
+		defaults[kind] = name
+	}
+
+	checkName := func(kind, namespace, name string) {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is empty", kind))
+			return
+		}
+		if strings.Contains(name, namespaceSeparator) || strings.Contains(namespace, namespaceSeparator) {
+			errs = append(errs, fmt.Errorf("%s %s: name and namespace must not contain %s", kind, name, namespaceSeparator))
+			return
+		}
+		// names is unique per resource kind in the namespace
+		name = QualifiedName(namespace, name)
+		key := kind + "/" + name
+		if _, ok := names[key]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate resource name %s", kind, name))
+			return
+		}
+		names[key] = name
+	}
+
+	if config.MaxConcurrentInit < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrent_init: must not be negative, got %d", config.MaxConcurrentInit))
+	}
+
+	for _, dbconfig := range config.DBConfig.SQLDBs {
+		checkName("database", dbconfig.Namespace, dbconfig.Name)
+		checkDefault("database", dbconfig.Namespace, dbconfig.Name, dbconfig.Default)
+		if err := sqldb.ValidateDriver(dbconfig.Driver); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %w", dbconfig.Name, err))
+			continue
+		}
+		if err := dbconfig.LeaderConnConfig.validateDSN(dbconfig.Driver); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: leader: %w", dbconfig.Name, err))
+		}
+		if err := dbconfig.LeaderConnConfig.validateDurations(); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: leader: %w", dbconfig.Name, err))
+		}
+		// replica is optional
+		if dbconfig.ReplicaConnConfig.configured() {
+			if err := dbconfig.ReplicaConnConfig.validateDSN(dbconfig.Driver); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: replica: %w", dbconfig.Name, err))
+			}
+			if err := dbconfig.ReplicaConnConfig.validateDurations(); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: replica: %w", dbconfig.Name, err))
+			}
+		}
+		for idx, replica := range dbconfig.ReplicasConnConfig {
+			if err := replica.validateDSN(dbconfig.Driver); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: replicas[%d]: %w", dbconfig.Name, idx, err))
+			}
+			if err := replica.validateDurations(); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: replicas[%d]: %w", dbconfig.Name, idx, err))
+			}
+		}
+		// sqlite is a local file or memory, there is no replica to read from
+		if sqldb.DriverName(dbconfig.Driver) == sqldb.DriverSQLite && (dbconfig.ReplicaConnConfig.configured() || len(dbconfig.ReplicasConnConfig) > 0) {
+			errs = append(errs, fmt.Errorf("database %s: replica: not supported in sqlite", dbconfig.Name))
+		}
+		if (dbconfig.TLS.CertFile == "") != (dbconfig.TLS.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("database %s: tls: %w", dbconfig.Name, sqldb.ErrTLSKeyPair))
+		}
+		if dbconfig.Driver == sqldb.DriverPostgres && dbconfig.TLS.ServerName != "" {
+			errs = append(errs, fmt.Errorf("database %s: tls: %w", dbconfig.Name, sqldb.ErrTLSServerName))
+		}
+		if dbconfig.ClickHouse != (SQLDBClickHouseConfig{}) {
+			if sqldb.DriverName(dbconfig.Driver) != sqldb.DriverClickHouse {
+				errs = append(errs, fmt.Errorf("database %s: clickhouse: only supported in clickhouse", dbconfig.Name))
+			}
+			if err := sqldb.ValidateClickHouseCompression(dbconfig.ClickHouse.Compression); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: clickhouse: %w", dbconfig.Name, err))
+			}
+			if dbconfig.ClickHouse.AsyncInsertBusyTimeout != "" {
+				if _, err := time.ParseDuration(dbconfig.ClickHouse.AsyncInsertBusyTimeout); err != nil {
+					errs = append(errs, fmt.Errorf("database %s: clickhouse: async_insert_busy_timeout: %w", dbconfig.Name, err))
+				}
+			}
+		}
+		if dbconfig.SQLServer != (SQLDBSQLServerConfig{}) {
+			if sqldb.DriverName(dbconfig.Driver) != sqldb.DriverSQLServer {
+				errs = append(errs, fmt.Errorf("database %s: sqlserver: only supported in sqlserver", dbconfig.Name))
+			}
+			if err := sqldb.ValidateSQLServerEncrypt(dbconfig.SQLServer.Encrypt); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: sqlserver: %w", dbconfig.Name, err))
+			}
+		}
+		if err := sqldb.ValidateBackend(dbconfig.Driver, dbconfig.Backend); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %w", dbconfig.Name, err))
+		}
+		switch dbconfig.IAM.Provider {
+		case "", iamProviderGCP:
+		case iamProviderAWS:
+			if dbconfig.IAM.Region == "" {
+				errs = append(errs, fmt.Errorf("database %s: iam: region is required by aws provider", dbconfig.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("database %s: iam: provider %s is not supported", dbconfig.Name, dbconfig.IAM.Provider))
+		}
+		if err := sqldb.ValidateBalancer(dbconfig.LoadBalancer); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %w", dbconfig.Name, err))
+		}
+		if dbconfig.HealthCheckInterval != "" {
+			if _, err := time.ParseDuration(dbconfig.HealthCheckInterval); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: health_check_interval: %w", dbconfig.Name, err))
+			}
+		}
+		if dbconfig.MaxReplicaLag != "" {
+			if _, err := time.ParseDuration(dbconfig.MaxReplicaLag); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: max_replica_lag: %w", dbconfig.Name, err))
+			}
+		}
+		if dbconfig.DefaultQueryTimeout != "" {
+			if _, err := time.ParseDuration(dbconfig.DefaultQueryTimeout); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: default_query_timeout: %w", dbconfig.Name, err))
+			}
+		}
+		if dbconfig.SlowQueryThreshold != "" {
+			if _, err := time.ParseDuration(dbconfig.SlowQueryThreshold); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: slow_query_threshold: %w", dbconfig.Name, err))
+			}
+		}
+		if dbconfig.CircuitBreakerTimeout != "" {
+			if _, err := time.ParseDuration(dbconfig.CircuitBreakerTimeout); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: circuit_breaker_timeout: %w", dbconfig.Name, err))
+			}
+		}
+		for idx, pool := range dbconfig.Pools {
+			if pool.Name == "" {
+				errs = append(errs, fmt.Errorf("database %s: pools[%d]: name is empty", dbconfig.Name, idx))
+				continue
+			}
+			// the pool is another database, so its name must not be used by other database
+			checkName("database", dbconfig.Namespace, dbconfig.Name+"-"+pool.Name)
+			for _, d := range []struct{ field, value string }{
+				{"conn_max_lifetime", pool.ConnectionMaxLifetime},
+				{"conn_max_idle_time", pool.ConnectionMaxIdleTime},
+				{"default_query_timeout", pool.DefaultQueryTimeout},
+			} {
+				if d.value == "" {
+					continue
+				}
+				if _, err := time.ParseDuration(d.value); err != nil {
+					errs = append(errs, fmt.Errorf("database %s: pools[%d]: %s: %w", dbconfig.Name, idx, d.field, err))
+				}
+			}
+		}
+		// the pool of in-memory sqlite is another database, and sqlite allow one writer at a time
+		if sqldb.DriverName(dbconfig.Driver) == sqldb.DriverSQLite && len(dbconfig.Pools) > 0 {
+			errs = append(errs, fmt.Errorf("database %s: pools: not supported in sqlite", dbconfig.Name))
+		}
+		if len(dbconfig.MaterializedViews) > 0 && dbconfig.Driver != sqldb.DriverPostgres {
+			errs = append(errs, fmt.Errorf("database %s: materialized_views: only supported in postgres", dbconfig.Name))
+		}
+		views := make(map[string]bool, len(dbconfig.MaterializedViews))
+		for _, view := range dbconfig.MaterializedViews {
+			views[view.Name] = true
+		}
+		for idx, view := range dbconfig.MaterializedViews {
+			if view.Name == "" {
+				errs = append(errs, fmt.Errorf("database %s: materialized_views[%d]: name is empty", dbconfig.Name, idx))
+			}
+			if view.RefreshInterval != "" {
+				if _, err := time.ParseDuration(view.RefreshInterval); err != nil {
+					errs = append(errs, fmt.Errorf("database %s: materialized_views[%d]: refresh_interval: %w", dbconfig.Name, idx, err))
+				}
+			}
+			for _, after := range view.RefreshAfter {
+				if !views[after] {
+					errs = append(errs, fmt.Errorf("database %s: materialized_views[%d]: refresh_after view %s is not found", dbconfig.Name, idx, after))
+				}
+			}
+		}
+	}
+
+	for _, redisconfig := range config.RedisConfig.Rds {
+		checkName("redis", redisconfig.Namespace, redisconfig.Name)
+		checkDefault("redis", redisconfig.Namespace, redisconfig.Name, redisconfig.Default)
+		addresses := []string{redisconfig.Address}
+		switch redisconfig.Mode {
+		case "", redisModeStandalone:
+			if len(redisconfig.Addresses) > 0 {
+				errs = append(errs, fmt.Errorf("redis %s: addresses: only supported in cluster and sentinel mode", redisconfig.Name))
+			}
+		case redisModeCluster:
+			addresses = redisconfig.seeds()
+		case redisModeSentinel:
+			addresses = redisconfig.seeds()
+			if redisconfig.MasterName == "" {
+				errs = append(errs, fmt.Errorf("redis %s: master_name is empty", redisconfig.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("redis %s: mode: %s is not supported", redisconfig.Name, redisconfig.Mode))
+			continue
+		}
+		if redisconfig.MasterName != "" && redisconfig.Mode != redisModeSentinel {
+			errs = append(errs, fmt.Errorf("redis %s: master_name: only supported in sentinel mode", redisconfig.Name))
+		}
+		if len(addresses) == 0 || addresses[0] == "" {
+			errs = append(errs, fmt.Errorf("redis %s: address is empty", redisconfig.Name))
+			continue
+		}
+		for _, address := range addresses {
+			var err error
+			// the address can be redis url, which is not supported for the sentinels
+			if address == redisconfig.Address && redisconfig.Mode != redisModeSentinel && strings.Contains(address, "://") {
+				_, err = redigo.ParseURL(address)
+			} else {
+				_, _, err = net.SplitHostPort(address)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("redis %s: invalid address: %w", redisconfig.Name, err))
+			}
+		}
+		if (redisconfig.TLS.CertFile == "") != (redisconfig.TLS.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("redis %s: tls: %w", redisconfig.Name, redigo.ErrCertificateKeyNil))
+		}
+		if redisconfig.Username != "" && redisconfig.Password == "" {
+			errs = append(errs, fmt.Errorf("redis %s: username is set without password", redisconfig.Name))
+		}
+		for _, cmd := range redisconfig.AllowedCommands {
+			if !redis.IsDangerousCommand(cmd) {
+				errs = append(errs, fmt.Errorf("redis %s: allowed_commands: %s is not blocked", redisconfig.Name, cmd))
+			}
+		}
+		switch redisconfig.Backend {
+		case "", redisBackendRedigo:
+		case redisBackendGoRedis:
+			if redisconfig.Mode == redisModeCluster && strings.Contains(redisconfig.Address, "://") {
+				errs = append(errs, fmt.Errorf("redis %s: url is only supported in standalone mode by goredis backend", redisconfig.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("redis %s: backend: %s is not supported", redisconfig.Name, redisconfig.Backend))
+		}
+	}
+
+	for _, objconfig := range config.ObjectStorageConfig {
+		checkName("object_storage", objconfig.Namespace, objconfig.Name)
+		checkDefault("object_storage", objconfig.Namespace, objconfig.Name, objconfig.Default)
+		if objconfig.Bucket == "" {
+			errs = append(errs, fmt.Errorf("object_storage %s: bucket is empty", objconfig.Name))
+		}
+
+		switch strings.ToLower(objconfig.Provider) {
+		case objectstorage.StorageLocal:
+		case objectstorage.StorageGCS:
+			if objconfig.GCS.JSONKey == "" {
+				errs = append(errs, fmt.Errorf("object_storage %s: gcs json_key is empty", objconfig.Name))
+			}
+		case objectstorage.StorageS3, objectstorage.StorageDO, objectstorage.StorageMinio:
+			if objconfig.S3.ClientID == "" || objconfig.S3.ClientSecret == "" {
+				errs = append(errs, fmt.Errorf("object_storage %s: s3 client_id and client_secret is required", objconfig.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("object_storage %s: provider %q not found", objconfig.Name, objconfig.Provider))
+		}
+
+		cache := objconfig.Cache
+		if !cache.Enabled && cache.Redis != "" {
+			errs = append(errs, fmt.Errorf("object_storage %s: cache redis is set but cache is not enabled", objconfig.Name))
+		}
+		if cache.MaxObjectSize < 0 || cache.MaxMemory < 0 {
+			errs = append(errs, fmt.Errorf("object_storage %s: cache max_object_size and max_memory must not be negative", objconfig.Name))
+		}
+		if cache.TTL != "" {
+			if _, err := time.ParseDuration(cache.TTL); err != nil {
+				errs = append(errs, fmt.Errorf("object_storage %s: cache ttl: %w", objconfig.Name, err))
+			}
+		}
+	}
+
+	for _, mongoconfig := range config.MongoDBConfig.MongoDBs {
+		checkName("mongodb", mongoconfig.Namespace, mongoconfig.Name)
+		if err := mongodb.ValidateURI(mongoconfig.URI); err != nil {
+			errs = append(errs, fmt.Errorf("mongodb %s: %w", mongoconfig.Name, err))
+		}
+		if mongoconfig.Database == "" {
+			errs = append(errs, fmt.Errorf("mongodb %s: %w", mongoconfig.Name, mongodb.ErrDatabaseEmpty))
+		}
+		if _, err := mongodb.ParseReadPreference(mongoconfig.ReadPreference); err != nil {
+			errs = append(errs, fmt.Errorf("mongodb %s: %w", mongoconfig.Name, err))
+		}
+	}
+
+	for _, searchconfig := range config.SearchConfig {
+		checkName("elasticsearch", searchconfig.Namespace, searchconfig.Name)
+		if err := search.ValidateAddresses(searchconfig.Addresses); err != nil {
+			errs = append(errs, fmt.Errorf("elasticsearch %s: %w", searchconfig.Name, err))
+		}
+		for _, dur := range []string{searchconfig.SniffInterval, searchconfig.Timeout} {
+			if dur == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(dur); err != nil {
+				errs = append(errs, fmt.Errorf("elasticsearch %s: %w", searchconfig.Name, err))
+			}
+		}
+	}
+
+	for _, grpcconfig := range config.GRPCClientConfig {
+		checkName("grpc_clients", grpcconfig.Namespace, grpcconfig.Name)
+		conf, err := grpcconfig.toClientConfig()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("grpc_clients %s: %w", grpcconfig.Name, err))
+			continue
+		}
+		if err := conf.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("grpc_clients %s: %w", grpcconfig.Name, err))
+		}
+	}
+
+	for _, httpconfig := range config.HTTPClientConfig {
+		checkName("http_clients", httpconfig.Namespace, httpconfig.Name)
+		if _, err := httpconfig.toClientConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("http_clients %s: %w", httpconfig.Name, err))
+		}
+		if err := httpclient.ValidateProxy(httpconfig.Proxy); err != nil {
+			errs = append(errs, fmt.Errorf("http_clients %s: %w", httpconfig.Name, err))
+		}
+	}
+
+	for _, aliasconfig := range config.Aliases {
+		checkName(aliasconfig.Kind, aliasconfig.Namespace, aliasconfig.Name)
+	}
+	errs = append(errs, validateAliases(config.Aliases, names)...)
+
+	errs = append(errs, configTasks(config).validate()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package age encrypt and decrypt files with age (https://age-encryption.org/v1) using filippo.io/age
+// only X25519 recipients and identities are supported, and the file can be binary or ascii armored
+//
+//	identities, err := age.ParseIdentities(strings.NewReader(os.Getenv("SOPS_AGE_KEY")))
+//	plaintext, err := age.Decrypt(ciphertext, identities...)
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// list of error
+var (
+	ErrInvalidIdentity  = errors.New("age: invalid identity")
+	ErrInvalidRecipient = errors.New("age: invalid recipient")
+	ErrRecipientsEmpty  = errors.New("age: recipients is empty")
+	ErrIdentitiesEmpty  = errors.New("age: identities is empty")
+	ErrInvalidHeader    = errors.New("age: invalid header")
+	ErrNoMatch          = errors.New("age: no identity matched any of the recipients")
+	ErrInvalidPayload   = errors.New("age: invalid payload")
+)
+
+// Recipient is X25519 recipient, the public key of the identity
+type Recipient struct {
+	recipient *age.X25519Recipient
+}
+
+// ParseRecipient parse age1... recipient
+func ParseRecipient(s string) (*Recipient, error) {
+	r, err := age.ParseX25519Recipient(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	return &Recipient{recipient: r}, nil
+}
+
+// String return the age1... format of the recipient
+func (r *Recipient) String() string {
+	return r.recipient.String()
+}
+
+// Identity is X25519 identity, the secret key of the recipient
+type Identity struct {
+	identity *age.X25519Identity
+}
+
+// ParseIdentity parse AGE-SECRET-KEY-1... identity, the lowercase identity is accepted as bech32 is case insensitive
+func ParseIdentity(s string) (*Identity, error) {
+	i, err := age.ParseX25519Identity(strings.ToUpper(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdentity, err)
+	}
+	return &Identity{identity: i}, nil
+}
+
+// ParseIdentities parse identity file, one identity per line, empty line and line started with # are ignored
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var identities []*Identity
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, ErrIdentitiesEmpty
+	}
+	return identities, nil
+}
+
+// Recipient return the recipient of the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{recipient: i.identity.Recipient()}
+}
+
+// IsArmored return true when the content is ascii armored age file
+func IsArmored(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte(armor.Header))
+}
+
+// Encrypt the plaintext to the recipients, and return binary age file
+// use Armor to get ascii armored file, for example to put the file in the configuration
+func Encrypt(plaintext []byte, recipients ...*Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, ErrRecipientsEmpty
+	}
+	rs := make([]age.Recipient, len(recipients))
+	for idx, r := range recipients {
+		rs[idx] = r.recipient
+	}
+	out := &bytes.Buffer{}
+	w, err := age.Encrypt(out, rs...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Decrypt the age file with the identities, the file can be binary or ascii armored
+func Decrypt(content []byte, identities ...*Identity) ([]byte, error) {
+	if len(identities) == 0 {
+		return nil, ErrIdentitiesEmpty
+	}
+	var src io.Reader = bytes.NewReader(content)
+	if IsArmored(content) {
+		src = armor.NewReader(bytes.NewReader(trimArmor(content)))
+	}
+	is := make([]age.Identity, len(identities))
+	for idx, i := range identities {
+		is[idx] = i.identity
+	}
+
+	r, err := age.Decrypt(src, is...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, ErrNoMatch
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return plaintext, nil
+}
+
+// Armor encode the age file in ascii armor
+func Armor(content []byte) []byte {
+	out := &bytes.Buffer{}
+	w := armor.NewWriter(out)
+	// write to bytes.Buffer never return error
+	w.Write(content)
+	w.Close()
+	return out.Bytes()
+}
+
+// trimArmor remove the whitespace around every line of ascii armored file
+// so the armored value can be indented in the configuration, for example in yaml block or toml multiline string
+func trimArmor(content []byte) []byte {
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	for idx, line := range lines {
+		lines[idx] = strings.TrimSpace(line)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,269 @@
+package age
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// generateIdentity return new random identity in AGE-SECRET-KEY-1... format
+func generateIdentity(t *testing.T) string {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity.String()
+}
+
+// encrypt the plaintext to the identities
+func encrypt(t *testing.T, plaintext []byte, armor bool, identities ...*Identity) []byte {
+	t.Helper()
+	recipients := make([]*Recipient, len(identities))
+	for idx, identity := range identities {
+		recipients[idx] = identity.Recipient()
+	}
+	encrypted, err := Encrypt(plaintext, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if armor {
+		return Armor(encrypted)
+	}
+	return encrypted
+}
+
+func TestParseIdentity(t *testing.T) {
+	cases := []struct {
+		name     string
+		identity string
+		err      error
+	}{
+		{
+			name:     "valid",
+			identity: generateIdentity(t),
+		},
+		{
+			name:     "lowercase",
+			identity: strings.ToLower(generateIdentity(t)),
+		},
+		{
+			name:     "invalid checksum",
+			identity: generateIdentity(t)[:70] + "QQQQQQ",
+			err:      ErrInvalidIdentity,
+		},
+		{
+			name:     "recipient is not identity",
+			identity: mustRecipient(t),
+			err:      ErrInvalidIdentity,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseIdentity(c.identity)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func mustRecipient(t *testing.T) string {
+	identity, err := ParseIdentity(generateIdentity(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity.Recipient().String()
+}
+
+func TestParseIdentities(t *testing.T) {
+	content := "# created: 2020-01-01\n# public key: age1...\n" + generateIdentity(t) + "\n\n" + generateIdentity(t) + "\n"
+	identities, err := ParseIdentities(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 2 {
+		t.Fatalf("expecting 2 identities but got %d", len(identities))
+	}
+	recipient := identities[0].Recipient().String()
+	if !strings.HasPrefix(recipient, "age1") {
+		t.Fatalf("expecting age1 recipient but got %s", recipient)
+	}
+	parsed, err := ParseRecipient(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != recipient {
+		t.Fatalf("expecting recipient %s but got %s", recipient, parsed.String())
+	}
+
+	if _, err := ParseIdentities(strings.NewReader("# empty\n")); !errors.Is(err, ErrIdentitiesEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrIdentitiesEmpty, err)
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	identity, err := ParseIdentity(generateIdentity(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseIdentity(generateIdentity(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the payload is encrypted in 64KiB chunks
+	large := bytes.Repeat([]byte("a"), 64*1024*2+10)
+
+	cases := []struct {
+		name       string
+		plaintext  []byte
+		armor      bool
+		recipients []*Identity
+		identities []*Identity
+		err        error
+	}{
+		{
+			name:       "binary",
+			plaintext:  []byte("secret"),
+			recipients: []*Identity{identity},
+			identities: []*Identity{identity},
+		},
+		{
+			name:       "armored",
+			plaintext:  []byte("secret"),
+			armor:      true,
+			recipients: []*Identity{identity},
+			identities: []*Identity{identity},
+		},
+		{
+			name:       "empty",
+			plaintext:  []byte{},
+			recipients: []*Identity{identity},
+			identities: []*Identity{identity},
+		},
+		{
+			name:       "multiple chunks",
+			plaintext:  large,
+			recipients: []*Identity{identity},
+			identities: []*Identity{identity},
+		},
+		{
+			name:       "second recipient",
+			plaintext:  []byte("secret"),
+			recipients: []*Identity{other, identity},
+			identities: []*Identity{identity},
+		},
+		{
+			name:       "no match",
+			plaintext:  []byte("secret"),
+			recipients: []*Identity{other},
+			identities: []*Identity{identity},
+			err:        ErrNoMatch,
+		},
+		{
+			name:       "no recipient",
+			plaintext:  []byte("secret"),
+			identities: []*Identity{identity},
+			err:        ErrRecipientsEmpty,
+		},
+		{
+			name:       "no identity",
+			plaintext:  []byte("secret"),
+			recipients: []*Identity{identity},
+			err:        ErrIdentitiesEmpty,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.recipients) == 0 {
+				if _, err := Encrypt(c.plaintext); !errors.Is(err, c.err) {
+					t.Fatalf("expecting error %v but got %v", c.err, err)
+				}
+				return
+			}
+			encrypted := encrypt(t, c.plaintext, c.armor, c.recipients...)
+			if IsArmored(encrypted) != c.armor {
+				t.Fatalf("expecting armored %v but got %v", c.armor, !c.armor)
+			}
+			plaintext, err := Decrypt(encrypted, c.identities...)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(plaintext, c.plaintext) {
+				t.Fatalf("expecting %d bytes plaintext but got %d bytes", len(c.plaintext), len(plaintext))
+			}
+		})
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	identity, err := ParseIdentity(generateIdentity(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := encrypt(t, []byte("secret"), false, identity)
+	idx := bytes.Index(encrypted, []byte("\n---"))
+	header := append([]byte(nil), encrypted...)
+	header[len("age-encryption.org/v1")+4]++
+	if _, err := Decrypt(header, identity); err == nil {
+		t.Fatal("expecting error when the header is changed")
+	}
+
+	payload := append([]byte(nil), encrypted...)
+	payload[len(payload)-1] ^= 1
+	if _, err := Decrypt(payload, identity); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidPayload, err)
+	}
+
+	truncated := encrypted[:idx]
+	if _, err := Decrypt(truncated, identity); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidHeader, err)
+	}
+}
+
+// TestDecryptCLI decrypt the files encrypted by the age cli with the identity of testdata/key.txt
+//
+//	age-keygen -o key.txt
+//	age -r <recipient> -o binary.age plaintext.txt
+//	age -a -r <recipient> -o armored.age plaintext.txt
+func TestDecryptCLI(t *testing.T) {
+	key, err := ioutil.ReadFile("testdata/key.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities, err := ParseIdentities(bytes.NewReader(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipient := identities[0].Recipient().String(); !bytes.Contains(key, []byte("# public key: "+recipient)) {
+		t.Fatalf("expecting recipient %s in the key file", recipient)
+	}
+	expect, err := ioutil.ReadFile("testdata/plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"testdata/binary.age", "testdata/armored.age"} {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext, err := Decrypt(content, identities...)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if !bytes.Equal(plaintext, expect) {
+			t.Fatalf("%s: expecting %q but got %q", file, expect, plaintext)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+// Package alert evaluate threshold and anomaly rules over prometheus metrics and notify the registered notifiers
+// it is a lightweight alerting for basic cases, without external alerting system
+//
+//	evaluator, err := alert.New(prometheus.DefaultGatherer, []alert.Rule{
+//		{Name: "pool_saturation", Metric: "kothak_resource_pool_saturation", Threshold: 0.9, For: time.Minute},
+//		{Name: "error_spike", Metric: "http_request_total", Labels: map[string]string{"code": "500"}, Rate: true, Threshold: 1, Deviation: 3},
+//	}, &alert.Options{Notifiers: []alert.Notifier{alert.Logger(logger)}})
+//	evaluator.Start(ctx)
+//	defer evaluator.Close()
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// list of alert status
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// list of default options
+const (
+	DefaultInterval = time.Second * 30
+	DefaultWindow   = 30
+)
+
+// list of error
+var (
+	ErrRuleNameEmpty   = errors.New("alert: rule name is empty")
+	ErrRuleMetricEmpty = errors.New("alert: rule metric is empty")
+	ErrDuplicateRule   = errors.New("alert: duplicate rule")
+)
+
+// Rule of alert, every series of the metric which match the labels is evaluated separately
+// the alert fire when the value is above the threshold, and when Deviation is set, also above the recent values
+type Rule struct {
+	Name string
+	// Metric name of counter, gauge or untyped metric
+	Metric string
+	// Labels filter the series of the metric by label value
+	Labels map[string]string
+	// Rate evaluate the per second increase of the counter instead of the value, for example error rate
+	Rate bool
+	// Threshold of the value, also the minimum value of anomaly so small spike is not alerted
+	Threshold float64
+	// Deviation is the number of standard deviation above the mean of recent values which is an anomaly
+	// only threshold is used when zero
+	Deviation float64
+	// Window is the number of recent values of anomaly detection, default to 30
+	// anomaly is not detected until half of the window is collected, and alerting values are not added to the window
+	Window int
+	// For is the duration of the condition before the alert fire
+	For time.Duration
+	// Message of the alert, default to the rule name
+	Message string
+}
+
+// Alert notified by the evaluator, when the alert fire and when it is resolved
+type Alert struct {
+	Rule    string            `json:"rule"`
+	Status  string            `json:"status"`
+	Labels  map[string]string `json:"labels"`
+	Value   float64           `json:"value"`
+	Limit   float64           `json:"limit"`
+	Message string            `json:"message"`
+	// StartsAt is the time the alert fire, the same in the resolved alert
+	StartsAt time.Time `json:"starts_at"`
+	At       time.Time `json:"at"`
+}
+
+// Key of the alert, unique per rule and series
+func (a Alert) Key() string {
+	return a.Rule + "{" + labelsKey(a.Labels) + "}"
+}
+
+// Notifier of alert
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NotifierFunc is function as notifier
+type NotifierFunc func(ctx context.Context, alert Alert) error
+
+// Notify implements Notifier
+func (f NotifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// Options of evaluator
+type Options struct {
+	// Interval of evaluation, default to 30 seconds
+	Interval time.Duration
+	// Notifiers of the alerts, every notifier is called even when the other failed
+	Notifiers []Notifier
+	// OnError is called when the evaluation or notification failed in Start
+	OnError func(err error)
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// series is the state of one series of the rule
+type series struct {
+	rule         string
+	labels       map[string]string
+	last         float64
+	lastAt       time.Time
+	hasLast      bool
+	window       []float64
+	pendingSince time.Time
+	firing       bool
+	startsAt     time.Time
+}
+
+// Evaluator of alert rules
+type Evaluator struct {
+	gatherer prometheus.Gatherer
+	rules    []Rule
+	opts     Options
+
+	mu     sync.Mutex
+	series map[string]*series
+	done   <-chan error
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// New evaluator of the rules over the metrics of the gatherer
+func New(gatherer prometheus.Gatherer, rules []Rule, options *Options) (*Evaluator, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	// copy the rules, so the defaults are not set to the caller rules
+	rules = append([]Rule(nil), rules...)
+	names := make(map[string]bool, len(rules))
+	for idx := range rules {
+		rule := &rules[idx]
+		if rule.Name == "" {
+			return nil, ErrRuleNameEmpty
+		}
+		if rule.Metric == "" {
+			return nil, fmt.Errorf("%w: %s", ErrRuleMetricEmpty, rule.Name)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateRule, rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Window <= 0 {
+			rule.Window = DefaultWindow
+		}
+		if rule.Message == "" {
+			rule.Message = rule.Name
+		}
+	}
+
+	e := Evaluator{
+		gatherer: gatherer,
+		rules:    rules,
+		opts:     opts,
+		series:   make(map[string]*series),
+		stop:     make(chan struct{}),
+	}
+	return &e, nil
+}
+
+// Evaluate the rules once and notify the changed alerts
+// the changed alerts are returned together with the first notification error
+func (e *Evaluator) Evaluate(ctx context.Context) ([]Alert, error) {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("alert: failed to gather metrics: %w", err)
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	now := e.opts.Now()
+	var alerts []Alert
+	e.mu.Lock()
+	for _, rule := range e.rules {
+		family, ok := byName[rule.Metric]
+		if !ok {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if !matchLabels(labels, rule.Labels) {
+				continue
+			}
+			value, ok := metricValue(m)
+			if !ok {
+				continue
+			}
+			key := rule.Name + "{" + labelsKey(labels) + "}"
+			s, ok := e.series[key]
+			if !ok {
+				s = &series{rule: rule.Name, labels: labels}
+				e.series[key] = s
+			}
+			if alert, changed := s.evaluate(rule, value, now); changed {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	var notifyErr error
+	for _, alert := range alerts {
+		for _, notifier := range e.opts.Notifiers {
+			if err := notifier.Notify(ctx, alert); err != nil && notifyErr == nil {
+				notifyErr = fmt.Errorf("alert: failed to notify %s: %w", alert.Key(), err)
+			}
+		}
+	}
+	return alerts, notifyErr
+}
+
+// evaluate the value of the series, the alert is returned when it fire or resolved
+func (s *series) evaluate(rule Rule, value float64, now time.Time) (Alert, bool) {
+	if rule.Rate {
+		last, lastAt, hasLast := s.last, s.lastAt, s.hasLast
+		s.last, s.lastAt, s.hasLast = value, now, true
+		elapsed := now.Sub(lastAt).Seconds()
+		if !hasLast || elapsed <= 0 {
+			return Alert{}, false
+		}
+		increase := value - last
+		// counter is reset when the process restart
+		if increase < 0 {
+			increase = value
+		}
+		value = increase / elapsed
+	}
+
+	limit := rule.Threshold
+	breach := value > rule.Threshold
+	if rule.Deviation > 0 {
+		minSamples := rule.Window / 2
+		if minSamples < 2 {
+			minSamples = 2
+		}
+		if len(s.window) < minSamples {
+			breach = false
+		} else {
+			mean, stddev := meanStddev(s.window)
+			limit = math.Max(rule.Threshold, mean+rule.Deviation*stddev)
+			breach = value > limit
+		}
+		// alerting value is not added to the window, so long incident does not become the baseline
+		if !breach {
+			s.window = append(s.window, value)
+			if len(s.window) > rule.Window {
+				s.window = s.window[len(s.window)-rule.Window:]
+			}
+		}
+	}
+
+	alert := Alert{
+		Rule:    rule.Name,
+		Labels:  s.labels,
+		Value:   value,
+		Limit:   limit,
+		Message: rule.Message,
+		At:      now,
+	}
+	if !breach {
+		s.pendingSince = time.Time{}
+		if !s.firing {
+			return Alert{}, false
+		}
+		s.firing = false
+		alert.Status = StatusResolved
+		alert.StartsAt = s.startsAt
+		return alert, true
+	}
+
+	if s.pendingSince.IsZero() {
+		s.pendingSince = now
+	}
+	if s.firing || now.Sub(s.pendingSince) < rule.For {
+		return Alert{}, false
+	}
+	s.firing = true
+	s.startsAt = now
+	alert.Status = StatusFiring
+	alert.StartsAt = now
+	return alert, true
+}
+
+// Firing return the firing alerts
+func (e *Evaluator) Firing() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alerts []Alert
+	for _, s := range e.series {
+		if !s.firing {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			Rule:     s.rule,
+			Status:   StatusFiring,
+			Labels:   s.labels,
+			StartsAt: s.startsAt,
+		})
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Key() < alerts[j].Key()
+	})
+	return alerts
+}
+
+// Start evaluate the rules every interval until Close is called
+func (e *Evaluator) Start(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done != nil {
+		return
+	}
+	e.done = safego.Go(safego.Detach(ctx), "alert.evaluator", e.run)
+}
+
+// Close stop the evaluation and wait for the running evaluation
+func (e *Evaluator) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+	})
+	e.mu.Lock()
+	done := e.done
+	e.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	return <-done
+}
+
+func (e *Evaluator) run(ctx context.Context) error {
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return nil
+		case <-ticker.C:
+			if _, err := e.Evaluate(ctx); err != nil {
+				e.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// metricValue return the value of counter, gauge or untyped metric
+func metricValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	}
+	return 0, false
+}
+
+func matchLabels(labels, matchers map[string]string) bool {
+	for name, value := range matchers {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// labelsKey return the labels sorted by name, formatted as name="value"
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for idx, name := range names {
+		pairs[idx] = fmt.Sprintf("%s=%q", name, labels[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func meanStddev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
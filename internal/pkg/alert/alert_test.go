@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestEvaluateThreshold(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	saturation := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "pool_saturation"}, []string{"name"})
+	registry.MustRegister(saturation)
+
+	now := time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+	var notified []Alert
+	evaluator, err := New(registry, []Rule{
+		{Name: "saturated", Metric: "pool_saturation", Labels: map[string]string{"name": "users"}, Threshold: 0.9, For: time.Second * 30},
+	}, &Options{
+		Notifiers: []Notifier{NotifierFunc(func(ctx context.Context, alert Alert) error {
+			notified = append(notified, alert)
+			return nil
+		})},
+		Now: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		users   float64
+		orders  float64
+		elapsed time.Duration
+		status  string
+	}{
+		{users: 0.5, orders: 1},
+		// pending for 30 seconds before firing
+		{users: 0.95, orders: 1, elapsed: time.Second * 30},
+		{users: 0.95, orders: 1, elapsed: time.Second * 30, status: StatusFiring},
+		{users: 0.99, orders: 1, elapsed: time.Second * 30},
+		{users: 0.2, orders: 1, elapsed: time.Second * 30, status: StatusResolved},
+	}
+	for idx, step := range steps {
+		now = now.Add(step.elapsed)
+		saturation.WithLabelValues("users").Set(step.users)
+		saturation.WithLabelValues("orders").Set(step.orders)
+		alerts, err := evaluator.Evaluate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step.status == "" {
+			if len(alerts) != 0 {
+				t.Fatalf("step %d: expect no alert but got %v", idx, alerts)
+			}
+			continue
+		}
+		if len(alerts) != 1 || alerts[0].Status != step.status || alerts[0].Labels["name"] != "users" {
+			t.Fatalf("step %d: expect %s alert of users but got %v", idx, step.status, alerts)
+		}
+		if step.status == StatusFiring && len(evaluator.Firing()) != 1 {
+			t.Fatalf("step %d: expect firing alert", idx)
+		}
+	}
+	if len(notified) != 2 || notified[1].StartsAt != notified[0].StartsAt {
+		t.Fatalf("expect firing and resolved notification of the same alert but got %v", notified)
+	}
+	if len(evaluator.Firing()) != 0 {
+		t.Fatalf("expect no firing alert but got %v", evaluator.Firing())
+	}
+}
+
+func TestEvaluateRateAnomaly(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_request_total"}, []string{"code"})
+	registry.MustRegister(requests)
+
+	now := time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+	evaluator, err := New(registry, []Rule{
+		{Name: "error_spike", Metric: "http_request_total", Labels: map[string]string{"code": "500"}, Rate: true, Threshold: 1, Deviation: 3, Window: 10},
+	}, &Options{Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluate := func(increase float64) []Alert {
+		t.Helper()
+		now = now.Add(time.Second * 10)
+		requests.WithLabelValues("500").Add(increase)
+		alerts, err := evaluator.Evaluate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return alerts
+	}
+	// the baseline of 2 to 3 errors per second, above the threshold but not an anomaly
+	for i := 0; i < 11; i++ {
+		if alerts := evaluate(float64(20 + i%2*10)); len(alerts) != 0 {
+			t.Fatalf("expect no alert in baseline but got %v", alerts)
+		}
+	}
+	alerts := evaluate(200)
+	if len(alerts) != 1 || alerts[0].Status != StatusFiring || alerts[0].Value != 20 {
+		t.Fatalf("expect error spike alert of 20 per second but got %v", alerts)
+	}
+	if alerts[0].Limit <= 3 || alerts[0].Limit >= 20 {
+		t.Fatalf("expect limit above the baseline but got %g", alerts[0].Limit)
+	}
+	// the spike is not added to the baseline
+	if alerts := evaluate(250); len(alerts) != 0 {
+		t.Fatalf("expect alert is still firing but got %v", alerts)
+	}
+	if alerts := evaluate(20); len(alerts) != 1 || alerts[0].Status != StatusResolved {
+		t.Fatalf("expect resolved alert but got %v", alerts)
+	}
+}
+
+func TestNewInvalidRule(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules []Rule
+		err   error
+	}{
+		{name: "empty name", rules: []Rule{{Metric: "up"}}, err: ErrRuleNameEmpty},
+		{name: "empty metric", rules: []Rule{{Name: "down"}}, err: ErrRuleMetricEmpty},
+		{name: "duplicate", rules: []Rule{{Name: "down", Metric: "up"}, {Name: "down", Metric: "up"}}, err: ErrDuplicateRule},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := New(prometheus.NewRegistry(), c.rules, nil); !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	lg "github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+)
+
+// PagerDutyURL is the url of pagerduty events api v2
+const PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Logger notify the alert to the logger, firing alert is logged as error and resolved alert as info
+func Logger(logger lg.Logger) Notifier {
+	return NotifierFunc(func(ctx context.Context, alert Alert) error {
+		if alert.Status == StatusFiring {
+			logger.Errorf("alert: %s is firing: %s, value %g is above %g", alert.Key(), alert.Message, alert.Value, alert.Limit)
+			return nil
+		}
+		logger.Infof("alert: %s is resolved: %s, value %g", alert.Key(), alert.Message, alert.Value)
+		return nil
+	})
+}
+
+// Webhook post the alert as json to the url, http.DefaultClient is used when client is nil
+func Webhook(url string, client *http.Client) Notifier {
+	return NotifierFunc(func(ctx context.Context, alert Alert) error {
+		return postJSON(ctx, client, url, alert)
+	})
+}
+
+// PagerDutyOptions of pagerduty notifier
+type PagerDutyOptions struct {
+	// URL of events api, default to PagerDutyURL
+	URL string
+	// Source of the event, for example the host name, default to go-project-example
+	Source string
+	// Severity of the event, critical|error|warning|info, default to error
+	Severity string
+	Client   *http.Client
+}
+
+// pagerDutyEvent of events api v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// PagerDuty trigger pagerduty incident of firing alert and resolve it when the alert is resolved
+// the incident is deduplicated by the alert key
+func PagerDuty(routingKey string, options *PagerDutyOptions) Notifier {
+	opts := PagerDutyOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.URL == "" {
+		opts.URL = PagerDutyURL
+	}
+	if opts.Source == "" {
+		opts.Source = "go-project-example"
+	}
+	if opts.Severity == "" {
+		opts.Severity = "error"
+	}
+
+	return NotifierFunc(func(ctx context.Context, alert Alert) error {
+		event := pagerDutyEvent{
+			RoutingKey:  routingKey,
+			EventAction: "resolve",
+			DedupKey:    alert.Key(),
+		}
+		if alert.Status == StatusFiring {
+			event.EventAction = "trigger"
+			event.Payload = &pagerDutyPayload{
+				Summary:       fmt.Sprintf("%s: %s, value %g is above %g", alert.Rule, alert.Message, alert.Value, alert.Limit),
+				Source:        opts.Source,
+				Severity:      opts.Severity,
+				Timestamp:     alert.At.Format("2006-01-02T15:04:05.000Z07:00"),
+				CustomDetails: alert.Labels,
+			}
+		}
+		return postJSON(ctx, opts.Client, opts.URL, event)
+	})
+}
+
+// postJSON post the body as json, error is returned when the response is not 2xx
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(out))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alert: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
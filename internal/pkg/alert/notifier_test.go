@@ -0,0 +1,70 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPagerDuty(t *testing.T) {
+	var events []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := make(map[string]interface{})
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	notifier := PagerDuty("routing-key", &PagerDutyOptions{URL: srv.URL, Source: "api-1"})
+	alert := Alert{
+		Rule:   "replication_lag",
+		Status: StatusFiring,
+		Labels: map[string]string{"name": "users"},
+		Value:  12,
+		Limit:  5,
+		At:     time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC),
+	}
+	if err := notifier.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	alert.Status = StatusResolved
+	if err := notifier.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expect 2 events but got %d", len(events))
+	}
+	if events[0]["event_action"] != "trigger" || events[1]["event_action"] != "resolve" {
+		t.Fatalf("unexpected event actions %v and %v", events[0]["event_action"], events[1]["event_action"])
+	}
+	// the incident is resolved by the same dedup key
+	if key := `replication_lag{name="users"}`; events[0]["dedup_key"] != key || events[1]["dedup_key"] != key {
+		t.Fatalf("expect dedup key %s but got %v and %v", key, events[0]["dedup_key"], events[1]["dedup_key"])
+	}
+	payload := events[0]["payload"].(map[string]interface{})
+	if payload["source"] != "api-1" || payload["severity"] != "error" {
+		t.Fatalf("unexpected payload %v", payload)
+	}
+	if _, ok := events[1]["payload"]; ok {
+		t.Fatal("expect no payload in resolve event")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	err := Webhook(srv.URL, nil).Notify(context.Background(), Alert{Rule: "saturated", Status: StatusFiring})
+	if err == nil {
+		t.Fatal("expect error when the webhook is unavailable")
+	}
+}
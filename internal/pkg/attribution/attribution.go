@@ -0,0 +1,93 @@
+// Package attribution carry the labels of the request to the resources, so the usage of resources can be attributed
+// to the endpoint, tenant and feature of the request, for example to attribute infrastructure cost per feature
+//
+// the labels is set to the context by the middleware, and read by the resources metrics from the context of the call
+//
+//	r.Use(attribution.Middleware(nil))
+//	chain := router.NewChainedMiddleware(r, attribution.Feature("checkout"))
+package attribution
+
+import (
+	"context"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// HeaderTenantID is the default header of the tenant
+const HeaderTenantID = "X-Tenant-Id"
+
+// Labels of the usage
+// keep the number of tenant and feature small, as every combination of labels is a new series of the metrics
+type Labels struct {
+	Endpoint string
+	Tenant   string
+	Feature  string
+}
+
+type labelsKey struct{}
+
+// WithLabels return context with the labels, empty label keep the label of the parent context
+func WithLabels(ctx context.Context, labels Labels) context.Context {
+	parent := FromContext(ctx)
+	if labels.Endpoint == "" {
+		labels.Endpoint = parent.Endpoint
+	}
+	if labels.Tenant == "" {
+		labels.Tenant = parent.Tenant
+	}
+	if labels.Feature == "" {
+		labels.Feature = parent.Feature
+	}
+	return context.WithValue(ctx, labelsKey{}, labels)
+}
+
+// FromContext return the labels of the context, the labels is empty when it is not set
+func FromContext(ctx context.Context) Labels {
+	labels, _ := ctx.Value(labelsKey{}).(Labels)
+	return labels
+}
+
+// Options of attribution middleware
+type Options struct {
+	// TenantHeader is the header of the tenant, default to X-Tenant-Id
+	TenantHeader string
+	// Feature return the feature of the request, the feature can also be set per route by Feature middleware
+	Feature func(rctx *requestcontext.RequestContext) string
+}
+
+// Middleware set the labels of the request to the request context
+// the endpoint is the handler name of the request, and the tenant is taken from the tenant header
+func Middleware(options *Options) router.MiddlewareFunc {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.TenantHeader == "" {
+		opts.TenantHeader = HeaderTenantID
+	}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			labels := Labels{
+				Endpoint: rctx.RequestHandler(),
+				Tenant:   rctx.RequestHeader().Get(opts.TenantHeader),
+			}
+			if opts.Feature != nil {
+				labels.Feature = opts.Feature(rctx)
+			}
+			rctx.SetContext(WithLabels(rctx.Context(), labels))
+			return next(rctx)
+		}
+	}
+}
+
+// Feature set the feature label of the request, for example in chained middlewares of the feature routes
+func Feature(feature string) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			rctx.SetContext(WithLabels(rctx.Context(), Labels{Feature: feature}))
+			return next(rctx)
+		}
+	}
+}
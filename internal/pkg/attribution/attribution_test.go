@@ -0,0 +1,74 @@
+package attribution
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+)
+
+func TestWithLabels(t *testing.T) {
+	ctx := WithLabels(context.Background(), Labels{Endpoint: "/v1/booking", Tenant: "acme"})
+	ctx = WithLabels(ctx, Labels{Feature: "checkout"})
+
+	expect := Labels{Endpoint: "/v1/booking", Tenant: "acme", Feature: "checkout"}
+	if got := FromContext(ctx); got != expect {
+		t.Fatalf("expect labels %+v but got %+v", expect, got)
+	}
+	if got := FromContext(context.Background()); got != (Labels{}) {
+		t.Fatalf("expect empty labels but got %+v", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct {
+		name    string
+		options *Options
+		header  string
+		expect  Labels
+	}{
+		{
+			name:   "default tenant header",
+			header: HeaderTenantID,
+			expect: Labels{Endpoint: "/v1/booking", Tenant: "acme", Feature: "checkout"},
+		},
+		{
+			name: "custom tenant header and feature",
+			options: &Options{
+				TenantHeader: "X-Org",
+				Feature: func(rctx *requestcontext.RequestContext) string {
+					return "booking"
+				},
+			},
+			header: "X-Org",
+			// the feature of the route override the feature of the middleware
+			expect: Labels{Endpoint: "/v1/booking", Tenant: "acme", Feature: "checkout"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/booking", nil)
+			req.Header.Set(c.header, "acme")
+			rctx := requestcontext.New(requestcontext.Constructor{
+				HTTPResponseWriter: httptest.NewRecorder(),
+				HTTPRequest:        req,
+				Path:               "/v1/booking",
+			})
+
+			var labels Labels
+			handler := Middleware(c.options)(Feature("checkout")(func(rctx *requestcontext.RequestContext) error {
+				labels = FromContext(rctx.Context())
+				return nil
+			}))
+			if err := handler(rctx); err != nil {
+				t.Fatal(err)
+			}
+			if labels != c.expect {
+				t.Fatalf("expect labels %+v but got %+v", c.expect, labels)
+			}
+		})
+	}
+}
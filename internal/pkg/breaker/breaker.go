@@ -0,0 +1,234 @@
+// Package breaker provide a circuit breaker, so call to a dependency which is down fail fast instead of waiting for timeout
+// the circuit is opened after consecutive failures, and closed again when the probes in half-open state succeed
+//
+//	b := breaker.New(nil)
+//	done, err := b.Allow()
+//	if err != nil {
+//		return err
+//	}
+//	err = call()
+//	done(err)
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// default value for options
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = time.Second * 10
+	DefaultHalfOpenProbes   = 1
+)
+
+// list of error
+var (
+	ErrOpen = errors.New("breaker: circuit is open")
+)
+
+// State of circuit
+type State int
+
+// list of state
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "unknown"
+}
+
+// Options of breaker
+type Options struct {
+	// FailureThreshold is the number of consecutive failures to open the circuit, default to 5
+	FailureThreshold int
+	// OpenTimeout is the wait time in open state before probes are allowed, default to 10s
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of concurrent calls allowed in half-open state, default to 1
+	// the circuit is closed when all probes succeed, and opened again when one of them fail
+	HalfOpenProbes int
+	// IsFailure decide whether the error is counted as failure, all errors are failure when nil
+	// error which is not failure is counted as success
+	IsFailure func(err error) bool
+	// OnStateChange is invoked after the state is changed
+	OnStateChange func(from, to State)
+	// Now return current time, default to time.Now
+	Now func() time.Time
+}
+
+// Breaker is a circuit breaker, it is safe for concurrent use
+type Breaker struct {
+	opts Options
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// probes is the number of running calls in half-open state, successes is the number of succeeded probes
+	probes    int
+	successes int
+	// generation is increased on every state change, so result of call from previous state is ignored
+	generation uint64
+}
+
+// New circuit breaker in closed state
+func New(options *Options) *Breaker {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = DefaultOpenTimeout
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = DefaultHalfOpenProbes
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	b := Breaker{
+		opts: opts,
+	}
+	return &b
+}
+
+// State of the circuit, open circuit is half-open after OpenTimeout
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	from, to, changed := b.refresh()
+	state := b.state
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(from, to)
+	}
+	return state
+}
+
+// Allow return ErrOpen when the call is not allowed, otherwise the result of the call must be reported with done
+// context.Canceled is not counted as success or failure
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	from, to, changed := b.refresh()
+	switch {
+	case b.state == StateOpen:
+		err = ErrOpen
+	case b.state == StateHalfOpen && b.probes >= b.opts.HalfOpenProbes:
+		err = ErrOpen
+	case b.state == StateHalfOpen:
+		b.probes++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	done = func(err error) {
+		once.Do(func() {
+			b.report(generation, err)
+		})
+	}
+	return done, nil
+}
+
+// Do call fn when the circuit allow it, and report the result of fn
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(err)
+	return err
+}
+
+// report the result of a call allowed in the generation
+func (b *Breaker) report(generation uint64, err error) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	var (
+		from    = b.state
+		changed bool
+	)
+	if b.state == StateHalfOpen {
+		b.probes--
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil && (b.opts.IsFailure == nil || b.opts.IsFailure(err)):
+		b.failures++
+		if b.state == StateHalfOpen || b.failures >= b.opts.FailureThreshold {
+			b.setState(StateOpen)
+			changed = true
+		}
+	default:
+		b.failures = 0
+		if b.state == StateHalfOpen {
+			b.successes++
+			if b.successes >= b.opts.HalfOpenProbes {
+				b.setState(StateClosed)
+				changed = true
+			}
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(from, to)
+	}
+}
+
+// refresh move open circuit to half-open after OpenTimeout, must be called with lock held
+func (b *Breaker) refresh() (from, to State, changed bool) {
+	if b.state != StateOpen || b.opts.Now().Sub(b.openedAt) < b.opts.OpenTimeout {
+		return b.state, b.state, false
+	}
+	b.setState(StateHalfOpen)
+	return StateOpen, StateHalfOpen, true
+}
+
+// setState reset the counters of the state, must be called with lock held
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	b.generation++
+	if state == StateOpen {
+		b.openedAt = b.opts.Now()
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,133 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestBreaker(t *testing.T) {
+	errFailed := errors.New("failed")
+	errIgnored := errors.New("ignored")
+
+	c := &clock{now: time.Now()}
+	var changes []string
+	b := New(&Options{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Second,
+		IsFailure: func(err error) bool {
+			return err != errIgnored
+		},
+		OnStateChange: func(from, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+		Now: c.Now,
+	})
+
+	fail := func() error {
+		return b.Do(func() error { return errFailed })
+	}
+
+	// error which is not failure reset the consecutive failures
+	fail()
+	b.Do(func() error { return errIgnored })
+	fail()
+	if state := b.State(); state != StateClosed {
+		t.Fatalf("expect closed but got %s", state)
+	}
+
+	fail()
+	if state := b.State(); state != StateOpen {
+		t.Fatalf("expect open but got %s", state)
+	}
+	if err := b.Do(func() error { return nil }); err != ErrOpen {
+		t.Fatalf("expect ErrOpen but got %v", err)
+	}
+
+	// only one probe is allowed in half-open
+	c.Add(time.Second)
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); err != ErrOpen {
+		t.Fatalf("expect ErrOpen for second probe but got %v", err)
+	}
+	done(errFailed)
+	if state := b.State(); state != StateOpen {
+		t.Fatalf("expect open after failed probe but got %s", state)
+	}
+
+	c.Add(time.Second)
+	done, err = b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(nil)
+	if state := b.State(); state != StateClosed {
+		t.Fatalf("expect closed after probe succeed but got %s", state)
+	}
+
+	expect := []string{"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed"}
+	if len(changes) != len(expect) {
+		t.Fatalf("expect changes %v but got %v", expect, changes)
+	}
+	for idx := range expect {
+		if changes[idx] != expect[idx] {
+			t.Fatalf("expect changes %v but got %v", expect, changes)
+		}
+	}
+}
+
+func TestBreakerIgnoreStaleResult(t *testing.T) {
+	c := &clock{now: time.Now()}
+	b := New(&Options{FailureThreshold: 1, OpenTimeout: time.Second, Now: c.Now})
+
+	slow, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Do(func() error { return errors.New("failed") })
+	c.Add(time.Second)
+	if state := b.State(); state != StateHalfOpen {
+		t.Fatalf("expect half-open but got %s", state)
+	}
+
+	// result of the call allowed before the circuit is opened doesn't close the circuit
+	slow(nil)
+	if state := b.State(); state != StateHalfOpen {
+		t.Fatalf("expect half-open but got %s", state)
+	}
+}
+
+func TestBreakerCanceledProbe(t *testing.T) {
+	c := &clock{now: time.Now()}
+	b := New(&Options{FailureThreshold: 1, OpenTimeout: time.Second, Now: c.Now})
+	b.Do(func() error { return errors.New("failed") })
+	c.Add(time.Second)
+
+	// canceled probe release the probe without closing the circuit
+	b.Do(func() error { return context.Canceled })
+	if state := b.State(); state != StateHalfOpen {
+		t.Fatalf("expect half-open but got %s", state)
+	}
+	if err := b.Do(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if state := b.State(); state != StateClosed {
+		t.Fatalf("expect closed but got %s", state)
+	}
+}
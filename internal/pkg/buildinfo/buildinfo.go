@@ -0,0 +1,81 @@
+// Package buildinfo provide information of the running binary
+// version, commit and build date are set when building the binary via ldflags, for example:
+//
+//	go build -ldflags "-X github.com/albertwidi/go-project-example/internal/pkg/buildinfo.Version=v1.0.0"
+package buildinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// set via ldflags
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+var (
+	mu         sync.RWMutex
+	subsystems = make(map[string]struct{})
+)
+
+// Info of the build
+type Info struct {
+	Version    string   `json:"version"`
+	Commit     string   `json:"commit"`
+	BuildDate  string   `json:"build_date"`
+	GoVersion  string   `json:"go_version"`
+	Compiler   string   `json:"compiler"`
+	Platform   string   `json:"platform"`
+	Subsystems []string `json:"subsystems"`
+}
+
+// String return the info in one line, used as startup banner
+func (i Info) String() string {
+	return fmt.Sprintf("version: %s, commit: %s, build_date: %s, go: %s %s, subsystems: %v",
+		i.Version, i.Commit, i.BuildDate, i.GoVersion, i.Platform, i.Subsystems)
+}
+
+// EnableSubsystem mark subsystems as enabled in the running binary
+func EnableSubsystem(names ...string) {
+	mu.Lock()
+	for _, name := range names {
+		subsystems[name] = struct{}{}
+	}
+	mu.Unlock()
+}
+
+// Get the build info
+func Get() Info {
+	mu.RLock()
+	enabled := make([]string, 0, len(subsystems))
+	for name := range subsystems {
+		enabled = append(enabled, name)
+	}
+	mu.RUnlock()
+	sort.Strings(enabled)
+
+	return Info{
+		Version:    Version,
+		Commit:     Commit,
+		BuildDate:  BuildDate,
+		GoVersion:  runtime.Version(),
+		Compiler:   runtime.Compiler,
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Subsystems: enabled,
+	}
+}
+
+// Handler return http handler to expose the build info in json
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Get())
+	})
+}
@@ -0,0 +1,28 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	EnableSubsystem("redis", "sqldb")
+	EnableSubsystem("redis")
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/buildinfo", nil))
+
+	info := Info{}
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("expecting go version %s but got %s", runtime.Version(), info.GoVersion)
+	}
+	if !reflect.DeepEqual(info.Subsystems, []string{"redis", "sqldb"}) {
+		t.Errorf("unexpected subsystems %v", info.Subsystems)
+	}
+}
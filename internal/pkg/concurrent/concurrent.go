@@ -0,0 +1,191 @@
+// Package concurrent provide helpers for bounded concurrency
+// use this package instead of rolling semaphore code in each subsystem
+package concurrent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// Group is a collection of goroutines working on subtasks of the same task
+// with limited number of goroutines running at the same time
+// the first error returned by a goroutine cancel the group context
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	name   string
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup create a new group with the limit of concurrent goroutines
+// limit <= 0 means no limit
+func NewGroup(ctx context.Context, name string, limit int) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := Group{
+		ctx:    ctx,
+		cancel: cancel,
+		name:   name,
+	}
+	if limit > 0 {
+		g.sem = make(chan struct{}, limit)
+	}
+	return &g, ctx
+}
+
+// Go run fn in a new goroutine, Go block when the number of running goroutines reach the limit
+// fn is not invoked when the group context is already canceled
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.setErr(g.ctx.Err())
+			return
+		}
+	}
+
+	g.wg.Add(1)
+	safego.Go(g.ctx, g.name, func(ctx context.Context) error {
+		defer func() {
+			if g.sem != nil {
+				<-g.sem
+			}
+			g.wg.Done()
+		}()
+
+		if err := ctx.Err(); err != nil {
+			g.setErr(err)
+			return err
+		}
+		// use safego.Run to catch panic of fn as error of the group
+		err := safego.Run(ctx, g.name, fn)
+		if err != nil {
+			g.setErr(err)
+		}
+		return err
+	})
+}
+
+// Wait for all goroutines to finish and return the first error
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+func (g *Group) setErr(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// Map invoke fn for each index from 0 to n-1 with at most limit goroutines
+// Map return the first error and stop invoking fn for the rest of the index
+func Map(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	g, ctx := NewGroup(ctx, "concurrent/map", limit)
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		idx := i
+		g.Go(func(ctx context.Context) error {
+			return fn(ctx, idx)
+		})
+	}
+	return g.Wait()
+}
+
+// Stage of a pipeline, transform one item into another item
+// return false to drop the item from the pipeline
+type Stage struct {
+	Name string
+	// Workers is the number of goroutines for this stage, default to 1
+	Workers int
+	// Buffer is the size of output channel of this stage
+	// a full buffer blocks the stage, which give backpressure to previous stages
+	Buffer int
+	Fn     func(ctx context.Context, item interface{}) (interface{}, bool, error)
+}
+
+// Pipeline connect source to stages and return the output channel of the last stage
+// the output channel is closed when all items are processed or an error happened
+// the error channel receive the first error of the pipeline when the output channel is closed
+func Pipeline(ctx context.Context, source <-chan interface{}, stages ...Stage) (<-chan interface{}, <-chan error) {
+	g, ctx := NewGroup(ctx, "concurrent/pipeline", 0)
+	in := source
+
+	for _, s := range stages {
+		stage := s
+		if stage.Workers <= 0 {
+			stage.Workers = 1
+		}
+		out := make(chan interface{}, stage.Buffer)
+
+		var stageWg sync.WaitGroup
+		stageWg.Add(stage.Workers)
+		for i := 0; i < stage.Workers; i++ {
+			input := in
+			g.Go(func(ctx context.Context) error {
+				defer stageWg.Done()
+				return runStage(ctx, stage, input, out)
+			})
+		}
+		// close the output of the stage when all workers done
+		safego.Go(ctx, "concurrent/pipeline/"+stage.Name, func(ctx context.Context) error {
+			stageWg.Wait()
+			close(out)
+			return nil
+		})
+		in = out
+	}
+
+	errChan := make(chan error, 1)
+	result := make(chan interface{})
+	safego.Go(ctx, "concurrent/pipeline/result", func(ctx context.Context) error {
+		defer close(errChan)
+		defer close(result)
+		for item := range in {
+			select {
+			case result <- item:
+			case <-ctx.Done():
+			}
+		}
+		if err := g.Wait(); err != nil {
+			errChan <- err
+		}
+		return nil
+	})
+	return result, errChan
+}
+
+func runStage(ctx context.Context, stage Stage, in <-chan interface{}, out chan<- interface{}) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case item, ok := <-in:
+			if !ok {
+				return nil
+			}
+			res, ok, err := stage.Fn(ctx, item)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package concurrent_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+)
+
+func TestMap(t *testing.T) {
+	var (
+		running    int32
+		maxRunning int32
+		results    = make([]int, 100)
+	)
+
+	err := concurrent.Map(context.Background(), len(results), 5, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		results[i] = i * 2
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 5 {
+		t.Errorf("expecting at most 5 goroutines but got %d", maxRunning)
+	}
+	for i, r := range results {
+		if r != i*2 {
+			t.Fatalf("expecting %d but got %d", i*2, r)
+		}
+	}
+}
+
+func TestMapError(t *testing.T) {
+	errTest := errors.New("test error")
+	err := concurrent.Map(context.Background(), 10, 2, func(ctx context.Context, i int) error {
+		if i == 3 {
+			return errTest
+		}
+		return nil
+	})
+	if !errors.Is(err, errTest) {
+		t.Errorf("expecting test error but got %v", err)
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	g, _ := concurrent.NewGroup(context.Background(), "test", 1)
+	g.Go(func(ctx context.Context) error {
+		panic("oops")
+	})
+	if err := g.Wait(); err == nil {
+		t.Error("expecting error from panic")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	source := make(chan interface{})
+	go func() {
+		defer close(source)
+		for i := 1; i <= 10; i++ {
+			source <- i
+		}
+	}()
+
+	out, errChan := concurrent.Pipeline(context.Background(), source,
+		concurrent.Stage{
+			Name:    "double",
+			Workers: 3,
+			Fn: func(ctx context.Context, item interface{}) (interface{}, bool, error) {
+				return item.(int) * 2, true, nil
+			},
+		},
+		concurrent.Stage{
+			Name: "filter",
+			Fn: func(ctx context.Context, item interface{}) (interface{}, bool, error) {
+				return item, item.(int)%4 == 0, nil
+			},
+		},
+	)
+
+	sum := 0
+	for item := range out {
+		sum += item.(int)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	// 4 + 8 + 12 + 16 + 20
+	if sum != 60 {
+		t.Errorf("expecting 60 but got %d", sum)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	errTest := errors.New("test error")
+	source := make(chan interface{}, 3)
+	source <- 1
+	source <- 2
+	source <- 3
+	close(source)
+
+	out, errChan := concurrent.Pipeline(context.Background(), source, concurrent.Stage{
+		Name: "fail",
+		Fn: func(ctx context.Context, item interface{}) (interface{}, bool, error) {
+			if item.(int) == 2 {
+				return nil, false, errTest
+			}
+			return item, true, nil
+		},
+	})
+	for range out {
+	}
+	if err := <-errChan; !errors.Is(err, errTest) {
+		t.Errorf("expecting test error but got %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+// Package consent track the versioned policies, for example the terms of service and the privacy policy,
+// and the acceptance of every subject in sqldb, so a route can require the subject to accept the current version
+// every acceptance and withdrawal is appended as a record with the time, ip and user agent for the compliance reports
+//
+//	tracker, err := consent.New(db, nil)
+//	err = tracker.Init(ctx)
+//	policy, err := tracker.Publish(ctx, consent.Policy{Name: "terms", URL: "https://example.com/terms/v2"})
+//	err = tracker.Accept(ctx, consent.Acceptance{Subject: "user-1", Policy: "terms", Version: policy.Version, IP: ip, UserAgent: ua})
+//	chain := router.NewChainedMiddleware(r, consent.Require(tracker, &consent.MiddlewareOptions{Subject: userID}, "terms"))
+package consent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// default value of options
+const (
+	DefaultPolicyTable = "consent_policies"
+	DefaultRecordTable = "consent_records"
+	DefaultCacheTTL    = time.Minute
+)
+
+// list of record action
+const (
+	ActionAccept   = "accept"
+	ActionWithdraw = "withdraw"
+)
+
+// list of error
+var (
+	ErrDBNil            = errors.New("consent: db is nil")
+	ErrPolicyNameEmpty  = errors.New("consent: policy name is empty")
+	ErrPolicyNotFound   = errors.New("consent: policy is not found")
+	ErrSubjectEmpty     = errors.New("consent: subject is empty")
+	ErrVersionNotLatest = errors.New("consent: version is older than the current version of the policy")
+)
+
+// Options of tracker
+type Options struct {
+	// PolicyTable of the policy versions, default to consent_policies
+	PolicyTable string
+	// RecordTable of the acceptances and withdrawals, default to consent_records
+	RecordTable string
+	// CacheTTL of the current version of the policies, default to 1 minute
+	// the new version published by another instance is required after the cache is expired
+	CacheTTL time.Duration
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// Policy version
+type Policy struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	// URL of the document of the version
+	URL string `json:"url"`
+	// EffectiveAt is the time the version become the current version, default to the time it is published
+	EffectiveAt time.Time `json:"effective_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Acceptance of a policy version by the subject
+type Acceptance struct {
+	Subject string
+	Policy  string
+	// Version of the policy, the current version is accepted when zero
+	Version   int
+	IP        string
+	UserAgent string
+}
+
+// Record of an acceptance or a withdrawal
+type Record struct {
+	Subject   string    `json:"subject"`
+	Policy    string    `json:"policy"`
+	Version   int       `json:"version"`
+	Action    string    `json:"action"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// cached current version of a policy
+type cachedPolicy struct {
+	policy    Policy
+	expiresAt time.Time
+}
+
+// Tracker of the policies and the consent of the subjects
+type Tracker struct {
+	db   *sqldb.DB
+	opts Options
+
+	mu    sync.Mutex
+	cache map[string]cachedPolicy
+}
+
+// New tracker of the consent in the database
+func New(db *sqldb.DB, options *Options) (*Tracker, error) {
+	if db == nil {
+		return nil, ErrDBNil
+	}
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.PolicyTable == "" {
+		opts.PolicyTable = DefaultPolicyTable
+	}
+	if opts.RecordTable == "" {
+		opts.RecordTable = DefaultRecordTable
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	t := Tracker{
+		db:    db,
+		opts:  opts,
+		cache: make(map[string]cachedPolicy),
+	}
+	return &t, nil
+}
+
+// Init create the policy and the record table when they don't exist
+func (t *Tracker) Init(ctx context.Context) error {
+	queries := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	name VARCHAR(255) NOT NULL,
+	version INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	effective_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (name, version)
+)`, t.opts.PolicyTable),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	subject VARCHAR(255) NOT NULL,
+	policy VARCHAR(255) NOT NULL,
+	version INTEGER NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	ip VARCHAR(64) NOT NULL,
+	user_agent TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`, t.opts.RecordTable),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_subject ON %s (subject, policy, created_at)", t.opts.RecordTable, t.opts.RecordTable),
+	}
+	for _, query := range queries {
+		if _, err := t.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("consent: failed to create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Publish a new version of the policy, the version is the next number after the latest version
+// the subjects must accept the new version after it is effective, the version is effective immediately when EffectiveAt is zero
+func (t *Tracker) Publish(ctx context.Context, policy Policy) (Policy, error) {
+	if policy.Name == "" {
+		return Policy{}, ErrPolicyNameEmpty
+	}
+	now := t.opts.Now().UTC()
+	policy.CreatedAt = now
+	if policy.EffectiveAt.IsZero() {
+		policy.EffectiveAt = now
+	}
+	policy.EffectiveAt = policy.EffectiveAt.UTC()
+
+	err := t.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		var latest sql.NullInt64
+		query := tx.Rebind(fmt.Sprintf("SELECT MAX(version) FROM %s WHERE name = ?", t.opts.PolicyTable))
+		if err := tx.QueryRowContext(ctx, query, policy.Name).Scan(&latest); err != nil {
+			return fmt.Errorf("consent: failed to load the version of %s: %w", policy.Name, err)
+		}
+		policy.Version = int(latest.Int64) + 1
+		query = tx.Rebind(fmt.Sprintf("INSERT INTO %s (name, version, url, effective_at, created_at) VALUES (?, ?, ?, ?, ?)", t.opts.PolicyTable))
+		if _, err := tx.ExecContext(ctx, query, policy.Name, policy.Version, policy.URL, policy.EffectiveAt, policy.CreatedAt); err != nil {
+			return fmt.Errorf("consent: failed to publish %s version %d: %w", policy.Name, policy.Version, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Policy{}, err
+	}
+	t.forget(policy.Name)
+	return policy, nil
+}
+
+// Current return the current version of the policy, it is the latest version which is effective
+func (t *Tracker) Current(ctx context.Context, name string) (Policy, error) {
+	if name == "" {
+		return Policy{}, ErrPolicyNameEmpty
+	}
+	now := t.opts.Now()
+	t.mu.Lock()
+	c, ok := t.cache[name]
+	t.mu.Unlock()
+	if ok && now.Before(c.expiresAt) && !now.Before(c.policy.EffectiveAt) {
+		return c.policy, nil
+	}
+
+	policy := Policy{Name: name}
+	query := t.db.Rebind(fmt.Sprintf("SELECT version, url, effective_at, created_at FROM %s WHERE name = ? AND effective_at <= ? ORDER BY version DESC LIMIT 1", t.opts.PolicyTable))
+	err := t.db.QueryRowContext(ctx, query, name, now.UTC()).Scan(&policy.Version, &policy.URL, &policy.EffectiveAt, &policy.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Policy{}, fmt.Errorf("%w: %s", ErrPolicyNotFound, name)
+	}
+	if err != nil {
+		return Policy{}, fmt.Errorf("consent: failed to load the current version of %s: %w", name, err)
+	}
+	expiresAt := now.Add(t.opts.CacheTTL)
+	// the cached version is loaded again when the next version become effective
+	next, err := t.nextEffectiveAt(ctx, name, now)
+	if err != nil {
+		return Policy{}, err
+	}
+	if !next.IsZero() && next.Before(expiresAt) {
+		expiresAt = next
+	}
+	t.mu.Lock()
+	t.cache[name] = cachedPolicy{policy: policy, expiresAt: expiresAt}
+	t.mu.Unlock()
+	return policy, nil
+}
+
+// nextEffectiveAt return the effective time of the version which is published but not effective yet
+func (t *Tracker) nextEffectiveAt(ctx context.Context, name string, now time.Time) (time.Time, error) {
+	var next sql.NullTime
+	query := t.db.Rebind(fmt.Sprintf("SELECT effective_at FROM %s WHERE name = ? AND effective_at > ? ORDER BY effective_at LIMIT 1", t.opts.PolicyTable))
+	err := t.db.QueryRowContext(ctx, query, name, now.UTC()).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("consent: failed to load the next version of %s: %w", name, err)
+	}
+	return next.Time, nil
+}
+
+// Versions return all versions of the policy, the oldest first
+func (t *Tracker) Versions(ctx context.Context, name string) ([]Policy, error) {
+	query := t.db.Rebind(fmt.Sprintf("SELECT name, version, url, effective_at, created_at FROM %s WHERE name = ? ORDER BY version", t.opts.PolicyTable))
+	rows, err := t.db.QueryContext(ctx, query, name)
+	if err != nil {
+		return nil, fmt.Errorf("consent: failed to load the versions of %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	var policies []Policy
+	for rows.Next() {
+		policy := Policy{}
+		if err := rows.Scan(&policy.Name, &policy.Version, &policy.URL, &policy.EffectiveAt, &policy.CreatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// Accept record the acceptance of the policy version by the subject
+// the version must exist and must not be older than the current version, so an outdated form cannot accept the old terms
+func (t *Tracker) Accept(ctx context.Context, acceptance Acceptance) error {
+	if acceptance.Subject == "" {
+		return ErrSubjectEmpty
+	}
+	current, err := t.Current(ctx, acceptance.Policy)
+	if err != nil {
+		return err
+	}
+	if acceptance.Version == 0 {
+		acceptance.Version = current.Version
+	}
+	if acceptance.Version < current.Version {
+		return fmt.Errorf("%w: %s version %d", ErrVersionNotLatest, acceptance.Policy, acceptance.Version)
+	}
+	if acceptance.Version > current.Version {
+		// the version which is not effective yet can be accepted in advance
+		var exists int
+		query := t.db.Rebind(fmt.Sprintf("SELECT 1 FROM %s WHERE name = ? AND version = ?", t.opts.PolicyTable))
+		err := t.db.QueryRowContext(ctx, query, acceptance.Policy, acceptance.Version).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s version %d", ErrPolicyNotFound, acceptance.Policy, acceptance.Version)
+		}
+		if err != nil {
+			return fmt.Errorf("consent: failed to load %s version %d: %w", acceptance.Policy, acceptance.Version, err)
+		}
+	}
+	return t.record(ctx, Record{
+		Subject:   acceptance.Subject,
+		Policy:    acceptance.Policy,
+		Version:   acceptance.Version,
+		Action:    ActionAccept,
+		IP:        acceptance.IP,
+		UserAgent: acceptance.UserAgent,
+	})
+}
+
+// Withdraw the consent of the subject to the policy, the subject must accept the current version again
+func (t *Tracker) Withdraw(ctx context.Context, subject, policy, ip, userAgent string) error {
+	if subject == "" {
+		return ErrSubjectEmpty
+	}
+	latest, ok, err := t.Latest(ctx, subject, policy)
+	if err != nil {
+		return err
+	}
+	// nothing to withdraw when the subject never accept the policy
+	if !ok || latest.Action == ActionWithdraw {
+		return nil
+	}
+	return t.record(ctx, Record{
+		Subject:   subject,
+		Policy:    policy,
+		Version:   latest.Version,
+		Action:    ActionWithdraw,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+}
+
+// Latest return the latest record of the subject for the policy, false is returned when the subject has no record
+func (t *Tracker) Latest(ctx context.Context, subject, policy string) (Record, bool, error) {
+	record := Record{}
+	query := t.db.Rebind(fmt.Sprintf("SELECT subject, policy, version, action, ip, user_agent, created_at FROM %s WHERE subject = ? AND policy = ? ORDER BY created_at DESC LIMIT 1", t.opts.RecordTable))
+	// the record is read from the leader, so the route doesn't reject the subject which just accept the policy
+	err := t.db.QueryRowContext(sqldb.ForceLeader(ctx), query, subject, policy).Scan(
+		&record.Subject, &record.Policy, &record.Version, &record.Action, &record.IP, &record.UserAgent, &record.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("consent: failed to load the consent of %s to %s: %w", subject, policy, err)
+	}
+	return record, true, nil
+}
+
+// Outdated return the current version of the policies which the subject has not accepted or has withdrawn
+func (t *Tracker) Outdated(ctx context.Context, subject string, policies ...string) ([]Policy, error) {
+	if subject == "" {
+		return nil, ErrSubjectEmpty
+	}
+	var outdated []Policy
+	for _, name := range policies {
+		current, err := t.Current(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		latest, ok, err := t.Latest(ctx, subject, name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || latest.Action != ActionAccept || latest.Version < current.Version {
+			outdated = append(outdated, current)
+		}
+	}
+	return outdated, nil
+}
+
+// record append the record of the consent
+func (t *Tracker) record(ctx context.Context, record Record) error {
+	record.CreatedAt = t.opts.Now().UTC()
+	query := t.db.Rebind(fmt.Sprintf("INSERT INTO %s (subject, policy, version, action, ip, user_agent, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)", t.opts.RecordTable))
+	_, err := t.db.ExecContext(ctx, query, record.Subject, record.Policy, record.Version, record.Action, record.IP, record.UserAgent, record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("consent: failed to record %s of %s to %s: %w", record.Action, record.Subject, record.Policy, err)
+	}
+	return nil
+}
+
+// forget the cached current version of the policy
+func (t *Tracker) forget(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.cache, name)
+}
@@ -0,0 +1,295 @@
+package consent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestTracker(t *testing.T) (*Tracker, *clock) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{now: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+	tracker, err := New(db, &Options{Now: c.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return tracker, c
+}
+
+func TestPublish(t *testing.T) {
+	tracker, c := newTestTracker(t)
+	ctx := context.Background()
+
+	if _, err := tracker.Current(ctx, "terms"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expecting error %v but got %v", ErrPolicyNotFound, err)
+	}
+	if _, err := tracker.Publish(ctx, Policy{}); !errors.Is(err, ErrPolicyNameEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrPolicyNameEmpty, err)
+	}
+	v1, err := tracker.Publish(ctx, Policy{Name: "terms", URL: "https://example.com/terms/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Version != 1 {
+		t.Fatalf("expecting version 1 but got %d", v1.Version)
+	}
+	// the next version is effective tomorrow
+	v2, err := tracker.Publish(ctx, Policy{Name: "terms", URL: "https://example.com/terms/v2", EffectiveAt: c.now.Add(time.Hour * 24)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Version != 2 {
+		t.Fatalf("expecting version 2 but got %d", v2.Version)
+	}
+
+	current, err := tracker.Current(ctx, "terms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != 1 || current.URL != v1.URL {
+		t.Fatalf("expecting version 1 is current but got %+v", current)
+	}
+	c.add(time.Hour * 25)
+	current, err = tracker.Current(ctx, "terms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != 2 {
+		t.Fatalf("expecting version 2 is current but got %+v", current)
+	}
+
+	versions, err := tracker.Versions(ctx, "terms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	tracker, c := newTestTracker(t)
+	ctx := context.Background()
+
+	if _, err := tracker.Publish(ctx, Policy{Name: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.Publish(ctx, Policy{Name: "privacy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	outdated, err := tracker.Outdated(ctx, "user-1", "terms", "privacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 2 {
+		t.Fatalf("expecting 2 outdated policies but got %+v", outdated)
+	}
+
+	c.add(time.Minute)
+	if err := tracker.Accept(ctx, Acceptance{Subject: "user-1", Policy: "terms", IP: "10.0.0.1", UserAgent: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	c.add(time.Minute)
+	if err := tracker.Accept(ctx, Acceptance{Subject: "user-1", Policy: "privacy", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Accept(ctx, Acceptance{Subject: "user-1", Policy: "terms", Version: 5}); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expecting error %v but got %v", ErrPolicyNotFound, err)
+	}
+	if err := tracker.Accept(ctx, Acceptance{Policy: "terms"}); !errors.Is(err, ErrSubjectEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrSubjectEmpty, err)
+	}
+	outdated, err = tracker.Outdated(ctx, "user-1", "terms", "privacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 0 {
+		t.Fatalf("expecting no outdated policy but got %+v", outdated)
+	}
+
+	// the new version must be accepted again
+	c.add(time.Minute)
+	if _, err := tracker.Publish(ctx, Policy{Name: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	outdated, err = tracker.Outdated(ctx, "user-1", "terms", "privacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 1 || outdated[0].Name != "terms" || outdated[0].Version != 2 {
+		t.Fatalf("expecting terms version 2 is outdated but got %+v", outdated)
+	}
+	if err := tracker.Accept(ctx, Acceptance{Subject: "user-1", Policy: "terms", Version: 1}); !errors.Is(err, ErrVersionNotLatest) {
+		t.Fatalf("expecting error %v but got %v", ErrVersionNotLatest, err)
+	}
+
+	// the withdrawn consent must be given again
+	c.add(time.Minute)
+	if err := tracker.Withdraw(ctx, "user-1", "privacy", "10.0.0.2", "test"); err != nil {
+		t.Fatal(err)
+	}
+	outdated, err = tracker.Outdated(ctx, "user-1", "privacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 1 {
+		t.Fatalf("expecting privacy is outdated after withdrawal but got %+v", outdated)
+	}
+
+	history, err := tracker.History(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expecting 3 records but got %+v", history)
+	}
+	if history[0].Action != ActionAccept || history[0].IP != "10.0.0.1" || !history[0].CreatedAt.Equal(time.Date(2020, 5, 1, 0, 1, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected record %+v", history[0])
+	}
+	if history[2].Action != ActionWithdraw || history[2].Policy != "privacy" || history[2].Version != 1 {
+		t.Fatalf("unexpected record %+v", history[2])
+	}
+}
+
+func TestReport(t *testing.T) {
+	tracker, c := newTestTracker(t)
+	ctx := context.Background()
+
+	if _, err := tracker.Publish(ctx, Policy{Name: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	start := c.now
+	for _, subject := range []string{"user-1", "user-2", "user-3"} {
+		c.add(time.Minute)
+		if err := tracker.Accept(ctx, Acceptance{Subject: subject, Policy: "terms"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.add(time.Minute)
+	if _, err := tracker.Publish(ctx, Policy{Name: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	c.add(time.Minute)
+	if err := tracker.Accept(ctx, Acceptance{Subject: "user-1", Policy: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	c.add(time.Minute)
+	if err := tracker.Withdraw(ctx, "user-2", "terms", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := tracker.Counts(ctx, "terms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []VersionCount{{Version: 1, Accepted: 3, Withdrawn: 1}, {Version: 2, Accepted: 1}}
+	if len(counts) != len(expect) || counts[0] != expect[0] || counts[1] != expect[1] {
+		t.Fatalf("expecting counts %+v but got %+v", expect, counts)
+	}
+
+	cases := []struct {
+		name   string
+		filter Filter
+		expect int
+	}{
+		{name: "all", filter: Filter{Policy: "terms"}, expect: 5},
+		{name: "version", filter: Filter{Policy: "terms", Version: 2}, expect: 1},
+		{name: "action", filter: Filter{Action: ActionWithdraw}, expect: 1},
+		{name: "range", filter: Filter{From: start.Add(time.Minute * 2), To: start.Add(time.Minute * 5)}, expect: 2},
+		{name: "limit", filter: Filter{Limit: 2}, expect: 2},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			records, err := tracker.Records(ctx, c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != c.expect {
+				t.Fatalf("expecting %d records but got %+v", c.expect, records)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tracker, _ := newTestTracker(t)
+	ctx := context.Background()
+
+	if _, err := tracker.Publish(ctx, Policy{Name: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	middleware := Require(tracker, &MiddlewareOptions{
+		Subject: func(rctx *requestcontext.RequestContext) string { return rctx.RequestHeader().Get("X-User-Id") },
+	}, "terms")
+	handler := middleware(func(rctx *requestcontext.RequestContext) error {
+		rctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return nil
+	})
+	serve := func(subject string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("X-User-Id", subject)
+		handler(requestcontext.New(requestcontext.Constructor{HTTPResponseWriter: rec, HTTPRequest: req}))
+		return rec
+	}
+
+	if rec := serve(""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expecting status %d but got %d", http.StatusUnauthorized, rec.Code)
+	}
+	rec := serve("user-1")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expecting status %d but got %d", http.StatusForbidden, rec.Code)
+	}
+	body := struct {
+		Data  []Policy `json:"data"`
+		Error struct {
+			Errors []string `json:"errors"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data) != 1 || body.Data[0].Name != "terms" || len(body.Error.Errors) != 1 || body.Error.Errors[0] != "terms:1" {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+
+	if err := tracker.Accept(ctx, Acceptance{Subject: "user-1", Policy: "terms"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve("user-1"); rec.Code != http.StatusOK {
+		t.Fatalf("expecting status %d but got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package consent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/xerrors"
+)
+
+// ErrConsentRequired is returned by the middleware when the subject has not accepted the current version of the policies
+var ErrConsentRequired = errors.New("consent: consent to the current version of the policies is required")
+
+// MiddlewareOptions of the consent middleware
+type MiddlewareOptions struct {
+	// Subject return the subject of the request, for example the authenticated user id
+	// the request without subject is rejected with unauthorized
+	Subject func(rctx *requestcontext.RequestContext) string
+	// OnError is called when the consent cannot be checked, the request is rejected with internal error
+	OnError func(err error)
+}
+
+// Require the subject to accept the current version of the policies before the route is handled
+// the request is rejected with forbidden and the outdated policies, so the client can show the policies to accept
+//
+//	chain := router.NewChainedMiddleware(r, consent.Require(tracker, &consent.MiddlewareOptions{Subject: userID}, "terms", "privacy"))
+func Require(tracker *Tracker, options *MiddlewareOptions, policies ...string) router.MiddlewareFunc {
+	const op xerrors.Op = "consent/require"
+
+	opts := MiddlewareOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Subject == nil {
+		opts.Subject = func(rctx *requestcontext.RequestContext) string { return "" }
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			subject := opts.Subject(rctx)
+			if subject == "" {
+				return writeError(rctx, http.StatusUnauthorized, xerrors.New(op, xerrors.KindUnauthorized, ErrSubjectEmpty), nil)
+			}
+			outdated, err := tracker.Outdated(rctx.Context(), subject, policies...)
+			if err != nil {
+				opts.OnError(err)
+				return writeError(rctx, http.StatusInternalServerError, xerrors.New(op, xerrors.KindInternalError, err), nil)
+			}
+			if len(outdated) > 0 {
+				return writeError(rctx, http.StatusForbidden, xerrors.New(op, xerrors.KindUnauthorized, ErrConsentRequired), outdated)
+			}
+			return next(rctx)
+		}
+	}
+}
+
+// writeError write the error response, the outdated policies are listed as name:version in the errors
+func writeError(rctx *requestcontext.RequestContext, status int, err error, outdated []Policy) error {
+	errs := make([]string, 0, len(outdated))
+	for _, policy := range outdated {
+		errs = append(errs, fmt.Sprintf("%s:%d", policy.Name, policy.Version))
+	}
+	_, werr := response.JSON(rctx.ResponseWriter()).WriteHeader(status).Data(outdated).Error(err, &response.JSONError{
+		Title:   "Consent Required",
+		Message: err.Error(),
+		Errors:  errs,
+	}).Write()
+	if werr != nil {
+		return werr
+	}
+	return err
+}
+
+// Client return the ip and the user agent of the request to record with the acceptance
+// the ip is the remote address, the ip of X-Forwarded-For must be taken by the caller when the proxy is trusted
+func Client(r *http.Request) (ip, userAgent string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ip, r.UserAgent()
+}
@@ -0,0 +1,124 @@
+package consent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter of the records in the compliance report, the empty fields are not filtered
+type Filter struct {
+	Subject string
+	Policy  string
+	Version int
+	Action  string
+	// From and To is the range of the record time, From is inclusive and To is exclusive
+	From time.Time
+	To   time.Time
+	// Limit the number of records, no limit when zero
+	Limit int
+}
+
+// VersionCount of the subjects which accepted or withdrew the version of the policy
+type VersionCount struct {
+	Version   int `json:"version"`
+	Accepted  int `json:"accepted"`
+	Withdrawn int `json:"withdrawn"`
+}
+
+// History return all records of the subject, the oldest first
+// for example to answer what the subject has agreed to and when
+func (t *Tracker) History(ctx context.Context, subject string) ([]Record, error) {
+	if subject == "" {
+		return nil, ErrSubjectEmpty
+	}
+	return t.Records(ctx, Filter{Subject: subject})
+}
+
+// Records return the records which match the filter, the oldest first
+func (t *Tracker) Records(ctx context.Context, filter Filter) ([]Record, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if filter.Subject != "" {
+		conds = append(conds, "subject = ?")
+		args = append(args, filter.Subject)
+	}
+	if filter.Policy != "" {
+		conds = append(conds, "policy = ?")
+		args = append(args, filter.Policy)
+	}
+	if filter.Version > 0 {
+		conds = append(conds, "version = ?")
+		args = append(args, filter.Version)
+	}
+	if filter.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	query := fmt.Sprintf("SELECT subject, policy, version, action, ip, user_agent, created_at FROM %s", t.opts.RecordTable)
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY created_at"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	rows, err := t.db.QueryContext(ctx, t.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("consent: failed to load records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		record := Record{}
+		if err := rows.Scan(&record.Subject, &record.Policy, &record.Version, &record.Action, &record.IP, &record.UserAgent, &record.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// Counts return the number of distinct subjects which accepted and withdrew every version of the policy, the oldest version first
+func (t *Tracker) Counts(ctx context.Context, policy string) ([]VersionCount, error) {
+	query := t.db.Rebind(fmt.Sprintf("SELECT version, action, COUNT(DISTINCT subject) FROM %s WHERE policy = ? GROUP BY version, action ORDER BY version", t.opts.RecordTable))
+	rows, err := t.db.QueryContext(ctx, query, policy)
+	if err != nil {
+		return nil, fmt.Errorf("consent: failed to count records of %s: %w", policy, err)
+	}
+	defer rows.Close()
+
+	var counts []VersionCount
+	for rows.Next() {
+		var (
+			version, count int
+			action         string
+		)
+		if err := rows.Scan(&version, &action, &count); err != nil {
+			return nil, err
+		}
+		if len(counts) == 0 || counts[len(counts)-1].Version != version {
+			counts = append(counts, VersionCount{Version: version})
+		}
+		switch action {
+		case ActionAccept:
+			counts[len(counts)-1].Accepted = count
+		case ActionWithdraw:
+			counts[len(counts)-1].Withdrawn = count
+		}
+	}
+	return counts, rows.Err()
+}
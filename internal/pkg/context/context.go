@@ -64,14 +64,25 @@ func (rc *RequestContext) Context() context.Context {
 	return rc.httpRequest.Context()
 }
 
+// SetContext replace the context of http.Request, for example to set the deadline of the request
+func (rc *RequestContext) SetContext(ctx context.Context) {
+	rc.httpRequest = rc.httpRequest.WithContext(ctx)
+}
+
 // ResponseWriter return http response writer from request context
 func (rc *RequestContext) ResponseWriter() http.ResponseWriter {
 	return rc.httpResponseWriter
 }
 
+// SetResponseWriter replace the http response writer, for example to capture the response in middleware
+func (rc *RequestContext) SetResponseWriter(w http.ResponseWriter) {
+	rc.httpResponseWriter = w
+}
+
 // JSON to create a json response via http response lib
+// conditional GET request is answered with 304 Not Modified when the response is not changed
 func (rc *RequestContext) JSON() *response.JSONResponse {
-	j := response.JSON(rc.httpResponseWriter)
+	j := response.JSON(rc.httpResponseWriter).Request(rc.httpRequest)
 	return j
 }
 
@@ -0,0 +1,72 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+// RedisMGet load string values of prefix+key with one MGET, empty value is not found
+func RedisMGet(r redis.Redis, prefix string) BatchFunc {
+	return func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		redisKeys := make([]string, len(keys))
+		for idx, key := range keys {
+			redisKeys[idx] = prefix + key
+		}
+		out, err := r.MGet(ctx, redisKeys...)
+		if err != nil && !r.IsErrNil(err) {
+			return nil, err
+		}
+
+		values := make(map[string]interface{}, len(keys))
+		for idx, value := range out {
+			if idx < len(keys) && value != "" {
+				values[keys[idx]] = value
+			}
+		}
+		return values, nil
+	}
+}
+
+// SQLIn load rows with one query, the query must have one IN (?) for the keys, for example
+//
+//	SELECT id, name FROM users WHERE id IN (?)
+//
+// every row is returned as map of column to value, keyed by the value of keyColumn
+// the query is routed to the follower like other read only query
+func SQLIn(db *sqldb.DB, query, keyColumn string) BatchFunc {
+	return func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		inQuery, args, err := sqlx.In(query, keys)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := db.QueryContext(ctx, db.Rebind(inQuery), args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		values := make(map[string]interface{}, len(keys))
+		for rows.Next() {
+			row := make(map[string]interface{})
+			if err := sqlx.MapScan(rows, row); err != nil {
+				return nil, err
+			}
+			for column, value := range row {
+				// mysql return text as bytes
+				if b, ok := value.([]byte); ok {
+					row[column] = string(b)
+				}
+			}
+			key, ok := row[keyColumn]
+			if !ok {
+				return nil, fmt.Errorf("dataloader: column %s is not in the result", keyColumn)
+			}
+			values[fmt.Sprint(key)] = row
+		}
+		return values, rows.Err()
+	}
+}
@@ -0,0 +1,220 @@
+// Package dataloader batch and cache lookups by key in one request
+// loads which are called at the same time, for example by graphql resolvers of a list, are sent to the batch function as one call
+//
+//	users := dataloader.New(dataloader.SQLIn(db, "SELECT * FROM users WHERE id IN (?)", "id"), nil)
+//	user, err := users.Load(ctx, "1")
+//
+// loader cache the result forever, so a new loader should be created for every request, see Middleware
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// list of error
+var (
+	ErrNotFound = errors.New("dataloader: key not found")
+)
+
+// BatchFunc load values of all keys, key which is not in the result is returned as ErrNotFound
+type BatchFunc func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// Options of loader
+type Options struct {
+	// Wait for more keys before the batch is loaded, default to 1 millisecond
+	Wait time.Duration
+	// MaxBatch is the maximum keys in one batch, the batch is loaded immediately when full, default to 100
+	MaxBatch int
+}
+
+// result of a key, done is closed when the result is ready
+type result struct {
+	value interface{}
+	err   error
+	done  chan struct{}
+}
+
+type batch struct {
+	keys    []string
+	results map[string]*result
+	timer   *time.Timer
+}
+
+// Loader of values by key
+type Loader struct {
+	fn   BatchFunc
+	opts Options
+
+	mu      sync.Mutex
+	cache   map[string]*result
+	pending *batch
+}
+
+// New loader
+func New(fn BatchFunc, options *Options) *Loader {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = time.Millisecond
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 100
+	}
+
+	l := Loader{
+		fn:    fn,
+		opts:  opts,
+		cache: make(map[string]*result),
+	}
+	return &l
+}
+
+// Load the value of the key, the key is loaded with other keys in the same batch
+func (l *Loader) Load(ctx context.Context, key string) (interface{}, error) {
+	res := l.enqueue(ctx, key)
+	select {
+	case <-res.done:
+		return res.value, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany load values of the keys, the values and errors are in the same order as keys
+func (l *Loader) LoadMany(ctx context.Context, keys []string) ([]interface{}, []error) {
+	results := make([]*result, len(keys))
+	for idx, key := range keys {
+		results[idx] = l.enqueue(ctx, key)
+	}
+	var (
+		values = make([]interface{}, len(keys))
+		errs   = make([]error, len(keys))
+	)
+	for idx, res := range results {
+		select {
+		case <-res.done:
+			values[idx], errs[idx] = res.value, res.err
+		case <-ctx.Done():
+			errs[idx] = ctx.Err()
+		}
+	}
+	return values, errs
+}
+
+// Prime the cache with the value of the key, the value is not changed when the key is already loaded
+func (l *Loader) Prime(key string, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	res := &result{value: value, done: make(chan struct{})}
+	close(res.done)
+	l.cache[key] = res
+}
+
+// Clear the cache of the key, for example after the value is changed
+func (l *Loader) Clear(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, key)
+}
+
+// enqueue the key to the pending batch, or return the cached result
+func (l *Loader) enqueue(ctx context.Context, key string) *result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if res, ok := l.cache[key]; ok {
+		return res
+	}
+
+	res := &result{done: make(chan struct{})}
+	l.cache[key] = res
+	if l.pending == nil {
+		b := &batch{results: make(map[string]*result)}
+		// the batch is loaded with the context of the first load
+		b.timer = time.AfterFunc(l.opts.Wait, func() {
+			l.dispatch(ctx, b)
+		})
+		l.pending = b
+	}
+	b := l.pending
+	b.keys = append(b.keys, key)
+	b.results[key] = res
+
+	if len(b.keys) >= l.opts.MaxBatch {
+		// the pending batch is loaded now, so next keys go to a new batch
+		if b.timer.Stop() {
+			l.pending = nil
+			safego.Go(ctx, "dataloader.dispatch", func(ctx context.Context) error {
+				l.dispatch(ctx, b)
+				return nil
+			})
+		}
+	}
+	return res
+}
+
+// dispatch load the batch and set the result of all keys
+// the panic is set as the error of the keys which are not done, so the waiting loads are not blocked forever
+func (l *Loader) dispatch(ctx context.Context, b *batch) {
+	l.mu.Lock()
+	if l.pending == b {
+		l.pending = nil
+	}
+	l.mu.Unlock()
+
+	if err := safego.Run(ctx, "dataloader.batch", func(ctx context.Context) error {
+		l.load(ctx, b)
+		return nil
+	}); err != nil {
+		l.fail(b, err)
+	}
+}
+
+// load call the batch function and set the result of all keys
+func (l *Loader) load(ctx context.Context, b *batch) {
+	values, err := l.fn(ctx, b.keys)
+	if err != nil {
+		l.fail(b, err)
+		return
+	}
+	for key, res := range b.results {
+		if value, ok := values[key]; ok {
+			res.value = value
+		} else {
+			res.err = fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		close(res.done)
+	}
+}
+
+// fail set the error of the keys which are not done
+// failed load is not cached, so it can be retried
+func (l *Loader) fail(b *batch, err error) {
+	for _, res := range b.results {
+		select {
+		case <-res.done:
+			continue
+		default:
+		}
+		res.err = err
+		close(res.done)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, res := range b.results {
+		if l.cache[key] == res {
+			delete(l.cache, key)
+		}
+	}
+}
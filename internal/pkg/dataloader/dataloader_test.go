@@ -0,0 +1,204 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+// recordBatch return batch function which return the key as value and record every batch
+func recordBatch(batches *[][]string, mu *sync.Mutex) BatchFunc {
+	return func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sorted := append([]string(nil), keys...)
+		sort.Strings(sorted)
+		*batches = append(*batches, sorted)
+
+		values := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			if key != "missing" {
+				values[key] = "value-" + key
+			}
+		}
+		return values, nil
+	}
+}
+
+func TestLoaderBatch(t *testing.T) {
+	var (
+		batches [][]string
+		mu      sync.Mutex
+	)
+	loader := New(recordBatch(&batches, &mu), &Options{Wait: time.Millisecond * 10})
+
+	keys := []string{"1", "2", "3", "2", "missing"}
+	var (
+		wg     sync.WaitGroup
+		values = make([]interface{}, len(keys))
+		errs   = make([]error, len(keys))
+	)
+	for idx, key := range keys {
+		wg.Add(1)
+		go func(idx int, key string) {
+			defer wg.Done()
+			values[idx], errs[idx] = loader.Load(context.Background(), key)
+		}(idx, key)
+	}
+	wg.Wait()
+
+	if len(batches) != 1 {
+		t.Fatalf("expecting 1 batch but got %d: %v", len(batches), batches)
+	}
+	if len(batches[0]) != 4 {
+		t.Errorf("expecting duplicate key to be loaded once but got %v", batches[0])
+	}
+	for idx, key := range keys {
+		if key == "missing" {
+			if !errors.Is(errs[idx], ErrNotFound) {
+				t.Errorf("expecting error %v but got %v", ErrNotFound, errs[idx])
+			}
+			continue
+		}
+		if errs[idx] != nil || values[idx] != "value-"+key {
+			t.Errorf("expecting value-%s but got %v, %v", key, values[idx], errs[idx])
+		}
+	}
+
+	// cached value is not loaded again
+	if _, err := loader.Load(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 {
+		t.Errorf("expecting cached value but got %d batches", len(batches))
+	}
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	var (
+		batches [][]string
+		mu      sync.Mutex
+	)
+	loader := New(recordBatch(&batches, &mu), &Options{Wait: time.Hour, MaxBatch: 2})
+
+	values, errs := loader.LoadMany(context.Background(), []string{"1", "2", "3", "4"})
+	for idx, err := range errs {
+		if err != nil {
+			t.Fatalf("failed to load %d: %v", idx, err)
+		}
+	}
+	if values[3] != "value-4" {
+		t.Errorf("expecting value-4 but got %v", values[3])
+	}
+	if len(batches) != 2 {
+		t.Errorf("expecting 2 full batches but got %v", batches)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	errFailed := errors.New("failed")
+	calls := 0
+	loader := New(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errFailed
+		}
+		if calls == 2 {
+			panic("boom")
+		}
+		return map[string]interface{}{"1": 1}, nil
+	}, nil)
+
+	if _, err := loader.Load(context.Background(), "1"); !errors.Is(err, errFailed) {
+		t.Errorf("expecting error %v but got %v", errFailed, err)
+	}
+	if _, err := loader.Load(context.Background(), "1"); err == nil {
+		t.Error("expecting error from panic")
+	}
+	// failed load is not cached
+	if value, err := loader.Load(context.Background(), "1"); err != nil || value != 1 {
+		t.Errorf("expecting value 1 but got %v, %v", value, err)
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	// the full batch is loaded in a new goroutine, every key of the batch receive the panic
+	loader := New(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		panic("boom")
+	}, &Options{Wait: time.Hour, MaxBatch: 2})
+
+	_, errs := loader.LoadMany(context.Background(), []string{"1", "2"})
+	for idx, err := range errs {
+		var perr *safego.PanicError
+		if !errors.As(err, &perr) {
+			t.Errorf("expecting panic error for key %d but got %v", idx, err)
+		}
+	}
+}
+
+func TestLoaders(t *testing.T) {
+	created := 0
+	loaders := NewLoaders(map[string]Factory{
+		"users": func() *Loader {
+			created++
+			return New(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+				return nil, nil
+			}, nil)
+		},
+	})
+	ctx := WithLoaders(context.Background(), loaders)
+
+	first, err := For(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := For(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || created != 1 {
+		t.Errorf("expecting the same loader in one request, created %d times", created)
+	}
+	if _, err := For(ctx, "orders"); !errors.Is(err, ErrLoaderNotFound) {
+		t.Errorf("expecting error %v but got %v", ErrLoaderNotFound, err)
+	}
+	if _, err := For(context.Background(), "users"); !errors.Is(err, ErrLoaderNotFound) {
+		t.Errorf("expecting error %v but got %v", ErrLoaderNotFound, err)
+	}
+}
+
+func TestSQLIn(t *testing.T) {
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name FROM users WHERE id IN ($1, $2)")).
+		WithArgs("1", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, []byte("alice")))
+
+	values, err := SQLIn(db, "SELECT id, name FROM users WHERE id IN (?)", "id")(context.Background(), []string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, ok := values["1"].(map[string]interface{})
+	if !ok || row["name"] != "alice" {
+		t.Errorf("expecting user 1 but got %v", values)
+	}
+	if _, ok := values["2"]; ok {
+		t.Error("expecting user 2 to be not found")
+	}
+}
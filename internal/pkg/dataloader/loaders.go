@@ -0,0 +1,78 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// list of loaders error
+var (
+	ErrLoaderNotFound = errors.New("dataloader: loader not found")
+)
+
+// Factory create new loader
+type Factory func() *Loader
+
+// Loaders of one request, the loader is created on the first use
+type Loaders struct {
+	factories map[string]Factory
+
+	mu      sync.Mutex
+	loaders map[string]*Loader
+}
+
+// NewLoaders return new loaders from the factories, keyed by loader name
+func NewLoaders(factories map[string]Factory) *Loaders {
+	l := Loaders{
+		factories: factories,
+		loaders:   make(map[string]*Loader),
+	}
+	return &l
+}
+
+// Get loader by name
+func (l *Loaders) Get(name string) (*Loader, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if loader, ok := l.loaders[name]; ok {
+		return loader, nil
+	}
+	factory, ok := l.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLoaderNotFound, name)
+	}
+	loader := factory()
+	l.loaders[name] = loader
+	return loader, nil
+}
+
+type loadersKey struct{}
+
+// WithLoaders return context with the loaders
+func WithLoaders(ctx context.Context, loaders *Loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, loaders)
+}
+
+// For return the loader of the request context by name
+func For(ctx context.Context, name string) (*Loader, error) {
+	loaders, ok := ctx.Value(loadersKey{}).(*Loaders)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLoaderNotFound, name)
+	}
+	return loaders.Get(name)
+}
+
+// Middleware set new loaders to every request context, so values are cached only in one request
+func Middleware(factories map[string]Factory) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			rctx.SetContext(WithLoaders(rctx.Context(), NewLoaders(factories)))
+			return next(rctx)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package defaults
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RedactedValue is printed in place of protected field value
+const RedactedValue = "******"
+
+// error list
+var (
+	// ErrNameEmpty is returned when registering config without name
+	ErrNameEmpty = errors.New("name of config is empty")
+	// ErrAlreadyRegistered is returned when config with the same name is already registered
+	ErrAlreadyRegistered = errors.New("config is already registered")
+)
+
+// Defaulter is implemented by config which need more than struct tag to set its default value
+// for example, to inherit value from parent config
+type Defaulter interface {
+	SetDefault() error
+}
+
+type entry struct {
+	name   string
+	config interface{}
+}
+
+// Registry hold configuration of every subsystem
+// the registry is used to set default value of all configs and to print the effective configuration
+type Registry struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+// NewRegistry create a new config registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register config of a subsystem, config must be a pointer of struct
+func (r *Registry) Register(name string, config interface{}) error {
+	if name == "" {
+		return ErrNameEmpty
+	}
+	val := reflect.ValueOf(config)
+	if val.Kind() != reflect.Ptr {
+		return ErrPassValue
+	}
+	if reflect.Indirect(val).Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.name == name {
+			return fmt.Errorf("%w: %s", ErrAlreadyRegistered, name)
+		}
+	}
+	r.entries = append(r.entries, entry{name: name, config: config})
+	return nil
+}
+
+// SetDefault set default value of all registered configs
+// SetDefault of the config is used when the config implements Defaulter, otherwise the struct tag is used
+func (r *Registry) SetDefault() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		var err error
+		if d, ok := e.config.(Defaulter); ok {
+			err = d.SetDefault()
+		} else {
+			err = SetDefault(e.config)
+		}
+		if err != nil {
+			return fmt.Errorf("defaults: failed to set default of %s: %w", e.name, err)
+		}
+	}
+	return nil
+}
+
+// Effective return the current value of all registered configs keyed by its name
+// field with tag protected:"1" is redacted
+func (r *Registry) Effective() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	effective := make(map[string]interface{}, len(r.entries))
+	for _, e := range r.entries {
+		effective[e.name] = redact(reflect.ValueOf(e.config))
+	}
+	return effective
+}
+
+// Print the effective configuration in toml format
+func (r *Registry) Print(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(r.Effective())
+}
+
+// fieldName return name of the field from its tag, the same name is used in config file
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"toml", "yaml", "json"} {
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// redact convert the value to map and slice of primitive types with protected field redacted
+func redact(val reflect.Value) interface{} {
+	val = reflect.Indirect(val)
+	if !val.IsValid() {
+		return nil
+	}
+	if val.Type() == timeDurationType {
+		return val.Interface().(time.Duration).String()
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		for i := 0; i < val.NumField(); i++ {
+			f := val.Type().Field(i)
+			// skip unexported field
+			if f.PkgPath != "" {
+				continue
+			}
+			fv := val.Field(i)
+			if f.Tag.Get("protected") == "1" {
+				if !fv.IsZero() {
+					m[fieldName(f)] = RedactedValue
+				}
+				continue
+			}
+			if v := redact(fv); v != nil {
+				m[fieldName(f)] = v
+			}
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			if v := redact(val.Index(i)); v != nil {
+				s = append(s, v)
+			}
+		}
+		return s
+	case reflect.Map:
+		m := make(map[string]interface{}, val.Len())
+		iter := val.MapRange()
+		for iter.Next() {
+			if v := redact(iter.Value()); v != nil {
+				m[fmt.Sprint(iter.Key().Interface())] = v
+			}
+		}
+		return m
+	case reflect.Interface:
+		return redact(val.Elem())
+	default:
+		return val.Interface()
+	}
+}
@@ -0,0 +1,349 @@
+// Package eventbus is an in-process publish/subscribe for domain events within one binary
+// packages publish events to a topic without knowing who is subscribing, for example cache invalidation or audit log,
+// so they can react to each other without import cycles
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// list of error
+var (
+	ErrTopicEmpty   = errors.New("eventbus: topic name is empty")
+	ErrHandlerNil   = errors.New("eventbus: handler is nil")
+	ErrPayloadType  = errors.New("eventbus: payload type is not matched with topic")
+	ErrBusClosed    = errors.New("eventbus: bus is closed")
+	ErrTopicInvalid = errors.New("eventbus: topic is not created with NewTopic")
+)
+
+// Topic of event, each topic only accept one type of payload
+type Topic struct {
+	name string
+	typ  reflect.Type
+}
+
+// NewTopic create a new topic with the type of payload
+// for example NewTopic("user.created", entity.User{})
+func NewTopic(name string, payload interface{}) Topic {
+	return Topic{
+		name: name,
+		typ:  reflect.TypeOf(payload),
+	}
+}
+
+// Name of topic
+func (t Topic) Name() string {
+	return t.name
+}
+
+// Event published to the bus
+type Event struct {
+	Topic       string
+	Payload     interface{}
+	PublishedAt time.Time
+}
+
+// Handler of event
+type Handler func(ctx context.Context, event Event) error
+
+// Options of bus
+type Options struct {
+	// OnSlow is invoked when an event is dropped because the subscriber buffer is full
+	OnSlow func(subscriber string, event Event)
+	// OnError is invoked when async handler return error
+	OnError func(subscriber string, event Event, err error)
+}
+
+// SubscribeOptions of a subscriber
+type SubscribeOptions struct {
+	// Name of subscriber, used for goroutine name and slow subscriber report
+	Name string
+	// Async deliver the event in a separate goroutine, otherwise the event is delivered in the publisher goroutine
+	Async bool
+	// Buffer is the number of events buffered for async subscriber, default to 100
+	Buffer int
+	// SlowThreshold is the maximum time publisher wait when the buffer is full before the event is dropped
+	// default to 100ms
+	SlowThreshold time.Duration
+}
+
+type queued struct {
+	ctx   context.Context
+	event Event
+}
+
+// Subscription of a topic
+type Subscription struct {
+	bus     *Bus
+	topic   string
+	name    string
+	handler Handler
+	options SubscribeOptions
+	dropped int64
+
+	// mu protect queue from being closed while an event is sent
+	mu     sync.RWMutex
+	closed bool
+	queue  chan queued
+	done   chan struct{}
+}
+
+// Dropped return the number of events dropped because the subscriber is too slow
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Unsubscribe from the topic, buffered events of async subscriber are still delivered
+func (s *Subscription) Unsubscribe() {
+	s.bus.remove(s)
+	s.stop()
+}
+
+func (s *Subscription) stop() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	if s.queue != nil {
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	for q := range s.queue {
+		err := safego.Run(q.ctx, s.name, func(ctx context.Context) error {
+			return s.handler(ctx, q.event)
+		})
+		if err != nil && s.bus.options.OnError != nil {
+			s.bus.options.OnError(s.name, q.event, err)
+		}
+	}
+}
+
+// deliver the event to subscriber, return error only for sync subscriber
+func (s *Subscription) deliver(ctx context.Context, event Event) error {
+	if s.queue == nil {
+		return safego.Run(ctx, s.name, func(ctx context.Context) error {
+			return s.handler(ctx, event)
+		})
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil
+	}
+
+	// the async handler might run after the publisher is returned, so the handler should not be canceled with the publisher
+	q := queued{ctx: safego.Detach(ctx), event: event}
+	select {
+	case s.queue <- q:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(s.options.SlowThreshold)
+	defer timer.Stop()
+	select {
+	case s.queue <- q:
+	case <-timer.C:
+		atomic.AddInt64(&s.dropped, 1)
+		if s.bus.options.OnSlow != nil {
+			s.bus.options.OnSlow(s.name, event)
+		}
+	}
+	return nil
+}
+
+// Bus of events
+type Bus struct {
+	options Options
+
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[string][]*Subscription
+	topics      map[string]reflect.Type
+
+	// to help testing
+	now func() time.Time
+}
+
+// New event bus
+func New(options *Options) *Bus {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	b := Bus{
+		options:     opts,
+		subscribers: make(map[string][]*Subscription),
+		topics:      make(map[string]reflect.Type),
+		now:         time.Now,
+	}
+	return &b
+}
+
+// checkTopic make sure the same topic name is always used with the same payload type
+// must be called with lock held
+func (b *Bus) checkTopic(topic Topic) error {
+	if topic.name == "" {
+		return ErrTopicEmpty
+	}
+	if topic.typ == nil {
+		return ErrTopicInvalid
+	}
+	typ, ok := b.topics[topic.name]
+	if !ok {
+		b.topics[topic.name] = topic.typ
+		return nil
+	}
+	if typ != topic.typ {
+		return fmt.Errorf("%w: topic %s already used with %s, got %s", ErrPayloadType, topic.name, typ, topic.typ)
+	}
+	return nil
+}
+
+// Subscribe handler to a topic
+func (b *Bus) Subscribe(topic Topic, handler Handler, options *SubscribeOptions) (*Subscription, error) {
+	if handler == nil {
+		return nil, ErrHandlerNil
+	}
+
+	opts := SubscribeOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Name == "" {
+		opts.Name = topic.name
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 100
+	}
+	if opts.SlowThreshold <= 0 {
+		opts.SlowThreshold = time.Millisecond * 100
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+	if err := b.checkTopic(topic); err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		bus:     b,
+		topic:   topic.name,
+		name:    fmt.Sprintf("eventbus/%s", opts.Name),
+		handler: handler,
+		options: opts,
+	}
+	if opts.Async {
+		s.queue = make(chan queued, opts.Buffer)
+		s.done = make(chan struct{})
+		safego.Go(context.Background(), s.name, func(ctx context.Context) error {
+			s.run()
+			return nil
+		})
+	}
+	b.subscribers[topic.name] = append(b.subscribers[topic.name], s)
+	return s, nil
+}
+
+func (b *Bus) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs := b.subscribers[s.topic]
+	for i, sub := range subs {
+		if sub == s {
+			b.subscribers[s.topic] = append(subs[:i:i], subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish payload to a topic
+// sync subscribers are invoked before Publish return and the first error of them is returned
+// async subscribers only receive the event in their buffer
+func (b *Bus) Publish(ctx context.Context, topic Topic, payload interface{}) error {
+	if reflect.TypeOf(payload) != topic.typ {
+		return fmt.Errorf("%w: topic %s expect %s, got %T", ErrPayloadType, topic.name, topic.typ, payload)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBusClosed
+	}
+	if err := b.checkTopic(topic); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	subs := make([]*Subscription, len(b.subscribers[topic.name]))
+	copy(subs, b.subscribers[topic.name])
+	b.mu.Unlock()
+
+	event := Event{
+		Topic:       topic.name,
+		Payload:     payload,
+		PublishedAt: b.now(),
+	}
+
+	var errs []error
+	for _, s := range subs {
+		if err := s.deliver(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("eventbus: subscriber %s: %w", s.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// PublishJSON decode the json payload to the payload type of the topic and publish it
+// for example to publish the event which is stored in the outbox table again
+func (b *Bus) PublishJSON(ctx context.Context, topic Topic, data []byte) error {
+	if topic.typ == nil {
+		return ErrTopicInvalid
+	}
+	payload := reflect.New(topic.typ)
+	if err := json.Unmarshal(data, payload.Interface()); err != nil {
+		return fmt.Errorf("eventbus: failed to decode payload of topic %s: %w", topic.name, err)
+	}
+	return b.Publish(ctx, topic, payload.Elem().Interface())
+}
+
+// Close the bus and wait until all buffered events of async subscribers are delivered
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	var subs []*Subscription
+	for _, s := range b.subscribers {
+		subs = append(subs, s...)
+	}
+	b.subscribers = make(map[string][]*Subscription)
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.stop()
+	}
+}
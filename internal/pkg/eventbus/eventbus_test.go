@@ -0,0 +1,153 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+)
+
+type userCreated struct {
+	ID int64
+}
+
+var topicUserCreated = eventbus.NewTopic("user.created", userCreated{})
+
+func TestPublishSync(t *testing.T) {
+	bus := eventbus.New(nil)
+	defer bus.Close()
+
+	var got []int64
+	_, err := bus.Subscribe(topicUserCreated, func(ctx context.Context, event eventbus.Event) error {
+		got = append(got, event.Payload.(userCreated).ID)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errTest := errors.New("test error")
+	_, err = bus.Subscribe(topicUserCreated, func(ctx context.Context, event eventbus.Event) error {
+		return errTest
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Publish(context.Background(), topicUserCreated, userCreated{ID: 1}); !errors.Is(err, errTest) {
+		t.Errorf("expecting error %v but got %v", errTest, err)
+	}
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("unexpected events %v", got)
+	}
+
+	if err := bus.Publish(context.Background(), topicUserCreated, "not a user"); !errors.Is(err, eventbus.ErrPayloadType) {
+		t.Errorf("expecting error %v but got %v", eventbus.ErrPayloadType, err)
+	}
+	if _, err := bus.Subscribe(eventbus.NewTopic("user.created", 1), func(ctx context.Context, event eventbus.Event) error {
+		return nil
+	}, nil); !errors.Is(err, eventbus.ErrPayloadType) {
+		t.Errorf("expecting error %v but got %v", eventbus.ErrPayloadType, err)
+	}
+}
+
+func TestPublishJSON(t *testing.T) {
+	bus := eventbus.New(nil)
+	defer bus.Close()
+
+	var got []int64
+	_, err := bus.Subscribe(topicUserCreated, func(ctx context.Context, event eventbus.Event) error {
+		got = append(got, event.Payload.(userCreated).ID)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishJSON(context.Background(), topicUserCreated, []byte(`{"ID": 10}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != 10 {
+		t.Errorf("unexpected events %v", got)
+	}
+	if err := bus.PublishJSON(context.Background(), topicUserCreated, []byte(`{"ID": "10"}`)); err == nil {
+		t.Error("expecting decode error")
+	}
+	if err := bus.PublishJSON(context.Background(), eventbus.Topic{}, []byte(`{}`)); !errors.Is(err, eventbus.ErrTopicInvalid) {
+		t.Errorf("expecting error %v but got %v", eventbus.ErrTopicInvalid, err)
+	}
+}
+
+func TestPublishAsync(t *testing.T) {
+	bus := eventbus.New(nil)
+
+	var (
+		mu  sync.Mutex
+		got []int64
+	)
+	sub, err := bus.Subscribe(topicUserCreated, func(ctx context.Context, event eventbus.Event) error {
+		mu.Lock()
+		got = append(got, event.Payload.(userCreated).ID)
+		mu.Unlock()
+		return nil
+	}, &eventbus.SubscribeOptions{Name: "audit", Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 10; i++ {
+		if err := bus.Publish(context.Background(), topicUserCreated, userCreated{ID: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// close wait for all buffered events to be delivered
+	bus.Close()
+
+	if len(got) != 10 {
+		t.Errorf("expecting 10 events but got %d", len(got))
+	}
+	if sub.Dropped() != 0 {
+		t.Errorf("expecting no dropped events but got %d", sub.Dropped())
+	}
+	if err := bus.Publish(context.Background(), topicUserCreated, userCreated{ID: 11}); err != eventbus.ErrBusClosed {
+		t.Errorf("expecting error %v but got %v", eventbus.ErrBusClosed, err)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	var slow []string
+	bus := eventbus.New(&eventbus.Options{
+		OnSlow: func(subscriber string, event eventbus.Event) {
+			slow = append(slow, subscriber)
+		},
+	})
+
+	block := make(chan struct{})
+	sub, err := bus.Subscribe(topicUserCreated, func(ctx context.Context, event eventbus.Event) error {
+		<-block
+		return nil
+	}, &eventbus.SubscribeOptions{Name: "slow", Async: true, Buffer: 1, SlowThreshold: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first event is taken by the handler, the second fill the buffer and the rest are dropped
+	for i := 0; i < 5; i++ {
+		if err := bus.Publish(context.Background(), topicUserCreated, userCreated{ID: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	close(block)
+	bus.Close()
+
+	if sub.Dropped() != 3 {
+		t.Errorf("expecting 3 dropped events but got %d", sub.Dropped())
+	}
+	if len(slow) != 3 || slow[0] != "eventbus/slow" {
+		t.Errorf("unexpected slow report %v", slow)
+	}
+}
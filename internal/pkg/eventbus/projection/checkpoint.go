@@ -0,0 +1,134 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// Checkpoint of a projection
+type Checkpoint struct {
+	// Cursor of the last applied event, empty when no event is applied
+	Cursor string `json:"cursor"`
+	// Position is the created_at of the last applied event
+	Position  time.Time `json:"position"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Init create the checkpoint and the applied table when they don't exist
+func (p *Projector) Init(ctx context.Context) error {
+	queries := map[string]string{
+		p.opts.CheckpointTable: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	projection VARCHAR(255) PRIMARY KEY,
+	cursor_value TEXT NOT NULL,
+	position TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+)`, p.opts.CheckpointTable),
+		p.opts.AppliedTable: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	projection VARCHAR(255) NOT NULL,
+	event_id VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (projection, event_id)
+)`, p.opts.AppliedTable),
+	}
+	for _, table := range []string{p.opts.CheckpointTable, p.opts.AppliedTable} {
+		if _, err := p.db.ExecContext(ctx, queries[table]); err != nil {
+			return fmt.Errorf("projection: failed to create table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// Checkpoint return the checkpoint of the projection, the checkpoint is empty when no event is applied
+// the checkpoint is read from the leader, so it is never behind the applied events
+func (p *Projector) Checkpoint(ctx context.Context, name string) (Checkpoint, error) {
+	checkpoint := Checkpoint{}
+	query := p.db.Rebind(fmt.Sprintf("SELECT cursor_value, position, updated_at FROM %s WHERE projection = ?", p.opts.CheckpointTable))
+	err := p.db.QueryRowContext(sqldb.ForceLeader(ctx), query, name).Scan(&checkpoint.Cursor, &checkpoint.Position, &checkpoint.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Checkpoint{}, nil
+	}
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("projection: failed to load checkpoint of %s: %w", name, err)
+	}
+	return checkpoint, nil
+}
+
+// saveCheckpoint of the projection in the transaction of the applied events
+func (p *Projector) saveCheckpoint(ctx context.Context, tx *sqldb.Tx, name string, checkpoint Checkpoint) error {
+	query, args, err := sqldb.BuildUpsert(tx.DriverName(), p.opts.CheckpointTable, []string{"projection"}, map[string]interface{}{
+		"projection":   name,
+		"cursor_value": checkpoint.Cursor,
+		"position":     checkpoint.Position,
+		"updated_at":   checkpoint.UpdatedAt,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("projection: failed to save checkpoint of %s: %w", name, err)
+	}
+	return nil
+}
+
+// ApplyOnce run the function only when the event is not applied by the projection yet, in the transaction of the handler
+// false is returned without running the function when the event is already applied
+// use it for the change which is not idempotent, for example incrementing a counter, as the event can be applied again after Reset
+func (p *Projector) ApplyOnce(ctx context.Context, tx *sqldb.Tx, name, eventID string, fn func() error) (bool, error) {
+	// all columns are keys except applied_at, and empty update keep the existing row
+	query, args, err := sqldb.BuildUpsert(tx.DriverName(), p.opts.AppliedTable, []string{"projection", "event_id"}, map[string]interface{}{
+		"projection": name,
+		"event_id":   eventID,
+		"applied_at": p.opts.Now(),
+	}, &sqldb.UpsertOptions{Update: []string{}})
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("projection: failed to mark event %s applied by %s: %w", eventID, name, err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+	return true, fn()
+}
+
+// Reset the checkpoint and the applied events of the projection, so all events are applied again in the next catch up
+// use it to rebuild the read model after a bug of the handler is fixed, the read model must be cleared in the function
+func (p *Projector) Reset(ctx context.Context, name string, clear func(tx *sqldb.Tx) error) error {
+	r, err := p.get(name)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = p.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		for _, table := range []string{p.opts.CheckpointTable, p.opts.AppliedTable} {
+			query := tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE projection = ?", table))
+			if _, err := tx.ExecContext(ctx, query, name); err != nil {
+				return fmt.Errorf("projection: failed to reset %s of %s: %w", table, name, err)
+			}
+		}
+		if clear == nil {
+			return nil
+		}
+		return clear(tx)
+	})
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	r.status.Checkpoint = Checkpoint{}
+	p.mu.Unlock()
+	return nil
+}
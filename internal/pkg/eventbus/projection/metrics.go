@@ -0,0 +1,58 @@
+package projection
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lagDesc = prometheus.NewDesc(
+		"projection_lag_seconds",
+		"Age of the oldest event which is not applied by the projection yet, zero when the projection caught up",
+		[]string{"projection"}, nil,
+	)
+	appliedDesc = prometheus.NewDesc(
+		"projection_applied_events_total",
+		"Number of events applied by the projection",
+		[]string{"projection"}, nil,
+	)
+	failuresDesc = prometheus.NewDesc(
+		"projection_failures_total",
+		"Number of failed catch up of the projection",
+		[]string{"projection"}, nil,
+	)
+	positionDesc = prometheus.NewDesc(
+		"projection_checkpoint_timestamp_seconds",
+		"Created time of the last event applied by the projection",
+		[]string{"projection"}, nil,
+	)
+)
+
+// Collector return prometheus collector of the lag and the applied events of the projections
+func (p *Projector) Collector() prometheus.Collector {
+	return collector{projector: p}
+}
+
+type collector struct {
+	projector *Projector
+}
+
+// Describe implements prometheus.Collector
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lagDesc
+	ch <- appliedDesc
+	ch <- failuresDesc
+	ch <- positionDesc
+}
+
+// Collect implements prometheus.Collector
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	now := c.projector.opts.Now()
+	for _, status := range c.projector.Statuses() {
+		ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, status.Lag(now).Seconds(), status.Name)
+		ch <- prometheus.MustNewConstMetric(appliedDesc, prometheus.CounterValue, float64(status.Applied), status.Name)
+		ch <- prometheus.MustNewConstMetric(failuresDesc, prometheus.CounterValue, float64(status.Failures), status.Name)
+		if !status.Checkpoint.Position.IsZero() {
+			ch <- prometheus.MustNewConstMetric(positionDesc, prometheus.GaugeValue, float64(status.Checkpoint.Position.Unix()), status.Name)
+		}
+	}
+}
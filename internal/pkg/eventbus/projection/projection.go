@@ -0,0 +1,366 @@
+// Package projection build the read models from the events of the outbox table
+// every projection apply the events in the order they are written, and save its checkpoint in the same transaction
+// so the read model and the checkpoint are always consistent, and the projection catch up from the checkpoint after restart
+//
+//	projector, err := projection.New(db, nil)
+//	err = projector.Register(projection.Projection{Name: "order_summary", Topics: []string{"order.created", "order.paid"}, Handler: applyOrder})
+//	err = projector.Init(ctx)
+//	err = projector.Subscribe(bus, topicOrderCreated, topicOrderPaid)
+//	projector.Start(ctx)
+//	defer projector.Close()
+//
+// the outbox events are read by created_at and id, so the events must be written with increasing created_at
+// an event which is committed after a later event is already applied is skipped
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus/replay"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// default value of options
+const (
+	DefaultCheckpointTable = "projection_checkpoints"
+	DefaultAppliedTable    = "projection_applied"
+	DefaultInterval        = time.Second * 5
+)
+
+// list of projection error
+var (
+	ErrNameEmpty         = errors.New("projection: name is empty")
+	ErrHandlerNil        = errors.New("projection: handler is nil")
+	ErrDuplicate         = errors.New("projection: projection already registered")
+	ErrNotFound          = errors.New("projection: projection not found")
+	errPublishNotAllowed = errors.New("projection: events are not published by projection")
+)
+
+// Handler apply the event to the read model in the transaction of the checkpoint
+// the whole batch is rolled back and applied again when the handler return error
+type Handler func(ctx context.Context, tx *sqldb.Tx, event replay.Event) error
+
+// Projection of events to a read model
+type Projection struct {
+	// Name of the projection, the key of its checkpoint
+	Name string
+	// Topics of the events applied by the projection, all topics when empty
+	Topics  []string
+	Handler Handler
+}
+
+// Options of projector
+type Options struct {
+	// CheckpointTable is the table of the checkpoints, default to projection_checkpoints
+	CheckpointTable string
+	// AppliedTable is the table of the events applied with ApplyOnce, default to projection_applied
+	AppliedTable string
+	// Outbox is the options of the outbox table, see replay.Options
+	Outbox *replay.Options
+	// Interval of catch up when no event is received from the bus, default to 5 seconds
+	Interval time.Duration
+	// OnError is called when the catch up of a projection failed in Start
+	OnError func(name string, err error)
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// Status of a projection
+type Status struct {
+	Name string `json:"name"`
+	// Checkpoint is the last applied event of the projection
+	Checkpoint Checkpoint `json:"checkpoint"`
+	// Applied is the number of events applied since the projector is created
+	Applied int64 `json:"applied"`
+	// Failures is the number of failed catch up since the projector is created
+	Failures int64 `json:"failures"`
+	// PendingSince is the created_at of the oldest event which is not applied yet, zero when the projection caught up
+	PendingSince time.Time `json:"pending_since"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// Lag of the projection at the time, zero when the projection caught up
+func (s Status) Lag(now time.Time) time.Duration {
+	if s.PendingSince.IsZero() {
+		return 0
+	}
+	return now.Sub(s.PendingSince)
+}
+
+type registered struct {
+	projection Projection
+	// mu serialize the catch up of the projection
+	mu     sync.Mutex
+	status Status
+}
+
+// Projector run the projections of the outbox events
+type Projector struct {
+	db     *sqldb.DB
+	events *replay.Replayer
+	opts   Options
+
+	mu          sync.Mutex
+	projections map[string]*registered
+	done        <-chan error
+
+	wake      chan struct{}
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// New projector of the outbox events in the database, the read models and the checkpoints are in the same database
+func New(db *sqldb.DB, options *Options) (*Projector, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.CheckpointTable == "" {
+		opts.CheckpointTable = DefaultCheckpointTable
+	}
+	if opts.AppliedTable == "" {
+		opts.AppliedTable = DefaultAppliedTable
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(name string, err error) {}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	for _, table := range []string{opts.CheckpointTable, opts.AppliedTable} {
+		if err := sqldb.ValidateIdentifier(table); err != nil {
+			return nil, err
+		}
+	}
+	// the replayer is only used to read the events
+	events, err := replay.New(db, replay.PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+		return errPublishNotAllowed
+	}), opts.Outbox)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Projector{
+		db:          db,
+		events:      events,
+		opts:        opts,
+		projections: make(map[string]*registered),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+	return &p, nil
+}
+
+// Register the projection, the projection start from its checkpoint or from the first event
+func (p *Projector) Register(projection Projection) error {
+	if projection.Name == "" {
+		return ErrNameEmpty
+	}
+	if projection.Handler == nil {
+		return ErrHandlerNil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.projections[projection.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, projection.Name)
+	}
+	p.projections[projection.Name] = &registered{
+		projection: projection,
+		status:     Status{Name: projection.Name},
+	}
+	return nil
+}
+
+// Subscribe to the topics of the bus, so the projections catch up right after the event is published
+// instead of waiting for the interval. the event itself is read from the outbox
+func (p *Projector) Subscribe(bus *eventbus.Bus, topics ...eventbus.Topic) error {
+	for _, topic := range topics {
+		_, err := bus.Subscribe(topic, func(ctx context.Context, event eventbus.Event) error {
+			select {
+			case p.wake <- struct{}{}:
+			default:
+			}
+			return nil
+		}, &eventbus.SubscribeOptions{Name: "projection/" + topic.Name()})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Projector) get(name string) (*registered, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.projections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return r, nil
+}
+
+// names of the registered projections sorted by name
+func (p *Projector) names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.projections))
+	for name := range p.projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CatchUp apply all events after the checkpoint of the projection, and return the number of applied events
+// every batch of events is applied in one transaction together with the checkpoint
+func (p *Projector) CatchUp(ctx context.Context, name string) (int, error) {
+	r, err := p.get(name)
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	checkpoint, err := p.Checkpoint(ctx, name)
+	if err != nil {
+		return 0, p.failed(r, err)
+	}
+	p.mu.Lock()
+	r.status.Checkpoint = checkpoint
+	p.mu.Unlock()
+
+	var applied int
+	cursor := checkpoint.Cursor
+	req := replay.Request{Topics: r.projection.Topics}
+	for {
+		events, next, err := p.events.Events(ctx, req, cursor)
+		if err != nil {
+			return applied, p.failed(r, err)
+		}
+		if len(events) == 0 {
+			p.setPending(r, time.Time{})
+			return applied, nil
+		}
+		p.setPending(r, events[0].CreatedAt)
+
+		last := events[len(events)-1]
+		lastCursor, err := last.Cursor()
+		if err != nil {
+			return applied, p.failed(r, err)
+		}
+		checkpoint = Checkpoint{Cursor: lastCursor, Position: last.CreatedAt, UpdatedAt: p.opts.Now()}
+		err = p.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+			for _, event := range events {
+				if err := r.projection.Handler(ctx, tx, event); err != nil {
+					return fmt.Errorf("projection: %s failed to apply event %s: %w", name, event.ID, err)
+				}
+			}
+			return p.saveCheckpoint(ctx, tx, name, checkpoint)
+		})
+		if err != nil {
+			return applied, p.failed(r, err)
+		}
+		applied += len(events)
+		p.mu.Lock()
+		r.status.Checkpoint = checkpoint
+		r.status.Applied += int64(len(events))
+		r.status.LastError = ""
+		p.mu.Unlock()
+
+		if next == "" {
+			p.setPending(r, time.Time{})
+			return applied, nil
+		}
+		cursor = next
+	}
+}
+
+func (p *Projector) setPending(r *registered, since time.Time) {
+	p.mu.Lock()
+	r.status.PendingSince = since
+	p.mu.Unlock()
+}
+
+// failed record the failure of the projection and return the error
+func (p *Projector) failed(r *registered, err error) error {
+	p.mu.Lock()
+	r.status.Failures++
+	r.status.LastError = err.Error()
+	p.mu.Unlock()
+	return err
+}
+
+// CatchUpAll catch up all projections, the error of every projection is passed to OnError and the first error is returned
+func (p *Projector) CatchUpAll(ctx context.Context) error {
+	var firstErr error
+	for _, name := range p.names() {
+		if _, err := p.CatchUp(ctx, name); err != nil {
+			p.opts.OnError(name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Statuses of all projections sorted by name
+func (p *Projector) Statuses() []Status {
+	names := p.names()
+	statuses := make([]Status, 0, len(names))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range names {
+		statuses = append(statuses, p.projections[name].status)
+	}
+	return statuses
+}
+
+// Start catch up all projections every interval, or when an event of the subscribed topics is published, until Close is called
+func (p *Projector) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done != nil {
+		return
+	}
+	p.done = safego.Go(safego.Detach(ctx), "projection.catchup", p.run)
+}
+
+// Close stop the catch up and wait for the running catch up
+func (p *Projector) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	return <-done
+}
+
+func (p *Projector) run(ctx context.Context) error {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		// the error is passed to OnError, and the projection is caught up again in the next round
+		p.CatchUpAll(ctx)
+		select {
+		case <-p.stop:
+			return nil
+		case <-ticker.C:
+		case <-p.wake:
+		}
+	}
+}
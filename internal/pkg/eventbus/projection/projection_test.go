@@ -0,0 +1,201 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus/replay"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var testStart = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestProjector(t *testing.T) (*Projector, *sqldb.DB) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := []string{
+		"CREATE TABLE outbox (id INTEGER PRIMARY KEY, aggregate_id TEXT, topic TEXT, payload TEXT, created_at DATETIME)",
+		"CREATE TABLE order_totals (order_id TEXT PRIMARY KEY, events INTEGER NOT NULL)",
+	}
+	for _, query := range queries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// small batch to catch up with more than one batch
+	p, err := New(db, &Options{
+		Outbox: &replay.Options{BatchSize: 2},
+		Now:    func() time.Time { return testStart.Add(time.Hour) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// init can be called again
+	if err := p.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return p, db
+}
+
+func insertEvent(t *testing.T, db *sqldb.DB, id int, aggregate, topic string, minute int) {
+	t.Helper()
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO outbox VALUES (?, ?, ?, ?, ?)", id, aggregate, topic, `{}`, testStart.Add(time.Minute*time.Duration(minute))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func orderEvents(p *Projector, failOn string) Handler {
+	return func(ctx context.Context, tx *sqldb.Tx, event replay.Event) error {
+		if event.ID == failOn {
+			return errors.New("handler bug")
+		}
+		_, err := p.ApplyOnce(ctx, tx, "order_totals", event.ID, func() error {
+			query, args, err := sqldb.BuildUpsert(tx.DriverName(), "order_totals", []string{"order_id"}, map[string]interface{}{"order_id": event.AggregateID, "events": 0}, &sqldb.UpsertOptions{Update: []string{}})
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE order_totals SET events = events + 1 WHERE order_id = ?", event.AggregateID)
+			return err
+		})
+		return err
+	}
+}
+
+func totals(t *testing.T, db *sqldb.DB) string {
+	t.Helper()
+	var rows []struct {
+		OrderID string `db:"order_id"`
+		Events  int    `db:"events"`
+	}
+	if err := db.SelectContext(context.Background(), &rows, "SELECT order_id, events FROM order_totals ORDER BY order_id"); err != nil {
+		t.Fatal(err)
+	}
+	parts := make([]string, len(rows))
+	for idx, row := range rows {
+		parts[idx] = fmt.Sprintf("%s=%d", row.OrderID, row.Events)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestCatchUp(t *testing.T) {
+	ctx := context.Background()
+	p, db := newTestProjector(t)
+	failOn := "3"
+	if err := p.Register(Projection{Name: "order_totals", Topics: []string{"order.created", "order.paid"}, Handler: func(ctx context.Context, tx *sqldb.Tx, event replay.Event) error {
+		return orderEvents(p, failOn)(ctx, tx, event)
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(Projection{Name: "order_totals", Handler: orderEvents(p, "")}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expecting error %v but got %v", ErrDuplicate, err)
+	}
+	insertEvent(t, db, 1, "order-1", "order.created", 0)
+	insertEvent(t, db, 2, "order-2", "order.created", 1)
+	insertEvent(t, db, 3, "order-1", "order.paid", 2)
+	insertEvent(t, db, 4, "order-1", "order.shipped", 3)
+
+	// the second batch is failed and rolled back, the first batch is kept
+	applied, err := p.CatchUp(ctx, "order_totals")
+	if err == nil {
+		t.Fatal("expecting handler error")
+	}
+	if applied != 2 || totals(t, db) != "order-1=1,order-2=1" {
+		t.Fatalf("expecting the first batch is applied but got %d: %s", applied, totals(t, db))
+	}
+	status := p.Statuses()[0]
+	if status.Failures != 1 || status.Lag(testStart.Add(time.Hour)) != time.Minute*58 {
+		t.Fatalf("expecting failure with lag of event 3 but got %+v", status)
+	}
+
+	// the catch up continue from the checkpoint after the bug is fixed
+	failOn = ""
+	applied, err = p.CatchUp(ctx, "order_totals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 1 || totals(t, db) != "order-1=2,order-2=1" {
+		t.Fatalf("expecting event 3 is applied but got %d: %s", applied, totals(t, db))
+	}
+	checkpoint, err := p.Checkpoint(ctx, "order_totals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkpoint.Position.Equal(testStart.Add(time.Minute * 2)) {
+		t.Fatalf("expecting checkpoint at event 3 but got %v", checkpoint.Position)
+	}
+	if applied, err := p.CatchUp(ctx, "order_totals"); err != nil || applied != 0 {
+		t.Fatalf("expecting nothing to apply but got %d: %v", applied, err)
+	}
+	if lag := p.Statuses()[0].Lag(time.Now()); lag != 0 {
+		t.Fatalf("expecting no lag but got %v", lag)
+	}
+
+	expect := `
+# HELP projection_applied_events_total Number of events applied by the projection
+# TYPE projection_applied_events_total counter
+projection_applied_events_total{projection="order_totals"} 3
+`
+	if err := testutil.CollectAndCompare(p.Collector(), strings.NewReader(expect), "projection_applied_events_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	p, db := newTestProjector(t)
+	if err := p.Register(Projection{Name: "order_totals", Handler: orderEvents(p, "")}); err != nil {
+		t.Fatal(err)
+	}
+	insertEvent(t, db, 1, "order-1", "order.created", 0)
+	insertEvent(t, db, 2, "order-1", "order.paid", 1)
+	if _, err := p.CatchUp(ctx, "order_totals"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the event which is already applied is not applied again
+	err := db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		return orderEvents(p, "")(ctx, tx, replay.Event{ID: "1", AggregateID: "order-1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals(t, db) != "order-1=2" {
+		t.Fatalf("expecting event is applied once but got %s", totals(t, db))
+	}
+
+	err = p.Reset(ctx, "order_totals", func(tx *sqldb.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM order_totals")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := p.CatchUp(ctx, "order_totals"); err != nil || applied != 2 {
+		t.Fatalf("expecting all events are applied again but got %d: %v", applied, err)
+	}
+	if totals(t, db) != "order-1=2" {
+		t.Fatalf("expecting rebuilt read model but got %s", totals(t, db))
+	}
+	if _, err := p.CatchUp(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expecting error %v but got %v", ErrNotFound, err)
+	}
+}
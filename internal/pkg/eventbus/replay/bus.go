@@ -0,0 +1,30 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+)
+
+// list of bus error
+var (
+	ErrTopicUnknown = errors.New("replay: topic is not registered to the bus publisher")
+)
+
+// Bus return the publisher which decode the payload to the type of the topic and publish it to the bus
+// only the events of the topics can be replayed, as the payload type is needed to decode the payload
+func Bus(bus *eventbus.Bus, topics ...eventbus.Topic) Publisher {
+	byName := make(map[string]eventbus.Topic, len(topics))
+	for _, topic := range topics {
+		byName[topic.Name()] = topic
+	}
+	return PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+		t, ok := byName[topic]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrTopicUnknown, topic)
+		}
+		return bus.PublishJSON(ctx, t, payload)
+	})
+}
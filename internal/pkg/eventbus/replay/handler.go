@@ -0,0 +1,45 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// Handler replay the events of the json request body and write the json result
+// the result is written with the cursor even when the replay is failed, so the replay can be continued
+//
+//	curl -X POST localhost:9000/debug/outbox/replay -d '{"aggregate_id": "order-1", "dry_run": true}'
+func (r *Replayer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		replayReq := Request{}
+		if err := json.NewDecoder(req.Body).Decode(&replayReq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := r.Replay(req.Context(), replayReq)
+		response := struct {
+			Result
+			Error string `json:"error,omitempty"`
+		}{Result: result}
+		status := http.StatusOK
+		if err != nil {
+			response.Error = err.Error()
+			status = http.StatusInternalServerError
+			if errors.Is(err, ErrFilterEmpty) || errors.Is(err, ErrTimeRange) || errors.Is(err, sqldb.ErrInvalidCursor) {
+				status = http.StatusBadRequest
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	})
+}
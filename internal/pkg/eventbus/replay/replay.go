@@ -0,0 +1,293 @@
+// Package replay publish the events of the outbox table again, in the order they are written
+// the events are selected by time range or aggregate id, for example to rebuild the read model of a subscriber after a bug is fixed
+//
+//	replayer, err := replay.New(db, replay.Bus(bus, entity.TopicOrderCreated, entity.TopicOrderPaid), nil)
+//	result, err := replayer.Replay(ctx, replay.Request{AggregateID: orderID, DryRun: true})
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+// default value of options
+const (
+	DefaultTable     = "outbox"
+	DefaultBatchSize = 500
+)
+
+// list of replay error
+var (
+	ErrPublisherNil = errors.New("replay: publisher is nil")
+	ErrFilterEmpty  = errors.New("replay: time range or aggregate id is required")
+	ErrTimeRange    = errors.New("replay: from must be before to")
+)
+
+// Columns of the outbox table
+type Columns struct {
+	// ID is the unique and increasing column, the events of the same time are replayed in the order of id
+	ID          string
+	AggregateID string
+	Topic       string
+	// Payload is the json payload of the event
+	Payload   string
+	CreatedAt string
+}
+
+// Options of replayer
+type Options struct {
+	// Table of the events, for example outbox or the archive table of outbox, default to outbox
+	Table string
+	// Columns of the table, default to id, aggregate_id, topic, payload and created_at
+	Columns Columns
+	// BatchSize is the number of events selected in one query, default to 500
+	BatchSize int
+}
+
+// Publisher publish the payload of the event to the topic
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// PublisherFunc is a function which implement Publisher
+type PublisherFunc func(ctx context.Context, topic string, payload []byte) error
+
+// Publish the payload to the topic
+func (f PublisherFunc) Publish(ctx context.Context, topic string, payload []byte) error {
+	return f(ctx, topic, payload)
+}
+
+// Request of replay
+type Request struct {
+	// From is the inclusive start of created_at of the events
+	From time.Time `json:"from"`
+	// To is the exclusive end of created_at of the events
+	To time.Time `json:"to"`
+	// AggregateID replay only the events of the aggregate, for example the order id
+	AggregateID string `json:"aggregate_id"`
+	// Topics replay only the events of the topics, all topics when empty
+	Topics []string `json:"topics"`
+	// TargetTopic publish all events to this topic instead of the topic of the event
+	// for example the topic which is only subscribed by the read model that is rebuilt
+	TargetTopic string `json:"target_topic"`
+	// DryRun count the events without publishing them
+	DryRun bool `json:"dry_run"`
+	// Cursor of the last replayed event, the replay continue after the cursor, see Result.Cursor
+	Cursor string `json:"cursor"`
+	// Limit is the maximum number of replayed events, all events when zero
+	Limit int `json:"limit"`
+}
+
+// Result of replay
+type Result struct {
+	// Replayed is the number of published events, or the number of events which would be published in dry run
+	Replayed int  `json:"replayed"`
+	DryRun   bool `json:"dry_run"`
+	// Topics is the number of replayed events per topic of the event
+	Topics map[string]int `json:"topics"`
+	// First and Last are the created_at of the first and the last replayed event
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// Cursor of the last replayed event, use it in the request to continue the replay which is failed or limited
+	Cursor string `json:"cursor"`
+}
+
+// Event of the outbox table
+type Event struct {
+	ID          string          `json:"id"`
+	AggregateID string          `json:"aggregate_id"`
+	Topic       string          `json:"topic"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Cursor of the event, the events after the cursor are returned by Events
+func (e Event) Cursor() (string, error) {
+	return sqldb.EncodeCursor(e.CreatedAt, e.ID)
+}
+
+// Replayer of outbox events
+type Replayer struct {
+	db        *sqldb.DB
+	publisher Publisher
+	opts      Options
+	keyset    sqldb.Keyset
+}
+
+// New replayer of the events in the database
+func New(db *sqldb.DB, publisher Publisher, options *Options) (*Replayer, error) {
+	if publisher == nil {
+		return nil, ErrPublisherNil
+	}
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.Columns.ID == "" {
+		opts.Columns.ID = "id"
+	}
+	if opts.Columns.AggregateID == "" {
+		opts.Columns.AggregateID = "aggregate_id"
+	}
+	if opts.Columns.Topic == "" {
+		opts.Columns.Topic = "topic"
+	}
+	if opts.Columns.Payload == "" {
+		opts.Columns.Payload = "payload"
+	}
+	if opts.Columns.CreatedAt == "" {
+		opts.Columns.CreatedAt = "created_at"
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > sqldb.MaxPageLimit {
+		opts.BatchSize = DefaultBatchSize
+	}
+	for _, name := range []string{opts.Table, opts.Columns.ID, opts.Columns.AggregateID, opts.Columns.Topic, opts.Columns.Payload, opts.Columns.CreatedAt} {
+		if err := sqldb.ValidateIdentifier(name); err != nil {
+			return nil, err
+		}
+	}
+
+	r := Replayer{
+		db:        db,
+		publisher: publisher,
+		opts:      opts,
+		keyset: sqldb.Keyset{
+			Keys:  []sqldb.SortKey{sqldb.Asc(opts.Columns.CreatedAt), sqldb.Asc(opts.Columns.ID)},
+			Limit: opts.BatchSize,
+		},
+	}
+	return &r, nil
+}
+
+func (req Request) validate() error {
+	if req.AggregateID == "" && (req.From.IsZero() || req.To.IsZero()) {
+		return ErrFilterEmpty
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To) {
+		return ErrTimeRange
+	}
+	return nil
+}
+
+// Replay publish the events of the request one by one in the order of created_at and id
+// so the events of the same aggregate are received in the order they are written
+// the replay stop at the first publish error, and the result has the cursor of the last published event to continue the replay
+func (r *Replayer) Replay(ctx context.Context, req Request) (Result, error) {
+	result := Result{DryRun: req.DryRun, Topics: make(map[string]int)}
+	if err := req.validate(); err != nil {
+		return result, err
+	}
+
+	cursor := req.Cursor
+	for {
+		events, next, err := r.Events(ctx, req, cursor)
+		if err != nil {
+			return result, err
+		}
+		for _, event := range events {
+			if req.Limit > 0 && result.Replayed >= req.Limit {
+				return result, nil
+			}
+			if !req.DryRun {
+				topic := event.Topic
+				if req.TargetTopic != "" {
+					topic = req.TargetTopic
+				}
+				if err := r.publisher.Publish(ctx, topic, event.Payload); err != nil {
+					return result, fmt.Errorf("replay: failed to publish event %s to %s: %w", event.ID, topic, err)
+				}
+			}
+			eventCursor, err := event.Cursor()
+			if err != nil {
+				return result, err
+			}
+			if result.Replayed == 0 {
+				result.First = event.CreatedAt
+			}
+			result.Replayed++
+			result.Topics[event.Topic]++
+			result.Last = event.CreatedAt
+			result.Cursor = eventCursor
+		}
+		if next == "" {
+			return result, nil
+		}
+		cursor = next
+	}
+}
+
+// Events return one batch of the events of the request after the cursor, and the cursor of the next batch
+// empty cursor is returned when there is no next batch
+func (r *Replayer) Events(ctx context.Context, req Request, cursor string) ([]Event, string, error) {
+	where, err := r.keyset.Where(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	columns := r.opts.Columns
+	conditions := []string{where.SQL}
+	args := append([]interface{}(nil), where.Args...)
+	if !req.From.IsZero() {
+		conditions = append(conditions, columns.CreatedAt+" >= ?")
+		args = append(args, req.From)
+	}
+	if !req.To.IsZero() {
+		conditions = append(conditions, columns.CreatedAt+" < ?")
+		args = append(args, req.To)
+	}
+	if req.AggregateID != "" {
+		conditions = append(conditions, columns.AggregateID+" = ?")
+		args = append(args, req.AggregateID)
+	}
+	query := fmt.Sprintf("SELECT %s, %s, %s, %s, %s FROM %s WHERE %s",
+		columns.ID, columns.AggregateID, columns.Topic, columns.Payload, columns.CreatedAt, r.opts.Table, strings.Join(conditions, " AND "))
+	if len(req.Topics) > 0 {
+		query += " AND " + columns.Topic + " IN (?)"
+		args = append(args, req.Topics)
+		query, args, err = sqlx.In(query, args...)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	query = r.db.Rebind(query + " " + r.keyset.Suffix())
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("replay: failed to select events of %s: %w", r.opts.Table, err)
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		event := Event{}
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.AggregateID, &event.Topic, &payload, &event.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	fetched := len(events)
+	events = events[:r.keyset.Trim(fetched)]
+	if len(events) == 0 {
+		return events, "", nil
+	}
+	last := events[len(events)-1]
+	next, err := r.keyset.Next(fetched, last.CreatedAt, last.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return events, next, nil
+}
@@ -0,0 +1,219 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+type published struct {
+	topic   string
+	payload string
+}
+
+func newTestReplayer(t *testing.T, publisher Publisher, options *Options) *Replayer {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE TABLE outbox (id INTEGER PRIMARY KEY, aggregate_id TEXT, topic TEXT, payload TEXT, created_at DATETIME)"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	events := []struct {
+		id        int
+		aggregate string
+		topic     string
+		minute    int
+	}{
+		// id 3 is written in the same time as id 2, and id 4 is written before id 3
+		{1, "order-1", "order.created", 0},
+		{2, "order-2", "order.created", 1},
+		{3, "order-1", "order.paid", 1},
+		{4, "order-2", "order.paid", 0},
+		{5, "order-1", "order.shipped", 10},
+	}
+	for _, e := range events {
+		payload := `{"id": "` + e.aggregate + `"}`
+		if _, err := db.ExecContext(ctx, "INSERT INTO outbox VALUES (?, ?, ?, ?, ?)", e.id, e.aggregate, e.topic, payload, start.Add(time.Minute*time.Duration(e.minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := New(db, publisher, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReplay(t *testing.T) {
+	var got []published
+	publisher := PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+		got = append(got, published{topic: topic, payload: string(payload)})
+		return nil
+	})
+	// small batch to replay with more than one query
+	r := newTestReplayer(t, publisher, &Options{BatchSize: 2})
+	from := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		req    Request
+		expect []string
+	}{
+		{
+			name:   "time range",
+			req:    Request{From: from, To: from.Add(time.Minute * 5)},
+			expect: []string{"order.created", "order.paid", "order.created", "order.paid"},
+		},
+		{
+			name:   "aggregate",
+			req:    Request{AggregateID: "order-1"},
+			expect: []string{"order.created", "order.paid", "order.shipped"},
+		},
+		{
+			name:   "topics",
+			req:    Request{From: from, To: from.Add(time.Hour), Topics: []string{"order.paid", "order.shipped"}},
+			expect: []string{"order.paid", "order.paid", "order.shipped"},
+		},
+		{
+			name:   "target topic",
+			req:    Request{AggregateID: "order-2", TargetTopic: "order.rebuild"},
+			expect: []string{"order.rebuild", "order.rebuild"},
+		},
+		{
+			name:   "dry run",
+			req:    Request{AggregateID: "order-2", DryRun: true},
+			expect: nil,
+		},
+	}
+
+	for _, c := range cases {
+		got = nil
+		result, err := r.Replay(context.Background(), c.req)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		topics := make([]string, len(got))
+		for idx, p := range got {
+			topics[idx] = p.topic
+		}
+		if strings.Join(topics, ",") != strings.Join(c.expect, ",") {
+			t.Fatalf("%s: expecting %v but got %v", c.name, c.expect, topics)
+		}
+		if !c.req.DryRun && result.Replayed != len(c.expect) {
+			t.Fatalf("%s: expecting %d replayed but got %d", c.name, len(c.expect), result.Replayed)
+		}
+	}
+	if got != nil {
+		t.Fatalf("expecting no published event in dry run but got %v", got)
+	}
+}
+
+func TestReplayContinue(t *testing.T) {
+	errPublish := errors.New("nsqd is down")
+	var (
+		got  []string
+		down = true
+	)
+	publisher := PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+		if topic == "order.paid" && down {
+			return errPublish
+		}
+		got = append(got, topic)
+		return nil
+	})
+	r := newTestReplayer(t, publisher, nil)
+
+	result, err := r.Replay(context.Background(), Request{AggregateID: "order-1"})
+	if !errors.Is(err, errPublish) {
+		t.Fatalf("expecting error %v but got %v", errPublish, err)
+	}
+	if result.Replayed != 1 || result.Cursor == "" {
+		t.Fatalf("expecting 1 replayed event with cursor but got %+v", result)
+	}
+
+	// the replay continue from the failed event
+	down = false
+	result, err = r.Replay(context.Background(), Request{AggregateID: "order-1", Cursor: result.Cursor, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Replayed != 1 || strings.Join(got, ",") != "order.created,order.paid" {
+		t.Fatalf("expecting order.paid is replayed but got %v", got)
+	}
+}
+
+func TestReplayInvalidRequest(t *testing.T) {
+	r := newTestReplayer(t, PublisherFunc(func(ctx context.Context, topic string, payload []byte) error {
+		return nil
+	}), nil)
+	now := time.Now()
+
+	if _, err := r.Replay(context.Background(), Request{From: now}); !errors.Is(err, ErrFilterEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrFilterEmpty, err)
+	}
+	if _, err := r.Replay(context.Background(), Request{From: now, To: now.Add(-time.Hour)}); !errors.Is(err, ErrTimeRange) {
+		t.Fatalf("expecting error %v but got %v", ErrTimeRange, err)
+	}
+	if _, err := New(nil, nil, nil); !errors.Is(err, ErrPublisherNil) {
+		t.Fatalf("expecting error %v but got %v", ErrPublisherNil, err)
+	}
+	if _, err := New(nil, PublisherFunc(nil), &Options{Table: "outbox; DROP TABLE users"}); !errors.Is(err, sqldb.ErrInvalidIdentifier) {
+		t.Fatalf("expecting error %v but got %v", sqldb.ErrInvalidIdentifier, err)
+	}
+}
+
+type orderEvent struct {
+	ID string `json:"id"`
+}
+
+func TestBusHandler(t *testing.T) {
+	bus := eventbus.New(nil)
+	defer bus.Close()
+	topic := eventbus.NewTopic("order.created", orderEvent{})
+	var got []string
+	_, err := bus.Subscribe(topic, func(ctx context.Context, event eventbus.Event) error {
+		got = append(got, event.Payload.(orderEvent).ID)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := newTestReplayer(t, Bus(bus, topic), nil).Handler()
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"aggregate_id": "order-2", "topics": ["order.created"]}`, http.StatusOK},
+		// order.paid is not registered to the bus publisher
+		{`{"aggregate_id": "order-2"}`, http.StatusInternalServerError},
+		{`{"dry_run": true}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/outbox/replay", strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Fatalf("%s: expecting status %d but got %d: %s", c.body, c.status, w.Code, w.Body.String())
+		}
+	}
+	// order.paid of order-2 is written first, so the second request fail before order.created is published
+	if strings.Join(got, ",") != "order-2" {
+		t.Fatalf("expecting order-2 is published to the bus but got %v", got)
+	}
+}
@@ -0,0 +1,306 @@
+// Package graceful provide listeners which can be inherited by a new process
+// this is used for zero downtime deployment on vm, where the new binary take over the listeners from the old one
+// the old process then stop accepting new connections, drain the in-flight requests and exit
+//
+// the listeners are passed to the new process as extra files, the address of each file is listed in GRACEFUL_LISTENERS
+//
+// address is parsed with ParseAddress, so the same config can be used to listen to:
+//
+//	localhost:8000            tcp address
+//	unix:/run/project.sock    unix domain socket
+//	systemd:admin             socket passed by systemd socket activation, by FileDescriptorName or index
+package graceful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// EnvListeners is the environment variable of inherited listeners address
+// the order of address is the order of file descriptor, starting from 3
+const EnvListeners = "GRACEFUL_LISTENERS"
+
+// list of network
+const (
+	NetworkTCP     = "tcp"
+	NetworkUnix    = "unix"
+	NetworkSystemd = "systemd"
+)
+
+// list of error
+var (
+	ErrReusePortNotSupported = errors.New("graceful: SO_REUSEPORT is not supported in this platform")
+	ErrListenerNotSupported  = errors.New("graceful: listener cannot be inherited")
+	ErrSystemdNotFound       = errors.New("graceful: systemd socket not found")
+)
+
+// ParseAddress return the network and address of the listener address
+// address without unix: or systemd: prefix is treated as tcp address
+func ParseAddress(address string) (network, addr string) {
+	for _, network := range []string{NetworkUnix, NetworkSystemd} {
+		if strings.HasPrefix(address, network+":") {
+			return network, strings.TrimPrefix(address, network+":")
+		}
+	}
+	return NetworkTCP, address
+}
+
+// key of the listener, the key is also used as address in GRACEFUL_LISTENERS
+func key(network, address string) string {
+	if network == NetworkTCP {
+		return address
+	}
+	return network + ":" + address
+}
+
+// Options of listeners
+type Options struct {
+	// ReusePort set SO_REUSEPORT to the new listener, so more than one process can listen to the same address
+	ReusePort bool
+}
+
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// Listeners of the process
+type Listeners struct {
+	options Options
+
+	mu        sync.Mutex
+	inherited map[string]net.Listener
+	systemd   map[string]net.Listener
+	active    map[string]net.Listener
+	// order of active listeners address, to keep the order of file descriptor stable
+	addresses []string
+	// fromParent is true when listeners are inherited from parent process
+	fromParent bool
+}
+
+// New listeners, inherit listeners from the parent process if available
+func New(options *Options) (*Listeners, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	l := Listeners{
+		options:   opts,
+		inherited: make(map[string]net.Listener),
+		active:    make(map[string]net.Listener),
+	}
+	if err := l.inherit(); err != nil {
+		return nil, err
+	}
+	systemd, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	l.systemd = systemd
+	return &l, nil
+}
+
+func (l *Listeners) inherit() error {
+	env := os.Getenv(EnvListeners)
+	if env == "" {
+		return nil
+	}
+	// make sure the listeners is not inherited again by our own child process
+	os.Unsetenv(EnvListeners)
+
+	for i, address := range strings.Split(env, ",") {
+		f := os.NewFile(uintptr(3+i), address)
+		if f == nil {
+			return fmt.Errorf("graceful: invalid file descriptor for %s", address)
+		}
+		ln, err := net.FileListener(f)
+		// FileListener duplicate the file descriptor, so the file can be closed
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("graceful: failed to inherit listener %s: %w", address, err)
+		}
+		l.inherited[address] = ln
+	}
+	l.fromParent = true
+	return nil
+}
+
+// ListenAddress parse the address with ParseAddress and announce on it
+func (l *Listeners) ListenAddress(address string) (net.Listener, error) {
+	network, addr := ParseAddress(address)
+	return l.Listen(network, addr)
+}
+
+// Listen announce on the address, inherited listener with the same address is returned when available
+// for systemd network, the address is the name or index of the socket passed by systemd
+func (l *Listeners) Listen(network, address string) (net.Listener, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	k := key(network, address)
+	if ln, ok := l.inherited[k]; ok {
+		delete(l.inherited, k)
+		l.setActive(k, ln)
+		return ln, nil
+	}
+
+	if network == NetworkSystemd {
+		ln, ok := l.systemd[address]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrSystemdNotFound, address)
+		}
+		l.setActive(k, ln)
+		return ln, nil
+	}
+
+	lc := net.ListenConfig{}
+	if l.options.ReusePort && network != NetworkUnix {
+		lc.Control = reusePort
+	}
+	if network == NetworkUnix {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := lc.Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+	l.setActive(k, ln)
+	return ln, nil
+}
+
+// removeStaleSocket remove the socket file left by a process which is not exited properly
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("graceful: %s is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+func (l *Listeners) setActive(address string, ln net.Listener) {
+	if _, ok := l.active[address]; !ok {
+		l.addresses = append(l.addresses, address)
+	}
+	l.active[address] = ln
+}
+
+// Inherited return true if the process inherit listeners from the parent process
+func (l *Listeners) Inherited() bool {
+	return l.fromParent
+}
+
+// Close inherited listeners which are not used by the process
+func (l *Listeners) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for address, ln := range l.inherited {
+		ln.Close()
+		delete(l.inherited, address)
+	}
+	return nil
+}
+
+// Restart start a new process of the same binary and arguments with all active listeners
+// the caller should shutdown its servers after the new process is started
+func (l *Listeners) Restart() (*os.Process, error) {
+	l.mu.Lock()
+	var (
+		files     = make([]*os.File, 0, len(l.addresses))
+		addresses = make([]string, 0, len(l.addresses))
+	)
+	for _, address := range l.addresses {
+		fl, ok := l.active[address].(fileListener)
+		if !ok {
+			l.mu.Unlock()
+			closeFiles(files)
+			return nil, fmt.Errorf("%w: %s", ErrListenerNotSupported, address)
+		}
+		// the socket file must not be removed when the old process close the listener
+		if ul, ok := l.active[address].(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		f, err := fl.File()
+		if err != nil {
+			l.mu.Unlock()
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+		addresses = append(addresses, address)
+	}
+	l.mu.Unlock()
+	// the files are duplicated into the child process
+	defer closeFiles(files)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", EnvListeners, strings.Join(addresses, ",")))
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("graceful: failed to start new process: %w", err)
+	}
+	return cmd.Process, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+var (
+	defaultListeners     *Listeners
+	defaultListenersErr  error
+	defaultListenersOnce sync.Once
+	defaultOptions       Options
+)
+
+// Configure the options of default listeners, must be called before the first Listen
+func Configure(options Options) {
+	defaultOptions = options
+}
+
+// Default return the default listeners of the process
+func Default() (*Listeners, error) {
+	defaultListenersOnce.Do(func() {
+		defaultListeners, defaultListenersErr = New(&defaultOptions)
+	})
+	return defaultListeners, defaultListenersErr
+}
+
+// Listen announce on the address using the default listeners
+func Listen(network, address string) (net.Listener, error) {
+	l, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return l.Listen(network, address)
+}
+
+// ListenAddress parse the address and announce on it using the default listeners
+func ListenAddress(address string) (net.Listener, error) {
+	l, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return l.ListenAddress(address)
+}
@@ -0,0 +1,115 @@
+package graceful
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	l, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Inherited() {
+		t.Fatal("listeners should not be inherited")
+	}
+
+	ln, err := l.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if len(l.addresses) != 1 {
+		t.Fatalf("expecting 1 active listener but got %d", len(l.addresses))
+	}
+	if _, ok := ln.(fileListener); !ok {
+		t.Fatal("tcp listener should be able to be inherited")
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	l, err := New(&Options{ReusePort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := l.Listen("tcp", "127.0.0.1:0")
+	if errors.Is(err, ErrReusePortNotSupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// another listener should be able to listen to the same address
+	ln2, err := l.Listen("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln2.Close()
+}
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{address: "localhost:8000", network: NetworkTCP, addr: "localhost:8000"},
+		{address: ":8000", network: NetworkTCP, addr: ":8000"},
+		{address: "unix:/tmp/project.sock", network: NetworkUnix, addr: "/tmp/project.sock"},
+		{address: "systemd:admin", network: NetworkSystemd, addr: "admin"},
+	}
+
+	for _, c := range cases {
+		network, addr := ParseAddress(c.address)
+		if network != c.network || addr != c.addr {
+			t.Errorf("%s: expecting %s %s but got %s %s", c.address, c.network, c.addr, network, addr)
+		}
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "graceful")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "project.sock")
+
+	l, err := New(&Options{ReusePort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := l.ListenAddress("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulate stale socket left by a killed process
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	ln, err = l.ListenAddress("unix:" + path)
+	if err != nil {
+		t.Fatalf("stale socket should be removed: %v", err)
+	}
+	defer ln.Close()
+	if _, ok := l.active["unix:"+path]; !ok {
+		t.Fatal("unix listener should be active")
+	}
+}
+
+func TestListenSystemdNotFound(t *testing.T) {
+	l, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ListenAddress("systemd:admin"); !errors.Is(err, ErrSystemdNotFound) {
+		t.Fatalf("expecting error %v but got %v", ErrSystemdNotFound, err)
+	}
+}
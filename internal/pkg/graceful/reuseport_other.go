@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package graceful
+
+import (
+	"syscall"
+)
+
+// reusePort is not supported in this platform
+func reusePort(network, address string, conn syscall.RawConn) error {
+	return ErrReusePortNotSupported
+}
@@ -0,0 +1,52 @@
+package graceful
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// list of systemd socket activation environment variable
+const (
+	envListenPID     = "LISTEN_PID"
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+)
+
+// systemdListeners return listeners passed by systemd socket activation
+// the listener is keyed by its FileDescriptorName and its index, both can be used in systemd:<name> address
+func systemdListeners() (map[string]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv(envListenPID))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	var names []string
+	if env := os.Getenv(envListenFDNames); env != "" {
+		names = strings.Split(env, ":")
+	}
+	// the sockets should not be passed again to our own child process
+	os.Unsetenv(envListenPID)
+	os.Unsetenv(envListenFDs)
+	os.Unsetenv(envListenFDNames)
+
+	listeners := make(map[string]net.Listener)
+	for i := 0; i < count; i++ {
+		f := os.NewFile(uintptr(3+i), fmt.Sprintf("systemd:%d", i))
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("graceful: failed to inherit systemd socket %d: %w", i, err)
+		}
+		listeners[strconv.Itoa(i)] = ln
+		if i < len(names) && names[i] != "" {
+			listeners[names[i]] = ln
+		}
+	}
+	return listeners, nil
+}
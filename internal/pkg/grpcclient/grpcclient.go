@@ -0,0 +1,156 @@
+// Package grpcclient create grpc client connection from configuration
+// the connection is traced with opencensus, the same as http client and server
+package grpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"go.opencensus.io/plugin/ocgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// list of load balancing policy
+const (
+	PolicyPickFirst  = "pick_first"
+	PolicyRoundRobin = "round_robin"
+)
+
+// list of error
+var (
+	ErrTargetEmpty       = errors.New("grpcclient: target is empty")
+	ErrPolicyNotFound    = errors.New("grpcclient: load balancing policy not found")
+	ErrCertificateKeyNil = errors.New("grpcclient: tls cert_file and key_file must be set together")
+	ErrCAInvalid         = errors.New("grpcclient: failed to append ca certificate")
+)
+
+// TLSConfig of client connection
+type TLSConfig struct {
+	Enabled bool
+	// CAFile is used to verify the server certificate, system pool is used when empty
+	CAFile string
+	// CertFile and KeyFile are used for mutual tls
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// KeepaliveConfig of client connection
+type KeepaliveConfig struct {
+	// Time after which client ping the server when there is no activity, keepalive is disabled when zero
+	Time time.Duration
+	// Timeout to wait for ping ack before the connection is closed
+	Timeout             time.Duration
+	PermitWithoutStream bool
+}
+
+// Config of grpc client connection
+type Config struct {
+	// Target of the connection, for example dns:///user-service:9000
+	Target    string
+	TLS       TLSConfig
+	Keepalive KeepaliveConfig
+	// LoadBalancingPolicy pick_first|round_robin, default to pick_first
+	LoadBalancingPolicy string
+	// Block until the connection is ready, the dial is bounded by DialTimeout
+	Block       bool
+	DialTimeout time.Duration
+}
+
+// Validate the configuration without dialing the target
+func (c Config) Validate() error {
+	if c.Target == "" {
+		return ErrTargetEmpty
+	}
+	switch c.LoadBalancingPolicy {
+	case "", PolicyPickFirst, PolicyRoundRobin:
+	default:
+		return fmt.Errorf("%w: %s", ErrPolicyNotFound, c.LoadBalancingPolicy)
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return ErrCertificateKeyNil
+	}
+	return nil
+}
+
+// Dial create a new client connection
+func Dial(ctx context.Context, config *Config) (*grpc.ClientConn, error) {
+	conf := Config{}
+	if config != nil {
+		conf = *config
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithStatsHandler(&ocgrpc.ClientHandler{}),
+	}
+	if conf.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	if conf.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                conf.Keepalive.Time,
+			Timeout:             conf.Keepalive.Timeout,
+			PermitWithoutStream: conf.Keepalive.PermitWithoutStream,
+		}))
+	}
+	if conf.LoadBalancingPolicy != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, conf.LoadBalancingPolicy)))
+	}
+	if conf.Block {
+		opts = append(opts, grpc.WithBlock())
+		if conf.DialTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, conf.DialTimeout)
+			defer cancel()
+		}
+	}
+
+	conn, err := grpc.DialContext(ctx, conf.Target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("grpcclient: failed to dial %s: %w", conf.Target, err)
+	}
+	return conn, nil
+}
+
+func newTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, ErrCAInvalid
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &tlsConfig, nil
+}
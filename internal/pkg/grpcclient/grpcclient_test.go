@@ -0,0 +1,58 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{
+			name:   "valid",
+			config: Config{Target: "localhost:9000", LoadBalancingPolicy: PolicyRoundRobin},
+		},
+		{
+			name:   "empty target",
+			config: Config{},
+			err:    ErrTargetEmpty,
+		},
+		{
+			name:   "unknown policy",
+			config: Config{Target: "localhost:9000", LoadBalancingPolicy: "random"},
+			err:    ErrPolicyNotFound,
+		},
+		{
+			name:   "cert without key",
+			config: Config{Target: "localhost:9000", TLS: TLSConfig{CertFile: "cert.pem"}},
+			err:    ErrCertificateKeyNil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.config.Validate()
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestDialNonBlocking(t *testing.T) {
+	// non blocking dial should not wait for the server
+	conn, err := Dial(context.Background(), &Config{
+		Target:              "localhost:0",
+		LoadBalancingPolicy: PolicyRoundRobin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
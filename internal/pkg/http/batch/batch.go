@@ -0,0 +1,249 @@
+// Package batch serve many api requests in one http request, for clients on high latency connection
+// every sub-request is served by the handler, usually the router, so it pass the same middlewares and handlers as a normal request
+//
+//	r.Post("/v1/batch", batch.New(r, nil).Handle)
+//
+// the request body is a list of sub-requests
+//
+//	{"requests": [{"id": "user", "method": "GET", "path": "/v1/users/1"}, {"id": "order", "method": "POST", "path": "/v1/orders", "body": {"item": 1}}]}
+//
+// and the response has the result of every sub-request in the same order
+//
+//	{"status": "OK", "data": {"responses": [{"id": "user", "status": 200, "headers": {...}, "body": {...}}, ...]}}
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/albertwidi/go-project-example/internal/xerrors"
+)
+
+// list of error
+var (
+	ErrEmpty           = errors.New("batch: requests is empty")
+	ErrTooManyRequests = errors.New("batch: too many requests")
+	ErrInvalidPath     = errors.New("batch: invalid path")
+	ErrInvalidMethod   = errors.New("batch: invalid method")
+	ErrNested          = errors.New("batch: batch request cannot be nested")
+)
+
+// headers which are not copied from the batch request to sub-request
+var skipHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+	"If-None-Match":     true,
+	"If-Modified-Since": true,
+}
+
+// Request is one sub-request of batch
+type Request struct {
+	// ID of the request, returned in the response to match the result
+	ID string `json:"id"`
+	// Method of the request, default to GET
+	Method string `json:"method"`
+	// Path of the request including query, for example /v1/users?limit=10
+	Path string `json:"path"`
+	// Headers of the request, the headers of batch request is used when not set, for example Authorization
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Response is the result of one sub-request
+type Response struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the json body, or json string when the body is not json
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Requests of batch
+type Requests struct {
+	Requests []Request `json:"requests"`
+}
+
+// Responses of batch
+type Responses struct {
+	Responses []Response `json:"responses"`
+}
+
+// Options of batch handler
+type Options struct {
+	// MaxRequests in one batch, default to 20
+	MaxRequests int
+	// Concurrency of sub-requests, default to 5
+	Concurrency int
+}
+
+// Handler of batch request
+type Handler struct {
+	handler http.Handler
+	opts    Options
+}
+
+// New batch handler, sub-requests are served with the handler
+func New(handler http.Handler, options *Options) *Handler {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MaxRequests <= 0 {
+		opts.MaxRequests = 20
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 5
+	}
+
+	h := Handler{
+		handler: handler,
+		opts:    opts,
+	}
+	return &h
+}
+
+// Handle the batch request
+func (h *Handler) Handle(rctx *requestcontext.RequestContext) error {
+	const op xerrors.Op = "batch/handle"
+
+	reqs := Requests{}
+	if err := rctx.DecodeJSON(&reqs); err != nil {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, err))
+	}
+	if len(reqs.Requests) == 0 {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, ErrEmpty))
+	}
+	if len(reqs.Requests) > h.opts.MaxRequests {
+		err := fmt.Errorf("%w: maximum is %d", ErrTooManyRequests, h.opts.MaxRequests)
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, err))
+	}
+
+	resps := Responses{Responses: h.serve(rctx.Context(), rctx.Request(), reqs.Requests)}
+	_, err := rctx.JSON().Data(resps).Write()
+	return err
+}
+
+// serve all sub-requests with limited concurrency, the responses is in the same order as requests
+func (h *Handler) serve(ctx context.Context, parent *http.Request, reqs []Request) []Response {
+	var (
+		resps  = make([]Response, len(reqs))
+		served = make([]bool, len(reqs))
+	)
+	err := concurrent.Map(ctx, len(reqs), h.opts.Concurrency, func(ctx context.Context, i int) error {
+		// the panic of a sub-request is its error response, so the other sub-requests are still served
+		err := safego.Run(ctx, "batch.serve", func(ctx context.Context) error {
+			resps[i] = h.serveOne(ctx, parent, reqs[i])
+			return nil
+		})
+		if err != nil {
+			resps[i] = errorResponse(reqs[i].ID, xerrors.KindInternalError, err)
+		}
+		served[i] = true
+		return nil
+	})
+	if err == nil {
+		// Map doesn't return the error when ctx is done before the sub-requests are started
+		err = ctx.Err()
+	}
+	if err != nil {
+		// the rest of sub-requests are not served when the batch request is canceled
+		for idx := range reqs {
+			if !served[idx] {
+				resps[idx] = errorResponse(reqs[idx].ID, xerrors.KindInternalError, err)
+			}
+		}
+	}
+	return resps
+}
+
+func (h *Handler) serveOne(ctx context.Context, parent *http.Request, r Request) Response {
+	req, err := newRequest(ctx, parent, r)
+	if err != nil {
+		return errorResponse(r.ID, xerrors.KindBadRequest, err)
+	}
+	rec := newRecorder()
+	h.handler.ServeHTTP(rec, req)
+	return rec.response(r.ID)
+}
+
+// newRequest create the sub-request, the request keep the context, remote address and headers of the batch request
+func newRequest(ctx context.Context, parent *http.Request, r Request) (*http.Request, error) {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMethod, r.Method)
+	}
+	// only relative path is allowed, so the sub-request cannot go to another host
+	if !strings.HasPrefix(r.Path, "/") || strings.HasPrefix(r.Path, "//") {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, r.Path)
+	}
+
+	req, err := http.NewRequest(method, r.Path, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+	if req.URL.Path == parent.URL.Path {
+		return nil, ErrNested
+	}
+	req = req.WithContext(ctx)
+	req.Host = parent.Host
+	req.RemoteAddr = parent.RemoteAddr
+	req.Proto, req.ProtoMajor, req.ProtoMinor = parent.Proto, parent.ProtoMajor, parent.ProtoMinor
+
+	for key, values := range parent.Header {
+		if skipHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if len(r.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// errorResponse write the error as json response of the sub-request
+func errorResponse(id string, kind xerrors.Kind, err error) Response {
+	const op xerrors.Op = "batch/serve"
+
+	message := err.Error()
+	if kind == xerrors.KindInternalError {
+		message = "internal error"
+	}
+	rec := newRecorder()
+	response.JSON(rec).Error(xerrors.New(op, kind, err), &response.JSONError{
+		Title:   "Batch Request Failed",
+		Message: message,
+	}).Write()
+	return rec.response(id)
+}
+
+func (h *Handler) writeError(rctx *requestcontext.RequestContext, err error) error {
+	_, werr := rctx.JSON().Error(err, &response.JSONError{
+		Title:   "Batch Request Failed",
+		Message: err.Error(),
+	}).Write()
+	if werr != nil {
+		return werr
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package batch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/batch"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/gorilla/mux"
+)
+
+type batchResponse struct {
+	Data batch.Responses `json:"data"`
+}
+
+func newTestRouter(t *testing.T, opts *batch.Options) (*router.Router, *int32) {
+	t.Helper()
+	var (
+		inFlight    int32
+		maxInFlight int32
+	)
+	r := router.New(":0", nil)
+	// middleware is applied to sub-requests
+	r.Use(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			rctx.ResponseWriter().Header().Set("X-Middleware", "1")
+			return next(rctx)
+		}
+	})
+	r.Get("/v1/users/{id}", func(rctx *requestcontext.RequestContext) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			current := atomic.LoadInt32(&maxInFlight)
+			if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+
+		_, err := rctx.JSON().Data(map[string]string{
+			"id":   mux.Vars(rctx.Request())["id"],
+			"auth": rctx.RequestHeader().Get("Authorization"),
+		}).Write()
+		return err
+	})
+	r.Post("/v1/echo", func(rctx *requestcontext.RequestContext) error {
+		body := map[string]interface{}{}
+		if err := rctx.DecodeJSON(&body); err != nil {
+			return err
+		}
+		_, err := rctx.JSON().Data(body).Write()
+		return err
+	})
+	r.Get("/v1/text", func(rctx *requestcontext.RequestContext) error {
+		_, err := rctx.ResponseWriter().Write([]byte("plain text"))
+		return err
+	})
+	r.Get("/v1/panic", func(rctx *requestcontext.RequestContext) error {
+		panic("boom")
+	})
+	r.Post("/v1/batch", batch.New(r, opts).Handle)
+	return r, &maxInFlight
+}
+
+func doBatch(t *testing.T, r http.Handler, reqs []batch.Request) (int, batch.Responses) {
+	t.Helper()
+	body, err := json.Marshal(batch.Requests{Requests: reqs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/batch", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	resp := batchResponse{}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, resp.Data
+}
+
+func TestBatch(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	code, resp := doBatch(t, r, []batch.Request{
+		{ID: "user", Path: "/v1/users/1"},
+		{ID: "echo", Method: "POST", Path: "/v1/echo", Body: json.RawMessage(`{"name":"test"}`)},
+		{ID: "text", Path: "/v1/text"},
+		{ID: "not_found", Path: "/v1/unknown"},
+		{ID: "absolute", Path: "http://example.com/v1/users/1"},
+		{ID: "nested", Method: "POST", Path: "/v1/batch"},
+		{ID: "method", Method: "CONNECT", Path: "/v1/users/1"},
+		{ID: "panic", Path: "/v1/panic"},
+		{ID: "override", Path: "/v1/users/2", Headers: map[string]string{"Authorization": "Bearer other"}},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expecting %d but got %d", http.StatusOK, code)
+	}
+
+	expect := []struct {
+		id     string
+		status int
+		body   string
+	}{
+		{id: "user", status: http.StatusOK, body: `{"status":"","data":{"auth":"Bearer token","id":"1"}}`},
+		{id: "echo", status: http.StatusOK, body: `{"status":"","data":{"name":"test"}}`},
+		{id: "text", status: http.StatusOK, body: `"plain text"`},
+		{id: "not_found", status: http.StatusNotFound},
+		{id: "absolute", status: http.StatusBadRequest},
+		{id: "nested", status: http.StatusBadRequest},
+		{id: "method", status: http.StatusBadRequest},
+		{id: "panic", status: http.StatusInternalServerError},
+		{id: "override", status: http.StatusOK, body: `{"status":"","data":{"auth":"Bearer other","id":"2"}}`},
+	}
+	if len(resp.Responses) != len(expect) {
+		t.Fatalf("expecting %d responses but got %d", len(expect), len(resp.Responses))
+	}
+	for idx, e := range expect {
+		got := resp.Responses[idx]
+		if got.ID != e.id || got.Status != e.status {
+			t.Errorf("expecting %s with status %d but got %s with status %d: %s", e.id, e.status, got.ID, got.Status, got.Body)
+			continue
+		}
+		if e.body != "" && string(got.Body) != e.body {
+			t.Errorf("%s: expecting body %s but got %s", e.id, e.body, got.Body)
+		}
+	}
+	if resp.Responses[0].Headers["X-Middleware"] != "1" {
+		t.Error("expecting sub-request to pass the middleware")
+	}
+}
+
+func TestBatchConcurrency(t *testing.T) {
+	r, maxInFlight := newTestRouter(t, &batch.Options{Concurrency: 2})
+
+	var reqs []batch.Request
+	for i := 0; i < 8; i++ {
+		reqs = append(reqs, batch.Request{ID: "user", Path: "/v1/users/1"})
+	}
+	if code, _ := doBatch(t, r, reqs); code != http.StatusOK {
+		t.Fatalf("expecting %d but got %d", http.StatusOK, code)
+	}
+	if n := atomic.LoadInt32(maxInFlight); n > 2 {
+		t.Errorf("expecting at most 2 concurrent sub-requests but got %d", n)
+	}
+}
+
+func TestBatchCanceled(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	body, err := json.Marshal(batch.Requests{Requests: []batch.Request{
+		{ID: "user1", Path: "/v1/users/1"},
+		{ID: "user2", Path: "/v1/users/2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/v1/batch", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	resp := batchResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// sub-requests which are not served still have the response with the id
+	if len(resp.Data.Responses) != 2 {
+		t.Fatalf("expecting 2 responses but got %d", len(resp.Data.Responses))
+	}
+	for idx, expect := range []string{"user1", "user2"} {
+		if got := resp.Data.Responses[idx]; got.ID != expect || got.Status != http.StatusInternalServerError {
+			t.Errorf("expecting response %s with status %d but got %s with status %d", expect, http.StatusInternalServerError, got.ID, got.Status)
+		}
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	r, _ := newTestRouter(t, &batch.Options{MaxRequests: 2})
+
+	cases := []struct {
+		name string
+		reqs []batch.Request
+	}{
+		{name: "empty", reqs: nil},
+		{name: "too many", reqs: []batch.Request{{Path: "/v1/users/1"}, {Path: "/v1/users/2"}, {Path: "/v1/users/3"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if code, _ := doBatch(t, r, c.reqs); code != http.StatusBadRequest {
+				t.Errorf("expecting %d but got %d", http.StatusBadRequest, code)
+			}
+		})
+	}
+}
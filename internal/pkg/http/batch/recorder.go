@@ -0,0 +1,64 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// recorder keep the response of sub-request in memory
+type recorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.status = status
+	rec.wroteHeader = true
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+// response return the recorded response, body which is not json is returned as json string
+func (rec *recorder) response(id string) Response {
+	resp := Response{
+		ID:     id,
+		Status: rec.status,
+	}
+	if len(rec.header) > 0 {
+		resp.Headers = make(map[string]string, len(rec.header))
+		for key := range rec.header {
+			resp.Headers[key] = strings.Join(rec.header[key], ", ")
+		}
+	}
+
+	body := rec.body.Bytes()
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		resp.Body = append(json.RawMessage(nil), body...)
+	default:
+		resp.Body, _ = json.Marshal(string(body))
+	}
+	return resp
+}
@@ -0,0 +1,448 @@
+// Package cache is a response cache middleware for idempotent GET requests
+// responses are cached in memory with least recently used eviction bounded by size, and optionally in redis to share the cache between instances
+//
+// the cache follow Cache-Control of the request and response:
+//   - response is cached for s-maxage or max-age, or the configured TTL when the response has none
+//   - response with no-store, no-cache, private or Set-Cookie is not cached
+//   - request with no-cache skip the lookup, and request with no-store skip the cache entirely
+//   - stale response is served for stale-while-revalidate duration while the response is refreshed in background
+//   - conditional request which match the ETag or Last-Modified of the cached response is answered with 304 Not Modified
+//
+// the cache is configured per route group, by creating a cache for each group:
+//
+//	catalog := cache.New(&cache.Options{TTL: time.Minute, Redis: rds})
+//	group := router.NewChainedMiddleware(r, catalog.Middleware())
+//	group.Get("/v1/products/{id}", handler)
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/misc"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/monitoring"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// HeaderCache tell whether the response is served from the cache
+const HeaderCache = "X-Cache"
+
+// list of X-Cache header value
+const (
+	StatusHit   = "HIT"
+	StatusMiss  = "MISS"
+	StatusStale = "STALE"
+)
+
+// Options of cache
+type Options struct {
+	// MaxBytes of the in memory cache, default to 64MB
+	MaxBytes int64
+	// MaxEntryBytes is the max body size of response to be cached, default to 1MB
+	MaxEntryBytes int64
+	// TTL of response without max-age or s-maxage, the response is not cached when TTL is zero
+	TTL time.Duration
+	// StaleWhileRevalidate is used when the response has no stale-while-revalidate directive
+	StaleWhileRevalidate time.Duration
+	// RevalidateTimeout of background revalidation, default to 10 seconds
+	RevalidateTimeout time.Duration
+	// Redis is the optional second tier of the cache, shared between instances
+	Redis redis.Redis
+	// RedisPrefix of the keys in redis, default to httpcache:
+	RedisPrefix string
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// Stats of the cache
+type Stats struct {
+	Hits        int64 `json:"hits"`
+	Misses      int64 `json:"misses"`
+	Stale       int64 `json:"stale"`
+	RedisErrors int64 `json:"redis_errors"`
+	// Entries and Bytes of the in memory cache
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Cache of http responses
+type Cache struct {
+	opts   Options
+	memory *lru
+
+	mu           sync.Mutex
+	revalidating map[string]struct{}
+
+	hits        int64
+	misses      int64
+	stale       int64
+	redisErrors int64
+}
+
+// New cache
+func New(options *Options) *Cache {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 64 << 20
+	}
+	if opts.MaxEntryBytes <= 0 {
+		opts.MaxEntryBytes = 1 << 20
+	}
+	if opts.RevalidateTimeout <= 0 {
+		opts.RevalidateTimeout = time.Second * 10
+	}
+	if opts.RedisPrefix == "" {
+		opts.RedisPrefix = "httpcache:"
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	c := Cache{
+		opts:         opts,
+		memory:       newLRU(opts.MaxBytes),
+		revalidating: make(map[string]struct{}),
+	}
+	return &c
+}
+
+// Stats return the statistics of the cache
+func (c *Cache) Stats() Stats {
+	entries, bytes := c.memory.stats()
+	return Stats{
+		Hits:        atomic.LoadInt64(&c.hits),
+		Misses:      atomic.LoadInt64(&c.misses),
+		Stale:       atomic.LoadInt64(&c.stale),
+		RedisErrors: atomic.LoadInt64(&c.redisErrors),
+		Entries:     entries,
+		Bytes:       bytes,
+	}
+}
+
+// Middleware serve GET requests from the cache, and cache the response of the handler
+func (c *Cache) Middleware() router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			req := rctx.Request()
+			if req.Method != http.MethodGet {
+				return next(rctx)
+			}
+			reqCC := parseCacheControl(req.Header)
+			if reqCC.has("no-store") {
+				return next(rctx)
+			}
+
+			primary := primaryKey(req)
+			if !reqCC.has("no-cache") {
+				now := c.opts.Now()
+				if e, key, ok := c.lookup(rctx.Context(), primary, req.Header); ok {
+					if e.isFresh(now) {
+						atomic.AddInt64(&c.hits, 1)
+						return serve(rctx.ResponseWriter(), req, e, now, StatusHit)
+					}
+					if e.isStale(now) {
+						atomic.AddInt64(&c.stale, 1)
+						c.revalidate(rctx, next, key)
+						return serve(rctx.ResponseWriter(), req, e, now, StatusStale)
+					}
+				}
+			}
+
+			atomic.AddInt64(&c.misses, 1)
+			rctx.ResponseWriter().Header().Set(HeaderCache, StatusMiss)
+			return c.fetch(rctx, next, primary)
+		}
+	}
+}
+
+// fetch call the handler and store the response when it is cacheable
+func (c *Cache) fetch(rctx *requestcontext.RequestContext, next router.HandlerFunc, primary string) error {
+	original := rctx.ResponseWriter()
+	capture := newCaptureWriter(original, c.opts.MaxEntryBytes)
+	rctx.SetResponseWriter(capture)
+	err := next(rctx)
+	rctx.SetResponseWriter(original)
+	if err != nil {
+		return err
+	}
+
+	c.store(rctx.Context(), primary, rctx.Request(), capture)
+	return nil
+}
+
+// revalidate refresh the entry in background, only one revalidation of the same key is running at a time
+// the request is cloned with the values but without the deadline of the original context, as the original request end before the revalidation
+// panic of the handler is recovered by safego, so it doesn't crash the process
+func (c *Cache) revalidate(rctx *requestcontext.RequestContext, next router.HandlerFunc, key string) {
+	c.mu.Lock()
+	if _, ok := c.revalidating[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.revalidating[key] = struct{}{}
+	c.mu.Unlock()
+
+	req := rctx.Request()
+	address, path := rctx.Address(), rctx.RequestHandler()
+
+	safego.Go(safego.Detach(req.Context()), "http/cache/revalidate", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, c.opts.RevalidateTimeout)
+		defer func() {
+			cancel()
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+
+		clone := req.Clone(ctx)
+		capture := newCaptureWriter(monitoring.NewResponseWriterDelegator(&discardWriter{header: http.Header{}}), c.opts.MaxEntryBytes)
+		revalidateCtx := requestcontext.New(requestcontext.Constructor{
+			HTTPResponseWriter: capture,
+			HTTPRequest:        clone,
+			Address:            address,
+			Path:               path,
+			Method:             misc.SanitizeMethod(clone.Method),
+		})
+		if err := next(revalidateCtx); err != nil {
+			return err
+		}
+		c.store(ctx, primaryKey(clone), clone, capture)
+		return nil
+	})
+}
+
+// store the response when it is cacheable
+func (c *Cache) store(ctx context.Context, primary string, req *http.Request, capture *captureWriter) {
+	if capture.overflow || !cacheableStatus[capture.status()] {
+		return
+	}
+	header := capture.Header()
+	respCC := parseCacheControl(header)
+	if respCC.has("no-store") || respCC.has("no-cache") || respCC.has("private") || header.Get("Set-Cookie") != "" {
+		return
+	}
+	// response of authorized request is only cached when it is explicitly shared
+	if req.Header.Get("Authorization") != "" && !respCC.has("public") && !respCC.has("s-maxage") {
+		return
+	}
+
+	ttl, ok := respCC.seconds("s-maxage")
+	if !ok {
+		ttl, ok = respCC.seconds("max-age")
+	}
+	if !ok {
+		ttl = c.opts.TTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	stale, ok := respCC.seconds("stale-while-revalidate")
+	if !ok {
+		stale = c.opts.StaleWhileRevalidate
+	}
+	vary, ok := parseVary(header)
+	if !ok {
+		return
+	}
+
+	now := c.opts.Now()
+	e := entry{
+		Status:     capture.status(),
+		Header:     header.Clone(),
+		Body:       capture.body.Bytes(),
+		StoredAt:   now,
+		Expires:    now.Add(ttl),
+		StaleUntil: now.Add(ttl + stale),
+	}
+	e.Header.Del(HeaderCache)
+	e.Header.Del("Age")
+
+	key := primary
+	if len(vary) > 0 {
+		varyEntry := entry{
+			Vary:       vary,
+			StoredAt:   e.StoredAt,
+			Expires:    e.Expires,
+			StaleUntil: e.StaleUntil,
+		}
+		c.set(ctx, primary, &varyEntry)
+		key = variantKey(primary, vary, req.Header)
+	}
+	c.set(ctx, key, &e)
+}
+
+// lookup return the entry of the request and its key
+func (c *Cache) lookup(ctx context.Context, primary string, header http.Header) (*entry, string, bool) {
+	e, ok := c.get(ctx, primary)
+	if !ok {
+		return nil, "", false
+	}
+	// status is not set in the vary list entry
+	if e.Status != 0 {
+		return e, primary, true
+	}
+	key := variantKey(primary, e.Vary, header)
+	e, ok = c.get(ctx, key)
+	return e, key, ok
+}
+
+// get the entry from memory then from redis, entry which is no longer usable is removed
+func (c *Cache) get(ctx context.Context, key string) (*entry, bool) {
+	now := c.opts.Now()
+	e, ok := c.memory.get(key)
+	if ok {
+		if now.Before(e.StaleUntil) {
+			return e, true
+		}
+		c.memory.delete(key)
+	}
+	if c.opts.Redis == nil {
+		return nil, false
+	}
+
+	value, err := c.opts.Redis.Get(ctx, c.opts.RedisPrefix+hashKey(key))
+	if err != nil {
+		if !c.opts.Redis.IsErrNil(err) {
+			atomic.AddInt64(&c.redisErrors, 1)
+		}
+		return nil, false
+	}
+	e = &entry{}
+	if err := json.Unmarshal([]byte(value), e); err != nil || !now.Before(e.StaleUntil) {
+		return nil, false
+	}
+	c.memory.set(key, e)
+	return e, true
+}
+
+// set the entry in memory and redis, the entry in redis expire when the entry is no longer usable
+func (c *Cache) set(ctx context.Context, key string, e *entry) {
+	c.memory.set(key, e)
+	if c.opts.Redis == nil {
+		return
+	}
+
+	value, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	expire := int(math.Ceil(e.StaleUntil.Sub(e.StoredAt).Seconds()))
+	if _, err := c.opts.Redis.SetEX(ctx, c.opts.RedisPrefix+hashKey(key), string(value), expire); err != nil {
+		atomic.AddInt64(&c.redisErrors, 1)
+	}
+}
+
+// serve write the entry as response, or 304 Not Modified when the client copy match the entry
+func serve(w http.ResponseWriter, req *http.Request, e *entry, now time.Time, status string) error {
+	header := w.Header()
+	// values are copied as the entry is shared between requests
+	for k, v := range e.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("Age", strconv.FormatInt(int64(now.Sub(e.StoredAt)/time.Second), 10))
+	header.Set(HeaderCache, status)
+	if e.Status == http.StatusOK {
+		lastModified, _ := http.ParseTime(e.Header.Get("Last-Modified"))
+		if response.CheckNotModified(w, req, e.Header.Get("ETag"), lastModified) {
+			return nil
+		}
+	}
+	w.WriteHeader(e.Status)
+	_, err := w.Write(e.Body)
+	return err
+}
+
+// captureWriter write the response to the underlying writer and keep the body to be cached
+type captureWriter struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	maxBytes int64
+	overflow bool
+	code     int
+	written  int64
+}
+
+func newCaptureWriter(w http.ResponseWriter, maxBytes int64) *captureWriter {
+	return &captureWriter{
+		ResponseWriter: w,
+		maxBytes:       maxBytes,
+	}
+}
+
+// WriteHeader via http.ResponseWriter
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.code == 0 {
+		cw.code = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Write the byte via http.ResponseWriter
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+	if !cw.overflow {
+		if int64(cw.body.Len()+len(b)) > cw.maxBytes {
+			cw.overflow = true
+			cw.body = bytes.Buffer{}
+		} else {
+			cw.body.Write(b)
+		}
+	}
+	n, err := cw.ResponseWriter.Write(b)
+	cw.written += int64(n)
+	return n, err
+}
+
+func (cw *captureWriter) status() int {
+	if cw.code == 0 {
+		return http.StatusOK
+	}
+	return cw.code
+}
+
+// Status return the status of the underlying delegator, so monitoring middleware still see the status
+func (cw *captureWriter) Status() int {
+	if d, ok := cw.ResponseWriter.(monitoring.Delegator); ok {
+		return d.Status()
+	}
+	return cw.code
+}
+
+// Written return the number of bytes written
+func (cw *captureWriter) Written() int64 {
+	if d, ok := cw.ResponseWriter.(monitoring.Delegator); ok {
+		return d.Written()
+	}
+	return cw.written
+}
+
+// discardWriter is the response writer of background revalidation
+type discardWriter struct {
+	header http.Header
+}
+
+func (dw *discardWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *discardWriter) WriteHeader(int) {}
+
+func (dw *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
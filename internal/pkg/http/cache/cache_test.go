@@ -0,0 +1,381 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/monitoring"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/alicebob/miniredis/v2"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (tc *testClock) Now() time.Time {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.now
+}
+
+func (tc *testClock) Add(d time.Duration) {
+	tc.mu.Lock()
+	tc.now = tc.now.Add(d)
+	tc.mu.Unlock()
+}
+
+// testHandler return the number of calls as the body, with the given response headers
+type testHandler struct {
+	calls  int64
+	header http.Header
+	status int
+	prefix string
+}
+
+func (th *testHandler) handle(rctx *requestcontext.RequestContext) error {
+	calls := atomic.AddInt64(&th.calls, 1)
+	w := rctx.ResponseWriter()
+	for k, v := range th.header {
+		w.Header()[k] = v
+	}
+	if th.status != 0 {
+		w.WriteHeader(th.status)
+	}
+	_, err := w.Write([]byte(th.prefix + strconv.FormatInt(calls, 10)))
+	return err
+}
+
+func do(h router.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	rctx := requestcontext.New(requestcontext.Constructor{
+		HTTPResponseWriter: monitoring.NewResponseWriterDelegator(recorder),
+		HTTPRequest:        req,
+		Path:               req.URL.Path,
+		Method:             req.Method,
+	})
+	h(rctx)
+	return recorder
+}
+
+func newRequest(method, url string, header map[string]string) *http.Request {
+	req := httptest.NewRequest(method, url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct {
+		name        string
+		options     Options
+		header      http.Header
+		status      int
+		prefix      string
+		requests    []*http.Request
+		expectCache []string
+		expectBody  []string
+	}{
+		{
+			name:        "cached with max-age",
+			header:      http.Header{"Cache-Control": {"max-age=60"}},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products?a=1&b=2", nil), newRequest(http.MethodGet, "/products?b=2&a=1", nil)},
+			expectCache: []string{StatusMiss, StatusHit},
+			expectBody:  []string{"1", "1"},
+		},
+		{
+			name:        "cached with default ttl",
+			options:     Options{TTL: time.Minute},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusHit},
+			expectBody:  []string{"1", "1"},
+		},
+		{
+			name:        "not cached without ttl",
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "different query",
+			options:     Options{TTL: time.Minute},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products?page=1", nil), newRequest(http.MethodGet, "/products?page=2", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "no-store response",
+			options:     Options{TTL: time.Minute},
+			header:      http.Header{"Cache-Control": {"no-store"}},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "private response",
+			header:      http.Header{"Cache-Control": {"private, max-age=60"}},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "set cookie response",
+			header:      http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"session=1"}},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "server error",
+			options:     Options{TTL: time.Minute},
+			status:      http.StatusInternalServerError,
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "request no-cache",
+			options:     Options{TTL: time.Minute},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", map[string]string{"Cache-Control": "no-cache"}), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss, StatusHit},
+			expectBody:  []string{"1", "2", "2"},
+		},
+		{
+			name:        "post is not cached",
+			options:     Options{TTL: time.Minute},
+			requests:    []*http.Request{newRequest(http.MethodPost, "/products", nil), newRequest(http.MethodPost, "/products", nil)},
+			expectCache: []string{"", ""},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:   "authorized request",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			requests: []*http.Request{
+				newRequest(http.MethodGet, "/products", map[string]string{"Authorization": "Bearer a"}),
+				newRequest(http.MethodGet, "/products", map[string]string{"Authorization": "Bearer b"}),
+			},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:   "vary",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}},
+			requests: []*http.Request{
+				newRequest(http.MethodGet, "/products", map[string]string{"Accept-Language": "id"}),
+				newRequest(http.MethodGet, "/products", map[string]string{"Accept-Language": "en"}),
+				newRequest(http.MethodGet, "/products", map[string]string{"Accept-Language": "id"}),
+			},
+			expectCache: []string{StatusMiss, StatusMiss, StatusHit},
+			expectBody:  []string{"1", "2", "1"},
+		},
+		{
+			name:        "vary everything",
+			header:      http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}},
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"1", "2"},
+		},
+		{
+			name:        "response too large",
+			options:     Options{TTL: time.Minute, MaxEntryBytes: 4},
+			prefix:      "large",
+			requests:    []*http.Request{newRequest(http.MethodGet, "/products", nil), newRequest(http.MethodGet, "/products", nil)},
+			expectCache: []string{StatusMiss, StatusMiss},
+			expectBody:  []string{"large1", "large2"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			th := testHandler{header: c.header, status: c.status, prefix: c.prefix}
+			h := New(&c.options).Middleware()(th.handle)
+			for idx, req := range c.requests {
+				resp := do(h, req)
+				if got := resp.Header().Get(HeaderCache); got != c.expectCache[idx] {
+					t.Errorf("request %d: expecting %s %q but got %q", idx, HeaderCache, c.expectCache[idx], got)
+				}
+				if got := resp.Body.String(); got != c.expectBody[idx] {
+					t.Errorf("request %d: expecting body %q but got %q", idx, c.expectBody[idx], got)
+				}
+			}
+		})
+	}
+}
+
+func TestAge(t *testing.T) {
+	clock := testClock{now: time.Now()}
+	th := testHandler{header: http.Header{"Cache-Control": {"max-age=60"}}}
+	h := New(&Options{Now: clock.Now}).Middleware()(th.handle)
+
+	do(h, newRequest(http.MethodGet, "/products", nil))
+	clock.Add(time.Second * 30)
+	resp := do(h, newRequest(http.MethodGet, "/products", nil))
+	if got := resp.Header().Get("Age"); got != "30" {
+		t.Errorf("expecting age 30 but got %s", got)
+	}
+
+	clock.Add(time.Second * 31)
+	resp = do(h, newRequest(http.MethodGet, "/products", nil))
+	if got := resp.Header().Get(HeaderCache); got != StatusMiss {
+		t.Errorf("expecting expired entry to miss but got %s", got)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := testClock{now: time.Now()}
+	th := testHandler{header: http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=30"}}}
+	c := New(&Options{Now: clock.Now})
+	h := c.Middleware()(th.handle)
+
+	do(h, newRequest(http.MethodGet, "/products", nil))
+	clock.Add(time.Second * 20)
+	resp := do(h, newRequest(http.MethodGet, "/products", nil))
+	if got := resp.Header().Get(HeaderCache); got != StatusStale {
+		t.Fatalf("expecting %s but got %s", StatusStale, got)
+	}
+	if got := resp.Body.String(); got != "1" {
+		t.Fatalf("expecting stale body 1 but got %s", got)
+	}
+
+	// wait for background revalidation
+	deadline := time.Now().Add(time.Second * 5)
+	for atomic.LoadInt64(&th.calls) < 2 || c.isRevalidating() {
+		if time.Now().After(deadline) {
+			t.Fatal("revalidation is not finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	resp = do(h, newRequest(http.MethodGet, "/products", nil))
+	if got := resp.Header().Get(HeaderCache); got != StatusHit {
+		t.Errorf("expecting %s after revalidation but got %s", StatusHit, got)
+	}
+	if got := resp.Body.String(); got != "2" {
+		t.Errorf("expecting revalidated body 2 but got %s", got)
+	}
+}
+
+func TestRevalidatePanic(t *testing.T) {
+	clock := testClock{now: time.Now()}
+	th := testHandler{header: http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=30"}}}
+	c := New(&Options{Now: clock.Now})
+	h := c.Middleware()(func(rctx *requestcontext.RequestContext) error {
+		if atomic.LoadInt64(&th.calls) > 0 {
+			panic("revalidation is broken")
+		}
+		return th.handle(rctx)
+	})
+
+	do(h, newRequest(http.MethodGet, "/products", nil))
+	clock.Add(time.Second * 20)
+	if got := do(h, newRequest(http.MethodGet, "/products", nil)).Header().Get(HeaderCache); got != StatusStale {
+		t.Fatalf("expecting %s but got %s", StatusStale, got)
+	}
+
+	// the panic is recovered and the key can be revalidated again
+	deadline := time.Now().Add(time.Second * 5)
+	for c.isRevalidating() {
+		if time.Now().After(deadline) {
+			t.Fatal("revalidation is not finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := do(h, newRequest(http.MethodGet, "/products", nil)).Body.String(); got != "1" {
+		t.Fatalf("expecting stale body 1 after the failed revalidation but got %s", got)
+	}
+}
+
+func (c *Cache) isRevalidating() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.revalidating) > 0
+}
+
+func TestMaxBytes(t *testing.T) {
+	th := testHandler{header: http.Header{"Cache-Control": {"max-age=60"}}}
+	c := New(&Options{MaxBytes: 1024})
+	h := c.Middleware()(th.handle)
+
+	for i := 0; i < 100; i++ {
+		do(h, newRequest(http.MethodGet, "/products/"+strconv.Itoa(i), nil))
+	}
+	stats := c.Stats()
+	if stats.Bytes > 1024 {
+		t.Errorf("expecting cache size not more than 1024 bytes but got %d", stats.Bytes)
+	}
+	if stats.Entries == 0 || stats.Entries == 100 {
+		t.Errorf("expecting some entries to be evicted, got %d entries", stats.Entries)
+	}
+
+	// the most recent entry is still in the cache
+	resp := do(h, newRequest(http.MethodGet, "/products/99", nil))
+	if got := resp.Header().Get(HeaderCache); got != StatusHit {
+		t.Errorf("expecting %s but got %s", StatusHit, got)
+	}
+	resp = do(h, newRequest(http.MethodGet, "/products/0", nil))
+	if got := resp.Header().Get(HeaderCache); got != StatusMiss {
+		t.Errorf("expecting %s but got %s", StatusMiss, got)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	rdg, err := redigo.New(context.Background(), mr.Addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+
+	th := testHandler{header: http.Header{"Cache-Control": {"max-age=60"}, "Content-Type": {"application/json"}}}
+	// two instances share the same redis
+	h1 := New(&Options{Redis: rdg}).Middleware()(th.handle)
+	h2 := New(&Options{Redis: rdg}).Middleware()(th.handle)
+
+	do(h1, newRequest(http.MethodGet, "/products", nil))
+	resp := do(h2, newRequest(http.MethodGet, "/products", nil))
+	if got := resp.Header().Get(HeaderCache); got != StatusHit {
+		t.Errorf("expecting %s from redis but got %s", StatusHit, got)
+	}
+	if got := resp.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expecting cached content type but got %s", got)
+	}
+	if th.calls != 1 {
+		t.Errorf("expecting handler called once but got %d", th.calls)
+	}
+
+	keys := mr.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("expecting 1 key in redis but got %v", keys)
+	}
+	if ttl := mr.TTL(keys[0]); ttl != time.Minute {
+		t.Errorf("expecting ttl of %v but got %v", time.Minute, ttl)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	th := testHandler{header: http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"1"`}}}
+	h := New(nil).Middleware()(th.handle)
+
+	do(h, newRequest(http.MethodGet, "/products", nil))
+	resp := do(h, newRequest(http.MethodGet, "/products", map[string]string{"If-None-Match": `"1"`}))
+	if resp.Code != http.StatusNotModified {
+		t.Errorf("expecting %d but got %d", http.StatusNotModified, resp.Code)
+	}
+	if resp.Body.Len() != 0 {
+		t.Errorf("expecting empty body but got %q", resp.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entry is a cached response
+type entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// Vary is the list of request header which is part of the key
+	// entry with vary is stored as the vary list under the primary key, and the response under the variant key
+	Vary       []string  `json:"vary,omitempty"`
+	StoredAt   time.Time `json:"stored_at"`
+	Expires    time.Time `json:"expires"`
+	StaleUntil time.Time `json:"stale_until"`
+}
+
+// size return the approximate memory size of the entry
+func (e *entry) size() int64 {
+	size := int64(len(e.Body))
+	for k, values := range e.Header {
+		size += int64(len(k))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	for _, v := range e.Vary {
+		size += int64(len(v))
+	}
+	// rough size of the struct itself
+	return size + 128
+}
+
+func (e *entry) isFresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+func (e *entry) isStale(now time.Time) bool {
+	return !e.isFresh(now) && now.Before(e.StaleUntil)
+}
+
+// cacheControl is the parsed Cache-Control header
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if idx := strings.Index(directive, "="); idx >= 0 {
+				name, arg = directive[:idx], strings.Trim(directive[idx+1:], `" `)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = arg
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds return the duration of the directive, false when the directive is not set or invalid
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	arg, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// cacheableStatus is the list of status which can be cached
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// parseVary return the canonical header names in Vary header, and false when the response vary on everything
+func parseVary(header http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// primaryKey return the key of the request without vary headers
+// the query is sorted so the same query in different order hit the same entry
+func primaryKey(req *http.Request) string {
+	return req.Method + " " + req.URL.Path + "?" + req.URL.Query().Encode()
+}
+
+// variantKey return the key of the request with the value of vary headers
+func variantKey(primary string, vary []string, header http.Header) string {
+	if len(vary) == 0 {
+		return primary
+	}
+	b := strings.Builder{}
+	b.WriteString(primary)
+	for _, name := range vary {
+		b.WriteString("|")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(header[name], ","))
+	}
+	return b.String()
+}
+
+// hashKey return short key for storage
+func hashKey(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is an in memory least recently used cache bounded by the size of entries
+type lru struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *entry
+	size  int64
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string) (*entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// set the entry and evict the least recently used entries until the cache fit the max bytes
+// entry larger than max bytes is not stored
+func (l *lru) set(key string, e *entry) {
+	size := e.size() + int64(len(key))
+	if size > l.maxBytes {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		item := el.Value.(*lruItem)
+		l.bytes += size - item.size
+		item.entry = e
+		item.size = size
+		l.ll.MoveToFront(el)
+	} else {
+		l.items[key] = l.ll.PushFront(&lruItem{key: key, entry: e, size: size})
+		l.bytes += size
+	}
+
+	for l.bytes > l.maxBytes {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+func (l *lru) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+func (l *lru) removeElement(el *list.Element) {
+	item := l.ll.Remove(el).(*lruItem)
+	delete(l.items, item.key)
+	l.bytes -= item.size
+}
+
+// stats return number of entries and total size of the entries
+func (l *lru) stats() (int, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len(), l.bytes
+}
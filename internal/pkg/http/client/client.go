@@ -1,30 +1,118 @@
 package client
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+)
 
 // Wrapper for http client
 type Wrapper struct {
-	c *http.Client
+	c     *http.Client
+	retry *retry.Policy
 }
 
 // Wrap htpp client
 func Wrap(client *http.Client) *Wrapper {
-	w := Wrapper{client}
+	w := Wrapper{c: client}
 	return &w
 }
 
 // Options for http client
 type Options struct {
+	Timeout time.Duration
+	// Retry policy of the request, the request is not retried when nil
+	// network error, 429 and 5xx status code are retried
+	Retry *retry.Policy
 }
 
 // New http client
 func New(options Options) *Wrapper {
 	w := Wrapper{
-		c: &http.Client{},
+		c: &http.Client{
+			Timeout: options.Timeout,
+		},
+		retry: options.Retry,
 	}
 	return &w
 }
 
+// statusError is returned when the response status code is retryable
+type statusError struct {
+	resp *http.Response
+}
+
+func (se *statusError) Error() string {
+	return fmt.Sprintf("client: retryable status code %d", se.resp.StatusCode)
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// Do send the http request and retry the request based on the retry policy
+// request with body is only retried when req.GetBody is set, which is the case for request created by http.NewRequest
+// when all attempts return retryable status code, the last response is returned
+func (w *Wrapper) Do(req *http.Request) (*http.Response, error) {
+	return doRetry(req, w.retry, w.c.Do)
+}
+
+func doRetry(req *http.Request, policy *retry.Policy, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if policy == nil || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return do(req)
+	}
+
+	var (
+		resp    *http.Response
+		attempt int
+	)
+	err := retry.Do(req.Context(), policy, func(ctx context.Context) error {
+		attempt++
+		r := req
+		if attempt > 1 {
+			// discard the response of previous attempt so the connection can be reused
+			drainBody(resp)
+			resp = nil
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return retry.Permanent(err)
+				}
+				r = req.Clone(ctx)
+				r.Body = body
+			}
+		}
+
+		var err error
+		resp, err = do(r)
+		if err != nil {
+			return err
+		}
+		if isRetryableStatus(resp.StatusCode) {
+			return &statusError{resp: resp}
+		}
+		return nil
+	})
+
+	if resp != nil {
+		return resp, nil
+	}
+	return nil, err
+}
+
+func drainBody(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
 // Get wrap the http client get request
 func (w *Wrapper) Get() {
 
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/http/deadline"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"go.opencensus.io/plugin/ochttp"
+)
+
+// ProxyDirect disable proxy, including proxy from environment variable
+const ProxyDirect = "direct"
+
+// ErrProxyInvalid is returned when the proxy is not a valid url
+var ErrProxyInvalid = errors.New("client: proxy must be a valid url")
+
+// Config of http client transport
+// zero value means the default value of http.DefaultTransport is used
+type Config struct {
+	// Timeout of the whole request including retries, no timeout when zero
+	Timeout               time.Duration
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	DisableKeepAlives     bool
+	// Proxy url of the client, proxy from environment variable is used when empty
+	// set to direct to not use any proxy
+	Proxy string
+	// Retry policy of the request, the request is not retried when nil
+	Retry *retry.Policy
+	// DisableTracing disable opencensus tracing of each attempt
+	DisableTracing bool
+}
+
+// ValidateProxy check whether the proxy is a valid url
+func ValidateProxy(proxy string) error {
+	if proxy == "" || proxy == ProxyDirect {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%w: %s", ErrProxyInvalid, proxy)
+	}
+	return nil
+}
+
+// NewClient create http client from configuration
+// the round tripper is chained as retry -> deadline -> tracing -> transport, so every attempt have its own span
+// and send the remaining time budget of the context
+func NewClient(config *Config) (*http.Client, error) {
+	conf := Config{}
+	if config != nil {
+		conf = *config
+	}
+	if err := ValidateProxy(conf.Proxy); err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := net.Dialer{
+		Timeout:   time.Second * 30,
+		KeepAlive: time.Second * 30,
+	}
+	if conf.DialTimeout > 0 {
+		dialer.Timeout = conf.DialTimeout
+	}
+	if conf.KeepAlive > 0 {
+		dialer.KeepAlive = conf.KeepAlive
+	}
+	transport.DialContext = dialer.DialContext
+	if conf.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = conf.TLSHandshakeTimeout
+	}
+	if conf.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = conf.ResponseHeaderTimeout
+	}
+	if conf.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = conf.IdleConnTimeout
+	}
+	if conf.MaxIdleConns > 0 {
+		transport.MaxIdleConns = conf.MaxIdleConns
+	}
+	if conf.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	}
+	if conf.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = conf.MaxConnsPerHost
+	}
+	transport.DisableKeepAlives = conf.DisableKeepAlives
+
+	switch conf.Proxy {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case ProxyDirect:
+		transport.Proxy = nil
+	default:
+		u, _ := url.Parse(conf.Proxy)
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	var rt http.RoundTripper = transport
+	if !conf.DisableTracing {
+		rt = &tracingTransport{Transport: ochttp.Transport{Base: rt}}
+	}
+	rt = &deadline.Transport{Base: rt}
+	if conf.Retry != nil {
+		rt = &RetryTransport{Base: rt, Policy: conf.Retry}
+	}
+	return &http.Client{
+		Timeout:   conf.Timeout,
+		Transport: rt,
+	}, nil
+}
+
+// RetryTransport retry the request based on the retry policy
+// network error, 429 and 5xx status code are retried, the same as Wrapper.Do
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Policy *retry.Policy
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := rt.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return doRetry(req, rt.Policy, base.RoundTrip)
+}
+
+type closeIdler interface {
+	CloseIdleConnections()
+}
+
+// CloseIdleConnections close idle connections of the base transport
+func (rt *RetryTransport) CloseIdleConnections() {
+	if ci, ok := rt.Base.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// tracingTransport forward CloseIdleConnections to the base transport, which is not done by ochttp.Transport
+type tracingTransport struct {
+	ochttp.Transport
+}
+
+func (tt *tracingTransport) CloseIdleConnections() {
+	if ci, ok := tt.Base.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+)
+
+func TestNewClientRetry(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&Config{
+		Timeout: time.Second * 5,
+		Proxy:   ProxyDirect,
+		Retry:   &retry.Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseIdleConnections()
+
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expecting status 200 but got %d", resp.StatusCode)
+	}
+	if count != 3 {
+		t.Fatalf("expecting 3 attempts but got %d", count)
+	}
+}
+
+func TestValidateProxy(t *testing.T) {
+	for _, proxy := range []string{"", ProxyDirect, "http://proxy:3128"} {
+		if err := ValidateProxy(proxy); err != nil {
+			t.Errorf("%s: not expecting error but got %v", proxy, err)
+		}
+	}
+	if err := ValidateProxy("proxy:3128"); !errors.Is(err, ErrProxyInvalid) {
+		t.Errorf("expecting error %v but got %v", ErrProxyInvalid, err)
+	}
+}
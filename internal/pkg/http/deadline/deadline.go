@@ -0,0 +1,136 @@
+// Package deadline propagate request deadline between services with X-Request-Timeout header
+// the server use the smaller of configured timeout and the budget sent by the caller
+// and the client send the remaining budget of the context to the next service
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/monitoring"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// HeaderRequestTimeout is the header of remaining time budget of the request
+// the value is formatted in milliseconds, for example 1500ms, a number without unit is treated as milliseconds
+const HeaderRequestTimeout = "X-Request-Timeout"
+
+// FromHeader return the time budget in the header
+func FromHeader(header http.Header) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get(HeaderRequestTimeout))
+	if value == "" {
+		return 0, false
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		value = fmt.Sprintf("%dms", ms)
+	}
+	budget, err := time.ParseDuration(value)
+	if err != nil || budget <= 0 {
+		return 0, false
+	}
+	return budget, true
+}
+
+// SetHeader set the remaining time budget of the context into the header
+// the header is removed when the context have no deadline, so budget from previous hop is not forwarded as is
+func SetHeader(ctx context.Context, header http.Header) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		header.Del(HeaderRequestTimeout)
+		return
+	}
+	remaining := time.Until(d)
+	if remaining < time.Millisecond {
+		remaining = time.Millisecond
+	}
+	header.Set(HeaderRequestTimeout, fmt.Sprintf("%dms", remaining.Milliseconds()))
+}
+
+// Options of deadline middleware
+type Options struct {
+	// Timeout of all routes, no timeout when zero
+	Timeout time.Duration
+	// Routes timeout by path prefix, the longest prefix is used and override the global timeout
+	Routes map[string]time.Duration
+}
+
+// timeoutOf return the timeout of the route
+func (opts *Options) timeoutOf(path string) time.Duration {
+	var (
+		timeout = opts.Timeout
+		matched = -1
+	)
+	for prefix, t := range opts.Routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+			timeout = t
+			matched = len(prefix)
+		}
+	}
+	return timeout
+}
+
+// Middleware set the deadline of request context
+// gateway timeout is returned when the deadline exceeded and the handler have not written the response
+func Middleware(options *Options) router.MiddlewareFunc {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			timeout := opts.timeoutOf(rctx.RequestHandler())
+			if budget, ok := FromHeader(rctx.RequestHeader()); ok && (timeout <= 0 || budget < timeout) {
+				timeout = budget
+			}
+			if timeout <= 0 {
+				return next(rctx)
+			}
+
+			ctx, cancel := context.WithTimeout(rctx.Context(), timeout)
+			defer cancel()
+			rctx.SetContext(ctx)
+
+			err := next(rctx)
+			if ctx.Err() != context.DeadlineExceeded {
+				return err
+			}
+			if d, ok := rctx.ResponseWriter().(monitoring.Delegator); ok && d.Status() == 0 {
+				d.WriteHeader(http.StatusGatewayTimeout)
+			}
+			return err
+		}
+	}
+}
+
+// Transport set X-Request-Timeout header of outgoing request based on the request context
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// round tripper should not modify the original request
+	r := req.Clone(req.Context())
+	SetHeader(req.Context(), r.Header)
+	return base.RoundTrip(r)
+}
+
+// CloseIdleConnections close idle connections of the base transport
+func (t *Transport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if ci, ok := t.Base.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
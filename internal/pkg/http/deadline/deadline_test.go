@@ -0,0 +1,155 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+func TestFromHeader(t *testing.T) {
+	cases := []struct {
+		value  string
+		budget time.Duration
+		ok     bool
+	}{
+		{value: "1500ms", budget: time.Millisecond * 1500, ok: true},
+		{value: "200", budget: time.Millisecond * 200, ok: true},
+		{value: "2s", budget: time.Second * 2, ok: true},
+		{value: "", ok: false},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+	}
+
+	for _, c := range cases {
+		header := http.Header{}
+		header.Set(HeaderRequestTimeout, c.value)
+		budget, ok := FromHeader(header)
+		if ok != c.ok || budget != c.budget {
+			t.Errorf("%q: expecting %v %v but got %v %v", c.value, c.budget, c.ok, budget, ok)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		header  string
+		options Options
+		expect  time.Duration
+	}{
+		{
+			name:    "global timeout",
+			path:    "/v1/user",
+			options: Options{Timeout: time.Second * 5},
+			expect:  time.Second * 5,
+		},
+		{
+			name:    "route timeout",
+			path:    "/v1/booking/{id}",
+			options: Options{Timeout: time.Second * 5, Routes: map[string]time.Duration{"/v1": time.Second * 2, "/v1/booking": time.Second * 10}},
+			expect:  time.Second * 10,
+		},
+		{
+			name:    "budget is smaller",
+			path:    "/v1/user",
+			header:  "300ms",
+			options: Options{Timeout: time.Second * 5},
+			expect:  time.Millisecond * 300,
+		},
+		{
+			name:    "budget without timeout",
+			path:    "/v1/user",
+			header:  "1s",
+			options: Options{},
+			expect:  time.Second,
+		},
+		{
+			name:    "no timeout",
+			path:    "/v1/user",
+			options: Options{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.header != "" {
+				req.Header.Set(HeaderRequestTimeout, c.header)
+			}
+			rctx := requestcontext.New(requestcontext.Constructor{
+				HTTPResponseWriter: httptest.NewRecorder(),
+				HTTPRequest:        req,
+				Path:               c.path,
+			})
+
+			var got time.Duration
+			handler := Middleware(&c.options)(func(rctx *requestcontext.RequestContext) error {
+				if d, ok := rctx.Context().Deadline(); ok {
+					got = time.Until(d)
+				}
+				return nil
+			})
+			handler(rctx)
+
+			if c.expect == 0 {
+				if got != 0 {
+					t.Fatalf("not expecting deadline but got %v", got)
+				}
+				return
+			}
+			if got > c.expect || got < c.expect-time.Millisecond*100 {
+				t.Fatalf("expecting deadline around %v but got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareGatewayTimeout(t *testing.T) {
+	r := router.New("", nil)
+	r.Use(Middleware(&Options{Timeout: time.Millisecond * 10}))
+	r.Get("/slow", func(rctx *requestcontext.RequestContext) error {
+		<-rctx.Context().Done()
+		return rctx.Context().Err()
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expecting status %d but got %d", http.StatusGatewayTimeout, rec.Code)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(HeaderRequestTimeout)
+	}))
+	defer srv.Close()
+
+	client := http.Client{Transport: &Transport{}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	h := http.Header{}
+	h.Set(HeaderRequestTimeout, header)
+	budget, ok := FromHeader(h)
+	if !ok || budget > time.Second*2 || budget < time.Second {
+		t.Fatalf("expecting budget around 2s but got %q", header)
+	}
+	if req.Header.Get(HeaderRequestTimeout) != "" {
+		t.Fatal("original request should not be modified")
+	}
+}
@@ -0,0 +1,82 @@
+package response
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StrongETag return strong entity tag of the body, the tag is changed when any byte of the body is changed
+func StrongETag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// WeakETag return weak entity tag of the version, for example the revision or updated time of the resource
+// use weak tag when the response is semantically the same for the same version but not byte to byte
+func WeakETag(version string) string {
+	return `W/"` + strings.Replace(version, `"`, "", -1) + `"`
+}
+
+// opaqueTag return the entity tag without weak prefix
+func opaqueTag(etag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+}
+
+// MatchETag return true when the etag is in the If-None-Match header value
+// the tags are compared with weak comparison as If-None-Match requires
+func MatchETag(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (tag != "" && opaqueTag(tag) == opaqueTag(etag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified return true when the client copy of the resource is still valid based on If-None-Match or If-Modified-Since header
+// If-Modified-Since is ignored when If-None-Match is set, and only GET and HEAD request is checked
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return MatchETag(ifNoneMatch, etag)
+	}
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	// http time has no sub-second precision
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// CheckNotModified set ETag and Last-Modified header, and write 304 Not Modified when the client copy is still valid
+// the caller should not write the body when it return true
+func CheckNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	header := w.Header()
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if !NotModified(r, etag, lastModified) {
+		return false
+	}
+	// representation headers are not sent with 304
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,129 @@
+package response_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/xerrors"
+)
+
+func TestMatchETag(t *testing.T) {
+	cases := []struct {
+		ifNoneMatch string
+		etag        string
+		expect      bool
+	}{
+		{ifNoneMatch: `"abc"`, etag: `"abc"`, expect: true},
+		{ifNoneMatch: `W/"abc"`, etag: `"abc"`, expect: true},
+		{ifNoneMatch: `"abc"`, etag: `W/"abc"`, expect: true},
+		{ifNoneMatch: `"xyz", "abc"`, etag: `"abc"`, expect: true},
+		{ifNoneMatch: `*`, etag: `"abc"`, expect: true},
+		{ifNoneMatch: `"xyz"`, etag: `"abc"`, expect: false},
+		{ifNoneMatch: `"abc"`, etag: ``, expect: false},
+	}
+
+	for _, c := range cases {
+		if got := response.MatchETag(c.ifNoneMatch, c.etag); got != c.expect {
+			t.Errorf("If-None-Match %s with etag %s: expecting %v but got %v", c.ifNoneMatch, c.etag, c.expect, got)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2020, 5, 5, 10, 0, 0, 500, time.UTC)
+	etag := response.WeakETag("10")
+
+	cases := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		expect  bool
+	}{
+		{
+			name:    "etag match",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-None-Match": `W/"10"`},
+			expect:  true,
+		},
+		{
+			name:    "etag not match",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-None-Match": `W/"9"`},
+			expect:  false,
+		},
+		{
+			name:    "etag has precedence over modified since",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-None-Match": `W/"9"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			expect:  false,
+		},
+		{
+			name:    "not modified since",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			expect:  true,
+		},
+		{
+			name:    "modified since",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)},
+			expect:  false,
+		},
+		{
+			name:    "post is never not modified",
+			method:  http.MethodPost,
+			headers: map[string]string{"If-None-Match": `W/"10"`},
+			expect:  false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, "http://example.com", nil)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			if got := response.NotModified(req, etag, lastModified); got != c.expect {
+				t.Errorf("expecting %v but got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestWriteConditional(t *testing.T) {
+	data := map[string]string{"name": "product"}
+	write := func(req *http.Request, err error) *http.Response {
+		w := httptest.NewRecorder()
+		jsonresp := response.JSON(w).Request(req).Data(data)
+		if err != nil {
+			jsonresp.Error(err, nil)
+		}
+		jsonresp.Write()
+		return w.Result()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	resp := write(req, nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expecting 200 with etag but got %d with etag %q", resp.StatusCode, etag)
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	resp = write(req, nil)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expecting %d but got %d", http.StatusNotModified, resp.StatusCode)
+	}
+	if resp.ContentLength > 0 {
+		t.Errorf("expecting empty body but got %d bytes", resp.ContentLength)
+	}
+
+	// error response is never not modified
+	resp = write(req, xerrors.New("not found", xerrors.KindNotFound))
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expecting %d but got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
@@ -3,6 +3,7 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/albertwidi/go-project-example/internal/xerrors"
 )
@@ -25,6 +26,10 @@ type JSONResponse struct {
 	writer        http.ResponseWriter
 	xerr          *xerrors.Errors
 	headerWritten bool
+	// conditional request
+	request      *http.Request
+	etag         string
+	lastModified time.Time
 
 	// response part
 	ResponseStatus Status             `json:"status"`
@@ -60,6 +65,26 @@ func (jresp *JSONResponse) SetHeader(key, value string) {
 	jresp.writer.Header().Set(key, value)
 }
 
+// Request set the request of the response, to answer conditional request with 304 Not Modified
+// the conditional request is only handled when the response is 200 OK without explicit WriteHeader
+func (jresp *JSONResponse) Request(r *http.Request) *JSONResponse {
+	jresp.request = r
+	return jresp
+}
+
+// ETag set the entity tag of the response, for example WeakETag of the resource version
+// strong entity tag of the body is used when the tag is not set
+func (jresp *JSONResponse) ETag(etag string) *JSONResponse {
+	jresp.etag = etag
+	return jresp
+}
+
+// LastModified set the last modified time of the response, to answer If-Modified-Since request
+func (jresp *JSONResponse) LastModified(t time.Time) *JSONResponse {
+	jresp.lastModified = t
+	return jresp
+}
+
 // Data for set data to json response
 func (jresp *JSONResponse) Data(data interface{}) *JSONResponse {
 	jresp.ResponseData = data
@@ -105,7 +130,7 @@ func (jresp *JSONResponse) Write() (int, error) {
 
 		case xerrors.KindBadRequest:
 			jresp.ResponseStatus = StatusBadRequest
-			jresp.writer.WriteHeader(http.StatusBadRequest)
+			jresp.WriteHeader(http.StatusBadRequest)
 
 		case xerrors.KindUnauthorized:
 			jresp.ResponseStatus = StatusUnauthorized
@@ -121,5 +146,15 @@ func (jresp *JSONResponse) Write() (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	if jresp.request != nil && !jresp.headerWritten {
+		etag := jresp.etag
+		if etag == "" {
+			etag = StrongETag(out)
+		}
+		if CheckNotModified(jresp.writer, jresp.request, etag, jresp.lastModified) {
+			jresp.headerWritten = true
+			return 0, nil
+		}
+	}
 	return jresp.writer.Write(out)
 }
@@ -0,0 +1,104 @@
+// Package tracing trace server requests with opencensus, and force the sampling of a specific request on demand
+// the request is sampled regardless of the sampler when X-Debug-Trace header match the debug token
+// or when ForceSample return true, for example user which is flagged for debugging
+//
+// the trace id of forced request is returned in X-Trace-Id header, so the full trace can be found
+// the sampling decision is propagated to downstream services by the opencensus http client
+package tracing
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/monitoring"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+)
+
+// list of tracing header
+const (
+	// HeaderDebugTrace force the sampling of the request, the value is the debug token
+	HeaderDebugTrace = "X-Debug-Trace"
+	// HeaderTraceID is the trace id of forced request
+	HeaderTraceID = "X-Trace-Id"
+)
+
+// Options of tracing middleware
+type Options struct {
+	// DebugToken of X-Debug-Trace header, the header is ignored when empty
+	DebugToken string
+	// Sampler of request which is not forced, default to opencensus default sampler
+	// sampled parent from the caller is respected by the default sampler
+	Sampler trace.Sampler
+	// ForceSample return true to sample the request, for example user flagged in feature flag
+	ForceSample func(rctx *requestcontext.RequestContext) bool
+}
+
+// forced return true when the sampling of request is forced
+func (opts *Options) forced(rctx *requestcontext.RequestContext) bool {
+	if opts.DebugToken != "" {
+		token := rctx.RequestHeader().Get(HeaderDebugTrace)
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(opts.DebugToken)) == 1 {
+			return true
+		}
+	}
+	return opts.ForceSample != nil && opts.ForceSample(rctx)
+}
+
+// Middleware start server span of every request, the span is child of the caller span in b3 headers
+func Middleware(options *Options) router.MiddlewareFunc {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	format := &b3.HTTPFormat{}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			forced := opts.forced(rctx)
+			// the debug token is not forwarded to the handler
+			rctx.RequestHeader().Del(HeaderDebugTrace)
+
+			startOptions := []trace.StartOption{trace.WithSpanKind(trace.SpanKindServer)}
+			if forced {
+				startOptions = append(startOptions, trace.WithSampler(trace.AlwaysSample()))
+			} else if opts.Sampler != nil {
+				startOptions = append(startOptions, trace.WithSampler(opts.Sampler))
+			}
+
+			name := rctx.RequestHandler()
+			ctx := rctx.Context()
+			var span *trace.Span
+			if parent, ok := format.SpanContextFromRequest(rctx.Request()); ok {
+				ctx, span = trace.StartSpanWithRemoteParent(ctx, name, parent, startOptions...)
+			} else {
+				ctx, span = trace.StartSpan(ctx, name, startOptions...)
+			}
+			defer span.End()
+			rctx.SetContext(ctx)
+
+			span.AddAttributes(
+				trace.StringAttribute("http.method", rctx.Request().Method),
+				trace.StringAttribute("http.path", rctx.Request().URL.Path),
+			)
+			if forced {
+				span.AddAttributes(trace.BoolAttribute("debug.forced", true))
+				rctx.ResponseWriter().Header().Set(HeaderTraceID, span.SpanContext().TraceID.String())
+			}
+
+			err := next(rctx)
+			if d, ok := rctx.ResponseWriter().(monitoring.Delegator); ok && d.Status() != 0 {
+				span.AddAttributes(trace.Int64Attribute("http.status_code", int64(d.Status())))
+				if d.Status() >= http.StatusInternalServerError {
+					span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: http.StatusText(d.Status())})
+				}
+			}
+			if err != nil {
+				span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	parent := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, TraceOptions: 1}
+	cases := []struct {
+		name    string
+		header  string
+		parent  *trace.SpanContext
+		options Options
+		sampled bool
+		forced  bool
+	}{
+		{
+			name:    "debug token",
+			header:  "secret",
+			options: Options{DebugToken: "secret", Sampler: trace.NeverSample()},
+			sampled: true,
+			forced:  true,
+		},
+		{
+			name:    "invalid debug token",
+			header:  "guess",
+			options: Options{DebugToken: "secret", Sampler: trace.NeverSample()},
+		},
+		{
+			name:    "empty debug token is ignored",
+			options: Options{Sampler: trace.NeverSample()},
+		},
+		{
+			name: "force sample",
+			options: Options{
+				Sampler: trace.NeverSample(),
+				ForceSample: func(rctx *requestcontext.RequestContext) bool {
+					return rctx.RequestHeader().Get("X-User-ID") == "42"
+				},
+			},
+			sampled: true,
+			forced:  true,
+		},
+		{
+			name:    "sampled parent",
+			parent:  &parent,
+			options: Options{},
+			sampled: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/booking", nil)
+			req.Header.Set("X-User-ID", "42")
+			if c.header != "" {
+				req.Header.Set(HeaderDebugTrace, c.header)
+			}
+			if c.parent != nil {
+				(&b3.HTTPFormat{}).SpanContextToRequest(*c.parent, req)
+			}
+			rec := httptest.NewRecorder()
+			rctx := requestcontext.New(requestcontext.Constructor{
+				HTTPResponseWriter: rec,
+				HTTPRequest:        req,
+				Path:               "/v1/booking",
+			})
+
+			var span *trace.Span
+			handler := Middleware(&c.options)(func(rctx *requestcontext.RequestContext) error {
+				span = trace.FromContext(rctx.Context())
+				if rctx.RequestHeader().Get(HeaderDebugTrace) != "" {
+					t.Fatal("expect debug token is not forwarded to the handler")
+				}
+				return nil
+			})
+			if err := handler(rctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if span == nil {
+				t.Fatal("expect span in the request context")
+			}
+			sc := span.SpanContext()
+			if sc.IsSampled() != c.sampled {
+				t.Fatalf("expect sampled %t but got %t", c.sampled, sc.IsSampled())
+			}
+			if c.parent != nil && sc.TraceID != c.parent.TraceID {
+				t.Fatalf("expect trace id %s but got %s", c.parent.TraceID, sc.TraceID)
+			}
+			traceID := rec.Header().Get(HeaderTraceID)
+			if c.forced != (traceID != "") {
+				t.Fatalf("expect trace id header %t but got %q", c.forced, traceID)
+			}
+			if c.forced && traceID != sc.TraceID.String() {
+				t.Fatalf("expect trace id %s but got %s", sc.TraceID, traceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package indexer sync changes of sql rows to a search engine
+// the indexer receive change events, transform the event to document using registered mapper and upsert the document in batches
+//
+// the indexer doesn't consume a source by itself, the caller feed the events:
+// send the events to the channel of Run, for example from outbox table, call Handle directly,
+// or subscribe EventHandler to an event bus topic with Event payload
+//
+//	bus.Subscribe(indexer.TopicChange, idx.EventHandler(), &eventbus.SubscribeOptions{Name: "indexer", Async: true})
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+// list of error
+var (
+	ErrMapperNotFound = errors.New("indexer: mapper not found")
+	ErrSinkNil        = errors.New("indexer: sink is nil")
+)
+
+// Operation of change event
+type Operation int
+
+// list of operation
+const (
+	OpUpsert Operation = iota
+	OpDelete
+)
+
+// Event of row changes
+type Event struct {
+	Table string
+	// ID of the row, used as document id
+	ID   string
+	Op   Operation
+	Data map[string]interface{}
+}
+
+// TopicChange is the event bus topic of row changes, the payload is Event
+var TopicChange = eventbus.NewTopic("indexer.change", Event{})
+
+// Document to be indexed
+type Document struct {
+	Index string
+	ID    string
+	Op    Operation
+	Body  interface{}
+}
+
+// Mapper transform event into document
+// return false to skip the event from being indexed
+type Mapper func(ctx context.Context, event Event) (Document, bool, error)
+
+// Sink is the search engine client which able to receive bulk documents
+type Sink interface {
+	Bulk(ctx context.Context, docs []Document) error
+}
+
+// Options of indexer
+type Options struct {
+	// BatchSize is the maximum documents sent in one bulk request
+	// default to 500
+	BatchSize int
+	// FlushInterval is the maximum time to wait before flushing incomplete batch
+	// default to 1 second
+	FlushInterval time.Duration
+	// MaxRetry is the number of retries after the first bulk request is failed, default to 3
+	MaxRetry int
+	// RetryInterval is the base interval of retry, the interval is doubled on every retry
+	// default to 100 milliseconds
+	RetryInterval time.Duration
+}
+
+// Indexer struct
+type Indexer struct {
+	sink    Sink
+	options Options
+
+	mu      sync.RWMutex
+	mappers map[string]Mapper
+}
+
+// New indexer
+func New(sink Sink, options *Options) (*Indexer, error) {
+	if sink == nil {
+		return nil, ErrSinkNil
+	}
+
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxRetry <= 0 {
+		opts.MaxRetry = 3
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Millisecond * 100
+	}
+
+	idx := Indexer{
+		sink:    sink,
+		options: opts,
+		mappers: make(map[string]Mapper),
+	}
+	return &idx, nil
+}
+
+// Register mapper for a table
+func (idx *Indexer) Register(table string, mapper Mapper) {
+	idx.mu.Lock()
+	idx.mappers[table] = mapper
+	idx.mu.Unlock()
+}
+
+func (idx *Indexer) mapEvent(ctx context.Context, event Event) (Document, bool, error) {
+	idx.mu.RLock()
+	mapper, ok := idx.mappers[event.Table]
+	idx.mu.RUnlock()
+	if !ok {
+		return Document{}, false, fmt.Errorf("%w: %s", ErrMapperNotFound, event.Table)
+	}
+
+	doc, ok, err := mapper(ctx, event)
+	if err != nil || !ok {
+		return doc, ok, err
+	}
+	if doc.ID == "" {
+		doc.ID = event.ID
+	}
+	doc.Op = event.Op
+	return doc, true, nil
+}
+
+// Handle events directly, events are mapped and sent in batches
+func (idx *Indexer) Handle(ctx context.Context, events ...Event) error {
+	docs := make([]Document, 0, len(events))
+	for _, event := range events {
+		doc, ok, err := idx.mapEvent(ctx, event)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+
+	for len(docs) > 0 {
+		n := idx.options.BatchSize
+		if n > len(docs) {
+			n = len(docs)
+		}
+		if err := idx.bulk(ctx, docs[:n]); err != nil {
+			return err
+		}
+		docs = docs[n:]
+	}
+	return nil
+}
+
+// EventHandler return the event bus handler which index the Event payload
+// every event is sent in its own bulk request, use Run to send the events in batches
+func (idx *Indexer) EventHandler() eventbus.Handler {
+	return func(ctx context.Context, event eventbus.Event) error {
+		change, ok := event.Payload.(Event)
+		if !ok {
+			return fmt.Errorf("%w: %s", eventbus.ErrPayloadType, event.Topic)
+		}
+		return idx.Handle(ctx, change)
+	}
+}
+
+// Run consume events from channel until the channel is closed or context is canceled
+// events are flushed when the batch is full or flush interval is reached
+func (idx *Indexer) Run(ctx context.Context, events <-chan Event) error {
+	ticker := time.NewTicker(idx.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, idx.options.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := idx.Handle(ctx, batch...)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-events:
+			if !ok {
+				return flush()
+			}
+			batch = append(batch, event)
+			if len(batch) >= idx.options.BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Reindex all rows of a table from the database
+// the query must return the id column, which will be used as the event id
+func (idx *Indexer) Reindex(ctx context.Context, db *sqldb.DB, table, idColumn, query string, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	batch := make([]Event, 0, idx.options.BatchSize)
+	for rows.Next() {
+		data := make(map[string]interface{})
+		if err := sqlx.MapScan(rows, data); err != nil {
+			return err
+		}
+		batch = append(batch, Event{
+			Table: table,
+			ID:    fmt.Sprint(convertBytes(data[idColumn])),
+			Op:    OpUpsert,
+			Data:  data,
+		})
+
+		if len(batch) >= idx.options.BatchSize {
+			if err := idx.Handle(ctx, batch...); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return idx.Handle(ctx, batch...)
+}
+
+// bulk send documents to sink with retry
+func (idx *Indexer) bulk(ctx context.Context, docs []Document) error {
+	policy := retry.Policy{
+		// the attempts include the first request
+		MaxAttempts:     idx.options.MaxRetry + 1,
+		InitialInterval: idx.options.RetryInterval,
+		Multiplier:      2,
+	}
+	err := retry.Do(ctx, &policy, func(ctx context.Context) error {
+		return idx.sink.Bulk(ctx, docs)
+	})
+	if err != nil {
+		return fmt.Errorf("indexer: bulk failed: %w", err)
+	}
+	return nil
+}
+
+// convertBytes convert []byte returned by sql driver into string
+func convertBytes(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
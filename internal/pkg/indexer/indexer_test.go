@@ -0,0 +1,153 @@
+package indexer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+	"github.com/albertwidi/go-project-example/internal/pkg/indexer"
+)
+
+type fakeSink struct {
+	mu       sync.Mutex
+	fail     int
+	bulks    [][]indexer.Document
+	attempts int
+}
+
+func (fs *fakeSink) Bulk(ctx context.Context, docs []indexer.Document) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.attempts++
+	if fs.fail > 0 {
+		fs.fail--
+		return errors.New("sink error")
+	}
+	copied := make([]indexer.Document, len(docs))
+	copy(copied, docs)
+	fs.bulks = append(fs.bulks, copied)
+	return nil
+}
+
+func userMapper(ctx context.Context, event indexer.Event) (indexer.Document, bool, error) {
+	if event.Data["skip"] == true {
+		return indexer.Document{}, false, nil
+	}
+	return indexer.Document{Index: "users", Body: event.Data}, true, nil
+}
+
+func TestHandle(t *testing.T) {
+	sink := &fakeSink{}
+	idx, err := indexer.New(sink, &indexer.Options{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Register("users", userMapper)
+
+	events := []indexer.Event{
+		{Table: "users", ID: "1", Data: map[string]interface{}{}},
+		{Table: "users", ID: "2", Data: map[string]interface{}{"skip": true}},
+		{Table: "users", ID: "3", Data: map[string]interface{}{}},
+		{Table: "users", ID: "4", Op: indexer.OpDelete},
+	}
+	if err := idx.Handle(context.Background(), events...); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.bulks) != 2 {
+		t.Fatalf("expecting 2 bulk requests but got %d", len(sink.bulks))
+	}
+	if sink.bulks[0][1].ID != "3" {
+		t.Errorf("expecting document id 3 but got %s", sink.bulks[0][1].ID)
+	}
+	if sink.bulks[1][0].Op != indexer.OpDelete {
+		t.Errorf("expecting delete operation but got %v", sink.bulks[1][0].Op)
+	}
+}
+
+func TestHandleMapperNotFound(t *testing.T) {
+	idx, err := indexer.New(&fakeSink{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Handle(context.Background(), indexer.Event{Table: "unknown"})
+	if !errors.Is(err, indexer.ErrMapperNotFound) {
+		t.Errorf("expecting ErrMapperNotFound but got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	sink := &fakeSink{fail: 2}
+	idx, err := indexer.New(sink, &indexer.Options{MaxRetry: 3, RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Register("users", userMapper)
+
+	if err := idx.Handle(context.Background(), indexer.Event{Table: "users", ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if sink.attempts != 3 {
+		t.Errorf("expecting 3 attempts but got %d", sink.attempts)
+	}
+
+	// the first request and 3 retries
+	sink = &fakeSink{fail: 10}
+	idx, err = indexer.New(sink, &indexer.Options{MaxRetry: 3, RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Register("users", userMapper)
+	if err := idx.Handle(context.Background(), indexer.Event{Table: "users", ID: "1"}); err == nil {
+		t.Fatal("expecting error after the retries")
+	}
+	if sink.attempts != 4 {
+		t.Errorf("expecting 4 attempts but got %d", sink.attempts)
+	}
+}
+
+func TestEventHandler(t *testing.T) {
+	sink := &fakeSink{}
+	idx, err := indexer.New(sink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Register("users", userMapper)
+
+	bus := eventbus.New(nil)
+	defer bus.Close()
+	if _, err := bus.Subscribe(indexer.TopicChange, idx.EventHandler(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish(context.Background(), indexer.TopicChange, indexer.Event{Table: "users", ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.bulks) != 1 || sink.bulks[0][0].ID != "1" {
+		t.Errorf("expecting the event is indexed but got %v", sink.bulks)
+	}
+}
+
+func TestRun(t *testing.T) {
+	sink := &fakeSink{}
+	idx, err := indexer.New(sink, &indexer.Options{BatchSize: 10, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Register("users", userMapper)
+
+	events := make(chan indexer.Event, 3)
+	for _, id := range []string{"1", "2", "3"} {
+		events <- indexer.Event{Table: "users", ID: id}
+	}
+	close(events)
+
+	if err := idx.Run(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.bulks) != 1 || len(sink.bulks[0]) != 3 {
+		t.Errorf("expecting 1 bulk with 3 documents but got %v", sink.bulks)
+	}
+}
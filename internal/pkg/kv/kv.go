@@ -0,0 +1,36 @@
+// Package kv is a small key-value store abstraction with pluggable backends
+// so subsystems like feature flags and idempotency keys can run in deployments without redis
+//
+// list of backends:
+//   - memory, for single instance deployment and tests
+//   - redis, shared between instances
+//   - sqldb, a single table in postgres or mysql
+//
+// the value of expired key is not returned, and the ttl of zero keep the key forever
+package kv
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// list of error
+var (
+	ErrNotFound = errors.New("kv: key not found")
+	ErrKeyEmpty = errors.New("kv: key is empty")
+)
+
+// Store of key-value
+type Store interface {
+	// Get return the value of the key, ErrNotFound is returned when the key does not exist or expired
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set the value of the key, the key expire after ttl, zero ttl keep the key forever
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete the key, deleting key which does not exist is not an error
+	Delete(ctx context.Context, key string) error
+	// CompareAndSwap set the value of the key only when the current value is old
+	// nil old set the value only when the key does not exist, for example to acquire idempotency key
+	// false is returned when the current value is not old
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+}
@@ -0,0 +1,125 @@
+// Package memory is in-memory key-value store, the value is not shared between instances
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/kv"
+)
+
+// Options of memory store
+type Options struct {
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+type entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Store is in-memory key-value store
+// expired key is removed when it is accessed
+type Store struct {
+	opts    Options
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// make sure the memory store implements kv.Store
+var _ kv.Store = (*Store)(nil)
+
+// New in-memory store
+func New(options *Options) *Store {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	s := Store{
+		opts:    opts,
+		entries: make(map[string]entry),
+	}
+	return &s
+}
+
+// get return the entry of the key, the caller must hold the lock
+func (s *Store) get(key string) (entry, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return entry{}, false
+	}
+	if !e.expiresAt.IsZero() && !s.opts.Now().Before(e.expiresAt) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+// set the entry of the key, the caller must hold the lock
+func (s *Store) set(key string, value []byte, ttl time.Duration) {
+	e := entry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expiresAt = s.opts.Now().Add(ttl)
+	}
+	s.entries[key] = e
+}
+
+// Get implements kv.Store
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	if key == "" {
+		return nil, kv.ErrKeyEmpty
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.get(key)
+	if !ok {
+		return nil, kv.ErrNotFound
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+// Set implements kv.Store
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	s.mu.Lock()
+	s.set(key, value, ttl)
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete implements kv.Store
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// CompareAndSwap implements kv.Store
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	if key == "" {
+		return false, kv.ErrKeyEmpty
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.get(key)
+	if old == nil && ok {
+		return false, nil
+	}
+	if old != nil && (!ok || !bytes.Equal(e.value, old)) {
+		return false, nil
+	}
+	s.set(key, value, ttl)
+	return true, nil
+}
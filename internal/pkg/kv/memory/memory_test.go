@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/kv"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Now()
+	s := New(&Options{Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v but got %v", kv.ErrNotFound, err)
+	}
+	if err := s.Set(ctx, "flag", []byte("on"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, err := s.Get(ctx, "flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "on" {
+		t.Fatalf("expect value on but got %s", value)
+	}
+
+	// the key expire after the ttl
+	now = now.Add(time.Minute)
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v after expired but got %v", kv.ErrNotFound, err)
+	}
+
+	if err := s.Set(ctx, "flag", []byte("on"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "flag"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v after deleted but got %v", kv.ErrNotFound, err)
+	}
+	if err := s.Set(ctx, "", []byte("on"), 0); !errors.Is(err, kv.ErrKeyEmpty) {
+		t.Fatalf("expect error %v but got %v", kv.ErrKeyEmpty, err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	now := time.Now()
+	s := New(&Options{Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		old     []byte
+		value   []byte
+		swapped bool
+	}{
+		{name: "create", value: []byte("pending"), swapped: true},
+		{name: "create existing key", value: []byte("pending")},
+		{name: "different old value", old: []byte("done"), value: []byte("failed")},
+		{name: "same old value", old: []byte("pending"), value: []byte("done"), swapped: true},
+	}
+	for _, c := range cases {
+		swapped, err := s.CompareAndSwap(ctx, "idempotency", c.old, c.value, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if swapped != c.swapped {
+			t.Fatalf("%s: expect swapped %t but got %t", c.name, c.swapped, swapped)
+		}
+	}
+
+	// expired key can be created again
+	now = now.Add(time.Minute)
+	swapped, err := s.CompareAndSwap(ctx, "idempotency", nil, []byte("pending"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !swapped {
+		t.Fatal("expect expired key to be created")
+	}
+}
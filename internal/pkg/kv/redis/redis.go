@@ -0,0 +1,139 @@
+// Package redis is key-value store in redis, the value is shared between instances
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/kv"
+)
+
+// setScript set the value with expiry in milliseconds
+const setScript = `return redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])`
+
+// casScript set the value when the current value is ARGV[2], or when the key does not exist and ARGV[1] is 1
+const casScript = `
+local current = redis.call('GET', KEYS[1])
+if ARGV[1] == '1' then
+	if current then
+		return 0
+	end
+elseif current ~= ARGV[2] then
+	return 0
+end
+if tonumber(ARGV[4]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+else
+	redis.call('SET', KEYS[1], ARGV[3])
+end
+return 1
+`
+
+// Client of redis, implemented by redigo.Redigo
+type Client interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value interface{}) (string, error)
+	Delete(ctx context.Context, key string) (int, error)
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+	IsErrNil(err error) bool
+}
+
+// Options of redis store
+type Options struct {
+	// Prefix of every key, for example idempotency:
+	Prefix string
+}
+
+// Store is key-value store in redis
+type Store struct {
+	client Client
+	prefix string
+}
+
+// make sure the redis store implements kv.Store
+var _ kv.Store = (*Store)(nil)
+
+// New redis store
+func New(client Client, options *Options) *Store {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	s := Store{
+		client: client,
+		prefix: opts.Prefix,
+	}
+	return &s
+}
+
+// Get implements kv.Store
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	if key == "" {
+		return nil, kv.ErrKeyEmpty
+	}
+	value, err := s.client.Get(ctx, s.prefix+key)
+	if err != nil {
+		if s.client.IsErrNil(err) {
+			return nil, kv.ErrNotFound
+		}
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// Set implements kv.Store
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	if ttl <= 0 {
+		_, err := s.client.Set(ctx, s.prefix+key, value)
+		return err
+	}
+	_, err := s.client.Eval(ctx, setScript, []string{s.prefix + key}, value, milliseconds(ttl))
+	return err
+}
+
+// Delete implements kv.Store
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	_, err := s.client.Delete(ctx, s.prefix+key)
+	return err
+}
+
+// CompareAndSwap implements kv.Store, the compare and set is atomic in lua script
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	if key == "" {
+		return false, kv.ErrKeyEmpty
+	}
+	notExist := "0"
+	if old == nil {
+		notExist = "1"
+	}
+	var expiry int64
+	if ttl > 0 {
+		expiry = milliseconds(ttl)
+	}
+	resp, err := s.client.Eval(ctx, casScript, []string{s.prefix + key}, notExist, old, value, strconv.FormatInt(expiry, 10))
+	if err != nil {
+		return false, err
+	}
+	swapped, ok := resp.(int64)
+	if !ok {
+		return false, fmt.Errorf("kv: unexpected compare and swap response %T", resp)
+	}
+	return swapped == 1, nil
+}
+
+// milliseconds of the ttl, ttl less than a millisecond is rounded up so the key is not kept forever
+func milliseconds(ttl time.Duration) int64 {
+	ms := int64(ttl / time.Millisecond)
+	if ms == 0 {
+		ms = 1
+	}
+	return ms
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/kv"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdg, err := redigo.New(context.Background(), mr.Addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(rdg, &Options{Prefix: "kv:"}), mr
+}
+
+func TestStore(t *testing.T) {
+	s, mr := newStore(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v but got %v", kv.ErrNotFound, err)
+	}
+	if err := s.Set(ctx, "flag", []byte("on"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, err := s.Get(ctx, "flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "on" {
+		t.Fatalf("expect value on but got %s", value)
+	}
+	if !mr.Exists("kv:flag") {
+		t.Fatal("expect key with prefix")
+	}
+
+	mr.FastForward(time.Minute)
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v after expired but got %v", kv.ErrNotFound, err)
+	}
+
+	if err := s.Set(ctx, "flag", []byte("on"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "flag"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v after deleted but got %v", kv.ErrNotFound, err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	s, mr := newStore(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		old     []byte
+		value   []byte
+		swapped bool
+	}{
+		{name: "create", value: []byte("pending"), swapped: true},
+		{name: "create existing key", value: []byte("pending")},
+		{name: "different old value", old: []byte("done"), value: []byte("failed")},
+		{name: "same old value", old: []byte("pending"), value: []byte("done"), swapped: true},
+	}
+	for _, c := range cases {
+		swapped, err := s.CompareAndSwap(ctx, "idempotency", c.old, c.value, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if swapped != c.swapped {
+			t.Fatalf("%s: expect swapped %t but got %t", c.name, c.swapped, swapped)
+		}
+	}
+	if ttl := mr.TTL("kv:idempotency"); ttl != time.Minute {
+		t.Fatalf("expect ttl 1m but got %s", ttl)
+	}
+	value, err := s.Get(ctx, "idempotency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "done" {
+		t.Fatalf("expect value done but got %s", value)
+	}
+}
@@ -0,0 +1,184 @@
+// Package sqldb is key-value store in a single table of postgres or mysql
+// the value is shared between instances, and the queries are always routed to the leader
+//
+// the table must be created before the store is used, for example in postgres:
+//
+//	CREATE TABLE kv (
+//		name       VARCHAR(255) PRIMARY KEY,
+//		value      BYTEA NOT NULL,
+//		expires_at TIMESTAMP NULL
+//	);
+//	CREATE INDEX kv_expires_at ON kv (expires_at);
+//
+// use BLOB for the value in mysql. expired rows are not returned, and removed by Cleanup
+package sqldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/kv"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// DefaultTable of the store
+const DefaultTable = "kv"
+
+// Options of sqldb store
+type Options struct {
+	// Table of the store, default to kv
+	Table string
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// Store is key-value store in sql database
+type Store struct {
+	db     *sqldb.DB
+	driver string
+	table  string
+	now    func() time.Time
+}
+
+// make sure the sqldb store implements kv.Store
+var _ kv.Store = (*Store)(nil)
+
+// New sqldb store
+func New(db *sqldb.DB, options *Options) (*Store, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if err := sqldb.ValidateIdentifier(opts.Table); err != nil {
+		return nil, err
+	}
+	driver := db.Leader().DriverName()
+	if err := sqldb.ValidateDriver(driver); err != nil {
+		return nil, err
+	}
+	s := Store{
+		db:     db,
+		driver: driver,
+		table:  opts.Table,
+		now:    opts.Now,
+	}
+	return &s, nil
+}
+
+// expiresAt return the expiry time of the ttl, nil when the key is kept forever
+func (s *Store) expiresAt(ttl time.Duration) interface{} {
+	if ttl <= 0 {
+		return nil
+	}
+	return s.now().Add(ttl).UTC()
+}
+
+// Get implements kv.Store
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	if key == "" {
+		return nil, kv.ErrKeyEmpty
+	}
+	query := s.db.Rebind(fmt.Sprintf("SELECT value FROM %s WHERE name = ? AND (expires_at IS NULL OR expires_at > ?)", s.table))
+	var value []byte
+	if err := s.db.GetContext(sqldb.ForceLeader(ctx), &value, query, key, s.now().UTC()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, kv.ErrNotFound
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set implements kv.Store
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	_, err := s.db.Upsert(ctx, s.table, []string{"name"}, map[string]interface{}{
+		"name":       key,
+		"value":      value,
+		"expires_at": s.expiresAt(ttl),
+	}, nil)
+	return err
+}
+
+// Delete implements kv.Store
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	query := s.db.Rebind(fmt.Sprintf("DELETE FROM %s WHERE name = ?", s.table))
+	_, err := s.db.ExecContext(ctx, query, key)
+	return err
+}
+
+// CompareAndSwap implements kv.Store
+// the key is inserted when old is nil, and the expired row of the key is deleted before the insert
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	if key == "" {
+		return false, kv.ErrKeyEmpty
+	}
+	now := s.now().UTC()
+	if old == nil {
+		deleteQuery := s.db.Rebind(fmt.Sprintf("DELETE FROM %s WHERE name = ? AND expires_at <= ?", s.table))
+		if _, err := s.db.ExecContext(ctx, deleteQuery, key, now); err != nil {
+			return false, err
+		}
+		insert := "INSERT INTO %s (name, value, expires_at) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING"
+		if s.driver == sqldb.DriverMySQL {
+			insert = "INSERT IGNORE INTO %s (name, value, expires_at) VALUES (?, ?, ?)"
+		}
+		result, err := s.db.ExecContext(ctx, s.db.Rebind(fmt.Sprintf(insert, s.table)), key, value, s.expiresAt(ttl))
+		if err != nil {
+			return false, err
+		}
+		return affected(result)
+	}
+
+	query := s.db.Rebind(fmt.Sprintf("UPDATE %s SET value = ?, expires_at = ? WHERE name = ? AND value = ? AND (expires_at IS NULL OR expires_at > ?)", s.table))
+	result, err := s.db.ExecContext(ctx, query, value, s.expiresAt(ttl), key, old, now)
+	if err != nil {
+		return false, err
+	}
+	swapped, err := affected(result)
+	if err != nil || swapped || !bytes.Equal(old, value) {
+		return swapped, err
+	}
+	// mysql return zero affected rows when the row is not changed, so check whether the value is already the same
+	current, err := s.Get(ctx, key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(current, old), nil
+}
+
+// Cleanup delete the expired rows, and return the number of deleted rows
+func (s *Store) Cleanup(ctx context.Context) (int64, error) {
+	query := s.db.Rebind(fmt.Sprintf("DELETE FROM %s WHERE expires_at <= ?", s.table))
+	result, err := s.db.ExecContext(ctx, query, s.now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func affected(result sql.Result) (bool, error) {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
@@ -0,0 +1,165 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/kv"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+func newStore(t *testing.T, driver string, now time.Time) (*Store, sqlmock.Sqlmock) {
+	t.Helper()
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, driver)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(db, &Options{Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, mock
+}
+
+func TestStore(t *testing.T) {
+	now := time.Now().UTC()
+	s, mock := newStore(t, sqldb.DriverPostgres, now)
+	ctx := context.Background()
+
+	getQuery := regexp.QuoteMeta("SELECT value FROM kv WHERE name = $1 AND (expires_at IS NULL OR expires_at > $2)")
+	mock.ExpectQuery(getQuery).WithArgs("flag", now).WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO kv (expires_at, name, value) VALUES ($1, $2, $3) ON CONFLICT (name) DO UPDATE SET expires_at = EXCLUDED.expires_at, value = EXCLUDED.value")).
+		WithArgs(now.Add(time.Minute), "flag", []byte("on")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(getQuery).WithArgs("flag", now).WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte("on")))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM kv WHERE name = $1")).WithArgs("flag").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM kv WHERE expires_at <= $1")).WithArgs(now).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if _, err := s.Get(ctx, "flag"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expect error %v but got %v", kv.ErrNotFound, err)
+	}
+	if err := s.Set(ctx, "flag", []byte("on"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, err := s.Get(ctx, "flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "on" {
+		t.Fatalf("expect value on but got %s", value)
+	}
+	if err := s.Delete(ctx, "flag"); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.Cleanup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Fatalf("expect 3 deleted rows but got %d", deleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	now := time.Now().UTC()
+	cases := []struct {
+		name    string
+		driver  string
+		old     []byte
+		value   []byte
+		expect  func(mock sqlmock.Sqlmock)
+		swapped bool
+	}{
+		{
+			name:   "create postgres",
+			driver: sqldb.DriverPostgres,
+			value:  []byte("pending"),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM kv WHERE name = $1 AND expires_at <= $2")).WithArgs("idempotency", now).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO kv (name, value, expires_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING")).
+					WithArgs("idempotency", []byte("pending"), nil).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			swapped: true,
+		},
+		{
+			name:   "create existing key mysql",
+			driver: sqldb.DriverMySQL,
+			value:  []byte("pending"),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM kv WHERE name = ? AND expires_at <= ?")).WithArgs("idempotency", now).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO kv (name, value, expires_at) VALUES (?, ?, ?)")).
+					WithArgs("idempotency", []byte("pending"), nil).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name:   "swap",
+			driver: sqldb.DriverPostgres,
+			old:    []byte("pending"),
+			value:  []byte("done"),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE kv SET value = $1, expires_at = $2 WHERE name = $3 AND value = $4 AND (expires_at IS NULL OR expires_at > $5)")).
+					WithArgs([]byte("done"), nil, "idempotency", []byte("pending"), now).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			swapped: true,
+		},
+		{
+			name:   "swap same value mysql",
+			driver: sqldb.DriverMySQL,
+			old:    []byte("done"),
+			value:  []byte("done"),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE kv SET value = ?, expires_at = ? WHERE name = ? AND value = ? AND (expires_at IS NULL OR expires_at > ?)")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT value FROM kv WHERE name = ?")).
+					WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte("done")))
+			},
+			swapped: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, mock := newStore(t, c.driver, now)
+			c.expect(mock)
+			swapped, err := s.CompareAndSwap(context.Background(), "idempotency", c.old, c.value, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if swapped != c.swapped {
+				t.Fatalf("expect swapped %t but got %t", c.swapped, swapped)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNewInvalidTable(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(db, &Options{Table: "kv; DROP TABLE users"}); !errors.Is(err, sqldb.ErrInvalidIdentifier) {
+		t.Fatalf("expect error %v but got %v", sqldb.ErrInvalidIdentifier, err)
+	}
+}
@@ -0,0 +1,64 @@
+package latency
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Handler return the report as json on GET, and reset the aggregated latency on DELETE
+// with ?format=text the report is returned as table, one row per operation
+func (a *Analyzer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodDelete:
+			a.Reset()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report := a.Report()
+		if r.URL.Query().Get("format") != "text" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "since %s, timeout of p%g\n", report.Since.Format("2006-01-02T15:04:05Z07:00"), report.Percentile*100)
+		fmt.Fprintln(tw, "RESOURCE\tOPERATION\tCOUNT\tERRORS\tP50\tP99\tMAX\tTIMEOUT\tPOOL")
+		for _, res := range report.Resources {
+			fmt.Fprintf(tw, "%s\t*\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", res.Resource, res.Count, res.Errors, res.P50, res.P99, res.Max,
+				timeoutText(res.SuggestedTimeout), poolText(res.SuggestedPoolSize))
+			for _, op := range res.Operations {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", res.Resource, op.Operation, op.Count, op.Errors, op.P50, op.P99, op.Max,
+					timeoutText(op.SuggestedTimeout))
+			}
+		}
+		tw.Flush()
+	})
+}
+
+// timeoutText return - for the timeout which has not enough samples
+func timeoutText(timeout time.Duration) string {
+	if timeout == 0 {
+		return "-"
+	}
+	return timeout.String()
+}
+
+// poolText return - for the pool size which has not enough samples
+func poolText(size int) string {
+	if size == 0 {
+		return "-"
+	}
+	return strconv.Itoa(size)
+}
@@ -0,0 +1,74 @@
+package latency
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// buckets of the latency histogram, every bucket is 20% wider than the previous, from 50µs to more than 10 minutes
+// so the percentile is over estimated by at most 20%, which is fine for a timeout
+var buckets = func() []time.Duration {
+	var bounds []time.Duration
+	for bound := float64(50 * time.Microsecond); bound < float64(10*time.Minute); bound *= 1.2 {
+		bounds = append(bounds, time.Duration(bound))
+	}
+	return append(bounds, math.MaxInt64)
+}()
+
+// stats of the spans of the operation or the resource
+type stats struct {
+	counts []int64
+	ok     int64
+	errors int64
+	sum    time.Duration
+	max    time.Duration
+	// minute is the unix minute of minuteCount, and peakMinute is the highest count of a minute
+	minute      int64
+	minuteCount int64
+	peakMinute  int64
+}
+
+func (s *stats) add(end time.Time, latency time.Duration, failed bool) {
+	if minute := end.Unix() / 60; minute != s.minute {
+		s.minute = minute
+		s.minuteCount = 0
+	}
+	s.minuteCount++
+	if s.minuteCount > s.peakMinute {
+		s.peakMinute = s.minuteCount
+	}
+
+	if failed {
+		s.errors++
+		return
+	}
+	if s.counts == nil {
+		s.counts = make([]int64, len(buckets))
+	}
+	s.counts[sort.Search(len(buckets), func(i int) bool { return latency <= buckets[i] })]++
+	s.ok++
+	s.sum += latency
+	if latency > s.max {
+		s.max = latency
+	}
+}
+
+// percentile return the upper bound of the bucket of the percentile, capped to the max latency
+func (s *stats) percentile(p float64) time.Duration {
+	if s.ok == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p * float64(s.ok)))
+	var seen int64
+	for idx, count := range s.counts {
+		seen += count
+		if seen >= rank {
+			if buckets[idx] > s.max {
+				return s.max
+			}
+			return buckets[idx]
+		}
+	}
+	return s.max
+}
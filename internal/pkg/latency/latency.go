@@ -0,0 +1,265 @@
+// Package latency aggregate the latency of the traced operations per resource, and recommend the timeouts and pool sizes
+// the analyzer is an opencensus exporter, so it see the same spans as the tracing backend
+//
+//	analyzer := latency.New(&latency.Options{SampleRate: 0.1})
+//	trace.RegisterExporter(analyzer)
+//	server.HandleAdmin("/debug/latency", analyzer.Handler())
+//
+// the client spans are keyed by the db.name or the http.host attribute, for example the sqldb and http client spans
+// and the server spans are keyed by ServerResource, so the route timeouts can also be tuned
+package latency
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// ServerResource is the resource of the server spans, the operation is the route of the request
+const ServerResource = "server"
+
+// default value of options
+const (
+	DefaultPercentile = 0.99
+	DefaultMultiplier = 1.5
+	DefaultHeadroom   = 1.5
+	DefaultMinSamples = 100
+)
+
+// ResourceAttributes are the span attributes of the client span resource, the first attribute which is set is used
+var ResourceAttributes = []string{"db.name", "http.host", "resource"}
+
+// Key of the aggregated latency
+type Key struct {
+	Resource  string
+	Operation string
+}
+
+// Options of analyzer
+type Options struct {
+	// Percentile of the latency which the timeout is based on, default to 0.99
+	Percentile float64
+	// Multiplier of the percentile latency to the suggested timeout, default to 1.5
+	Multiplier float64
+	// Headroom of the peak concurrency to the suggested pool size, default to 1.5
+	Headroom float64
+	// MinSamples is the minimum spans of the operation before the timeout is suggested, default to 100
+	MinSamples int64
+	// SampleRate of the traces, the observed rate is divided by the sample rate to estimate the pool size, default to 1
+	SampleRate float64
+	// Key return the key of the span, the span is not aggregated when it return false. default to DefaultKey
+	Key func(span *trace.SpanData) (Key, bool)
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// DefaultKey return the key of the client and server spans, the spans of other kind, for example the internal spans, are skipped
+// the resource of the client span is from ResourceAttributes, or the span name before / when no attribute is set
+func DefaultKey(span *trace.SpanData) (Key, bool) {
+	switch span.SpanKind {
+	case trace.SpanKindServer:
+		return Key{Resource: ServerResource, Operation: span.Name}, true
+	case trace.SpanKindClient:
+	default:
+		return Key{}, false
+	}
+	for _, attr := range ResourceAttributes {
+		if value, ok := span.Attributes[attr].(string); ok && value != "" {
+			return Key{Resource: value, Operation: span.Name}, true
+		}
+	}
+	if idx := strings.Index(span.Name, "/"); idx > 0 {
+		return Key{Resource: span.Name[:idx], Operation: span.Name[idx+1:]}, true
+	}
+	return Key{Resource: span.Name, Operation: span.Name}, true
+}
+
+// Analyzer aggregate the latency of the exported spans, Analyzer implements trace.Exporter
+type Analyzer struct {
+	opts       Options
+	mu         sync.Mutex
+	since      time.Time
+	operations map[Key]*stats
+	resources  map[string]*stats
+}
+
+// New return analyzer of the options
+func New(options *Options) *Analyzer {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Percentile <= 0 || opts.Percentile >= 1 {
+		opts.Percentile = DefaultPercentile
+	}
+	if opts.Multiplier < 1 {
+		opts.Multiplier = DefaultMultiplier
+	}
+	if opts.Headroom < 1 {
+		opts.Headroom = DefaultHeadroom
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = DefaultMinSamples
+	}
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+	if opts.Key == nil {
+		opts.Key = DefaultKey
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	a := &Analyzer{opts: opts}
+	a.Reset()
+	return a
+}
+
+// ExportSpan implements trace.Exporter, the latency of the failed span is not aggregated as it is often cut by the current timeout
+func (a *Analyzer) ExportSpan(span *trace.SpanData) {
+	key, ok := a.opts.Key(span)
+	if !ok {
+		return
+	}
+	latency := span.EndTime.Sub(span.StartTime)
+	failed := span.Status.Code != trace.StatusCodeOK
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	op, ok := a.operations[key]
+	if !ok {
+		op = &stats{}
+		a.operations[key] = op
+	}
+	op.add(span.EndTime, latency, failed)
+	res, ok := a.resources[key.Resource]
+	if !ok {
+		res = &stats{}
+		a.resources[key.Resource] = res
+	}
+	res.add(span.EndTime, latency, failed)
+}
+
+// Reset drop the aggregated latency, for example after the timeouts are changed
+func (a *Analyzer) Reset() {
+	a.mu.Lock()
+	a.since = a.opts.Now()
+	a.operations = make(map[Key]*stats)
+	a.resources = make(map[string]*stats)
+	a.mu.Unlock()
+}
+
+// Report of the recommendations
+type Report struct {
+	Since       time.Time `json:"since"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Percentile  float64   `json:"percentile"`
+	// Resources sorted by name
+	Resources []ResourceReport `json:"resources"`
+}
+
+// ResourceReport is the latency and the recommendations of the resource, for example the database or the http client
+type ResourceReport struct {
+	Resource string `json:"resource"`
+	Latency
+	// PeakRate is the highest operations per second of a minute, estimated with the sample rate
+	PeakRate float64 `json:"peak_rate"`
+	// SuggestedPoolSize is the peak concurrency by little's law with the headroom, the peak rate times the mean latency
+	// zero when the resource has not enough samples
+	SuggestedPoolSize int `json:"suggested_pool_size"`
+	// Operations sorted by name
+	Operations []OperationReport `json:"operations"`
+}
+
+// OperationReport is the latency and the recommendation of the operation, for example SELECT of the database
+type OperationReport struct {
+	Operation string `json:"operation"`
+	Latency
+}
+
+// Latency of the successful spans, and the timeout suggested from the latency
+type Latency struct {
+	Count  int64         `json:"count"`
+	Errors int64         `json:"errors"`
+	Mean   time.Duration `json:"mean"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+	// SuggestedTimeout is the latency of the percentile times the multiplier, rounded up
+	// zero when there are less than min samples
+	SuggestedTimeout time.Duration `json:"suggested_timeout"`
+}
+
+// Report return the recommendations of the aggregated latency
+func (a *Analyzer) Report() Report {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	byResource := make(map[string][]OperationReport, len(a.resources))
+	for key, op := range a.operations {
+		byResource[key.Resource] = append(byResource[key.Resource], OperationReport{
+			Operation: key.Operation,
+			Latency:   a.latency(op),
+		})
+	}
+	report := Report{
+		Since:       a.since,
+		GeneratedAt: a.opts.Now(),
+		Percentile:  a.opts.Percentile,
+		Resources:   make([]ResourceReport, 0, len(a.resources)),
+	}
+	for name, res := range a.resources {
+		operations := byResource[name]
+		sort.Slice(operations, func(i, j int) bool { return operations[i].Operation < operations[j].Operation })
+		r := ResourceReport{
+			Resource:   name,
+			Latency:    a.latency(res),
+			PeakRate:   float64(res.peakMinute) / 60 / a.opts.SampleRate,
+			Operations: operations,
+		}
+		if res.ok >= a.opts.MinSamples {
+			r.SuggestedPoolSize = int(math.Ceil(r.PeakRate * r.Mean.Seconds() * a.opts.Headroom))
+			if r.SuggestedPoolSize < 1 {
+				r.SuggestedPoolSize = 1
+			}
+		}
+		report.Resources = append(report.Resources, r)
+	}
+	sort.Slice(report.Resources, func(i, j int) bool { return report.Resources[i].Resource < report.Resources[j].Resource })
+	return report
+}
+
+func (a *Analyzer) latency(s *stats) Latency {
+	l := Latency{
+		Count:  s.ok + s.errors,
+		Errors: s.errors,
+		P50:    s.percentile(0.5),
+		P90:    s.percentile(0.9),
+		P99:    s.percentile(0.99),
+		Max:    s.max,
+	}
+	if s.ok > 0 {
+		l.Mean = s.sum / time.Duration(s.ok)
+	}
+	if s.ok >= a.opts.MinSamples {
+		l.SuggestedTimeout = roundUp(time.Duration(float64(s.percentile(a.opts.Percentile)) * a.opts.Multiplier))
+	}
+	return l
+}
+
+// roundUp the timeout to 10ms below 1s, to 100ms below 10s and to 1s above, so the suggestion is easy to configure
+func roundUp(d time.Duration) time.Duration {
+	unit := time.Second
+	switch {
+	case d < time.Second:
+		unit = 10 * time.Millisecond
+	case d < 10*time.Second:
+		unit = 100 * time.Millisecond
+	}
+	return (d + unit - 1) / unit * unit
+}
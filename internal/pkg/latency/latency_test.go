@@ -0,0 +1,136 @@
+package latency
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func span(kind int, name string, attrs map[string]interface{}, end time.Time, latency time.Duration, code int32) *trace.SpanData {
+	return &trace.SpanData{
+		SpanKind:   kind,
+		Name:       name,
+		Attributes: attrs,
+		StartTime:  end.Add(-latency),
+		EndTime:    end,
+		Status:     trace.Status{Code: code},
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	a := New(&Options{MinSamples: 10, SampleRate: 0.5, Now: func() time.Time { return now }})
+
+	db := map[string]interface{}{"db.name": "billing"}
+	// failed insert is counted but its latency is not aggregated
+	for i := 0; i < 5; i++ {
+		a.ExportSpan(span(trace.SpanKindClient, "sqldb/INSERT", db, now, 5*time.Millisecond, trace.StatusCodeOK))
+	}
+	a.ExportSpan(span(trace.SpanKindClient, "sqldb/INSERT", db, now, time.Second, trace.StatusCodeDeadlineExceeded))
+	a.ExportSpan(span(trace.SpanKindServer, "/v1/invoices", nil, now, 50*time.Millisecond, trace.StatusCodeOK))
+	a.ExportSpan(span(trace.SpanKindClient, "payments/charge", nil, now, 50*time.Millisecond, trace.StatusCodeOK))
+	// internal span is skipped
+	a.ExportSpan(span(trace.SpanKindUnspecified, "kothak/new", nil, now, time.Second, trace.StatusCodeOK))
+	// 120 selects in the first minute and 60 in the second, 1% of the selects take 200ms
+	for i := 0; i < 180; i++ {
+		latency := 10 * time.Millisecond
+		if i%100 == 99 {
+			latency = 200 * time.Millisecond
+		}
+		a.ExportSpan(span(trace.SpanKindClient, "sqldb/SELECT", db, now.Add(time.Duration(i/120)*time.Minute), latency, trace.StatusCodeOK))
+	}
+
+	report := a.Report()
+	if len(report.Resources) != 3 {
+		t.Fatalf("expecting 3 resources but got %+v", report.Resources)
+	}
+	billing := report.Resources[0]
+	if billing.Resource != "billing" || billing.Count != 186 || billing.Errors != 1 || len(billing.Operations) != 2 {
+		t.Fatalf("unexpected billing report %+v", billing)
+	}
+	// 126 spans in the first minute with the sample rate of 0.5
+	if expect := 126.0 / 60 / 0.5; billing.PeakRate != expect {
+		t.Fatalf("expecting peak rate %v but got %v", expect, billing.PeakRate)
+	}
+	if billing.SuggestedPoolSize < 1 {
+		t.Fatalf("expecting suggested pool size but got %d", billing.SuggestedPoolSize)
+	}
+
+	insert, selects := billing.Operations[0], billing.Operations[1]
+	if insert.Operation != "sqldb/INSERT" || insert.Count != 6 || insert.Errors != 1 || insert.Max != 5*time.Millisecond {
+		t.Fatalf("unexpected insert report %+v", insert)
+	}
+	if insert.SuggestedTimeout != 0 {
+		t.Fatalf("expecting no suggested timeout of insert with less than min samples but got %s", insert.SuggestedTimeout)
+	}
+	// p99 of the selects is in the bucket of 10ms, the 200ms outliers are the last 1%
+	if selects.P99 < 10*time.Millisecond || selects.P99 > 12*time.Millisecond || selects.Max != 200*time.Millisecond {
+		t.Fatalf("unexpected select latency %+v", selects.Latency)
+	}
+	if selects.SuggestedTimeout < 15*time.Millisecond || selects.SuggestedTimeout > 20*time.Millisecond || selects.SuggestedTimeout%(10*time.Millisecond) != 0 {
+		t.Fatalf("expecting suggested timeout of p99 times 1.5 rounded up to 10ms but got %s", selects.SuggestedTimeout)
+	}
+
+	if report.Resources[1].Resource != "payments" || report.Resources[1].Operations[0].Operation != "charge" {
+		t.Fatalf("expecting resource from the span name but got %+v", report.Resources[1])
+	}
+	if report.Resources[2].Resource != ServerResource || report.Resources[2].Operations[0].Operation != "/v1/invoices" {
+		t.Fatalf("expecting server resource but got %+v", report.Resources[2])
+	}
+
+	a.Reset()
+	if report := a.Report(); len(report.Resources) != 0 {
+		t.Fatalf("expecting empty report after reset but got %+v", report.Resources)
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	cases := []struct {
+		d      time.Duration
+		expect time.Duration
+	}{
+		{15 * time.Millisecond, 20 * time.Millisecond},
+		{1234 * time.Millisecond, 1300 * time.Millisecond},
+		{12345 * time.Millisecond, 13 * time.Second},
+		{time.Second, time.Second},
+	}
+
+	for _, c := range cases {
+		if got := roundUp(c.d); got != c.expect {
+			t.Errorf("expecting %s of %s but got %s", c.expect, c.d, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a := New(&Options{MinSamples: 1})
+	a.ExportSpan(span(trace.SpanKindClient, "GET /users", map[string]interface{}{"http.host": "users.internal"}, time.Now(), 30*time.Millisecond, trace.StatusCodeOK))
+	handler := a.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/latency", nil))
+	report := Report{}
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Resources) != 1 || report.Resources[0].Resource != "users.internal" || report.Resources[0].SuggestedTimeout != 50*time.Millisecond {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/latency?format=text", nil))
+	if !strings.Contains(rec.Body.String(), "users.internal  GET /users") {
+		t.Fatalf("expecting operation row but got\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/debug/latency", nil))
+	if rec.Code != http.StatusNoContent || len(a.Report().Resources) != 0 {
+		t.Fatalf("expecting reset but got %d %+v", rec.Code, a.Report())
+	}
+}
@@ -0,0 +1,44 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/tabwriter"
+	"time"
+)
+
+// Handler return the report as json, with ?format=text the phases are returned as timeline table
+func (r *Recorder) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report := r.Report()
+		if req.URL.Query().Get("format") != "text" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "started %s, %s\n", report.Start.Format(time.RFC3339), readyText(report))
+		fmt.Fprintln(tw, "OFFSET\tDURATION\tPHASE\tERROR")
+		for _, phase := range report.Phases {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", phase.Start.Sub(report.Start).Round(time.Millisecond),
+				phase.Duration.Round(time.Millisecond), phase.Name, phase.Error)
+		}
+		tw.Flush()
+	})
+}
+
+// readyText return the duration until ready, or not ready yet
+func readyText(report Report) string {
+	if report.Ready.IsZero() {
+		return "not ready yet"
+	}
+	return "ready after " + report.ReadyAfter.Round(time.Millisecond).String()
+}
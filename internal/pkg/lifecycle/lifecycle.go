@@ -0,0 +1,151 @@
+// lifecycle record the phases of the program startup and shutdown with their durations
+// for example config load, every resource init, server start, drain and close, to see why the program is slow to be ready
+
+package lifecycle
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Phase of the lifecycle
+type Phase struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	// Error of the phase, empty when the phase succeed
+	Error string `json:"error,omitempty"`
+}
+
+// Report of the lifecycle, the phases are sorted by the start time
+type Report struct {
+	Start time.Time `json:"start"`
+	// Ready is the time when the program is ready to serve, zero when it is not ready yet
+	Ready time.Time `json:"ready"`
+	// ReadyAfter is the duration from the start until ready
+	ReadyAfter time.Duration `json:"ready_after"`
+	Phases     []Phase       `json:"phases"`
+}
+
+// Options of recorder
+type Options struct {
+	// OnPhase is invoked after every phase is recorded, for example to write the phase to the log
+	OnPhase func(phase Phase)
+	// Now is used for the time of the phases, default to time.Now
+	Now func() time.Time
+}
+
+// Recorder of the lifecycle phases, the methods of nil recorder do nothing
+// so the packages record the phases without knowing whether the recorder is set
+type Recorder struct {
+	opts  Options
+	start time.Time
+
+	mu     sync.Mutex
+	ready  time.Time
+	phases []Phase
+}
+
+type recorderKey struct{}
+
+// New recorder, the start of the lifecycle is the time of New
+func New(options *Options) *Recorder {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Recorder{opts: opts, start: opts.Now()}
+}
+
+// WithRecorder return the context with the recorder, for example to record the phases of kothak.New
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext return the recorder of WithRecorder, nil when it is not set
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// Begin the phase, the phase is recorded with the error of the returned function
+//
+//	end := recorder.Begin("config/load")
+//	err := load()
+//	end(err)
+func (r *Recorder) Begin(name string) func(err error) {
+	if r == nil {
+		return func(err error) {}
+	}
+	start := r.opts.Now()
+	return func(err error) {
+		r.Record(name, start, err)
+	}
+}
+
+// Record the phase which started at start and ended now
+func (r *Recorder) Record(name string, start time.Time, err error) {
+	if r == nil {
+		return
+	}
+	end := r.opts.Now()
+	phase := Phase{
+		Name:     name,
+		Start:    start,
+		End:      end,
+		Duration: end.Sub(start),
+	}
+	if err != nil {
+		phase.Error = err.Error()
+	}
+	r.mu.Lock()
+	r.phases = append(r.phases, phase)
+	r.mu.Unlock()
+	if r.opts.OnPhase != nil {
+		r.opts.OnPhase(phase)
+	}
+}
+
+// Ready mark the program as ready to serve, only the first call is recorded
+func (r *Recorder) Ready() {
+	if r == nil {
+		return
+	}
+	now := r.opts.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ready.IsZero() {
+		r.ready = now
+	}
+}
+
+// Report return the recorded phases
+func (r *Recorder) Report() Report {
+	if r == nil {
+		return Report{}
+	}
+	r.mu.Lock()
+	report := Report{
+		Start:  r.start,
+		Ready:  r.ready,
+		Phases: append([]Phase(nil), r.phases...),
+	}
+	r.mu.Unlock()
+	if !report.Ready.IsZero() {
+		report.ReadyAfter = report.Ready.Sub(report.Start)
+	}
+	// the outer phase is listed before the phases inside it
+	sort.SliceStable(report.Phases, func(i, j int) bool {
+		if !report.Phases[i].Start.Equal(report.Phases[j].Start) {
+			return report.Phases[i].Start.Before(report.Phases[j].Start)
+		}
+		return report.Phases[i].Duration > report.Phases[j].Duration
+	})
+	return report
+}
@@ -0,0 +1,102 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock advance one second on every call
+func clock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	var logged []string
+	r := New(&Options{
+		Now:     clock(start),
+		OnPhase: func(phase Phase) { logged = append(logged, phase.Name) },
+	})
+
+	endInit := r.Begin("resources/init")
+	endDB := r.Begin("resources/database/users")
+	endDB(errors.New("connection refused"))
+	endInit(nil)
+	r.Ready()
+	r.Ready()
+
+	report := r.Report()
+	if !report.Start.Equal(start.Add(time.Second)) || report.ReadyAfter != 5*time.Second {
+		t.Fatalf("unexpected start and ready after %s %s", report.Start, report.ReadyAfter)
+	}
+	if len(report.Phases) != 2 {
+		t.Fatalf("expecting 2 phases but got %+v", report.Phases)
+	}
+	// the outer phase is listed first
+	if report.Phases[0].Name != "resources/init" || report.Phases[0].Duration != 3*time.Second {
+		t.Fatalf("unexpected outer phase %+v", report.Phases[0])
+	}
+	if report.Phases[1].Error != "connection refused" || report.Phases[1].Duration != time.Second {
+		t.Fatalf("unexpected inner phase %+v", report.Phases[1])
+	}
+	if strings.Join(logged, ",") != "resources/database/users,resources/init" {
+		t.Fatalf("expecting the phases to be logged when they end but got %v", logged)
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	r := FromContext(context.Background())
+	if r != nil {
+		t.Fatal("expecting nil recorder")
+	}
+	r.Begin("config/load")(nil)
+	r.Ready()
+	if report := r.Report(); len(report.Phases) != 0 {
+		t.Fatalf("expecting empty report but got %+v", report)
+	}
+
+	recorder := New(nil)
+	if FromContext(WithRecorder(context.Background(), recorder)) != recorder {
+		t.Fatal("expecting the recorder of the context")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := New(&Options{Now: clock(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))})
+	r.Begin("config/load")(nil)
+	handler := r.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/lifecycle?format=text", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "not ready yet") || !strings.Contains(body, "1s      1s        config/load") {
+		t.Fatalf("unexpected text report\n%s", body)
+	}
+
+	r.Ready()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/lifecycle", nil))
+	report := Report{}
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.ReadyAfter != 3*time.Second || len(report.Phases) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/lifecycle", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expecting %d but got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
@@ -0,0 +1,185 @@
+// Package loadtest is a small load driver to measure throughput and latency of a function under concurrency
+// the result can be written in go benchmark format, so it can be compared with benchstat the same way as go test -bench output
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/concurrent"
+)
+
+// list of error
+var (
+	ErrFuncNil     = errors.New("loadtest: function is nil")
+	ErrNoStopLimit = errors.New("loadtest: duration or requests must be set")
+)
+
+// Options of load test
+type Options struct {
+	// Concurrency is the number of workers calling the function, default to 1
+	Concurrency int
+	// Duration of the load test, the test is stopped when either duration or requests is reached
+	Duration time.Duration
+	// Requests is the total number of calls
+	Requests int
+	// Warmup calls of each worker which are not measured
+	Warmup int
+}
+
+// Result of load test
+type Result struct {
+	Requests int
+	Errors   int
+	Duration time.Duration
+	// latencies of successful and failed calls
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// Throughput return the number of calls per second
+func (r Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// WriteBenchmark write the result as a go benchmark line, for example
+//
+//	BenchmarkRedisGet-8   10000   52000 ns/op   48000 p50-ns/op   91000 p99-ns/op   19230 ops/s   0 errors
+//
+// ns/op is the mean latency of a single call, not the wall time divided by calls
+func (r Result) WriteBenchmark(w io.Writer, name string, concurrency int) error {
+	_, err := fmt.Fprintf(w, "Benchmark%s-%d\t%d\t%d ns/op\t%d p50-ns/op\t%d p99-ns/op\t%.0f ops/s\t%d errors\n",
+		name, concurrency, r.Requests, r.Mean.Nanoseconds(), r.P50.Nanoseconds(), r.P99.Nanoseconds(), r.Throughput(), r.Errors)
+	return err
+}
+
+// Run call the function concurrently until the duration or number of requests is reached
+// the context passed to the function is canceled when the duration is reached
+func Run(ctx context.Context, options *Options, fn func(ctx context.Context) error) (Result, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if fn == nil {
+		return Result{}, ErrFuncNil
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return Result{}, ErrNoStopLimit
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	for i := 0; i < opts.Warmup*opts.Concurrency; i++ {
+		fn(ctx)
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errCount  int
+		// tokens limit the total number of requests, nil means unlimited
+		tokens chan struct{}
+	)
+	if opts.Requests > 0 {
+		tokens = make(chan struct{}, opts.Requests)
+		for i := 0; i < opts.Requests; i++ {
+			tokens <- struct{}{}
+		}
+		close(tokens)
+	}
+
+	start := time.Now()
+	// a panic of the function stop all workers and is returned as the error of the load test
+	g, _ := concurrent.NewGroup(ctx, "loadtest/worker", opts.Concurrency)
+	for i := 0; i < opts.Concurrency; i++ {
+		g.Go(func(ctx context.Context) error {
+			var (
+				local  = make([]time.Duration, 0, 1024)
+				failed int
+			)
+			for {
+				if ctx.Err() != nil {
+					break
+				}
+				if tokens != nil {
+					if _, ok := <-tokens; !ok {
+						break
+					}
+				}
+				callStart := time.Now()
+				err := fn(ctx)
+				// call is canceled by the end of the test, so it is not counted
+				if err != nil && ctx.Err() != nil {
+					break
+				}
+				local = append(local, time.Since(callStart))
+				if err != nil {
+					failed++
+				}
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			errCount += failed
+			mu.Unlock()
+			return nil
+		})
+	}
+	// the workers might not start when the duration is already reached
+	if err := g.Wait(); err != nil && err != ctx.Err() {
+		return Result{}, fmt.Errorf("loadtest: worker failed: %w", err)
+	}
+
+	result := Result{
+		Requests: len(latencies),
+		Errors:   errCount,
+		Duration: time.Since(start),
+	}
+	summarize(&result, latencies)
+	return result, nil
+}
+
+// summarize set the latency distribution of the result
+func summarize(result *Result, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) time.Duration {
+		idx := int(float64(len(latencies))*p+0.5) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(latencies) {
+			idx = len(latencies) - 1
+		}
+		return latencies[idx]
+	}
+	result.Mean = total / time.Duration(len(latencies))
+	result.P50 = percentile(0.5)
+	result.P90 = percentile(0.9)
+	result.P99 = percentile(0.99)
+	result.Max = latencies[len(latencies)-1]
+}
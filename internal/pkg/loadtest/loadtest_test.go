@@ -0,0 +1,95 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+func TestRunRequests(t *testing.T) {
+	var calls int32
+	errFailed := errors.New("failed")
+	result, err := Run(context.Background(), &Options{Concurrency: 4, Requests: 100}, func(ctx context.Context) error {
+		if atomic.AddInt32(&calls, 1)%10 == 0 {
+			return errFailed
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 100 || calls != 100 {
+		t.Errorf("expecting 100 requests, got %d with %d calls", result.Requests, calls)
+	}
+	if result.Errors != 10 {
+		t.Errorf("expecting 10 errors, got %d", result.Errors)
+	}
+	if result.P50 > result.P99 || result.P99 > result.Max {
+		t.Errorf("invalid latency distribution %+v", result)
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	result, err := Run(context.Background(), &Options{Concurrency: 2, Duration: time.Millisecond * 50}, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests == 0 {
+		t.Fatal("expecting requests when running by duration")
+	}
+	// calls canceled by the end of the test are not counted as error
+	if result.Errors != 0 {
+		t.Errorf("expecting no error, got %d", result.Errors)
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	var calls int32
+	_, err := Run(context.Background(), &Options{Concurrency: 4, Requests: 100}, func(ctx context.Context) error {
+		if atomic.AddInt32(&calls, 1) == 10 {
+			panic("boom")
+		}
+		return nil
+	})
+	var perr *safego.PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expecting panic error but got %v", err)
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	if _, err := Run(context.Background(), &Options{Requests: 1}, nil); !errors.Is(err, ErrFuncNil) {
+		t.Errorf("expecting error %v, got %v", ErrFuncNil, err)
+	}
+	if _, err := Run(context.Background(), nil, func(ctx context.Context) error { return nil }); !errors.Is(err, ErrNoStopLimit) {
+		t.Errorf("expecting error %v, got %v", ErrNoStopLimit, err)
+	}
+}
+
+func TestWriteBenchmark(t *testing.T) {
+	result := Result{Requests: 10, Duration: time.Second, Mean: time.Millisecond, P50: time.Millisecond, P99: time.Millisecond * 2}
+	buff := bytes.NewBuffer(nil)
+	if err := result.WriteBenchmark(buff, "RedisGet", 4); err != nil {
+		t.Fatal(err)
+	}
+	expect := "BenchmarkRedisGet-4\t10\t1000000 ns/op\t1000000 p50-ns/op\t2000000 p99-ns/op\t10 ops/s\t0 errors\n"
+	if buff.String() != expect {
+		t.Errorf("expecting %q, got %q", expect, buff.String())
+	}
+	if !strings.HasPrefix(buff.String(), "Benchmark") {
+		t.Error("benchmark line must start with Benchmark")
+	}
+}
@@ -29,20 +29,28 @@ var levelFormat = []string{
 
 // New standard logger
 func New(config *logger.Config) (*Logger, error) {
-	return nil, nil
-}
-
-func newLogger(config *logger.Config) (*log.Logger, error) {
-	stdLogger := &log.Logger{}
-
 	if config == nil {
 		config = &logger.Config{
 			Level:      logger.InfoLevel,
 			TimeFormat: logger.DefaultTimeFormat,
 		}
-		stdLogger.SetOutput(os.Stderr)
 	}
 
+	stdLogger, err := newLogger(config)
+	if err != nil {
+		return nil, err
+	}
+
+	l := Logger{
+		logger: stdLogger,
+		config: config,
+	}
+	return &l, nil
+}
+
+func newLogger(config *logger.Config) (*log.Logger, error) {
+	stdLogger := log.New(os.Stderr, "", log.LstdFlags)
+
 	if config.TimeFormat == "" {
 		config.TimeFormat = logger.DefaultTimeFormat
 	}
@@ -177,7 +185,7 @@ func (l *Logger) print(level logger.Level, args ...interface{}) {
 	if level < l.config.Level {
 		return
 	}
-	l.logger.Print(levelFormat[level], args)
+	l.logger.Print(append([]interface{}{levelFormat[level]}, args...)...)
 }
 
 func (l *Logger) printf(level logger.Level, format string, v ...interface{}) {
@@ -185,14 +193,14 @@ func (l *Logger) printf(level logger.Level, format string, v ...interface{}) {
 		return
 	}
 	format = levelFormat[level] + format
-	l.logger.Printf(format, v)
+	l.logger.Printf(format, v...)
 }
 
 func (l *Logger) println(level logger.Level, args ...interface{}) {
 	if level < l.config.Level {
 		return
 	}
-	l.logger.Println(levelFormat[level], args)
+	l.logger.Println(append([]interface{}{levelFormat[level]}, args...)...)
 }
 
 func (l *Logger) printw(level logger.Level, message string, fields logger.KV) {
@@ -0,0 +1,149 @@
+// Package mongodb wrap mongo client with leader and follower database
+// leader always read from the primary, follower read based on the configured read preference
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// list of error
+var (
+	ErrURIEmpty      = errors.New("mongodb: uri is empty")
+	ErrURIInvalid    = errors.New("mongodb: uri must start with mongodb:// or mongodb+srv://")
+	ErrDatabaseEmpty = errors.New("mongodb: database name is empty")
+)
+
+// DB struct to hold mongodb client and database
+type DB struct {
+	client   *mongo.Client
+	leader   *mongo.Database
+	follower *mongo.Database
+}
+
+// ConnectOptions to list options when connect to mongodb
+type ConnectOptions struct {
+	// Retry is the number of connect attempts, the wait time between attempts is increased exponentially
+	Retry          int
+	MaxPoolSize    uint64
+	MinPoolSize    uint64
+	ConnectTimeout time.Duration
+	// ReadPreference of follower, for example secondaryPreferred
+	// default to primary
+	ReadPreference string
+}
+
+// ValidateURI check whether the uri is a mongodb connection string
+func ValidateURI(uri string) error {
+	if uri == "" {
+		return ErrURIEmpty
+	}
+	if !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		return ErrURIInvalid
+	}
+	return nil
+}
+
+// ParseReadPreference parse the read preference mode, empty string is treated as primary
+func ParseReadPreference(pref string) (*readpref.ReadPref, error) {
+	if pref == "" {
+		return readpref.Primary(), nil
+	}
+	mode, err := readpref.ModeFromString(pref)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: invalid read preference %s: %w", pref, err)
+	}
+	return readpref.New(mode)
+}
+
+// Connect to mongodb and ping the primary to check the connection
+func Connect(ctx context.Context, uri, database string, connOpts *ConnectOptions) (*DB, error) {
+	opts := connOpts
+	if opts == nil {
+		opts = &ConnectOptions{}
+	}
+	if err := ValidateURI(uri); err != nil {
+		return nil, err
+	}
+	if database == "" {
+		return nil, ErrDatabaseEmpty
+	}
+	followerPref, err := ParseReadPreference(opts.ReadPreference)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts := options.Client().ApplyURI(uri)
+	if opts.MaxPoolSize > 0 {
+		clientOpts.SetMaxPoolSize(opts.MaxPoolSize)
+	}
+	if opts.MinPoolSize > 0 {
+		clientOpts.SetMinPoolSize(opts.MinPoolSize)
+	}
+	if opts.ConnectTimeout > 0 {
+		clientOpts.SetConnectTimeout(opts.ConnectTimeout)
+	}
+
+	client, err := mongo.NewClient(clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	attempts := opts.Retry
+	if attempts <= 0 {
+		attempts = 1
+	}
+	policy := retry.Policy{
+		MaxAttempts:     attempts,
+		InitialInterval: time.Second * 3,
+		MaxInterval:     time.Second * 30,
+		Jitter:          0.2,
+	}
+	err = retry.Do(ctx, &policy, func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("mongodb: failed connect to database: %w", err)
+	}
+
+	db := DB{
+		client:   client,
+		leader:   client.Database(database, options.Database().SetReadPreference(readpref.Primary())),
+		follower: client.Database(database, options.Database().SetReadPreference(followerPref)),
+	}
+	return &db, nil
+}
+
+// Leader return database which always read from primary
+func (db *DB) Leader() *mongo.Database {
+	return db.leader
+}
+
+// Follower return database which read based on the read preference
+func (db *DB) Follower() *mongo.Database {
+	return db.follower
+}
+
+// Client return the mongo client
+func (db *DB) Client() *mongo.Client {
+	return db.client
+}
+
+// Close the connection to mongodb
+func (db *DB) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return db.client.Disconnect(ctx)
+}
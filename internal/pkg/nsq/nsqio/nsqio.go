@@ -46,27 +46,42 @@ type CompressionConfig struct {
 	Snappy       bool `toml:"snappy" yaml:"snappy"`
 }
 
+// newConfig return the nsq config, the default value of nsq is kept when the config is empty
 func newConfig(conf Config) (*nsqio.Config, error) {
 	cfg := nsqio.NewConfig()
+	setInt := func(dst *int, value int) {
+		if value != 0 {
+			*dst = value
+		}
+	}
+	setDuration := func(dst *time.Duration, value time.Duration) {
+		if value != 0 {
+			*dst = value
+		}
+	}
 
 	// basic
-	cfg.Hostname = conf.Hostname
+	if conf.Hostname != "" {
+		cfg.Hostname = conf.Hostname
+	}
 	// queue
-	cfg.MaxInFlight = conf.Queue.MaxInFlight
-	cfg.MsgTimeout = conf.Queue.MsgTimeout
-	cfg.MaxRequeueDelay = conf.Queue.MaxRequeueDelay
-	cfg.DefaultRequeueDelay = conf.Queue.DefaultRequeueDelay
+	setInt(&cfg.MaxInFlight, conf.Queue.MaxInFlight)
+	setDuration(&cfg.MsgTimeout, conf.Queue.MsgTimeout)
+	setDuration(&cfg.MaxRequeueDelay, conf.Queue.MaxRequeueDelay)
+	setDuration(&cfg.DefaultRequeueDelay, conf.Queue.DefaultRequeueDelay)
 	// timeout
-	cfg.DialTimeout = conf.Timeout.Dial
-	cfg.ReadTimeout = conf.Timeout.Read
-	cfg.WriteTimeout = conf.Timeout.Write
-	cfg.MsgTimeout = conf.Timeout.MessageTimeout
+	setDuration(&cfg.DialTimeout, conf.Timeout.Dial)
+	setDuration(&cfg.ReadTimeout, conf.Timeout.Read)
+	setDuration(&cfg.WriteTimeout, conf.Timeout.Write)
+	setDuration(&cfg.MsgTimeout, conf.Timeout.MessageTimeout)
 	// lookupd config
-	cfg.LookupdPollInterval = conf.Lookupd.PoolInterval
-	cfg.LookupdPollJitter = conf.Lookupd.PollJitter
+	setDuration(&cfg.LookupdPollInterval, conf.Lookupd.PoolInterval)
+	if conf.Lookupd.PollJitter != 0 {
+		cfg.LookupdPollJitter = conf.Lookupd.PollJitter
+	}
 	// compression
 	cfg.Deflate = conf.Compression.Deflate
-	cfg.DeflateLevel = conf.Compression.DeflateLevel
+	setInt(&cfg.DeflateLevel, conf.Compression.DeflateLevel)
 	cfg.Snappy = conf.Compression.Snappy
 
 	return cfg, cfg.Validate()
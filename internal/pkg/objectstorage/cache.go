@@ -0,0 +1,304 @@
+package objectstorage
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+)
+
+// default value of cache options
+const (
+	DefaultCachePrefix        = "objcache:"
+	DefaultCacheTTL           = time.Minute
+	DefaultCacheMaxObjectSize = 256 << 10
+	DefaultCacheMaxBytes      = 64 << 20
+)
+
+// errObjectTooLarge is returned by the loader when the object is larger than the max object size
+var errObjectTooLarge = errors.New("objectstorage: object is too large to be cached")
+
+// CacheOptions of the read-through cache of small objects
+type CacheOptions struct {
+	// MaxObjectSize of the cached object in bytes, larger object is always read from the storage, default to 256KiB
+	MaxObjectSize int64
+	// TTL of the cached object in memory and in redis, default to 1 minute
+	TTL time.Duration
+	// MaxBytes of the objects in memory, the least recently used objects are evicted, default to 64MiB
+	MaxBytes int64
+	// Redis is the second layer which is shared by the instances, the objects are only cached in memory when nil
+	Redis redis.Redis
+	// Prefix of the keys in redis, default to objcache:<bucket name>:
+	Prefix string
+	// OnError is called when redis failed, the object is read from the storage on redis error
+	OnError func(err error)
+}
+
+// objectCache of the small objects in memory and redis
+type objectCache struct {
+	opts   CacheOptions
+	memory *objectLRU
+
+	mu    sync.Mutex
+	calls map[string]*objectCall
+	// epoch is increased on every invalidation, the loaded object is not cached when the epoch changed while loading
+	epoch uint64
+}
+
+// objectCall is the load which is running in this instance, the callers of the same key wait for its result
+type objectCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// SetCache enable the read-through cache of Download, DownloadByte and DownloadFile before the storage is used
+// upload and delete of the storage invalidate the object in this instance and in redis,
+// the other instances might serve the old object from their memory until the ttl
+// the object which is written with Stream is not invalidated, the cache is disabled when options is nil
+func (s *Storage) SetCache(options *CacheOptions) {
+	if options == nil {
+		s.cache = nil
+		return
+	}
+	opts := *options
+	if opts.MaxObjectSize <= 0 {
+		opts.MaxObjectSize = DefaultCacheMaxObjectSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultCacheMaxBytes
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultCachePrefix + s.storage.BucketName() + ":"
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+	s.cache = &objectCache{
+		opts:   opts,
+		memory: newObjectLRU(opts.MaxBytes),
+		calls:  make(map[string]*objectCall),
+	}
+}
+
+// read return the reader of the object, from the cache when it is enabled
+// only one download of the key is running at a time in this instance when the object is not cached
+func (s *Storage) read(ctx context.Context, key string, readOptions *ReadOptions) (io.ReadCloser, error) {
+	c := s.cache
+	if c == nil {
+		reader, err := s.download(ctx, key, readOptions)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	if data, ok := c.get(ctx, key); ok {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	// large object is returned to the caller which load the key, it is only known after the reader is opened
+	var large io.ReadCloser
+	data, err := c.do(key, func(epoch uint64) ([]byte, error) {
+		reader, err := s.download(ctx, key, readOptions)
+		if err != nil {
+			return nil, err
+		}
+		if reader.Size() > c.opts.MaxObjectSize {
+			large = reader
+			return nil, errObjectTooLarge
+		}
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		c.set(ctx, key, data, epoch)
+		return data, nil
+	})
+	if large != nil {
+		return large, nil
+	}
+	if err == errObjectTooLarge {
+		// the caller waited for the load of a large object, so it open its own reader
+		reader, err := s.download(ctx, key, readOptions)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// get the cached object from memory, then from redis
+func (c *objectCache) get(ctx context.Context, key string) ([]byte, bool) {
+	if data, ok := c.memory.get(key, time.Now()); ok {
+		return data, true
+	}
+	if c.opts.Redis == nil {
+		return nil, false
+	}
+	value, err := c.opts.Redis.Get(ctx, c.opts.Prefix+key)
+	if c.opts.Redis.IsErrNil(err) {
+		return nil, false
+	}
+	if err != nil {
+		c.opts.OnError(fmt.Errorf("objectstorage: failed to get cache %s: %w", key, err))
+		return nil, false
+	}
+	data := []byte(value)
+	c.memory.set(key, data, time.Now().Add(c.opts.TTL))
+	return data, true
+}
+
+// set the object in memory and redis, unless the object is invalidated since the load started
+func (c *objectCache) set(ctx context.Context, key string, data []byte, epoch uint64) {
+	c.mu.Lock()
+	invalidated := c.epoch != epoch
+	c.mu.Unlock()
+	if invalidated {
+		return
+	}
+	c.memory.set(key, data, time.Now().Add(c.opts.TTL))
+	if c.opts.Redis == nil {
+		return
+	}
+	if _, err := c.opts.Redis.SetEX(ctx, c.opts.Prefix+key, string(data), expirySeconds(c.opts.TTL)); err != nil {
+		c.opts.OnError(fmt.Errorf("objectstorage: failed to set cache %s: %w", key, err))
+	}
+}
+
+// invalidate delete the object from memory and redis, the running load of the key is not joined anymore
+func (c *objectCache) invalidate(ctx context.Context, key string) {
+	c.mu.Lock()
+	c.epoch++
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	c.memory.delete(key)
+	if c.opts.Redis == nil {
+		return
+	}
+	if _, err := c.opts.Redis.Delete(ctx, c.opts.Prefix+key); err != nil {
+		c.opts.OnError(fmt.Errorf("objectstorage: failed to invalidate cache %s: %w", key, err))
+	}
+}
+
+// do run the load once for the key at the same time in this instance
+func (c *objectCache) do(key string, fn func(epoch uint64) ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	call := &objectCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	call.data, call.err = fn(epoch)
+	call.wg.Done()
+
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	return call.data, call.err
+}
+
+// expirySeconds of the redis expiry, rounded up so the key always expire
+func expirySeconds(d time.Duration) int {
+	s := int((d + time.Second - 1) / time.Second)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+// objectLRU is an in memory least recently used cache bounded by the size of the objects
+type objectLRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type objectItem struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+func newObjectLRU(maxBytes int64) *objectLRU {
+	return &objectLRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *objectLRU) get(key string, now time.Time) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*objectItem)
+	if now.After(item.expiresAt) {
+		l.removeElement(el)
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return item.data, true
+}
+
+// set the object and evict the least recently used objects until the cache fit the max bytes
+func (l *objectLRU) set(key string, data []byte, expiresAt time.Time) {
+	if int64(len(key)+len(data)) > l.maxBytes {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+	l.items[key] = l.ll.PushFront(&objectItem{key: key, data: data, expiresAt: expiresAt})
+	l.bytes += int64(len(key) + len(data))
+	for l.bytes > l.maxBytes {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+func (l *objectLRU) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+func (l *objectLRU) removeElement(el *list.Element) {
+	item := l.ll.Remove(el).(*objectItem)
+	delete(l.items, item.key)
+	l.bytes -= int64(len(item.key) + len(item.data))
+}
@@ -0,0 +1,145 @@
+package objectstorage_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newCachedStorage return two storages of the same bucket which share the redis cache, and the count of downloads from the bucket
+func newCachedStorage(t *testing.T, mr *miniredis.Miniredis) (*objectstorage.Storage, *objectstorage.Storage, *int64) {
+	t.Helper()
+	r, err := redigo.New(context.Background(), mr.Addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var downloads int64
+	provider := memory.New("templates")
+	storages := make([]*objectstorage.Storage, 2)
+	for i := range storages {
+		storages[i] = objectstorage.New(provider)
+		storages[i].AddHook(func(ctx context.Context, event objectstorage.OperationEvent) {
+			if event.Operation == objectstorage.OperationDownload {
+				atomic.AddInt64(&downloads, 1)
+			}
+		})
+		storages[i].SetCache(&objectstorage.CacheOptions{MaxObjectSize: 16, Redis: r})
+	}
+	return storages[0], storages[1], &downloads
+}
+
+func TestCache(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	s1, s2, downloads := newCachedStorage(t, mr)
+	ctx := context.Background()
+
+	if _, err := s1.UploadByte(ctx, []byte("hello"), "welcome.tmpl", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		data, err := s1.DownloadByte(ctx, "welcome.tmpl", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "hello" {
+			t.Fatalf("expecting hello but got %s", data)
+		}
+	}
+	// the second storage read the object from redis
+	if data, err := s2.DownloadByte(ctx, "welcome.tmpl", nil); err != nil || string(data) != "hello" {
+		t.Fatalf("expecting hello from redis but got %s %v", data, err)
+	}
+	if got := atomic.LoadInt64(downloads); got != 1 {
+		t.Fatalf("expecting 1 download but got %d", got)
+	}
+	if !mr.Exists("objcache:templates:welcome.tmpl") {
+		t.Fatal("expecting the object in redis")
+	}
+
+	// upload invalidate the object in memory and redis
+	if _, err := s1.UploadByte(ctx, []byte("hello again"), "welcome.tmpl", nil); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("objcache:templates:welcome.tmpl") {
+		t.Fatal("expecting the object to be invalidated in redis")
+	}
+	if data, err := s1.DownloadByte(ctx, "welcome.tmpl", nil); err != nil || string(data) != "hello again" {
+		t.Fatalf("expecting the new object but got %s %v", data, err)
+	}
+
+	// delete invalidate the object
+	if err := s1.Delete(ctx, "welcome.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s1.DownloadByte(ctx, "welcome.tmpl", nil); err == nil {
+		t.Fatal("expecting error of the deleted object")
+	}
+
+	// object larger than the max object size is not cached
+	large := bytes.Repeat([]byte("a"), 32)
+	if _, err := s1.UploadByte(ctx, large, "large.tmpl", nil); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt64(downloads)
+	for i := 0; i < 2; i++ {
+		data, err := s1.DownloadByte(ctx, "large.tmpl", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, large) {
+			t.Fatalf("expecting the large object but got %s", data)
+		}
+	}
+	if got := atomic.LoadInt64(downloads) - before; got != 2 {
+		t.Fatalf("expecting 2 downloads of the large object but got %d", got)
+	}
+}
+
+func TestCacheRedisError(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, _, downloads := newCachedStorage(t, mr)
+	ctx := context.Background()
+	if _, err := s1.UploadByte(ctx, []byte("hello"), "welcome.tmpl", nil); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := s1.DownloadByte(ctx, "welcome.tmpl", nil)
+			if err != nil || string(data) != "hello" {
+				t.Errorf("expecting hello from the storage but got %s %v", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+	// the object is still cached in memory of the instance when redis is down
+	before := atomic.LoadInt64(downloads)
+	if before < 1 {
+		t.Fatal("expecting the object to be downloaded from the storage")
+	}
+	if _, err := s1.DownloadByte(ctx, "welcome.tmpl", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt64(downloads); got != before {
+		t.Fatalf("expecting the object from memory but got %d downloads", got-before)
+	}
+}
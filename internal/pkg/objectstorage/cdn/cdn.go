@@ -0,0 +1,254 @@
+// cdn map object of the bucket to the public url of the cdn which serve the bucket as read only replica
+// the url is signed with hmac token when the bucket has signing key, and the cache of the object is purged on overwrite and delete
+
+package cdn
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+)
+
+// default value of bucket config
+const (
+	DefaultTokenParam   = "token"
+	DefaultExpiresParam = "expires"
+	DefaultExpiry       = time.Hour
+)
+
+// list of cdn error
+var (
+	ErrBucketNotMapped = errors.New("cdn: bucket is not mapped")
+	ErrKeyNotMapped    = errors.New("cdn: key is not under the key prefix")
+	ErrBaseURLEmpty    = errors.New("cdn: base url is empty")
+	ErrSigningKeyEmpty = errors.New("cdn: signing key is empty")
+	ErrInvalidToken    = errors.New("cdn: invalid token")
+	ErrTokenExpired    = errors.New("cdn: token is expired")
+	ErrPurgerNil       = errors.New("cdn: purger is nil")
+)
+
+// BucketConfig of the cdn which serve the bucket
+type BucketConfig struct {
+	// BaseURL of the cdn, for example https://cdn.example.com/images
+	BaseURL string
+	// KeyPrefix is removed from the key, for example when the origin of the cdn is a directory of the bucket
+	KeyPrefix string
+	// SigningKey sign every url with hmac-sha256 token, the url is not signed when empty
+	SigningKey string
+	// TokenParam and ExpiresParam are the query parameters of the signed url, default to token and expires
+	TokenParam   string
+	ExpiresParam string
+	// Expiry of the signed url, default to 1h
+	Expiry time.Duration
+}
+
+// bucket is the parsed bucket config
+type bucket struct {
+	config  BucketConfig
+	baseURL *url.URL
+}
+
+// Purger purge the cache of the urls in the cdn
+type Purger interface {
+	Purge(ctx context.Context, urls ...string) error
+}
+
+// Options of cdn
+type Options struct {
+	// Buckets config by the bucket name
+	Buckets map[string]BucketConfig
+	// Purger of the cache, Purge and PurgeHook fail with ErrPurgerNil when nil
+	Purger Purger
+	// OnPurgeError is called when the purge in PurgeHook failed
+	OnPurgeError func(key string, err error)
+	// Now is used to sign and verify the url, default to time.Now
+	Now func() time.Time
+}
+
+// CDN map object of the bucket to cdn url
+type CDN struct {
+	buckets      map[string]bucket
+	purger       Purger
+	onPurgeError func(key string, err error)
+	now          func() time.Time
+}
+
+// New cdn from the bucket config
+func New(options *Options) (*CDN, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	c := CDN{
+		buckets:      make(map[string]bucket, len(opts.Buckets)),
+		purger:       opts.Purger,
+		onPurgeError: opts.OnPurgeError,
+		now:          opts.Now,
+	}
+	for name, config := range opts.Buckets {
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("%w: %s", ErrBaseURLEmpty, name)
+		}
+		baseURL, err := url.Parse(strings.TrimSuffix(config.BaseURL, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("cdn: invalid base url of %s: %w", name, err)
+		}
+		if config.TokenParam == "" {
+			config.TokenParam = DefaultTokenParam
+		}
+		if config.ExpiresParam == "" {
+			config.ExpiresParam = DefaultExpiresParam
+		}
+		if config.Expiry <= 0 {
+			config.Expiry = DefaultExpiry
+		}
+		c.buckets[name] = bucket{config: config, baseURL: baseURL}
+	}
+	return &c, nil
+}
+
+// URL return the cdn url of the key, the url is signed with the default expiry when the bucket has signing key
+func (c *CDN) URL(bucketName, key string) (string, error) {
+	b, ok := c.buckets[bucketName]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrBucketNotMapped, bucketName)
+	}
+	if b.config.SigningKey != "" {
+		return c.SignedURL(bucketName, key, b.config.Expiry)
+	}
+	u, err := b.url(key)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// SignedURL return the cdn url of the key with token which is valid until the expiry
+func (c *CDN) SignedURL(bucketName, key string, expiry time.Duration) (string, error) {
+	b, ok := c.buckets[bucketName]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrBucketNotMapped, bucketName)
+	}
+	if b.config.SigningKey == "" {
+		return "", fmt.Errorf("%w: %s", ErrSigningKeyEmpty, bucketName)
+	}
+	u, err := b.url(key)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(c.now().Add(expiry).Unix(), 10)
+	query := url.Values{}
+	query.Set(b.config.ExpiresParam, expires)
+	query.Set(b.config.TokenParam, b.token(u.EscapedPath(), expires))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Verify the token of the signed url, for example in the origin or edge function of the cdn
+func (c *CDN) Verify(bucketName, rawURL string) error {
+	b, ok := c.buckets[bucketName]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBucketNotMapped, bucketName)
+	}
+	if b.config.SigningKey == "" {
+		return fmt.Errorf("%w: %s", ErrSigningKeyEmpty, bucketName)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	query := u.Query()
+	expires := query.Get(b.config.ExpiresParam)
+	token := query.Get(b.config.TokenParam)
+	if !hmac.Equal([]byte(token), []byte(b.token(u.EscapedPath(), expires))) {
+		return ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !c.now().Before(time.Unix(unix, 0)) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// Purge the cache of the keys in the cdn, the url without token is purged
+func (c *CDN) Purge(ctx context.Context, bucketName string, keys ...string) error {
+	if c.purger == nil {
+		return ErrPurgerNil
+	}
+	b, ok := c.buckets[bucketName]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBucketNotMapped, bucketName)
+	}
+	urls := make([]string, 0, len(keys))
+	for _, key := range keys {
+		u, err := b.url(key)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, u.String())
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	return c.purger.Purge(ctx, urls...)
+}
+
+// PurgeHook return object storage hook which purge the cache of the key after it is uploaded or deleted
+// the upload might overwrite the object, so the cdn should not serve the old object. key which is not mapped is skipped
+func (c *CDN) PurgeHook(bucketName string) objectstorage.Hook {
+	return func(ctx context.Context, event objectstorage.OperationEvent) {
+		if event.Err != nil || (event.Operation != objectstorage.OperationUpload && event.Operation != objectstorage.OperationDelete) {
+			return
+		}
+		err := c.Purge(ctx, bucketName, event.Key)
+		if err != nil && !errors.Is(err, ErrKeyNotMapped) && c.onPurgeError != nil {
+			c.onPurgeError(event.Key, err)
+		}
+	}
+}
+
+// url return the cdn url of the key without token
+func (b bucket) url(key string) (*url.URL, error) {
+	key = strings.TrimPrefix(key, "/")
+	if b.config.KeyPrefix != "" {
+		prefix := strings.TrimPrefix(b.config.KeyPrefix, "/")
+		if !strings.HasPrefix(key, prefix) {
+			return nil, fmt.Errorf("%w: %s", ErrKeyNotMapped, key)
+		}
+		key = strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")
+	}
+
+	segments := strings.Split(key, "/")
+	for idx, segment := range segments {
+		segments[idx] = url.PathEscape(segment)
+	}
+	u := *b.baseURL
+	u.RawPath = u.EscapedPath() + "/" + strings.Join(segments, "/")
+	u.Path = u.Path + "/" + key
+	return &u, nil
+}
+
+// token of the escaped path and expires
+func (b bucket) token(path, expires string) string {
+	mac := hmac.New(sha256.New, []byte(b.config.SigningKey))
+	mac.Write([]byte(path))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,160 @@
+package cdn
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+)
+
+type fakePurger struct {
+	urls []string
+	err  error
+}
+
+func (p *fakePurger) Purge(ctx context.Context, urls ...string) error {
+	p.urls = append(p.urls, urls...)
+	return p.err
+}
+
+func newTestCDN(t *testing.T, purger Purger) *CDN {
+	t.Helper()
+	c, err := New(&Options{
+		Buckets: map[string]BucketConfig{
+			"images": {BaseURL: "https://cdn.example.com/images/"},
+			"public": {BaseURL: "https://static.example.com", KeyPrefix: "public/"},
+			"private": {
+				BaseURL:    "https://private.example.com",
+				SigningKey: "secret",
+				Expiry:     time.Minute,
+			},
+		},
+		Purger: purger,
+		Now: func() time.Time {
+			return time.Unix(1600000000, 0)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestURL(t *testing.T) {
+	mapper := newTestCDN(t, nil)
+
+	cases := []struct {
+		bucket string
+		key    string
+		expect string
+		err    error
+	}{
+		{"images", "user/avatar.png", "https://cdn.example.com/images/user/avatar.png", nil},
+		{"images", "/user/my avatar?.png", "https://cdn.example.com/images/user/my%20avatar%3F.png", nil},
+		{"public", "public/css/app.css", "https://static.example.com/css/app.css", nil},
+		{"public", "private/secret.txt", "", ErrKeyNotMapped},
+		{"private", "report.pdf", "https://private.example.com/report.pdf?expires=1600000060&token=", nil},
+		{"unknown", "a.txt", "", ErrBucketNotMapped},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.bucket+"/"+c.key, func(t *testing.T) {
+			got, err := mapper.URL(c.bucket, c.key)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if !strings.HasPrefix(got, c.expect) {
+				t.Fatalf("expecting %s but got %s", c.expect, got)
+			}
+		})
+	}
+
+	if _, err := mapper.SignedURL("images", "a.png", time.Minute); !errors.Is(err, ErrSigningKeyEmpty) {
+		t.Errorf("expecting %v but got %v", ErrSigningKeyEmpty, err)
+	}
+	if _, err := New(&Options{Buckets: map[string]BucketConfig{"images": {}}}); !errors.Is(err, ErrBaseURLEmpty) {
+		t.Errorf("expecting %v but got %v", ErrBaseURLEmpty, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	c := newTestCDN(t, nil)
+	signed, err := c.SignedURL("private", "reports/2020 q3.pdf", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify("private", signed); err != nil {
+		t.Fatalf("expecting valid signed url but got %v", err)
+	}
+
+	tampered := strings.Replace(signed, "q3", "q4", 1)
+	if err := c.Verify("private", tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expecting %v but got %v", ErrInvalidToken, err)
+	}
+	extended := strings.Replace(signed, "expires=1600000060", "expires=1700000000", 1)
+	if err := c.Verify("private", extended); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expecting %v but got %v", ErrInvalidToken, err)
+	}
+
+	c.now = func() time.Time {
+		return time.Unix(1600000060, 0)
+	}
+	if err := c.Verify("private", signed); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expecting %v but got %v", ErrTokenExpired, err)
+	}
+}
+
+func TestPurgeHook(t *testing.T) {
+	purger := &fakePurger{}
+	c := newTestCDN(t, purger)
+	var purgeErrs []string
+	c.onPurgeError = func(key string, err error) {
+		purgeErrs = append(purgeErrs, key)
+	}
+
+	storage := objectstorage.New(memory.New("public"))
+	defer storage.Close()
+	storage.AddHook(c.PurgeHook("public"))
+
+	ctx := context.Background()
+	if _, err := storage.UploadByte(ctx, []byte("body{}"), "public/css/app.css", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.DownloadByte(ctx, "public/css/app.css", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete(ctx, "public/css/app.css"); err != nil {
+		t.Fatal(err)
+	}
+	// key which is not mapped is not purged
+	if _, err := storage.UploadByte(ctx, []byte("secret"), "private/secret.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	// failed delete is not purged
+	storage.Delete(ctx, "public/not-found.css")
+
+	expect := []string{"https://static.example.com/css/app.css", "https://static.example.com/css/app.css"}
+	if strings.Join(purger.urls, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expecting purged urls %v but got %v", expect, purger.urls)
+	}
+	if len(purgeErrs) != 0 {
+		t.Fatalf("expecting no purge error but got %v", purgeErrs)
+	}
+
+	purger.err = errors.New("purge failed")
+	if _, err := storage.UploadByte(ctx, []byte("body{}"), "public/css/app.css", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(purgeErrs) != 1 || purgeErrs[0] != "public/css/app.css" {
+		t.Fatalf("expecting purge error of public/css/app.css but got %v", purgeErrs)
+	}
+
+	if err := newTestCDN(t, nil).Purge(ctx, "public", "public/a.css"); !errors.Is(err, ErrPurgerNil) {
+		t.Errorf("expecting %v but got %v", ErrPurgerNil, err)
+	}
+}
@@ -0,0 +1,103 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// default value of http purger options
+const (
+	DefaultPurgeField     = "files"
+	DefaultPurgeBatchSize = 30
+)
+
+// list of purge error
+var (
+	ErrPurgeEndpointEmpty = errors.New("cdn: purge endpoint is empty")
+)
+
+// HTTPPurgerOptions of http purger
+type HTTPPurgerOptions struct {
+	// Endpoint of the purge api, for example https://api.cloudflare.com/client/v4/zones/{zone_id}/purge_cache
+	Endpoint string
+	// Token is sent as bearer token in the Authorization header when not empty
+	Token string
+	// Field of the urls in the json body, default to files
+	Field string
+	// BatchSize is the maximum urls in one request, default to 30
+	BatchSize int
+	// Client of the request, default to http.DefaultClient
+	Client *http.Client
+}
+
+// HTTPPurger purge the cache by sending the urls as json to the purge api of the cdn, for example {"files": ["https://..."]}
+type HTTPPurger struct {
+	opts HTTPPurgerOptions
+}
+
+// NewHTTPPurger return http purger
+func NewHTTPPurger(options *HTTPPurgerOptions) (*HTTPPurger, error) {
+	opts := HTTPPurgerOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Endpoint == "" {
+		return nil, ErrPurgeEndpointEmpty
+	}
+	if opts.Field == "" {
+		opts.Field = DefaultPurgeField
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultPurgeBatchSize
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &HTTPPurger{opts: opts}, nil
+}
+
+// Purge the urls, the urls are sent in batches of BatchSize
+func (p *HTTPPurger) Purge(ctx context.Context, urls ...string) error {
+	for start := 0; start < len(urls); start += p.opts.BatchSize {
+		end := start + p.opts.BatchSize
+		if end > len(urls) {
+			end = len(urls)
+		}
+		if err := p.purge(ctx, urls[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *HTTPPurger) purge(ctx context.Context, urls []string) error {
+	body, err := json.Marshal(map[string][]string{p.opts.Field: urls})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.opts.Token)
+	}
+
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cdn: failed to purge: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("cdn: failed to purge: status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPPurger(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("invalid token"))
+			return
+		}
+		var body map[string][]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, body["files"])
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTPPurger(nil); !errors.Is(err, ErrPurgeEndpointEmpty) {
+		t.Fatalf("expecting %v but got %v", ErrPurgeEndpointEmpty, err)
+	}
+
+	purger, err := NewHTTPPurger(&HTTPPurgerOptions{Endpoint: server.URL, Token: "token", BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for i := 0; i < 5; i++ {
+		urls = append(urls, fmt.Sprintf("https://cdn.example.com/%d.png", i))
+	}
+	if err := purger.Purge(context.Background(), urls...); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[2]) != 1 {
+		t.Fatalf("expecting 3 batches of 2, 2 and 1 urls but got %v", batches)
+	}
+	if batches[2][0] != urls[4] {
+		t.Fatalf("expecting %s in the last batch but got %s", urls[4], batches[2][0])
+	}
+
+	unauthorized, err := NewHTTPPurger(&HTTPPurgerOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = unauthorized.Purge(context.Background(), urls[0])
+	if err == nil || !strings.Contains(err.Error(), "status 401: invalid token") {
+		t.Fatalf("expecting unauthorized error but got %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+// imageproxy serve images from object storage with on the fly resize, crop and format conversion
+// every url is signed with hmac, so the parameters cannot be changed to abuse the transformation
+// the transformed images are cached in the derived storage, keyed by the source key and the parameters
+
+package imageproxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+// default value of options
+const (
+	DefaultMaxWidth       = 4096
+	DefaultMaxHeight      = 4096
+	DefaultMaxSourceBytes = 32 << 20
+	DefaultMaxPixels      = 50000000
+	DefaultCacheControl   = "public, max-age=31536000, immutable"
+)
+
+// list of handler error
+var (
+	ErrSigningKeyEmpty  = errors.New("imageproxy: signing key is empty")
+	ErrInvalidSignature = errors.New("imageproxy: invalid signature")
+)
+
+// Options of the handler
+type Options struct {
+	// SigningKey of the url signature, see Sign
+	SigningKey string
+	// Derived storage to cache the transformed images, the image is transformed on every request when nil
+	Derived *objectstorage.Storage
+	// Prefix of the request path which is not part of the key, for example /images
+	Prefix string
+	// MaxWidth and MaxHeight of the result, default to 4096
+	MaxWidth  int
+	MaxHeight int
+	// MaxSourceBytes is the maximum size of the source image, default to 32MB
+	MaxSourceBytes int64
+	// MaxPixels is the maximum width * height of the source image, default to 50 megapixels
+	MaxPixels int
+	// CacheControl header of the response, default to one year as the url of the same params is never changed
+	CacheControl string
+}
+
+// Handler serve the transformed image of the source storage
+type Handler struct {
+	source *objectstorage.Storage
+	opts   Options
+}
+
+// New image handler of the source storage
+func New(source *objectstorage.Storage, options *Options) (*Handler, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.SigningKey == "" {
+		return nil, ErrSigningKeyEmpty
+	}
+	if opts.MaxWidth <= 0 {
+		opts.MaxWidth = DefaultMaxWidth
+	}
+	if opts.MaxHeight <= 0 {
+		opts.MaxHeight = DefaultMaxHeight
+	}
+	if opts.MaxSourceBytes <= 0 {
+		opts.MaxSourceBytes = DefaultMaxSourceBytes
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = DefaultMaxPixels
+	}
+	if opts.CacheControl == "" {
+		opts.CacheControl = DefaultCacheControl
+	}
+	opts.Prefix = strings.TrimSuffix(opts.Prefix, "/")
+	return &Handler{source: source, opts: opts}, nil
+}
+
+// Sign return the signature of the key and the params
+func Sign(signingKey, key string, params Params) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(key))
+	mac.Write([]byte("?"))
+	mac.Write([]byte(params.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL return the signed path of the transformed image, for example /images/user/avatar.png?h=200&w=200&sig=...
+func (h *Handler) URL(key string, params Params) string {
+	query := params.Encode()
+	if query != "" {
+		query += "&"
+	}
+	query += paramSignature + "=" + Sign(h.opts.SigningKey, key, params)
+	u := url.URL{Path: h.opts.Prefix + "/" + strings.TrimPrefix(key, "/"), RawQuery: query}
+	return u.String()
+}
+
+// ServeHTTP serve the transformed image from the derived storage, or transform the source image when it is not cached
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, h.opts.Prefix+"/") {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, h.opts.Prefix+"/")
+	query := r.URL.Query()
+	params, err := ParseParams(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !hmac.Equal([]byte(query.Get(paramSignature)), []byte(Sign(h.opts.SigningKey, key, params))) {
+		http.Error(w, ErrInvalidSignature.Error(), http.StatusForbidden)
+		return
+	}
+	if params.Width > h.opts.MaxWidth || params.Height > h.opts.MaxHeight {
+		http.Error(w, fmt.Sprintf("%s: maximum size is %dx%d", ErrInvalidParams, h.opts.MaxWidth, h.opts.MaxHeight), http.StatusBadRequest)
+		return
+	}
+
+	content, contentType, err := h.image(r, key, params)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Cache-Control", h.opts.CacheControl)
+	if r.Method == http.MethodGet {
+		w.Write(content)
+	}
+}
+
+// image return the cached image in the derived storage, or transform and cache the source image
+func (h *Handler) image(r *http.Request, key string, params Params) ([]byte, string, error) {
+	ctx := r.Context()
+	cacheKey := derivedKey(key, params)
+	if h.opts.Derived != nil {
+		// failed read from the cache is a cache miss, the image is transformed from the source
+		if content, contentType, err := h.cached(r, cacheKey); err == nil {
+			return content, contentType, nil
+		}
+	}
+
+	src, err := h.download(r, key)
+	if err != nil {
+		return nil, "", err
+	}
+	content, contentType, err := Transform(src, params, h.opts.MaxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+	if h.opts.Derived != nil {
+		// the image is served even when it is failed to be cached, and cached again in the next request
+		h.opts.Derived.UploadByte(ctx, content, cacheKey, &objectstorage.WriteOptions{
+			ContentType: contentType,
+			Metadata:    map[string]string{"source": key, "params": params.Encode()},
+		})
+	}
+	return content, contentType, nil
+}
+
+// cached return the transformed image and its content type from the derived storage
+func (h *Handler) cached(r *http.Request, cacheKey string) ([]byte, string, error) {
+	reader, err := h.opts.Derived.Download(r.Context(), cacheKey, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	blobReader, ok := reader.(*blob.Reader)
+	if !ok {
+		return nil, "", errors.New("imageproxy: unknown reader of derived storage")
+	}
+	defer blobReader.Close()
+	content, err := ioutil.ReadAll(blobReader)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, blobReader.ContentType(), nil
+}
+
+// download the source image, limited by MaxSourceBytes
+func (h *Handler) download(r *http.Request, key string) ([]byte, error) {
+	reader, err := h.source.Download(r.Context(), key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	src, err := ioutil.ReadAll(io.LimitReader(reader, h.opts.MaxSourceBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(src)) > h.opts.MaxSourceBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrImageTooLarge, h.opts.MaxSourceBytes)
+	}
+	return src, nil
+}
+
+// derivedKey return the key of the transformed image, the source key followed by the hash of the params
+func derivedKey(key string, params Params) string {
+	sum := sha256.Sum256([]byte(params.Encode()))
+	return key + "/" + hex.EncodeToString(sum[:8])
+}
+
+// statusOf return the http status of the error
+func statusOf(err error) int {
+	switch {
+	case gcerrors.Code(err) == gcerrors.NotFound:
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidParams):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedImage):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
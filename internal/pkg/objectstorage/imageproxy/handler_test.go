@@ -0,0 +1,93 @@
+package imageproxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	source := objectstorage.New(memory.New("images"))
+	defer source.Close()
+	derived := objectstorage.New(memory.New("derived"))
+	defer derived.Close()
+	if _, err := source.UploadByte(ctx, newTestImage(t, 400, 200), "user/avatar.png", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.UploadByte(ctx, []byte("not an image"), "user/text.png", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(source, nil); !errors.Is(err, ErrSigningKeyEmpty) {
+		t.Fatalf("expecting %v but got %v", ErrSigningKeyEmpty, err)
+	}
+	h, err := New(source, &Options{SigningKey: "secret", Derived: derived, Prefix: "/images/", MaxWidth: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var downloads int
+	source.AddHook(func(ctx context.Context, event objectstorage.OperationEvent) {
+		if event.Operation == objectstorage.OperationDownload {
+			downloads++
+		}
+	})
+
+	thumbnail := h.URL("user/avatar.png", Params{Width: 100, Height: 100, Fit: FitCover, Format: FormatJPEG})
+	if !strings.HasPrefix(thumbnail, "/images/user/avatar.png?fit=cover&fmt=jpeg&h=100&w=100&sig=") {
+		t.Fatalf("unexpected url %s", thumbnail)
+	}
+
+	cases := []struct {
+		name        string
+		target      string
+		status      int
+		contentType string
+	}{
+		{"transformed", thumbnail, http.StatusOK, "image/jpeg"},
+		{"cached", thumbnail, http.StatusOK, "image/jpeg"},
+		{"original format", h.URL("user/avatar.png", Params{Width: 50}), http.StatusOK, "image/png"},
+		{"changed params", strings.Replace(thumbnail, "w=100", "w=900", 1), http.StatusForbidden, ""},
+		{"no signature", "/images/user/avatar.png?w=100", http.StatusForbidden, ""},
+		{"too large", h.URL("user/avatar.png", Params{Width: 2000}), http.StatusBadRequest, ""},
+		{"invalid params", "/images/user/avatar.png?w=abc", http.StatusBadRequest, ""},
+		{"not found", h.URL("user/missing.png", Params{Width: 100}), http.StatusNotFound, ""},
+		{"not an image", h.URL("user/text.png", Params{Width: 100}), http.StatusUnsupportedMediaType, ""},
+		{"outside prefix", "/other/user/avatar.png", http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, c.target, nil))
+		if recorder.Code != c.status {
+			t.Fatalf("%s: expecting status %d but got %d: %s", c.name, c.status, recorder.Code, recorder.Body.String())
+		}
+		if c.contentType != "" && recorder.Header().Get("Content-Type") != c.contentType {
+			t.Fatalf("%s: expecting content type %s but got %s", c.name, c.contentType, recorder.Header().Get("Content-Type"))
+		}
+	}
+
+	// the cached thumbnail is not downloaded from the source
+	if downloads != 4 {
+		t.Errorf("expecting 4 downloads from source but got %d", downloads)
+	}
+	attrs, err := derived.Attributes(ctx, derivedKey("user/avatar.png", Params{Width: 100, Height: 100, Fit: FitCover, Format: FormatJPEG}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.ContentType != "image/jpeg" || attrs.Metadata["source"] != "user/avatar.png" {
+		t.Errorf("unexpected attributes of derived image: %s %v", attrs.ContentType, attrs.Metadata)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, thumbnail, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting status %d but got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
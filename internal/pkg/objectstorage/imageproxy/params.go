@@ -0,0 +1,146 @@
+package imageproxy
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// list of fit mode when both width and height are set
+const (
+	// FitContain resize the image to fit inside width and height, the aspect ratio is kept
+	FitContain = "contain"
+	// FitCover resize the image to cover width and height, and crop the center of the image
+	FitCover = "cover"
+	// FitFill resize the image to width and height exactly, the aspect ratio is not kept
+	FitFill = "fill"
+)
+
+// list of output format
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+)
+
+// list of query parameter
+const (
+	paramWidth     = "w"
+	paramHeight    = "h"
+	paramFit       = "fit"
+	paramCrop      = "crop"
+	paramFormat    = "fmt"
+	paramQuality   = "q"
+	paramSignature = "sig"
+)
+
+// list of params error
+var (
+	ErrInvalidParams = errors.New("imageproxy: invalid params")
+)
+
+// Params of image transformation
+type Params struct {
+	// Width and Height of the result, the other side follow the aspect ratio when one of them is zero
+	// the image is not resized when both are zero
+	Width  int
+	Height int
+	// Fit mode when both width and height are set, default to contain
+	Fit string
+	// Crop is the region of the source which is cropped before resize, the whole image when empty
+	Crop image.Rectangle
+	// Format of the result, default to the format of the source
+	Format string
+	// Quality of jpeg, default to 85
+	Quality int
+}
+
+// ParseParams parse the params from query, for example w=200&h=200&fit=cover&crop=0,0,400,400&fmt=jpeg&q=80
+func ParseParams(query url.Values) (Params, error) {
+	var (
+		params Params
+		err    error
+	)
+	atoi := func(name string) int {
+		value := query.Get(name)
+		if value == "" || err != nil {
+			return 0
+		}
+		var n int
+		n, err = strconv.Atoi(value)
+		if err != nil || n < 0 {
+			err = fmt.Errorf("%w: %s=%s", ErrInvalidParams, name, value)
+		}
+		return n
+	}
+	params.Width = atoi(paramWidth)
+	params.Height = atoi(paramHeight)
+	params.Quality = atoi(paramQuality)
+	if err != nil {
+		return Params{}, err
+	}
+	params.Fit = query.Get(paramFit)
+	params.Format = query.Get(paramFormat)
+
+	if crop := query.Get(paramCrop); crop != "" {
+		values := strings.Split(crop, ",")
+		if len(values) != 4 {
+			return Params{}, fmt.Errorf("%w: crop must be x,y,width,height", ErrInvalidParams)
+		}
+		var n [4]int
+		for idx, value := range values {
+			n[idx], err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n[idx] < 0 {
+				return Params{}, fmt.Errorf("%w: crop=%s", ErrInvalidParams, crop)
+			}
+		}
+		params.Crop = image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3])
+	}
+	return params, params.Validate()
+}
+
+// Validate the fit mode, format and quality
+func (p Params) Validate() error {
+	switch p.Fit {
+	case "", FitContain, FitCover, FitFill:
+	default:
+		return fmt.Errorf("%w: fit=%s", ErrInvalidParams, p.Fit)
+	}
+	switch p.Format {
+	case "", FormatJPEG, FormatPNG, FormatGIF:
+	default:
+		return fmt.Errorf("%w: fmt=%s", ErrInvalidParams, p.Format)
+	}
+	if p.Quality > 100 {
+		return fmt.Errorf("%w: q=%d", ErrInvalidParams, p.Quality)
+	}
+	return nil
+}
+
+// Encode the params as query in stable order, empty params are omitted
+func (p Params) Encode() string {
+	query := url.Values{}
+	if !p.Crop.Empty() {
+		query.Set(paramCrop, fmt.Sprintf("%d,%d,%d,%d", p.Crop.Min.X, p.Crop.Min.Y, p.Crop.Dx(), p.Crop.Dy()))
+	}
+	if p.Fit != "" {
+		query.Set(paramFit, p.Fit)
+	}
+	if p.Format != "" {
+		query.Set(paramFormat, p.Format)
+	}
+	if p.Height > 0 {
+		query.Set(paramHeight, strconv.Itoa(p.Height))
+	}
+	if p.Quality > 0 {
+		query.Set(paramQuality, strconv.Itoa(p.Quality))
+	}
+	if p.Width > 0 {
+		query.Set(paramWidth, strconv.Itoa(p.Width))
+	}
+	// url.Values encode the keys in sorted order
+	return query.Encode()
+}
@@ -0,0 +1,118 @@
+package imageproxy
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// weight of the source pixel to the result pixel
+type weight struct {
+	index int
+	value float64
+}
+
+// weights of the source pixels for every result pixel with triangle filter
+// the filter is widened when the image is shrunk, so every source pixel is counted
+func weights(srcLen, dstLen int) [][]weight {
+	scale := float64(srcLen) / float64(dstLen)
+	support := math.Max(scale, 1)
+	result := make([][]weight, dstLen)
+	for i := range result {
+		center := (float64(i)+0.5)*scale - 0.5
+		var (
+			ws    []weight
+			total float64
+		)
+		for j := int(math.Ceil(center - support)); j <= int(math.Floor(center+support)); j++ {
+			value := 1 - math.Abs(float64(j)-center)/support
+			if value <= 0 {
+				continue
+			}
+			index := j
+			if index < 0 {
+				index = 0
+			} else if index >= srcLen {
+				index = srcLen - 1
+			}
+			ws = append(ws, weight{index: index, value: value})
+			total += value
+		}
+		for idx := range ws {
+			ws[idx].value /= total
+		}
+		result[i] = ws
+	}
+	return result
+}
+
+// toRGBA return the image as premultiplied rgba with origin at zero, so the colors can be averaged
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok && bounds.Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resize the image to width and height, the horizontal and vertical passes are done separately
+func resize(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	bounds := src.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return src
+	}
+
+	// horizontal pass
+	horizontal := image.NewRGBA(image.Rect(0, 0, width, bounds.Dy()))
+	xWeights := weights(bounds.Dx(), width)
+	for y := 0; y < bounds.Dy(); y++ {
+		srcRow := src.Pix[y*src.Stride:]
+		dstRow := horizontal.Pix[y*horizontal.Stride:]
+		for x, ws := range xWeights {
+			var c [4]float64
+			for _, w := range ws {
+				offset := w.index * 4
+				for i := range c {
+					c[i] += float64(srcRow[offset+i]) * w.value
+				}
+			}
+			for i := range c {
+				dstRow[x*4+i] = clamp(c[i])
+			}
+		}
+	}
+
+	// vertical pass
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	yWeights := weights(bounds.Dy(), height)
+	for y, ws := range yWeights {
+		dstRow := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			var c [4]float64
+			for _, w := range ws {
+				offset := w.index*horizontal.Stride + x*4
+				for i := range c {
+					c[i] += float64(horizontal.Pix[offset+i]) * w.value
+				}
+			}
+			for i := range c {
+				dstRow[x*4+i] = clamp(c[i])
+			}
+		}
+	}
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	v = math.Round(v)
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
@@ -0,0 +1,132 @@
+package imageproxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// DefaultQuality of jpeg
+const DefaultQuality = 85
+
+// list of transform error
+var (
+	ErrUnsupportedImage = errors.New("imageproxy: unsupported image")
+	ErrImageTooLarge    = errors.New("imageproxy: image is too large")
+)
+
+// Transform crop, resize and convert the format of the image, and return the content and its content type
+// the size of the source is checked before it is decoded, and source with more than maxPixels is rejected, no limit when zero
+// animated gif is transformed as its first frame
+func Transform(src []byte, params Params, maxPixels int) ([]byte, string, error) {
+	if err := params.Validate(); err != nil {
+		return nil, "", err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if maxPixels > 0 && config.Width*config.Height > maxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	region := img.Bounds()
+	if !params.Crop.Empty() {
+		region = params.Crop.Add(region.Min).Intersect(region)
+		if region.Empty() {
+			return nil, "", fmt.Errorf("%w: crop is outside of the image", ErrInvalidParams)
+		}
+	}
+	region, width, height := fit(region, params)
+	if region != img.Bounds() {
+		img = subImage(img, region)
+	}
+	result := resize(img, width, height)
+
+	if params.Format != "" {
+		format = params.Format
+	}
+	var buff bytes.Buffer
+	switch format {
+	case FormatJPEG:
+		quality := params.Quality
+		if quality == 0 {
+			quality = DefaultQuality
+		}
+		err = jpeg.Encode(&buff, flatten(result), &jpeg.Options{Quality: quality})
+	case FormatGIF:
+		err = gif.Encode(&buff, result, nil)
+	default:
+		format = FormatPNG
+		err = png.Encode(&buff, result)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buff.Bytes(), "image/" + format, nil
+}
+
+// fit return the region of the source and the size of the result
+func fit(region image.Rectangle, params Params) (image.Rectangle, int, int) {
+	sw, sh := float64(region.Dx()), float64(region.Dy())
+	width, height := params.Width, params.Height
+	switch {
+	case width == 0 && height == 0:
+		return region, region.Dx(), region.Dy()
+	case width == 0:
+		return region, round(sw * float64(height) / sh), height
+	case height == 0:
+		return region, width, round(sh * float64(width) / sw)
+	}
+
+	switch params.Fit {
+	case FitFill:
+		return region, width, height
+	case FitCover:
+		scale := math.Max(float64(width)/sw, float64(height)/sh)
+		cw, ch := round(float64(width)/scale), round(float64(height)/scale)
+		min := region.Min.Add(image.Pt((region.Dx()-cw)/2, (region.Dy()-ch)/2))
+		return image.Rectangle{Min: min, Max: min.Add(image.Pt(cw, ch))}, width, height
+	default:
+		scale := math.Min(float64(width)/sw, float64(height)/sh)
+		return region, round(sw * scale), round(sh * scale)
+	}
+}
+
+func round(v float64) int {
+	if n := int(math.Round(v)); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// subImage return the region of the image, the image is copied when it cannot be sliced
+func subImage(img image.Image, region image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(region)
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, region.Min, draw.Src)
+	return rgba
+}
+
+// flatten the transparent pixels on white background, as jpeg has no alpha
+func flatten(img *image.RGBA) *image.RGBA {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
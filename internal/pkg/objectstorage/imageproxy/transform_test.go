@@ -0,0 +1,149 @@
+package imageproxy
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+// newTestImage return png of the size, the left half is red and the right half is blue
+func newTestImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buff bytes.Buffer
+	if err := png.Encode(&buff, img); err != nil {
+		t.Fatal(err)
+	}
+	return buff.Bytes()
+}
+
+func TestParseParams(t *testing.T) {
+	cases := []struct {
+		query  string
+		expect Params
+		err    error
+	}{
+		{"w=200&h=100&fit=cover&fmt=jpeg&q=80", Params{Width: 200, Height: 100, Fit: FitCover, Format: FormatJPEG, Quality: 80}, nil},
+		{"crop=10,20,30,40", Params{Crop: image.Rect(10, 20, 40, 60)}, nil},
+		{"", Params{}, nil},
+		{"w=-1", Params{}, ErrInvalidParams},
+		{"w=abc", Params{}, ErrInvalidParams},
+		{"fit=stretch", Params{}, ErrInvalidParams},
+		{"fmt=webp", Params{}, ErrInvalidParams},
+		{"q=101", Params{}, ErrInvalidParams},
+		{"crop=1,2,3", Params{}, ErrInvalidParams},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.query, func(t *testing.T) {
+			query, err := url.ParseQuery(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, err := ParseParams(query)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if params != c.expect {
+				t.Fatalf("expecting %+v but got %+v", c.expect, params)
+			}
+			// encoded params is parsed to the same params
+			query, _ = url.ParseQuery(params.Encode())
+			if again, _ := ParseParams(query); again != params {
+				t.Fatalf("expecting %+v from encoded params but got %+v", params, again)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	src := newTestImage(t, 400, 200)
+
+	cases := []struct {
+		name        string
+		params      Params
+		width       int
+		height      int
+		contentType string
+	}{
+		{"original", Params{}, 400, 200, "image/png"},
+		{"width only", Params{Width: 100}, 100, 50, "image/png"},
+		{"height only", Params{Height: 100}, 200, 100, "image/png"},
+		{"contain", Params{Width: 100, Height: 100}, 100, 50, "image/png"},
+		{"cover", Params{Width: 100, Height: 100, Fit: FitCover}, 100, 100, "image/png"},
+		{"fill", Params{Width: 100, Height: 100, Fit: FitFill}, 100, 100, "image/png"},
+		{"crop", Params{Crop: image.Rect(0, 0, 100, 100)}, 100, 100, "image/png"},
+		{"crop outside is cut", Params{Crop: image.Rect(300, 100, 500, 300)}, 100, 100, "image/png"},
+		{"jpeg", Params{Width: 40, Format: FormatJPEG, Quality: 70}, 40, 20, "image/jpeg"},
+		{"gif", Params{Width: 40, Format: FormatGIF}, 40, 20, "image/gif"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			content, contentType, err := Transform(src, c.params, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != c.contentType {
+				t.Fatalf("expecting content type %s but got %s", c.contentType, contentType)
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Width != c.width || config.Height != c.height {
+				t.Fatalf("expecting %dx%d but got %dx%d", c.width, c.height, config.Width, config.Height)
+			}
+		})
+	}
+}
+
+func TestTransformColors(t *testing.T) {
+	src := newTestImage(t, 400, 200)
+	// cover crop the center, so the left and right side keep their colors
+	content, _, err := Transform(src, Params{Width: 10, Height: 10, Fit: FitCover}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := img.At(0, 5).RGBA(); r>>8 != 255 || b != 0 {
+		t.Errorf("expecting red in the left side but got %v", img.At(0, 5))
+	}
+	if r, _, b, _ := img.At(9, 5).RGBA(); r != 0 || b>>8 != 255 {
+		t.Errorf("expecting blue in the right side but got %v", img.At(9, 5))
+	}
+}
+
+func TestTransformLimit(t *testing.T) {
+	src := newTestImage(t, 400, 200)
+	if _, _, err := Transform(src, Params{}, 400*200-1); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("expecting %v but got %v", ErrImageTooLarge, err)
+	}
+	if _, _, err := Transform([]byte("not an image"), Params{}, 0); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("expecting %v but got %v", ErrUnsupportedImage, err)
+	}
+	if _, _, err := Transform(src, Params{Crop: image.Rect(500, 500, 600, 600)}, 0); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("expecting %v but got %v", ErrInvalidParams, err)
+	}
+}
@@ -0,0 +1,50 @@
+// memory storage is an in-memory object storage
+// all objects are lost when the storage is closed, only use this for testing
+
+package memory
+
+import (
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/memblob"
+)
+
+// Memory storage struct
+type Memory struct {
+	bucketName string
+	bucket     *blob.Bucket
+}
+
+// New in-memory storage
+func New(bucketName string) *Memory {
+	m := Memory{
+		bucketName: bucketName,
+		bucket:     memblob.OpenBucket(nil),
+	}
+	return &m
+}
+
+// Bucket of memory storage
+func (m *Memory) Bucket() *blob.Bucket {
+	return m.bucket
+}
+
+// Name return the name of provider
+func (m *Memory) Name() string {
+	return objectstorage.StorageMemory
+}
+
+// BucketName return the name of the bucket
+func (m *Memory) BucketName() string {
+	return m.bucketName
+}
+
+// BucketURL return the url of blob storage bucket
+func (m *Memory) BucketURL() string {
+	return ""
+}
+
+// Close will close the memory bucket
+func (m *Memory) Close() error {
+	return m.bucket.Close()
+}
@@ -17,6 +17,8 @@ import (
 const (
 	// local storage for testing
 	StorageLocal = "local"
+	// in-memory storage for testing
+	StorageMemory = "memory"
 	// google cloud storage
 	StorageGCS = "gcs"
 	// amazon s3 storage
@@ -33,6 +35,28 @@ var (
 	ErrCredentialsEmpty = errors.New("credentials is empty")
 )
 
+// list of operation of the hook
+const (
+	OperationUpload     = "upload"
+	OperationDownload   = "download"
+	OperationAttributes = "attributes"
+	OperationSignedURL  = "signed_url"
+	OperationDelete     = "delete"
+	OperationList       = "list"
+)
+
+// OperationEvent of operation executed by the storage
+type OperationEvent struct {
+	Operation string
+	Key       string
+	// Duration of the operation, download duration is the time to open the reader
+	Duration time.Duration
+	Err      error
+}
+
+// Hook is invoked after every upload, download, attributes, signed url, delete and list operation
+type Hook func(ctx context.Context, event OperationEvent)
+
 // StorageProvider interface
 type StorageProvider interface {
 	Bucket() *blob.Bucket
@@ -45,6 +69,8 @@ type StorageProvider interface {
 // Storage struct
 type Storage struct {
 	storage StorageProvider
+	hooks   []Hook
+	cache   *objectCache
 }
 
 // ReadOptions struct
@@ -75,17 +101,74 @@ type WriteOptions struct {
 
 // New artifact
 func New(storage StorageProvider) *Storage {
-	return &Storage{storage}
+	return &Storage{storage: storage}
+}
+
+// AddHook add hook to the storage, hooks must be added before the storage is used
+func (s *Storage) AddHook(hook Hook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *Storage) runHooks(ctx context.Context, operation, key string, start time.Time, err error) {
+	if len(s.hooks) == 0 {
+		return
+	}
+	event := OperationEvent{
+		Operation: operation,
+		Key:       key,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+	for _, hook := range s.hooks {
+		hook(ctx, event)
+	}
 }
 
 // Attributes return information/attributes of object
 func (s *Storage) Attributes(ctx context.Context, key string) (*blob.Attributes, error) {
-	return s.storage.Bucket().Attributes(ctx, key)
+	start := time.Now()
+	attrs, err := s.storage.Bucket().Attributes(ctx, key)
+	s.runHooks(ctx, OperationAttributes, key, start, err)
+	return attrs, err
 }
 
 // SignedURL to create a temporary URL to download a private file
 func (s *Storage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
-	return s.storage.Bucket().SignedURL(ctx, key, &blob.SignedURLOptions{Expiry: expiry})
+	start := time.Now()
+	signedURL, err := s.storage.Bucket().SignedURL(ctx, key, &blob.SignedURLOptions{Expiry: expiry})
+	s.runHooks(ctx, OperationSignedURL, key, start, err)
+	return signedURL, err
+}
+
+// Delete the object of the key
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.storage.Bucket().Delete(ctx, key)
+	if s.cache != nil {
+		s.cache.invalidate(ctx, key)
+	}
+	s.runHooks(ctx, OperationDelete, key, start, err)
+	return err
+}
+
+// List the keys of the objects with the prefix, sorted by key
+func (s *Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	start := time.Now()
+	var keys []string
+	iter := s.storage.Bucket().List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.runHooks(ctx, OperationList, prefix, start, err)
+			return nil, err
+		}
+		keys = append(keys, obj.Key)
+	}
+	s.runHooks(ctx, OperationList, prefix, start, nil)
+	return keys, nil
 }
 
 // Upload file from bytes
@@ -115,12 +198,12 @@ func (s *Storage) UploadByte(ctx context.Context, content []byte, key string, wr
 
 // Download file from bucket
 func (s *Storage) Download(ctx context.Context, key string, readOptions *ReadOptions) (io.Reader, error) {
-	return s.download(ctx, key, readOptions)
+	return s.read(ctx, key, readOptions)
 }
 
 // DownloadFile will download and create file from object storage
 func (s *Storage) DownloadFile(ctx context.Context, key, destination string, readOptions *ReadOptions) error {
-	reader, err := s.download(ctx, key, readOptions)
+	reader, err := s.read(ctx, key, readOptions)
 	if err != nil {
 		return err
 	}
@@ -150,7 +233,7 @@ func (s *Storage) DownloadFile(ctx context.Context, key, destination string, rea
 
 // DownloadByte is a helper function for downloading content with return of byte
 func (s *Storage) DownloadByte(ctx context.Context, key string, readOptions *ReadOptions) ([]byte, error) {
-	reader, err := s.download(ctx, key, readOptions)
+	reader, err := s.read(ctx, key, readOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -162,6 +245,17 @@ func (s *Storage) DownloadByte(ctx context.Context, key string, readOptions *Rea
 // upload content to object storage
 // the function return the path of uploaded object and error
 func (s *Storage) upload(ctx context.Context, key string, reader io.Reader, writeOptions *WriteOptions) (string, error) {
+	start := time.Now()
+	uploadPath, err := s.write(ctx, key, reader, writeOptions)
+	// the object is invalidated even when the write failed, as the object might be partially written
+	if s.cache != nil {
+		s.cache.invalidate(ctx, key)
+	}
+	s.runHooks(ctx, OperationUpload, key, start, err)
+	return uploadPath, err
+}
+
+func (s *Storage) write(ctx context.Context, key string, reader io.Reader, writeOptions *WriteOptions) (string, error) {
 	uploadPath := path.Join(s.storage.BucketURL(), key)
 	blobBucket := s.storage.Bucket()
 
@@ -216,8 +310,10 @@ func (s *Storage) download(ctx context.Context, key string, readOptions *ReadOpt
 		opts = &blob.ReaderOptions{}
 	}
 
+	start := time.Now()
 	bucket := s.storage.Bucket()
 	reader, err := bucket.NewReader(ctx, key, opts)
+	s.runHooks(ctx, OperationDownload, key, start, err)
 	return reader, err
 }
 
@@ -0,0 +1,133 @@
+package objectstorage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+)
+
+// benchSizes of object in the benchmarks, in bytes
+var benchSizes = []int{64 << 10, 1 << 20, 16 << 20}
+
+func benchName(size int) string {
+	if size >= 1<<20 {
+		return strconv.Itoa(size>>20) + "MB"
+	}
+	return strconv.Itoa(size>>10) + "KB"
+}
+
+// BenchmarkUpload upload the object with Upload, which read the whole content to memory before writing
+func BenchmarkUpload(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range benchSizes {
+		content := bytes.Repeat([]byte("a"), size)
+		b.Run(benchName(size), func(b *testing.B) {
+			storage := objectstorage.New(memory.New("bench"))
+			defer storage.Close()
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.Upload(ctx, bytes.NewReader(content), "bench", nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStreamWrite upload the object by copying the content to the stream writer
+func BenchmarkStreamWrite(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range benchSizes {
+		content := bytes.Repeat([]byte("a"), size)
+		b.Run(benchName(size), func(b *testing.B) {
+			storage := objectstorage.New(memory.New("bench"))
+			defer storage.Close()
+			stream, err := storage.Stream(ctx, "bench", nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w, err := stream.Writer(ctx, "bench", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
+					b.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStreamRead download the object by copying the stream reader
+func BenchmarkStreamRead(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range benchSizes {
+		content := bytes.Repeat([]byte("a"), size)
+		b.Run(benchName(size), func(b *testing.B) {
+			storage := objectstorage.New(memory.New("bench"))
+			defer storage.Close()
+			if _, err := storage.UploadByte(ctx, content, "bench", nil); err != nil {
+				b.Fatal(err)
+			}
+			stream, err := storage.Stream(ctx, "bench", nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r, err := stream.Reader(ctx, "bench", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(ioutil.Discard, r); err != nil {
+					b.Fatal(err)
+				}
+				r.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkDownloadByte download the object to memory
+func BenchmarkDownloadByte(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range benchSizes {
+		content := bytes.Repeat([]byte("a"), size)
+		b.Run(benchName(size), func(b *testing.B) {
+			storage := objectstorage.New(memory.New("bench"))
+			defer storage.Close()
+			if _, err := storage.UploadByte(ctx, content, "bench", nil); err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.DownloadByte(ctx, "bench", nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
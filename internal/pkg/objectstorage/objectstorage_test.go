@@ -13,6 +13,7 @@ import (
 
 	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
 	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/local"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
 )
 
 // File struct
@@ -255,3 +256,35 @@ func TestDownloadFile(t *testing.T) {
 		os.Remove(c.Key)
 	}
 }
+
+func TestHook(t *testing.T) {
+	storage := objectstorage.New(memory.New("hook"))
+	var events []objectstorage.OperationEvent
+	storage.AddHook(func(ctx context.Context, event objectstorage.OperationEvent) {
+		events = append(events, event)
+	})
+
+	ctx := context.Background()
+	if _, err := storage.UploadByte(ctx, []byte("content"), "file.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.DownloadByte(ctx, "file.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Attributes(ctx, "missing.txt"); err == nil {
+		t.Fatal("expecting error of missing object")
+	}
+
+	expect := []string{objectstorage.OperationUpload, objectstorage.OperationDownload, objectstorage.OperationAttributes}
+	if len(events) != len(expect) {
+		t.Fatalf("expecting %d events but got %d", len(expect), len(events))
+	}
+	for idx, operation := range expect {
+		if events[idx].Operation != operation || events[idx].Key == "" {
+			t.Errorf("expecting %s event but got %+v", operation, events[idx])
+		}
+	}
+	if events[2].Err == nil {
+		t.Error("expecting error in attributes event")
+	}
+}
@@ -0,0 +1,124 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/xerrors"
+	"github.com/gorilla/mux"
+)
+
+// maxWait of long polling the operation status
+const maxWait = time.Second * 30
+
+// Handler of operations
+type Handler struct {
+	manager *Manager
+	// prefix of the operation path, used for the Location header
+	prefix string
+}
+
+// NewHandler return handler of operations, the prefix is the path where the handler is registered, for example /v1
+func NewHandler(manager *Manager, prefix string) *Handler {
+	h := Handler{
+		manager: manager,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+	}
+	return &h
+}
+
+// Register the handlers to the router
+//
+//	GET  /operations/{id}        get status of the operation, use ?wait=10s to wait until the operation is done
+//	POST /operations/{id}/cancel cancel the operation
+func (h *Handler) Register(r *router.Router, middlewares ...router.MiddlewareFunc) {
+	group := router.NewChainedMiddleware(r, middlewares...)
+	group.Get(h.prefix+"/operations/{id}", h.Get)
+	group.Post(h.prefix+"/operations/{id}/cancel", h.Cancel)
+}
+
+// Location return the path of the operation status
+func (h *Handler) Location(id string) string {
+	return h.prefix + "/operations/" + id
+}
+
+// Accepted write 202 Accepted with the operation and the Location of the operation status
+// use this in the handler which start the operation
+func (h *Handler) Accepted(rctx *requestcontext.RequestContext, op Operation) error {
+	w := rctx.ResponseWriter()
+	w.Header().Set("Location", h.Location(op.ID))
+	_, err := response.JSON(w).WriteHeader(http.StatusAccepted).Data(op).Write()
+	return err
+}
+
+// Get the operation status
+func (h *Handler) Get(rctx *requestcontext.RequestContext) error {
+	const op xerrors.Op = "operations/get"
+
+	id := mux.Vars(rctx.Request())["id"]
+	operation, err := h.manager.Get(rctx.Context(), id)
+	if err != nil {
+		return h.writeError(rctx, xerrors.New(op, errorKind(err), err))
+	}
+
+	if wait := rctx.Request().URL.Query().Get("wait"); wait != "" && !operation.Done() {
+		timeout, err := time.ParseDuration(wait)
+		if err != nil || timeout <= 0 {
+			return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, errors.New("operations: invalid wait duration")))
+		}
+		if timeout > maxWait {
+			timeout = maxWait
+		}
+		ctx, cancel := context.WithTimeout(rctx.Context(), timeout)
+		defer cancel()
+		// timeout is not an error, the last known status is returned
+		operation, err = h.manager.Wait(ctx, id)
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return h.writeError(rctx, xerrors.New(op, errorKind(err), err))
+		}
+	}
+
+	// the status is changed every update, so the client can poll with If-None-Match
+	_, err = rctx.JSON().ETag(response.WeakETag(operation.UpdatedAt.Format(time.RFC3339Nano) + string(operation.Status))).Data(operation).Write()
+	return err
+}
+
+// Cancel the operation
+func (h *Handler) Cancel(rctx *requestcontext.RequestContext) error {
+	const op xerrors.Op = "operations/cancel"
+
+	id := mux.Vars(rctx.Request())["id"]
+	operation, err := h.manager.Cancel(rctx.Context(), id)
+	if err != nil {
+		return h.writeError(rctx, xerrors.New(op, errorKind(err), err))
+	}
+	_, err = response.JSON(rctx.ResponseWriter()).WriteHeader(http.StatusAccepted).Data(operation).Write()
+	return err
+}
+
+func errorKind(err error) xerrors.Kind {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return xerrors.KindNotFound
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrDone):
+		return xerrors.KindBadRequest
+	}
+	return xerrors.KindInternalError
+}
+
+func (h *Handler) writeError(rctx *requestcontext.RequestContext, err error) error {
+	_, werr := rctx.JSON().Error(err, &response.JSONError{
+		Title:   "Operation Failed",
+		Message: err.Error(),
+	}).Write()
+	if werr != nil {
+		return werr
+	}
+	return err
+}
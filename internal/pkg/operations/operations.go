@@ -0,0 +1,471 @@
+// Package operations run slow work as long-running operation
+// the caller get the operation id immediately, then poll or subscribe the status until the operation is done, or cancel it
+//
+//	op, err := manager.Start(ctx, "export_orders", func(ctx context.Context, report operations.Reporter) (interface{}, error) {
+//		report(50, "half way")
+//		return result, nil
+//	})
+//	operations.Accepted(rctx, op)
+//
+// the status is kept in the Store, use redis store so every instance can serve the status of the operation
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// list of error
+var (
+	ErrNotFound   = errors.New("operations: operation not found")
+	ErrDone       = errors.New("operations: operation is already done")
+	ErrFuncNil    = errors.New("operations: function is nil")
+	ErrNameEmpty  = errors.New("operations: name is empty")
+	ErrCancelled  = errors.New("operations: operation is cancelled")
+	ErrStoreNil   = errors.New("operations: store is nil")
+	ErrInvalidID  = errors.New("operations: invalid operation id")
+	errNotRunning = errors.New("operations: operation is not running in this instance")
+)
+
+// Status of operation
+type Status string
+
+// list of operation status
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Done return true when the operation will not change anymore
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// Operation is the status of long-running operation
+type Operation struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// Progress in percent
+	Progress int    `json:"progress"`
+	Message  string `json:"message,omitempty"`
+	// Result of succeeded operation, encoded as json
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error of failed operation
+	Error string `json:"error,omitempty"`
+	// CancelRequested is set when cancel is requested for the running operation, the operation is stopped by the instance which run it
+	CancelRequested bool       `json:"cancel_requested,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DoneAt          *time.Time `json:"done_at,omitempty"`
+}
+
+// Done return true when the operation will not change anymore
+func (op Operation) Done() bool {
+	return op.Status.Done()
+}
+
+// Reporter report the progress of the operation in percent
+type Reporter func(progress int, message string)
+
+// Func is the work of the operation, the result is encoded as json
+// the context is cancelled when the operation is cancelled
+type Func func(ctx context.Context, report Reporter) (interface{}, error)
+
+// Options of manager
+type Options struct {
+	// TTL of the operation in the store after it is done, default to 24 hours
+	TTL time.Duration
+	// Timeout of the operation, no timeout when zero
+	Timeout time.Duration
+	// PollInterval of subscription to check the store, for operation which run in another instance, default to 1 second
+	PollInterval time.Duration
+	// NewID return the id of new operation, default to random hex string
+	NewID func() string
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// Manager of long-running operations
+type Manager struct {
+	store Store
+	opts  Options
+
+	mu          sync.Mutex
+	running     map[string]context.CancelFunc
+	subscribers map[string][]chan Operation
+	wg          sync.WaitGroup
+}
+
+// New manager
+func New(store Store, options *Options) (*Manager, error) {
+	if store == nil {
+		return nil, ErrStoreNil
+	}
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Hour * 24
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.NewID == nil {
+		opts.NewID = randomID
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	m := Manager{
+		store:       store,
+		opts:        opts,
+		running:     make(map[string]context.CancelFunc),
+		subscribers: make(map[string][]chan Operation),
+	}
+	return &m, nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("operations: failed to generate id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Start the operation in background and return the pending operation
+// the operation keep the values of ctx but not its deadline, so it outlive the request which start it
+func (m *Manager) Start(ctx context.Context, name string, fn Func) (Operation, error) {
+	if name == "" {
+		return Operation{}, ErrNameEmpty
+	}
+	if fn == nil {
+		return Operation{}, ErrFuncNil
+	}
+
+	now := m.opts.Now()
+	op := Operation{
+		ID:        m.opts.NewID(),
+		Name:      name,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.store.Save(ctx, op, 0); err != nil {
+		return Operation{}, err
+	}
+
+	var (
+		runCtx context.Context
+		cancel context.CancelFunc
+	)
+	if m.opts.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(safego.Detach(ctx), m.opts.Timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(safego.Detach(ctx))
+	}
+	m.mu.Lock()
+	m.running[op.ID] = cancel
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	safego.Go(runCtx, "operations."+name, func(ctx context.Context) error {
+		defer m.wg.Done()
+		m.run(ctx, cancel, op, fn)
+		return nil
+	})
+	return op, nil
+}
+
+// run the operation and save every status change
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, op Operation, fn Func) {
+	var mu sync.Mutex
+	defer func() {
+		cancel()
+		m.mu.Lock()
+		delete(m.running, op.ID)
+		m.mu.Unlock()
+	}()
+
+	op.Status = StatusRunning
+	m.save(ctx, op)
+
+	report := func(progress int, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if progress < 0 {
+			progress = 0
+		}
+		if progress > 100 {
+			progress = 100
+		}
+		op.Progress = progress
+		op.Message = message
+		m.save(ctx, op)
+	}
+
+	// cancel might be requested from another instance
+	watched := safego.Go(ctx, "operations.watch_cancel", func(ctx context.Context) error {
+		// the operation is cancelled when the watcher panic, so it doesn't run without the cancel
+		defer cancel()
+		m.watchCancel(ctx, cancel, op.ID)
+		return nil
+	})
+
+	result, err := m.call(ctx, fn, report)
+	var watchErr error
+	if ctx.Err() != nil {
+		// the watcher stop when ctx is done
+		watchErr = <-watched
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	now := m.opts.Now()
+	op.DoneAt = &now
+	switch {
+	case watchErr != nil:
+		op.Status = StatusFailed
+		op.Error = watchErr.Error()
+	case errors.Is(ctx.Err(), context.Canceled):
+		op.Status = StatusCancelled
+		op.Error = ErrCancelled.Error()
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		op.Status = StatusFailed
+		op.Error = context.DeadlineExceeded.Error()
+	case err != nil:
+		op.Status = StatusFailed
+		op.Error = err.Error()
+	default:
+		encoded, jerr := json.Marshal(result)
+		if jerr != nil {
+			op.Status = StatusFailed
+			op.Error = fmt.Sprintf("operations: failed to encode result: %v", jerr)
+			break
+		}
+		op.Status = StatusSucceeded
+		op.Progress = 100
+		op.Result = encoded
+	}
+	// the operation context might be cancelled, but the final status must be saved
+	m.save(context.Background(), op)
+}
+
+// watchCancel cancel the operation when cancel is requested in the store, until ctx is done
+func (m *Manager) watchCancel(ctx context.Context, cancel context.CancelFunc, id string) {
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if requested, err := m.store.CancelRequested(ctx, id); err == nil && requested {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// call the function and return the panic as error
+func (m *Manager) call(ctx context.Context, fn Func, report Reporter) (result interface{}, err error) {
+	err = safego.Run(ctx, "operations.call", func(ctx context.Context) error {
+		var ferr error
+		result, ferr = fn(ctx, report)
+		return ferr
+	})
+	return result, err
+}
+
+// save the operation and notify local subscribers
+func (m *Manager) save(ctx context.Context, op Operation) {
+	op.UpdatedAt = m.opts.Now()
+	var ttl time.Duration
+	if op.Done() {
+		ttl = m.opts.TTL
+	}
+	m.store.Save(ctx, op, ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.subscribers[op.ID] {
+		// subscriber only need the latest status, drop the old one when the buffer is full
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- op
+	}
+}
+
+// Get the operation
+func (m *Manager) Get(ctx context.Context, id string) (Operation, error) {
+	if id == "" {
+		return Operation{}, ErrInvalidID
+	}
+	op, err := m.store.Get(ctx, id)
+	if err != nil || op.Done() {
+		return op, err
+	}
+	op.CancelRequested, err = m.store.CancelRequested(ctx, id)
+	return op, err
+}
+
+// Cancel the operation, the operation is stopped by the instance which run it
+// the operation might still be succeeded when the function doesn't check the context
+func (m *Manager) Cancel(ctx context.Context, id string) (Operation, error) {
+	op, err := m.Get(ctx, id)
+	if err != nil {
+		return op, err
+	}
+	if op.Done() {
+		return op, ErrDone
+	}
+
+	if err := m.store.RequestCancel(ctx, id, m.opts.TTL); err != nil {
+		return op, err
+	}
+	op.CancelRequested = true
+	if err := m.cancelLocal(id); err != nil && !errors.Is(err, errNotRunning) {
+		return op, err
+	}
+	return op, nil
+}
+
+func (m *Manager) cancelLocal(id string) error {
+	m.mu.Lock()
+	cancel, ok := m.running[id]
+	m.mu.Unlock()
+	if !ok {
+		return errNotRunning
+	}
+	cancel()
+	return nil
+}
+
+// Subscribe return the status of the operation every time it is changed, the channel is closed when the operation is done or ctx is done
+// the current status is sent first
+func (m *Manager) Subscribe(ctx context.Context, id string) (<-chan Operation, error) {
+	out, _, err := m.subscribe(ctx, id)
+	return out, err
+}
+
+// subscribe return the status of the operation and the error of the polling, the error is sent when the polling panic
+func (m *Manager) subscribe(ctx context.Context, id string) (<-chan Operation, <-chan error, error) {
+	op, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local := make(chan Operation, 1)
+	m.mu.Lock()
+	m.subscribers[id] = append(m.subscribers[id], local)
+	m.mu.Unlock()
+
+	out := make(chan Operation, 1)
+	out <- op
+	if op.Done() {
+		m.unsubscribe(id, local)
+		close(out)
+		return out, nil, nil
+	}
+
+	errs := safego.Go(ctx, "operations.subscribe", func(ctx context.Context) error {
+		defer func() {
+			m.unsubscribe(id, local)
+			close(out)
+		}()
+		ticker := time.NewTicker(m.opts.PollInterval)
+		defer ticker.Stop()
+
+		last := op
+		for {
+			var current Operation
+			select {
+			case <-ctx.Done():
+				return nil
+			case current = <-local:
+			case <-ticker.C:
+				polled, err := m.store.Get(ctx, id)
+				if err != nil {
+					continue
+				}
+				current = polled
+			}
+			if current.UpdatedAt.Equal(last.UpdatedAt) && current.Status == last.Status {
+				continue
+			}
+			last = current
+			select {
+			case out <- current:
+			case <-ctx.Done():
+				return nil
+			}
+			if current.Done() {
+				return nil
+			}
+		}
+	})
+	return out, errs, nil
+}
+
+func (m *Manager) unsubscribe(id string, ch chan Operation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscribers := m.subscribers[id]
+	for idx, sub := range subscribers {
+		if sub == ch {
+			subscribers = append(subscribers[:idx], subscribers[idx+1:]...)
+			break
+		}
+	}
+	if len(subscribers) == 0 {
+		delete(m.subscribers, id)
+		return
+	}
+	m.subscribers[id] = subscribers
+}
+
+// Wait until the operation is done or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (Operation, error) {
+	updates, errs, err := m.subscribe(ctx, id)
+	if err != nil {
+		return Operation{}, err
+	}
+	var op Operation
+	for op = range updates {
+	}
+	if !op.Done() {
+		if err := <-errs; err != nil {
+			return op, err
+		}
+		return op, ctx.Err()
+	}
+	return op, nil
+}
+
+// Close cancel all operations running in this instance and wait for them to stop
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	for _, cancel := range m.running {
+		cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return nil
+}
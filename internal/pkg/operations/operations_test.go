@@ -0,0 +1,341 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := New(NewMemoryStore(), &Options{PollInterval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func waitDone(t *testing.T, m *Manager, id string) Operation {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	op, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to wait operation %s: %v", id, err)
+	}
+	return op
+}
+
+func TestOperation(t *testing.T) {
+	cases := []struct {
+		name         string
+		fn           Func
+		expectStatus Status
+		expectResult string
+		expectError  string
+	}{
+		{
+			name: "succeeded",
+			fn: func(ctx context.Context, report Reporter) (interface{}, error) {
+				report(50, "half way")
+				return map[string]int{"total": 10}, nil
+			},
+			expectStatus: StatusSucceeded,
+			expectResult: `{"total":10}`,
+		},
+		{
+			name: "failed",
+			fn: func(ctx context.Context, report Reporter) (interface{}, error) {
+				return nil, errors.New("something wrong")
+			},
+			expectStatus: StatusFailed,
+			expectError:  "something wrong",
+		},
+		{
+			name: "panic",
+			fn: func(ctx context.Context, report Reporter) (interface{}, error) {
+				panic("boom")
+			},
+			expectStatus: StatusFailed,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newTestManager(t)
+			defer m.Close()
+
+			started, err := m.Start(context.Background(), c.name, c.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if started.ID == "" || started.Status != StatusPending {
+				t.Fatalf("expecting pending operation with id but got %+v", started)
+			}
+
+			op := waitDone(t, m, started.ID)
+			if op.Status != c.expectStatus {
+				t.Fatalf("expecting status %s but got %s", c.expectStatus, op.Status)
+			}
+			if c.expectResult != "" && string(op.Result) != c.expectResult {
+				t.Errorf("expecting result %s but got %s", c.expectResult, op.Result)
+			}
+			if c.expectError != "" && op.Error != c.expectError {
+				t.Errorf("expecting error %s but got %s", c.expectError, op.Error)
+			}
+			if op.DoneAt == nil {
+				t.Error("expecting done_at to be set")
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+
+	next := make(chan struct{})
+	started, err := m.Start(context.Background(), "progress", func(ctx context.Context, report Reporter) (interface{}, error) {
+		<-next
+		report(50, "half way")
+		<-next
+		return "ok", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	updates, err := m.Subscribe(ctx, started.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next <- struct{}{}
+
+	// subscriber only receive the latest status, so the operation is finished after the progress is received
+	var sawProgress bool
+	for op := range updates {
+		if op.Progress == 50 && op.Status == StatusRunning && !sawProgress {
+			sawProgress = true
+			next <- struct{}{}
+		}
+		if op.Done() {
+			if op.Status != StatusSucceeded {
+				t.Errorf("expecting last update to be %s but got %s", StatusSucceeded, op.Status)
+			}
+		}
+	}
+	if !sawProgress {
+		t.Error("expecting progress update")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+
+	started, err := m.Start(context.Background(), "cancel", func(ctx context.Context, report Reporter) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Cancel(context.Background(), started.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	op := waitDone(t, m, started.ID)
+	if op.Status != StatusCancelled {
+		t.Fatalf("expecting status %s but got %s", StatusCancelled, op.Status)
+	}
+	if _, err := m.Cancel(context.Background(), started.ID); !errors.Is(err, ErrDone) {
+		t.Errorf("expecting error %v but got %v", ErrDone, err)
+	}
+	if _, err := m.Cancel(context.Background(), "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expecting error %v but got %v", ErrNotFound, err)
+	}
+}
+
+// cancel from another instance is picked by the instance which run the operation
+func TestCancelFromStore(t *testing.T) {
+	store := NewMemoryStore()
+	runner, err := New(store, &Options{PollInterval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+	other, err := New(store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started, err := runner.Start(context.Background(), "remote_cancel", func(ctx context.Context, report Reporter) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Cancel(context.Background(), started.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if op := waitDone(t, runner, started.ID); op.Status != StatusCancelled {
+		t.Errorf("expecting status %s but got %s", StatusCancelled, op.Status)
+	}
+}
+
+// panicStore panic once in CancelRequested, and in every Get after the first when panicGet is set
+type panicStore struct {
+	*MemoryStore
+	once     sync.Once
+	panicked chan struct{}
+	panicGet bool
+	gets     int32
+}
+
+func (s *panicStore) CancelRequested(ctx context.Context, id string) (bool, error) {
+	if !s.panicGet {
+		s.once.Do(func() {
+			close(s.panicked)
+			panic("boom")
+		})
+	}
+	return s.MemoryStore.CancelRequested(ctx, id)
+}
+
+func (s *panicStore) Get(ctx context.Context, id string) (Operation, error) {
+	if s.panicGet && atomic.AddInt32(&s.gets, 1) > 1 {
+		panic("boom")
+	}
+	return s.MemoryStore.Get(ctx, id)
+}
+
+func TestWatchCancelPanic(t *testing.T) {
+	store := &panicStore{MemoryStore: NewMemoryStore(), panicked: make(chan struct{})}
+	m, err := New(store, &Options{PollInterval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	started, err := m.Start(context.Background(), "watch_panic", func(ctx context.Context, report Reporter) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-store.panicked
+
+	op := waitDone(t, m, started.ID)
+	if op.Status != StatusFailed {
+		t.Fatalf("expecting status %s but got %s", StatusFailed, op.Status)
+	}
+	if !strings.Contains(op.Error, "boom") {
+		t.Errorf("expecting the panic as the error but got %s", op.Error)
+	}
+}
+
+func TestWaitPanic(t *testing.T) {
+	store := &panicStore{MemoryStore: NewMemoryStore(), panicGet: true}
+	m, err := New(store, &Options{PollInterval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	started, err := m.Start(context.Background(), "wait_panic", func(ctx context.Context, report Reporter) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	var perr *safego.PanicError
+	if _, err := m.Wait(ctx, started.ID); !errors.As(err, &perr) {
+		t.Fatalf("expecting panic error but got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+	h := NewHandler(m, "/v1")
+
+	r := router.New(":0", nil)
+	h.Register(r)
+	r.Post("/v1/exports", func(rctx *requestcontext.RequestContext) error {
+		op, err := m.Start(rctx.Context(), "export", func(ctx context.Context, report Reporter) (interface{}, error) {
+			return "done", nil
+		})
+		if err != nil {
+			return err
+		}
+		return h.Accepted(rctx, op)
+	})
+
+	do := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/v1/exports", nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expecting %d but got %d", http.StatusAccepted, w.Code)
+	}
+	location := w.Header().Get("Location")
+
+	w = do(http.MethodGet, location+"?wait=5s", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expecting %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	resp := struct {
+		Data Operation `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Status != StatusSucceeded || string(resp.Data.Result) != `"done"` {
+		t.Errorf("expecting succeeded operation but got %+v", resp.Data)
+	}
+
+	w = do(http.MethodGet, location, map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expecting %d but got %d", http.StatusNotModified, w.Code)
+	}
+
+	if w = do(http.MethodPost, location+"/cancel", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expecting %d when cancel done operation but got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = do(http.MethodGet, "/v1/operations/unknown", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expecting %d but got %d", http.StatusNotFound, w.Code)
+	}
+	if w = do(http.MethodGet, location+"?wait=abc", nil); w.Code != http.StatusOK {
+		// done operation doesn't wait, so the wait parameter is not parsed
+		t.Errorf("expecting %d but got %d", http.StatusOK, w.Code)
+	}
+}
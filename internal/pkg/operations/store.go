@@ -0,0 +1,174 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+)
+
+// Store of operation status
+type Store interface {
+	Get(ctx context.Context, id string) (Operation, error)
+	// Save the operation, the operation is removed after ttl, never removed when ttl is zero
+	Save(ctx context.Context, op Operation, ttl time.Duration) error
+	// RequestCancel mark the operation as cancelled, kept apart from the operation so the runner doesn't overwrite it
+	RequestCancel(ctx context.Context, id string, ttl time.Duration) error
+	// CancelRequested return true when cancel is requested for the operation
+	CancelRequested(ctx context.Context, id string) (bool, error)
+}
+
+// MemoryStore store the operations in memory, only for single instance and testing
+type MemoryStore struct {
+	mu         sync.RWMutex
+	operations map[string]memoryOperation
+	cancelled  map[string]time.Time
+	now        func() time.Time
+}
+
+type memoryOperation struct {
+	op       Operation
+	expireAt time.Time
+}
+
+// NewMemoryStore return new in memory store
+func NewMemoryStore() *MemoryStore {
+	s := MemoryStore{
+		operations: make(map[string]memoryOperation),
+		cancelled:  make(map[string]time.Time),
+		now:        time.Now,
+	}
+	return &s
+}
+
+// Get operation
+func (s *MemoryStore) Get(ctx context.Context, id string) (Operation, error) {
+	s.mu.RLock()
+	mop, ok := s.operations[id]
+	s.mu.RUnlock()
+	if !ok || (!mop.expireAt.IsZero() && s.now().After(mop.expireAt)) {
+		return Operation{}, ErrNotFound
+	}
+	return mop.op, nil
+}
+
+// Save operation
+func (s *MemoryStore) Save(ctx context.Context, op Operation, ttl time.Duration) error {
+	mop := memoryOperation{op: op}
+	if ttl > 0 {
+		mop.expireAt = s.now().Add(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// remove expired operations, so the store doesn't grow forever
+	now := s.now()
+	for id, stored := range s.operations {
+		if !stored.expireAt.IsZero() && now.After(stored.expireAt) {
+			delete(s.operations, id)
+		}
+	}
+	for id, expireAt := range s.cancelled {
+		if now.After(expireAt) {
+			delete(s.cancelled, id)
+		}
+	}
+	s.operations[op.ID] = mop
+	return nil
+}
+
+// RequestCancel of operation
+func (s *MemoryStore) RequestCancel(ctx context.Context, id string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelled[id] = s.now().Add(ttl)
+	return nil
+}
+
+// CancelRequested return true when cancel is requested for the operation
+func (s *MemoryStore) CancelRequested(ctx context.Context, id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expireAt, ok := s.cancelled[id]
+	return ok && !s.now().After(expireAt), nil
+}
+
+// RedisStore store the operations in redis, so the status can be read from every instance
+type RedisStore struct {
+	redis  redis.Redis
+	prefix string
+}
+
+// NewRedisStore return new redis store, default prefix is operations:
+func NewRedisStore(r redis.Redis, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "operations:"
+	}
+	s := RedisStore{
+		redis:  r,
+		prefix: prefix,
+	}
+	return &s
+}
+
+// Get operation
+func (s *RedisStore) Get(ctx context.Context, id string) (Operation, error) {
+	op := Operation{}
+	out, err := s.redis.Get(ctx, s.prefix+id)
+	if err != nil {
+		if s.redis.IsErrNil(err) {
+			return op, ErrNotFound
+		}
+		return op, err
+	}
+	if out == "" {
+		return op, ErrNotFound
+	}
+	if err := json.Unmarshal([]byte(out), &op); err != nil {
+		return op, fmt.Errorf("operations: failed to decode operation %s: %w", id, err)
+	}
+	return op, nil
+}
+
+// Save operation
+func (s *RedisStore) Save(ctx context.Context, op Operation, ttl time.Duration) error {
+	out, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		_, err = s.redis.Set(ctx, s.prefix+op.ID, out)
+		return err
+	}
+	expire := int(ttl / time.Second)
+	if expire < 1 {
+		expire = 1
+	}
+	_, err = s.redis.SetEX(ctx, s.prefix+op.ID, out, expire)
+	return err
+}
+
+// RequestCancel of operation
+func (s *RedisStore) RequestCancel(ctx context.Context, id string, ttl time.Duration) error {
+	expire := int(ttl / time.Second)
+	if expire < 1 {
+		expire = 1
+	}
+	_, err := s.redis.SetEX(ctx, s.prefix+id+":cancel", "1", expire)
+	return err
+}
+
+// CancelRequested return true when cancel is requested for the operation
+func (s *RedisStore) CancelRequested(ctx context.Context, id string) (bool, error) {
+	out, err := s.redis.Get(ctx, s.prefix+id+":cancel")
+	if err != nil {
+		if s.redis.IsErrNil(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return out != "", nil
+}
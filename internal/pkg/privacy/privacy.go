@@ -0,0 +1,340 @@
+// Package privacy export the personal data of a data subject from every registered source into one zip archive
+// for example to answer the gdpr request of a user to access its data
+// the export run as long-running operation, and every request, completion and failure is published as audit event
+//
+//	exporter, err := privacy.New(storage, manager, &privacy.Options{Bus: bus, URLExpiry: time.Hour * 24})
+//	err = exporter.Register(
+//		privacy.SQL("orders", db, "SELECT id, total, created_at FROM orders WHERE user_id = $1"),
+//		privacy.Objects("avatars", storage, func(subject string) string { return "avatars/" + subject + "/" }),
+//		privacy.Redis("sessions", r, func(subject string) []string { return []string{"session:" + subject} }),
+//	)
+//	op, err := exporter.Start(ctx, privacy.Request{Subject: "user-1", Actor: "dpo@example.com", Reason: "access request #42"})
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/operations"
+)
+
+// OperationName of the export operation
+const OperationName = "privacy_export"
+
+// ManifestName is the name of the manifest file in the archive
+const ManifestName = "manifest.json"
+
+// default value of options
+const (
+	DefaultPrefix = "privacy-exports/"
+)
+
+// list of audit action
+const (
+	ActionRequested = "requested"
+	ActionCompleted = "completed"
+	ActionFailed    = "failed"
+)
+
+// TopicAudit of the audit events of the exports
+var TopicAudit = eventbus.NewTopic("privacy.export.audit", AuditEvent{})
+
+// list of error
+var (
+	ErrStorageNil       = errors.New("privacy: storage is nil")
+	ErrManagerNil       = errors.New("privacy: operations manager is nil")
+	ErrSourceNameEmpty  = errors.New("privacy: source name is empty")
+	ErrDuplicateSource  = errors.New("privacy: duplicate source")
+	ErrSourcesEmpty     = errors.New("privacy: no source is registered")
+	ErrSubjectEmpty     = errors.New("privacy: subject is empty")
+	ErrActorEmpty       = errors.New("privacy: actor is empty")
+	ErrExportIDEmpty    = errors.New("privacy: export id is empty")
+	errExportNotStarted = errors.New("privacy: export is not started")
+)
+
+// Options of exporter
+type Options struct {
+	// Prefix of the archive key, default to privacy-exports/
+	Prefix string
+	// URLExpiry of the signed url of the archive in the result, the url is not signed when zero
+	URLExpiry time.Duration
+	// Bus to publish the audit events to TopicAudit, the events are not published when nil
+	Bus *eventbus.Bus
+	// OnError is called when the audit event of the completion or the failure failed to be published
+	OnError func(err error)
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+}
+
+// Request of the export
+type Request struct {
+	// Subject of the data, for example the user id
+	Subject string `json:"subject"`
+	// Actor who request the export, for example the data protection officer or the user itself
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// AuditEvent of the export
+type AuditEvent struct {
+	ID      string    `json:"id"`
+	Action  string    `json:"action"`
+	Subject string    `json:"subject"`
+	Actor   string    `json:"actor"`
+	Reason  string    `json:"reason,omitempty"`
+	Key     string    `json:"key,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// SourceResult of the export
+type SourceResult struct {
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+}
+
+// Result of the export, it is the result of the operation
+type Result struct {
+	ID      string `json:"id"`
+	Subject string `json:"subject"`
+	// Key of the archive in the storage
+	Key string `json:"key"`
+	// URL to download the archive, it is empty when URLExpiry is not set
+	URL       string         `json:"url,omitempty"`
+	Sources   []SourceResult `json:"sources"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Exporter of the personal data
+type Exporter struct {
+	storage *objectstorage.Storage
+	manager *operations.Manager
+	opts    Options
+
+	mu      sync.Mutex
+	sources []Source
+}
+
+// New exporter which write the archives to the storage and run the exports in the manager
+func New(storage *objectstorage.Storage, manager *operations.Manager, options *Options) (*Exporter, error) {
+	if storage == nil {
+		return nil, ErrStorageNil
+	}
+	if manager == nil {
+		return nil, ErrManagerNil
+	}
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	e := Exporter{
+		storage: storage,
+		manager: manager,
+		opts:    opts,
+	}
+	return &e, nil
+}
+
+// Register the sources of the personal data, the sources are collected in the order they are registered
+func (e *Exporter) Register(sources ...Source) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, source := range sources {
+		if source.Name() == "" {
+			return ErrSourceNameEmpty
+		}
+		for _, s := range e.sources {
+			if s.Name() == source.Name() {
+				return fmt.Errorf("%w: %s", ErrDuplicateSource, source.Name())
+			}
+		}
+		e.sources = append(e.sources, source)
+	}
+	return nil
+}
+
+// Start the export in background and return the pending operation, the result of the operation is Result
+// the export is not started when the audit event of the request failed to be published
+func (e *Exporter) Start(ctx context.Context, req Request) (operations.Operation, error) {
+	if err := validate(req); err != nil {
+		return operations.Operation{}, err
+	}
+	if len(e.Sources()) == 0 {
+		return operations.Operation{}, ErrSourcesEmpty
+	}
+
+	// the operation id is the id of the export, it is known after the operation is started
+	started := make(chan string, 1)
+	op, err := e.manager.Start(ctx, OperationName, func(ctx context.Context, report operations.Reporter) (interface{}, error) {
+		var id string
+		select {
+		case id = <-started:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if id == "" {
+			return nil, errExportNotStarted
+		}
+		return e.Export(ctx, id, req, report)
+	})
+	if err != nil {
+		return operations.Operation{}, err
+	}
+	if err := e.audit(ctx, AuditEvent{ID: op.ID, Action: ActionRequested, Subject: req.Subject, Actor: req.Actor, Reason: req.Reason}); err != nil {
+		started <- ""
+		return operations.Operation{}, err
+	}
+	started <- op.ID
+	return op, nil
+}
+
+// Get the operation of the export
+func (e *Exporter) Get(ctx context.Context, id string) (operations.Operation, error) {
+	return e.manager.Get(ctx, id)
+}
+
+// Sources return the registered sources
+func (e *Exporter) Sources() []Source {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Source(nil), e.sources...)
+}
+
+// Export collect the data of the subject from every source to the archive and return the result, the report can be nil
+// the export failed when any source failed, as the archive must contain all personal data of the subject
+func (e *Exporter) Export(ctx context.Context, id string, req Request, report operations.Reporter) (*Result, error) {
+	if id == "" {
+		return nil, ErrExportIDEmpty
+	}
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if report == nil {
+		report = func(progress int, message string) {}
+	}
+
+	event := AuditEvent{ID: id, Subject: req.Subject, Actor: req.Actor, Reason: req.Reason}
+	result, err := e.export(ctx, id, req.Subject, report)
+	if err != nil {
+		event.Action = ActionFailed
+		event.Error = err.Error()
+	} else {
+		event.Action = ActionCompleted
+		event.Key = result.Key
+	}
+	// the context might be cancelled, but the outcome must be audited
+	if aerr := e.audit(context.Background(), event); aerr != nil {
+		e.opts.OnError(aerr)
+	}
+	return result, err
+}
+
+func (e *Exporter) export(ctx context.Context, id, subject string, report operations.Reporter) (*Result, error) {
+	sources := e.Sources()
+	if len(sources) == 0 {
+		return nil, ErrSourcesEmpty
+	}
+
+	now := e.opts.Now()
+	result := Result{
+		ID:        id,
+		Subject:   subject,
+		Key:       path.Join(e.opts.Prefix, url.PathEscape(subject), url.PathEscape(id)+".zip"),
+		CreatedAt: now.UTC(),
+	}
+
+	// canceling the context before the blob writer is closed abort the upload, so failed export doesn't leave partial archive
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := e.storage.Stream(ctx, result.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+	bw, err := stream.Writer(ctx, result.Key, &objectstorage.WriteOptions{
+		ContentType:        "application/zip",
+		ContentDisposition: fmt.Sprintf("attachment; filename=%q", path.Base(result.Key)),
+		Metadata:           map[string]string{"subject": subject, "export_id": id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("privacy: failed to open %s: %w", result.Key, err)
+	}
+
+	zw := zip.NewWriter(bw)
+	err = e.write(ctx, zw, sources, subject, now, report, &result)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		cancel()
+		bw.Close()
+		return nil, err
+	}
+	if err := bw.Close(); err != nil {
+		return nil, fmt.Errorf("privacy: failed to upload %s: %w", result.Key, err)
+	}
+
+	if e.opts.URLExpiry > 0 {
+		result.URL, err = e.storage.SignedURL(ctx, result.Key, e.opts.URLExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("privacy: failed to sign url of %s: %w", result.Key, err)
+		}
+	}
+	return &result, nil
+}
+
+// write the files of every source and the manifest to the archive
+func (e *Exporter) write(ctx context.Context, zw *zip.Writer, sources []Source, subject string, now time.Time, report operations.Reporter, result *Result) error {
+	for idx, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report(idx*100/len(sources), "collecting "+source.Name())
+		archive := Archive{zw: zw, dir: source.Name(), modTime: now}
+		if err := source.Collect(ctx, subject, &archive); err != nil {
+			return fmt.Errorf("privacy: failed to collect %s: %w", source.Name(), err)
+		}
+		result.Sources = append(result.Sources, SourceResult{Name: source.Name(), Files: archive.files})
+	}
+	manifest := Archive{zw: zw, modTime: now}
+	return manifest.AddJSON(ManifestName, result)
+}
+
+// audit publish the audit event to the bus
+func (e *Exporter) audit(ctx context.Context, event AuditEvent) error {
+	if e.opts.Bus == nil {
+		return nil
+	}
+	event.At = e.opts.Now()
+	if err := e.opts.Bus.Publish(ctx, TopicAudit, event); err != nil {
+		return fmt.Errorf("privacy: failed to publish audit event %s of %s: %w", event.Action, event.ID, err)
+	}
+	return nil
+}
+
+func validate(req Request) error {
+	if req.Subject == "" {
+		return ErrSubjectEmpty
+	}
+	if req.Actor == "" {
+		return ErrActorEmpty
+	}
+	return nil
+}
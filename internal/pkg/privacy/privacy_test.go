@@ -0,0 +1,255 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/eventbus"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/operations"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/alicebob/miniredis/v2"
+)
+
+type auditLog struct {
+	mu     sync.Mutex
+	events []AuditEvent
+	fail   error
+}
+
+func (a *auditLog) handle(ctx context.Context, event eventbus.Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fail != nil {
+		return a.fail
+	}
+	a.events = append(a.events, event.Payload.(AuditEvent))
+	return nil
+}
+
+func (a *auditLog) actions() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var actions []string
+	for _, event := range a.events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+func newTestExporter(t *testing.T) (*Exporter, *objectstorage.Storage, *auditLog, func()) {
+	t.Helper()
+	ctx := context.Background()
+
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := []string{
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id TEXT, total INTEGER)",
+		"INSERT INTO orders VALUES (1, 'user-1', 100), (2, 'user-2', 200), (3, 'user-1', 300)",
+	}
+	for _, query := range queries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage := objectstorage.New(memory.New("test"))
+	for key, content := range map[string]string{
+		"avatars/user-1/small.png": "small",
+		"avatars/user-1/large.png": "large",
+		"avatars/user-2/small.png": "other",
+	} {
+		if _, err := storage.UploadByte(ctx, []byte(content), key, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.Set("session:user-1", "token")
+	mr.HSet("profile:user-1", "name", "John")
+	r, err := redigo.New(ctx, mr.Addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := operations.New(operations.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := eventbus.New(nil)
+	audit := &auditLog{}
+	if _, err := bus.Subscribe(TopicAudit, audit.handle, nil); err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(storage, manager, &Options{
+		Bus: bus,
+		Now: func() time.Time { return time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Register(
+		SQL("orders", db, "SELECT id, total FROM orders WHERE user_id = ? ORDER BY id"),
+		Objects("avatars", storage, func(subject string) string { return "avatars/" + subject + "/" }),
+		Redis("sessions", r, func(subject string) []string { return []string{"session:" + subject, "missing:" + subject} }),
+		RedisHash("profiles", r, func(subject string) []string { return []string{"profile:" + subject} }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, storage, audit, mr.Close
+}
+
+func readArchive(t *testing.T, storage *objectstorage.Storage, key string) map[string]string {
+	t.Helper()
+	content, err := storage.DownloadByte(context.Background(), key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(out)
+	}
+	return files
+}
+
+func TestRegister(t *testing.T) {
+	e, _, _, closeRedis := newTestExporter(t)
+	defer closeRedis()
+
+	noop := func(ctx context.Context, subject string, archive *Archive) error { return nil }
+	if err := e.Register(SourceFunc("", noop)); !errors.Is(err, ErrSourceNameEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrSourceNameEmpty, err)
+	}
+	if err := e.Register(SourceFunc("orders", noop)); !errors.Is(err, ErrDuplicateSource) {
+		t.Fatalf("expecting error %v but got %v", ErrDuplicateSource, err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	e, storage, audit, closeRedis := newTestExporter(t)
+	defer closeRedis()
+	ctx := context.Background()
+
+	if _, err := e.Start(ctx, Request{Subject: "user-1"}); !errors.Is(err, ErrActorEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrActorEmpty, err)
+	}
+	op, err := e.Start(ctx, Request{Subject: "user-1", Actor: "dpo@example.com", Reason: "access request"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	op, err = e.manager.Wait(waitCtx, op.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != operations.StatusSucceeded {
+		t.Fatalf("expecting status %s but got %s: %s", operations.StatusSucceeded, op.Status, op.Error)
+	}
+	result := Result{}
+	if err := json.Unmarshal(op.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Key != "privacy-exports/user-1/"+op.ID+".zip" {
+		t.Fatalf("unexpected key %s", result.Key)
+	}
+
+	files := readArchive(t, storage, result.Key)
+	expect := map[string]string{
+		"orders/rows.ndjson":   "{\"id\":1,\"total\":100}\n{\"id\":3,\"total\":300}\n",
+		"avatars/large.png":    "large",
+		"avatars/small.png":    "small",
+		"sessions/values.json": "{\n  \"session:user-1\": \"token\"\n}\n",
+		"profiles/hashes.json": "{\n  \"profile:user-1\": {\n    \"name\": \"John\"\n  }\n}\n",
+	}
+	for name, content := range expect {
+		if files[name] != content {
+			t.Fatalf("expecting %s to be %q but got %q", name, content, files[name])
+		}
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(files) != len(expect)+1 {
+		t.Fatalf("expecting %d files but got %v", len(expect)+1, names)
+	}
+	manifest := Result{}
+	if err := json.Unmarshal([]byte(files[ManifestName]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Sources) != 4 || manifest.Sources[1].Name != "avatars" || len(manifest.Sources[1].Files) != 2 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	actions := audit.actions()
+	if len(actions) != 2 || actions[0] != ActionRequested || actions[1] != ActionCompleted {
+		t.Fatalf("expecting requested and completed audit events but got %v", actions)
+	}
+	if audit.events[1].Key != result.Key || audit.events[1].Actor != "dpo@example.com" {
+		t.Fatalf("unexpected audit event %+v", audit.events[1])
+	}
+}
+
+func TestExportFailed(t *testing.T) {
+	e, storage, audit, closeRedis := newTestExporter(t)
+	defer closeRedis()
+	ctx := context.Background()
+
+	if err := e.Register(SourceFunc("broken", func(ctx context.Context, subject string, archive *Archive) error {
+		return errors.New("source is down")
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Export(ctx, "export-1", Request{Subject: "user-1", Actor: "dpo"}, nil); err == nil {
+		t.Fatal("expecting error but got nil")
+	}
+	// the partial archive is not uploaded
+	if _, err := storage.Attributes(ctx, "privacy-exports/user-1/export-1.zip"); err == nil {
+		t.Fatal("expecting the archive is not uploaded")
+	}
+	actions := audit.actions()
+	if len(actions) != 1 || actions[0] != ActionFailed || audit.events[0].Error == "" {
+		t.Fatalf("expecting failed audit event but got %+v", audit.events)
+	}
+
+	// the export is not started when the request cannot be audited
+	audit.fail = errors.New("audit log is down")
+	if _, err := e.Start(ctx, Request{Subject: "user-1", Actor: "dpo"}); err == nil {
+		t.Fatal("expecting error but got nil")
+	}
+}
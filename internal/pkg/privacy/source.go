@@ -0,0 +1,184 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/export"
+)
+
+// ErrFileNameInvalid is returned when the file name of the source is empty or not relative
+var ErrFileNameInvalid = errors.New("privacy: file name must be relative and not empty")
+
+// Source of the personal data of a subject, for example the orders of the user
+type Source interface {
+	// Name of the source, the files of the source are written in the directory of the name
+	Name() string
+	// Collect write the data of the subject as the files of the archive
+	Collect(ctx context.Context, subject string, archive *Archive) error
+}
+
+// Archive of the export, the files of every source are written in the directory of the source
+type Archive struct {
+	zw      *zip.Writer
+	dir     string
+	modTime time.Time
+	files   []string
+}
+
+// Create a file of the source in the archive, the file must be written before the next file is created
+func (a *Archive) Create(name string) (io.Writer, error) {
+	clean := path.Clean(name)
+	if name == "" || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, fmt.Errorf("%w: %s", ErrFileNameInvalid, name)
+	}
+	name = path.Join(a.dir, clean)
+	a.files = append(a.files, name)
+	return a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.modTime})
+}
+
+// AddJSON write the value as indented json file in the archive
+func (a *Archive) AddJSON(name string, v interface{}) error {
+	w, err := a.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+type sourceFunc struct {
+	name string
+	fn   func(ctx context.Context, subject string, archive *Archive) error
+}
+
+func (s sourceFunc) Name() string {
+	return s.name
+}
+
+func (s sourceFunc) Collect(ctx context.Context, subject string, archive *Archive) error {
+	return s.fn(ctx, subject, archive)
+}
+
+// SourceFunc return the source which collect the data with the function
+func SourceFunc(name string, fn func(ctx context.Context, subject string, archive *Archive) error) Source {
+	return sourceFunc{name: name, fn: fn}
+}
+
+// SQL return the source of the query result as rows.ndjson, the subject is the only argument of the query
+// the query run in the follower unless it is not a read query
+//
+//	privacy.SQL("orders", db, "SELECT id, total, created_at FROM orders WHERE user_id = $1")
+func SQL(name string, db *sqldb.DB, query string) Source {
+	return SourceFunc(name, func(ctx context.Context, subject string, archive *Archive) error {
+		rows, err := db.QueryContext(ctx, query, subject)
+		if err != nil {
+			return fmt.Errorf("privacy: failed to query %s: %w", name, err)
+		}
+		defer rows.Close()
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return err
+		}
+		w, err := archive.Create("rows.ndjson")
+		if err != nil {
+			return err
+		}
+		nw := export.NewNDJSONWriter(w, export.InferSchema(columnTypes))
+		if _, err := export.WriteRows(rows, nw); err != nil {
+			return err
+		}
+		return nw.Close()
+	})
+}
+
+// Objects return the source of the objects with the prefix of the subject, every object is written as is
+// the path of the file is the key without the prefix
+//
+//	privacy.Objects("avatars", storage, func(subject string) string { return "avatars/" + subject + "/" })
+func Objects(name string, storage *objectstorage.Storage, prefix func(subject string) string) Source {
+	return SourceFunc(name, func(ctx context.Context, subject string, archive *Archive) error {
+		p := prefix(subject)
+		keys, err := storage.List(ctx, p)
+		if err != nil {
+			return fmt.Errorf("privacy: failed to list %s: %w", p, err)
+		}
+		for _, key := range keys {
+			// the directory marker of some providers has no content
+			if key == p || strings.HasSuffix(key, "/") {
+				continue
+			}
+			if err := copyObject(ctx, storage, key, strings.TrimPrefix(key, p), archive); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func copyObject(ctx context.Context, storage *objectstorage.Storage, key, name string, archive *Archive) error {
+	reader, err := storage.Download(ctx, key, nil)
+	if err != nil {
+		return fmt.Errorf("privacy: failed to download %s: %w", key, err)
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// Redis return the source of the string values of the keys as values.json, the keys which don't exist are skipped
+//
+//	privacy.Redis("sessions", r, func(subject string) []string { return []string{"session:" + subject} })
+func Redis(name string, r redis.Redis, keys func(subject string) []string) Source {
+	return SourceFunc(name, func(ctx context.Context, subject string, archive *Archive) error {
+		values := make(map[string]string)
+		for _, key := range keys(subject) {
+			value, err := r.Get(ctx, key)
+			if r.IsErrNil(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("privacy: failed to get %s: %w", key, err)
+			}
+			values[key] = value
+		}
+		return archive.AddJSON("values.json", values)
+	})
+}
+
+// RedisHash return the source of the hash keys as hashes.json, the keys which don't exist are skipped
+func RedisHash(name string, r redis.Redis, keys func(subject string) []string) Source {
+	return SourceFunc(name, func(ctx context.Context, subject string, archive *Archive) error {
+		hashes := make(map[string]map[string]string)
+		for _, key := range keys(subject) {
+			fields, err := r.HGetAll(ctx, key)
+			if r.IsErrNil(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("privacy: failed to get %s: %w", key, err)
+			}
+			if len(fields) > 0 {
+				hashes[key] = fields
+			}
+		}
+		return archive.AddJSON("hashes.json", hashes)
+	})
+}
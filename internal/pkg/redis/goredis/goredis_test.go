@@ -0,0 +1,202 @@
+package goredis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T, conf Config) (redis.Redis, *miniredis.Miniredis) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Addresses = []string{mr.Addr()}
+	g, err := New(context.Background(), &conf)
+	if err != nil {
+		mr.Close()
+		t.Fatal(err)
+	}
+	return g, mr
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{name: "empty addresses", config: Config{}, err: ErrAddressesEmpty},
+		{name: "sentinel without master", config: Config{Mode: ModeSentinel, Addresses: []string{"sentinel-0:26379"}}, err: ErrMasterNameEmpty},
+		{name: "unknown mode", config: Config{Mode: "replica", Addresses: []string{"redis:6379"}}, err: ErrModeNotSupported},
+		{name: "invalid url", config: Config{Addresses: []string{"http://redis:6379"}}},
+	}
+
+	for _, c := range cases {
+		conf := c.config
+		_, err := New(context.Background(), &conf)
+		if c.err == nil && err == nil {
+			t.Errorf("%s: expecting error but got nil", c.name)
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%s: expecting error %v but got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		commands []string
+	)
+	r, mr := newTestRedis(t, Config{
+		OnCommand: func(ctx context.Context, cmd string, duration time.Duration, err error) {
+			mu.Lock()
+			commands = append(commands, cmd)
+			mu.Unlock()
+		},
+	})
+	defer mr.Close()
+	defer r.Close()
+	ctx := context.Background()
+
+	if ok, err := r.Set(ctx, "session:1", "user"); err != nil || !r.IsResponseOK(ok) {
+		t.Fatalf("expecting OK but got %s %v", ok, err)
+	}
+	if v, err := r.Get(ctx, "session:1"); err != nil || v != "user" {
+		t.Fatalf("expecting user but got %s %v", v, err)
+	}
+	if _, err := r.Get(ctx, "session:2"); !r.IsErrNil(err) {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	if n, err := r.SetNX(ctx, "session:1", "other", 10); err != nil || n != 0 {
+		t.Fatalf("expecting 0 of existing key but got %d %v", n, err)
+	}
+	if n, err := r.SetNX(ctx, "lock:1", "owner", 10); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d %v", n, err)
+	}
+	if ttl := mr.TTL("lock:1"); ttl != 10*time.Second {
+		t.Fatalf("expecting ttl 10s but got %s", ttl)
+	}
+	if n, err := r.IncrementBy(ctx, "counter", 5); err != nil || n != 5 {
+		t.Fatalf("expecting 5 but got %d %v", n, err)
+	}
+	if n, err := r.Increment(ctx, "counter"); err != nil || n != 6 {
+		t.Fatalf("expecting 6 but got %d %v", n, err)
+	}
+	if _, err := r.MSet(ctx, "a", "1", "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if values, err := r.MGet(ctx, "a", "missing", "b"); err != nil || !reflect.DeepEqual(values, []string{"1", "", "2"}) {
+		t.Fatalf("unexpected mget %v %v", values, err)
+	}
+	if _, err := r.HMSet(ctx, "user:1", map[string]interface{}{"name": "albert", "age": 20}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.HSetEX(ctx, "user:1", "city", "jakarta", 60); err != nil || n != 1 {
+		t.Fatalf("expecting 1 of expire but got %d %v", n, err)
+	}
+	if all, err := r.HGetAll(ctx, "user:1"); err != nil || !reflect.DeepEqual(all, map[string]string{"name": "albert", "age": "20", "city": "jakarta"}) {
+		t.Fatalf("unexpected hgetall %v %v", all, err)
+	}
+	if values, err := r.HMGet(ctx, "user:1", "name", "missing"); err != nil || !reflect.DeepEqual(values, []string{"albert", ""}) {
+		t.Fatalf("unexpected hmget %v %v", values, err)
+	}
+	if n, err := r.HDel(ctx, "user:1", "age", "missing"); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d %v", n, err)
+	}
+	if n, err := r.LPush(ctx, "queue", "c", "b", "a"); err != nil || n != 3 {
+		t.Fatalf("expecting 3 but got %d %v", n, err)
+	}
+	if n, err := r.LSet(ctx, "queue", "z", 2); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d %v", n, err)
+	}
+	if v, err := r.LIndex(ctx, "queue", 2); err != nil || v != "z" {
+		t.Fatalf("expecting z but got %s %v", v, err)
+	}
+	if _, err := r.LTrim(ctx, "queue", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.LPop(ctx, "queue"); err != nil || v != "a" {
+		t.Fatalf("expecting a but got %s %v", v, err)
+	}
+	if n, err := r.LLen(ctx, "queue"); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d %v", n, err)
+	}
+	if n, err := r.Delete(ctx, "session:1"); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d %v", n, err)
+	}
+	if n, err := r.Expire(ctx, "session:1", 10); err != nil || n != 0 {
+		t.Fatalf("expecting 0 of deleted key but got %d %v", n, err)
+	}
+	if stats := r.Stats(); stats.ActiveCount == 0 || stats.IdleCount == 0 {
+		t.Fatalf("expecting the connection in the pool but got %+v", stats)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(commands) == 0 || commands[0] != redis.CommandSet {
+		t.Fatalf("expecting the commands are recorded in uppercase but got %v", commands)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name    string
+		allowed []string
+		cmd     string
+		args    []interface{}
+		err     error
+	}{
+		{name: "flushall is blocked", cmd: "flushall", err: redis.ErrCommandBlocked},
+		{name: "keys is blocked", cmd: redis.CommandKeys, args: []interface{}{"*"}, err: redis.ErrCommandBlocked},
+		{name: "keys is allowed", allowed: []string{"keys"}, cmd: redis.CommandKeys, args: []interface{}{"*"}},
+		{name: "other command", cmd: redis.CommandGet, args: []interface{}{"session:1"}},
+		{name: "command built in script", cmd: "eval", args: []interface{}{"return redis.call('FLUSH'..'ALL')", 0}, err: redis.ErrCommandBlocked},
+		{name: "command from argument", cmd: redis.CommandEval, args: []interface{}{"return redis.call(ARGV[1])", 0, "FLUSHALL"}, err: redis.ErrCommandBlocked},
+		{name: "script load", cmd: redis.CommandScript, args: []interface{}{"LOAD", "return redis.call('FLUSHALL')"}, err: redis.ErrCommandBlocked},
+		{name: "script load of other command", cmd: redis.CommandScript, args: []interface{}{"LOAD", "return redis.call('GET', KEYS[1])"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r, mr := newTestRedis(t, Config{AllowedCommands: c.allowed})
+			defer mr.Close()
+			defer r.Close()
+			mr.Set("session:1", "user")
+
+			_, err := r.(*GoRedis).Do(ctx, c.cmd, c.args...)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if !mr.Exists("session:1") {
+				t.Fatal("expecting the key is not flushed")
+			}
+		})
+	}
+
+	r, mr := newTestRedis(t, Config{})
+	defer mr.Close()
+	defer r.Close()
+	pipe := r.(*GoRedis).Client().Pipeline()
+	pipe.Set(ctx, "a", "1", 0)
+	pipe.FlushAll(ctx)
+	if _, err := pipe.Exec(ctx); !errors.Is(err, redis.ErrCommandBlocked) {
+		t.Fatalf("expecting the pipeline is blocked but got %v", err)
+	}
+	if mr.Exists("a") {
+		t.Fatal("expecting the pipeline is not sent")
+	}
+	if _, err := r.(*GoRedis).Eval(ctx, "return redis.call('FLUSHDB')", nil); !errors.Is(err, redis.ErrCommandBlocked) {
+		t.Fatalf("expecting error %v of the script but got %v", redis.ErrCommandBlocked, err)
+	}
+}
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// list of guard error
+var (
+	ErrCommandBlocked = errors.New("redis: command is blocked")
+)
+
+// DangerousCommands are blocked by default, the commands can wipe or block a shared instance
+var DangerousCommands = []string{CommandFlushAll, CommandFlushDB, CommandKeys, CommandConfig, CommandDebug}
+
+// IsDangerousCommand return true if the command is blocked by default
+func IsDangerousCommand(cmd string) bool {
+	cmd = strings.ToUpper(cmd)
+	for _, dangerous := range DangerousCommands {
+		if cmd == dangerous {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptCallPattern match the command call in lua script, the first argument is captured when it is a string literal
+var scriptCallPattern = regexp.MustCompile(`redis\s*\.\s*p?call\s*(\(\s*(?:'([^'\\]*)'|"([^"\\]*)")\s*[,)])?`)
+
+// Guard reject the dangerous commands which are not allowed, nil guard allow every command
+// the guard is best-effort, it check the commands sent by the client and the scripts sent with EVAL and SCRIPT LOAD
+// so it is not a replacement of the redis ACL, for example EVALSHA of a script loaded by other client is not checked
+type Guard struct {
+	blocked map[string]bool
+}
+
+// NewGuard return the guard which block the dangerous commands except the allowed commands
+func NewGuard(allowed []string) *Guard {
+	g := Guard{blocked: make(map[string]bool)}
+	for _, cmd := range DangerousCommands {
+		g.blocked[cmd] = true
+	}
+	for _, cmd := range allowed {
+		delete(g.blocked, strings.ToUpper(cmd))
+	}
+	return &g
+}
+
+// Check return ErrCommandBlocked if the command is blocked
+func (g *Guard) Check(cmd string) error {
+	if g == nil || !g.blocked[strings.ToUpper(cmd)] {
+		return nil
+	}
+	return fmt.Errorf("%w: %s, allow the command in allowed_commands of the redis", ErrCommandBlocked, strings.ToUpper(cmd))
+}
+
+// CheckCommand return ErrCommandBlocked if the command is blocked, or the command send the script which call the blocked command
+// for example EVAL and SCRIPT LOAD, so the script cannot be loaded and run later with EVALSHA
+func (g *Guard) CheckCommand(cmd string, args ...interface{}) error {
+	if err := g.Check(cmd); err != nil {
+		return err
+	}
+	var script interface{}
+	switch strings.ToUpper(cmd) {
+	case CommandEval, CommandEvalRO:
+		if len(args) > 0 {
+			script = args[0]
+		}
+	case CommandScript, CommandFunction:
+		if len(args) > 1 && strings.ToUpper(fmt.Sprint(args[0])) == "LOAD" {
+			script = args[len(args)-1]
+		}
+	}
+	if script == nil {
+		return nil
+	}
+	if b, ok := script.([]byte); ok {
+		return g.CheckScript(string(b))
+	}
+	return g.CheckScript(fmt.Sprint(script))
+}
+
+// CheckScript return ErrCommandBlocked if the lua script call the blocked command, for example redis.call('FLUSHALL')
+// the command of redis.call must be a string literal, the command built in the script is blocked as it cannot be checked
+// for example redis.call('FLUSH'..'ALL') or redis.call(cmd)
+func (g *Guard) CheckScript(script string) error {
+	if g == nil || len(g.blocked) == 0 {
+		return nil
+	}
+	for _, match := range scriptCallPattern.FindAllStringSubmatch(script, -1) {
+		if match[1] == "" {
+			return fmt.Errorf("%w: command is not a string literal in script, call the command with string literal, for example redis.call('GET', KEYS[1])", ErrCommandBlocked)
+		}
+		if err := g.Check(match[2] + match[3]); err != nil {
+			return fmt.Errorf("%w: %s in script, allow the command in allowed_commands of the redis", ErrCommandBlocked, strings.ToUpper(match[2]+match[3]))
+		}
+	}
+	script = strings.ToLower(script)
+	for cmd := range g.blocked {
+		lower := strings.ToLower(cmd)
+		if strings.Contains(script, "'"+lower+"'") || strings.Contains(script, `"`+lower+`"`) {
+			return fmt.Errorf("%w: %s in script, allow the command in allowed_commands of the redis", ErrCommandBlocked, cmd)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	guard := NewGuard([]string{"keys"})
+	cases := []struct {
+		cmd  string
+		args []interface{}
+		err  error
+	}{
+		{cmd: CommandGet},
+		{cmd: CommandFlushAll, err: ErrCommandBlocked},
+		{cmd: "flushdb", err: ErrCommandBlocked},
+		{cmd: CommandConfig, err: ErrCommandBlocked},
+		{cmd: CommandKeys},
+		{cmd: CommandEval, args: []interface{}{"return redis.call('GET', KEYS[1])", 1, "a"}},
+		{cmd: CommandEval, args: []interface{}{`return redis.call("flushall")`, 0}, err: ErrCommandBlocked},
+		{cmd: CommandEval, args: []interface{}{"return redis.call('KEYS', '*')", 0}},
+		{cmd: "eval_ro", args: []interface{}{[]byte("return redis.pcall( 'FLUSHDB' )"), 0}, err: ErrCommandBlocked},
+		// the command built in the script cannot be checked, so it is blocked
+		{cmd: CommandEval, args: []interface{}{"return redis.call('FLUSH'..'ALL')", 0}, err: ErrCommandBlocked},
+		{cmd: CommandEval, args: []interface{}{"return redis.call(ARGV[1])", 0, "FLUSHALL"}, err: ErrCommandBlocked},
+		{cmd: CommandEval, args: []interface{}{"local call = redis.call; return call('GET', KEYS[1])", 1, "a"}, err: ErrCommandBlocked},
+		{cmd: CommandEval, args: []interface{}{`return redis.call('FLUSH\65LL')`, 0}, err: ErrCommandBlocked},
+		// the script is checked when it is loaded, so it cannot be run later with EVALSHA
+		{cmd: CommandScript, args: []interface{}{"load", "return redis.call('FLUSHALL')"}, err: ErrCommandBlocked},
+		{cmd: CommandScript, args: []interface{}{"LOAD", "return redis.call('GET', KEYS[1])"}},
+		{cmd: CommandFunction, args: []interface{}{"LOAD", "REPLACE", "#!lua name=lib\nredis.register_function('wipe', function() return redis.call('FLUSHDB') end)"}, err: ErrCommandBlocked},
+		{cmd: CommandScript, args: []interface{}{"FLUSH"}},
+	}
+
+	for _, c := range cases {
+		if err := guard.CheckCommand(c.cmd, c.args...); !errors.Is(err, c.err) {
+			t.Errorf("expecting error %v of %s %v but got %v", c.err, c.cmd, c.args, err)
+		}
+	}
+
+	// the guard which allow every dangerous command does not check the script
+	allowAll := NewGuard(DangerousCommands)
+	if err := allowAll.CheckCommand(CommandEval, "return redis.call(ARGV[1])", 0, "FLUSHALL"); err != nil {
+		t.Fatalf("expecting the script is allowed but got %v", err)
+	}
+
+	var nilGuard *Guard
+	if err := nilGuard.Check(CommandFlushAll); err != nil {
+		t.Fatalf("expecting nil guard to allow the command but got %v", err)
+	}
+}
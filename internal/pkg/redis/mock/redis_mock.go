@@ -6,6 +6,7 @@ package redismock
 
 import (
 	context "context"
+	redis "github.com/albertwidi/go-project-example/internal/pkg/redis"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -47,6 +48,20 @@ func (mr *MockRedisMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRedis)(nil).Close))
 }
 
+// Stats mocks base method
+func (m *MockRedis) Stats() redis.PoolStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(redis.PoolStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockRedisMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockRedis)(nil).Stats))
+}
+
 // IsErrNil mocks base method
 func (m *MockRedis) IsErrNil(err error) bool {
 	m.ctrl.T.Helper()
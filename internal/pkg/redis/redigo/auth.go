@@ -0,0 +1,157 @@
+package redigo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// list of auth error
+var (
+	ErrCertificateKeyNil = errors.New("redigo: tls cert_file and key_file must be set together")
+	ErrCAInvalid         = errors.New("redigo: failed to append ca certificate")
+	ErrURLInvalid        = errors.New("redigo: invalid redis url")
+)
+
+// TLSConfig of redis connection
+type TLSConfig struct {
+	Enabled bool
+	// CAFile is used to verify the server certificate, system pool is used when empty
+	CAFile string
+	// CertFile and KeyFile are used for mutual tls
+	CertFile string
+	KeyFile  string
+	// ServerName to verify the server certificate, default to the host of the address
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// URL of redis, formatted as redis://[username:password@]host:port or rediss:// for tls
+type URL struct {
+	Address  string
+	Username string
+	Password string
+	TLS      bool
+}
+
+// ParseURL return the address and the auth of redis url, the database of the url is not supported
+func ParseURL(rawurl string) (URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return URL{}, fmt.Errorf("%w: %v", ErrURLInvalid, err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return URL{}, fmt.Errorf("%w: scheme %q is not redis or rediss", ErrURLInvalid, u.Scheme)
+	}
+	if u.Path != "" && u.Path != "/" && u.Path != "/0" {
+		return URL{}, fmt.Errorf("%w: database %s is not supported", ErrURLInvalid, u.Path[1:])
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return URL{}, fmt.Errorf("%w: %v", ErrURLInvalid, err)
+	}
+	redisURL := URL{Address: u.Host, TLS: u.Scheme == "rediss"}
+	if u.User != nil {
+		redisURL.Username = u.User.Username()
+		redisURL.Password, _ = u.User.Password()
+	}
+	return redisURL, nil
+}
+
+// dialer create the connection to redis with the tls and the auth of the config
+type dialer struct {
+	username  string
+	password  string
+	tlsConfig *tls.Config
+	maxActive int
+	maxIdle   int
+}
+
+func newDialer(conf Config) (dialer, error) {
+	d := dialer{username: conf.Username, password: conf.Password, maxActive: conf.MaxActive, maxIdle: conf.MaxIdle}
+	if !conf.TLS.Enabled {
+		return d, nil
+	}
+	tlsConfig, err := NewTLSConfig(conf.TLS)
+	if err != nil {
+		return d, err
+	}
+	d.tlsConfig = tlsConfig
+	return d, nil
+}
+
+// NewTLSConfig return the tls config of the certificate files
+func NewTLSConfig(config TLSConfig) (*tls.Config, error) {
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, ErrCertificateKeyNil
+	}
+	tlsConfig := tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, ErrCAInvalid
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &tlsConfig, nil
+}
+
+// dial the address with tls when it is enabled, and authenticate the connection
+// AUTH with the username is the ACL user of redis 6, and AUTH with only the password is the default user
+func (d dialer) dial(address string, options ...redigo.DialOption) (redigo.Conn, error) {
+	conn, err := d.dialTLS(address, options...)
+	if err != nil {
+		return nil, err
+	}
+	if d.password == "" {
+		return conn, nil
+	}
+	if d.username != "" {
+		_, err = conn.Do("AUTH", d.username, d.password)
+	} else {
+		_, err = conn.Do("AUTH", d.password)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("redigo: failed to authenticate to %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// dialTLS dial the address with tls when it is enabled, without authentication
+func (d dialer) dialTLS(address string, options ...redigo.DialOption) (redigo.Conn, error) {
+	if d.tlsConfig != nil {
+		options = append(options, redigo.DialUseTLS(true), redigo.DialTLSConfig(d.tlsConfig))
+	}
+	return redigo.Dial("tcp", address, options...)
+}
+
+// newPool return the connection pool of the address
+func (d dialer) newPool(address string) *redigo.Pool {
+	return &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			return d.dial(address)
+		},
+		MaxActive: d.maxActive,
+		MaxIdle:   d.maxIdle,
+	}
+}
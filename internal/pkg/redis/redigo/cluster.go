@@ -0,0 +1,402 @@
+package redigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// slotCount is the number of hash slots of redis cluster
+const slotCount = 16384
+
+// default value of cluster config
+const (
+	DefaultClusterRefreshInterval = time.Minute
+	DefaultClusterMaxRedirects    = 5
+)
+
+// list of cluster error
+var (
+	ErrClusterSeedsEmpty = errors.New("redigo: cluster seed addresses are empty")
+	ErrClusterNoSlots    = errors.New("redigo: cluster has no slots")
+)
+
+// cluster route the commands to the master of the hash slot of the key
+type cluster struct {
+	seeds           []string
+	newPool         func(address string) *redigo.Pool
+	refreshInterval time.Duration
+	maxRedirects    int
+
+	mu sync.RWMutex
+	// slots is the address of the master of every slot, empty when the slot is not known
+	slots       []string
+	pools       map[string]*redigo.Pool
+	refreshedAt time.Time
+	refreshing  int32
+}
+
+// NewCluster return redis cluster connection using redigo library
+// the topology is discovered with CLUSTER SLOTS from the first seed which reply, and refreshed in background
+// every refresh interval, on MOVED redirection and when the node cannot be connected
+// the keys of multi keys command, for example MGET and EVAL, must be in the same slot, use hash tag like {user:1}
+func NewCluster(ctx context.Context, seeds []string, config *Config) (*Redigo, error) {
+	if len(seeds) == 0 {
+		return nil, ErrClusterSeedsEmpty
+	}
+	r, err := New(ctx, seeds[0], config)
+	if err != nil {
+		return nil, err
+	}
+	// the pool of the first seed is kept as the pool of its address
+	seedPool := r.pool
+	r.pool = nil
+
+	c := &cluster{
+		seeds:           append([]string(nil), seeds...),
+		newPool:         r.dialer.newPool,
+		refreshInterval: DefaultClusterRefreshInterval,
+		maxRedirects:    DefaultClusterMaxRedirects,
+		slots:           make([]string, slotCount),
+		pools:           map[string]*redigo.Pool{seeds[0]: seedPool},
+	}
+	if config != nil && config.ClusterRefreshInterval != 0 {
+		c.refreshInterval = config.ClusterRefreshInterval
+	}
+	if config != nil && config.ClusterMaxRedirects > 0 {
+		c.maxRedirects = config.ClusterMaxRedirects
+	}
+	r.cluster = c
+	if err := r.refreshCluster(ctx); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// pool return the pool of the node address, the pool is created on the first use
+func (c *cluster) pool(address string) *redigo.Pool {
+	c.mu.RLock()
+	p, ok := c.pools[address]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.pools[address]; ok {
+		return p
+	}
+	p = c.newPool(address)
+	c.pools[address] = p
+	return p
+}
+
+// address return the address of the master of the slot, or any known node when the slot is not known
+func (c *cluster) address(slot int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if slot >= 0 && c.slots[slot] != "" {
+		return c.slots[slot]
+	}
+	for _, address := range c.slots {
+		if address != "" {
+			return address
+		}
+	}
+	return c.seeds[0]
+}
+
+// nodes return the known masters and the seeds, the node of the topology is asked first
+func (c *cluster) nodes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var (
+		nodes []string
+		seen  = make(map[string]bool)
+	)
+	for _, address := range append(append([]string(nil), c.slots...), c.seeds...) {
+		if address != "" && !seen[address] {
+			seen[address] = true
+			nodes = append(nodes, address)
+		}
+	}
+	return nodes
+}
+
+// setSlots replace the topology, the pools of the nodes which are removed from the topology are closed
+func (c *cluster) setSlots(slots []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slots = slots
+	c.refreshedAt = time.Now()
+
+	used := make(map[string]bool)
+	for _, address := range slots {
+		used[address] = true
+	}
+	for _, seed := range c.seeds {
+		used[seed] = true
+	}
+	for address, p := range c.pools {
+		if !used[address] {
+			p.Close()
+			delete(c.pools, address)
+		}
+	}
+}
+
+// moved set the master of the slot from MOVED redirection, until the topology is refreshed
+func (c *cluster) moved(slot int, address string) {
+	c.mu.Lock()
+	c.slots[slot] = address
+	c.mu.Unlock()
+}
+
+// stale return true when the topology is older than the refresh interval
+func (c *cluster) stale() bool {
+	if c.refreshInterval < 0 {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.refreshedAt) > c.refreshInterval
+}
+
+// refreshCluster load the topology from the first node which reply to CLUSTER SLOTS
+func (rdg *Redigo) refreshCluster(ctx context.Context) error {
+	var errs []string
+	for _, address := range rdg.cluster.nodes() {
+		slots, err := rdg.clusterSlots(ctx, address)
+		if err == nil {
+			rdg.cluster.setSlots(slots)
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", address, err))
+	}
+	return fmt.Errorf("redigo: failed to refresh cluster topology: %s", strings.Join(errs, ", "))
+}
+
+// refreshClusterAsync refresh the topology in background, only one refresh is running at a time
+func (rdg *Redigo) refreshClusterAsync() {
+	c := rdg.cluster
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+	safego.Go(context.Background(), "redis/cluster/refresh", func(ctx context.Context) error {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		return rdg.refreshCluster(ctx)
+	})
+}
+
+// clusterSlots return the master address of every slot from CLUSTER SLOTS of the node
+// the reply is a list of [start, end, [ip, port, id], replicas...], the empty ip is the ip of the node itself
+func (rdg *Redigo) clusterSlots(ctx context.Context, address string) ([]string, error) {
+	conn, err := rdg.getConn(ctx, rdg.cluster.pool(address))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ranges, err := redigo.Values(rdg.doConn(ctx, conn, redis.CommandCluster, "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, ErrClusterNoSlots
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]string, slotCount)
+	for _, r := range ranges {
+		values, err := redigo.Values(r, nil)
+		if err != nil || len(values) < 3 {
+			return nil, fmt.Errorf("redigo: invalid cluster slots reply: %v", r)
+		}
+		start, err := redigo.Int(values[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		end, err := redigo.Int(values[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		master, err := redigo.Values(values[2], nil)
+		if err != nil || len(master) < 2 {
+			return nil, fmt.Errorf("redigo: invalid cluster slots node: %v", values[2])
+		}
+		ip, err := redigo.String(master[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		port, err := redigo.Int(master[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		if ip == "" {
+			ip = host
+		}
+		if start < 0 || end >= slotCount || start > end {
+			return nil, fmt.Errorf("redigo: invalid cluster slots range %d-%d", start, end)
+		}
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = net.JoinHostPort(ip, strconv.Itoa(port))
+		}
+	}
+	return slots, nil
+}
+
+// doCluster run the command in the master of the slot of the key, and follow the MOVED and ASK redirections
+func (rdg *Redigo) doCluster(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	c := rdg.cluster
+	if c.stale() {
+		rdg.refreshClusterAsync()
+	}
+	slot := -1
+	if key, ok := commandKey(cmd, args); ok {
+		slot = keySlot(key)
+	}
+
+	address, asking := c.address(slot), false
+	for redirects := 0; ; redirects++ {
+		resp, err := rdg.doNode(ctx, address, asking, cmd, args...)
+		if isDialError(err) {
+			rdg.refreshClusterAsync()
+			return resp, err
+		}
+		redirect, ok := parseRedirect(err)
+		if !ok || redirects >= c.maxRedirects {
+			return resp, err
+		}
+		if redirect.moved {
+			c.moved(redirect.slot, redirect.address)
+			rdg.refreshClusterAsync()
+		}
+		address, asking = redirect.address, !redirect.moved
+	}
+}
+
+// doNode run the command in the node, ASKING is sent before the command of ASK redirection
+func (rdg *Redigo) doNode(ctx context.Context, address string, asking bool, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := rdg.getConn(ctx, rdg.cluster.pool(address))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if asking {
+		if _, err := conn.Do(redis.CommandAsking); err != nil {
+			return nil, err
+		}
+	}
+	return rdg.doConn(ctx, conn, cmd, args...)
+}
+
+// redirect of MOVED or ASK error, for example MOVED 3999 127.0.0.1:6381
+type redirect struct {
+	moved   bool
+	slot    int
+	address string
+}
+
+func parseRedirect(err error) (redirect, bool) {
+	var redisErr redigo.Error
+	if !errors.As(err, &redisErr) {
+		return redirect{}, false
+	}
+	fields := strings.Fields(string(redisErr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return redirect{}, false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= slotCount {
+		return redirect{}, false
+	}
+	return redirect{moved: fields[0] == "MOVED", slot: slot, address: fields[2]}, true
+}
+
+// commandKey return the key which the command is routed by, the first key of EVAL or the first argument of other commands
+func commandKey(cmd string, args []interface{}) (string, bool) {
+	switch strings.ToUpper(cmd) {
+	case redis.CommandPing, redis.CommandCluster:
+		return "", false
+	case redis.CommandEval, redis.CommandEvalRO, redis.CommandEvalSha:
+		if len(args) < 3 {
+			return "", false
+		}
+		if numKeys, ok := argInt(args[1]); !ok || numKeys == 0 {
+			return "", false
+		}
+		return argString(args[2])
+	}
+	if len(args) == 0 {
+		return "", false
+	}
+	return argString(args[0])
+}
+
+// argInt return the integer of the argument, for example numkeys of EVAL which is passed as int by Eval
+func argInt(arg interface{}) (int64, bool) {
+	switch arg := arg.(type) {
+	case int:
+		return int64(arg), true
+	case int64:
+		return arg, true
+	case string:
+		i, err := strconv.ParseInt(arg, 10, 64)
+		return i, err == nil
+	case []byte:
+		i, err := strconv.ParseInt(string(arg), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func argString(arg interface{}) (string, bool) {
+	switch arg := arg.(type) {
+	case string:
+		return arg, true
+	case []byte:
+		return string(arg), true
+	}
+	return "", false
+}
+
+// keySlot return the hash slot of the key, only the hash tag between the first { and the next } is hashed when it is not empty
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % slotCount
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum which is used by redis cluster
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
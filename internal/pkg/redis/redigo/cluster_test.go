@@ -0,0 +1,350 @@
+package redigo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// fakeCluster is the topology of the fake nodes, every node reply the same CLUSTER SLOTS
+type fakeCluster struct {
+	mu    sync.Mutex
+	nodes []*fakeNode
+	// owner is the index of the node which own the slot
+	owner map[int]int
+	// migrating is the index of the node which the slot is migrated to, the missing key is redirected with ASK
+	migrating map[int]int
+}
+
+type fakeNode struct {
+	cluster *fakeCluster
+	index   int
+	srv     *server.Server
+	addr    string
+	data    map[string]string
+	// evals is the number of EVAL which is received by the node, including the redirected
+	evals int
+}
+
+func newFakeCluster(t *testing.T, nodes int) *fakeCluster {
+	t.Helper()
+	c := &fakeCluster{owner: make(map[int]int), migrating: make(map[int]int)}
+	for i := 0; i < nodes; i++ {
+		srv, err := server.NewServer("127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := &fakeNode{cluster: c, index: i, srv: srv, addr: srv.Addr().String(), data: make(map[string]string)}
+		srv.Register("CLUSTER", node.clusterSlots)
+		srv.Register("ASKING", func(peer *server.Peer, cmd string, args []string) {
+			peer.Ctx = true
+			peer.WriteOK()
+		})
+		srv.Register("GET", node.get)
+		srv.Register("SET", node.set)
+		srv.Register("EVAL", node.eval)
+		srv.Register("PING", func(peer *server.Peer, cmd string, args []string) { peer.WriteInline("PONG") })
+		c.nodes = append(c.nodes, node)
+	}
+	// the slots are split evenly between the nodes
+	for slot := 0; slot < slotCount; slot++ {
+		c.owner[slot] = slot * nodes / slotCount
+	}
+	return c
+}
+
+func (c *fakeCluster) close() {
+	for _, node := range c.nodes {
+		node.srv.Close()
+	}
+}
+
+func (c *fakeCluster) setOwner(slot, index int) {
+	c.mu.Lock()
+	c.owner[slot] = index
+	c.mu.Unlock()
+}
+
+func (c *fakeCluster) setMigrating(slot, index int) {
+	c.mu.Lock()
+	c.migrating[slot] = index
+	c.mu.Unlock()
+}
+
+func (n *fakeNode) clusterSlots(peer *server.Peer, cmd string, args []string) {
+	n.cluster.mu.Lock()
+	defer n.cluster.mu.Unlock()
+	type slotRange struct{ start, end, owner int }
+	var ranges []slotRange
+	for slot := 0; slot < slotCount; slot++ {
+		owner := n.cluster.owner[slot]
+		if len(ranges) > 0 && ranges[len(ranges)-1].owner == owner && ranges[len(ranges)-1].end == slot-1 {
+			ranges[len(ranges)-1].end = slot
+			continue
+		}
+		ranges = append(ranges, slotRange{start: slot, end: slot, owner: owner})
+	}
+	peer.WriteLen(len(ranges))
+	for _, r := range ranges {
+		host, port, _ := net.SplitHostPort(n.cluster.nodes[r.owner].addr)
+		p, _ := strconv.Atoi(port)
+		peer.WriteLen(3)
+		peer.WriteInt(r.start)
+		peer.WriteInt(r.end)
+		peer.WriteLen(3)
+		peer.WriteBulk(host)
+		peer.WriteInt(p)
+		peer.WriteBulk("node-" + strconv.Itoa(r.owner))
+	}
+}
+
+// route return false and write the redirection when the key is not served by the node
+func (n *fakeNode) route(peer *server.Peer, key string) bool {
+	asking, _ := peer.Ctx.(bool)
+	peer.Ctx = nil
+	slot := keySlot(key)
+
+	n.cluster.mu.Lock()
+	defer n.cluster.mu.Unlock()
+	owner := n.cluster.owner[slot]
+	target, migrating := n.cluster.migrating[slot]
+	switch {
+	case owner == n.index:
+		if _, ok := n.data[key]; !ok && migrating {
+			peer.WriteError("ASK " + strconv.Itoa(slot) + " " + n.cluster.nodes[target].addr)
+			return false
+		}
+		return true
+	case migrating && target == n.index && asking:
+		return true
+	}
+	peer.WriteError("MOVED " + strconv.Itoa(slot) + " " + n.cluster.nodes[owner].addr)
+	return false
+}
+
+func (n *fakeNode) get(peer *server.Peer, cmd string, args []string) {
+	if !n.route(peer, args[0]) {
+		return
+	}
+	n.cluster.mu.Lock()
+	value, ok := n.data[args[0]]
+	n.cluster.mu.Unlock()
+	if !ok {
+		peer.WriteNull()
+		return
+	}
+	peer.WriteBulk(value)
+}
+
+func (n *fakeNode) set(peer *server.Peer, cmd string, args []string) {
+	if !n.route(peer, args[0]) {
+		return
+	}
+	n.cluster.mu.Lock()
+	n.data[args[0]] = args[1]
+	n.cluster.mu.Unlock()
+	peer.WriteOK()
+}
+
+// eval reply the value of the first key, the script is not run
+func (n *fakeNode) eval(peer *server.Peer, cmd string, args []string) {
+	n.cluster.mu.Lock()
+	n.evals++
+	n.cluster.mu.Unlock()
+	if len(args) < 3 || args[1] == "0" {
+		peer.WriteNull()
+		return
+	}
+	n.get(peer, cmd, args[2:])
+}
+
+func TestKeySlot(t *testing.T) {
+	cases := []struct {
+		key  string
+		slot int
+	}{
+		{"123456789", 12739},
+		{"foo", 12182},
+		{"bar", 5061},
+		{"{user1000}.following", keySlot("user1000")},
+		{"{user1000}.followers", keySlot("user1000")},
+		// empty hash tag hash the whole key
+		{"foo{}{bar}", int(crc16("foo{}{bar}")) % slotCount},
+		{"foo{{bar}}zap", keySlot("{bar")},
+	}
+
+	for _, c := range cases {
+		if slot := keySlot(c.key); slot != c.slot {
+			t.Errorf("expecting slot %d of %s but got %d", c.slot, c.key, slot)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	fc := newFakeCluster(t, 2)
+	defer fc.close()
+	ctx := context.Background()
+
+	// the second node is only discovered from the topology
+	rdg, err := NewCluster(ctx, []string{"127.0.0.1:1", fc.nodes[0].addr}, &Config{ClusterRefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+
+	// bar is in the first half of the slots and foo is in the second half
+	for _, key := range []string{"foo", "bar"} {
+		if _, err := rdg.Set(ctx, key, "value-"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fc.nodes[0].data["bar"] != "value-bar" || fc.nodes[1].data["foo"] != "value-foo" {
+		t.Fatalf("expecting the keys in the master of the slot but got %v and %v", fc.nodes[0].data, fc.nodes[1].data)
+	}
+	if _, err := rdg.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// foo is moved to the first node
+	fooSlot := keySlot("foo")
+	fc.setOwner(fooSlot, 0)
+	fc.nodes[0].data["foo"] = "moved"
+	value, err := rdg.Get(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "moved" {
+		t.Fatalf("expecting value from the new master but got %s", value)
+	}
+	if address := rdg.cluster.address(fooSlot); address != fc.nodes[0].addr {
+		t.Fatalf("expecting the slot to be moved to %s but got %s", fc.nodes[0].addr, address)
+	}
+
+	// bar is migrating to the second node, the missing key is asked to the second node
+	barSlot := keySlot("bar")
+	fc.setMigrating(barSlot, 1)
+	if _, err := rdg.Set(ctx, "{bar}.new", "migrated"); err != nil {
+		t.Fatal(err)
+	}
+	if fc.nodes[1].data["{bar}.new"] != "migrated" {
+		t.Fatalf("expecting the key in the importing node but got %v", fc.nodes[1].data)
+	}
+	// ASK is not a topology change
+	if address := rdg.cluster.address(barSlot); address != fc.nodes[0].addr {
+		t.Fatalf("expecting the slot to stay in %s but got %s", fc.nodes[0].addr, address)
+	}
+}
+
+func TestClusterRefresh(t *testing.T) {
+	fc := newFakeCluster(t, 2)
+	defer fc.close()
+	ctx := context.Background()
+
+	rdg, err := NewCluster(ctx, []string{fc.nodes[0].addr}, &Config{ClusterRefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	// foo is in the second node
+	if _, err := rdg.Set(ctx, "foo", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	// every slot is moved to the first node
+	for slot := 0; slot < slotCount; slot++ {
+		fc.setOwner(slot, 0)
+	}
+	if err := rdg.refreshCluster(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rdg.cluster.mu.RLock()
+	_, ok := rdg.cluster.pools[fc.nodes[1].addr]
+	rdg.cluster.mu.RUnlock()
+	if ok {
+		t.Fatal("expecting the pool of the removed node to be closed")
+	}
+	if address := rdg.cluster.address(keySlot("foo")); address != fc.nodes[0].addr {
+		t.Fatalf("expecting foo in %s but got %s", fc.nodes[0].addr, address)
+	}
+}
+
+func TestClusterEval(t *testing.T) {
+	fc := newFakeCluster(t, 2)
+	defer fc.close()
+	ctx := context.Background()
+
+	rdg, err := NewCluster(ctx, []string{fc.nodes[0].addr}, &Config{ClusterRefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	// foo is in the second node
+	fc.nodes[1].data["foo"] = "value"
+	value, err := rdg.Eval(ctx, "return redis.call('GET', KEYS[1])", []string{"foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := value.([]byte); string(v) != "value" {
+		t.Fatalf("expecting value from the owner of the slot but got %v", value)
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.nodes[0].evals != 0 || fc.nodes[1].evals != 1 {
+		t.Fatalf("expecting the eval is sent to the owner on the first try but got %d and %d", fc.nodes[0].evals, fc.nodes[1].evals)
+	}
+}
+
+func TestClusterStats(t *testing.T) {
+	fc := newFakeCluster(t, 2)
+	defer fc.close()
+	ctx := context.Background()
+
+	rdg, err := NewCluster(ctx, []string{fc.nodes[0].addr}, &Config{ClusterRefreshInterval: -1, MaxIdle: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	// bar is in the first node and foo is in the second node
+	for _, key := range []string{"foo", "bar"} {
+		if _, err := rdg.Set(ctx, key, "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, node := range fc.nodes {
+		if stats := rdg.cluster.pool(node.addr).Stats(); stats.ActiveCount != 1 || stats.IdleCount != 1 {
+			t.Fatalf("expecting one idle connection of %s but got %+v", node.addr, stats)
+		}
+	}
+	expect := redis.PoolStats{ActiveCount: 2, IdleCount: 2}
+	if stats := rdg.Stats(); stats != expect {
+		t.Fatalf("expecting the sum of the nodes %+v but got %+v", expect, stats)
+	}
+
+	// the connection in use is active but not idle
+	conn := rdg.cluster.pool(fc.nodes[1].addr).Get()
+	if _, err := conn.Do(redis.CommandPing); err != nil {
+		t.Fatal(err)
+	}
+	expect.IdleCount--
+	if stats := rdg.Stats(); stats != expect {
+		t.Fatalf("expecting %+v while the connection is in use but got %+v", expect, stats)
+	}
+	conn.Close()
+}
+
+func TestNewClusterError(t *testing.T) {
+	if _, err := NewCluster(context.Background(), nil, nil); !errors.Is(err, ErrClusterSeedsEmpty) {
+		t.Fatalf("expecting %v but got %v", ErrClusterSeedsEmpty, err)
+	}
+	_, err := NewCluster(context.Background(), []string{"127.0.0.1:1"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to refresh cluster topology") {
+		t.Fatalf("expecting refresh error but got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package redigo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestGuard(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		allowed []string
+		cmd     string
+		args    []interface{}
+		err     error
+	}{
+		{name: "flushall is blocked", cmd: "flushall", err: redis.ErrCommandBlocked},
+		{name: "keys is blocked", cmd: redis.CommandKeys, args: []interface{}{"*"}, err: redis.ErrCommandBlocked},
+		{name: "keys is allowed", allowed: []string{"keys"}, cmd: redis.CommandKeys, args: []interface{}{"*"}},
+		{name: "flushall is allowed", allowed: []string{redis.CommandFlushAll}, cmd: redis.CommandFlushAll},
+		{name: "other command", cmd: redis.CommandGet, args: []interface{}{"session:1"}},
+		{name: "command built in script", cmd: "eval", args: []interface{}{"return redis.call('FLUSH'..'ALL')", 0}, err: redis.ErrCommandBlocked},
+		{name: "command from argument", cmd: redis.CommandEval, args: []interface{}{"return redis.call(ARGV[1])", 0, "FLUSHALL"}, err: redis.ErrCommandBlocked},
+		{name: "script load", cmd: redis.CommandScript, args: []interface{}{"LOAD", "return redis.call('FLUSHALL')"}, err: redis.ErrCommandBlocked},
+		{name: "script load of other command", cmd: redis.CommandScript, args: []interface{}{"LOAD", "return redis.call('GET', KEYS[1])"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			mr.Set("session:1", "user")
+			rdg, err := New(ctx, mr.Addr(), &Config{AllowedCommands: c.allowed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rdg.Close()
+
+			_, err = rdg.Do(ctx, c.cmd, c.args...)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if c.err != nil && !mr.Exists("session:1") {
+				t.Fatal("expecting the key is not flushed by the blocked command")
+			}
+		})
+	}
+
+	rdg, err := New(ctx, mr.Addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	if _, err := rdg.Eval(ctx, "return redis.call('FLUSHDB')", nil); !errors.Is(err, redis.ErrCommandBlocked) {
+		t.Fatalf("expecting error %v of the script but got %v", redis.ErrCommandBlocked, err)
+	}
+}
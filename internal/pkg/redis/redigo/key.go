@@ -16,14 +16,21 @@ func (rdg *Redigo) Set(ctx context.Context, key string, value interface{}) (stri
 	return ok, err
 }
 
-// SetNX do SETNX (only set if not exist) with SET's NX & EX args.
+// SetNX do SET with NX & EX args (only set if not exist), return 1 if key is set and 0 if key already exists.
 // It sets the key which will expired in `expire` seconds
 func (rdg *Redigo) SetNX(ctx context.Context, key string, value interface{}, expire int) (int, error) {
-	resp, err := redigo.Int(rdg.do(ctx, "SETNX", key, value, redis.CommandSetNX, redis.CommandExpire, expire))
-	if err != nil && !rdg.IsErrNil(err) {
+	ok, err := redigo.String(rdg.do(ctx, redis.CommandSet, key, value, "NX", "EX", expire))
+	if err != nil {
+		if rdg.IsErrNil(err) {
+			// key is already exists
+			return 0, nil
+		}
 		return 0, err
 	}
-	return resp, err
+	if !rdg.IsResponseOK(ok) {
+		return 0, redis.ErrResponseNotOK
+	}
+	return 1, nil
 }
 
 // SetEX key and value
@@ -116,13 +123,7 @@ func (rdg *Redigo) HSet(ctx context.Context, key, field string, value interface{
 
 // HSetEX key and value and sets the expiration to the given `expire` seconds
 func (rdg *Redigo) HSetEX(ctx context.Context, key, field string, value interface{}, expire int) (int, error) {
-	conn, err := rdg.getConn(ctx)
-	if err != nil {
-		return 0, err
-	}
-	defer conn.Close()
-
-	resp, err := redigo.Int(conn.Do(redis.CommandHSet, key, field, value))
+	resp, err := redigo.Int(rdg.do(ctx, redis.CommandHSet, key, field, value))
 	if err != nil && !rdg.IsErrNil(err) {
 		return resp, err
 	}
@@ -0,0 +1,145 @@
+package redigo
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// batchSize is the number of keys in a single batch benchmark
+const batchSize = 100
+
+func newBenchRedis(b *testing.B) (*Redigo, func()) {
+	b.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		b.Fatal(err)
+	}
+	rdg, err := New(context.Background(), mr.Addr(), &Config{MaxIdle: 16})
+	if err != nil {
+		mr.Close()
+		b.Fatal(err)
+	}
+
+	keys := make([]interface{}, 0, batchSize*2)
+	for i := 0; i < batchSize; i++ {
+		keys = append(keys, "bench:"+strconv.Itoa(i), "value")
+	}
+	if _, err := rdg.MSet(context.Background(), keys...); err != nil {
+		b.Fatal(err)
+	}
+	return rdg, func() {
+		rdg.Close()
+		mr.Close()
+	}
+}
+
+func benchKeys() []string {
+	keys := make([]string, batchSize)
+	for i := range keys {
+		keys[i] = "bench:" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func BenchmarkGet(b *testing.B) {
+	rdg, closeFn := newBenchRedis(b)
+	defer closeFn()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rdg.Get(ctx, "bench:0"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	rdg, closeFn := newBenchRedis(b)
+	defer closeFn()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := rdg.Get(ctx, "bench:0"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkBatchSequential get the batch one round trip per key, the baseline of batch benchmarks
+func BenchmarkBatchSequential(b *testing.B) {
+	rdg, closeFn := newBenchRedis(b)
+	defer closeFn()
+	var (
+		ctx  = context.Background()
+		keys = benchKeys()
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			if _, err := rdg.Get(ctx, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchMGet(b *testing.B) {
+	rdg, closeFn := newBenchRedis(b)
+	defer closeFn()
+	var (
+		ctx  = context.Background()
+		keys = benchKeys()
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rdg.MGet(ctx, keys...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBatchPipeline send all commands before reading the replies in a single connection
+func BenchmarkBatchPipeline(b *testing.B) {
+	rdg, closeFn := newBenchRedis(b)
+	defer closeFn()
+	var (
+		ctx  = context.Background()
+		keys = benchKeys()
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := rdg.getConn(ctx, rdg.pool)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, key := range keys {
+			if err := conn.Send("GET", key); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			b.Fatal(err)
+		}
+		for range keys {
+			if _, err := conn.Receive(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,51 @@
+package redigo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestStats(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	ctx := context.Background()
+
+	rdg, err := New(ctx, mr.Addr(), &Config{MaxIdle: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	if stats := rdg.Stats(); stats != (redis.PoolStats{}) {
+		t.Fatalf("expecting no connection before the first command but got %+v", stats)
+	}
+	if _, err := rdg.Set(ctx, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if stats, expect := rdg.Stats(), (redis.PoolStats{ActiveCount: 1, IdleCount: 1}); stats != expect {
+		t.Fatalf("expecting %+v after the command but got %+v", expect, stats)
+	}
+
+	// the idle connection is reused and the second connection is dialed
+	first := rdg.pool.Get()
+	second := rdg.pool.Get()
+	if _, err := second.Do(redis.CommandPing); err != nil {
+		t.Fatal(err)
+	}
+	if stats, expect := rdg.Stats(), (redis.PoolStats{ActiveCount: 2}); stats != expect {
+		t.Fatalf("expecting %+v while the connections are in use but got %+v", expect, stats)
+	}
+	first.Close()
+	if stats, expect := rdg.Stats(), (redis.PoolStats{ActiveCount: 2, IdleCount: 1}); stats != expect {
+		t.Fatalf("expecting %+v after the connection is returned but got %+v", expect, stats)
+	}
+	second.Close()
+	if stats, expect := rdg.Stats(), (redis.PoolStats{ActiveCount: 2, IdleCount: 2}); stats != expect {
+		t.Fatalf("expecting %+v after all connections are returned but got %+v", expect, stats)
+	}
+}
@@ -0,0 +1,223 @@
+package redigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// default value of sentinel config
+const (
+	DefaultSentinelRefreshInterval = time.Second * 10
+	DefaultSentinelDialTimeout     = time.Second
+)
+
+// list of sentinel error
+var (
+	ErrSentinelsEmpty         = errors.New("redigo: sentinel addresses are empty")
+	ErrSentinelMasterEmpty    = errors.New("redigo: sentinel master name is empty")
+	ErrSentinelMasterNotFound = errors.New("redigo: sentinel master is not found")
+)
+
+// sentinel connect to the current master of the sentinel master name
+type sentinel struct {
+	masterName      string
+	sentinels       []string
+	dialer          dialer
+	newPool         func(address string) *redigo.Pool
+	refreshInterval time.Duration
+
+	mu         sync.RWMutex
+	master     string
+	pool       *redigo.Pool
+	resolvedAt time.Time
+	resolving  int32
+}
+
+// NewSentinel return redis connection to the master which is discovered from the sentinels
+// the master is asked to the first sentinel which reply, and asked again in background every refresh interval,
+// when the master cannot be connected and when the master reply READONLY because it is demoted to replica.
+// the pool of the old master is closed on failover, so the next command dial the new master
+func NewSentinel(ctx context.Context, masterName string, sentinels []string, config *Config) (*Redigo, error) {
+	if masterName == "" {
+		return nil, ErrSentinelMasterEmpty
+	}
+	if len(sentinels) == 0 {
+		return nil, ErrSentinelsEmpty
+	}
+	conf := Config{}
+	if config != nil {
+		conf = *config
+	}
+	d, err := newDialer(conf)
+	if err != nil {
+		return nil, err
+	}
+	s := &sentinel{
+		masterName:      masterName,
+		sentinels:       append([]string(nil), sentinels...),
+		dialer:          d,
+		newPool:         d.newPool,
+		refreshInterval: DefaultSentinelRefreshInterval,
+	}
+	if conf.SentinelRefreshInterval != 0 {
+		s.refreshInterval = conf.SentinelRefreshInterval
+	}
+	master, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := New(ctx, master, config)
+	if err != nil {
+		return nil, err
+	}
+	// the pool of the master is owned by the sentinel, so it can be replaced on failover
+	s.setMaster(master, r.pool)
+	r.pool = nil
+	r.sentinel = s
+	return r, nil
+}
+
+// current return the pool of the current master
+func (s *sentinel) current() *redigo.Pool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pool
+}
+
+// address return the address of the current master
+func (s *sentinel) address() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.master
+}
+
+// setMaster replace the master when the address is changed and close the pool of the old master
+// the new pool is created when pool is nil, and the given pool is closed when the master is not changed
+func (s *sentinel) setMaster(master string, pool *redigo.Pool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolvedAt = time.Now()
+	if master == s.master && s.pool != nil {
+		if pool != nil {
+			pool.Close()
+		}
+		return false
+	}
+	if pool == nil {
+		pool = s.newPool(master)
+	}
+	if s.pool != nil {
+		s.pool.Close()
+	}
+	s.master, s.pool = master, pool
+	return true
+}
+
+// stale return true when the master is resolved longer than the refresh interval
+func (s *sentinel) stale() bool {
+	if s.refreshInterval < 0 {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.resolvedAt) > s.refreshInterval
+}
+
+// resolve return the address of the master from the first sentinel which know the master
+func (s *sentinel) resolve(ctx context.Context) (string, error) {
+	var errs []string
+	for _, address := range s.sentinels {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		master, err := s.masterAddr(address)
+		if err == nil {
+			return master, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", address, err))
+	}
+	return "", fmt.Errorf("redigo: failed to resolve sentinel master %s: %s", s.masterName, strings.Join(errs, ", "))
+}
+
+// masterAddr ask the sentinel with SENTINEL get-master-addr-by-name, the reply is [ip, port] or nil when the master is unknown
+func (s *sentinel) masterAddr(address string) (string, error) {
+	conn, err := s.dialer.dialTLS(address,
+		redigo.DialConnectTimeout(DefaultSentinelDialTimeout),
+		redigo.DialReadTimeout(DefaultSentinelDialTimeout),
+		redigo.DialWriteTimeout(DefaultSentinelDialTimeout),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	reply, err := redigo.Strings(conn.Do(redis.CommandSentinel, "get-master-addr-by-name", s.masterName))
+	if errors.Is(err, redigo.ErrNil) {
+		return "", ErrSentinelMasterNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(reply) != 2 {
+		return "", fmt.Errorf("redigo: invalid sentinel master reply: %v", reply)
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
+
+// refreshSentinel resolve the master and switch to the new master on failover
+func (rdg *Redigo) refreshSentinel(ctx context.Context) (bool, error) {
+	master, err := rdg.sentinel.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	return rdg.sentinel.setMaster(master, nil), nil
+}
+
+// refreshSentinelAsync resolve the master in background, only one refresh is running at a time
+func (rdg *Redigo) refreshSentinelAsync() {
+	s := rdg.sentinel
+	if !atomic.CompareAndSwapInt32(&s.resolving, 0, 1) {
+		return
+	}
+	safego.Go(context.Background(), "redis/sentinel/refresh", func(ctx context.Context) error {
+		defer atomic.StoreInt32(&s.resolving, 0)
+		_, err := rdg.refreshSentinel(ctx)
+		return err
+	})
+}
+
+// doSentinel run the command in the current master
+// the command which is rejected with READONLY is retried once in the new master after failover
+func (rdg *Redigo) doSentinel(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	s := rdg.sentinel
+	if s.stale() {
+		rdg.refreshSentinelAsync()
+	}
+	resp, err := rdg.doPool(ctx, s.current(), cmd, args...)
+	switch {
+	case isDialError(err):
+		rdg.refreshSentinelAsync()
+	case isReadOnlyError(err):
+		if changed, rerr := rdg.refreshSentinel(ctx); rerr == nil && changed {
+			return rdg.doPool(ctx, s.current(), cmd, args...)
+		}
+	}
+	return resp, err
+}
+
+// isReadOnlyError return true when the command is sent to a replica, for example the old master after failover
+func isReadOnlyError(err error) bool {
+	var redisErr redigo.Error
+	return errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "READONLY")
+}
@@ -0,0 +1,222 @@
+package redigo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// fakeSentinel reply the address of the master of mymaster
+type fakeSentinel struct {
+	srv *server.Server
+
+	mu     sync.Mutex
+	master string
+}
+
+func newFakeSentinel(t *testing.T, master string) *fakeSentinel {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSentinel{srv: srv, master: master}
+	srv.Register("SENTINEL", func(peer *server.Peer, cmd string, args []string) {
+		if len(args) != 2 || args[1] != "mymaster" {
+			peer.WriteNull()
+			return
+		}
+		s.mu.Lock()
+		host, port, _ := net.SplitHostPort(s.master)
+		s.mu.Unlock()
+		peer.WriteLen(2)
+		peer.WriteBulk(host)
+		peer.WriteBulk(port)
+	})
+	return s
+}
+
+func (s *fakeSentinel) addr() string {
+	return s.srv.Addr().String()
+}
+
+func (s *fakeSentinel) failover(master string) {
+	s.mu.Lock()
+	s.master = master
+	s.mu.Unlock()
+}
+
+func TestSentinelFailover(t *testing.T) {
+	oldMaster, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer oldMaster.Close()
+	newMaster, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newMaster.Close()
+	s := newFakeSentinel(t, oldMaster.Addr())
+	defer s.srv.Close()
+	ctx := context.Background()
+
+	// the first sentinel is down
+	rdg, err := NewSentinel(ctx, "mymaster", []string{"127.0.0.1:1", s.addr()}, &Config{SentinelRefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	if _, err := rdg.Set(ctx, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := oldMaster.Get("foo"); value != "bar" {
+		t.Fatalf("expecting the key in the old master but got %q", value)
+	}
+
+	// the master is dead, the dial error trigger the refresh in background
+	s.failover(newMaster.Addr())
+	oldMaster.Close()
+	if _, err := rdg.Set(ctx, "foo", "baz"); err == nil {
+		t.Fatal("expecting error of the dead master")
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for rdg.sentinel.address() != newMaster.Addr() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expecting the master to be %s but got %s", newMaster.Addr(), rdg.sentinel.address())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if _, err := rdg.Set(ctx, "foo", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := newMaster.Get("foo"); value != "baz" {
+		t.Fatalf("expecting the key in the new master but got %q", value)
+	}
+}
+
+func TestSentinelReadOnly(t *testing.T) {
+	// the old master is demoted to replica and reject the write
+	replica, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	replica.Register("SET", func(peer *server.Peer, cmd string, args []string) {
+		peer.WriteError("READONLY You can't write against a read only replica.")
+	})
+	newMaster, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newMaster.Close()
+	s := newFakeSentinel(t, replica.Addr().String())
+	defer s.srv.Close()
+	ctx := context.Background()
+
+	rdg, err := NewSentinel(ctx, "mymaster", []string{s.addr()}, &Config{SentinelRefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+
+	// the write is retried in the new master
+	s.failover(newMaster.Addr())
+	if _, err := rdg.Set(ctx, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := newMaster.Get("foo"); value != "bar" {
+		t.Fatalf("expecting the key in the new master but got %q", value)
+	}
+
+	// READONLY is returned when the sentinel doesn't know the new master yet
+	s.failover(replica.Addr().String())
+	if _, err := rdg.refreshSentinel(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rdg.do(ctx, redis.CommandSet, "foo", "bar"); !isReadOnlyError(err) {
+		t.Fatalf("expecting READONLY error but got %v", err)
+	}
+}
+
+func TestSentinelStats(t *testing.T) {
+	oldMaster, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer oldMaster.Close()
+	newMaster, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newMaster.Close()
+	s := newFakeSentinel(t, oldMaster.Addr())
+	defer s.srv.Close()
+	ctx := context.Background()
+
+	rdg, err := NewSentinel(ctx, "mymaster", []string{s.addr()}, &Config{SentinelRefreshInterval: -1, MaxIdle: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdg.Close()
+	if _, err := rdg.Set(ctx, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if stats, expect := rdg.Stats(), (redis.PoolStats{ActiveCount: 1, IdleCount: 1}); stats != expect {
+		t.Fatalf("expecting %+v of the master but got %+v", expect, stats)
+	}
+
+	// the pool of the old master is closed on failover, only the connections of the new master are counted
+	conn := rdg.sentinel.current().Get()
+	if _, err := conn.Do(redis.CommandPing); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s.failover(newMaster.Addr())
+	if _, err := rdg.refreshSentinel(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := rdg.Stats(); stats != (redis.PoolStats{}) {
+		t.Fatalf("expecting no connection of the new master but got %+v", stats)
+	}
+	if _, err := rdg.Set(ctx, "foo", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if stats, expect := rdg.Stats(), (redis.PoolStats{ActiveCount: 1, IdleCount: 1}); stats != expect {
+		t.Fatalf("expecting %+v of the new master but got %+v", expect, stats)
+	}
+}
+
+func TestNewSentinelError(t *testing.T) {
+	s := newFakeSentinel(t, "127.0.0.1:6379")
+	defer s.srv.Close()
+
+	cases := []struct {
+		masterName string
+		sentinels  []string
+		err        error
+		message    string
+	}{
+		{masterName: "", sentinels: []string{s.addr()}, err: ErrSentinelMasterEmpty},
+		{masterName: "mymaster", err: ErrSentinelsEmpty},
+		{masterName: "unknown", sentinels: []string{s.addr()}, message: ErrSentinelMasterNotFound.Error()},
+	}
+
+	for _, c := range cases {
+		_, err := NewSentinel(context.Background(), c.masterName, c.sentinels, nil)
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("expecting %v but got %v", c.err, err)
+		}
+		if c.message != "" && (err == nil || !strings.Contains(err.Error(), c.message)) {
+			t.Errorf("expecting error contains %q but got %v", c.message, err)
+		}
+	}
+}
@@ -15,6 +15,7 @@ var (
 // Redis interface
 type Redis interface {
 	Close() error
+	Stats() PoolStats
 	IsErrNil(err error) bool
 	IsResponseOK(result string) bool
 	Set(ctx context.Context, key string, value interface{}) (string, error)
@@ -44,6 +45,15 @@ type Redis interface {
 	LTrim(ctd context.Context, key string, start, stop int) (string, error)
 }
 
+// PoolStats of redis connection pool
+type PoolStats struct {
+	// ActiveCount is the number of connections in the pool
+	// the count includes idle connections and connections in use
+	ActiveCount int `json:"active_count"`
+	// IdleCount is the number of idle connections in the pool
+	IdleCount int `json:"idle_count"`
+}
+
 // list of redis command
 const (
 	CommandPing        = "PING"
@@ -71,4 +81,17 @@ const (
 	CommandLPop        = "LPOP"
 	CommandLRem        = "LREM"
 	CommandLTrim       = "LTRIM"
+	CommandEval        = "EVAL"
+	CommandEvalRO      = "EVAL_RO"
+	CommandEvalSha     = "EVALSHA"
+	CommandScript      = "SCRIPT"
+	CommandFunction    = "FUNCTION"
+	CommandCluster     = "CLUSTER"
+	CommandAsking      = "ASKING"
+	CommandSentinel    = "SENTINEL"
+	CommandFlushAll    = "FLUSHALL"
+	CommandFlushDB     = "FLUSHDB"
+	CommandKeys        = "KEYS"
+	CommandConfig      = "CONFIG"
+	CommandDebug       = "DEBUG"
 )
@@ -0,0 +1,158 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// default value for budget
+const (
+	DefaultBudgetRetries = 3
+	DefaultBudgetRatio   = 0.1
+	DefaultBudgetMax     = 10
+)
+
+// ErrBudgetExhausted is returned by Do when the retry is denied by the budget of the context
+// the error also unwrap to the last error of the function
+var ErrBudgetExhausted = errors.New("retry: budget exhausted")
+
+// BudgetOptions of retry budget
+type BudgetOptions struct {
+	// Retries is the number of retries allowed before any attempt succeed, default to 3
+	Retries int
+	// Ratio is the number of retries earned by every successful attempt, default to 0.1
+	// so the request which make many successful calls can retry more, while the request which only see failures
+	// is limited to Retries and doesn't multiply the load of the degraded dependency
+	Ratio float64
+	// Max is the maximum of the retries which can be earned, default to 10
+	Max int
+}
+
+// BudgetStats is the usage of the budget
+type BudgetStats struct {
+	// Retries is the number of allowed retries
+	Retries int
+	// Denied is the number of retries denied by the budget
+	Denied int
+	// Remaining retries of the budget, rounded down
+	Remaining int
+}
+
+// Budget of retries shared by every Do with the same context, for example every sql, redis and http retry of a request
+// every retry withdraw one token of the budget, and every successful attempt deposit the ratio of a token
+type Budget struct {
+	mu      sync.Mutex
+	tokens  float64
+	ratio   float64
+	max     float64
+	retries int
+	denied  int
+}
+
+// NewBudget return new retry budget
+func NewBudget(options *BudgetOptions) *Budget {
+	opts := BudgetOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = DefaultBudgetRetries
+	}
+	if opts.Ratio <= 0 {
+		opts.Ratio = DefaultBudgetRatio
+	}
+	if opts.Max <= 0 {
+		opts.Max = DefaultBudgetMax
+	}
+	if opts.Max < opts.Retries {
+		opts.Max = opts.Retries
+	}
+	return &Budget{
+		tokens: float64(opts.Retries),
+		ratio:  opts.Ratio,
+		max:    float64(opts.Max),
+	}
+}
+
+// Withdraw return true when a retry is allowed by the budget
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		b.denied++
+		return false
+	}
+	b.tokens--
+	b.retries++
+	return true
+}
+
+// Deposit the ratio of a token after successful attempt
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.mu.Unlock()
+}
+
+// Stats return the usage of the budget
+func (b *Budget) Stats() BudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BudgetStats{
+		Retries:   b.retries,
+		Denied:    b.denied,
+		Remaining: int(b.tokens),
+	}
+}
+
+type budgetKey struct{}
+
+// WithBudget return context with the retry budget, every Do with the context draw from the budget
+func WithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// BudgetFromContext return the retry budget of the context, nil when the context has no budget
+func BudgetFromContext(ctx context.Context) *Budget {
+	budget, _ := ctx.Value(budgetKey{}).(*Budget)
+	return budget
+}
+
+// budgetError is returned when the retry is denied by the budget
+type budgetError struct {
+	attempt int
+	err     error
+}
+
+func (be *budgetError) Error() string {
+	return fmt.Sprintf("retry: budget exhausted after %d attempts: %v", be.attempt, be.err)
+}
+
+func (be *budgetError) Unwrap() error {
+	return be.err
+}
+
+func (be *budgetError) Is(target error) bool {
+	return target == ErrBudgetExhausted
+}
+
+// Middleware set new retry budget to the context of every request
+// the budget is not replaced when the context already has one, so nested middleware chains share the same budget
+func Middleware(options *BudgetOptions) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			if BudgetFromContext(rctx.Context()) == nil {
+				rctx.SetContext(WithBudget(rctx.Context(), NewBudget(options)))
+			}
+			return next(rctx)
+		}
+	}
+}
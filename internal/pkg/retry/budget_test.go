@@ -0,0 +1,89 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+)
+
+func TestBudget(t *testing.T) {
+	budget := retry.NewBudget(&retry.BudgetOptions{Retries: 2, Ratio: 0.5, Max: 3})
+	ctx := retry.WithBudget(context.Background(), budget)
+	policy := &retry.Policy{MaxAttempts: 5, InitialInterval: time.Millisecond}
+
+	// the first call use all the retries of the budget
+	attempts := 0
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		attempts++
+		return errTest
+	})
+	if !errors.Is(err, retry.ErrBudgetExhausted) || !errors.Is(err, errTest) {
+		t.Fatalf("expecting error %v of %v but got %v", retry.ErrBudgetExhausted, errTest, err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expecting 3 attempts but got %d", attempts)
+	}
+
+	// the next call of the same request is not retried
+	attempts = 0
+	retry.Do(ctx, policy, func(ctx context.Context) error {
+		attempts++
+		return errTest
+	})
+	if attempts != 1 {
+		t.Fatalf("expecting 1 attempt but got %d", attempts)
+	}
+
+	// successful calls earn the retry back
+	for i := 0; i < 2; i++ {
+		if err := retry.Do(ctx, policy, func(ctx context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := budget.Stats()
+	if stats.Retries != 2 || stats.Denied != 2 || stats.Remaining != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBudgetMax(t *testing.T) {
+	budget := retry.NewBudget(&retry.BudgetOptions{Retries: 1, Ratio: 1, Max: 2})
+	for i := 0; i < 5; i++ {
+		budget.Deposit()
+	}
+	if remaining := budget.Stats().Remaining; remaining != 2 {
+		t.Fatalf("expecting 2 remaining retries but got %d", remaining)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var budgets []*retry.Budget
+	handler := retry.Middleware(nil)(retry.Middleware(nil)(func(rctx *requestcontext.RequestContext) error {
+		budgets = append(budgets, retry.BudgetFromContext(rctx.Context()))
+		return nil
+	}))
+	for i := 0; i < 2; i++ {
+		rctx := requestcontext.New(requestcontext.Constructor{
+			HTTPResponseWriter: httptest.NewRecorder(),
+			HTTPRequest:        httptest.NewRequest(http.MethodGet, "/", nil),
+		})
+		if err := handler(rctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(budgets) != 2 || budgets[0] == nil || budgets[1] == nil {
+		t.Fatalf("expecting budget in every request but got %v", budgets)
+	}
+	if budgets[0] == budgets[1] {
+		t.Fatal("expecting new budget for every request")
+	}
+	if remaining := budgets[0].Stats().Remaining; remaining != retry.DefaultBudgetRetries {
+		t.Fatalf("expecting %d remaining retries but got %d", retry.DefaultBudgetRetries, remaining)
+	}
+}
@@ -0,0 +1,191 @@
+// Package retry provide a retry helper with exponential backoff
+// use this package instead of writing retry loop in each subsystem, so every retry in the project behave the same way
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// default value for policy
+const (
+	DefaultMaxAttempts     = 3
+	DefaultInitialInterval = time.Millisecond * 100
+	DefaultMaxInterval     = time.Second * 10
+	DefaultMultiplier      = 2
+)
+
+// Policy of retry
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts including the first attempt, default to 3
+	// set to 1 to disable retry
+	MaxAttempts int
+	// InitialInterval is the wait time before the first retry, default to 100ms
+	InitialInterval time.Duration
+	// MaxInterval is the upper bound of wait time between attempts, default to 10s
+	MaxInterval time.Duration
+	// Multiplier is the factor to increase the interval on every retry, default to 2
+	// set to 1 for constant interval
+	Multiplier float64
+	// Jitter is the randomization factor of the interval, the value is between 0 and 1
+	// for example 0.5 with 1s interval means the actual interval is between 500ms and 1.5s
+	Jitter float64
+	// MaxElapsed is the maximum time spent on all attempts, zero means no limit
+	// retry is stopped when the next wait exceed the limit
+	MaxElapsed time.Duration
+	// Retryable decide whether the error should be retried, all errors are retried when nil
+	Retryable func(err error) bool
+	// OnRetry is invoked after a failed attempt, before waiting for the next attempt
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// list of error
+var (
+	ErrFuncNil = errors.New("retry: function is nil")
+)
+
+// permanentError is an error that should not be retried
+type permanentError struct {
+	err error
+}
+
+func (pe *permanentError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe *permanentError) Unwrap() error {
+	return pe.err
+}
+
+// Permanent wrap err so it is not retried regardless of the policy
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent return true if err is marked as permanent
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+func (p Policy) withDefault() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = DefaultInitialInterval
+	}
+	if p.MaxInterval <= 0 {
+		p.MaxInterval = DefaultMaxInterval
+	}
+	if p.MaxInterval < p.InitialInterval {
+		p.MaxInterval = p.InitialInterval
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultMultiplier
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+// Interval return the wait time before the given retry number, starting from 1
+// the interval is not randomized
+func (p Policy) Interval(retry int) time.Duration {
+	p = p.withDefault()
+	interval := float64(p.InitialInterval)
+	for i := 1; i < retry; i++ {
+		interval *= p.Multiplier
+		if interval >= float64(p.MaxInterval) {
+			return p.MaxInterval
+		}
+	}
+	return time.Duration(interval)
+}
+
+func (p Policy) jitter(interval time.Duration) time.Duration {
+	if p.Jitter == 0 {
+		return interval
+	}
+	delta := p.Jitter * float64(interval)
+	min := float64(interval) - delta
+	return time.Duration(min + rand.Float64()*(2*delta))
+}
+
+// Do invoke fn until it succeed or the policy is exhausted
+// fn is not retried when the error is permanent, not retryable, when ctx is done or when the retry budget of ctx is exhausted
+// the last error of fn is returned when all attempts failed
+func Do(ctx context.Context, policy *Policy, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return ErrFuncNil
+	}
+
+	p := Policy{}
+	if policy != nil {
+		p = *policy
+	}
+	p = p.withDefault()
+
+	var (
+		start  = time.Now()
+		budget = BudgetFromContext(ctx)
+		err    error
+	)
+
+	for attempt := 1; ; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err != nil {
+				return err
+			}
+			return ctxErr
+		}
+
+		err = fn(ctx)
+		if err == nil {
+			if budget != nil {
+				budget.Deposit()
+			}
+			return nil
+		}
+
+		var pe *permanentError
+		if errors.As(err, &pe) {
+			return pe.err
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return fmt.Errorf("retry: failed after %d attempts: %w", attempt, err)
+		}
+
+		wait := p.jitter(p.Interval(attempt))
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return fmt.Errorf("retry: max elapsed time exceeded after %d attempts: %w", attempt, err)
+		}
+		if budget != nil && !budget.Withdraw() {
+			return &budgetError{attempt: attempt, err: err}
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+)
+
+var errTest = errors.New("test error")
+
+func TestDo(t *testing.T) {
+	var (
+		attempts int
+		hooks    []int
+	)
+
+	err := retry.Do(context.Background(), &retry.Policy{
+		MaxAttempts:     5,
+		InitialInterval: time.Millisecond,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			hooks = append(hooks, attempt)
+		},
+	}, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errTest
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expecting 3 attempts but got %d", attempts)
+	}
+	if len(hooks) != 2 || hooks[0] != 1 || hooks[1] != 2 {
+		t.Errorf("unexpected hooks invocation %v", hooks)
+	}
+}
+
+func TestDoExhausted(t *testing.T) {
+	attempts := 0
+	err := retry.Do(context.Background(), &retry.Policy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+	}, func(ctx context.Context) error {
+		attempts++
+		return errTest
+	})
+	if !errors.Is(err, errTest) {
+		t.Errorf("expecting error %v but got %v", errTest, err)
+	}
+	if attempts != 3 {
+		t.Errorf("expecting 3 attempts but got %d", attempts)
+	}
+}
+
+func TestDoNotRetryable(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy retry.Policy
+		err    error
+	}{
+		{
+			name:   "permanent",
+			policy: retry.Policy{InitialInterval: time.Millisecond},
+			err:    retry.Permanent(errTest),
+		},
+		{
+			name: "predicate",
+			policy: retry.Policy{
+				InitialInterval: time.Millisecond,
+				Retryable:       func(err error) bool { return false },
+			},
+			err: errTest,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			attempts := 0
+			err := retry.Do(context.Background(), &c.policy, func(ctx context.Context) error {
+				attempts++
+				return c.err
+			})
+			if err != errTest {
+				t.Errorf("expecting error %v but got %v", errTest, err)
+			}
+			if attempts != 1 {
+				t.Errorf("expecting 1 attempt but got %d", attempts)
+			}
+		})
+	}
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := retry.Do(ctx, &retry.Policy{
+		MaxAttempts:     10,
+		InitialInterval: time.Hour,
+	}, func(ctx context.Context) error {
+		attempts++
+		cancel()
+		return errTest
+	})
+	if err != errTest {
+		t.Errorf("expecting error %v but got %v", errTest, err)
+	}
+	if attempts != 1 {
+		t.Errorf("expecting 1 attempt but got %d", attempts)
+	}
+}
+
+func TestDoMaxElapsed(t *testing.T) {
+	attempts := 0
+	err := retry.Do(context.Background(), &retry.Policy{
+		MaxAttempts:     10,
+		InitialInterval: time.Second,
+		MaxElapsed:      time.Millisecond * 100,
+	}, func(ctx context.Context) error {
+		attempts++
+		return errTest
+	})
+	if !errors.Is(err, errTest) {
+		t.Errorf("expecting error %v but got %v", errTest, err)
+	}
+	if attempts != 1 {
+		t.Errorf("expecting 1 attempt but got %d", attempts)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	p := retry.Policy{
+		InitialInterval: time.Second,
+		MaxInterval:     time.Second * 5,
+		Multiplier:      2,
+	}
+	expect := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 5, time.Second * 5}
+	for i, e := range expect {
+		if got := p.Interval(i + 1); got != e {
+			t.Errorf("retry %d: expecting interval %s but got %s", i+1, e, got)
+		}
+	}
+}
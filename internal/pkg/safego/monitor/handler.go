@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/pprof"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/alert"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Report of the monitor handler
+type Report struct {
+	Snapshot Snapshot `json:"snapshot"`
+	// History is the recent goroutine counts of every subsystem, the oldest first
+	History map[string][]int64 `json:"history"`
+	Firing  []alert.Alert      `json:"firing"`
+}
+
+// Handler return the current goroutines per subsystem with the history and the firing alerts as json
+// with ?debug=1 the goroutine profile is returned as text, every stack is written with the subsystem label
+func (m *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Get("debug") == "1" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			pprof.Lookup("goroutine").WriteTo(w, 1)
+			return
+		}
+
+		report := Report{
+			Snapshot: m.current(),
+			History:  m.History(),
+			Firing:   m.Firing(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+var goroutinesDesc = prometheus.NewDesc(
+	"safego_goroutines",
+	"Number of running goroutines per subsystem, goroutines which are not spawned by safego are unattributed",
+	[]string{safego.LabelSubsystem}, nil,
+)
+
+// Collector return prometheus collector of the running goroutines per subsystem
+// so the goroutines can also be alerted with alert rule, for example {Metric: "safego_goroutines", Threshold: 1000}
+func (m *Monitor) Collector() prometheus.Collector {
+	return collector{monitor: m}
+}
+
+type collector struct {
+	monitor *Monitor
+}
+
+// Describe implements prometheus.Collector
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- goroutinesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	for subsystem, count := range c.monitor.current().Subsystems {
+		ch <- prometheus.MustNewConstMetric(goroutinesDesc, prometheus.GaugeValue, float64(count), subsystem)
+	}
+}
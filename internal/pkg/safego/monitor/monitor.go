@@ -0,0 +1,323 @@
+// Package monitor snapshot the running goroutines per subsystem and alert on unbounded growth
+// goroutines are attributed to the subsystem by safego, goroutines spawned with go statement are counted as unattributed
+//
+//	m := monitor.New(&monitor.Options{
+//		Limits:    map[string]int64{"eventbus": 1000},
+//		Notifiers: []alert.Notifier{alert.Logger(logger)},
+//	})
+//	m.Start(ctx)
+//	defer m.Close()
+//	server.HandleAdmin("/debug/goroutines", m.Handler())
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/alert"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// Unattributed is the subsystem of goroutines which are not spawned by safego
+const Unattributed = "unattributed"
+
+// list of alert rule
+const (
+	// RuleGrowth fire when the goroutines of the subsystem never decrease in the window and grow at least MinGrowth
+	RuleGrowth = "goroutine_growth"
+	// RuleLimit fire when the goroutines of the subsystem are above its limit
+	RuleLimit = "goroutine_limit"
+)
+
+// default value of options
+const (
+	DefaultInterval  = time.Second * 30
+	DefaultWindow    = 10
+	DefaultMinGrowth = 100
+)
+
+// Options of monitor
+type Options struct {
+	// Interval of snapshot, default to 30 seconds
+	Interval time.Duration
+	// Window is the number of recent snapshots to detect the growth, default to 10
+	Window int
+	// MinGrowth is the minimum increase of goroutines in the window which is alerted, default to 100
+	// so the goroutines which grow slowly to its steady state are not alerted
+	MinGrowth int64
+	// Limits of running goroutines per subsystem, including Unattributed
+	Limits map[string]int64
+	// Notifiers of the alerts, every notifier is called even when the other failed
+	Notifiers []alert.Notifier
+	// OnError is called when the notification failed in Start
+	OnError func(err error)
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+	// Counts return the running goroutines per subsystem, default to safego.Counts
+	Counts func() map[string]int64
+	// Total return the number of all goroutines, default to runtime.NumGoroutine
+	Total func() int
+}
+
+// Snapshot of running goroutines
+type Snapshot struct {
+	At    time.Time `json:"at"`
+	Total int64     `json:"total"`
+	// Subsystems is the running goroutines per subsystem, including Unattributed
+	Subsystems map[string]int64 `json:"subsystems"`
+}
+
+// state of one subsystem
+type state struct {
+	history        []int64
+	growthFiring   bool
+	growthStartsAt time.Time
+	limitFiring    bool
+	limitStartsAt  time.Time
+}
+
+// Monitor of goroutines
+type Monitor struct {
+	opts Options
+
+	mu     sync.Mutex
+	last   Snapshot
+	states map[string]*state
+	done   <-chan error
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// New monitor of goroutines
+func New(options *Options) *Monitor {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Window <= 1 {
+		opts.Window = DefaultWindow
+	}
+	if opts.MinGrowth <= 0 {
+		opts.MinGrowth = DefaultMinGrowth
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Counts == nil {
+		opts.Counts = safego.Counts
+	}
+	if opts.Total == nil {
+		opts.Total = runtime.NumGoroutine
+	}
+
+	m := Monitor{
+		opts:   opts,
+		states: make(map[string]*state),
+		stop:   make(chan struct{}),
+	}
+	return &m
+}
+
+// current return the running goroutines without evaluating the alerts
+func (m *Monitor) current() Snapshot {
+	snapshot := Snapshot{
+		At:         m.opts.Now(),
+		Total:      int64(m.opts.Total()),
+		Subsystems: make(map[string]int64),
+	}
+	var attributed int64
+	for subsystem, count := range m.opts.Counts() {
+		snapshot.Subsystems[subsystem] = count
+		attributed += count
+	}
+	unattributed := snapshot.Total - attributed
+	// the goroutine may finish between the counts and the total
+	if unattributed < 0 {
+		unattributed = 0
+	}
+	snapshot.Subsystems[Unattributed] = unattributed
+	return snapshot
+}
+
+// Snapshot the running goroutines and notify the changed alerts
+// the changed alerts are returned together with the first notification error
+func (m *Monitor) Snapshot(ctx context.Context) (Snapshot, []alert.Alert, error) {
+	snapshot := m.current()
+
+	var alerts []alert.Alert
+	m.mu.Lock()
+	m.last = snapshot
+	for subsystem, count := range snapshot.Subsystems {
+		s, ok := m.states[subsystem]
+		if !ok {
+			s = &state{}
+			m.states[subsystem] = s
+		}
+		alerts = append(alerts, m.evaluate(s, subsystem, count, snapshot.At)...)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Key() < alerts[j].Key()
+	})
+	var notifyErr error
+	for _, a := range alerts {
+		for _, notifier := range m.opts.Notifiers {
+			if err := notifier.Notify(ctx, a); err != nil && notifyErr == nil {
+				notifyErr = fmt.Errorf("monitor: failed to notify %s: %w", a.Key(), err)
+			}
+		}
+	}
+	return snapshot, alerts, notifyErr
+}
+
+// evaluate the count of the subsystem, the alerts are returned when they fire or resolved
+func (m *Monitor) evaluate(s *state, subsystem string, count int64, now time.Time) []alert.Alert {
+	s.history = append(s.history, count)
+	if len(s.history) > m.opts.Window {
+		s.history = s.history[len(s.history)-m.opts.Window:]
+	}
+
+	var (
+		alerts = make([]alert.Alert, 0, 2)
+		labels = map[string]string{safego.LabelSubsystem: subsystem}
+	)
+	first := s.history[0]
+	growing := len(s.history) == m.opts.Window && count-first >= m.opts.MinGrowth
+	for idx := 1; growing && idx < len(s.history); idx++ {
+		if s.history[idx] < s.history[idx-1] {
+			growing = false
+		}
+	}
+	if growing != s.growthFiring {
+		s.growthFiring = growing
+		a := alert.Alert{
+			Rule:    RuleGrowth,
+			Status:  alert.StatusResolved,
+			Labels:  labels,
+			Value:   float64(count),
+			Limit:   float64(first + m.opts.MinGrowth),
+			Message: fmt.Sprintf("goroutines of %s grow from %d to %d in %d snapshots", subsystem, first, count, len(s.history)),
+			At:      now,
+		}
+		if growing {
+			s.growthStartsAt = now
+			a.Status = alert.StatusFiring
+		}
+		a.StartsAt = s.growthStartsAt
+		alerts = append(alerts, a)
+	}
+
+	limit, ok := m.opts.Limits[subsystem]
+	if !ok {
+		return alerts
+	}
+	if above := count > limit; above != s.limitFiring {
+		s.limitFiring = above
+		a := alert.Alert{
+			Rule:    RuleLimit,
+			Status:  alert.StatusResolved,
+			Labels:  labels,
+			Value:   float64(count),
+			Limit:   float64(limit),
+			Message: fmt.Sprintf("goroutines of %s are above the limit", subsystem),
+			At:      now,
+		}
+		if above {
+			s.limitStartsAt = now
+			a.Status = alert.StatusFiring
+		}
+		a.StartsAt = s.limitStartsAt
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// Last return the last snapshot, the snapshot is empty before the first snapshot
+func (m *Monitor) Last() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// History return the recent goroutine counts of every subsystem, the oldest first
+func (m *Monitor) History() map[string][]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	history := make(map[string][]int64, len(m.states))
+	for subsystem, s := range m.states {
+		history[subsystem] = append([]int64(nil), s.history...)
+	}
+	return history
+}
+
+// Firing return the firing alerts
+func (m *Monitor) Firing() []alert.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var alerts []alert.Alert
+	for subsystem, s := range m.states {
+		labels := map[string]string{safego.LabelSubsystem: subsystem}
+		if s.growthFiring {
+			alerts = append(alerts, alert.Alert{Rule: RuleGrowth, Status: alert.StatusFiring, Labels: labels, StartsAt: s.growthStartsAt})
+		}
+		if s.limitFiring {
+			alerts = append(alerts, alert.Alert{Rule: RuleLimit, Status: alert.StatusFiring, Labels: labels, StartsAt: s.limitStartsAt})
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Key() < alerts[j].Key()
+	})
+	return alerts
+}
+
+// Start snapshot the goroutines every interval until Close is called
+func (m *Monitor) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done != nil {
+		return
+	}
+	m.done = safego.Go(safego.Detach(ctx), "monitor.goroutines", m.run)
+}
+
+// Close stop the snapshot and wait for the running snapshot
+func (m *Monitor) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	m.mu.Lock()
+	done := m.done
+	m.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	return <-done
+}
+
+func (m *Monitor) run(ctx context.Context) error {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return nil
+		case <-ticker.C:
+			if _, _, err := m.Snapshot(ctx); err != nil {
+				m.opts.OnError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/alert"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSnapshot(t *testing.T) {
+	var (
+		counts   map[string]int64
+		notified []alert.Alert
+	)
+	m := New(&Options{
+		Window:    3,
+		MinGrowth: 10,
+		Limits:    map[string]int64{"eventbus": 50},
+		Notifiers: []alert.Notifier{alert.NotifierFunc(func(ctx context.Context, a alert.Alert) error {
+			notified = append(notified, a)
+			return nil
+		})},
+		Now:    func() time.Time { return time.Unix(0, 0) },
+		Counts: func() map[string]int64 { return counts },
+		Total:  func() int { return 100 },
+	})
+
+	cases := []struct {
+		name   string
+		counts map[string]int64
+		expect []string
+	}{
+		{"first", map[string]int64{"eventbus": 10, "server": 2}, nil},
+		{"collecting window", map[string]int64{"eventbus": 15, "server": 2}, nil},
+		{"growing", map[string]int64{"eventbus": 25, "server": 2}, []string{"goroutine_growth/eventbus/firing"}},
+		{"still growing above limit", map[string]int64{"eventbus": 60, "server": 2}, []string{"goroutine_limit/eventbus/firing"}},
+		{"decrease", map[string]int64{"eventbus": 40, "server": 2}, []string{"goroutine_growth/eventbus/resolved", "goroutine_limit/eventbus/resolved"}},
+	}
+
+	for _, c := range cases {
+		counts = c.counts
+		notified = nil
+		snapshot, alerts, err := m.Snapshot(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unattributed := snapshot.Subsystems[Unattributed]; unattributed != 100-c.counts["eventbus"]-c.counts["server"] {
+			t.Fatalf("%s: expecting unattributed goroutines are the rest of the total but got %d", c.name, unattributed)
+		}
+		if len(alerts) != len(c.expect) || len(notified) != len(c.expect) {
+			t.Fatalf("%s: expecting alerts %v but got %v", c.name, c.expect, alerts)
+		}
+		for idx, a := range alerts {
+			if got := a.Rule + "/" + a.Labels["subsystem"] + "/" + a.Status; got != c.expect[idx] {
+				t.Fatalf("%s: expecting alert %s but got %s", c.name, c.expect[idx], got)
+			}
+		}
+	}
+	if firing := m.Firing(); len(firing) != 0 {
+		t.Fatalf("expecting no firing alert but got %v", firing)
+	}
+	if history := m.History()["eventbus"]; len(history) != 3 || history[2] != 40 {
+		t.Fatalf("expecting the last 3 counts of eventbus but got %v", history)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New(&Options{
+		Counts: func() map[string]int64 { return map[string]int64{"server": 3} },
+		Total:  func() int { return 5 },
+	})
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expecting status 200 but got %d", recorder.Code)
+	}
+	report := Report{}
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Snapshot.Subsystems["server"] != 3 || report.Snapshot.Subsystems[Unattributed] != 2 {
+		t.Fatalf("expecting 3 server and 2 unattributed goroutines but got %v", report.Snapshot.Subsystems)
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(m.Collector()); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 2 {
+		t.Fatalf("expecting safego_goroutines of 2 subsystems but got %v", families)
+	}
+}
@@ -0,0 +1,192 @@
+// Package safego spawn goroutines with context propagation and panic safety
+// all background goroutines in the project should be spawned via this package instead of bare go statement
+// so a panic in one goroutine doesn't crash the whole program and logger/trace information is not lost
+//
+// every goroutine is labeled with its subsystem and name in the goroutine profile, and counted per subsystem
+// so goroutine leak can be attributed to the subsystem, see Counts
+package safego
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"go.opencensus.io/trace"
+)
+
+type fieldsKey struct{}
+
+type subsystemKey struct{}
+
+// list of goroutine profile label
+const (
+	LabelSubsystem = "subsystem"
+	LabelGoroutine = "goroutine"
+)
+
+// counts is the number of running goroutines per subsystem, the value is *int64
+var counts sync.Map
+
+// PanicError is returned when the goroutine is panic
+type PanicError struct {
+	Name  string
+	Value interface{}
+	Stack []byte
+}
+
+// Error return the panic value
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("safego: goroutine %s panic: %v", pe.Name, pe.Value)
+}
+
+// WithFields return a context with logger fields
+// the fields is inherited by all goroutines spawned from the context
+func WithFields(ctx context.Context, kv logger.KV) context.Context {
+	fields := make(logger.KV)
+	for k, v := range Fields(ctx) {
+		fields[k] = v
+	}
+	for k, v := range kv {
+		fields[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields return logger fields from context
+func Fields(ctx context.Context) logger.KV {
+	fields, ok := ctx.Value(fieldsKey{}).(logger.KV)
+	if !ok {
+		return logger.KV{}
+	}
+	return fields
+}
+
+// WithSubsystem return a context with the subsystem of goroutines spawned from the context
+// the subsystem is inherited by all goroutines spawned from the context, for example all goroutines of a worker pool
+func WithSubsystem(ctx context.Context, subsystem string) context.Context {
+	return context.WithValue(ctx, subsystemKey{}, subsystem)
+}
+
+// Subsystem return the subsystem of the goroutine name
+// the subsystem from WithSubsystem is used when it is set, otherwise the name until the first / or ., for example server of server/run
+func Subsystem(ctx context.Context, name string) string {
+	if subsystem, ok := ctx.Value(subsystemKey{}).(string); ok && subsystem != "" {
+		return subsystem
+	}
+	if idx := strings.IndexAny(name, "/."); idx > 0 {
+		return name[:idx]
+	}
+	return name
+}
+
+// Counts return the number of running goroutines spawned by Go per subsystem
+// subsystem is kept with zero count after all its goroutines are finished
+func Counts() map[string]int64 {
+	result := make(map[string]int64)
+	counts.Range(func(key, value interface{}) bool {
+		result[key.(string)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return result
+}
+
+func counter(subsystem string) *int64 {
+	if count, ok := counts.Load(subsystem); ok {
+		return count.(*int64)
+	}
+	count, _ := counts.LoadOrStore(subsystem, new(int64))
+	return count.(*int64)
+}
+
+// Go run fn in a new goroutine
+// the goroutine run with a new trace span as the child of span in ctx
+// when fn panic, the panic is recovered, logged and returned as *PanicError
+//
+// the returned channel is buffered and will receive the error of fn before closed
+// it is safe to ignore the channel if the error is not needed
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) <-chan error {
+	errChan := make(chan error, 1)
+	subsystem := Subsystem(ctx, name)
+	count := counter(subsystem)
+	atomic.AddInt64(count, 1)
+	go func() {
+		defer close(errChan)
+		defer atomic.AddInt64(count, -1)
+		// the labels are also inherited by goroutines spawned with go statement inside fn
+		pprof.Do(ctx, pprof.Labels(LabelSubsystem, subsystem, LabelGoroutine, name), func(ctx context.Context) {
+			errChan <- Run(ctx, name, fn)
+		})
+	}()
+	return errChan
+}
+
+// Run fn in the current goroutine with the same panic safety and context propagation as Go
+func Run(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	ctx = WithFields(ctx, logger.KV{"goroutine": name})
+	ctx, span := trace.StartSpan(ctx, name)
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr := &PanicError{
+				Name:  name,
+				Value: r,
+				Stack: debug.Stack(),
+			}
+			fields := Fields(ctx)
+			fields["panic"] = fmt.Sprintf("%v", r)
+			fields["stack"] = string(perr.Stack)
+			log.Errorw("safego: recovered from panic", fields)
+			span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: perr.Error()})
+			err = perr
+		}
+		span.End()
+	}()
+
+	return fn(ctx)
+}
+
+// Wait for all error channels returned by Go and return all non-nil errors
+func Wait(errChans ...<-chan error) []error {
+	var errs []error
+	for _, errChan := range errChans {
+		for err := range errChan {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// Detach return a new context which carry all values from ctx but not its deadline and cancellation
+// this is useful for background task which spawned from a request and must outlive the request
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (dc detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (dc detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (dc detachedContext) Err() error {
+	return nil
+}
+
+func (dc detachedContext) Value(key interface{}) interface{} {
+	return dc.parent.Value(key)
+}
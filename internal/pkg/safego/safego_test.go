@@ -0,0 +1,134 @@
+package safego_test
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+func TestGo(t *testing.T) {
+	errTest := errors.New("test error")
+
+	cases := []struct {
+		name  string
+		fn    func(ctx context.Context) error
+		check func(err error) bool
+	}{
+		{
+			name:  "no error",
+			fn:    func(ctx context.Context) error { return nil },
+			check: func(err error) bool { return err == nil },
+		},
+		{
+			name:  "return error",
+			fn:    func(ctx context.Context) error { return errTest },
+			check: func(err error) bool { return errors.Is(err, errTest) },
+		},
+		{
+			name: "panic",
+			fn:   func(ctx context.Context) error { panic("oops") },
+			check: func(err error) bool {
+				var perr *safego.PanicError
+				return errors.As(err, &perr) && perr.Value == "oops"
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := <-safego.Go(context.Background(), c.name, c.fn)
+			if !c.check(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestFieldsInheritance(t *testing.T) {
+	ctx := safego.WithFields(context.Background(), logger.KV{"request_id": "abc"})
+
+	var fields logger.KV
+	errs := safego.Wait(safego.Go(ctx, "child", func(ctx context.Context) error {
+		fields = safego.Fields(ctx)
+		return nil
+	}))
+	if len(errs) != 0 {
+		t.Fatalf("not expecting error but got %v", errs)
+	}
+	if fields["request_id"] != "abc" || fields["goroutine"] != "child" {
+		t.Errorf("fields is not inherited, got %v", fields)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithTimeout(safego.WithFields(context.Background(), logger.KV{"a": 1}), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	detached := safego.Detach(ctx)
+	if detached.Err() != nil {
+		t.Errorf("detached context should not be canceled")
+	}
+	if safego.Fields(detached)["a"] != 1 {
+		t.Errorf("detached context should carry values")
+	}
+}
+
+func TestCounts(t *testing.T) {
+	ctx := safego.WithSubsystem(context.Background(), "counts_test")
+
+	var (
+		release  = make(chan struct{})
+		labels   = make(chan string, 2)
+		errChans []<-chan error
+	)
+	for i := 0; i < 2; i++ {
+		errChans = append(errChans, safego.Go(ctx, "counts_test/worker", func(ctx context.Context) error {
+			label, _ := pprof.Label(ctx, safego.LabelSubsystem)
+			labels <- label
+			<-release
+			return nil
+		}))
+	}
+	for i := 0; i < 2; i++ {
+		if label := <-labels; label != "counts_test" {
+			t.Errorf("expecting subsystem label counts_test but got %s", label)
+		}
+	}
+	if count := safego.Counts()["counts_test"]; count != 2 {
+		t.Errorf("expecting 2 running goroutines but got %d", count)
+	}
+
+	close(release)
+	safego.Wait(errChans...)
+	if count := safego.Counts()["counts_test"]; count != 0 {
+		t.Errorf("expecting no running goroutine but got %d", count)
+	}
+}
+
+func TestSubsystem(t *testing.T) {
+	cases := []struct {
+		ctx    context.Context
+		name   string
+		expect string
+	}{
+		{context.Background(), "server/run", "server"},
+		{context.Background(), "alert.evaluator", "alert"},
+		{context.Background(), "worker", "worker"},
+		{safego.WithSubsystem(context.Background(), "payment"), "concurrent/pipeline/result", "payment"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if subsystem := safego.Subsystem(c.ctx, c.name); subsystem != c.expect {
+				t.Errorf("expecting subsystem %s but got %s", c.expect, subsystem)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+// Package search is a client of elasticsearch/opensearch rest api
+// the client balance requests across nodes, retry failed requests on another node and optionally sniff the cluster nodes
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/indexer"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+)
+
+// list of error
+var (
+	ErrNoAddress      = errors.New("search: address is empty")
+	ErrAddressInvalid = errors.New("search: address must be a http or https url")
+	ErrBulkFailed     = errors.New("search: some documents are failed in bulk request")
+)
+
+// ResponseError is returned when the response status code is not 2xx
+type ResponseError struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Error return the status code and body of the response
+func (re *ResponseError) Error() string {
+	return fmt.Sprintf("search: response status %d: %s", re.StatusCode, string(re.Body))
+}
+
+// Config of search client
+type Config struct {
+	Addresses []string
+	Username  string
+	Password  string
+	APIKey    string
+	// Sniff the cluster to discover all nodes, the addresses are used when no node found
+	Sniff bool
+	// SniffInterval default to 5 minutes
+	SniffInterval time.Duration
+	// MaxRetry is the number of attempts of a request, default to 3
+	MaxRetry int
+	// Timeout of a request, default to 30 seconds
+	Timeout time.Duration
+}
+
+// ValidateAddresses check all addresses is a valid http url
+func ValidateAddresses(addresses []string) error {
+	if len(addresses) == 0 {
+		return ErrNoAddress
+	}
+	for _, address := range addresses {
+		u, err := url.Parse(address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %s", ErrAddressInvalid, address)
+		}
+	}
+	return nil
+}
+
+// Client of search engine
+type Client struct {
+	config Config
+	http   *http.Client
+
+	mu    sync.RWMutex
+	nodes []string
+	next  uint32
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New search client, the client ping the cluster to check the connection
+func New(ctx context.Context, config *Config) (*Client, error) {
+	conf := Config{}
+	if config != nil {
+		conf = *config
+	}
+	if err := ValidateAddresses(conf.Addresses); err != nil {
+		return nil, err
+	}
+	if conf.SniffInterval <= 0 {
+		conf.SniffInterval = time.Minute * 5
+	}
+	if conf.MaxRetry <= 0 {
+		conf.MaxRetry = 3
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = time.Second * 30
+	}
+
+	nodes := make([]string, len(conf.Addresses))
+	for i, address := range conf.Addresses {
+		nodes[i] = strings.TrimRight(address, "/")
+	}
+
+	c := Client{
+		config: conf,
+		http:   &http.Client{Timeout: conf.Timeout},
+		nodes:  nodes,
+		stop:   make(chan struct{}),
+	}
+	if err := c.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	if conf.Sniff {
+		if err := c.sniff(ctx); err != nil {
+			return nil, err
+		}
+		safego.Go(safego.Detach(ctx), "search/sniff", func(ctx context.Context) error {
+			c.sniffLoop(ctx)
+			return nil
+		})
+	}
+	return &c, nil
+}
+
+// Nodes return list of nodes used by the client
+func (c *Client) Nodes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	nodes := make([]string, len(c.nodes))
+	copy(nodes, c.nodes)
+	return nodes
+}
+
+// node return the next node in round robin
+func (c *Client) node() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	n := atomic.AddUint32(&c.next, 1)
+	return c.nodes[int(n-1)%len(c.nodes)]
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Perform the request to the cluster and return the response body
+// failed request is retried on the next node
+func (c *Client) Perform(ctx context.Context, method, path string, body []byte, contentType string) ([]byte, error) {
+	var respBody []byte
+	policy := retry.Policy{
+		MaxAttempts:     c.config.MaxRetry,
+		InitialInterval: time.Millisecond * 100,
+		MaxInterval:     time.Second * 5,
+		Jitter:          0.2,
+	}
+	err := retry.Do(ctx, &policy, func(ctx context.Context) error {
+		var err error
+		respBody, err = c.perform(ctx, c.node(), method, path, body, contentType)
+		return err
+	})
+	return respBody, err
+}
+
+func (c *Client) perform(ctx context.Context, node, method, path string, body []byte, contentType string) ([]byte, error) {
+	req, err := http.NewRequest(method, node+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+	req = req.WithContext(ctx)
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.config.APIKey)
+	} else if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		rerr := &ResponseError{StatusCode: resp.StatusCode, Body: respBody}
+		if isRetryableStatus(resp.StatusCode) {
+			return nil, rerr
+		}
+		return nil, retry.Permanent(rerr)
+	}
+	return respBody, nil
+}
+
+// Ping the cluster
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Perform(ctx, http.MethodGet, "/", nil, "")
+	return err
+}
+
+// Search documents in index and decode the response into dest
+func (c *Client) Search(ctx context.Context, index string, query interface{}, dest interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Perform(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_search", body, "")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp, dest)
+}
+
+type bulkResponse struct {
+	Errors bool                                     `json:"errors"`
+	Items  []map[string]struct{ Error interface{} } `json:"items"`
+}
+
+// Bulk index or delete documents, this implements indexer.Sink
+func (c *Client) Bulk(ctx context.Context, docs []indexer.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	buff := bytes.NewBuffer(nil)
+	for _, doc := range docs {
+		action := "index"
+		if doc.Op == indexer.OpDelete {
+			action = "delete"
+		}
+		meta := map[string]map[string]string{
+			action: {"_index": doc.Index, "_id": doc.ID},
+		}
+		if err := json.NewEncoder(buff).Encode(meta); err != nil {
+			return err
+		}
+		if doc.Op == indexer.OpDelete {
+			continue
+		}
+		if err := json.NewEncoder(buff).Encode(doc.Body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.Perform(ctx, http.MethodPost, "/_bulk", buff.Bytes(), "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+
+	bulkResp := bulkResponse{}
+	if err := json.Unmarshal(resp, &bulkResp); err != nil {
+		return err
+	}
+	if !bulkResp.Errors {
+		return nil
+	}
+	for _, item := range bulkResp.Items {
+		for action, result := range item {
+			if result.Error != nil {
+				return fmt.Errorf("%w: %s: %v", ErrBulkFailed, action, result.Error)
+			}
+		}
+	}
+	return ErrBulkFailed
+}
+
+type nodesResponse struct {
+	Nodes map[string]struct {
+		HTTP struct {
+			PublishAddress string `json:"publish_address"`
+		} `json:"http"`
+	} `json:"nodes"`
+}
+
+// sniff the cluster nodes and replace the nodes used by the client
+func (c *Client) sniff(ctx context.Context) error {
+	resp, err := c.Perform(ctx, http.MethodGet, "/_nodes/http", nil, "")
+	if err != nil {
+		return err
+	}
+	nodesResp := nodesResponse{}
+	if err := json.Unmarshal(resp, &nodesResp); err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if u, err := url.Parse(c.config.Addresses[0]); err == nil {
+		scheme = u.Scheme
+	}
+	var nodes []string
+	for _, node := range nodesResp.Nodes {
+		address := node.HTTP.PublishAddress
+		if address == "" {
+			continue
+		}
+		// publish address might be formatted as hostname/ip:port
+		if idx := strings.Index(address, "/"); idx >= 0 {
+			address = address[idx+1:]
+		}
+		nodes = append(nodes, fmt.Sprintf("%s://%s", scheme, address))
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.nodes = nodes
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) sniffLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.config.SniffInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			// keep the current nodes when sniff is failed
+			c.sniff(ctx)
+		}
+	}
+}
+
+// Close the client and stop sniffing
+func (c *Client) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	return nil
+}
@@ -0,0 +1,107 @@
+package search_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/indexer"
+	"github.com/albertwidi/go-project-example/internal/pkg/search"
+)
+
+func TestBulk(t *testing.T) {
+	var (
+		lines    []string
+		failures int32 = 1
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "elastic" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"tagline":"You Know, for Search"}`))
+		case "/_bulk":
+			// fail the first bulk request to test retry
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			w.Write([]byte(`{"errors":false,"items":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := search.New(context.Background(), &search.Config{
+		Addresses: []string{srv.URL},
+		Username:  "elastic",
+		Password:  "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Bulk(context.Background(), []indexer.Document{
+		{Index: "users", ID: "1", Op: indexer.OpUpsert, Body: map[string]string{"name": "albert"}},
+		{Index: "users", ID: "2", Op: indexer.OpDelete},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{
+		`{"index":{"_id":"1","_index":"users"}}`,
+		`{"name":"albert"}`,
+		`{"delete":{"_id":"2","_index":"users"}}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("unexpected bulk body:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestSniff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{}`))
+		case "/_nodes/http":
+			fmt.Fprintf(w, `{"nodes":{"a":{"http":{"publish_address":"localhost/%s"}}}}`, r.Host)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := search.New(context.Background(), &search.Config{
+		Addresses: []string{srv.URL},
+		Sniff:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	nodes := client.Nodes()
+	if len(nodes) != 1 || nodes[0] != srv.URL {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	if err := search.ValidateAddresses(nil); !errors.Is(err, search.ErrNoAddress) {
+		t.Errorf("expecting error %v but got %v", search.ErrNoAddress, err)
+	}
+	if err := search.ValidateAddresses([]string{"localhost:9200"}); !errors.Is(err, search.ErrAddressInvalid) {
+		t.Errorf("expecting error %v but got %v", search.ErrAddressInvalid, err)
+	}
+}
@@ -0,0 +1,262 @@
+// Package sops decrypt yaml file encrypted by sops (https://github.com/mozilla/sops) with age key
+// only age master key and AES256_GCM values are supported, and the mac of the file is verified
+// so the file is rejected when a value is changed, added or removed after it is encrypted
+package sops
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/age"
+	"gopkg.in/yaml.v2"
+)
+
+// list of error
+var (
+	ErrNotEncrypted   = errors.New("sops: file is not encrypted by sops")
+	ErrNoAgeKey       = errors.New("sops: file has no age key")
+	ErrInvalidValue   = errors.New("sops: invalid encrypted value")
+	ErrInvalidDataKey = errors.New("sops: invalid data key")
+	ErrInvalidMAC     = errors.New("sops: invalid mac")
+)
+
+// metadataKey is the top level key of sops metadata in the file
+const metadataKey = "sops"
+
+var encryptedPattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+type metadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	// MAC is the encrypted sha512 of the values, the last modified time is the additional data of the mac
+	MAC          string `yaml:"mac"`
+	LastModified string `yaml:"lastmodified"`
+	// MACOnlyEncrypted only add the encrypted values to the mac
+	MACOnlyEncrypted bool `yaml:"mac_only_encrypted"`
+}
+
+// IsEncrypted return true when the yaml has sops metadata
+func IsEncrypted(content []byte) bool {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return false
+	}
+	for _, item := range doc {
+		if key, ok := item.Key.(string); ok && key == metadataKey {
+			return true
+		}
+	}
+	return false
+}
+
+// DecryptYAML decrypt the values of sops encrypted yaml, and return the yaml without sops metadata
+func DecryptYAML(content []byte, identities ...*age.Identity) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	var (
+		meta  *metadata
+		items = make(yaml.MapSlice, 0, len(doc))
+	)
+	for _, item := range doc {
+		if key, ok := item.Key.(string); ok && key == metadataKey {
+			out, err := yaml.Marshal(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			meta = &metadata{}
+			if err := yaml.Unmarshal(out, meta); err != nil {
+				return nil, fmt.Errorf("sops: invalid metadata: %w", err)
+			}
+			continue
+		}
+		items = append(items, item)
+	}
+	if meta == nil {
+		return nil, ErrNotEncrypted
+	}
+
+	dataKey, err := meta.dataKey(identities)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDataKey, err)
+	}
+	// sops use 32 bytes iv instead of the standard 12 bytes
+	aead, err := cipher.NewGCMWithNonceSize(block, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decryptor{aead: aead, mac: sha512.New(), macOnlyEncrypted: meta.MACOnlyEncrypted}
+	decrypted, err := d.decrypt(items, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.verify(meta); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(decrypted)
+}
+
+// dataKey decrypt the data key with the first age key that can be decrypted by the identities
+func (m *metadata) dataKey(identities []*age.Identity) ([]byte, error) {
+	if len(m.Age) == 0 {
+		return nil, ErrNoAgeKey
+	}
+	var err error
+	for _, key := range m.Age {
+		var dataKey []byte
+		dataKey, err = age.Decrypt([]byte(key.Enc), identities...)
+		if err != nil {
+			continue
+		}
+		if len(dataKey) != 32 {
+			return nil, ErrInvalidDataKey
+		}
+		return dataKey, nil
+	}
+	return nil, fmt.Errorf("sops: failed to decrypt data key: %w", err)
+}
+
+type decryptor struct {
+	aead cipher.AEAD
+	// mac is the hash of the values in the order of the file, the same as sops
+	mac              hash.Hash
+	macOnlyEncrypted bool
+}
+
+// verify the mac of the decrypted values with the mac of the metadata
+func (d *decryptor) verify(meta *metadata) error {
+	if meta.MAC == "" {
+		return fmt.Errorf("%w: file has no mac", ErrInvalidMAC)
+	}
+	lastModified, err := time.Parse(time.RFC3339, meta.LastModified)
+	if err != nil {
+		return fmt.Errorf("%w: invalid last modified: %v", ErrInvalidMAC, err)
+	}
+	mac, err := d.decryptValue(meta.MAC, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMAC, err)
+	}
+	if mac != fmt.Sprintf("%X", d.mac.Sum(nil)) {
+		return fmt.Errorf("%w: file is changed after it is encrypted", ErrInvalidMAC)
+	}
+	return nil
+}
+
+// addMAC add the value to the mac in the format of sops, for example True and False for bool
+func (d *decryptor) addMAC(value interface{}, encrypted bool) {
+	if d.macOnlyEncrypted && !encrypted {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		d.mac.Write([]byte(v))
+	case int:
+		d.mac.Write([]byte(strconv.Itoa(v)))
+	case float64:
+		d.mac.Write([]byte(strconv.FormatFloat(v, 'f', -1, 64)))
+	case bool:
+		if v {
+			d.mac.Write([]byte("True"))
+		} else {
+			d.mac.Write([]byte("False"))
+		}
+	case nil:
+	default:
+		d.mac.Write([]byte(fmt.Sprint(v)))
+	}
+}
+
+// decrypt the value recursively, path is the list of map keys to the value
+// items of a list have the same path as the list
+func (d *decryptor) decrypt(value interface{}, path []string) (interface{}, error) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		for idx, item := range v {
+			decrypted, err := d.decrypt(item.Value, append(path, fmt.Sprint(item.Key)))
+			if err != nil {
+				return nil, err
+			}
+			v[idx].Value = decrypted
+		}
+		return v, nil
+	case []interface{}:
+		for idx, item := range v {
+			decrypted, err := d.decrypt(item, path)
+			if err != nil {
+				return nil, err
+			}
+			v[idx] = decrypted
+		}
+		return v, nil
+	case string:
+		if !strings.HasPrefix(v, "ENC[") {
+			d.addMAC(v, false)
+			return v, nil
+		}
+		decrypted, err := d.decryptValue(v, strings.Join(path, ":")+":")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidValue, strings.Join(path, "."), err)
+		}
+		d.addMAC(decrypted, true)
+		return decrypted, nil
+	default:
+		d.addMAC(value, false)
+		return value, nil
+	}
+}
+
+// decryptValue decrypt ENC[AES256_GCM,data:...,iv:...,tag:...,type:...] value
+func (d *decryptor) decryptValue(value, additionalData string) (interface{}, error) {
+	matches := encryptedPattern.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, errors.New("invalid format")
+	}
+	var decoded [3][]byte
+	for idx := range decoded {
+		b, err := base64.StdEncoding.DecodeString(matches[idx+1])
+		if err != nil {
+			return nil, err
+		}
+		decoded[idx] = b
+	}
+	data, iv, tag := decoded[0], decoded[1], decoded[2]
+	if len(iv) != d.aead.NonceSize() || len(tag) != d.aead.Overhead() {
+		return nil, errors.New("invalid iv or tag")
+	}
+	plaintext, err := d.aead.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(plaintext)
+	switch typ := matches[4]; typ {
+	case "str", "bytes":
+		return s, nil
+	case "int":
+		return strconv.Atoi(s)
+	case "float":
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		return strconv.ParseBool(strings.ToLower(s))
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+}
@@ -0,0 +1,225 @@
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/age"
+	"gopkg.in/yaml.v2"
+)
+
+const testIdentity = "AGE-SECRET-KEY-1QUYQJZSTPSXSURCSZYFPX9Q4ZCT3SXG6RVWP68SLYQSJYGEYY5NQ23P5FC"
+
+// encryptValue encrypt the value in sops format with the data key
+func encryptValue(t *testing.T, dataKey []byte, value, typ, path string) string {
+	t.Helper()
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, 32)
+	rand.Read(iv)
+	sealed := aead.Seal(nil, iv, []byte(value), []byte(path))
+	data, tag := sealed[:len(sealed)-16], sealed[len(sealed)-16:]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data), base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(tag), typ)
+}
+
+// testMAC return the mac of the values in sops format
+func testMAC(values ...string) string {
+	h := sha512.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+	}
+	return fmt.Sprintf("%X", h.Sum(nil))
+}
+
+func TestDecryptYAML(t *testing.T) {
+	identity, err := age.ParseIdentity(testIdentity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+	encryptedKey, err := age.Encrypt(dataKey, identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := strings.Replace(strings.TrimSpace(string(age.Armor(encryptedKey))), "\n", "\n      ", -1)
+
+	content := fmt.Sprintf(`resources:
+  databases:
+  - name: master
+    password: %s
+    max_open: %s
+  ratio: %s
+  enabled: %s
+  plain: value
+hosts:
+- %s
+sops:
+  age:
+  - recipient: %s
+    enc: |
+      %s
+  lastmodified: "2020-01-01T00:00:00Z"
+  mac: %s
+  version: 3.5.0
+`,
+		encryptValue(t, dataKey, "secret", "str", "resources:databases:password:"),
+		encryptValue(t, dataKey, "10", "int", "resources:databases:max_open:"),
+		encryptValue(t, dataKey, "0.5", "float", "resources:ratio:"),
+		encryptValue(t, dataKey, "True", "bool", "resources:enabled:"),
+		encryptValue(t, dataKey, "localhost", "str", "hosts:"),
+		identity.Recipient().String(),
+		enc,
+		// the mac is the sha512 of all values in the order of the file
+		encryptValue(t, dataKey, testMAC("master", "secret", "10", "0.5", "True", "value", "localhost"), "str", "2020-01-01T00:00:00Z"),
+	)
+	if !IsEncrypted([]byte(content)) {
+		t.Fatal("expecting encrypted yaml")
+	}
+
+	out, err := DecryptYAML([]byte(content), identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Resources struct {
+			Databases []struct {
+				Name     string `yaml:"name"`
+				Password string `yaml:"password"`
+				MaxOpen  int    `yaml:"max_open"`
+			} `yaml:"databases"`
+			Ratio   float64 `yaml:"ratio"`
+			Enabled bool    `yaml:"enabled"`
+			Plain   string  `yaml:"plain"`
+		} `yaml:"resources"`
+		Hosts []string               `yaml:"hosts"`
+		Sops  map[string]interface{} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(out, &result); err != nil {
+		t.Fatal(err)
+	}
+	db := result.Resources.Databases[0]
+	if db.Name != "master" || db.Password != "secret" || db.MaxOpen != 10 {
+		t.Fatalf("expecting master database with secret password and 10 max open but got %+v", db)
+	}
+	if result.Resources.Ratio != 0.5 || !result.Resources.Enabled || result.Resources.Plain != "value" {
+		t.Fatalf("expecting decrypted resources but got %+v", result.Resources)
+	}
+	if len(result.Hosts) != 1 || result.Hosts[0] != "localhost" {
+		t.Fatalf("expecting localhost host but got %v", result.Hosts)
+	}
+	if result.Sops != nil {
+		t.Fatalf("expecting sops metadata is removed but got %v", result.Sops)
+	}
+
+	// the value is bound to its path
+	moved := strings.Replace(content, "  plain: value", "  plain: "+encryptValue(t, dataKey, "value", "str", "resources:other:"), 1)
+	if _, err := DecryptYAML([]byte(moved), identity); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidValue, err)
+	}
+
+	// the mac is changed when a value is changed, added or removed
+	changed := strings.Replace(content, "  plain: value", "  plain: other", 1)
+	if _, err := DecryptYAML([]byte(changed), identity); !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidMAC, err)
+	}
+	added := strings.Replace(content, "  plain: value", "  plain: value\n  added: value", 1)
+	if _, err := DecryptYAML([]byte(added), identity); !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidMAC, err)
+	}
+	withoutMAC := regexp.MustCompile(`(?m)^  mac: .*\n`).ReplaceAllString(content, "")
+	if _, err := DecryptYAML([]byte(withoutMAC), identity); !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidMAC, err)
+	}
+
+	other, err := age.ParseIdentity("AGE-SECRET-KEY-1PCGPY9QKRQDPC83QYGJZV2P29SHRQV35XCUR50P7GPPYG3JGFFXQYZM3HQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptYAML([]byte(content), other); err == nil {
+		t.Fatal("expecting error when the identity is not the recipient")
+	}
+}
+
+func TestNotEncrypted(t *testing.T) {
+	content := []byte("resources:\n  plain: value\n")
+	if IsEncrypted(content) {
+		t.Fatal("expecting yaml is not encrypted")
+	}
+	if _, err := DecryptYAML(content); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("expecting error %v but got %v", ErrNotEncrypted, err)
+	}
+}
+
+// TestDecryptCLI decrypt the file encrypted by the sops cli with the age identity of testdata/key.txt
+//
+//	sops --encrypt --age <recipient> secrets.yaml > secrets.enc.yaml
+func TestDecryptCLI(t *testing.T) {
+	key, err := ioutil.ReadFile("testdata/key.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile("testdata/secrets.enc.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := DecryptYAML(content, identities...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `resources:
+  databases:
+  - name: master
+    password: secret
+    max_open: 10
+  ratio: 0.5
+  enabled: true
+  region_unencrypted: ap-southeast-1
+  replica: null
+hosts:
+- localhost
+- 127.0.0.1
+`
+	if string(out) != expect {
+		t.Fatalf("expecting\n%s\nbut got\n%s", expect, out)
+	}
+
+	// the values of a list have the same path, so only the mac detect the swapped values
+	lines := strings.Split(string(content), "\n")
+	for idx, line := range lines {
+		if strings.HasPrefix(line, "hosts:") {
+			lines[idx+1], lines[idx+2] = lines[idx+2], lines[idx+1]
+			break
+		}
+	}
+	swapped := strings.Join(lines, "\n")
+	if _, err := DecryptYAML([]byte(swapped), identities...); !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expecting error %v of swapped values but got %v", ErrInvalidMAC, err)
+	}
+	changed := strings.Replace(string(content), "region_unencrypted: ap-southeast-1", "region_unencrypted: us-east-1", 1)
+	if _, err := DecryptYAML([]byte(changed), identities...); !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expecting error %v of changed unencrypted value but got %v", ErrInvalidMAC, err)
+	}
+}
@@ -0,0 +1,414 @@
+// Package archive move old rows of sql database to object storage, and restore them back
+//
+//	archiver, err := archive.New(db, storage, nil)
+//	results, err := archiver.Archive(ctx, archive.Table{Name: "events", KeyColumn: "id", TimeColumn: "created_at", Retention: time.Hour * 24 * 90})
+//	restored, err := archiver.Restore(ctx, archive.Table{Name: "events", KeyColumn: "id"}, results[0].Key)
+//
+// every batch is selected with FOR UPDATE in a leader transaction, uploaded as gzip ndjson, downloaded back to verify
+// the row count and sha256 checksum, and only then deleted in the same transaction
+// the object key is derived from the rows, so a batch which failed after upload is overwritten by the next run
+//
+// the batches are archived in postgres and mysql only, sqlite and sqlserver doesn't support LIMIT with FOR UPDATE
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/export"
+	"github.com/jmoiron/sqlx"
+)
+
+// list of archive format
+const (
+	// FormatNDJSON is gzip compressed newline delimited json, one object per row
+	FormatNDJSON = export.FormatNDJSON
+)
+
+// default value for options
+const (
+	DefaultPrefix    = "archive"
+	DefaultBatchSize = 1000
+)
+
+// list of metadata of archived object
+const (
+	metadataTable    = "table"
+	metadataFormat   = "format"
+	metadataRows     = "rows"
+	metadataChecksum = "sha256"
+)
+
+// list of error
+var (
+	ErrInvalidName        = errors.New("archive: invalid name")
+	ErrFormatNotSupported = errors.New("archive: format is not supported")
+	ErrDriverNotSupported = errors.New("archive: driver is not supported")
+	ErrRetentionEmpty     = errors.New("archive: retention is empty")
+	ErrVerifyFailed       = errors.New("archive: archived object doesn't match the rows")
+	ErrDeleteMismatch     = errors.New("archive: deleted rows doesn't match the archived rows")
+)
+
+// name allow schema qualified name, the name is not a query parameter so it is checked
+var name = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// unsafeKey is the characters which are replaced in object key
+var unsafeKey = regexp.MustCompile(`[^A-Za-z0-9_.=-]`)
+
+// Table to archive
+type Table struct {
+	Name string
+	// KeyColumn is the unique column to order, delete and restore the rows, for example id
+	KeyColumn string
+	// TimeColumn is the column compared with the retention, for example created_at
+	TimeColumn string
+	// Retention of the rows, rows older than retention are archived
+	Retention time.Duration
+}
+
+func (t Table) validate(archive bool) error {
+	columns := []string{t.Name, t.KeyColumn}
+	if archive {
+		if t.Retention <= 0 {
+			return ErrRetentionEmpty
+		}
+		columns = append(columns, t.TimeColumn)
+	}
+	for _, column := range columns {
+		if !name.MatchString(column) {
+			return fmt.Errorf("%w: %q", ErrInvalidName, column)
+		}
+	}
+	return nil
+}
+
+// Options of archiver
+type Options struct {
+	// Prefix of object key, default to archive
+	Prefix string
+	// BatchSize is the number of rows in one object, default to 1000
+	// the rows are locked until the object is uploaded and verified, so keep the batch small
+	BatchSize int
+	// Format of the object, default to ndjson
+	// parquet is not supported, time is written as microseconds and bytes as string, so the rows cannot be restored as they were
+	Format string
+	// Now is used for the retention, default to time.Now
+	Now func() time.Time
+}
+
+// Result of archived batch
+type Result struct {
+	Key      string `json:"key"`
+	Rows     int    `json:"rows"`
+	Checksum string `json:"sha256"`
+}
+
+// Archiver of sql database
+type Archiver struct {
+	db      *sqldb.DB
+	storage *objectstorage.Storage
+	opts    Options
+}
+
+// New archiver of the database to the storage
+func New(db *sqldb.DB, storage *objectstorage.Storage, options *Options) (*Archiver, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Format == "" {
+		opts.Format = FormatNDJSON
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Format != FormatNDJSON {
+		return nil, fmt.Errorf("%w: %s", ErrFormatNotSupported, opts.Format)
+	}
+
+	a := Archiver{
+		db:      db,
+		storage: storage,
+		opts:    opts,
+	}
+	return &a, nil
+}
+
+// Archive all rows of the table which are older than the retention, batch by batch
+// the archived batches are returned together with the error of the failed batch
+func (a *Archiver) Archive(ctx context.Context, table Table) ([]Result, error) {
+	if err := table.validate(true); err != nil {
+		return nil, err
+	}
+	cutoff := a.opts.Now().Add(-table.Retention)
+
+	var results []Result
+	for {
+		result, err := a.archiveBatch(ctx, table, cutoff)
+		if err != nil {
+			return results, err
+		}
+		if result == nil {
+			return results, nil
+		}
+		results = append(results, *result)
+		if result.Rows < a.opts.BatchSize {
+			return results, nil
+		}
+	}
+}
+
+// ArchiveBatch archive one batch of rows of the table which are older than the retention
+// nil result is returned when there is no row to archive
+func (a *Archiver) ArchiveBatch(ctx context.Context, table Table) (*Result, error) {
+	if err := table.validate(true); err != nil {
+		return nil, err
+	}
+	return a.archiveBatch(ctx, table, a.opts.Now().Add(-table.Retention))
+}
+
+func (a *Archiver) archiveBatch(ctx context.Context, table Table, cutoff time.Time) (*Result, error) {
+	switch driver := sqldb.DriverName(a.db.Leader().DriverName()); driver {
+	case sqldb.DriverPostgres, sqldb.DriverMySQL:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotSupported, driver)
+	}
+
+	var result *Result
+	err := a.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		query := fmt.Sprintf("SELECT * FROM %s WHERE %s < ? ORDER BY %s LIMIT %d FOR UPDATE",
+			table.Name, table.TimeColumn, table.KeyColumn, a.opts.BatchSize)
+		columns, rows, err := selectRows(ctx, tx, tx.Rebind(query), cutoff)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		keyIdx := -1
+		for idx, column := range columns {
+			if column.Name == table.KeyColumn {
+				keyIdx = idx
+			}
+		}
+		if keyIdx < 0 {
+			return fmt.Errorf("%w: key column %s is not selected", ErrInvalidName, table.KeyColumn)
+		}
+
+		first, last := rows[0][keyIdx], rows[len(rows)-1][keyIdx]
+		key := fmt.Sprintf("%s/%s/%s/%s-%s.%s.gz", a.opts.Prefix, table.Name, cutoff.UTC().Format("2006/01/02"),
+			keyPart(first), keyPart(last), a.opts.Format)
+		res, err := a.upload(ctx, table.Name, key, columns, rows)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]interface{}, len(rows))
+		for idx, row := range rows {
+			ids[idx] = row[keyIdx]
+		}
+		query, args, err := sqlx.In(fmt.Sprintf("DELETE FROM %s WHERE %s IN (?)", table.Name, table.KeyColumn), ids)
+		if err != nil {
+			return err
+		}
+		deleted, err := tx.ExecContext(ctx, tx.Rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		if affected, err := deleted.RowsAffected(); err != nil || affected != int64(len(rows)) {
+			return fmt.Errorf("%w: archived %d rows but deleted %d", ErrDeleteMismatch, len(rows), affected)
+		}
+		result = res
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to archive %s: %w", table.Name, err)
+	}
+	return result, nil
+}
+
+// ArchivePartition archive all rows of the partition and drop it, postgres only
+// the partition is locked exclusively until it is dropped, detach it from the parent table first to not block the parent
+func (a *Archiver) ArchivePartition(ctx context.Context, partition string) (*Result, error) {
+	if !name.MatchString(partition) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidName, partition)
+	}
+	if driver := a.db.Leader().DriverName(); driver != sqldb.DriverPostgres {
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotSupported, driver)
+	}
+
+	var result *Result
+	err := a.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", partition)); err != nil {
+			return err
+		}
+		columns, rows, err := selectRows(ctx, tx, fmt.Sprintf("SELECT * FROM %s", partition))
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s/partitions/%s.%s.gz", a.opts.Prefix, partition, a.opts.Format)
+		res, err := a.upload(ctx, partition, key, columns, rows)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", partition)); err != nil {
+			return err
+		}
+		result = res
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to archive partition %s: %w", partition, err)
+	}
+	return result, nil
+}
+
+// upload the rows and verify the uploaded object
+func (a *Archiver) upload(ctx context.Context, table, key string, columns []export.Column, rows [][]interface{}) (*Result, error) {
+	content, checksum, err := encode(columns, rows)
+	if err != nil {
+		return nil, err
+	}
+	_, err = a.storage.UploadByte(ctx, content, key, &objectstorage.WriteOptions{
+		ContentType:     "application/x-ndjson",
+		ContentEncoding: "gzip",
+		Metadata: map[string]string{
+			metadataTable:    table,
+			metadataFormat:   a.opts.Format,
+			metadataRows:     fmt.Sprint(len(rows)),
+			metadataChecksum: checksum,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	if err := a.verify(ctx, key, len(rows), checksum); err != nil {
+		return nil, err
+	}
+	return &Result{Key: key, Rows: len(rows), Checksum: checksum}, nil
+}
+
+// Verify the row count and checksum of the archived object against its metadata
+func (a *Archiver) Verify(ctx context.Context, key string) (*Result, error) {
+	attrs, err := a.storage.Attributes(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to get attributes of %s: %w", key, err)
+	}
+	var rows int
+	if _, err := fmt.Sscan(attrs.Metadata[metadataRows], &rows); err != nil {
+		return nil, fmt.Errorf("%w: invalid rows metadata of %s", ErrVerifyFailed, key)
+	}
+	checksum := attrs.Metadata[metadataChecksum]
+	if err := a.verify(ctx, key, rows, checksum); err != nil {
+		return nil, err
+	}
+	return &Result{Key: key, Rows: rows, Checksum: checksum}, nil
+}
+
+func (a *Archiver) verify(ctx context.Context, key string, rows int, checksum string) error {
+	content, err := a.storage.DownloadByte(ctx, key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	gotRows, gotChecksum, err := inspect(content)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrVerifyFailed, key, err)
+	}
+	if gotRows != rows || gotChecksum != checksum {
+		return fmt.Errorf("%w: %s has %d rows with checksum %s, expect %d rows with checksum %s",
+			ErrVerifyFailed, key, gotRows, gotChecksum, rows, checksum)
+	}
+	return nil
+}
+
+// Restore the rows of archived object to the table, the object is verified before restored
+// rows which key already exist in the table are skipped, so restore can be repeated
+// the number of inserted rows is returned
+func (a *Archiver) Restore(ctx context.Context, table Table, key string) (int, error) {
+	if err := table.validate(false); err != nil {
+		return 0, err
+	}
+	if _, err := a.Verify(ctx, key); err != nil {
+		return 0, err
+	}
+	content, err := a.storage.DownloadByte(ctx, key, nil)
+	if err != nil {
+		return 0, fmt.Errorf("archive: failed to download %s: %w", key, err)
+	}
+	rows, err := decode(content)
+	if err != nil {
+		return 0, fmt.Errorf("archive: failed to decode %s: %w", key, err)
+	}
+
+	driver := a.db.Leader().DriverName()
+	var restored int
+	err = a.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		for _, row := range rows {
+			// empty update skip the existing row
+			query, args, err := sqldb.BuildUpsert(driver, table.Name, []string{table.KeyColumn}, row, &sqldb.UpsertOptions{Update: []string{}})
+			if err != nil {
+				return err
+			}
+			result, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			// mysql count 0 for unchanged duplicate, and 1 for the inserted row
+			if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+				restored++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("archive: failed to restore %s to %s: %w", key, table.Name, err)
+	}
+	return restored, nil
+}
+
+// selectRows return the columns inferred from the database type, and all rows of the query
+func selectRows(ctx context.Context, tx *sqldb.Tx, query string, args ...interface{}) ([]export.Column, [][]interface{}, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columnTypes))
+		dest := make([]interface{}, len(columnTypes))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		result = append(result, values)
+	}
+	return export.InferSchema(columnTypes), result, rows.Err()
+}
+
+// keyPart return the value as part of object key
+func keyPart(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	return strings.Trim(unsafeKey.ReplaceAllString(fmt.Sprint(v), "_"), "_")
+}
@@ -0,0 +1,237 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/export"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	now         = time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+	createdAt   = time.Date(2020, 1, 5, 10, 0, 0, 0, time.UTC)
+	eventsTable = Table{Name: "events", KeyColumn: "id", TimeColumn: "created_at", Retention: time.Hour * 24 * 30}
+)
+
+func newMockArchiver(t *testing.T, options *Options) (*Archiver, sqlmock.Sqlmock) {
+	t.Helper()
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Now: func() time.Time { return now }}
+	if options != nil {
+		opts = *options
+		opts.Now = func() time.Time { return now }
+	}
+	a, err := New(db, objectstorage.New(memory.New("archive")), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, mock
+}
+
+func eventRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "payload", "created_at"}).
+		AddRow(int64(1), "signup", []byte(`{"plan":"pro"}`), createdAt).
+		AddRow(int64(2), "login", []byte{0xff, 0x00}, createdAt)
+}
+
+func TestNew(t *testing.T) {
+	// parquet cannot be restored as the original rows
+	if _, err := New(nil, nil, &Options{Format: export.FormatParquet}); !errors.Is(err, ErrFormatNotSupported) {
+		t.Fatalf("expect error %v but got %v", ErrFormatNotSupported, err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	a, mock := newMockArchiver(t, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM events WHERE created_at < $1 ORDER BY id LIMIT 1000 FOR UPDATE")).
+		WithArgs(now.Add(-eventsTable.Retention)).
+		WillReturnRows(eventRows())
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM events WHERE id IN ($1, $2)")).
+		WithArgs(int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	results, err := a.Archive(context.Background(), eventsTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expect 1 result but got %d", len(results))
+	}
+	if expect := "archive/events/2020/04/05/1-2.ndjson.gz"; results[0].Key != expect || results[0].Rows != 2 {
+		t.Fatalf("unexpected result %+v", results[0])
+	}
+
+	verified, err := a.Verify(context.Background(), results[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*verified, results[0]) {
+		t.Fatalf("expect %+v but got %+v", results[0], *verified)
+	}
+}
+
+func TestArchiveDeleteMismatch(t *testing.T) {
+	a, mock := newMockArchiver(t, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM events")).WillReturnRows(eventRows())
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM events")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	if _, err := a.Archive(context.Background(), eventsTable); !errors.Is(err, ErrDeleteMismatch) {
+		t.Fatalf("expect error %v but got %v", ErrDeleteMismatch, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveNoRows(t *testing.T) {
+	a, mock := newMockArchiver(t, &Options{BatchSize: 10})
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("LIMIT 10 FOR UPDATE")).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	results, err := a.Archive(context.Background(), eventsTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expect no result but got %d", len(results))
+	}
+	if _, err := a.Archive(context.Background(), Table{Name: "events; DROP TABLE users", KeyColumn: "id", TimeColumn: "created_at", Retention: time.Hour}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect error %v but got %v", ErrInvalidName, err)
+	}
+}
+
+func TestArchiveDriverNotSupported(t *testing.T) {
+	for _, driver := range []string{sqldb.DriverSQLite, sqldb.DriverSQLServer} {
+		mockdb, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		leader := sqlx.NewDb(mockdb, driver)
+		db, err := sqldb.Wrap(context.Background(), leader, leader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := New(db, objectstorage.New(memory.New("archive")), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Archive(context.Background(), eventsTable); !errors.Is(err, ErrDriverNotSupported) {
+			t.Errorf("expect error %v for %s but got %v", ErrDriverNotSupported, driver, err)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	a, mock := newMockArchiver(t, nil)
+	columns := []export.Column{
+		{Name: "id", Type: export.TypeInt64},
+		{Name: "name", Type: export.TypeString},
+		{Name: "payload", Type: export.TypeBytes},
+		{Name: "created_at", Type: export.TypeTimestamp},
+	}
+	rows := [][]interface{}{
+		{int64(1), "signup", []byte{0xff, 0x00}, createdAt},
+		{int64(2), "login", nil, createdAt},
+	}
+	result, err := a.upload(context.Background(), "events", "archive/events/1-2.ndjson.gz", columns, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO events (created_at, id, name, payload) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING")).
+		WithArgs("2020-01-05T10:00:00Z", "1", "signup", []byte{0xff, 0x00}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO events")).
+		WithArgs("2020-01-05T10:00:00Z", "2", "login", nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	restored, err := a.Restore(context.Background(), Table{Name: "events", KeyColumn: "id"}, result.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 1 {
+		t.Fatalf("expect 1 restored row but got %d", restored)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyFailed(t *testing.T) {
+	a, _ := newMockArchiver(t, nil)
+	content, _, err := encode([]export.Column{{Name: "id", Type: export.TypeInt64}}, [][]interface{}{{int64(1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.storage.UploadByte(context.Background(), content, "archive/events/1-1.ndjson.gz", &objectstorage.WriteOptions{
+		Metadata: map[string]string{metadataRows: "2", metadataChecksum: "invalid"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Verify(context.Background(), "archive/events/1-1.ndjson.gz"); !errors.Is(err, ErrVerifyFailed) {
+		t.Fatalf("expect error %v but got %v", ErrVerifyFailed, err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	columns := []export.Column{
+		{Name: "id", Type: export.TypeInt64},
+		{Name: "body", Type: export.TypeString},
+		{Name: "blob", Type: export.TypeBytes},
+		{Name: "deleted", Type: export.TypeTimestamp},
+		{Name: "active", Type: export.TypeBool},
+	}
+	rows := [][]interface{}{
+		{int64(10), []byte("text"), []byte("blob"), nil, true},
+	}
+	content, checksum, err := encode(columns, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, inspected, err := inspect(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || inspected != checksum {
+		t.Fatalf("expect 1 row with checksum %s but got %d rows with checksum %s", checksum, count, inspected)
+	}
+
+	decoded, err := decode(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []map[string]interface{}{
+		{"id": "10", "body": "text", "blob": []byte("blob"), "deleted": nil, "active": true},
+	}
+	if !reflect.DeepEqual(decoded, expect) {
+		t.Fatalf("expect %v but got %v", expect, decoded)
+	}
+}
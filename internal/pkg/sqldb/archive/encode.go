@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/export"
+)
+
+// encode the rows as gzip compressed ndjson, the checksum is the sha256 of uncompressed ndjson
+// bytes column is written as base64 object, see export.Base64Key
+func encode(columns []export.Column, rows [][]interface{}) ([]byte, string, error) {
+	var (
+		buff bytes.Buffer
+		hash = sha256.New()
+	)
+	zw := gzip.NewWriter(&buff)
+	nw := export.NewNDJSONWriter(io.MultiWriter(zw, hash), columns)
+	for _, row := range rows {
+		if err := nw.Write(row); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := nw.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buff.Bytes(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// inspect return the number of rows and the checksum of gzip compressed ndjson
+func inspect(content []byte) (int, string, error) {
+	data, err := gunzip(content)
+	if err != nil {
+		return 0, "", err
+	}
+	sum := sha256.Sum256(data)
+	return bytes.Count(data, []byte("\n")), hex.EncodeToString(sum[:]), nil
+}
+
+// decode the rows of gzip compressed ndjson
+// numbers are kept as json.Number, and base64 object is converted back to bytes
+func decode(content []byte) ([]map[string]interface{}, error) {
+	data, err := gunzip(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		row := make(map[string]interface{})
+		if err := dec.Decode(&row); err != nil {
+			return nil, err
+		}
+		for column, v := range row {
+			row[column] = decodeValue(v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+func decodeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case map[string]interface{}:
+		if s, ok := v[export.Base64Key].(string); ok && len(v) == 1 {
+			if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+				return b
+			}
+		}
+	}
+	return v
+}
+
+func gunzip(content []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
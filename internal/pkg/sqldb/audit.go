@@ -0,0 +1,139 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEntry of the write query, the literals of the statement are redacted and the arguments are never recorded
+type AuditEntry struct {
+	// Database is the name of the database of AuditOptions
+	Database string `json:"database,omitempty"`
+	// Actor of the query from the context, see WithActor
+	Actor string `json:"actor"`
+	// Statement of the query sanitized by SanitizeQuery
+	Statement string `json:"statement"`
+	// Args is the number of arguments of the query
+	Args int `json:"args"`
+	// Tx is true when the query run in transaction, the write is only persisted when the transaction is committed
+	Tx bool `json:"tx"`
+	// RowsAffected of exec, -1 when it is unknown
+	RowsAffected int64  `json:"rows_affected"`
+	Error        string `json:"error,omitempty"`
+	// Labels from AuditOptions.Labels, for example the route and the tenant of the request
+	Labels    map[string]string `json:"labels,omitempty"`
+	Duration  time.Duration     `json:"duration"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// AuditSink receive the audit entries, the sink is called in the query path so it should be fast or buffered
+type AuditSink interface {
+	Audit(ctx context.Context, entry AuditEntry) error
+}
+
+// AuditSinkFunc is the function adapter of AuditSink
+type AuditSinkFunc func(ctx context.Context, entry AuditEntry) error
+
+// Audit implements AuditSink
+func (fn AuditSinkFunc) Audit(ctx context.Context, entry AuditEntry) error {
+	return fn(ctx, entry)
+}
+
+// WriterAuditSink return sink which write every entry as a json line to w, the writes are serialized
+func WriterAuditSink(w io.Writer) AuditSink {
+	var mu sync.Mutex
+	return AuditSinkFunc(func(ctx context.Context, entry AuditEntry) error {
+		out, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, err = w.Write(append(out, '\n'))
+		return err
+	})
+}
+
+// AuditOptions of the query audit
+type AuditOptions struct {
+	// Database name of the entries
+	Database string
+	// Sink of the entries, the queries are not audited when nil
+	Sink AuditSink
+	// Actor return who run the query, default to the actor of WithActor
+	Actor func(ctx context.Context) string
+	// Labels return more labels of the entry from the context, for example the route of the request
+	Labels func(ctx context.Context) map[string]string
+	// OnError is called when the sink failed to receive the entry, the query is not failed by the audit
+	OnError func(entry AuditEntry, err error)
+}
+
+type actorKey struct{}
+
+// WithActor return context with the actor of the queries, for example the user id or the service account of the request
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext return the actor of WithActor, empty when it is not set
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Auditor return middleware which record who, what and when of every write query to the sink
+// the write is exec, or query which is not IsReadQuery, for example INSERT ... RETURNING and SELECT ... FOR UPDATE
+// the query is audited after it is run, including the failed query, and the queries in transaction are also audited
+func Auditor(options *AuditOptions) Middleware {
+	opts := AuditOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Actor == nil {
+		opts.Actor = ActorFromContext
+	}
+
+	return func(next QueryFunc) QueryFunc {
+		if opts.Sink == nil {
+			return next
+		}
+		return func(ctx context.Context, q Query) (sql.Result, error) {
+			if !q.Exec && IsReadQuery(q.Query) {
+				return next(ctx, q)
+			}
+
+			start := time.Now()
+			result, err := next(ctx, q)
+			entry := AuditEntry{
+				Database:     opts.Database,
+				Actor:        opts.Actor(ctx),
+				Statement:    SanitizeQuery(q.Query),
+				Args:         len(q.Args),
+				Tx:           q.Tx,
+				RowsAffected: -1,
+				Duration:     time.Since(start),
+				CreatedAt:    start,
+			}
+			if result != nil {
+				if rows, rerr := result.RowsAffected(); rerr == nil {
+					entry.RowsAffected = rows
+				}
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if opts.Labels != nil {
+				entry.Labels = opts.Labels(ctx)
+			}
+			// the sink is called with context which is not canceled, so the write is audited even after the request is done
+			if serr := opts.Sink.Audit(context.Background(), entry); serr != nil && opts.OnError != nil {
+				opts.OnError(entry, serr)
+			}
+			return result, err
+		}
+	}
+}
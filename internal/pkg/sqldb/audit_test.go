@@ -0,0 +1,109 @@
+package sqldb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAuditor(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	var (
+		entries []AuditEntry
+		errSink = errors.New("sink error")
+		failed  []AuditEntry
+	)
+	db.Use(Auditor(&AuditOptions{
+		Database: "billing",
+		Sink: AuditSinkFunc(func(ctx context.Context, entry AuditEntry) error {
+			entries = append(entries, entry)
+			if entry.Actor == "" {
+				return errSink
+			}
+			return nil
+		}),
+		OnError: func(entry AuditEntry, err error) {
+			if errors.Is(err, errSink) {
+				failed = append(failed, entry)
+			}
+		},
+	}))
+
+	ctx := WithActor(context.Background(), "user:1")
+	followerMock.ExpectQuery("SELECT amount FROM invoices").WillReturnRows(sqlmock.NewRows([]string{"amount"}).AddRow(100))
+	leaderMock.ExpectExec("UPDATE invoices").WillReturnResult(sqlmock.NewResult(0, 2))
+	leaderMock.ExpectBegin()
+	leaderMock.ExpectQuery("INSERT INTO invoices").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	leaderMock.ExpectCommit()
+	leaderMock.ExpectExec("DELETE FROM invoices").WillReturnError(errors.New("permission denied"))
+
+	var amount int
+	if err := db.GetContext(ctx, &amount, "SELECT amount FROM invoices WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE invoices SET status = 'paid', amount = 100 WHERE id = $1", 1); err != nil {
+		t.Fatal(err)
+	}
+	err := db.WithTransaction(ctx, func(tx *Tx) error {
+		var id int
+		return tx.GetContext(ctx, &id, "INSERT INTO invoices (amount) VALUES (250) RETURNING id")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM invoices"); err == nil {
+		t.Fatal("expecting error but got nil")
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []AuditEntry{
+		{Database: "billing", Actor: "user:1", Statement: "UPDATE invoices SET status = ?, amount = ? WHERE id = $1", Args: 1, RowsAffected: 2},
+		{Database: "billing", Actor: "user:1", Statement: "INSERT INTO invoices (amount) VALUES (?) RETURNING id", Tx: true, RowsAffected: -1},
+		{Database: "billing", Statement: "DELETE FROM invoices", RowsAffected: -1, Error: "permission denied"},
+	}
+	if len(entries) != len(expect) {
+		t.Fatalf("expecting %d entries but got %+v", len(expect), entries)
+	}
+	for idx, e := range expect {
+		entry := entries[idx]
+		if entry.Database != e.Database || entry.Actor != e.Actor || entry.Statement != e.Statement || entry.Args != e.Args ||
+			entry.Tx != e.Tx || entry.RowsAffected != e.RowsAffected || entry.Error != e.Error {
+			t.Errorf("expecting entry %+v but got %+v", e, entry)
+		}
+		if entry.CreatedAt.IsZero() {
+			t.Errorf("expecting created_at of entry %d", idx)
+		}
+	}
+	if len(failed) != 1 || failed[0].Statement != "DELETE FROM invoices" {
+		t.Fatalf("expecting the failed audit of the delete but got %+v", failed)
+	}
+}
+
+func TestWriterAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := WriterAuditSink(&buf)
+	for _, actor := range []string{"user:1", "user:2"} {
+		if err := sink.Audit(context.Background(), AuditEntry{Actor: actor, Statement: "DELETE FROM invoices"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expecting 2 lines but got %s", buf.String())
+	}
+	entry := AuditEntry{}
+	if err := json.Unmarshal(lines[1], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Actor != "user:2" || entry.Statement != "DELETE FROM invoices" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
@@ -0,0 +1,108 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// list of postgres backend
+const (
+	// BackendPq is github.com/lib/pq, the default backend
+	BackendPq = "pq"
+	// BackendPgx is github.com/jackc/pgx native connection, see pgx.go
+	BackendPgx = "pgx"
+)
+
+// list of backend error
+var (
+	ErrBackendNotSupported = errors.New("sqldb: backend is not supported")
+)
+
+// backend of postgres database which is not the default database/sql driver
+type backend struct {
+	// open the database of the dsn with the password function of new connection, the pool is configured by Connect
+	open func(dsn string, password PasswordFunc) (*sql.DB, error)
+	// isDriver return true when the driver of the database is the driver of the backend
+	isDriver func(d driver.Driver) bool
+	// errorDetail return the detail of the backend error
+	errorDetail func(err error) (ErrorDetail, bool)
+	// sendBatch send the batch in one round trip with the raw driver connection
+	// false is returned when the connection is not the connection of the backend
+	sendBatch func(ctx context.Context, driverConn interface{}, b *Batch) ([]int64, bool, error)
+}
+
+// pgxBackend is set by pgx.go
+var pgxBackend *backend
+
+// ValidateBackend return error if the backend is not supported by the driver
+func ValidateBackend(driver, name string) error {
+	switch name {
+	case "", BackendPq:
+		return nil
+	case BackendPgx:
+		if DriverName(driver) != DriverPostgres {
+			return fmt.Errorf("%w: %s of %s driver", ErrBackendNotSupported, name, driver)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrBackendNotSupported, name)
+}
+
+// ErrorDetail of postgres error, for example the violated constraint of unique_violation
+type ErrorDetail struct {
+	// Code is the SQLSTATE of the error, for example 23505
+	Code       string `json:"code"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Detail     string `json:"detail,omitempty"`
+	Hint       string `json:"hint,omitempty"`
+	Where      string `json:"where,omitempty"`
+	Schema     string `json:"schema,omitempty"`
+	Table      string `json:"table,omitempty"`
+	Column     string `json:"column,omitempty"`
+	DataType   string `json:"data_type,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+	// Position of the error in the query, starts from 1, zero when unknown
+	Position int `json:"position,omitempty"`
+}
+
+// Class return the first two characters of the code, for example 23 of integrity_constraint_violation
+func (d ErrorDetail) Class() string {
+	if len(d.Code) < 2 {
+		return d.Code
+	}
+	return d.Code[:2]
+}
+
+// PostgresError return the detail of the postgres error of pq or pgx backend
+// false is returned when the error is not returned by postgres server
+func PostgresError(err error) (ErrorDetail, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		detail := ErrorDetail{
+			Code:       string(pqErr.Code),
+			Severity:   pqErr.Severity,
+			Message:    pqErr.Message,
+			Detail:     pqErr.Detail,
+			Hint:       pqErr.Hint,
+			Where:      pqErr.Where,
+			Schema:     pqErr.Schema,
+			Table:      pqErr.Table,
+			Column:     pqErr.Column,
+			DataType:   pqErr.DataTypeName,
+			Constraint: pqErr.Constraint,
+		}
+		detail.Position, _ = strconv.Atoi(pqErr.Position)
+		return detail, true
+	}
+	if pgxBackend != nil {
+		return pgxBackend.errorDetail(err)
+	}
+	return ErrorDetail{}, false
+}
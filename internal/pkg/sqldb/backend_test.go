@@ -0,0 +1,75 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestValidateBackend(t *testing.T) {
+	cases := []struct {
+		driver  string
+		backend string
+		err     error
+	}{
+		{DriverPostgres, "", nil},
+		{DriverPostgres, BackendPq, nil},
+		{DriverMySQL, "", nil},
+		{DriverMySQL, BackendPgx, ErrBackendNotSupported},
+		{DriverPostgres, "pgxpool", ErrBackendNotSupported},
+		{DriverPostgres, BackendPgx, nil},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.driver+"/"+c.backend, func(t *testing.T) {
+			if err := ValidateBackend(c.driver, c.backend); !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+		})
+	}
+
+	if _, err := Connect(context.Background(), DriverMySQL, "user:pass@tcp(localhost:3306)/db", &ConnectOptions{Backend: BackendPgx}); !errors.Is(err, ErrBackendNotSupported) {
+		t.Fatalf("expecting error %v but got %v", ErrBackendNotSupported, err)
+	}
+}
+
+func TestPostgresError(t *testing.T) {
+	err := fmt.Errorf("failed to insert: %w", &pq.Error{
+		Code:       "23505",
+		Severity:   "ERROR",
+		Message:    "duplicate key value violates unique constraint",
+		Detail:     "Key (email)=(a@example.com) already exists.",
+		Schema:     "public",
+		Table:      "users",
+		Constraint: "users_email_key",
+		Position:   "12",
+	})
+	detail, ok := PostgresError(err)
+	if !ok {
+		t.Fatal("expecting postgres error")
+	}
+	expect := ErrorDetail{
+		Code:       "23505",
+		Severity:   "ERROR",
+		Message:    "duplicate key value violates unique constraint",
+		Detail:     "Key (email)=(a@example.com) already exists.",
+		Schema:     "public",
+		Table:      "users",
+		Constraint: "users_email_key",
+		Position:   12,
+	}
+	if detail != expect {
+		t.Fatalf("expecting %+v but got %+v", expect, detail)
+	}
+	if detail.Class() != "23" {
+		t.Fatalf("expecting class 23 but got %s", detail.Class())
+	}
+
+	if _, ok := PostgresError(errors.New("connection refused")); ok {
+		t.Fatal("expecting not a postgres error")
+	}
+}
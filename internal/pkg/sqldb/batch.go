@@ -0,0 +1,108 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// list of batch error
+var (
+	ErrBatchEmpty = errors.New("sqldb: batch is empty")
+)
+
+type batchQuery struct {
+	query string
+	args  []interface{}
+}
+
+// Batch of exec queries which are sent to the leader together, see SendBatch
+type Batch struct {
+	queries []batchQuery
+}
+
+// Queue the query to the batch, the query is not sent until SendBatch
+func (b *Batch) Queue(query string, args ...interface{}) {
+	b.queries = append(b.queries, batchQuery{query: query, args: args})
+}
+
+// Len return the number of queued queries
+func (b *Batch) Len() int {
+	return len(b.queries)
+}
+
+// SendBatch send the queries of the batch to the leader and return the rows affected of every query
+// the queries run in one transaction, so all queries are rolled back when one of them is failed
+// pgx backend send all queries in one round trip, other backend send the queries one by one in a transaction
+func (db *DB) SendBatch(ctx context.Context, b *Batch) ([]int64, error) {
+	if b == nil || len(b.queries) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	if pgxBackend != nil && pgxBackend.isDriver(db.Leader().Driver()) {
+		var (
+			affected []int64
+			native   bool
+		)
+		err := db.call(ctx, b.query(), b.args(), true, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			c, err := conn.Conn(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer c.Close()
+			err = c.Raw(func(driverConn interface{}) error {
+				var err error
+				affected, native, err = pgxBackend.sendBatch(ctx, driverConn, b)
+				return err
+			})
+			return nil, err
+		})
+		if native || err != nil {
+			return affected, err
+		}
+	}
+
+	affected := make([]int64, 0, len(b.queries))
+	err := db.WithTransaction(ctx, func(tx *Tx) error {
+		affected = affected[:0]
+		for _, q := range b.queries {
+			result, err := tx.ExecContext(ctx, q.query, q.args...)
+			if err != nil {
+				return err
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			affected = append(affected, rows)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return affected, nil
+}
+
+// query return all queries of the batch for the hooks and trace
+func (b *Batch) query() string {
+	queries := make([]string, len(b.queries))
+	for idx, q := range b.queries {
+		queries[idx] = q.query
+	}
+	return strings.Join(queries, "; ")
+}
+
+// args return the number of arguments of all queries
+func (b *Batch) args() int {
+	var args int
+	for _, q := range b.queries {
+		args += len(q.args)
+	}
+	return args
+}
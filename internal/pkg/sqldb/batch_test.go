@@ -0,0 +1,54 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSendBatch(t *testing.T) {
+	ctx := context.Background()
+	conn, err := Connect(ctx, DriverSQLite, SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.SendBatch(ctx, &Batch{}); !errors.Is(err, ErrBatchEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrBatchEmpty, err)
+	}
+
+	b := &Batch{}
+	b.Queue("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+	b.Queue("INSERT INTO users (id, name) VALUES (?, ?), (?, ?)", 1, "a", 2, "b")
+	b.Queue("UPDATE users SET name = ? WHERE id = ?", "c", 1)
+	if b.Len() != 3 {
+		t.Fatalf("expecting 3 queries but got %d", b.Len())
+	}
+	affected, err := db.SendBatch(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(affected) != 3 || affected[1] != 2 || affected[2] != 1 {
+		t.Fatalf("expecting rows affected [0 2 1] but got %v", affected)
+	}
+
+	// the failed batch roll back all queries
+	b = &Batch{}
+	b.Queue("DELETE FROM users")
+	b.Queue("INSERT INTO users (id) VALUES (?)", 3)
+	if _, err := db.SendBatch(ctx, b); err == nil {
+		t.Fatal("expecting not null error")
+	}
+	var count int
+	if err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expecting 2 rows after rollback but got %d", count)
+	}
+}
@@ -0,0 +1,142 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// list of bulk insert error
+var (
+	ErrBulkColumnsEmpty = errors.New("sqldb: bulk insert columns is empty")
+	ErrBulkRowLength    = errors.New("sqldb: bulk insert row length is not the same as columns")
+)
+
+// DefaultBulkBatchSize is the number of rows of every batch when the batch size is not set
+const DefaultBulkBatchSize = 1000
+
+// maxPlaceholders is the maximum number of placeholders in one statement
+// both postgres and mysql limit the number of parameters of prepared statement to 65535
+const maxPlaceholders = 65535
+
+// maxSQLitePlaceholders is the default SQLITE_MAX_VARIABLE_NUMBER of sqlite before 3.32
+const maxSQLitePlaceholders = 999
+
+// placeholderLimit return the maximum number of placeholders in one statement of the driver
+func placeholderLimit(driver string) int {
+	if driver == DriverSQLite {
+		return maxSQLitePlaceholders
+	}
+	return maxPlaceholders
+}
+
+// BuildBulkInsert return the multi-row insert query and its arguments for the driver
+// every row must have the same length as the columns, and the values are in the order of the columns
+func BuildBulkInsert(driver, table string, columns []string, rows [][]interface{}) (string, []interface{}, error) {
+	if err := validateBulkInsert(driver, table, columns, rows); err != nil {
+		return "", nil, err
+	}
+	if limit := placeholderLimit(DriverName(driver)); len(rows)*len(columns) > limit {
+		return "", nil, fmt.Errorf("sqldb: bulk insert has more than %d values", limit)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for idx, row := range rows {
+		if idx > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for col, value := range row {
+			if col > 0 {
+				b.WriteString(", ")
+			}
+			args = append(args, value)
+			if driver == DriverPostgres {
+				fmt.Fprintf(&b, "$%d", len(args))
+			} else {
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String(), args, nil
+}
+
+// BulkInsert insert the rows to the table in batches of batchSize rows, default to DefaultBulkBatchSize
+// postgres use COPY in a transaction for every batch, and mysql use multi-row INSERT
+// the batch size of multi-row INSERT is reduced when the batch has more values than the database allow
+// the number of inserted rows is returned, batches before the failed batch are kept in the table
+func (db *DB) BulkInsert(ctx context.Context, table string, columns []string, rows [][]interface{}, batchSize int) (int64, error) {
+	if err := validateBulkInsert(db.driver, table, columns, rows); err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBulkBatchSize
+	}
+	if limit := placeholderLimit(db.driver); db.driver != DriverPostgres && batchSize*len(columns) > limit {
+		batchSize = limit / len(columns)
+	}
+
+	var inserted int64
+	for start := 0; start < len(rows); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return inserted, err
+		}
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batch := rows[start:end]
+
+		var err error
+		if db.driver == DriverPostgres {
+			idx := 0
+			_, err = db.copyFrom(ctx, table, columns, func() ([]interface{}, bool, error) {
+				if idx == len(batch) {
+					return nil, false, nil
+				}
+				idx++
+				return batch[idx-1], true, nil
+			})
+		} else {
+			err = db.insertValues(ctx, table, columns, batch)
+		}
+		if err != nil {
+			return inserted, fmt.Errorf("sqldb: failed to bulk insert rows %d to %d: %w", start, end, err)
+		}
+		inserted += int64(len(batch))
+	}
+	return inserted, nil
+}
+
+func (db *DB) insertValues(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	query, args, err := BuildBulkInsert(db.driver, table, columns, rows)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func validateBulkInsert(driver, table string, columns []string, rows [][]interface{}) error {
+	if err := ValidateDriver(driver); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return ErrBulkColumnsEmpty
+	}
+	for _, name := range append([]string{table}, columns...) {
+		if err := ValidateIdentifier(name); err != nil {
+			return err
+		}
+	}
+	for idx, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf("%w: row %d has %d values", ErrBulkRowLength, idx, len(row))
+		}
+	}
+	return nil
+}
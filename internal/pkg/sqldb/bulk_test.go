@@ -0,0 +1,143 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestBuildBulkInsert(t *testing.T) {
+	rows := [][]interface{}{{1, "a"}, {2, "b"}}
+
+	cases := []struct {
+		name    string
+		driver  string
+		table   string
+		columns []string
+		rows    [][]interface{}
+		query   string
+		err     error
+	}{
+		{
+			name:    "postgres",
+			driver:  DriverPostgres,
+			table:   "users",
+			columns: []string{"id", "name"},
+			rows:    rows,
+			query:   "INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4)",
+		},
+		{
+			name:    "mysql",
+			driver:  DriverMySQL,
+			table:   "users",
+			columns: []string{"id", "name"},
+			rows:    rows,
+			query:   "INSERT INTO users (id, name) VALUES (?, ?), (?, ?)",
+		},
+		{
+			name:   "columns empty",
+			driver: DriverMySQL,
+			table:  "users",
+			rows:   rows,
+			err:    ErrBulkColumnsEmpty,
+		},
+		{
+			name:    "invalid table",
+			driver:  DriverMySQL,
+			table:   "users; DROP TABLE users",
+			columns: []string{"id", "name"},
+			rows:    rows,
+			err:     ErrInvalidIdentifier,
+		},
+		{
+			name:    "row length",
+			driver:  DriverMySQL,
+			table:   "users",
+			columns: []string{"id", "name"},
+			rows:    [][]interface{}{{1, "a"}, {2}},
+			err:     ErrBulkRowLength,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query, args, err := BuildBulkInsert(c.driver, c.table, c.columns, c.rows)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if query != c.query {
+				t.Fatalf("expecting query\n%s\nbut got\n%s", c.query, query)
+			}
+			if len(args) != len(c.rows)*len(c.columns) {
+				t.Fatalf("expecting %d args but got %d", len(c.rows)*len(c.columns), len(args))
+			}
+		})
+	}
+}
+
+func TestBulkInsertMySQL(t *testing.T) {
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, DriverMySQL)
+	db, err := Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, name) VALUES (?, ?), (?, ?)")).
+		WithArgs(1, "a", 2, "b").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, name) VALUES (?, ?)")).
+		WithArgs(3, "c").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rows := [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}}
+	inserted, err := db.BulkInsert(context.Background(), "users", []string{"id", "name"}, rows, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 {
+		t.Fatalf("expecting 3 inserted rows but got %d", inserted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBulkInsertPostgres(t *testing.T) {
+	db, mock, followerMock := newMockDB(t)
+
+	copyQuery := regexp.QuoteMeta(`COPY "public"."users" ("id", "name") FROM STDIN`)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(copyQuery)
+	mock.ExpectExec(copyQuery).WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(copyQuery).WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(copyQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectPrepare(copyQuery)
+	mock.ExpectExec(copyQuery).WithArgs(3, "c").WillReturnError(errors.New("invalid input"))
+	mock.ExpectRollback()
+
+	rows := [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}}
+	inserted, err := db.BulkInsert(context.Background(), "public.users", []string{"id", "name"}, rows, 2)
+	if err == nil {
+		t.Fatal("expecting error of the second batch")
+	}
+	if inserted != 2 {
+		t.Fatalf("expecting 2 inserted rows but got %d", inserted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
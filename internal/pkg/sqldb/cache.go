@@ -0,0 +1,285 @@
+package sqldb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+)
+
+// default value of query cache options
+const (
+	DefaultQueryCachePrefix       = "sqlcache:"
+	DefaultQueryCacheLockTimeout  = time.Second * 5
+	DefaultQueryCacheLockWait     = time.Second
+	DefaultQueryCachePollInterval = time.Millisecond * 50
+)
+
+// list of query cache error
+var (
+	ErrCacheKeyEmpty = errors.New("sqldb: cache key is empty")
+	ErrCacheDestType = errors.New("sqldb: cache destination must be a non nil pointer")
+	errCachePanic    = errors.New("sqldb: cached query panicked")
+)
+
+// unlockScript delete the lock only when it is held by the token
+// the lock might be expired and taken by another instance while the query is running
+const unlockScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+// scripter is the redis client which can run lua script, for example redigo.Redigo
+type scripter interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// QueryCacheOptions of the query result cache
+type QueryCacheOptions struct {
+	// Prefix of the keys in redis, default to sqlcache:
+	Prefix string
+	// LockTimeout is the expiry of the lock which is held by the instance which run the query, default to 5 seconds
+	LockTimeout time.Duration
+	// LockWait is the maximum time to wait for the result of the query which run in another instance, default to 1 second
+	// the query is run without the lock after the wait
+	LockWait time.Duration
+	// PollInterval of the cache while waiting for the result of another instance, default to 50ms
+	PollInterval time.Duration
+	// OnError is called when redis failed, the query is run against the database on redis error
+	OnError func(err error)
+}
+
+// queryCache of the query results in redis
+type queryCache struct {
+	redis redis.Redis
+	opts  QueryCacheOptions
+
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+// cacheCall is the query which is running in this instance, the callers of the same key wait for its result
+type cacheCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// SetQueryCache set the redis of CachedSelect before the db is used, the query result cache is disabled when redis is nil
+func (db *DB) SetQueryCache(r redis.Redis, options *QueryCacheOptions) {
+	if r == nil {
+		db.queryCache = nil
+		return
+	}
+	opts := QueryCacheOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultQueryCachePrefix
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = DefaultQueryCacheLockTimeout
+	}
+	if opts.LockWait <= 0 {
+		opts.LockWait = DefaultQueryCacheLockWait
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultQueryCachePollInterval
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+	db.queryCache = &queryCache{
+		redis: r,
+		opts:  opts,
+		calls: make(map[string]*cacheCall),
+	}
+}
+
+// CachedSelect return the cached result of the query in dest, or select the result and cache it for ttl
+// the result is encoded as json, so dest must be encoded and decoded back to the same value, for example a slice of struct
+// the query is run once per key at the same time: the callers in the same instance wait for the running query,
+// and the other instances wait for the result in redis up to LockWait, so an expired key doesn't hit the database many times
+// the query is always run against the database when the cache is not set with SetQueryCache
+//
+//	err := db.CachedSelect(ctx, "products:featured", time.Minute, &products, "SELECT id, name FROM products WHERE featured")
+func (db *DB) CachedSelect(ctx context.Context, key string, ttl time.Duration, dest interface{}, query string, args ...interface{}) error {
+	c := db.queryCache
+	if c == nil {
+		return db.SelectContext(ctx, dest, query, args...)
+	}
+	if key == "" {
+		return ErrCacheKeyEmpty
+	}
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() {
+		return ErrCacheDestType
+	}
+
+	key = c.opts.Prefix + key
+	if data, ok := c.get(ctx, key); ok {
+		if err := json.Unmarshal(data, dest); err == nil {
+			return nil
+		}
+	}
+
+	data, err := c.do(key, func() ([]byte, error) {
+		return c.load(ctx, key, ttl, func() ([]byte, error) {
+			// select into a new value, so the callers which wait for the query don't share dest
+			value := reflect.New(destValue.Elem().Type())
+			if err := db.SelectContext(ctx, value.Interface(), query, args...); err != nil {
+				return nil, err
+			}
+			return json.Marshal(value.Interface())
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// InvalidateCache delete the cached results of the keys
+func (db *DB) InvalidateCache(ctx context.Context, keys ...string) error {
+	c := db.queryCache
+	if c == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if _, err := c.redis.Delete(ctx, c.opts.Prefix+key); err != nil {
+			return fmt.Errorf("sqldb: failed to invalidate cache %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// get the cached result, false is returned when the key doesn't exist or redis failed
+func (c *queryCache) get(ctx context.Context, key string) ([]byte, bool) {
+	value, err := c.redis.Get(ctx, key)
+	if c.redis.IsErrNil(err) {
+		return nil, false
+	}
+	if err != nil {
+		c.opts.OnError(fmt.Errorf("sqldb: failed to get cache %s: %w", key, err))
+		return nil, false
+	}
+	return []byte(value), true
+}
+
+// do run the function once for the key at the same time in this instance
+func (c *queryCache) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	// the error is kept when fn panic, so the waiting callers doesn't get an empty result
+	call := &cacheCall{err: errCachePanic}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		call.wg.Done()
+	}()
+	call.data, call.err = fn()
+	return call.data, call.err
+}
+
+// load the result with the lock of the key, or wait for the result of the instance which hold the lock
+func (c *queryCache) load(ctx context.Context, key string, ttl time.Duration, query func() ([]byte, error)) ([]byte, error) {
+	lockKey := key + ":lock"
+	token := lockToken()
+	locked, err := c.redis.SetNX(ctx, lockKey, token, expirySeconds(c.opts.LockTimeout))
+	if err != nil {
+		c.opts.OnError(fmt.Errorf("sqldb: failed to lock cache %s: %w", key, err))
+	}
+	if err == nil && locked == 0 {
+		if data, ok := c.wait(ctx, key); ok {
+			return data, nil
+		}
+	}
+
+	data, err := query()
+	if err != nil {
+		if locked == 1 {
+			c.unlock(ctx, lockKey, token)
+		}
+		return nil, err
+	}
+	if _, err := c.redis.SetEX(ctx, key, string(data), expirySeconds(ttl)); err != nil {
+		c.opts.OnError(fmt.Errorf("sqldb: failed to set cache %s: %w", key, err))
+	}
+	if locked == 1 {
+		c.unlock(ctx, lockKey, token)
+	}
+	return data, nil
+}
+
+// unlock delete the lock when it is still held by the token
+// the check and delete is not atomic when the redis client can't run lua script
+func (c *queryCache) unlock(ctx context.Context, lockKey, token string) {
+	if sc, ok := c.redis.(scripter); ok {
+		if _, err := sc.Eval(ctx, unlockScript, []string{lockKey}, token); err != nil {
+			c.opts.OnError(fmt.Errorf("sqldb: failed to unlock cache %s: %w", lockKey, err))
+		}
+		return
+	}
+	current, err := c.redis.Get(ctx, lockKey)
+	if err != nil || current != token {
+		return
+	}
+	if _, err := c.redis.Delete(ctx, lockKey); err != nil {
+		c.opts.OnError(fmt.Errorf("sqldb: failed to unlock cache %s: %w", lockKey, err))
+	}
+}
+
+// lockToken return random value of the lock, so only the instance which hold the lock can delete it
+func lockToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("sqldb: failed to generate lock token: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// wait for the result of another instance until LockWait
+func (c *queryCache) wait(ctx context.Context, key string) ([]byte, bool) {
+	timer := time.NewTimer(c.opts.LockWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(c.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-timer.C:
+			return nil, false
+		case <-ticker.C:
+			if data, ok := c.get(ctx, key); ok {
+				return data, true
+			}
+		}
+	}
+}
+
+// expirySeconds of the redis expiry, rounded up so the key always expire
+func expirySeconds(d time.Duration) int {
+	s := int((d + time.Second - 1) / time.Second)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
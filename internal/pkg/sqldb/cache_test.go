@@ -0,0 +1,244 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/redis/redigo"
+	"github.com/alicebob/miniredis/v2"
+)
+
+type cachedProduct struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func newCachedDB(t *testing.T) (*DB, *miniredis.Miniredis, *int64) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := Connect(ctx, DriverSQLite, SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO products VALUES (1, 'book'), (2, 'pen')",
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var queries int64
+	db.AddHook(func(ctx context.Context, event QueryEvent) {
+		atomic.AddInt64(&queries, 1)
+	})
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := redigo.New(ctx, mr.Addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetQueryCache(r, &QueryCacheOptions{LockWait: time.Millisecond * 200, PollInterval: time.Millisecond * 10})
+	return db, mr, &queries
+}
+
+func TestCachedSelect(t *testing.T) {
+	db, mr, queries := newCachedDB(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	var products []cachedProduct
+	if err := db.CachedSelect(ctx, "products", time.Minute, &products, "SELECT id, name FROM products ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || products[1].Name != "pen" {
+		t.Fatalf("unexpected products %+v", products)
+	}
+	if !mr.Exists(DefaultQueryCachePrefix + "products") {
+		t.Fatal("expecting the result is cached")
+	}
+	if ttl := mr.TTL(DefaultQueryCachePrefix + "products"); ttl != time.Minute {
+		t.Fatalf("expecting ttl %s but got %s", time.Minute, ttl)
+	}
+
+	// the cached result is returned without query
+	if _, err := db.ExecContext(ctx, "INSERT INTO products VALUES (3, 'ink')"); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt64(queries)
+	var cached []cachedProduct
+	if err := db.CachedSelect(ctx, "products", time.Minute, &cached, "SELECT id, name FROM products ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 2 || atomic.LoadInt64(queries) != before {
+		t.Fatalf("expecting 2 cached products without query but got %+v", cached)
+	}
+
+	if err := db.InvalidateCache(ctx, "products"); err != nil {
+		t.Fatal(err)
+	}
+	cached = nil
+	if err := db.CachedSelect(ctx, "products", time.Minute, &cached, "SELECT id, name FROM products ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 3 {
+		t.Fatalf("expecting 3 products after invalidation but got %+v", cached)
+	}
+
+	if err := db.CachedSelect(ctx, "", time.Minute, &cached, "SELECT id, name FROM products"); !errors.Is(err, ErrCacheKeyEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrCacheKeyEmpty, err)
+	}
+	if err := db.CachedSelect(ctx, "products", time.Minute, cached, "SELECT id, name FROM products"); !errors.Is(err, ErrCacheDestType) {
+		t.Fatalf("expecting error %v but got %v", ErrCacheDestType, err)
+	}
+	// the query error is not cached
+	if err := db.CachedSelect(ctx, "broken", time.Minute, &cached, "SELECT id FROM missing"); err == nil {
+		t.Fatal("expecting error but got nil")
+	}
+	if mr.Exists(DefaultQueryCachePrefix+"broken") || mr.Exists(DefaultQueryCachePrefix+"broken:lock") {
+		t.Fatal("expecting the failed query is not cached and the lock is released")
+	}
+}
+
+func TestCachedSelectStampede(t *testing.T) {
+	db, mr, queries := newCachedDB(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	before := atomic.LoadInt64(queries)
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var products []cachedProduct
+			if err := db.CachedSelect(ctx, "products", time.Minute, &products, "SELECT id, name FROM products ORDER BY id"); err != nil {
+				errs <- err
+				return
+			}
+			if len(products) != 2 {
+				errs <- errors.New("unexpected products")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if count := atomic.LoadInt64(queries) - before; count != 1 {
+		t.Fatalf("expecting 1 query but got %d", count)
+	}
+
+	// another instance hold the lock, the result of the instance is waited
+	mr.Set(DefaultQueryCachePrefix+"featured:lock", "1")
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		mr.Set(DefaultQueryCachePrefix+"featured", `[{"ID":9,"Name":"other"}]`)
+	}()
+	var products []cachedProduct
+	if err := db.CachedSelect(ctx, "featured", time.Minute, &products, "SELECT id, name FROM products"); err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].ID != 9 {
+		t.Fatalf("expecting the result of another instance but got %+v", products)
+	}
+
+	// the query run after the wait when the other instance never set the result
+	mr.Set(DefaultQueryCachePrefix+"stuck:lock", "1")
+	products = nil
+	if err := db.CachedSelect(ctx, "stuck", time.Minute, &products, "SELECT id, name FROM products"); err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("expecting 2 products but got %+v", products)
+	}
+}
+
+func TestQueryCacheLock(t *testing.T) {
+	db, mr, _ := newCachedDB(t)
+	defer mr.Close()
+	c := db.queryCache
+	ctx := context.Background()
+	key := DefaultQueryCachePrefix + "slow"
+
+	// the lock is expired while the query is running and taken by another instance, the lock of the other instance is kept
+	data, err := c.load(ctx, key, time.Minute, func() ([]byte, error) {
+		mr.Set(key+":lock", "other")
+		return []byte("[]"), nil
+	})
+	if err != nil || string(data) != "[]" {
+		t.Fatalf("expecting [] but got %s, %v", data, err)
+	}
+	if lock, err := mr.Get(key + ":lock"); err != nil || lock != "other" {
+		t.Fatalf("expecting the lock of another instance but got %s, %v", lock, err)
+	}
+
+	// the lock is released by the instance which hold it
+	mr.Del(key + ":lock")
+	if _, err := c.load(ctx, key, time.Minute, func() ([]byte, error) {
+		return nil, errors.New("failed")
+	}); err == nil {
+		t.Fatal("expecting error from the query")
+	}
+	if mr.Exists(key + ":lock") {
+		t.Fatal("expecting the lock is released")
+	}
+}
+
+func TestQueryCacheDoPanic(t *testing.T) {
+	db, mr, _ := newCachedDB(t)
+	defer mr.Close()
+	c := db.queryCache
+
+	func() {
+		defer func() {
+			recover()
+		}()
+		c.do("products", func() ([]byte, error) {
+			panic("boom")
+		})
+	}()
+
+	// the next caller of the key is not blocked by the panicked call
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if data, err := c.do("products", func() ([]byte, error) {
+			return []byte("[]"), nil
+		}); err != nil || string(data) != "[]" {
+			t.Errorf("expecting [] but got %s, %v", data, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expecting the call is not blocked after the panic")
+	}
+}
+
+func TestCachedSelectWithoutCache(t *testing.T) {
+	db, mr, _ := newCachedDB(t)
+	defer mr.Close()
+	db.SetQueryCache(nil, nil)
+
+	var products []cachedProduct
+	if err := db.CachedSelect(context.Background(), "products", time.Minute, &products, "SELECT id, name FROM products"); err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || mr.Exists(DefaultQueryCachePrefix+"products") {
+		t.Fatalf("expecting products without cache but got %+v", products)
+	}
+}
@@ -0,0 +1,131 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/breaker"
+	"github.com/jmoiron/sqlx"
+)
+
+// list of circuit error
+var (
+	ErrCircuitOpen = errors.New("sqldb: circuit is open")
+)
+
+// SetCircuitBreaker set circuit breaker to the leader and every follower, nil remove the circuit breakers
+// query fail with ErrCircuitOpen when the circuit of the leader is open, and follower with open circuit is skipped
+// only connection error is counted as failure unless the options has its own IsFailure, see IsTransient
+// QueryRow is not guarded because the error is only returned by Scan, and statements in transaction are not counted
+// the circuit breaker must be set before the db is used
+func (db *DB) SetCircuitBreaker(options *breaker.Options) {
+	if options == nil {
+		db.leaderBreaker = nil
+		for _, f := range db.followers {
+			f.breaker = nil
+		}
+		return
+	}
+
+	newBreaker := func() *breaker.Breaker {
+		opts := *options
+		if opts.IsFailure == nil {
+			opts.IsFailure = func(err error) bool {
+				return classify(db.driver, err).connection()
+			}
+		}
+		return breaker.New(&opts)
+	}
+	db.leaderBreaker = newBreaker()
+	for _, f := range db.followers {
+		if f.leader {
+			f.breaker = db.leaderBreaker
+			continue
+		}
+		f.breaker = newBreaker()
+	}
+}
+
+// breakerOf return the circuit breaker of the connection, nil when it has no circuit breaker
+func (db *DB) breakerOf(conn *sqlx.DB) *breaker.Breaker {
+	if conn == db.Leader() {
+		return db.leaderBreaker
+	}
+	for _, f := range db.followers {
+		if !f.leader && f.db == conn {
+			return f.breaker
+		}
+	}
+	return nil
+}
+
+// allow return ErrCircuitOpen when the circuit of the connection is open
+func (db *DB) allow(conn *sqlx.DB) (done func(err error), err error) {
+	b := db.breakerOf(conn)
+	if b == nil {
+		return func(error) {}, nil
+	}
+	done, err = b.Allow()
+	if err != nil {
+		return nil, ErrCircuitOpen
+	}
+	return done, nil
+}
+
+// call run the query function in the routed connection, guarded by the circuit breaker and retried with the retry policy
+// read query go to leader when the circuit of the follower is not allowing the query
+// query which is not read only, for example INSERT ... RETURNING, is retried like exec
+// hooks are invoked after every attempt, with rows affected from the result of fn
+func (db *DB) call(ctx context.Context, query string, args int, exec bool, fn func(ctx context.Context, conn *sqlx.DB) (sql.Result, error)) error {
+	write := exec || !IsReadQuery(query)
+	return db.withRetry(ctx, write, func(ctx context.Context) error {
+		leader := db.Leader()
+		conn := leader
+		if !exec {
+			conn = db.route(ctx, query)
+		}
+		done, err := db.allow(conn)
+		if err != nil && conn != leader {
+			conn = leader
+			done, err = db.allow(conn)
+		}
+		if err != nil {
+			return err
+		}
+		ctx, span := db.startSpan(ctx, query, conn == leader)
+		start := time.Now()
+		result, err := fn(ctx, conn)
+		done(err)
+		if conn == leader {
+			db.observeLeader(err)
+		}
+		endSpan(span, err)
+		if len(db.hooks) > 0 {
+			event := QueryEvent{
+				Query:        query,
+				Args:         args,
+				Duration:     time.Since(start),
+				RowsAffected: -1,
+				Leader:       conn == leader,
+				Err:          err,
+			}
+			if result != nil {
+				if rows, rerr := result.RowsAffected(); rerr == nil {
+					event.RowsAffected = rows
+				}
+			}
+			db.runHooks(ctx, event)
+		}
+		return err
+	})
+}
+
+// circuitState return the state of the circuit breaker, empty when there is no circuit breaker
+func circuitState(b *breaker.Breaker) string {
+	if b == nil {
+		return ""
+	}
+	return b.State().String()
+}
@@ -0,0 +1,107 @@
+package sqldb
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/breaker"
+	"github.com/lib/pq"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	now := time.Now()
+
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+	db.SetCircuitBreaker(&breaker.Options{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Second,
+		Now: func() time.Time {
+			return now
+		},
+	})
+	exec := func() error {
+		_, err := db.Exec("UPDATE users SET name = 'a'")
+		return err
+	}
+
+	// query error is not counted as failure
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(&pq.Error{Code: "23505"})
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(&pq.Error{Code: "23505"})
+	exec()
+	exec()
+	if state := db.Stats().LeaderCircuit; state != "closed" {
+		t.Fatalf("expect closed circuit but got %s", state)
+	}
+
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(refused)
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(refused)
+	exec()
+	exec()
+	if err := exec(); err != ErrCircuitOpen {
+		t.Fatalf("expect ErrCircuitOpen but got %v", err)
+	}
+	err := db.WithTransaction(context.Background(), func(tx *Tx) error {
+		return nil
+	})
+	if err != ErrCircuitOpen {
+		t.Fatalf("expect ErrCircuitOpen from transaction but got %v", err)
+	}
+
+	// read query still go to the follower
+	followerMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a"))
+	var name string
+	if err := db.Get(&name, "SELECT name FROM users"); err != nil {
+		t.Fatal(err)
+	}
+
+	// probe is allowed after the open timeout
+	now = now.Add(time.Second)
+	leaderMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := exec(); err != nil {
+		t.Fatal(err)
+	}
+	if state := db.Stats().LeaderCircuit; state != "closed" {
+		t.Fatalf("expect closed circuit but got %s", state)
+	}
+
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCircuitBreakerFollower(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+	db.SetCircuitBreaker(&breaker.Options{FailureThreshold: 1, OpenTimeout: time.Hour})
+
+	followerMock.ExpectQuery("SELECT name").WillReturnError(syscall.ECONNRESET)
+	leaderMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a"))
+
+	var name string
+	if err := db.Get(&name, "SELECT name FROM users"); err == nil {
+		t.Fatal("expect error from follower")
+	}
+	// follower with open circuit is skipped and the query go to leader
+	if err := db.Get(&name, "SELECT name FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if state := db.Stats().Followers[0].Circuit; state != "open" {
+		t.Fatalf("expect open follower circuit but got %s", state)
+	}
+
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// list of clickhouse compression
+const (
+	ClickHouseCompressionNone    = "none"
+	ClickHouseCompressionLZ4     = "lz4"
+	ClickHouseCompressionZSTD    = "zstd"
+	ClickHouseCompressionGzip    = "gzip"
+	ClickHouseCompressionDeflate = "deflate"
+	ClickHouseCompressionBrotli  = "br"
+)
+
+// list of clickhouse error
+var (
+	ErrClickHouseCompressionInvalid = errors.New("sqldb: invalid clickhouse compression")
+)
+
+// ClickHouseOptions of clickhouse connection, the options are set as the parameters of the dsn
+// the dsn is kept as is when the option is empty, so the parameters of the dsn are used
+type ClickHouseOptions struct {
+	// AsyncInsert buffer the inserts in the server and write them in batch, so many small inserts don't create many parts
+	AsyncInsert bool
+	// AsyncInsertNoWait return after the insert is buffered instead of after it is written, the insert error is not returned
+	AsyncInsertNoWait bool
+	// AsyncInsertBusyTimeout is the maximum time the inserts are buffered before they are written, default to the server setting
+	AsyncInsertBusyTimeout time.Duration
+	// Compression of the data, none|lz4|zstd|gzip|deflate|br, gzip, deflate and br are only supported by http protocol
+	Compression string
+	// CompressionLevel of the compression, default to the driver level
+	CompressionLevel int
+}
+
+// ValidateClickHouseCompression return error if the compression is not supported by clickhouse
+func ValidateClickHouseCompression(compression string) error {
+	switch compression {
+	case "", ClickHouseCompressionNone, ClickHouseCompressionLZ4, ClickHouseCompressionZSTD,
+		ClickHouseCompressionGzip, ClickHouseCompressionDeflate, ClickHouseCompressionBrotli:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrClickHouseCompressionInvalid, compression)
+}
+
+// ClickHouseDSN return the clickhouse dsn with the options
+// the unknown parameters of the dsn are sent as the query settings, for example async_insert=1
+func ClickHouseDSN(dsn string, opts ClickHouseOptions) (string, error) {
+	if err := ValidateClickHouseCompression(opts.Compression); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("sqldb: invalid clickhouse dsn: %w", err)
+	}
+	query := u.Query()
+	if opts.AsyncInsert {
+		query.Set("async_insert", "1")
+		query.Set("wait_for_async_insert", "1")
+		if opts.AsyncInsertNoWait {
+			query.Set("wait_for_async_insert", "0")
+		}
+		if opts.AsyncInsertBusyTimeout > 0 {
+			query.Set("async_insert_busy_timeout_ms", strconv.FormatInt(int64(opts.AsyncInsertBusyTimeout/time.Millisecond), 10))
+		}
+	}
+	if opts.Compression != "" {
+		query.Set("compress", opts.Compression)
+	}
+	if opts.CompressionLevel > 0 {
+		query.Set("compress_level", strconv.Itoa(opts.CompressionLevel))
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// validateClickHouseDSN check the dsn is a url of clickhouse native or http protocol
+func validateClickHouseDSN(dsn string) error {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return fmt.Errorf("sqldb: invalid clickhouse dsn: %w", err)
+	}
+	switch u.Scheme {
+	case "clickhouse", "tcp", "http", "https":
+	default:
+		return fmt.Errorf("sqldb: invalid clickhouse dsn: expecting clickhouse, tcp, http or https scheme but got %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("sqldb: invalid clickhouse dsn: %w", ErrDSNHostEmpty)
+	}
+	return nil
+}
+
+// buildClickHouseDSN return the clickhouse dsn of native protocol
+func buildClickHouseDSN(config DSNConfig) (string, error) {
+	if config.Host[0] == '/' {
+		return "", fmt.Errorf("%w: unix socket of %s", ErrDriverNotSupported, DriverClickHouse)
+	}
+	u := url.URL{Scheme: "clickhouse", Host: config.Host}
+	if config.Port > 0 {
+		u.Host = net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	}
+	if config.User != "" {
+		u.User = url.UserPassword(config.User, config.Password)
+	}
+	if config.DBName != "" {
+		u.Path = "/" + config.DBName
+	}
+	query := url.Values{}
+	for key, value := range config.Params {
+		query.Set(key, value)
+	}
+	switch config.SSLMode {
+	case SSLModeRequire:
+		query.Set("secure", "true")
+		query.Set("skip_verify", "true")
+	case SSLModeVerifyCA, SSLModeVerifyFull:
+		query.Set("secure", "true")
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// withClickHouseTLS return the dsn which connect with tls, the driver verify the server with the system ca
+func withClickHouseTLS(dsn string, config TLSConfig) (string, error) {
+	if config.CAFile != "" || config.CertFile != "" || config.ServerName != "" {
+		return "", fmt.Errorf("%w: tls ca_file, client certificate and server_name of %s", ErrDriverNotSupported, DriverClickHouse)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("sqldb: invalid clickhouse dsn: %w", err)
+	}
+	query := u.Query()
+	query.Set("secure", "true")
+	if config.InsecureSkipVerify {
+		query.Set("skip_verify", "true")
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
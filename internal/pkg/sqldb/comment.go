@@ -0,0 +1,155 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sort"
+	"strings"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"go.opencensus.io/trace"
+)
+
+// HeaderRequestID is the default header of the request id in the comment tags
+const HeaderRequestID = "X-Request-Id"
+
+// list of comment tag
+const (
+	CommentTagService     = "application"
+	CommentTagRoute       = "route"
+	CommentTagRequestID   = "request_id"
+	CommentTagDriver      = "db_driver"
+	CommentTagTraceparent = "traceparent"
+)
+
+// CommentOptions of the query comment
+type CommentOptions struct {
+	// Service is the application tag of every query, for example the name of the program
+	Service string
+	// Tags return more tags of the query from the context, the tags of WithCommentTags are kept when the key is the same
+	Tags func(ctx context.Context) map[string]string
+}
+
+type commentTagsKey struct{}
+
+// WithCommentTags return context with the tags of the query comment, for example the route and the request id
+// the tags of the parent context are kept unless they are set again
+func WithCommentTags(ctx context.Context, tags map[string]string) context.Context {
+	parent, _ := ctx.Value(commentTagsKey{}).(map[string]string)
+	merged := make(map[string]string, len(parent)+len(tags))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, commentTagsKey{}, merged)
+}
+
+// CommentTags set the route and the request id of the request as the tags of the query comment
+// empty header default to X-Request-Id, the request id tag is not set when the request has no request id
+//
+//	r.Use(sqldb.CommentTags(""))
+func CommentTags(requestIDHeader string) router.MiddlewareFunc {
+	if requestIDHeader == "" {
+		requestIDHeader = HeaderRequestID
+	}
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			tags := map[string]string{CommentTagRoute: rctx.RequestHandler()}
+			if id := rctx.RequestHeader().Get(requestIDHeader); id != "" {
+				tags[CommentTagRequestID] = id
+			}
+			rctx.SetContext(WithCommentTags(rctx.Context(), tags))
+			return next(rctx)
+		}
+	}
+}
+
+// Commenter return middleware which append sqlcommenter comment to the query, so the slow query log of the database
+// can be traced back to the service, route and request. the trace of the query is in the traceparent tag
+//
+//	SELECT * FROM users WHERE id = $1 /*application='project',request_id='a1b2',route='%2Fusers',traceparent='00-...-01'*/
+//
+// the query with comment is not cached by the prepared statement cache, as the comment is different for every request
+func Commenter(driverName string, options *CommentOptions) Middleware {
+	opts := CommentOptions{}
+	if options != nil {
+		opts = *options
+	}
+
+	return func(next QueryFunc) QueryFunc {
+		return func(ctx context.Context, q Query) (sql.Result, error) {
+			tags := map[string]string{CommentTagDriver: DriverName(driverName)}
+			if opts.Service != "" {
+				tags[CommentTagService] = opts.Service
+			}
+			if opts.Tags != nil {
+				for k, v := range opts.Tags(ctx) {
+					tags[k] = v
+				}
+			}
+			if ctxTags, ok := ctx.Value(commentTagsKey{}).(map[string]string); ok {
+				for k, v := range ctxTags {
+					tags[k] = v
+				}
+			}
+			if span := trace.FromContext(ctx); span != nil {
+				tags[CommentTagTraceparent] = traceparent(span.SpanContext())
+			}
+			q.Query = AppendComment(q.Query, tags)
+			return next(ctx, q)
+		}
+	}
+}
+
+// AppendComment append the tags to the query as sqlcommenter comment, the keys are sorted and the values are url encoded
+// so the quote, the placeholder and the named parameter are never in the comment
+// the query which already has a comment at the end is not changed, as the comment might be a hint of the database
+func AppendComment(query string, tags map[string]string) string {
+	if len(tags) == 0 || hasComment(query) {
+		return query
+	}
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return query
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.Grow(len(query) + 128)
+	b.WriteString(strings.TrimRight(query, " \t\n\r;"))
+	b.WriteString(" /*")
+	for idx, k := range keys {
+		if idx > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(url.QueryEscape(k))
+		b.WriteString("='")
+		b.WriteString(url.QueryEscape(tags[k]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	return b.String()
+}
+
+// hasComment return true when the query end with comment
+func hasComment(query string) bool {
+	return strings.HasSuffix(strings.TrimRight(query, " \t\n\r;"), "*/")
+}
+
+// traceparent return the w3c trace context of the span, for example 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func traceparent(sc trace.SpanContext) string {
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
@@ -0,0 +1,94 @@
+package sqldb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"go.opencensus.io/trace"
+)
+
+func TestAppendComment(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		tags   map[string]string
+		expect string
+	}{
+		{
+			name:   "sorted and encoded",
+			query:  "SELECT * FROM users WHERE id = $1;",
+			tags:   map[string]string{"route": "/users/{id}", "application": "project", "request_id": "it's:1?"},
+			expect: "SELECT * FROM users WHERE id = $1 /*application='project',request_id='it%27s%3A1%3F',route='%2Fusers%2F%7Bid%7D'*/",
+		},
+		{
+			name:   "empty tag",
+			query:  "SELECT 1",
+			tags:   map[string]string{"route": ""},
+			expect: "SELECT 1",
+		},
+		{
+			name:   "existing comment",
+			query:  "SELECT * FROM users /*+ IndexScan(users) */",
+			tags:   map[string]string{"route": "/users"},
+			expect: "SELECT * FROM users /*+ IndexScan(users) */",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if out := AppendComment(c.query, c.tags); out != c.expect {
+				t.Fatalf("expecting %s but got %s", c.expect, out)
+			}
+		})
+	}
+}
+
+func TestCommenter(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+	db.Use(Commenter(DriverPostgres, &CommentOptions{
+		Service: "project",
+		Tags: func(ctx context.Context) map[string]string {
+			return map[string]string{CommentTagRoute: "default"}
+		},
+	}))
+
+	ctx := context.Background()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	handler := CommentTags("")(func(rctx *requestcontext.RequestContext) error {
+		ctx = rctx.Context()
+		return nil
+	})
+	handler(requestcontext.New(requestcontext.Constructor{HTTPResponseWriter: rec, HTTPRequest: req, Path: "/users"}))
+	ctx, span := trace.StartSpan(ctx, "request", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	sc := span.SpanContext()
+
+	followerMock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users /*application='project',db_driver='postgres',request_id='req-1',route='%2Fusers',traceparent='00-" +
+		sc.TraceID.String() + "-" + sc.SpanID.String() + "-01'*/")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	leaderMock.ExpectExec(regexp.QuoteMeta("DELETE FROM sessions /*application='project',db_driver='postgres',route='default'*/")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var ids []int
+	if err := db.SelectContext(ctx, &ids, "SELECT id FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	// the tags of the options are used without request
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM sessions"); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
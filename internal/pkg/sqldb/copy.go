@@ -0,0 +1,198 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// list of copy error
+var (
+	ErrCopyNotSupported = errors.New("sqldb: copy is only supported by postgres")
+)
+
+// CopyOptions of csv in CopyFromReader and CopyTo
+type CopyOptions struct {
+	// Header is true when the first line of the csv is the column names
+	// CopyFromReader skip the header, and CopyTo write the column names of the query
+	Header bool
+	// Comma is the field delimiter, default to ,
+	Comma rune
+	// Null is the value of NULL, for example \N. no value is NULL when it is empty
+	Null string
+}
+
+func (opts *CopyOptions) comma() rune {
+	if opts.Comma == 0 {
+		return ','
+	}
+	return opts.Comma
+}
+
+// CopyFrom copy the rows from the channel to the table with postgres COPY FROM STDIN
+// the rows are copied in one transaction, which is committed when the channel is closed
+// and rolled back when a row is failed or the context is done. the number of copied rows is returned
+func (db *DB) CopyFrom(ctx context.Context, table string, columns []string, rows <-chan []interface{}) (int64, error) {
+	return db.copyFrom(ctx, table, columns, func() ([]interface{}, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case row, ok := <-rows:
+			return row, ok, nil
+		}
+	})
+}
+
+// CopyFromReader copy the csv rows of the reader to the table with postgres COPY FROM STDIN
+// the values of the csv are in the order of the columns, and the rows are copied in one transaction
+func (db *DB) CopyFromReader(ctx context.Context, table string, columns []string, r io.Reader, options *CopyOptions) (int64, error) {
+	opts := CopyOptions{}
+	if options != nil {
+		opts = *options
+	}
+	reader := csv.NewReader(r)
+	reader.Comma = opts.comma()
+	reader.FieldsPerRecord = len(columns)
+	reader.ReuseRecord = true
+	if opts.Header {
+		if _, err := reader.Read(); err != nil && err != io.EOF {
+			return 0, fmt.Errorf("sqldb: failed to read csv header: %w", err)
+		}
+	}
+
+	return db.copyFrom(ctx, table, columns, func() ([]interface{}, bool, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("sqldb: failed to read csv: %w", err)
+		}
+		row := make([]interface{}, len(record))
+		for idx, value := range record {
+			if opts.Null != "" && value == opts.Null {
+				continue
+			}
+			row[idx] = value
+		}
+		return row, true, nil
+	})
+}
+
+// copyFrom copy the rows returned by next until it return false
+func (db *DB) copyFrom(ctx context.Context, table string, columns []string, next func() ([]interface{}, bool, error)) (int64, error) {
+	if db.driver != DriverPostgres {
+		return 0, fmt.Errorf("%w: %s", ErrCopyNotSupported, db.driver)
+	}
+	// COPY FROM STDIN is sent with the copy protocol of lib/pq
+	if pgxBackend != nil && pgxBackend.isDriver(db.Leader().Driver()) {
+		return 0, fmt.Errorf("%w: %s backend", ErrCopyNotSupported, BackendPgx)
+	}
+	if err := validateBulkInsert(db.driver, table, columns, nil); err != nil {
+		return 0, err
+	}
+	query := pq.CopyIn(table, columns...)
+	if idx := strings.Index(table, "."); idx >= 0 {
+		query = pq.CopyInSchema(table[:idx], table[idx+1:], columns...)
+	}
+
+	var copied int64
+	err := db.WithTransaction(ctx, func(tx *Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for {
+			row, ok, err := next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if len(row) != len(columns) {
+				return fmt.Errorf("%w: row %d has %d values", ErrBulkRowLength, copied, len(row))
+			}
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				return err
+			}
+			copied++
+		}
+		// exec without arguments flush the buffered rows
+		_, err = stmt.ExecContext(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("sqldb: failed to copy to %s: %w", table, err)
+	}
+	return copied, nil
+}
+
+// CopyTo write the rows of the query to the writer as csv, and return the number of written rows
+// lib/pq does not support COPY TO STDOUT, so the rows are streamed from the query and routed like QueryContext
+// the values are written in the text format of the driver, and time is formatted in RFC3339
+func (db *DB) CopyTo(ctx context.Context, w io.Writer, options *CopyOptions, query string, args ...interface{}) (int64, error) {
+	opts := CopyOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if db.driver != DriverPostgres {
+		return 0, fmt.Errorf("%w: %s", ErrCopyNotSupported, db.driver)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma()
+	if opts.Header {
+		if err := writer.Write(columns); err != nil {
+			return 0, err
+		}
+	}
+
+	var (
+		written int64
+		values  = make([]sql.RawBytes, len(columns))
+		dest    = make([]interface{}, len(columns))
+		record  = make([]string, len(columns))
+	)
+	for idx := range values {
+		dest[idx] = &values[idx]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return written, err
+		}
+		for idx, value := range values {
+			if value == nil {
+				record[idx] = opts.Null
+				continue
+			}
+			record[idx] = string(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return written, err
+	}
+	writer.Flush()
+	return written, writer.Error()
+}
@@ -0,0 +1,130 @@
+package sqldb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestCopyFrom(t *testing.T) {
+	db, mock, _ := newMockDB(t)
+
+	copyQuery := regexp.QuoteMeta(`COPY "events" ("id", "name") FROM STDIN`)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(copyQuery)
+	mock.ExpectExec(copyQuery).WithArgs(1, "signup").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(copyQuery).WithArgs(2, "login").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(copyQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	rows := make(chan []interface{})
+	go func() {
+		defer close(rows)
+		rows <- []interface{}{1, "signup"}
+		rows <- []interface{}{2, "login"}
+	}()
+	copied, err := db.CopyFrom(context.Background(), "events", []string{"id", "name"}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 2 {
+		t.Fatalf("expecting 2 copied rows but got %d", copied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopyFromReader(t *testing.T) {
+	db, mock, _ := newMockDB(t)
+
+	copyQuery := regexp.QuoteMeta(`COPY "events" ("id", "name") FROM STDIN`)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(copyQuery)
+	mock.ExpectExec(copyQuery).WithArgs("1", "signup").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(copyQuery).WithArgs("2", nil).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(copyQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	csv := "id;name\n1;signup\n2;\\N\n"
+	copied, err := db.CopyFromReader(context.Background(), "events", []string{"id", "name"}, strings.NewReader(csv), &CopyOptions{
+		Header: true,
+		Comma:  ';',
+		Null:   `\N`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 2 {
+		t.Fatalf("expecting 2 copied rows but got %d", copied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopyFromReaderInvalidCSV(t *testing.T) {
+	db, mock, _ := newMockDB(t)
+
+	copyQuery := regexp.QuoteMeta(`COPY "events" ("id", "name") FROM STDIN`)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(copyQuery)
+	mock.ExpectExec(copyQuery).WithArgs("1", "signup").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if _, err := db.CopyFromReader(context.Background(), "events", []string{"id", "name"}, strings.NewReader("1,signup\n2\n"), nil); err == nil {
+		t.Fatal("expecting error of row with missing column")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	followerMock.ExpectQuery(regexp.QuoteMeta("SELECT id, name FROM events WHERE id > $1")).WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "signup").AddRow(2, nil))
+
+	var buf bytes.Buffer
+	written, err := db.CopyTo(context.Background(), &buf, &CopyOptions{Header: true, Null: `\N`}, "SELECT id, name FROM events WHERE id > $1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 2 {
+		t.Fatalf("expecting 2 written rows but got %d", written)
+	}
+	expect := "id,name\n1,signup\n2,\\N\n"
+	if buf.String() != expect {
+		t.Fatalf("expecting csv\n%s\nbut got\n%s", expect, buf.String())
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopyNotSupported(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, DriverMySQL)
+	db, err := Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CopyFromReader(context.Background(), "events", []string{"id"}, strings.NewReader("1\n"), nil); !errors.Is(err, ErrCopyNotSupported) {
+		t.Fatalf("expecting error %v but got %v", ErrCopyNotSupported, err)
+	}
+	if _, err := db.CopyTo(context.Background(), &bytes.Buffer{}, nil, "SELECT id FROM events"); !errors.Is(err, ErrCopyNotSupported) {
+		t.Fatalf("expecting error %v but got %v", ErrCopyNotSupported, err)
+	}
+}
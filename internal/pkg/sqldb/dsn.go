@@ -0,0 +1,205 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// list of supported driver
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite3"
+	// DriverClickHouse is registered by the driver package, for example github.com/ClickHouse/clickhouse-go/v2
+	DriverClickHouse = "clickhouse"
+	// DriverSQLServer is registered by the driver package, for example github.com/microsoft/go-mssqldb
+	DriverSQLServer = "sqlserver"
+)
+
+// driverAliases is the other name of the supported driver
+var driverAliases = map[string]string{
+	"sqlite": DriverSQLite,
+	"mssql":  DriverSQLServer,
+}
+
+// DriverName return the registered name of the driver, for example sqlite3 of sqlite
+func DriverName(driver string) string {
+	if name, ok := driverAliases[driver]; ok {
+		return name
+	}
+	return driver
+}
+
+// list of dsn error
+var (
+	ErrDriverNotSupported = errors.New("sqldb: driver is not supported")
+	ErrDSNEmpty           = errors.New("sqldb: dsn is empty")
+	ErrDSNHostEmpty       = errors.New("sqldb: dsn host is empty")
+	ErrSSLModeInvalid     = errors.New("sqldb: invalid ssl mode")
+)
+
+// list of ssl mode, the same as postgres sslmode
+const (
+	SSLModeDisable    = "disable"
+	SSLModeRequire    = "require"
+	SSLModeVerifyCA   = "verify-ca"
+	SSLModeVerifyFull = "verify-full"
+)
+
+// ValidateDriver return error if driver is not supported by sqldb
+func ValidateDriver(driver string) error {
+	switch DriverName(driver) {
+	case DriverPostgres, DriverMySQL, DriverSQLite, DriverClickHouse, DriverSQLServer:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrDriverNotSupported, driver)
+}
+
+// ValidateDSN check the syntax of dsn based on the driver without connecting to the database
+func ValidateDSN(driver, dsn string) error {
+	if err := ValidateDriver(driver); err != nil {
+		return err
+	}
+	if strings.TrimSpace(dsn) == "" {
+		return ErrDSNEmpty
+	}
+
+	switch DriverName(driver) {
+	case DriverMySQL:
+		if _, err := mysql.ParseDSN(dsn); err != nil {
+			return fmt.Errorf("sqldb: invalid mysql dsn: %w", err)
+		}
+
+	case DriverClickHouse:
+		return validateClickHouseDSN(dsn)
+
+	case DriverSQLServer:
+		return validateSQLServerDSN(dsn)
+
+	case DriverPostgres:
+		// postgres accept both url and key=value connection string
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			if _, err := pq.ParseURL(dsn); err != nil {
+				return fmt.Errorf("sqldb: invalid postgres dsn: %w", err)
+			}
+			return nil
+		}
+		for _, kv := range strings.Fields(dsn) {
+			if idx := strings.Index(kv, "="); idx <= 0 {
+				return fmt.Errorf("sqldb: invalid postgres dsn: expecting key=value but got %s", kv)
+			}
+		}
+	}
+	return nil
+}
+
+// DSNConfig is the structured configuration of dsn, the dsn is built per driver with BuildDSN
+type DSNConfig struct {
+	// Host of the database, or the directory of unix socket when it start with /
+	Host string
+	// Port of the database, default to the driver default port
+	Port     int
+	User     string
+	Password string
+	DBName   string
+	// SSLMode is disable|require|verify-ca|verify-full, default to the driver default
+	// in mysql, require skip the certificate verification, and verify-ca and verify-full verify the certificate
+	SSLMode string
+	// Params is the extra driver parameters, for example connect_timeout in postgres or parseTime in mysql
+	Params map[string]string
+}
+
+// BuildDSN return the dsn of the driver, every value is escaped for the driver
+// postgres dsn use the key=value format, mysql dsn use the go-sql-driver format and clickhouse dsn use the native protocol url
+// sqlserver dsn use the sqlserver url with the database parameter
+// sqlite has no host, so its dsn is not built, for example use :memory: or file:local.db as the dsn
+func BuildDSN(driver string, config DSNConfig) (string, error) {
+	if err := ValidateDriver(driver); err != nil {
+		return "", err
+	}
+	driver = DriverName(driver)
+	if driver == DriverSQLite {
+		return "", fmt.Errorf("%w: structured dsn of %s", ErrDriverNotSupported, driver)
+	}
+	if config.Host == "" {
+		return "", ErrDSNHostEmpty
+	}
+	switch config.SSLMode {
+	case "", SSLModeDisable, SSLModeRequire, SSLModeVerifyCA, SSLModeVerifyFull:
+	default:
+		return "", fmt.Errorf("%w: %s", ErrSSLModeInvalid, config.SSLMode)
+	}
+
+	if driver == DriverClickHouse {
+		return buildClickHouseDSN(config)
+	}
+	if driver == DriverSQLServer {
+		return buildSQLServerDSN(config)
+	}
+	if driver == DriverMySQL {
+		cfg := mysql.NewConfig()
+		cfg.User = config.User
+		cfg.Passwd = config.Password
+		cfg.DBName = config.DBName
+		cfg.Net = "tcp"
+		cfg.Addr = config.Host
+		if strings.HasPrefix(config.Host, "/") {
+			cfg.Net = "unix"
+		} else if config.Port > 0 {
+			cfg.Addr = net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+		}
+		switch config.SSLMode {
+		case SSLModeRequire:
+			cfg.TLSConfig = "skip-verify"
+		case SSLModeVerifyCA, SSLModeVerifyFull:
+			cfg.TLSConfig = "true"
+		}
+		if len(config.Params) > 0 {
+			cfg.Params = make(map[string]string, len(config.Params))
+			for key, value := range config.Params {
+				cfg.Params[key] = value
+			}
+		}
+		return cfg.FormatDSN(), nil
+	}
+
+	params := []struct{ key, value string }{
+		{"host", config.Host},
+		{"user", config.User},
+		{"password", config.Password},
+		{"dbname", config.DBName},
+		{"sslmode", config.SSLMode},
+	}
+	if config.Port > 0 {
+		params = append(params, struct{ key, value string }{"port", strconv.Itoa(config.Port)})
+	}
+	keys := make([]string, 0, len(config.Params))
+	for key := range config.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		params = append(params, struct{ key, value string }{key, config.Params[key]})
+	}
+
+	var kvs []string
+	for _, param := range params {
+		if param.value != "" {
+			kvs = append(kvs, param.key+"="+quotePostgres(param.value))
+		}
+	}
+	return strings.Join(kvs, " "), nil
+}
+
+// quotePostgres quote the value of postgres key=value connection string
+func quotePostgres(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
@@ -0,0 +1,104 @@
+package sqldb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestBuildDSN(t *testing.T) {
+	cases := []struct {
+		name   string
+		driver string
+		config DSNConfig
+		expect string
+		err    error
+	}{
+		{
+			name:   "postgres",
+			driver: DriverPostgres,
+			config: DSNConfig{
+				Host:     "localhost",
+				Port:     5432,
+				User:     "example",
+				Password: `it's a se\cret`,
+				DBName:   "example",
+				SSLMode:  SSLModeDisable,
+				Params:   map[string]string{"connect_timeout": "5", "application_name": "example"},
+			},
+			expect: `host='localhost' user='example' password='it\'s a se\\cret' dbname='example' sslmode='disable' port='5432' application_name='example' connect_timeout='5'`,
+		},
+		{
+			name:   "postgres without optional fields",
+			driver: DriverPostgres,
+			config: DSNConfig{Host: "localhost", User: "example"},
+			expect: `host='localhost' user='example'`,
+		},
+		{
+			name:   "mysql",
+			driver: DriverMySQL,
+			config: DSNConfig{
+				Host:     "localhost",
+				Port:     3306,
+				User:     "example",
+				Password: "p@ss:word/",
+				DBName:   "example",
+				SSLMode:  SSLModeRequire,
+				Params:   map[string]string{"charset": "utf8mb4"},
+			},
+			expect: "example:p@ss:word/@tcp(localhost:3306)/example?tls=skip-verify&charset=utf8mb4",
+		},
+		{
+			name:   "mysql unix socket",
+			driver: DriverMySQL,
+			config: DSNConfig{Host: "/var/run/mysqld/mysqld.sock", User: "example", DBName: "example"},
+			expect: "example@unix(/var/run/mysqld/mysqld.sock)/example",
+		},
+		{
+			name:   "empty host",
+			driver: DriverPostgres,
+			config: DSNConfig{User: "example"},
+			err:    ErrDSNHostEmpty,
+		},
+		{
+			name:   "invalid ssl mode",
+			driver: DriverMySQL,
+			config: DSNConfig{Host: "localhost", SSLMode: "prefer"},
+			err:    ErrSSLModeInvalid,
+		},
+		{
+			name:   "driver not supported",
+			driver: "sqlite3",
+			config: DSNConfig{Host: "localhost"},
+			err:    ErrDriverNotSupported,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dsn, err := BuildDSN(c.driver, c.config)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+			if dsn != c.expect {
+				t.Fatalf("expect dsn\n%s\nbut got\n%s", c.expect, dsn)
+			}
+		})
+	}
+}
+
+func TestBuildDSNMySQLParse(t *testing.T) {
+	config := DSNConfig{Host: "localhost", Port: 3306, User: "example", Password: "p@ss:word/", DBName: "example"}
+	dsn, err := BuildDSN(DriverMySQL, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Passwd != config.Password || parsed.Addr != "localhost:3306" || parsed.DBName != config.DBName {
+		t.Fatalf("unexpected parsed dsn %+v", parsed)
+	}
+}
@@ -0,0 +1,350 @@
+// Package export stream query results as ndjson or parquet, to any writer or to object storage
+//
+//	result, err := export.Export(ctx, db, storage, "exports/orders.parquet", export.FormatParquet, nil,
+//		"SELECT id, total, created_at FROM orders WHERE created_at >= $1", since)
+//
+// the schema is inferred from the database type of the columns, or set explicitly with Options.Columns
+// rows are written as they are read, only the current parquet row group is kept in memory
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// list of export format
+const (
+	// FormatNDJSON is newline delimited json, one object per row
+	FormatNDJSON = "ndjson"
+	// FormatParquet is uncompressed parquet with plain encoding
+	FormatParquet = "parquet"
+)
+
+// list of error
+var (
+	ErrFormatNotSupported = errors.New("export: format is not supported")
+	ErrColumnMismatch     = errors.New("export: number of values doesn't match the columns")
+	ErrInvalidValue       = errors.New("export: invalid value for column type")
+	ErrWriterClosed       = errors.New("export: writer is closed")
+)
+
+// Type of column
+type Type int
+
+// list of column type
+const (
+	TypeString Type = iota
+	TypeBytes
+	TypeBool
+	TypeInt64
+	TypeFloat64
+	TypeTimestamp
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBytes:
+		return "bytes"
+	case TypeBool:
+		return "bool"
+	case TypeInt64:
+		return "int64"
+	case TypeFloat64:
+		return "float64"
+	case TypeTimestamp:
+		return "timestamp"
+	}
+	return "string"
+}
+
+// Column of exported rows, every column is nullable
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Writer of rows
+type Writer interface {
+	// Write one row, values are in the same order as the columns
+	Write(values []interface{}) error
+	// Close flush the buffered rows, the underlying writer is not closed
+	Close() error
+}
+
+// NewWriter of the format
+func NewWriter(format string, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case FormatNDJSON:
+		return NewNDJSONWriter(w, columns), nil
+	case FormatParquet:
+		return NewParquetWriter(w, columns, nil), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFormatNotSupported, format)
+}
+
+// ContentType of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/octet-stream"
+}
+
+// InferSchema return the columns from the database type of the columns
+// numeric and decimal are exported as string to keep the precision, and unknown type is exported as string
+func InferSchema(columnTypes []*sql.ColumnType) []Column {
+	columns := make([]Column, len(columnTypes))
+	for idx, ct := range columnTypes {
+		columns[idx] = Column{Name: ct.Name(), Type: inferType(ct.DatabaseTypeName(), ct.ScanType())}
+	}
+	return columns
+}
+
+func inferType(databaseType string, scanType reflect.Type) Type {
+	switch strings.ToUpper(databaseType) {
+	case "BOOL", "BOOLEAN":
+		return TypeBool
+	case "INT2", "INT4", "INT8", "SMALLINT", "INTEGER", "INT", "BIGINT", "TINYINT", "MEDIUMINT", "YEAR":
+		return TypeInt64
+	case "FLOAT4", "FLOAT8", "REAL", "FLOAT", "DOUBLE":
+		return TypeFloat64
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATE", "DATETIME":
+		return TypeTimestamp
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY":
+		return TypeBytes
+	case "":
+	default:
+		return TypeString
+	}
+
+	// the driver doesn't report the database type
+	if scanType == nil {
+		return TypeString
+	}
+	switch scanType.Kind() {
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return TypeInt64
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat64
+	}
+	if scanType == reflect.TypeOf(time.Time{}) {
+		return TypeTimestamp
+	}
+	return TypeString
+}
+
+// timeLayouts of timestamp returned as text by the driver, for example mysql without parseTime
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// convert the value from the driver to the go type of the column, nil is kept as nil
+// the result is string, []byte, bool, int64, float64 or time.Time
+func convert(t Type, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if b, ok := v.([]byte); ok && t != TypeBytes && t != TypeString {
+		v = string(b)
+	}
+
+	switch t {
+	case TypeString:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		}
+		return fmt.Sprint(v), nil
+
+	case TypeBytes:
+		switch v := v.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+
+	case TypeBool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+
+	case TypeInt64:
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case uint32:
+			return int64(v), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+
+	case TypeFloat64:
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, nil
+			}
+		}
+
+	case TypeTimestamp:
+		switch v := v.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			for _, layout := range timeLayouts {
+				if ts, err := time.Parse(layout, v); err == nil {
+					return ts, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %v for %s", ErrInvalidValue, v, t)
+}
+
+// finite return false for NaN and infinity, which has no json representation
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Options of export
+type Options struct {
+	// Columns of the rows, default to the schema inferred from the query
+	Columns []Column
+	// Metadata of the object
+	Metadata map[string]string
+}
+
+// Result of export
+type Result struct {
+	Key     string   `json:"key"`
+	Rows    int      `json:"rows"`
+	Columns []Column `json:"-"`
+}
+
+// WriteRows write all rows to the writer and return the number of rows, the rows and the writer are not closed
+func WriteRows(rows *sql.Rows, w Writer) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		if err := w.Write(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// Export the result of the query to the object storage, the query run in the follower unless it is not a read query
+// the object is streamed while the rows are read, and the upload is aborted when the export failed
+func Export(ctx context.Context, db *sqldb.DB, storage *objectstorage.Storage, key, format string, options *Options, query string, args ...interface{}) (*Result, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if format != FormatNDJSON && format != FormatParquet {
+		return nil, fmt.Errorf("%w: %s", ErrFormatNotSupported, format)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("export: failed to query: %w", err)
+	}
+	defer rows.Close()
+	columns := opts.Columns
+	if columns == nil {
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return nil, err
+		}
+		columns = InferSchema(columnTypes)
+	}
+
+	// canceling the context before the blob writer is closed abort the upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := storage.Stream(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	bw, err := stream.Writer(ctx, key, &objectstorage.WriteOptions{ContentType: ContentType(format), Metadata: opts.Metadata})
+	if err != nil {
+		return nil, fmt.Errorf("export: failed to open %s: %w", key, err)
+	}
+	w, err := NewWriter(format, bw, columns)
+	if err != nil {
+		return nil, err
+	}
+	count, err := WriteRows(rows, w)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		cancel()
+		bw.Close()
+		return nil, fmt.Errorf("export: failed to write %s: %w", key, err)
+	}
+	if err := bw.Close(); err != nil {
+		return nil, fmt.Errorf("export: failed to upload %s: %w", key, err)
+	}
+	return &Result{Key: key, Rows: count, Columns: columns}, nil
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestInferType(t *testing.T) {
+	cases := []struct {
+		databaseType string
+		scanType     reflect.Type
+		expect       Type
+	}{
+		{databaseType: "INT8", expect: TypeInt64},
+		{databaseType: "bigint", expect: TypeInt64},
+		{databaseType: "FLOAT8", expect: TypeFloat64},
+		{databaseType: "NUMERIC", expect: TypeString},
+		{databaseType: "TIMESTAMPTZ", expect: TypeTimestamp},
+		{databaseType: "BYTEA", expect: TypeBytes},
+		{databaseType: "BOOL", expect: TypeBool},
+		{databaseType: "VARCHAR", scanType: reflect.TypeOf(int64(0)), expect: TypeString},
+		{scanType: reflect.TypeOf(int32(0)), expect: TypeInt64},
+		{scanType: reflect.TypeOf(time.Time{}), expect: TypeTimestamp},
+		{scanType: reflect.TypeOf(new(interface{})).Elem(), expect: TypeString},
+	}
+
+	for _, c := range cases {
+		t.Run(c.databaseType+"/"+c.expect.String(), func(t *testing.T) {
+			if got := inferType(c.databaseType, c.scanType); got != c.expect {
+				t.Fatalf("expect %s but got %s", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	createdAt := time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		typ    Type
+		value  interface{}
+		expect interface{}
+		err    error
+	}{
+		{name: "int from text", typ: TypeInt64, value: []byte("42"), expect: int64(42)},
+		{name: "float from numeric", typ: TypeFloat64, value: []byte("1.5"), expect: 1.5},
+		{name: "bool from tinyint", typ: TypeBool, value: int64(1), expect: true},
+		{name: "timestamp from mysql text", typ: TypeTimestamp, value: []byte("2020-05-05 10:00:00"), expect: createdAt},
+		{name: "string from time", typ: TypeString, value: createdAt, expect: "2020-05-05T10:00:00Z"},
+		{name: "nil", typ: TypeInt64, value: nil, expect: nil},
+		{name: "invalid int", typ: TypeInt64, value: "ten", err: ErrInvalidValue},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := convert(c.typ, c.value)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+			if !reflect.DeepEqual(got, c.expect) {
+				t.Fatalf("expect %v but got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buff bytes.Buffer
+	nw := NewNDJSONWriter(&buff, []Column{
+		{Name: "id", Type: TypeInt64},
+		{Name: "name", Type: TypeString},
+		{Name: "avatar", Type: TypeBytes},
+		{Name: "score", Type: TypeFloat64},
+		{Name: "created_at", Type: TypeTimestamp},
+	})
+	rows := [][]interface{}{
+		{int64(1), "<a>", []byte{0xff}, 1.5, time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)},
+		{[]byte("2"), []byte{0xff}, nil, math.Inf(1), nil},
+	}
+	for _, row := range rows {
+		if err := nw.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := nw.Write([]interface{}{"three", "c", nil, nil, nil}); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expect error %v but got %v", ErrInvalidValue, err)
+	}
+	if err := nw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `{"id":1,"name":"<a>","avatar":{"$base64":"/w=="},"score":1.5,"created_at":"2020-05-05T10:00:00Z"}
+{"id":2,"name":{"$base64":"/w=="},"avatar":null,"score":"+Inf","created_at":null}
+`
+	if buff.String() != expect {
+		t.Fatalf("expect\n%s\nbut got\n%s", expect, buff.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := objectstorage.New(memory.New("exports"))
+	columns := []Column{{Name: "id", Type: TypeInt64}, {Name: "name", Type: TypeString}}
+
+	mock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "a").AddRow(int64(2), "b"))
+	result, err := Export(context.Background(), db, storage, "exports/users.ndjson", FormatNDJSON, &Options{Columns: columns}, "SELECT id, name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 2 {
+		t.Fatalf("expect 2 rows but got %d", result.Rows)
+	}
+	content, err := storage.DownloadByte(context.Background(), "exports/users.ndjson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n"; string(content) != expect {
+		t.Fatalf("expect %q but got %q", expect, content)
+	}
+
+	// the upload is aborted when the export failed
+	mock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("one", "a"))
+	if _, err := Export(context.Background(), db, storage, "exports/failed.parquet", FormatParquet, &Options{Columns: columns}, "SELECT id, name FROM users"); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expect error %v but got %v", ErrInvalidValue, err)
+	}
+	if _, err := storage.Attributes(context.Background(), "exports/failed.parquet"); err == nil {
+		t.Fatal("expect failed export to not be uploaded")
+	}
+	if _, err := Export(context.Background(), db, storage, "exports/users.csv", "csv", nil, "SELECT 1"); !errors.Is(err, ErrFormatNotSupported) {
+		t.Fatalf("expect error %v but got %v", ErrFormatNotSupported, err)
+	}
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Base64Key is the key of json object which hold binary value in ndjson
+// bytes column, and string column which is not valid utf-8, is written as {"$base64": "..."}
+const Base64Key = "$base64"
+
+// NDJSONWriter write every row as json object with the columns in order
+type NDJSONWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	// names is the json encoded column names
+	names [][]byte
+	// value is the buffer of json encoded value, html is not escaped
+	value  bytes.Buffer
+	enc    *json.Encoder
+	closed bool
+}
+
+// NewNDJSONWriter of the columns
+func NewNDJSONWriter(w io.Writer, columns []Column) *NDJSONWriter {
+	names := make([][]byte, len(columns))
+	for idx, column := range columns {
+		names[idx], _ = json.Marshal(column.Name)
+	}
+	nw := NDJSONWriter{
+		w:       bufio.NewWriter(w),
+		columns: columns,
+		names:   names,
+	}
+	nw.enc = json.NewEncoder(&nw.value)
+	nw.enc.SetEscapeHTML(false)
+	return &nw
+}
+
+// Write the row as one line of json
+func (nw *NDJSONWriter) Write(values []interface{}) error {
+	if nw.closed {
+		return ErrWriterClosed
+	}
+	if len(values) != len(nw.columns) {
+		return ErrColumnMismatch
+	}
+	// every value is converted before writing, so invalid row is not partially written
+	converted := make([]interface{}, len(values))
+	for idx, column := range nw.columns {
+		v, err := convert(column.Type, values[idx])
+		if err != nil {
+			return err
+		}
+		converted[idx] = v
+	}
+
+	nw.w.WriteByte('{')
+	for idx, v := range converted {
+		nw.value.Reset()
+		if err := nw.enc.Encode(jsonValue(v)); err != nil {
+			return err
+		}
+		if idx > 0 {
+			nw.w.WriteByte(',')
+		}
+		nw.w.Write(nw.names[idx])
+		nw.w.WriteByte(':')
+		// the encoder always end the value with newline
+		nw.w.Write(bytes.TrimSuffix(nw.value.Bytes(), []byte("\n")))
+	}
+	nw.w.WriteString("}\n")
+	return nil
+}
+
+// Close flush the buffered rows
+func (nw *NDJSONWriter) Close() error {
+	if nw.closed {
+		return nil
+	}
+	nw.closed = true
+	return nw.w.Flush()
+}
+
+// jsonValue return the json representation of converted value
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return map[string]string{Base64Key: base64.StdEncoding.EncodeToString(v)}
+	case string:
+		if !utf8.ValidString(v) {
+			return map[string]string{Base64Key: base64.StdEncoding.EncodeToString([]byte(v))}
+		}
+	case float64:
+		// NaN and infinity has no json number
+		if !finite(v) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
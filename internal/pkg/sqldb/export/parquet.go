@@ -0,0 +1,164 @@
+package export
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/marshal"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// DefaultRowGroupSize is the number of rows in one parquet row group
+const DefaultRowGroupSize = 10000
+
+// ParquetOptions of parquet writer
+type ParquetOptions struct {
+	// RowGroupSize is the number of rows buffered before written as a row group, default to 10000
+	RowGroupSize int
+	// CreatedBy is written in the file metadata, default to go-project-example
+	CreatedBy string
+}
+
+// ParquetWriter write rows as parquet file using github.com/xitongsys/parquet-go
+// every column is optional with plain encoding and no compression
+type ParquetWriter struct {
+	w       io.Writer
+	pw      *writer.ParquetWriter
+	columns []Column
+	opts    ParquetOptions
+	rows    int
+	closed  bool
+}
+
+// NewParquetWriter of the columns
+func NewParquetWriter(w io.Writer, columns []Column, options *ParquetOptions) *ParquetWriter {
+	opts := ParquetOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = DefaultRowGroupSize
+	}
+	if opts.CreatedBy == "" {
+		opts.CreatedBy = "go-project-example"
+	}
+	return &ParquetWriter{
+		w:       w,
+		columns: columns,
+		opts:    opts,
+	}
+}
+
+// Write the row, the row group is written when it is full
+func (pw *ParquetWriter) Write(values []interface{}) error {
+	if pw.closed {
+		return ErrWriterClosed
+	}
+	if len(values) != len(pw.columns) {
+		return ErrColumnMismatch
+	}
+	// every value is converted before buffered, so invalid row is not partially buffered
+	converted := make([]interface{}, len(values))
+	for idx, column := range pw.columns {
+		v, err := convert(column.Type, values[idx])
+		if err != nil {
+			return err
+		}
+		converted[idx] = parquetValue(v)
+	}
+	if err := pw.start(); err != nil {
+		return err
+	}
+	if err := pw.pw.Write(converted); err != nil {
+		return err
+	}
+	pw.rows++
+	if pw.rows >= pw.opts.RowGroupSize {
+		pw.rows = 0
+		return pw.pw.Flush(true)
+	}
+	return nil
+}
+
+// start create the parquet writer, the magic is written to the writer when it is created
+func (pw *ParquetWriter) start() error {
+	if pw.pw != nil {
+		return nil
+	}
+	w, err := writer.NewParquetWriter(writerfile.NewWriterFile(pw.w), parquetSchema(pw.columns), 1)
+	if err != nil {
+		return err
+	}
+	// the row is the values of the columns in order, the same as the csv writer of parquet-go
+	w.MarshalFunc = marshal.MarshalCSV
+	w.CompressionType = parquet.CompressionCodec_UNCOMPRESSED
+	// the row group is flushed by the number of rows instead of the size
+	w.RowGroupSize = math.MaxInt64
+	w.Footer.CreatedBy = &pw.opts.CreatedBy
+	pw.pw = w
+	return nil
+}
+
+// Close write the buffered rows and the file metadata
+func (pw *ParquetWriter) Close() error {
+	if pw.closed {
+		return nil
+	}
+	pw.closed = true
+	if err := pw.start(); err != nil {
+		return err
+	}
+	return pw.pw.WriteStop()
+}
+
+// parquetSchema return the flattened schema, the root element has the columns as children
+func parquetSchema(columns []Column) []*parquet.SchemaElement {
+	root := parquet.NewSchemaElement()
+	root.Name = "schema"
+	root.NumChildren = int32Ptr(int32(len(columns)))
+	root.RepetitionType = parquet.FieldRepetitionTypePtr(parquet.FieldRepetitionType_REQUIRED)
+
+	schema := []*parquet.SchemaElement{root}
+	for _, column := range columns {
+		element := parquet.NewSchemaElement()
+		element.Name = column.Name
+		element.RepetitionType = parquet.FieldRepetitionTypePtr(parquet.FieldRepetitionType_OPTIONAL)
+		switch column.Type {
+		case TypeBytes:
+			element.Type = parquet.TypePtr(parquet.Type_BYTE_ARRAY)
+		case TypeBool:
+			element.Type = parquet.TypePtr(parquet.Type_BOOLEAN)
+		case TypeInt64:
+			element.Type = parquet.TypePtr(parquet.Type_INT64)
+		case TypeFloat64:
+			element.Type = parquet.TypePtr(parquet.Type_DOUBLE)
+		case TypeTimestamp:
+			element.Type = parquet.TypePtr(parquet.Type_INT64)
+			element.ConvertedType = parquet.ConvertedTypePtr(parquet.ConvertedType_TIMESTAMP_MICROS)
+		default:
+			element.Type = parquet.TypePtr(parquet.Type_BYTE_ARRAY)
+			element.ConvertedType = parquet.ConvertedTypePtr(parquet.ConvertedType_UTF8)
+		}
+		schema = append(schema, element)
+	}
+	return schema
+}
+
+// parquetValue return the value of the converted value as parquet-go type
+// byte array is string in parquet-go, and timestamp is microseconds since epoch
+func parquetValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UnixNano() / int64(time.Microsecond)
+	}
+	return v
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// readParquet return the reader of the parquet file, the file is read with parquet-go
+func readParquet(t *testing.T, file []byte) *reader.ParquetReader {
+	t.Helper()
+	bf, err := buffer.NewBufferFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(bf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func TestParquetWriter(t *testing.T) {
+	createdAt := time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+	columns := []Column{
+		{Name: "id", Type: TypeInt64},
+		{Name: "name", Type: TypeString},
+		{Name: "total", Type: TypeFloat64},
+		{Name: "paid", Type: TypeBool},
+		{Name: "created_at", Type: TypeTimestamp},
+		{Name: "payload", Type: TypeBytes},
+	}
+	var buff bytes.Buffer
+	pw := NewParquetWriter(&buff, columns, &ParquetOptions{RowGroupSize: 2})
+	rows := [][]interface{}{
+		{int64(1), "a", 10.5, true, createdAt, []byte{0x00, 0xff}},
+		{int64(2), nil, []byte("20"), false, nil, nil},
+		{[]byte("3"), "c", nil, true, createdAt, "raw"},
+	}
+	for _, row := range rows {
+		if err := pw.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Write([]interface{}{int64(4)}); err != ErrColumnMismatch {
+		t.Fatalf("expect error %v but got %v", ErrColumnMismatch, err)
+	}
+	if err := pw.Write([]interface{}{"four", nil, nil, nil, nil, nil}); err == nil {
+		t.Fatal("expect error of invalid value")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pr := readParquet(t, buff.Bytes())
+	defer pr.ReadStop()
+	if numRows := pr.GetNumRows(); numRows != 3 {
+		t.Fatalf("expect 3 rows but got %d", numRows)
+	}
+	if len(pr.Footer.RowGroups) != 2 {
+		t.Fatalf("expect 2 row groups but got %d", len(pr.Footer.RowGroups))
+	}
+	if createdBy := pr.Footer.GetCreatedBy(); createdBy != "go-project-example" {
+		t.Fatalf("expect created by go-project-example but got %s", createdBy)
+	}
+
+	// the reader rename the schema in the footer, the name in the file is the external name
+	var names []string
+	for idx, element := range pr.Footer.Schema[1:] {
+		names = append(names, pr.SchemaHandler.Infos[idx+1].ExName)
+		if element.GetRepetitionType() != parquet.FieldRepetitionType_OPTIONAL {
+			t.Fatalf("expect optional column %s", names[idx])
+		}
+	}
+	if expect := []string{"id", "name", "total", "paid", "created_at", "payload"}; !reflect.DeepEqual(names, expect) {
+		t.Fatalf("expect columns %v but got %v", expect, names)
+	}
+	if converted := pr.Footer.Schema[2].GetConvertedType(); converted != parquet.ConvertedType_UTF8 {
+		t.Fatalf("expect utf8 converted type but got %s", converted)
+	}
+	if converted := pr.Footer.Schema[5].GetConvertedType(); converted != parquet.ConvertedType_TIMESTAMP_MICROS {
+		t.Fatalf("expect timestamp micros converted type but got %s", converted)
+	}
+	for _, rg := range pr.Footer.RowGroups {
+		for _, chunk := range rg.Columns {
+			if codec := chunk.MetaData.Codec; codec != parquet.CompressionCodec_UNCOMPRESSED {
+				t.Fatalf("expect uncompressed column but got %s", codec)
+			}
+		}
+	}
+
+	micros := createdAt.UnixNano() / int64(time.Microsecond)
+	// null values are not returned, the definition level of null is 0
+	expects := []struct {
+		values []interface{}
+		levels []int32
+	}{
+		{values: []interface{}{int64(1), int64(2), int64(3)}, levels: []int32{1, 1, 1}},
+		{values: []interface{}{"a", "c"}, levels: []int32{1, 0, 1}},
+		{values: []interface{}{10.5, float64(20)}, levels: []int32{1, 1, 0}},
+		{values: []interface{}{true, false, true}, levels: []int32{1, 1, 1}},
+		{values: []interface{}{micros, micros}, levels: []int32{1, 0, 1}},
+		{values: []interface{}{string([]byte{0x00, 0xff}), "raw"}, levels: []int32{1, 0, 1}},
+	}
+	for idx, expect := range expects {
+		values, _, levels, err := pr.ReadColumnByIndex(int64(idx), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var defined []interface{}
+		for _, v := range values {
+			if v != nil {
+				defined = append(defined, v)
+			}
+		}
+		if !reflect.DeepEqual(defined, expect.values) || !reflect.DeepEqual(levels, expect.levels) {
+			t.Fatalf("column %s: expect values %v with levels %v but got %v with levels %v", columns[idx].Name, expect.values, expect.levels, defined, levels)
+		}
+	}
+}
+
+func TestParquetWriterEmpty(t *testing.T) {
+	var buff bytes.Buffer
+	pw := NewParquetWriter(&buff, []Column{{Name: "id", Type: TypeInt64}}, nil)
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	pr := readParquet(t, buff.Bytes())
+	defer pr.ReadStop()
+	if numRows := pr.GetNumRows(); numRows != 0 {
+		t.Fatalf("expect no rows but got %d", numRows)
+	}
+	if err := pw.Write([]interface{}{int64(1)}); err != ErrWriterClosed {
+		t.Fatalf("expect error %v but got %v", ErrWriterClosed, err)
+	}
+}
@@ -0,0 +1,13 @@
+package sqldb
+
+// Expr is sql expression and its arguments, for example condition of json or geometry column
+// the placeholder of arguments is ?, so the query should be rebind with DB.Rebind
+//
+//	expr := db.JSONContains("metadata", map[string]interface{}{"plan": "pro"})
+//	err := db.SelectContext(ctx, &ids, db.Rebind("SELECT id FROM users WHERE "+expr.SQL), expr.Args...)
+//
+// the column is written as is to the expression, so it must not come from user input
+type Expr struct {
+	SQL  string
+	Args []interface{}
+}
@@ -0,0 +1,213 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// list of failover error
+var (
+	ErrResolverNil    = errors.New("sqldb: failover resolver is nil")
+	ErrLeaderReadOnly = errors.New("sqldb: resolved leader is read only")
+	ErrClosed         = errors.New("sqldb: db is closed")
+)
+
+// default value of failover options
+const (
+	DefaultFailoverThreshold = 3
+	DefaultFailoverInterval  = time.Second * 10
+	DefaultFailoverTimeout   = time.Second * 30
+)
+
+// LeaderResolver return a new connection to the current leader
+type LeaderResolver func(ctx context.Context) (*sqlx.DB, error)
+
+// ReconnectResolver connect to the dsn again, so the host of the dsn is looked up again
+// use this when the dsn is a cluster endpoint which dns point to the new leader after failover, for example aws rds
+func ReconnectResolver(driver, dsn string, connOpts *ConnectOptions) LeaderResolver {
+	return func(ctx context.Context) (*sqlx.DB, error) {
+		return Connect(ctx, driver, dsn, connOpts)
+	}
+}
+
+// FailoverOptions of leader failover
+type FailoverOptions struct {
+	// Resolver return the connection of the new leader
+	Resolver LeaderResolver
+	// Threshold is the number of consecutive failed leader queries before the leader is resolved, default to 3
+	// connection error and write to read only database are counted as failure, see IsReadOnly
+	Threshold int
+	// Interval is the minimum time between resolves, default to 10s
+	Interval time.Duration
+	// Timeout of the resolve, default to 30s
+	Timeout time.Duration
+	// OnFailover is called after every resolve, err is not nil when the leader is not swapped
+	OnFailover func(err error)
+}
+
+// failover state of the db
+type failover struct {
+	// lastResolve is the unix nano of the last resolve, placed first for atomic alignment
+	lastResolve int64
+	failures    int32
+	resolving   int32
+	opts        FailoverOptions
+}
+
+// SetFailover resolve the leader again when the leader query fail consecutively, nil disable the failover
+// the leader is resolved in background, and the old leader is closed after the new leader is swapped in
+// queries which already started in the old leader, including transactions, are finished in the old leader
+// the pool settings of the new leader is set by the resolver, for example ConnectOptions of ReconnectResolver
+// the failover must be set before the db is used
+func (db *DB) SetFailover(options *FailoverOptions) error {
+	if options == nil {
+		db.failover = nil
+		return nil
+	}
+	opts := *options
+	if opts.Resolver == nil {
+		return ErrResolverNil
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultFailoverThreshold
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultFailoverInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultFailoverTimeout
+	}
+	db.failover = &failover{opts: opts}
+	return nil
+}
+
+// Failover resolve the leader and swap the leader connection, regardless the threshold and interval
+// the new leader is rejected with ErrLeaderReadOnly when it is still a replica
+func (db *DB) Failover(ctx context.Context) error {
+	if db.failover == nil {
+		return ErrResolverNil
+	}
+	return db.resolveLeader(ctx)
+}
+
+// observeLeader count the consecutive failures of leader query, and resolve the leader when it reach the threshold
+func (db *DB) observeLeader(err error) {
+	fo := db.failover
+	if fo == nil {
+		return
+	}
+	if !classify(db.driver, err).connection() && !IsReadOnly(db.driver, err) {
+		atomic.StoreInt32(&fo.failures, 0)
+		return
+	}
+	if atomic.AddInt32(&fo.failures, 1) < int32(fo.opts.Threshold) {
+		return
+	}
+	if time.Since(time.Unix(0, atomic.LoadInt64(&fo.lastResolve))) < fo.opts.Interval {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&fo.resolving, 0, 1) {
+		return
+	}
+	safego.Go(context.Background(), "sqldb/failover/resolve", func(ctx context.Context) error {
+		defer atomic.StoreInt32(&fo.resolving, 0)
+		ctx, cancel := context.WithTimeout(ctx, fo.opts.Timeout)
+		defer cancel()
+		return db.resolveLeader(ctx)
+	})
+}
+
+// resolveLeader resolve the new leader and swap it with the current leader
+func (db *DB) resolveLeader(ctx context.Context) (err error) {
+	fo := db.failover
+	atomic.StoreInt64(&fo.lastResolve, time.Now().UnixNano())
+	defer func() {
+		if fo.opts.OnFailover != nil {
+			fo.opts.OnFailover(err)
+		}
+	}()
+
+	leader, err := fo.opts.Resolver(ctx)
+	if err != nil {
+		return fmt.Errorf("sqldb: failed to resolve leader: %w", err)
+	}
+	if leader.DriverName() != db.driver {
+		leader.Close()
+		return fmt.Errorf("sqldb: resolved leader driver is not matched. leader = %s resolved = %s", db.driver, leader.DriverName())
+	}
+	readOnly, err := isReadOnlyDB(ctx, leader)
+	if err != nil {
+		leader.Close()
+		return fmt.Errorf("sqldb: failed to check resolved leader: %w", err)
+	}
+	if readOnly {
+		leader.Close()
+		return ErrLeaderReadOnly
+	}
+
+	db.leaderMu.Lock()
+	select {
+	case <-db.stop:
+		db.leaderMu.Unlock()
+		leader.Close()
+		return ErrClosed
+	default:
+	}
+	old := db.leader
+	db.leader = leader
+	db.leaderMu.Unlock()
+	atomic.StoreInt32(&fo.failures, 0)
+
+	if db.stmtCache != nil {
+		db.stmtCache.drop(old)
+	}
+	// close wait for the queries which are running in the old leader
+	safego.Go(context.Background(), "sqldb/failover/close", func(ctx context.Context) error {
+		return old.Close()
+	})
+	return nil
+}
+
+// IsReadOnly return true when the error is caused by writing to read only database
+// for example the leader connection is still connected to the old leader which is demoted to replica
+func IsReadOnly(driverName string, err error) bool {
+	if err == nil {
+		return false
+	}
+	switch driverName {
+	case DriverPostgres:
+		pgErr, ok := PostgresError(err)
+		// read_only_sql_transaction
+		return ok && pgErr.Code == "25006"
+	case DriverMySQL:
+		var mysqlErr *mysql.MySQLError
+		// ER_OPTION_PREVENTS_STATEMENT of --read-only and --super-read-only
+		return errors.As(err, &mysqlErr) && mysqlErr.Number == 1290
+	}
+	return false
+}
+
+// isReadOnlyDB return true when the database is a replica
+func isReadOnlyDB(ctx context.Context, conn *sqlx.DB) (bool, error) {
+	var readOnly bool
+	switch conn.DriverName() {
+	case DriverPostgres:
+		if err := conn.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&readOnly); err != nil {
+			return false, err
+		}
+	case DriverMySQL:
+		if err := conn.QueryRowContext(ctx, "SELECT @@global.read_only").Scan(&readOnly); err != nil {
+			return false, err
+		}
+	default:
+		return false, conn.PingContext(ctx)
+	}
+	return readOnly, nil
+}
@@ -0,0 +1,155 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// newMockResolver return resolver of a new leader mock, the leader is read only when readOnly is true
+func newMockResolver(t *testing.T, readOnly bool, resolved *int32) (LeaderResolver, sqlmock.Sqlmock) {
+	t.Helper()
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("SELECT pg_is_in_recovery()").WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(readOnly))
+	return func(ctx context.Context) (*sqlx.DB, error) {
+		atomic.AddInt32(resolved, 1)
+		return sqlx.NewDb(mockdb, DriverPostgres), nil
+	}, mock
+}
+
+func TestFailover(t *testing.T) {
+	db, leaderMock, _ := newMockDB(t)
+	var resolved int32
+	resolver, newLeaderMock := newMockResolver(t, false, &resolved)
+	failovers := make(chan error, 1)
+	err := db.SetFailover(&FailoverOptions{
+		Resolver:   resolver,
+		Threshold:  2,
+		OnFailover: func(err error) { failovers <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldLeader := db.Leader()
+	readOnly := &pq.Error{Code: "25006"}
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(readOnly)
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(readOnly)
+	for i := 0; i < 2; i++ {
+		if _, err := db.ExecContext(context.Background(), "UPDATE users SET name = 'a'"); !errors.Is(err, readOnly) {
+			t.Fatalf("expecting read only error but got %v", err)
+		}
+	}
+
+	select {
+	case err := <-failovers:
+		if err != nil {
+			t.Fatalf("expecting leader is swapped but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expecting failover after consecutive failures")
+	}
+	if db.Leader() == oldLeader {
+		t.Fatal("expecting new leader after failover")
+	}
+	if db.Follower() == db.Leader() {
+		t.Error("expecting follower is not changed by failover")
+	}
+
+	newLeaderMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, err := db.ExecContext(context.Background(), "UPDATE users SET name = 'a'"); err != nil {
+		t.Fatalf("expecting exec in new leader but got %v", err)
+	}
+	if err := newLeaderMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if n := atomic.LoadInt32(&resolved); n != 1 {
+		t.Errorf("expecting 1 resolve but got %d", n)
+	}
+}
+
+func TestFailoverThreshold(t *testing.T) {
+	db, leaderMock, _ := newMockDB(t)
+	var resolved int32
+	resolver, _ := newMockResolver(t, false, &resolved)
+	if err := db.SetFailover(&FailoverOptions{Resolver: resolver, Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// error of the query reset the consecutive failures
+	readOnly := &pq.Error{Code: "25006"}
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(readOnly)
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(&pq.Error{Code: "23505"})
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(readOnly)
+	for i := 0; i < 3; i++ {
+		db.ExecContext(context.Background(), "UPDATE users SET name = 'a'")
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&resolved); n != 0 {
+		t.Errorf("expecting no resolve but got %d", n)
+	}
+}
+
+func TestFailoverReadOnlyLeader(t *testing.T) {
+	db, _, _ := newMockDB(t)
+	var resolved int32
+	resolver, mock := newMockResolver(t, true, &resolved)
+	mock.ExpectClose()
+	if err := db.Failover(context.Background()); !errors.Is(err, ErrResolverNil) {
+		t.Fatalf("expecting %v but got %v", ErrResolverNil, err)
+	}
+	if err := db.SetFailover(&FailoverOptions{}); !errors.Is(err, ErrResolverNil) {
+		t.Fatalf("expecting %v but got %v", ErrResolverNil, err)
+	}
+	if err := db.SetFailover(&FailoverOptions{Resolver: resolver}); err != nil {
+		t.Fatal(err)
+	}
+
+	leader := db.Leader()
+	if err := db.Failover(context.Background()); !errors.Is(err, ErrLeaderReadOnly) {
+		t.Fatalf("expecting %v but got %v", ErrLeaderReadOnly, err)
+	}
+	if db.Leader() != leader {
+		t.Error("expecting leader is not swapped to read only database")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	cases := []struct {
+		driver string
+		err    error
+		expect bool
+	}{
+		{DriverPostgres, &pq.Error{Code: "25006"}, true},
+		{DriverPostgres, fmt.Errorf("exec: %w", &pq.Error{Code: "25006"}), true},
+		{DriverPostgres, &pq.Error{Code: "23505"}, false},
+		{DriverMySQL, &mysql.MySQLError{Number: 1290}, true},
+		{DriverMySQL, &mysql.MySQLError{Number: 1062}, false},
+		{DriverMySQL, nil, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(fmt.Sprintf("%s %v", c.driver, c.err), func(t *testing.T) {
+			if got := IsReadOnly(c.driver, c.err); got != c.expect {
+				t.Fatalf("expecting %v but got %v", c.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/breaker"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/jmoiron/sqlx"
+)
+
+// list of follower load balancer
+const (
+	BalancerRoundRobin       = "round_robin"
+	BalancerLeastConnections = "least_connections"
+)
+
+// list of follower error
+var (
+	ErrBalancerNotSupported = errors.New("sqldb: load balancer is not supported")
+)
+
+// ValidateBalancer return error if the load balancer of followers is not supported
+// empty balancer is valid and use round robin
+func ValidateBalancer(balancer string) error {
+	switch balancer {
+	case "", BalancerRoundRobin, BalancerLeastConnections:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrBalancerNotSupported, balancer)
+}
+
+// FollowerOptions of followers
+type FollowerOptions struct {
+	// Balancer of read queries between followers, default to round_robin
+	Balancer string
+	// HealthCheckInterval is the interval of follower ping, follower which fail the ping is skipped until the next ping succeed
+	// followers are not checked when the interval is zero
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout of each ping, default to 1 second
+	HealthCheckTimeout time.Duration
+	// MaxLag of replication, follower which lag behind more than MaxLag is stale and skipped until it catch up
+	// the lag is checked on every health check, default interval is 1 second when MaxLag is set. Lag is not checked when zero
+	MaxLag time.Duration
+}
+
+// follower connection and its health
+type follower struct {
+	db *sqlx.DB
+	// leader is true when the follower is the leader connection, use followerDB to get the current leader
+	leader bool
+	// unhealthy is 1 when the last health check failed
+	unhealthy int32
+	// stale is 1 when the replication lag is more than MaxLag or unknown
+	stale int32
+	// lag of replication in nanoseconds, -1 when unknown
+	lag int64
+	// breaker of the follower, nil when the db has no circuit breaker
+	breaker *breaker.Breaker
+}
+
+// followerDB return the connection of the follower, the current leader when the follower is the leader
+func (db *DB) followerDB(f *follower) *sqlx.DB {
+	if f.leader {
+		return db.Leader()
+	}
+	return f.db
+}
+
+func (f *follower) healthy() bool {
+	return atomic.LoadInt32(&f.unhealthy) == 0
+}
+
+func (f *follower) isStale() bool {
+	return atomic.LoadInt32(&f.stale) == 1
+}
+
+// available return true when follower can serve read queries, follower with open circuit is not available
+func (f *follower) available() bool {
+	if f.breaker != nil && f.breaker.State() == breaker.StateOpen {
+		return false
+	}
+	return f.healthy() && !f.isStale()
+}
+
+func (f *follower) lastLag() time.Duration {
+	return time.Duration(atomic.LoadInt64(&f.lag))
+}
+
+func (f *follower) setLag(lag time.Duration, stale int32) {
+	atomic.StoreInt64(&f.lag, int64(lag))
+	atomic.StoreInt32(&f.stale, stale)
+}
+
+// FollowerStats of follower connection pool
+type FollowerStats struct {
+	Healthy bool `json:"healthy"`
+	// Stale is true when the replication lag is more than MaxLag
+	Stale bool `json:"stale"`
+	// Lag of replication from the last check, -1 when unknown
+	Lag time.Duration `json:"lag"`
+	// Circuit is the state of the circuit breaker, empty when it has no circuit breaker
+	Circuit string      `json:"circuit,omitempty"`
+	Stats   sql.DBStats `json:"stats"`
+}
+
+// WrapFollowers wrap leader and followers sqlx object to one DB object
+// read only queries are load balanced between healthy followers, and go to leader when no follower is healthy or all followers are stale
+// the leader is used as the follower when followers is empty
+func WrapFollowers(ctx context.Context, leader *sqlx.DB, followers []*sqlx.DB, options *FollowerOptions) (*DB, error) {
+	opts := FollowerOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if err := ValidateBalancer(opts.Balancer); err != nil {
+		return nil, err
+	}
+	if opts.Balancer == "" {
+		opts.Balancer = BalancerRoundRobin
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = time.Second
+	}
+	if opts.MaxLag > 0 && opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = time.Second
+	}
+	if len(followers) == 0 {
+		followers = []*sqlx.DB{leader}
+	}
+
+	db := DB{
+		driver:          DriverName(leader.DriverName()),
+		leader:          leader,
+		followerOptions: opts,
+		stop:            make(chan struct{}),
+	}
+	for _, f := range followers {
+		if DriverName(leader.DriverName()) != DriverName(f.DriverName()) {
+			return nil, fmt.Errorf("sqldb: leader and follower driver is not matched. leader = %s follower = %s", leader.DriverName(), f.DriverName())
+		}
+		db.followers = append(db.followers, &follower{db: f, leader: f == leader})
+	}
+
+	if opts.HealthCheckInterval > 0 && db.hasReplica() {
+		safego.Go(safego.Detach(ctx), "sqldb/follower/healthcheck", func(ctx context.Context) error {
+			db.checkFollowersEvery(ctx, opts.HealthCheckInterval)
+			return nil
+		})
+	}
+	return &db, nil
+}
+
+// hasReplica return true when at least one follower is not the leader
+func (db *DB) hasReplica() bool {
+	for _, f := range db.followers {
+		if !f.leader {
+			return true
+		}
+	}
+	return false
+}
+
+// pickFollower return available follower based on the balancer, nil when no follower is available
+func (db *DB) pickFollower() *follower {
+	if len(db.followers) == 1 {
+		if f := db.followers[0]; f.available() {
+			return f
+		}
+		return nil
+	}
+
+	switch db.followerOptions.Balancer {
+	case BalancerLeastConnections:
+		var (
+			picked *follower
+			inUse  int
+		)
+		for _, f := range db.followers {
+			if !f.available() {
+				continue
+			}
+			if n := db.followerDB(f).Stats().InUse; picked == nil || n < inUse {
+				picked, inUse = f, n
+			}
+		}
+		return picked
+
+	default:
+		n := uint64(len(db.followers))
+		start := atomic.AddUint64(&db.next, 1)
+		for i := uint64(0); i < n; i++ {
+			if f := db.followers[(start+i)%n]; f.available() {
+				return f
+			}
+		}
+		return nil
+	}
+}
+
+// CheckFollowers ping all followers and mark follower which fail the ping as unhealthy
+// when MaxLag is set, the replication lag of healthy follower is checked and the follower is marked as stale when it lag behind
+// the followers are checked periodically when HealthCheckInterval is set
+func (db *DB) CheckFollowers(ctx context.Context) {
+	for _, f := range db.followers {
+		if f.leader {
+			continue
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, db.followerOptions.HealthCheckTimeout)
+		var unhealthy int32
+		if err := f.db.PingContext(pingCtx); err != nil {
+			unhealthy = 1
+		}
+		cancel()
+		atomic.StoreInt32(&f.unhealthy, unhealthy)
+
+		if unhealthy == 0 && db.followerOptions.MaxLag > 0 {
+			db.checkLag(ctx, f)
+		}
+	}
+}
+
+func (db *DB) checkFollowersEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			db.CheckFollowers(ctx)
+		}
+	}
+}
+
+// addStats add the connection pool statistics
+func addStats(a, b sql.DBStats) sql.DBStats {
+	a.MaxOpenConnections += b.MaxOpenConnections
+	a.OpenConnections += b.OpenConnections
+	a.InUse += b.InUse
+	a.Idle += b.Idle
+	a.WaitCount += b.WaitCount
+	a.WaitDuration += b.WaitDuration
+	a.MaxIdleClosed += b.MaxIdleClosed
+	a.MaxLifetimeClosed += b.MaxLifetimeClosed
+	return a
+}
@@ -0,0 +1,133 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockFollowers(t *testing.T, n int, opts *FollowerOptions) (*DB, []sqlmock.Sqlmock) {
+	t.Helper()
+	leaderdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		followers []*sqlx.DB
+		mocks     []sqlmock.Sqlmock
+	)
+	for i := 0; i < n; i++ {
+		followerdb, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		followers = append(followers, sqlx.NewDb(followerdb, DriverPostgres))
+		mocks = append(mocks, mock)
+	}
+	db, err := WrapFollowers(context.Background(), sqlx.NewDb(leaderdb, DriverPostgres), followers, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mocks
+}
+
+// followerIndex return the index of the follower connection, -1 for leader
+func followerIndex(db *DB, conn *sqlx.DB) int {
+	for idx, f := range db.followers {
+		if f.db == conn {
+			return idx
+		}
+	}
+	return -1
+}
+
+func TestFollowerRoundRobin(t *testing.T) {
+	db, _ := newMockFollowers(t, 3, nil)
+
+	counts := make(map[int]int)
+	for i := 0; i < 30; i++ {
+		counts[followerIndex(db, db.Follower())]++
+	}
+	for idx := 0; idx < 3; idx++ {
+		if counts[idx] != 10 {
+			t.Errorf("expecting follower %d picked 10 times but got %d", idx, counts[idx])
+		}
+	}
+}
+
+func TestFollowerHealthCheck(t *testing.T) {
+	db, mocks := newMockFollowers(t, 3, nil)
+	mocks[0].ExpectPing()
+	mocks[1].ExpectPing().WillReturnError(errors.New("connection refused"))
+	mocks[2].ExpectPing()
+	db.CheckFollowers(context.Background())
+
+	for i := 0; i < 30; i++ {
+		if idx := followerIndex(db, db.Follower()); idx == 1 || idx == -1 {
+			t.Fatalf("expecting unhealthy follower to be skipped but got %d", idx)
+		}
+	}
+	stats := db.Stats()
+	if len(stats.Followers) != 3 || stats.Followers[1].Healthy || !stats.Followers[0].Healthy {
+		t.Errorf("expecting only follower 1 to be unhealthy, got %+v", stats.Followers)
+	}
+
+	// all followers are down, read from leader
+	for _, mock := range mocks {
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	}
+	db.CheckFollowers(context.Background())
+	if db.Follower() != db.Leader() {
+		t.Error("expecting leader when no follower is healthy")
+	}
+
+	// follower is back
+	mocks[2].ExpectPing()
+	mocks[0].ExpectPing().WillReturnError(errors.New("connection refused"))
+	mocks[1].ExpectPing().WillReturnError(errors.New("connection refused"))
+	db.CheckFollowers(context.Background())
+	if idx := followerIndex(db, db.Follower()); idx != 2 {
+		t.Errorf("expecting follower 2 but got %d", idx)
+	}
+}
+
+func TestFollowerLeastConnections(t *testing.T) {
+	db, mocks := newMockFollowers(t, 2, &FollowerOptions{Balancer: BalancerLeastConnections})
+
+	// hold a connection of follower 0
+	mocks[0].ExpectBegin()
+	tx, err := db.followers[0].db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < 5; i++ {
+		if idx := followerIndex(db, db.Follower()); idx != 1 {
+			t.Fatalf("expecting follower with the least connections but got %d", idx)
+		}
+	}
+}
+
+func TestWrapFollowers(t *testing.T) {
+	leaderdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(leaderdb, DriverPostgres)
+
+	if _, err := WrapFollowers(context.Background(), leader, nil, &FollowerOptions{Balancer: "random"}); !errors.Is(err, ErrBalancerNotSupported) {
+		t.Errorf("expecting error %v but got %v", ErrBalancerNotSupported, err)
+	}
+
+	db, err := WrapFollowers(context.Background(), leader, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Follower() != leader {
+		t.Error("expecting leader as follower when there is no follower")
+	}
+}
@@ -0,0 +1,261 @@
+package sqldb
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SRIDWGS84 is the spatial reference id of longitude and latitude coordinate, used by gps
+const SRIDWGS84 = 4326
+
+// list of geometry error
+var (
+	ErrGeometryScan     = errors.New("sqldb: cannot scan geometry column")
+	ErrGeometryType     = errors.New("sqldb: unexpected geometry type")
+	ErrGeometryTooShort = errors.New("sqldb: geometry is too short")
+)
+
+// wkb geometry type and ewkb flags
+const (
+	wkbPoint   = 1
+	wkbPolygon = 3
+
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// Geometry is a postgis geometry which is written as extended well-known text
+type Geometry interface {
+	driver.Valuer
+	EWKT() string
+}
+
+// Coordinate of geometry, X is longitude and Y is latitude for SRIDWGS84
+type Coordinate struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Point geometry, SRID zero means the point has no spatial reference
+type Point struct {
+	Coordinate
+	SRID int `json:"srid,omitempty"`
+}
+
+// NewPoint return gps point of the longitude and latitude
+func NewPoint(lng, lat float64) Point {
+	return Point{Coordinate: Coordinate{X: lng, Y: lat}, SRID: SRIDWGS84}
+}
+
+// EWKT return extended well-known text of the point, for example SRID=4326;POINT(106.8 -6.2)
+func (p Point) EWKT() string {
+	return withSRID(p.SRID, "POINT("+formatCoordinate(p.Coordinate)+")")
+}
+
+// Value implements driver.Valuer
+func (p Point) Value() (driver.Value, error) {
+	return p.EWKT(), nil
+}
+
+// Scan implements sql.Scanner, the column is scanned from hex or binary extended well-known binary
+func (p *Point) Scan(src interface{}) error {
+	r, err := newEWKBReader(src, wkbPoint)
+	if err != nil {
+		return err
+	}
+	p.SRID = r.srid
+	p.Coordinate, err = r.coordinate()
+	return err
+}
+
+// Polygon geometry, the first ring is the exterior and the others are holes
+// every ring is closed, the first and last coordinate are the same
+type Polygon struct {
+	Rings [][]Coordinate `json:"rings"`
+	SRID  int            `json:"srid,omitempty"`
+}
+
+// EWKT return extended well-known text of the polygon, for example SRID=4326;POLYGON((0 0,1 0,1 1,0 0))
+func (p Polygon) EWKT() string {
+	rings := make([]string, len(p.Rings))
+	for idx, ring := range p.Rings {
+		coordinates := make([]string, len(ring))
+		for i, c := range ring {
+			coordinates[i] = formatCoordinate(c)
+		}
+		rings[idx] = "(" + strings.Join(coordinates, ",") + ")"
+	}
+	return withSRID(p.SRID, "POLYGON("+strings.Join(rings, ",")+")")
+}
+
+// Value implements driver.Valuer
+func (p Polygon) Value() (driver.Value, error) {
+	return p.EWKT(), nil
+}
+
+// Scan implements sql.Scanner, the column is scanned from hex or binary extended well-known binary
+func (p *Polygon) Scan(src interface{}) error {
+	r, err := newEWKBReader(src, wkbPolygon)
+	if err != nil {
+		return err
+	}
+	p.SRID = r.srid
+
+	numRings, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	p.Rings = make([][]Coordinate, 0, numRings)
+	for i := uint32(0); i < numRings; i++ {
+		numPoints, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		ring := make([]Coordinate, 0, numPoints)
+		for j := uint32(0); j < numPoints; j++ {
+			c, err := r.coordinate()
+			if err != nil {
+				return err
+			}
+			ring = append(ring, c)
+		}
+		p.Rings = append(p.Rings, ring)
+	}
+	return nil
+}
+
+// GeoDWithin return postgis expression which is true when the geometry column is within meters of the geometry
+// the distance is calculated as geography, so the column should have geography index to use the index
+// for example CREATE INDEX ON places USING GIST ((location::geography))
+func GeoDWithin(column string, g Geometry, meters float64) Expr {
+	return Expr{
+		SQL:  fmt.Sprintf("ST_DWithin(%s::geography, ST_GeomFromEWKT(?)::geography, ?)", column),
+		Args: []interface{}{g, meters},
+	}
+}
+
+// GeoDistance return postgis expression of the distance in meters between the geometry column and the geometry
+// for example to order the result from the nearest
+func GeoDistance(column string, g Geometry) Expr {
+	return Expr{
+		SQL:  fmt.Sprintf("ST_Distance(%s::geography, ST_GeomFromEWKT(?)::geography)", column),
+		Args: []interface{}{g},
+	}
+}
+
+// GeoContains return postgis expression which is true when the geometry column contains the geometry
+// for example area polygon which contains a point, the SRID of the column and the geometry must be the same
+func GeoContains(column string, g Geometry) Expr {
+	return Expr{
+		SQL:  fmt.Sprintf("ST_Contains(%s, ST_GeomFromEWKT(?))", column),
+		Args: []interface{}{g},
+	}
+}
+
+// GeoWithin return postgis expression which is true when the geometry column is within the geometry
+// for example point which is inside an area polygon, the SRID of the column and the geometry must be the same
+func GeoWithin(column string, g Geometry) Expr {
+	return Expr{
+		SQL:  fmt.Sprintf("ST_Within(%s, ST_GeomFromEWKT(?))", column),
+		Args: []interface{}{g},
+	}
+}
+
+func withSRID(srid int, wkt string) string {
+	if srid == 0 {
+		return wkt
+	}
+	return "SRID=" + strconv.Itoa(srid) + ";" + wkt
+}
+
+func formatCoordinate(c Coordinate) string {
+	return strconv.FormatFloat(c.X, 'f', -1, 64) + " " + strconv.FormatFloat(c.Y, 'f', -1, 64)
+}
+
+// ewkbReader read extended well-known binary
+type ewkbReader struct {
+	b         []byte
+	order     binary.ByteOrder
+	srid      int
+	dimension int
+}
+
+// newEWKBReader read the header of the geometry and check the geometry type
+// postgis return geometry as hex string in text protocol, and as binary for ST_AsEWKB
+func newEWKBReader(src interface{}, geometryType uint32) (*ewkbReader, error) {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrGeometryScan, src)
+	}
+	// binary start with byte order 0 or 1, while hex start with character 0
+	if len(b) > 0 && b[0] == '0' {
+		decoded := make([]byte, hex.DecodedLen(len(b)))
+		if _, err := hex.Decode(decoded, b); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGeometryScan, err)
+		}
+		b = decoded
+	}
+	if len(b) < 5 {
+		return nil, ErrGeometryTooShort
+	}
+
+	r := &ewkbReader{b: b[1:], order: binary.LittleEndian, dimension: 2}
+	if b[0] == 0 {
+		r.order = binary.BigEndian
+	}
+	typ, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if typ&ewkbZ != 0 {
+		r.dimension++
+	}
+	if typ&ewkbM != 0 {
+		r.dimension++
+	}
+	if typ&ewkbSRID != 0 {
+		srid, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		r.srid = int(srid)
+	}
+	if typ&0xffff != geometryType {
+		return nil, fmt.Errorf("%w: %d", ErrGeometryType, typ&0xffff)
+	}
+	return r, nil
+}
+
+func (r *ewkbReader) uint32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, ErrGeometryTooShort
+	}
+	v := r.order.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+// coordinate read x and y, and skip z and m
+func (r *ewkbReader) coordinate() (Coordinate, error) {
+	if len(r.b) < 8*r.dimension {
+		return Coordinate{}, ErrGeometryTooShort
+	}
+	c := Coordinate{
+		X: math.Float64frombits(r.order.Uint64(r.b)),
+		Y: math.Float64frombits(r.order.Uint64(r.b[8:])),
+	}
+	r.b = r.b[8*r.dimension:]
+	return c, nil
+}
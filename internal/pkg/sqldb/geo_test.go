@@ -0,0 +1,134 @@
+package sqldb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPointScan(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    interface{}
+		expect Point
+		err    error
+	}{
+		{
+			name:   "hex with srid",
+			src:    []byte("0101000020E6100000000000000000F03F0000000000000040"),
+			expect: Point{Coordinate: Coordinate{X: 1, Y: 2}, SRID: SRIDWGS84},
+		},
+		{
+			name:   "hex without srid",
+			src:    "0101000000000000000000F03F0000000000000040",
+			expect: Point{Coordinate: Coordinate{X: 1, Y: 2}},
+		},
+		{
+			name:   "binary big endian",
+			src:    []byte{0, 0, 0, 0, 1, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0, 0, 0, 0, 0},
+			expect: Point{Coordinate: Coordinate{X: 1, Y: 2}},
+		},
+		{
+			name: "invalid hex",
+			src:  "0103000000000000000",
+			err:  ErrGeometryScan,
+		},
+		{
+			name: "too short",
+			src:  []byte("0101000020E6100000000000000000F03F"),
+			err:  ErrGeometryTooShort,
+		},
+		{
+			name: "not a point",
+			src:  "010300000000000000",
+			err:  ErrGeometryType,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var p Point
+			err := p.Scan(c.src)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+			if c.err == nil && p != c.expect {
+				t.Fatalf("expect %+v but got %+v", c.expect, p)
+			}
+		})
+	}
+}
+
+func TestPolygonScan(t *testing.T) {
+	ring := []Coordinate{{0, 0}, {1, 0}, {1, 1}, {0, 0}}
+
+	var b bytes.Buffer
+	b.WriteByte(1)
+	binary.Write(&b, binary.LittleEndian, uint32(wkbPolygon|ewkbSRID))
+	binary.Write(&b, binary.LittleEndian, uint32(SRIDWGS84))
+	binary.Write(&b, binary.LittleEndian, uint32(1))
+	binary.Write(&b, binary.LittleEndian, uint32(len(ring)))
+	for _, c := range ring {
+		binary.Write(&b, binary.LittleEndian, math.Float64bits(c.X))
+		binary.Write(&b, binary.LittleEndian, math.Float64bits(c.Y))
+	}
+
+	var p Polygon
+	if err := p.Scan(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if p.SRID != SRIDWGS84 || len(p.Rings) != 1 || len(p.Rings[0]) != len(ring) {
+		t.Fatalf("unexpected polygon %+v", p)
+	}
+	for idx, c := range ring {
+		if p.Rings[0][idx] != c {
+			t.Fatalf("expect %v but got %v", c, p.Rings[0][idx])
+		}
+	}
+	if ewkt := p.EWKT(); ewkt != "SRID=4326;POLYGON((0 0,1 0,1 1,0 0))" {
+		t.Fatalf("unexpected ewkt %s", ewkt)
+	}
+}
+
+func TestPointEWKT(t *testing.T) {
+	if ewkt := NewPoint(106.8272, -6.1751).EWKT(); ewkt != "SRID=4326;POINT(106.8272 -6.1751)" {
+		t.Fatalf("unexpected ewkt %s", ewkt)
+	}
+	if ewkt := (Point{Coordinate: Coordinate{X: 1, Y: 2}}).EWKT(); ewkt != "POINT(1 2)" {
+		t.Fatalf("unexpected ewkt %s", ewkt)
+	}
+}
+
+func TestGeoDWithinQuery(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	followerMock.ExpectQuery(regexp.QuoteMeta("SELECT id, location FROM places WHERE ST_DWithin(location::geography, ST_GeomFromEWKT($1)::geography, $2)")).
+		WithArgs("SRID=4326;POINT(106.8 -6.2)", float64(500)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "location"}).AddRow(1, []byte("0101000020E6100000000000000000F03F0000000000000040")))
+
+	type place struct {
+		ID       int64 `db:"id"`
+		Location Point `db:"location"`
+	}
+	expr := GeoDWithin("location", NewPoint(106.8, -6.2), 500)
+	var places []place
+	if err := db.SelectContext(context.Background(), &places, db.Rebind("SELECT id, location FROM places WHERE "+expr.SQL), expr.Args...); err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 || places[0].Location.X != 1 {
+		t.Fatalf("unexpected places %+v", places)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// QueryEvent of query executed by the db
+type QueryEvent struct {
+	Query string
+	// Args is the number of query arguments, the arguments are not in the event as they might contain sensitive data
+	Args     int
+	Duration time.Duration
+	// RowsAffected by exec, -1 for other query or when it is unknown
+	RowsAffected int64
+	// Leader is true when the query is executed in leader, and false when it is executed in follower
+	Leader bool
+	Err    error
+}
+
+// Hook is invoked after every query, including every retry attempt
+// query in transaction is not passed to the hook
+type Hook func(ctx context.Context, event QueryEvent)
+
+// AddHook add hook to the db, hooks must be added before the db is used
+func (db *DB) AddHook(hook Hook) {
+	db.hooks = append(db.hooks, hook)
+}
+
+// SlowQueryHook return hook which invoke fn when the query take longer than threshold
+func SlowQueryHook(threshold time.Duration, fn func(ctx context.Context, event QueryEvent)) Hook {
+	return func(ctx context.Context, event QueryEvent) {
+		if event.Duration >= threshold {
+			fn(ctx, event)
+		}
+	}
+}
+
+func (db *DB) runHooks(ctx context.Context, event QueryEvent) {
+	for _, hook := range db.hooks {
+		hook(ctx, event)
+	}
+}
+
+// maxSanitizedQuery is the maximum length of sanitized query
+const maxSanitizedQuery = 2048
+
+// SanitizeQuery return the query for logging, literal string and number are replaced with ?
+// and whitespaces are collapsed. placeholder like $1 is not changed
+func SanitizeQuery(query string) string {
+	var (
+		b     strings.Builder
+		space bool
+	)
+	b.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case space && b.Len() > 0:
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case c == '\'':
+			// skip string literal, quote inside the string is escaped with another quote
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			b.WriteByte(c)
+			for i+1 < len(query) && isDigit(query[i+1]) {
+				i++
+				b.WriteByte(query[i])
+			}
+		case isDigit(c) && (i == 0 || !(isKeywordChar(query[i-1]) || isDigit(query[i-1]))):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	out := b.String()
+	if len(out) > maxSanitizedQuery {
+		out = out[:maxSanitizedQuery] + "..."
+	}
+	return out
+}
+
+// list of sanitized values for the digest, for example IN ($1, $2, $3) and the rows of VALUES (?, ?), (?, ?)
+var (
+	valueList = regexp.MustCompile(`(\?|\$\d+)(\s*,\s*(\?|\$\d+))+`)
+	rowList   = regexp.MustCompile(`\(\?\)(\s*,\s*\(\?\))+`)
+)
+
+// Digest return the digest of the statement, the queries which only differ in literals, whitespaces and the number of
+// values in a list have the same digest. the digest is 16 hex characters to label the metrics of the statement
+func Digest(query string) string {
+	statement := valueList.ReplaceAllString(SanitizeQuery(query), "?")
+	statement = strings.ToLower(rowList.ReplaceAllString(statement, "(?)"))
+	h := fnv.New64a()
+	h.Write([]byte(statement))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,99 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSanitizeQuery(t *testing.T) {
+	cases := []struct {
+		query  string
+		expect string
+	}{
+		{
+			query:  "SELECT id FROM users\n\tWHERE email = 'a@b.c' AND age > 21",
+			expect: "SELECT id FROM users WHERE email = ? AND age > ?",
+		},
+		{
+			query:  "UPDATE users SET name = 'it''s' WHERE id = $1",
+			expect: "UPDATE users SET name = ? WHERE id = $1",
+		},
+		{
+			query:  "SELECT price * 1.5 FROM table2 WHERE col12 = 3",
+			expect: "SELECT price * ? FROM table2 WHERE col12 = ?",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			if out := SanitizeQuery(c.query); out != c.expect {
+				t.Fatalf("expect %s but got %s", c.expect, out)
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	cases := []struct {
+		a, b string
+		same bool
+	}{
+		{a: "SELECT * FROM users WHERE id = 1", b: "select *  from users\nwhere id = 2", same: true},
+		{a: "SELECT * FROM users WHERE id IN ($1, $2)", b: "SELECT * FROM users WHERE id IN ($1,$2,$3)", same: true},
+		{a: "INSERT INTO users VALUES (?, ?), (?, ?)", b: "INSERT INTO users VALUES (?, ?)", same: true},
+		{a: "SELECT * FROM users WHERE id = ?", b: "SELECT * FROM orders WHERE id = ?", same: false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.a, func(t *testing.T) {
+			a, b := Digest(c.a), Digest(c.b)
+			if len(a) != 16 {
+				t.Fatalf("expecting 16 characters digest but got %s", a)
+			}
+			if (a == b) != c.same {
+				t.Fatalf("expecting same digest %v but got %s and %s", c.same, a, b)
+			}
+		})
+	}
+}
+
+func TestHook(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	var events []QueryEvent
+	db.AddHook(func(ctx context.Context, event QueryEvent) {
+		events = append(events, event)
+	})
+	var slow []QueryEvent
+	db.AddHook(SlowQueryHook(time.Millisecond*20, func(ctx context.Context, event QueryEvent) {
+		slow = append(slow, event)
+	}))
+
+	leaderMock.ExpectExec("UPDATE users").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	followerMock.ExpectQuery("SELECT name").WillDelayFor(time.Millisecond * 30).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a"))
+
+	if _, err := db.Exec("UPDATE users SET active = true WHERE org_id = $1", 1); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	if err := db.Select(&names, "SELECT name FROM users"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expect 2 events but got %d", len(events))
+	}
+	if e := events[0]; !e.Leader || e.RowsAffected != 3 || e.Args != 1 {
+		t.Fatalf("unexpected exec event %+v", e)
+	}
+	if e := events[1]; e.Leader || e.RowsAffected != -1 {
+		t.Fatalf("unexpected select event %+v", e)
+	}
+	if len(slow) != 1 || slow[0].Query != "SELECT name FROM users" {
+		t.Fatalf("expect select as slow query but got %+v", slow)
+	}
+}
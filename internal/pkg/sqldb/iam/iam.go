@@ -0,0 +1,93 @@
+// Package iam provide short lived database token of cloud provider as sqldb.PasswordFunc
+// the token is used as the password of every new connection, so static credentials are not stored in the dsn
+//
+//	password, err := iam.AWS(&iam.AWSOptions{Region: "ap-southeast-1"})
+//	db, err := sqldb.Connect(ctx, sqldb.DriverPostgres, dsn, &sqldb.ConnectOptions{Password: password})
+//
+// aws rds require tls, and mysql require allowCleartextPasswords=true in the dsn
+package iam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// CloudSQLScope is the oauth2 scope of gcp cloud sql iam login
+const CloudSQLScope = "https://www.googleapis.com/auth/sqlservice.login"
+
+// list of error
+var (
+	ErrRegionEmpty = errors.New("iam: aws region is empty")
+)
+
+// AWSOptions of aws rds iam authentication
+type AWSOptions struct {
+	Region string
+	// Credentials to sign the token, default to the credentials chain of aws session
+	Credentials *credentials.Credentials
+}
+
+// AWS return password of aws rds iam authentication
+// the token is signed locally for the address and user of the dsn, and valid for 15 minutes
+func AWS(options *AWSOptions) (sqldb.PasswordFunc, error) {
+	opts := AWSOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Region == "" {
+		return nil, ErrRegionEmpty
+	}
+	if opts.Credentials == nil {
+		sess, err := session.NewSession(&aws.Config{Region: aws.String(opts.Region)})
+		if err != nil {
+			return nil, fmt.Errorf("iam: failed to create aws session: %w", err)
+		}
+		opts.Credentials = sess.Config.Credentials
+	}
+
+	return func(ctx context.Context, address, user string) (string, error) {
+		return rdsutils.BuildAuthToken(address, opts.Region, user, opts.Credentials)
+	}, nil
+}
+
+// GCPOptions of gcp cloud sql iam authentication
+type GCPOptions struct {
+	// TokenSource of the oauth2 token, default to google default credentials with CloudSQLScope
+	TokenSource oauth2.TokenSource
+}
+
+// GCP return password of gcp cloud sql iam authentication
+// the oauth2 access token is reused until it is expired, the user of dsn is the iam user of the database
+// the default token source keep the context for every refresh, so the context is detached and the token can be refreshed after ctx is canceled
+func GCP(ctx context.Context, options *GCPOptions) (sqldb.PasswordFunc, error) {
+	opts := GCPOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.TokenSource == nil {
+		ts, err := google.DefaultTokenSource(safego.Detach(ctx), CloudSQLScope)
+		if err != nil {
+			return nil, fmt.Errorf("iam: failed to get google credentials: %w", err)
+		}
+		opts.TokenSource = ts
+	}
+	ts := oauth2.ReuseTokenSource(nil, opts.TokenSource)
+
+	return func(ctx context.Context, address, user string) (string, error) {
+		token, err := ts.Token()
+		if err != nil {
+			return "", fmt.Errorf("iam: failed to get gcp token: %w", err)
+		}
+		return token.AccessToken, nil
+	}, nil
+}
@@ -0,0 +1,133 @@
+package iam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"golang.org/x/oauth2"
+)
+
+func TestAWS(t *testing.T) {
+	if _, err := AWS(nil); !errors.Is(err, ErrRegionEmpty) {
+		t.Fatalf("expect error %v but got %v", ErrRegionEmpty, err)
+	}
+
+	password, err := AWS(&AWSOptions{
+		Region:      "ap-southeast-1",
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := password(context.Background(), "db.payments:5432", "iam_user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "db.payments:5432?") {
+		t.Fatalf("expect token of the address but got %s", token)
+	}
+	query, err := url.ParseQuery(token[strings.Index(token, "?")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("DBUser") != "iam_user" || query.Get("Action") != "connect" || query.Get("X-Amz-Signature") == "" {
+		t.Fatalf("unexpected token query %v", query)
+	}
+}
+
+// countTokenSource count the token requests
+type countTokenSource struct {
+	count int
+}
+
+func (ts *countTokenSource) Token() (*oauth2.Token, error) {
+	ts.count++
+	return &oauth2.Token{AccessToken: "access-token", Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func TestGCP(t *testing.T) {
+	ts := &countTokenSource{}
+	password, err := GCP(context.Background(), &GCPOptions{TokenSource: ts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := password(context.Background(), "10.0.0.2:5432", "sa@project.iam")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "access-token" {
+			t.Fatalf("expect access-token but got %s", token)
+		}
+	}
+	// the token is reused until it is expired
+	if ts.count != 1 {
+		t.Fatalf("expect 1 token request but got %d", ts.count)
+	}
+}
+
+func TestGCPDefaultCredentials(t *testing.T) {
+	// the token expire immediately, so every password refresh the token
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access-token","token_type":"Bearer","expires_in":1}`))
+	}))
+	defer server.Close()
+
+	// the token of user credentials is refreshed with the context of the token source
+	credentials, err := json.Marshal(map[string]string{
+		"type":          "authorized_user",
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+		"refresh_token": "refresh-token",
+		"token_uri":     server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "iam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "credentials.json")
+	if err := ioutil.WriteFile(file, credentials, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", file)
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+
+	// the context of initialization is canceled after the database is connected
+	ctx, cancel := context.WithCancel(context.Background())
+	password, err := GCP(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for i := 0; i < 2; i++ {
+		token, err := password(context.Background(), "10.0.0.2:5432", "sa@project.iam")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "access-token" {
+			t.Fatalf("expect access-token but got %s", token)
+		}
+	}
+	if n := atomic.LoadInt64(&requests); n != 2 {
+		t.Fatalf("expect the token is refreshed after the context is canceled but got %d requests", n)
+	}
+}
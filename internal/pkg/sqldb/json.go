@@ -0,0 +1,159 @@
+package sqldb
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// list of json error
+var (
+	ErrJSONScan = errors.New("sqldb: cannot scan json column")
+)
+
+// JSONMap is a json object column, for example schemaless metadata
+// NULL is scanned as nil map, and nil map is written as NULL
+type JSONMap map[string]interface{}
+
+// Scan implements sql.Scanner
+func (m *JSONMap) Scan(src interface{}) error {
+	if src == nil {
+		*m = nil
+		return nil
+	}
+	b, err := jsonBytes(src)
+	if err != nil {
+		return err
+	}
+	out := make(JSONMap)
+	if err := json.Unmarshal(b, &out); err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// Value implements driver.Valuer
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return jsonString(m)
+}
+
+// JSON is a json column of any type, V is marshalled when written and must be a pointer when scanned
+//
+//	var settings Settings
+//	err := db.QueryRowContext(ctx, "SELECT settings FROM users WHERE id = $1", id).Scan(sqldb.JSONOf(&settings))
+type JSON struct {
+	V interface{}
+}
+
+// JSONOf return json column of v
+func JSONOf(v interface{}) *JSON {
+	return &JSON{V: v}
+}
+
+// Scan implements sql.Scanner, NULL doesn't change V
+func (j *JSON) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	b, err := jsonBytes(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, j.V)
+}
+
+// Value implements driver.Valuer
+func (j JSON) Value() (driver.Value, error) {
+	if j.V == nil {
+		return nil, nil
+	}
+	return jsonString(j.V)
+}
+
+// jsonString marshal v as string, lib/pq send bytes as binary which is not valid for jsonb parameter
+func jsonString(v interface{}) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func jsonBytes(src interface{}) ([]byte, error) {
+	switch v := src.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrJSONScan, src)
+}
+
+// JSONContains return expression which is true when the json column contains the value
+// postgres use @> for jsonb column, and mysql use JSON_CONTAINS
+func (db *DB) JSONContains(column string, value interface{}) Expr {
+	return jsonContains(db.driver, column, value)
+}
+
+// JSONExtract return expression of the value in the path of json column as text, NULL when the path doesn't exist
+// number in the path is the index of array
+func (db *DB) JSONExtract(column string, path ...string) Expr {
+	return jsonExtract(db.driver, column, path)
+}
+
+// JSONSet return expression of the json column with the value in the path is set, for example in UPDATE ... SET
+// NULL column is set as empty object, but missing parent of the path is not created
+func (db *DB) JSONSet(column string, path []string, value interface{}) Expr {
+	return jsonSet(db.driver, column, path, value)
+}
+
+func jsonContains(driverName, column string, value interface{}) Expr {
+	if driverName == DriverMySQL {
+		return Expr{SQL: fmt.Sprintf("JSON_CONTAINS(%s, ?)", column), Args: []interface{}{JSON{V: value}}}
+	}
+	return Expr{SQL: fmt.Sprintf("%s @> ?::jsonb", column), Args: []interface{}{JSON{V: value}}}
+}
+
+func jsonExtract(driverName, column string, path []string) Expr {
+	if driverName == DriverMySQL {
+		return Expr{SQL: fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, ?))", column), Args: []interface{}{mysqlJSONPath(path)}}
+	}
+	return Expr{SQL: fmt.Sprintf("%s #>> ?", column), Args: []interface{}{pq.Array(path)}}
+}
+
+func jsonSet(driverName, column string, path []string, value interface{}) Expr {
+	if driverName == DriverMySQL {
+		return Expr{
+			SQL:  fmt.Sprintf("JSON_SET(COALESCE(%s, JSON_OBJECT()), ?, CAST(? AS JSON))", column),
+			Args: []interface{}{mysqlJSONPath(path), JSON{V: value}},
+		}
+	}
+	return Expr{
+		SQL:  fmt.Sprintf("jsonb_set(COALESCE(%s, '{}'::jsonb), ?, ?::jsonb, true)", column),
+		Args: []interface{}{pq.Array(path), JSON{V: value}},
+	}
+}
+
+// mysqlJSONPath return mysql json path of the keys, for example $."a"[0]
+func mysqlJSONPath(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range path {
+		if _, err := strconv.ParseUint(key, 10, 64); err == nil {
+			fmt.Fprintf(&b, "[%s]", key)
+			continue
+		}
+		b.WriteString(".")
+		b.WriteString(strconv.Quote(key))
+	}
+	return b.String()
+}
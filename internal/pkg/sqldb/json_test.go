@@ -0,0 +1,159 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestJSONMap(t *testing.T) {
+	var m JSONMap
+	if err := m.Scan([]byte(`{"plan":"pro","seats":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if m["plan"] != "pro" || m["seats"] != float64(2) {
+		t.Fatalf("unexpected map %v", m)
+	}
+	value, err := m.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != `{"plan":"pro","seats":2}` {
+		t.Fatalf("unexpected value %v", value)
+	}
+
+	if err := m.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if m != nil {
+		t.Fatalf("expect nil map but got %v", m)
+	}
+	if value, _ := m.Value(); value != nil {
+		t.Fatalf("expect NULL but got %v", value)
+	}
+	if err := m.Scan(1); err == nil {
+		t.Fatal("expect error when scanning number")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type settings struct {
+		Theme  string   `json:"theme"`
+		Labels []string `json:"labels"`
+	}
+
+	var s settings
+	if err := JSONOf(&s).Scan(`{"theme":"dark","labels":["a"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if s.Theme != "dark" || len(s.Labels) != 1 {
+		t.Fatalf("unexpected settings %+v", s)
+	}
+	value, err := JSONOf(s).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != `{"theme":"dark","labels":["a"]}` {
+		t.Fatalf("unexpected value %v", value)
+	}
+}
+
+func TestExpr(t *testing.T) {
+	cases := []struct {
+		name   string
+		expr   Expr
+		sql    string
+		values []driver.Value
+	}{
+		{
+			name:   "postgres contains",
+			expr:   jsonContains(DriverPostgres, "metadata", map[string]string{"plan": "pro"}),
+			sql:    "metadata @> ?::jsonb",
+			values: []driver.Value{`{"plan":"pro"}`},
+		},
+		{
+			name:   "mysql contains",
+			expr:   jsonContains(DriverMySQL, "metadata", map[string]string{"plan": "pro"}),
+			sql:    "JSON_CONTAINS(metadata, ?)",
+			values: []driver.Value{`{"plan":"pro"}`},
+		},
+		{
+			name:   "postgres extract",
+			expr:   jsonExtract(DriverPostgres, "metadata", []string{"address", "city"}),
+			sql:    "metadata #>> ?",
+			values: []driver.Value{`{"address","city"}`},
+		},
+		{
+			name:   "mysql extract",
+			expr:   jsonExtract(DriverMySQL, "metadata", []string{"tags", "0"}),
+			sql:    "JSON_UNQUOTE(JSON_EXTRACT(metadata, ?))",
+			values: []driver.Value{`$."tags"[0]`},
+		},
+		{
+			name:   "postgres set",
+			expr:   jsonSet(DriverPostgres, "metadata", []string{"plan"}, "pro"),
+			sql:    "jsonb_set(COALESCE(metadata, '{}'::jsonb), ?, ?::jsonb, true)",
+			values: []driver.Value{`{"plan"}`, `"pro"`},
+		},
+		{
+			name:   "mysql set",
+			expr:   jsonSet(DriverMySQL, "metadata", []string{"plan"}, "pro"),
+			sql:    "JSON_SET(COALESCE(metadata, JSON_OBJECT()), ?, CAST(? AS JSON))",
+			values: []driver.Value{`$."plan"`, `"pro"`},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.expr.SQL != c.sql {
+				t.Fatalf("expect sql %s but got %s", c.sql, c.expr.SQL)
+			}
+			if len(c.expr.Args) != len(c.values) {
+				t.Fatalf("expect %d args but got %d", len(c.values), len(c.expr.Args))
+			}
+			for idx, arg := range c.expr.Args {
+				value := driver.Value(arg)
+				if valuer, ok := arg.(driver.Valuer); ok {
+					var err error
+					if value, err = valuer.Value(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if value != c.values[idx] {
+					t.Fatalf("expect arg %v but got %v", c.values[idx], value)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONContainsQuery(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	followerMock.ExpectQuery(regexp.QuoteMeta("SELECT id, metadata FROM users WHERE metadata @> $1::jsonb")).
+		WithArgs(`{"plan":"pro"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "metadata"}).AddRow(1, []byte(`{"plan":"pro"}`)))
+
+	type user struct {
+		ID       int64   `db:"id"`
+		Metadata JSONMap `db:"metadata"`
+	}
+	expr := db.JSONContains("metadata", map[string]interface{}{"plan": "pro"})
+	var users []user
+	if err := db.SelectContext(context.Background(), &users, db.Rebind("SELECT id, metadata FROM users WHERE "+expr.SQL), expr.Args...); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Metadata["plan"] != "pro" {
+		t.Fatalf("unexpected users %+v", users)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// list of replication lag error
+var (
+	ErrReplicationStopped = errors.New("sqldb: replication is stopped")
+)
+
+// postgresLagQuery return the replay lag in seconds, the lag is zero when all received wal is replayed
+// so idle leader doesn't make the replica look stale
+const postgresLagQuery = `SELECT CASE
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+const mysqlLagQuery = "SHOW SLAVE STATUS"
+
+// ReplicationLag return how far the replica is behind the leader
+// the lag is zero when the database is not a replica
+func ReplicationLag(ctx context.Context, replica *sqlx.DB) (time.Duration, error) {
+	switch replica.DriverName() {
+	case DriverPostgres:
+		var seconds float64
+		if err := replica.QueryRowContext(ctx, postgresLagQuery).Scan(&seconds); err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+
+	case DriverMySQL:
+		return mysqlReplicationLag(ctx, replica)
+	}
+	return 0, fmt.Errorf("%w: %s", ErrDriverNotSupported, replica.DriverName())
+}
+
+// mysqlReplicationLag read Seconds_Behind_Master from the slave status
+// the status has many columns which is changed between versions, so the column is looked up by name
+func mysqlReplicationLag(ctx context.Context, replica *sqlx.DB) (time.Duration, error) {
+	rows, err := replica.QueryContext(ctx, mysqlLagQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		// not a replica
+		return 0, rows.Err()
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for idx := range values {
+		dest[idx] = &values[idx]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for idx, column := range columns {
+		if column != "Seconds_Behind_Master" {
+			continue
+		}
+		// null when the replication thread is not running
+		if values[idx] == nil {
+			return 0, ErrReplicationStopped
+		}
+		seconds, err := strconv.ParseInt(string(values[idx]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("sqldb: invalid Seconds_Behind_Master %q: %w", values[idx], err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("sqldb: Seconds_Behind_Master is not in slave status")
+}
+
+// checkLag mark the follower as stale when the lag is more than MaxLag, or when the lag is unknown
+func (db *DB) checkLag(ctx context.Context, f *follower) {
+	lagCtx, cancel := context.WithTimeout(ctx, db.followerOptions.HealthCheckTimeout)
+	defer cancel()
+
+	var stale int32
+	lag, err := ReplicationLag(lagCtx, f.db)
+	if err != nil || lag > db.followerOptions.MaxLag {
+		stale = 1
+	}
+	if err != nil {
+		lag = -1
+	}
+	f.setLag(lag, stale)
+}
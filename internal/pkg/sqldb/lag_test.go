@@ -0,0 +1,136 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestReplicationLag(t *testing.T) {
+	cases := []struct {
+		name      string
+		driver    string
+		expect    func(mock sqlmock.Sqlmock)
+		lag       time.Duration
+		expectErr error
+	}{
+		{
+			name:   "postgres",
+			driver: DriverPostgres,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(2.5))
+			},
+			lag: time.Millisecond * 2500,
+		},
+		{
+			name:   "mysql",
+			driver: DriverMySQL,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(
+					sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}).AddRow("Waiting for master to send event", "7"),
+				)
+			},
+			lag: time.Second * 7,
+		},
+		{
+			name:   "mysql is not replica",
+			driver: DriverMySQL,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}))
+			},
+			lag: 0,
+		},
+		{
+			name:   "mysql replication stopped",
+			driver: DriverMySQL,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(
+					sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}).AddRow("", nil),
+				)
+			},
+			expectErr: ErrReplicationStopped,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			replicadb, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer replicadb.Close()
+			c.expect(mock)
+
+			lag, err := ReplicationLag(context.Background(), sqlx.NewDb(replicadb, c.driver))
+			if !errors.Is(err, c.expectErr) {
+				t.Fatalf("expecting error %v but got %v", c.expectErr, err)
+			}
+			if lag != c.lag {
+				t.Errorf("expecting lag %s but got %s", c.lag, lag)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFollowerLag(t *testing.T) {
+	// followers are checked manually in the test
+	db, mocks := newMockFollowers(t, 2, &FollowerOptions{MaxLag: time.Second * 5, HealthCheckInterval: time.Hour})
+	defer db.Close()
+
+	lagRows := func(seconds float64) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"lag"}).AddRow(seconds)
+	}
+
+	// follower 1 is behind
+	mocks[0].ExpectPing()
+	mocks[0].ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows(0.1))
+	mocks[1].ExpectPing()
+	mocks[1].ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows(30))
+	db.CheckFollowers(context.Background())
+
+	for i := 0; i < 10; i++ {
+		if idx := followerIndex(db, db.Follower()); idx != 0 {
+			t.Fatalf("expecting stale follower to be skipped but got %d", idx)
+		}
+	}
+	stats := db.Stats()
+	if !stats.Followers[1].Stale || stats.Followers[1].Lag != time.Second*30 || !stats.Followers[1].Healthy {
+		t.Errorf("expecting follower 1 to be healthy but stale, got %+v", stats.Followers[1])
+	}
+
+	// all followers are behind or the lag is unknown, read from leader
+	mocks[0].ExpectPing()
+	mocks[0].ExpectQuery("pg_last_wal_replay_lsn").WillReturnError(errors.New("permission denied"))
+	mocks[1].ExpectPing()
+	mocks[1].ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows(10))
+	db.CheckFollowers(context.Background())
+	if db.Follower() != db.Leader() {
+		t.Error("expecting leader when all followers are stale")
+	}
+	if lag := db.Stats().Followers[0].Lag; lag != -1 {
+		t.Errorf("expecting unknown lag but got %s", lag)
+	}
+
+	// follower 1 catch up
+	mocks[0].ExpectPing()
+	mocks[0].ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows(6))
+	mocks[1].ExpectPing()
+	mocks[1].ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows(0))
+	db.CheckFollowers(context.Background())
+	if idx := followerIndex(db, db.Follower()); idx != 1 {
+		t.Errorf("expecting follower 1 but got %d", idx)
+	}
+
+	for _, mock := range mocks {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
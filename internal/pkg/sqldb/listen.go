@@ -0,0 +1,248 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/lib/pq"
+)
+
+// default value of listen options
+const (
+	DefaultListenMinReconnectInterval = time.Second
+	DefaultListenMaxReconnectInterval = time.Minute
+	DefaultListenPingInterval         = time.Second * 30
+	DefaultListenBufferSize           = 100
+)
+
+// list of listen error
+var (
+	ErrListenChannelEmpty = errors.New("sqldb: listen channel is empty")
+	ErrSubscriptionClosed = errors.New("sqldb: subscription is closed")
+	errListenerClosed     = errors.New("sqldb: listener is closed")
+)
+
+// ListenOptions of postgres subscription
+type ListenOptions struct {
+	// MinReconnectInterval is the wait time before reconnecting after the connection is lost, default to 1 second
+	// the wait time is doubled after every failed attempt until MaxReconnectInterval, default to 1 minute
+	MinReconnectInterval time.Duration
+	MaxReconnectInterval time.Duration
+	// PingInterval check the idle connection, so the lost connection is detected without waiting for the tcp timeout, default to 30 seconds
+	PingInterval time.Duration
+	// BufferSize of the notifications channel, default to 100
+	// the notifications are not dropped, so a slow receiver block the subscription when the buffer is full
+	BufferSize int
+	// OnError is called when the connection is lost, the connect attempt or the ping failed
+	OnError func(err error)
+}
+
+// Notification of postgres NOTIFY
+type Notification struct {
+	Channel string
+	Payload string
+	// PID of the backend which send the notification
+	PID int
+	// Reconnected is true when the connection is re-established, the channel and payload are empty
+	// notifications sent while the connection was lost are not delivered, so invalidate everything which depends on them
+	Reconnected bool
+}
+
+// listener is the dedicated connection of the subscription, it is *pq.Listener
+type listener interface {
+	Listen(channel string) error
+	Unlisten(channel string) error
+	Ping() error
+	Close() error
+	NotificationChannel() <-chan *pq.Notification
+}
+
+// newListener is replaced in test, so the subscription can be tested without postgres
+var newListener = func(dsn string, opts ListenOptions, callback pq.EventCallbackType) listener {
+	return pq.NewListener(dsn, opts.MinReconnectInterval, opts.MaxReconnectInterval, callback)
+}
+
+// Subscription of postgres LISTEN on a dedicated connection
+// the connection is re-established and the channels are listened again after the connection is lost
+type Subscription struct {
+	opts          ListenOptions
+	listener      listener
+	notifications chan Notification
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Listen to the postgres channels on a new connection of the dsn, the connection is not part of the pool of DB
+// it block until all channels are listened or the context is done, the connection is retried in the meantime
+//
+//	sub, err := sqldb.Listen(ctx, dsn, nil, "cache_invalidation")
+//	defer sub.Close()
+//	for n := range sub.Notifications() {
+//		if n.Reconnected {
+//			cache.Purge()
+//			continue
+//		}
+//		cache.Delete(n.Payload)
+//	}
+func Listen(ctx context.Context, dsn string, options *ListenOptions, channels ...string) (*Subscription, error) {
+	opts := ListenOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MinReconnectInterval <= 0 {
+		opts.MinReconnectInterval = DefaultListenMinReconnectInterval
+	}
+	if opts.MaxReconnectInterval < opts.MinReconnectInterval {
+		opts.MaxReconnectInterval = DefaultListenMaxReconnectInterval
+		if opts.MaxReconnectInterval < opts.MinReconnectInterval {
+			opts.MaxReconnectInterval = opts.MinReconnectInterval
+		}
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = DefaultListenPingInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultListenBufferSize
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+	if len(channels) == 0 {
+		return nil, ErrListenChannelEmpty
+	}
+	for _, channel := range channels {
+		if channel == "" {
+			return nil, ErrListenChannelEmpty
+		}
+	}
+
+	s := Subscription{
+		opts:          opts,
+		notifications: make(chan Notification, opts.BufferSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	s.listener = newListener(dsn, opts, s.event)
+
+	// listen block until the connection is established, so it is interrupted by closing the listener
+	listened := safego.Go(ctx, "sqldb/listen/listen", func(ctx context.Context) error {
+		for _, channel := range channels {
+			if err := s.listener.Listen(channel); err != nil {
+				return fmt.Errorf("sqldb: failed to listen %s: %w", channel, err)
+			}
+		}
+		return nil
+	})
+	select {
+	case err := <-listened:
+		if err != nil {
+			s.listener.Close()
+			return nil, err
+		}
+	case <-ctx.Done():
+		s.listener.Close()
+		return nil, ctx.Err()
+	}
+
+	// the subscription outlive ctx, a panic in OnError stop the subscription instead of the process
+	safego.Go(safego.Detach(ctx), "sqldb/listen/run", func(ctx context.Context) error {
+		s.run()
+		return nil
+	})
+	return &s, nil
+}
+
+// Notifications return the channel of the notifications, the channel is closed after the subscription is closed
+func (s *Subscription) Notifications() <-chan Notification {
+	return s.notifications
+}
+
+// Listen to another channel, it block until the connection is established
+func (s *Subscription) Listen(channel string) error {
+	if channel == "" {
+		return ErrListenChannelEmpty
+	}
+	if s.closed() {
+		return ErrSubscriptionClosed
+	}
+	return s.listener.Listen(channel)
+}
+
+// Unlisten stop receiving the notifications of the channel
+func (s *Subscription) Unlisten(channel string) error {
+	if s.closed() {
+		return ErrSubscriptionClosed
+	}
+	return s.listener.Unlisten(channel)
+}
+
+// Close the connection of the subscription and the notifications channel
+func (s *Subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = s.listener.Close()
+		close(s.notifications)
+	})
+	return err
+}
+
+func (s *Subscription) closed() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// event of the connection, called by the listener
+func (s *Subscription) event(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		s.opts.OnError(fmt.Errorf("sqldb: listen connection is lost: %w", err))
+	case pq.ListenerEventConnectionAttemptFailed:
+		s.opts.OnError(fmt.Errorf("sqldb: failed to connect listen connection: %w", err))
+	}
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.PingInterval)
+	defer ticker.Stop()
+
+	source := s.listener.NotificationChannel()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case n, ok := <-source:
+			if !ok {
+				s.opts.OnError(errListenerClosed)
+				return
+			}
+			// nil is sent by the listener after the connection is re-established
+			notification := Notification{Reconnected: true}
+			if n != nil {
+				notification = Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid}
+			}
+			select {
+			case s.notifications <- notification:
+			case <-s.stop:
+				return
+			}
+		case <-ticker.C:
+			// the listener reconnect when the ping failed
+			if err := s.listener.Ping(); err != nil {
+				s.opts.OnError(fmt.Errorf("sqldb: failed to ping listen connection: %w", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type fakeListener struct {
+	mu       sync.Mutex
+	channels []string
+	blocked  chan struct{}
+	closed   bool
+	notify   chan *pq.Notification
+}
+
+func (f *fakeListener) Listen(channel string) error {
+	if f.blocked != nil {
+		<-f.blocked
+		return errors.New("listener closed")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.channels = append(f.channels, channel)
+	return nil
+}
+
+func (f *fakeListener) Unlisten(channel string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for idx, c := range f.channels {
+		if c == channel {
+			f.channels = append(f.channels[:idx], f.channels[idx+1:]...)
+			return nil
+		}
+	}
+	return errors.New("channel not open")
+}
+
+func (f *fakeListener) Ping() error {
+	return nil
+}
+
+func (f *fakeListener) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed && f.blocked != nil {
+		close(f.blocked)
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *fakeListener) NotificationChannel() <-chan *pq.Notification {
+	return f.notify
+}
+
+func withFakeListener(t *testing.T, f *fakeListener) func() {
+	t.Helper()
+	original := newListener
+	newListener = func(dsn string, opts ListenOptions, callback pq.EventCallbackType) listener {
+		return f
+	}
+	return func() {
+		newListener = original
+	}
+}
+
+func TestListen(t *testing.T) {
+	f := &fakeListener{notify: make(chan *pq.Notification, 3)}
+	defer withFakeListener(t, f)()
+
+	ctx := context.Background()
+	if _, err := Listen(ctx, "postgres://localhost/test", nil); !errors.Is(err, ErrListenChannelEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrListenChannelEmpty, err)
+	}
+	sub, err := Listen(ctx, "postgres://localhost/test", nil, "cache_invalidation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Listen("user_changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Unlisten("cache_invalidation"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.channels) != 1 || f.channels[0] != "user_changed" {
+		t.Fatalf("expecting channels [user_changed] but got %v", f.channels)
+	}
+
+	f.notify <- &pq.Notification{BePid: 10, Channel: "user_changed", Extra: "user:1"}
+	f.notify <- nil
+	expect := []Notification{
+		{Channel: "user_changed", Payload: "user:1", PID: 10},
+		{Reconnected: true},
+	}
+	for _, e := range expect {
+		select {
+		case n := <-sub.Notifications():
+			if n != e {
+				t.Fatalf("expecting notification %+v but got %+v", e, n)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expecting notification %+v but got nothing", e)
+		}
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !f.closed {
+		t.Fatal("expecting the listener is closed")
+	}
+	if _, ok := <-sub.Notifications(); ok {
+		t.Fatal("expecting the notifications channel is closed")
+	}
+	if err := sub.Listen("user_changed"); !errors.Is(err, ErrSubscriptionClosed) {
+		t.Fatalf("expecting error %v but got %v", ErrSubscriptionClosed, err)
+	}
+	// close can be called again
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenContextDone(t *testing.T) {
+	// listen block as the connection is never established
+	f := &fakeListener{notify: make(chan *pq.Notification), blocked: make(chan struct{})}
+	defer withFakeListener(t, f)()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := Listen(ctx, "postgres://localhost/test", nil, "cache_invalidation"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expecting error %v but got %v", context.DeadlineExceeded, err)
+	}
+	if !f.closed {
+		t.Fatal("expecting the listener is closed")
+	}
+}
+
+func TestListenPanic(t *testing.T) {
+	f := &fakeListener{notify: make(chan *pq.Notification)}
+	defer withFakeListener(t, f)()
+
+	// the listener closed its notification channel, and OnError panic
+	called := make(chan struct{})
+	opts := &ListenOptions{OnError: func(err error) {
+		close(called)
+		panic(err)
+	}}
+	sub, err := Listen(context.Background(), "postgres://localhost/test", opts, "cache_invalidation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(f.notify)
+	<-called
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- sub.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expecting the subscription is closed after the panic")
+	}
+}
@@ -0,0 +1,468 @@
+// Package matview refresh postgres materialized views on schedule, on trigger, or before read when the view is stale
+//
+//	refresher, err := matview.New(db, []matview.View{
+//		{Name: "daily_sales", Interval: time.Hour, Concurrently: true},
+//		{Name: "monthly_sales", RefreshAfter: []string{"daily_sales"}},
+//	}, nil)
+//	err = refresher.Start(ctx)
+//	defer refresher.Close()
+//
+// the refresh is guarded by advisory lock, so only one instance refresh the same view at a time
+// the refresh time is kept in a table, so every instance share the same schedule and staleness
+package matview
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// default value for options
+const (
+	DefaultTable         = "materialized_view_refreshes"
+	DefaultCheckInterval = time.Second * 10
+)
+
+// list of error
+var (
+	ErrViewNotFound       = errors.New("matview: view not found")
+	ErrDuplicateView      = errors.New("matview: duplicate view")
+	ErrInvalidName        = errors.New("matview: invalid name")
+	ErrCycle              = errors.New("matview: cycle in refresh after")
+	ErrDriverNotSupported = errors.New("matview: only postgres is supported")
+)
+
+// name allow schema qualified name, the name is not a query parameter so it is checked
+var name = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// View is a materialized view to refresh
+type View struct {
+	Name string
+	// Interval between refresh, the view is only refreshed by Trigger, EnsureFresh or RefreshAfter when zero
+	Interval time.Duration
+	// Concurrently refresh the view without blocking reads, the view must have a unique index
+	Concurrently bool
+	// RefreshAfter is the list of views which trigger refresh of this view after they are refreshed
+	RefreshAfter []string
+}
+
+// Options of refresher
+type Options struct {
+	// Table to keep the refresh time, default to materialized_view_refreshes
+	Table string
+	// CheckInterval of the schedule, default to 10s
+	CheckInterval time.Duration
+	// OnError is invoked when scheduled or triggered refresh failed
+	OnError func(view string, err error)
+	// Now is used for the refresh time, default to time.Now
+	Now func() time.Time
+}
+
+// Status of view
+type Status struct {
+	Name string `json:"name"`
+	// RefreshedAt is nil when the view is never refreshed by the refresher
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"`
+}
+
+// Refresher of materialized views
+type Refresher struct {
+	db    *sqldb.DB
+	views map[string]View
+	names []string
+	// dependents of view, refreshed after the view is refreshed
+	dependents map[string][]string
+	opts       Options
+
+	mu          sync.Mutex
+	refreshedAt map[string]time.Time
+	durations   map[string]float64
+	failures    map[string]float64
+	pending     map[string]bool
+
+	trigger   chan struct{}
+	stop      chan struct{}
+	done      <-chan error
+	closeOnce sync.Once
+}
+
+// New refresher of the views
+func New(db *sqldb.DB, views []View, options *Options) (*Refresher, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = DefaultCheckInterval
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if db.Leader().DriverName() != sqldb.DriverPostgres {
+		return nil, ErrDriverNotSupported
+	}
+	if !name.MatchString(opts.Table) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidName, opts.Table)
+	}
+
+	r := Refresher{
+		db:          db,
+		views:       make(map[string]View, len(views)),
+		dependents:  make(map[string][]string),
+		opts:        opts,
+		refreshedAt: make(map[string]time.Time),
+		durations:   make(map[string]float64),
+		failures:    make(map[string]float64),
+		pending:     make(map[string]bool),
+		trigger:     make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+	for _, view := range views {
+		if !name.MatchString(view.Name) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, view.Name)
+		}
+		if _, ok := r.views[view.Name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateView, view.Name)
+		}
+		r.views[view.Name] = view
+		r.names = append(r.names, view.Name)
+	}
+	sort.Strings(r.names)
+	for _, view := range views {
+		for _, after := range view.RefreshAfter {
+			if _, ok := r.views[after]; !ok {
+				return nil, fmt.Errorf("%w: %s refresh after %s", ErrViewNotFound, view.Name, after)
+			}
+			r.dependents[after] = append(r.dependents[after], view.Name)
+		}
+	}
+	if err := r.checkCycle(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// checkCycle return error when a view is refreshed after itself
+func (r *Refresher) checkCycle() error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(r.views))
+	var visit func(view string) error
+	visit = func(view string) error {
+		switch state[view] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrCycle, view)
+		case visited:
+			return nil
+		}
+		state[view] = visiting
+		for _, dependent := range r.dependents[view] {
+			if err := visit(dependent); err != nil {
+				return err
+			}
+		}
+		state[view] = visited
+		return nil
+	}
+	for _, view := range r.names {
+		if err := visit(view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Init create the table of refresh time when it doesn't exist
+func (r *Refresher) Init(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	view_name VARCHAR(255) PRIMARY KEY,
+	refreshed_at TIMESTAMP WITH TIME ZONE NOT NULL
+)`, r.opts.Table)
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("matview: failed to create table %s: %w", r.opts.Table, err)
+	}
+	return nil
+}
+
+// Statuses return status of all views, ordered by name
+func (r *Refresher) Statuses(ctx context.Context) ([]Status, error) {
+	if err := r.load(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]Status, 0, len(r.names))
+	for _, view := range r.names {
+		status := Status{Name: view}
+		if at, ok := r.refreshedAt[view]; ok {
+			status.RefreshedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// load the refresh time of all views from the leader
+func (r *Refresher) load(ctx context.Context) error {
+	var rows []struct {
+		Name        string    `db:"view_name"`
+		RefreshedAt time.Time `db:"refreshed_at"`
+	}
+	query := fmt.Sprintf("SELECT view_name, refreshed_at FROM %s", r.opts.Table)
+	if err := r.db.SelectContext(sqldb.ForceLeader(ctx), &rows, query); err != nil {
+		return fmt.Errorf("matview: failed to load refresh time: %w", err)
+	}
+	r.mu.Lock()
+	for _, row := range rows {
+		if _, ok := r.views[row.Name]; ok {
+			r.refreshedAt[row.Name] = row.RefreshedAt
+		}
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// Refresh the view, and then the views which are refreshed after it
+// false is returned when the view is being refreshed by another instance
+func (r *Refresher) Refresh(ctx context.Context, view string) (bool, error) {
+	if _, ok := r.views[view]; !ok {
+		return false, fmt.Errorf("%w: %s", ErrViewNotFound, view)
+	}
+	refreshed, err := r.refresh(ctx, view, false, 0)
+	if err != nil || !refreshed {
+		return refreshed, err
+	}
+	return true, r.refreshDependents(ctx, view)
+}
+
+// EnsureFresh refresh the view when it is refreshed more than maxAge ago, to read through a stale view
+// it wait for refresh by another instance, and skip the refresh when the view is fresh after the wait
+func (r *Refresher) EnsureFresh(ctx context.Context, view string, maxAge time.Duration) error {
+	if _, ok := r.views[view]; !ok {
+		return fmt.Errorf("%w: %s", ErrViewNotFound, view)
+	}
+	if err := r.load(ctx); err != nil {
+		return err
+	}
+	if r.fresh(view, maxAge) {
+		return nil
+	}
+	refreshed, err := r.refresh(ctx, view, true, maxAge)
+	if err != nil || !refreshed {
+		return err
+	}
+	return r.refreshDependents(ctx, view)
+}
+
+func (r *Refresher) refreshDependents(ctx context.Context, view string) error {
+	for _, dependent := range r.dependents[view] {
+		if _, err := r.Refresh(ctx, dependent); err != nil {
+			return fmt.Errorf("matview: refresh %s after %s: %w", dependent, view, err)
+		}
+	}
+	return nil
+}
+
+// fresh return true when the view is refreshed within maxAge
+func (r *Refresher) fresh(view string, maxAge time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at, ok := r.refreshedAt[view]
+	return ok && r.opts.Now().Sub(at) < maxAge
+}
+
+// refresh the view in a transaction with advisory lock of the view
+// when wait is true, the lock is waited and the refresh is skipped when the view is refreshed within maxAge by the lock holder
+func (r *Refresher) refresh(ctx context.Context, view string, wait bool, maxAge time.Duration) (bool, error) {
+	var (
+		start     = r.opts.Now()
+		refreshed bool
+		now       time.Time
+	)
+	err := r.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		if wait {
+			if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", r.lockKey(view)); err != nil {
+				return err
+			}
+			var at time.Time
+			err := tx.GetContext(ctx, &at, fmt.Sprintf("SELECT refreshed_at FROM %s WHERE view_name = $1", r.opts.Table), view)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if err == nil && r.opts.Now().Sub(at) < maxAge {
+				r.setRefreshedAt(view, at)
+				return nil
+			}
+		} else {
+			var locked bool
+			if err := tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock($1)", r.lockKey(view)); err != nil {
+				return err
+			}
+			if !locked {
+				return nil
+			}
+		}
+
+		query := "REFRESH MATERIALIZED VIEW "
+		if r.views[view].Concurrently {
+			query += "CONCURRENTLY "
+		}
+		if _, err := tx.ExecContext(ctx, query+view); err != nil {
+			return err
+		}
+
+		now = r.opts.Now()
+		upsert, args, err := sqldb.BuildUpsert(sqldb.DriverPostgres, r.opts.Table, []string{"view_name"}, map[string]interface{}{
+			"view_name":    view,
+			"refreshed_at": now,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, upsert, args...); err != nil {
+			return err
+		}
+		refreshed = true
+		return nil
+	})
+	if err != nil {
+		r.mu.Lock()
+		r.failures[view]++
+		r.mu.Unlock()
+		return false, fmt.Errorf("matview: failed to refresh %s: %w", view, err)
+	}
+	if refreshed {
+		r.mu.Lock()
+		r.refreshedAt[view] = now
+		r.durations[view] = now.Sub(start).Seconds()
+		r.mu.Unlock()
+	}
+	return refreshed, nil
+}
+
+func (r *Refresher) setRefreshedAt(view string, at time.Time) {
+	r.mu.Lock()
+	r.refreshedAt[view] = at
+	r.mu.Unlock()
+}
+
+// lockKey return advisory lock key of the view
+func (r *Refresher) lockKey(view string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(r.opts.Table + ":" + view))
+	return int64(h.Sum64())
+}
+
+// Trigger refresh of the view in the background, the refresher must be started
+func (r *Refresher) Trigger(view string) error {
+	if _, ok := r.views[view]; !ok {
+		return fmt.Errorf("%w: %s", ErrViewNotFound, view)
+	}
+	r.mu.Lock()
+	r.pending[view] = true
+	r.mu.Unlock()
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start create the table and refresh the views on schedule until Close is called
+func (r *Refresher) Start(ctx context.Context) error {
+	if err := r.Init(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done != nil {
+		return nil
+	}
+	r.done = safego.Go(safego.Detach(ctx), "matview.refresher", r.run)
+	return nil
+}
+
+// Close stop the schedule and wait for the running refresh
+func (r *Refresher) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	return <-done
+}
+
+func (r *Refresher) run(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.CheckInterval)
+	defer ticker.Stop()
+	r.refreshDue(ctx)
+	for {
+		select {
+		case <-r.stop:
+			return nil
+		case <-ticker.C:
+			r.refreshDue(ctx)
+		case <-r.trigger:
+			r.refreshPending(ctx)
+		}
+	}
+}
+
+// refreshDue refresh views which are not refreshed within their interval
+func (r *Refresher) refreshDue(ctx context.Context) {
+	if err := r.load(ctx); err != nil {
+		r.onError("", err)
+		return
+	}
+	for _, view := range r.names {
+		interval := r.views[view].Interval
+		if interval <= 0 || r.fresh(view, interval) {
+			continue
+		}
+		if _, err := r.Refresh(ctx, view); err != nil {
+			r.onError(view, err)
+		}
+	}
+	r.refreshPending(ctx)
+}
+
+func (r *Refresher) refreshPending(ctx context.Context) {
+	r.mu.Lock()
+	pending := make([]string, 0, len(r.pending))
+	for view := range r.pending {
+		pending = append(pending, view)
+	}
+	r.pending = make(map[string]bool)
+	r.mu.Unlock()
+	sort.Strings(pending)
+
+	for _, view := range pending {
+		if _, err := r.Refresh(ctx, view); err != nil {
+			r.onError(view, err)
+		}
+	}
+}
+
+func (r *Refresher) onError(view string, err error) {
+	if r.opts.OnError != nil {
+		r.opts.OnError(view, err)
+	}
+}
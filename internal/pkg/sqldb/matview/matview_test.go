@@ -0,0 +1,258 @@
+package matview
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var refreshedAt = time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+
+var testViews = []View{
+	{Name: "daily_sales", Interval: time.Hour, Concurrently: true},
+	{Name: "monthly_sales", RefreshAfter: []string{"daily_sales"}},
+}
+
+func newMockRefresher(t *testing.T, views []View, now time.Time) (*Refresher, sqlmock.Sqlmock) {
+	t.Helper()
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(db, views, &Options{Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, mock
+}
+
+func expectRefresh(mock sqlmock.Sqlmock, r *Refresher, query, view string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+		WithArgs(r.lockKey(view)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO materialized_view_refreshes (refreshed_at, view_name) VALUES ($1, $2)")).
+		WithArgs(refreshedAt, view).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name  string
+		views []View
+		err   error
+	}{
+		{name: "valid", views: testViews},
+		{name: "invalid name", views: []View{{Name: "sales; DROP TABLE users"}}, err: ErrInvalidName},
+		{name: "duplicate", views: []View{{Name: "sales"}, {Name: "sales"}}, err: ErrDuplicateView},
+		{name: "unknown refresh after", views: []View{{Name: "sales", RefreshAfter: []string{"orders"}}}, err: ErrViewNotFound},
+		{
+			name: "cycle",
+			views: []View{
+				{Name: "a", RefreshAfter: []string{"c"}},
+				{Name: "b", RefreshAfter: []string{"a"}},
+				{Name: "c", RefreshAfter: []string{"b"}},
+			},
+			err: ErrCycle,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockdb, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+			db, err := sqldb.Wrap(context.Background(), leader, leader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := New(db, c.views, nil); !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestNewDriverNotSupported(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverMySQL)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(db, testViews, nil); !errors.Is(err, ErrDriverNotSupported) {
+		t.Fatalf("expect error %v but got %v", ErrDriverNotSupported, err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	r, mock := newMockRefresher(t, testViews, refreshedAt)
+	expectRefresh(mock, r, "REFRESH MATERIALIZED VIEW CONCURRENTLY daily_sales", "daily_sales")
+	expectRefresh(mock, r, "REFRESH MATERIALIZED VIEW monthly_sales", "monthly_sales")
+
+	refreshed, err := r.Refresh(context.Background(), "daily_sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refreshed {
+		t.Fatal("expect view to be refreshed")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if at := r.refreshedAt["monthly_sales"]; !at.Equal(refreshedAt) {
+		t.Fatalf("expect monthly_sales refreshed at %s but got %s", refreshedAt, at)
+	}
+}
+
+func TestRefreshLocked(t *testing.T) {
+	r, mock := newMockRefresher(t, testViews, refreshedAt)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectCommit()
+
+	refreshed, err := r.Refresh(context.Background(), "daily_sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed {
+		t.Fatal("expect view to be skipped")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshFailed(t *testing.T) {
+	r, mock := newMockRefresher(t, testViews, refreshedAt)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("REFRESH MATERIALIZED VIEW monthly_sales")).WillReturnError(errors.New("no unique index"))
+	mock.ExpectRollback()
+
+	if _, err := r.Refresh(context.Background(), "monthly_sales"); err == nil {
+		t.Fatal("expect error")
+	}
+	if _, err := r.Refresh(context.Background(), "weekly_sales"); !errors.Is(err, ErrViewNotFound) {
+		t.Fatalf("expect error %v but got %v", ErrViewNotFound, err)
+	}
+	if failures := r.failures["monthly_sales"]; failures != 1 {
+		t.Fatalf("expect 1 failure but got %v", failures)
+	}
+}
+
+func TestEnsureFresh(t *testing.T) {
+	views := []View{{Name: "daily_sales"}}
+	cases := []struct {
+		name   string
+		now    time.Time
+		expect func(mock sqlmock.Sqlmock, r *Refresher)
+	}{
+		{
+			name: "fresh",
+			now:  refreshedAt.Add(time.Minute),
+			expect: func(mock sqlmock.Sqlmock, r *Refresher) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT view_name, refreshed_at FROM materialized_view_refreshes")).
+					WillReturnRows(sqlmock.NewRows([]string{"view_name", "refreshed_at"}).AddRow("daily_sales", refreshedAt))
+			},
+		},
+		{
+			name: "refreshed by another instance",
+			now:  refreshedAt.Add(time.Minute),
+			expect: func(mock sqlmock.Sqlmock, r *Refresher) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT view_name, refreshed_at FROM materialized_view_refreshes")).
+					WillReturnRows(sqlmock.NewRows([]string{"view_name", "refreshed_at"}).AddRow("daily_sales", refreshedAt.Add(-time.Hour)))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).
+					WithArgs(r.lockKey("daily_sales")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT refreshed_at FROM materialized_view_refreshes WHERE view_name = $1")).
+					WithArgs("daily_sales").
+					WillReturnRows(sqlmock.NewRows([]string{"refreshed_at"}).AddRow(refreshedAt))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "stale",
+			now:  refreshedAt,
+			expect: func(mock sqlmock.Sqlmock, r *Refresher) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT view_name, refreshed_at FROM materialized_view_refreshes")).
+					WillReturnRows(sqlmock.NewRows([]string{"view_name", "refreshed_at"}))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT refreshed_at FROM materialized_view_refreshes WHERE view_name = $1")).
+					WillReturnRows(sqlmock.NewRows([]string{"refreshed_at"}))
+				mock.ExpectExec(regexp.QuoteMeta("REFRESH MATERIALIZED VIEW daily_sales")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO materialized_view_refreshes")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, mock := newMockRefresher(t, views, c.now)
+			c.expect(mock, r)
+			if err := r.EnsureFresh(context.Background(), "daily_sales", time.Minute*5); err != nil {
+				t.Fatal(err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	r, _ := newMockRefresher(t, testViews, refreshedAt.Add(time.Minute))
+	r.refreshedAt["daily_sales"] = refreshedAt
+	r.failures["monthly_sales"] = 2
+
+	ch := make(chan prometheus.Metric, 10)
+	r.Collect(ch)
+	close(ch)
+	values := make(map[*prometheus.Desc]float64)
+	for m := range ch {
+		metric := dto.Metric{}
+		if err := m.Write(&metric); err != nil {
+			t.Fatal(err)
+		}
+		if metric.Gauge != nil {
+			values[m.Desc()] = metric.Gauge.GetValue()
+		} else {
+			values[m.Desc()] = metric.Counter.GetValue()
+		}
+	}
+	if len(values) != 2 {
+		t.Fatalf("expect 2 metrics but got %d", len(values))
+	}
+	if staleness := values[stalenessDesc]; staleness != 60 {
+		t.Fatalf("expect staleness of 60 seconds but got %v", staleness)
+	}
+	if failures := values[refreshFailuresDesc]; failures != 2 {
+		t.Fatalf("expect 2 failures but got %v", failures)
+	}
+}
@@ -0,0 +1,48 @@
+package matview
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// list of metrics descriptor
+var (
+	stalenessDesc = prometheus.NewDesc(
+		"sqldb_materialized_view_staleness_seconds",
+		"seconds since the last refresh of the materialized view",
+		[]string{"view"}, nil,
+	)
+	refreshDurationDesc = prometheus.NewDesc(
+		"sqldb_materialized_view_refresh_duration_seconds",
+		"duration of the last refresh of the materialized view",
+		[]string{"view"}, nil,
+	)
+	refreshFailuresDesc = prometheus.NewDesc(
+		"sqldb_materialized_view_refresh_failures_total",
+		"number of failed refresh of the materialized view",
+		[]string{"view"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector
+func (r *Refresher) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stalenessDesc
+	ch <- refreshDurationDesc
+	ch <- refreshFailuresDesc
+}
+
+// Collect implements prometheus.Collector
+// the staleness is based on the last refresh time known by the refresher, view which is never refreshed is not exported
+func (r *Refresher) Collect(ch chan<- prometheus.Metric) {
+	now := r.opts.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for view, at := range r.refreshedAt {
+		ch <- prometheus.MustNewConstMetric(stalenessDesc, prometheus.GaugeValue, now.Sub(at).Seconds(), view)
+	}
+	for view, v := range r.durations {
+		ch <- prometheus.MustNewConstMetric(refreshDurationDesc, prometheus.GaugeValue, v, view)
+	}
+	for view, v := range r.failures {
+		ch <- prometheus.MustNewConstMetric(refreshFailuresDesc, prometheus.CounterValue, v, view)
+	}
+}
@@ -0,0 +1,60 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Query is the query passed to the middlewares
+type Query struct {
+	Query string
+	// Args of the query, or the struct or map of named parameters as the only argument when Named is true
+	Args  []interface{}
+	Named bool
+	// Exec is true for ExecContext and NamedExecContext
+	Exec bool
+	// Tx is true when the query run in transaction
+	Tx bool
+}
+
+// QueryFunc run the query, the result is nil for query which is not exec
+type QueryFunc func(ctx context.Context, q Query) (sql.Result, error)
+
+// Middleware wrap the QueryFunc, for example to audit the query, check the tenant of the query or record metrics
+// the middleware can change the query before calling next, or return error without calling next to reject the query
+//
+//	db.Use(func(next sqldb.QueryFunc) sqldb.QueryFunc {
+//		return func(ctx context.Context, q sqldb.Query) (sql.Result, error) {
+//			if tenant.FromContext(ctx) == "" && !q.Tx {
+//				return nil, errTenantRequired
+//			}
+//			return next(ctx, q)
+//		}
+//	})
+//
+// the middlewares run once per query, retry attempts are inside the middlewares and observed by hooks
+type Middleware func(next QueryFunc) QueryFunc
+
+// Use add middlewares to the db, the first middleware is the outermost
+// middlewares must be added before the db is used
+func (db *DB) Use(middlewares ...Middleware) {
+	db.middlewares = append(db.middlewares, middlewares...)
+}
+
+// intercept run fn through the middlewares
+func intercept(ctx context.Context, middlewares []Middleware, q Query, fn QueryFunc) (sql.Result, error) {
+	for idx := len(middlewares) - 1; idx >= 0; idx-- {
+		fn = middlewares[idx](fn)
+	}
+	return fn(ctx, q)
+}
+
+// canceledRow return row which Scan return context.Canceled
+// sql.Row can't be created with error, so this is used when the middleware of QueryRowContext reject the query
+func canceledRow(ctx context.Context, conn interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, query string) *sql.Row {
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	return conn.QueryRowContext(ctx, query)
+}
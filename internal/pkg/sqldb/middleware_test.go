@@ -0,0 +1,116 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMiddleware(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	var order []string
+	record := func(name string) Middleware {
+		return func(next QueryFunc) QueryFunc {
+			return func(ctx context.Context, q Query) (sql.Result, error) {
+				order = append(order, name)
+				return next(ctx, q)
+			}
+		}
+	}
+	// comment the query with the caller, so the query can be found in the database log
+	comment := func(next QueryFunc) QueryFunc {
+		return func(ctx context.Context, q Query) (sql.Result, error) {
+			q.Query = "/* booking */ " + q.Query
+			return next(ctx, q)
+		}
+	}
+	db.Use(record("first"), record("second"), comment)
+
+	leaderMock.ExpectExec(`/\* booking \*/ UPDATE orders`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	followerMock.ExpectQuery(`/\* booking \*/ SELECT id`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	result, err := db.ExecContext(context.Background(), "UPDATE orders SET status = 1 WHERE id = $1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _ := result.RowsAffected(); rows != 2 {
+		t.Fatalf("expecting 2 rows affected but got %d", rows)
+	}
+	var ids []int
+	if err := db.SelectContext(context.Background(), &ids, "SELECT id FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "first,second,first,second" {
+		t.Fatalf("expecting middlewares run in order but got %v", order)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiddlewareReject(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	errTenant := errors.New("tenant is required")
+	var queries []Query
+	db.Use(func(next QueryFunc) QueryFunc {
+		return func(ctx context.Context, q Query) (sql.Result, error) {
+			queries = append(queries, q)
+			if !strings.Contains(q.Query, "tenant_id") {
+				return nil, errTenant
+			}
+			return next(ctx, q)
+		}
+	})
+
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM orders"); !errors.Is(err, errTenant) {
+		t.Fatalf("expecting error %v but got %v", errTenant, err)
+	}
+	var id int
+	if err := db.GetContext(context.Background(), &id, "SELECT id FROM orders"); !errors.Is(err, errTenant) {
+		t.Fatalf("expecting error %v but got %v", errTenant, err)
+	}
+	if err := db.QueryRowContext(context.Background(), "SELECT id FROM orders").Scan(&id); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expecting error %v but got %v", context.Canceled, err)
+	}
+	if _, err := db.NamedExecContext(context.Background(), "DELETE FROM orders WHERE id = :id", map[string]interface{}{"id": 1}); !errors.Is(err, errTenant) {
+		t.Fatalf("expecting error %v but got %v", errTenant, err)
+	}
+	if last := queries[len(queries)-1]; !last.Named || !last.Exec || len(last.Args) != 1 {
+		t.Fatalf("expecting named exec with one argument but got %+v", last)
+	}
+
+	leaderMock.ExpectBegin()
+	leaderMock.ExpectExec("UPDATE orders").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	leaderMock.ExpectRollback()
+	err := db.WithTransaction(context.Background(), func(tx *Tx) error {
+		if _, err := tx.ExecContext(context.Background(), "UPDATE orders SET status = 1 WHERE tenant_id = $1", 1); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(context.Background(), "UPDATE orders SET status = 2")
+		return err
+	})
+	if !errors.Is(err, errTenant) {
+		t.Fatalf("expecting error %v but got %v", errTenant, err)
+	}
+	if last := queries[len(queries)-1]; !last.Tx {
+		t.Fatalf("expecting query in transaction but got %+v", last)
+	}
+
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
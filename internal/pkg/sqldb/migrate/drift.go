@@ -0,0 +1,138 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// DefaultSnapshotTable of the schema snapshot after the migrations
+const DefaultSnapshotTable = "schema_snapshots"
+
+// ErrSnapshotNotFound is returned when the schema is never snapshotted by Up, Down or Snapshot
+var ErrSnapshotNotFound = errors.New("migrate: schema snapshot is not found")
+
+// Drift of the live schema from the schema after the applied migrations
+type Drift struct {
+	// Version of the latest applied migration
+	Version int64 `json:"version"`
+	// SnapshotVersion is the latest applied migration when the snapshot is taken
+	// it is behind Version when the migration is applied without the migrator
+	SnapshotVersion int64     `json:"snapshot_version"`
+	SnapshotAt      time.Time `json:"snapshot_at"`
+	Changes         []Change  `json:"changes,omitempty"`
+}
+
+// Drifted return true when the schema is changed outside of the migrations
+func (d Drift) Drifted() bool {
+	return len(d.Changes) > 0 || d.SnapshotVersion != d.Version
+}
+
+type schemaSnapshot struct {
+	Version    int64     `db:"version"`
+	Definition string    `db:"definition"`
+	TakenAt    time.Time `db:"taken_at"`
+}
+
+// snapshotSupported return true when the schema of the database can be inspected
+func (m *Migrator) snapshotSupported() bool {
+	_, ok := schemaQueries[sqldb.DriverName(m.db.Leader().DriverName())]
+	return ok
+}
+
+// inspect the schema without the tables of the migrator
+func (m *Migrator) inspect(ctx context.Context) (Schema, error) {
+	schema, err := Inspect(ctx, m.db)
+	if err != nil {
+		return Schema{}, err
+	}
+	return schema.without(m.opts.Table, m.opts.SnapshotTable), nil
+}
+
+// latestSnapshot return the last taken snapshot of the schema
+func (m *Migrator) latestSnapshot(ctx context.Context) (schemaSnapshot, error) {
+	var snapshot schemaSnapshot
+	query := "SELECT version, definition, taken_at FROM " + m.opts.SnapshotTable + " ORDER BY version DESC LIMIT 1"
+	if err := m.db.Leader().GetContext(ctx, &snapshot, query); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return snapshot, ErrSnapshotNotFound
+		}
+		return snapshot, fmt.Errorf("migrate: failed to get schema snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// snapshot the live schema as the expected schema of the migration version, only the latest snapshot is kept
+func (m *Migrator) snapshot(ctx context.Context, version int64) error {
+	schema, err := m.inspect(ctx)
+	if err != nil {
+		return err
+	}
+	definition, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	err = m.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+m.opts.SnapshotTable); err != nil {
+			return err
+		}
+		query := tx.Rebind("INSERT INTO " + m.opts.SnapshotTable + " (version, definition, taken_at) VALUES (?, ?, ?)")
+		_, err := tx.ExecContext(ctx, query, version, string(definition), m.opts.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: failed to snapshot schema of version %d: %w", version, err)
+	}
+	return nil
+}
+
+// Snapshot take the live schema as the expected schema of the latest applied migration
+// Up and Down take the snapshot after the migrations, so Snapshot is only needed to accept a drift after it is reviewed
+func (m *Migrator) Snapshot(ctx context.Context) error {
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return m.snapshot(ctx, latestVersion(versions))
+}
+
+// Drift compare the live schema with the snapshot after the applied migrations
+// the columns are compared by name, type, max length and nullable, so a hand-applied change to the schema is found before
+// the next migration failed because of it
+func (m *Migrator) Drift(ctx context.Context) (Drift, error) {
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return Drift{}, err
+	}
+	snapshot, err := m.latestSnapshot(ctx)
+	if err != nil {
+		return Drift{}, err
+	}
+	expected := Schema{}
+	if err := json.Unmarshal([]byte(snapshot.Definition), &expected); err != nil {
+		return Drift{}, fmt.Errorf("migrate: invalid schema snapshot of version %d: %w", snapshot.Version, err)
+	}
+	actual, err := m.inspect(ctx)
+	if err != nil {
+		return Drift{}, err
+	}
+	return Drift{
+		Version:         latestVersion(versions),
+		SnapshotVersion: snapshot.Version,
+		SnapshotAt:      snapshot.TakenAt,
+		Changes:         Diff(expected, actual),
+	}, nil
+}
+
+// latestVersion of the applied versions which are sorted by version, zero when no migration is applied
+func latestVersion(versions []appliedVersion) int64 {
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1].Version
+}
@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+func newSQLiteMigrator(t *testing.T, source Source) (*Migrator, *sqldb.DB) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(db, source, nil), db
+}
+
+func TestDrift(t *testing.T) {
+	m, db := newSQLiteMigrator(t, Migrations(
+		Migration{Version: 1, Name: "users", Up: "CREATE TABLE users(id INTEGER NOT NULL PRIMARY KEY, name VARCHAR(64) NOT NULL)"},
+		Migration{Version: 2, Name: "orders", Up: "CREATE TABLE orders(id INTEGER NOT NULL, user_id INTEGER)"},
+	))
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := m.Drift(ctx); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Fatalf("expecting error %v but got %v", ErrSnapshotNotFound, err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	drift, err := m.Drift(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift.Drifted() || drift.Version != 2 || drift.SnapshotVersion != 2 {
+		t.Fatalf("expecting no drift after migrations but got %+v", drift)
+	}
+
+	// hand-applied hotfix
+	for _, query := range []string{
+		"ALTER TABLE orders ADD COLUMN note TEXT",
+		"CREATE TABLE orders_backup(id INTEGER)",
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drift, err = m.Drift(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Change{
+		{Kind: ChangeColumnAdded, Table: "orders", Column: "note", Actual: "text"},
+		{Kind: ChangeTableAdded, Table: "orders_backup"},
+	}
+	if len(drift.Changes) != len(expect) {
+		t.Fatalf("expecting changes %+v but got %+v", expect, drift.Changes)
+	}
+	for idx, change := range expect {
+		if drift.Changes[idx] != change {
+			t.Errorf("expecting change %+v but got %+v", change, drift.Changes[idx])
+		}
+	}
+
+	// the reviewed drift is accepted by a new snapshot
+	if err := m.Snapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err = m.Drift(ctx); err != nil || drift.Drifted() {
+		t.Fatalf("expecting no drift after snapshot but got %+v, error %v", drift, err)
+	}
+}
+
+func TestDriftVersion(t *testing.T) {
+	m, db := newSQLiteMigrator(t, Migrations(
+		Migration{Version: 1, Name: "users", Up: "CREATE TABLE users(id INTEGER)"},
+	))
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the migration applied without the migrator
+	if _, err := db.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (2, 'orders', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatal(err)
+	}
+	drift, err := m.Drift(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !drift.Drifted() || drift.Version != 2 || drift.SnapshotVersion != 1 {
+		t.Fatalf("expecting snapshot of version 1 is behind version 2 but got %+v", drift)
+	}
+
+	// up without new migration snapshot the schema of the latest version
+	if _, err := db.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = 2"); err != nil {
+		t.Fatal(err)
+	}
+	m.source = Migrations(
+		Migration{Version: 1, Name: "users", Up: "CREATE TABLE users(id INTEGER)"},
+		Migration{Version: 2, Name: "orders", Up: "CREATE TABLE orders(id INTEGER)"},
+	)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err = m.Drift(ctx); err != nil || drift.Drifted() || drift.SnapshotVersion != 1 {
+		t.Fatalf("expecting no drift after down but got %+v, error %v", drift, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := Schema{Tables: []Table{
+		{Name: "users", Columns: []Column{{Name: "id", Type: "integer"}, {Name: "name", Type: "varchar(64)"}, {Name: "email", Type: "text", Nullable: true}}},
+		{Name: "sessions", Columns: []Column{{Name: "id", Type: "text"}}},
+	}}
+	actual := Schema{Tables: []Table{
+		{Name: "users", Columns: []Column{{Name: "id", Type: "integer"}, {Name: "name", Type: "text"}, {Name: "phone", Type: "text", Nullable: true}}},
+	}}
+	changes := Diff(expected, actual)
+	expect := []string{
+		"table_removed sessions",
+		"column_removed users.email",
+		"column_changed users.name: varchar(64) not null -> text not null",
+		"column_added users.phone",
+	}
+	if len(changes) != len(expect) {
+		t.Fatalf("expecting %d changes but got %+v", len(expect), changes)
+	}
+	for idx, e := range expect {
+		if changes[idx].String() != e {
+			t.Errorf("expecting change %s but got %s", e, changes[idx])
+		}
+	}
+}
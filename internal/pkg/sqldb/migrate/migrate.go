@@ -0,0 +1,298 @@
+// Package migrate apply versioned sql migrations to sqldb and keep the applied versions in a schema table
+//
+//	migrator := migrate.New(db, migrate.Dir("database/schema/user"), nil)
+//	applied, err := migrator.Up(ctx)
+//
+// every migration is applied in its own transaction in the leader database, and the schema after the migrations is kept
+// as a snapshot, so a change to the schema outside of the migrations is reported by Drift
+// mysql doesn't support transactional ddl and need multiStatements=true in the dsn for migration with many statements
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// DefaultTable of applied migration versions
+const DefaultTable = "schema_migrations"
+
+// list of error
+var (
+	ErrDuplicateVersion = errors.New("migrate: duplicate migration version")
+	ErrMissingMigration = errors.New("migrate: applied migration is not found in source")
+	ErrInvalidTable     = errors.New("migrate: invalid table name")
+	ErrSourceNil        = errors.New("migrate: source is nil")
+)
+
+// tableName allow schema qualified table name, the table name is not a query parameter so it is checked
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Options of migrator
+type Options struct {
+	// Table to keep the applied versions, default to schema_migrations
+	Table string
+	// SnapshotTable to keep the schema after the migrations, default to schema_snapshots
+	SnapshotTable string
+	// Now is used for the applied time, default to time.Now
+	Now func() time.Time
+}
+
+// Status of migration
+type Status struct {
+	Migration
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Missing is true when the migration is applied but not found in source
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Migrator of database schema
+type Migrator struct {
+	db     *sqldb.DB
+	source Source
+	opts   Options
+}
+
+// New migrator
+func New(db *sqldb.DB, source Source, options *Options) *Migrator {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.SnapshotTable == "" {
+		opts.SnapshotTable = DefaultSnapshotTable
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	m := Migrator{
+		db:     db,
+		source: source,
+		opts:   opts,
+	}
+	return &m
+}
+
+// ensureTable create the schema and the snapshot table when not exist
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	for _, table := range []string{m.opts.Table, m.opts.SnapshotTable} {
+		if !tableName.MatchString(table) {
+			return fmt.Errorf("%w: %s", ErrInvalidTable, table)
+		}
+	}
+	query := "CREATE TABLE IF NOT EXISTS " + m.opts.Table + ` (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`
+	if _, err := m.db.Leader().ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migrate: failed to create table %s: %w", m.opts.Table, err)
+	}
+	// text of mysql is limited to 64kb
+	definitionType := "TEXT"
+	if sqldb.DriverName(m.db.Leader().DriverName()) == sqldb.DriverMySQL {
+		definitionType = "LONGTEXT"
+	}
+	query = "CREATE TABLE IF NOT EXISTS " + m.opts.SnapshotTable + ` (
+	version BIGINT NOT NULL PRIMARY KEY,
+	definition ` + definitionType + ` NOT NULL,
+	taken_at TIMESTAMP NOT NULL
+)`
+	if _, err := m.db.Leader().ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migrate: failed to create table %s: %w", m.opts.SnapshotTable, err)
+	}
+	return nil
+}
+
+type appliedVersion struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// applied return applied versions sorted by version
+func (m *Migrator) applied(ctx context.Context) ([]appliedVersion, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	var versions []appliedVersion
+	query := "SELECT version, name, applied_at FROM " + m.opts.Table + " ORDER BY version"
+	if err := m.db.Leader().SelectContext(ctx, &versions, query); err != nil {
+		return nil, fmt.Errorf("migrate: failed to get applied versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (m *Migrator) load() ([]Migration, error) {
+	if m.source == nil {
+		return nil, ErrSourceNil
+	}
+	return m.source.Load()
+}
+
+// Up apply all migrations which are not applied yet, ordered by version
+// migration older than the latest applied version is also applied, so migrations from different branches can be merged
+// the schema is snapshotted after the migrations, or when it is not snapshotted at the latest applied version
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v.Version] = true
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := m.apply(ctx, migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	if !m.snapshotSupported() {
+		return done, nil
+	}
+	latest := latestVersion(versions)
+	if len(done) == 0 {
+		if snapshot, err := m.latestSnapshot(ctx); err == nil && snapshot.Version == latest {
+			return done, nil
+		}
+	}
+	for _, migration := range done {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+	return done, m.snapshot(ctx, latest)
+}
+
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	err := m.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		if strings.TrimSpace(migration.Up) != "" {
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return err
+			}
+		}
+		query := tx.Rebind("INSERT INTO " + m.opts.Table + " (version, name, applied_at) VALUES (?, ?, ?)")
+		_, err := tx.ExecContext(ctx, query, migration.Version, migration.Name, m.opts.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: failed to apply %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// Down revert the latest applied migrations, steps less than 1 revert one migration
+// the schema is snapshotted after the migrations are reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		steps = 1
+	}
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	for idx := len(versions) - 1; idx >= 0 && len(done) < steps; idx-- {
+		migration, ok := byVersion[versions[idx].Version]
+		if !ok {
+			return done, fmt.Errorf("%w: %d_%s", ErrMissingMigration, versions[idx].Version, versions[idx].Name)
+		}
+		if err := m.revert(ctx, migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	if len(done) == 0 || !m.snapshotSupported() {
+		return done, nil
+	}
+	return done, m.snapshot(ctx, latestVersion(versions[:len(versions)-len(done)]))
+}
+
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	err := m.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		if strings.TrimSpace(migration.Down) != "" {
+			if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM "+m.opts.Table+" WHERE version = ?"), migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: failed to revert %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// Status of all migrations in source and the applied migrations which are not in source, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	applied := make(map[int64]appliedVersion, len(versions))
+	for _, v := range versions {
+		applied[v.Version] = v
+	}
+	for _, migration := range migrations {
+		status := Status{Migration: migration}
+		if v, ok := applied[migration.Version]; ok {
+			appliedAt := v.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, v := range applied {
+		appliedAt := v.AppliedAt
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: v.Version, Name: v.Name},
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
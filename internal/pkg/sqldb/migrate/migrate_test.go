@@ -0,0 +1,173 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	testMigrations = Migrations(
+		Migration{Version: 1, Name: "users", Up: "CREATE TABLE users(id int)", Down: "DROP TABLE users"},
+		Migration{Version: 2, Name: "orders", Up: "CREATE TABLE orders(id int)", Down: "DROP TABLE orders"},
+	)
+	appliedAt = time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+)
+
+func newMockMigrator(t *testing.T, source Source) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	db, err := sqldb.Wrap(context.Background(), leader, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(db, source, &Options{Now: func() time.Time { return appliedAt }})
+	return m, mock
+}
+
+func expectApplied(mock sqlmock.Sqlmock, versions ...Migration) {
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_snapshots")).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "name", "applied_at"})
+	for _, v := range versions {
+		rows.AddRow(v.Version, v.Name, appliedAt)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, name, applied_at FROM schema_migrations ORDER BY version")).WillReturnRows(rows)
+}
+
+func expectSnapshot(mock sqlmock.Sqlmock, version int64) {
+	mock.ExpectQuery("FROM information_schema.columns").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "column_name", "data_type", "length", "nullable"}).AddRow("users", "id", "integer", 0, true))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM schema_snapshots").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_snapshots (version, definition, taken_at) VALUES ($1, $2, $3)")).
+		WithArgs(version, `{"tables":[{"name":"users","columns":[{"name":"id","type":"integer","nullable":true}]}]}`, appliedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestUp(t *testing.T) {
+	m, mock := newMockMigrator(t, testMigrations)
+	expectApplied(mock, Migration{Version: 1, Name: "users"})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE orders(id int)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)")).
+		WithArgs(int64(2), "orders", appliedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectSnapshot(mock, 2)
+
+	done, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("expecting only version 2 to be applied but got %+v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpFailed(t *testing.T) {
+	errSyntax := errors.New("syntax error")
+	m, mock := newMockMigrator(t, testMigrations)
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE users(id int)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE orders(id int)")).WillReturnError(errSyntax)
+	mock.ExpectRollback()
+
+	done, err := m.Up(context.Background())
+	if !errors.Is(err, errSyntax) {
+		t.Fatalf("expecting error %v but got %v", errSyntax, err)
+	}
+	// the failed migration is stopped, and applied migrations are kept
+	if len(done) != 1 || done[0].Version != 1 {
+		t.Errorf("expecting version 1 to be applied but got %+v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDown(t *testing.T) {
+	m, mock := newMockMigrator(t, testMigrations)
+	expectApplied(mock, Migration{Version: 1, Name: "users"}, Migration{Version: 2, Name: "orders"})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE orders")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectSnapshot(mock, 1)
+
+	done, err := m.Down(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("expecting version 2 to be reverted but got %+v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDownMissing(t *testing.T) {
+	m, mock := newMockMigrator(t, testMigrations)
+	expectApplied(mock, Migration{Version: 3, Name: "payments"})
+
+	if _, err := m.Down(context.Background(), 1); !errors.Is(err, ErrMissingMigration) {
+		t.Errorf("expecting error %v but got %v", ErrMissingMigration, err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	m, mock := newMockMigrator(t, testMigrations)
+	expectApplied(mock, Migration{Version: 1, Name: "users"}, Migration{Version: 3, Name: "payments"})
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct {
+		version int64
+		applied bool
+		missing bool
+	}{
+		{version: 1, applied: true},
+		{version: 2, applied: false},
+		{version: 3, applied: true, missing: true},
+	}
+	if len(statuses) != len(expect) {
+		t.Fatalf("expecting %d statuses but got %d", len(expect), len(statuses))
+	}
+	for idx, e := range expect {
+		s := statuses[idx]
+		if s.Version != e.version || s.Applied != e.applied || s.Missing != e.missing {
+			t.Errorf("expecting %+v but got %+v", e, s)
+		}
+	}
+}
+
+func TestInvalidTable(t *testing.T) {
+	m, _ := newMockMigrator(t, testMigrations)
+	m.opts.Table = "migrations; DROP TABLE users"
+	if _, err := m.Up(context.Background()); !errors.Is(err, ErrInvalidTable) {
+		t.Errorf("expecting error %v but got %v", ErrInvalidTable, err)
+	}
+}
@@ -0,0 +1,206 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// Column of a table in the database schema
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// Table of the database schema, the columns are ordered by their position in the table
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+}
+
+// Schema of the database, the tables are sorted by name
+type Schema struct {
+	Tables []Table `json:"tables"`
+}
+
+// list of change kind
+const (
+	ChangeTableAdded    = "table_added"
+	ChangeTableRemoved  = "table_removed"
+	ChangeColumnAdded   = "column_added"
+	ChangeColumnRemoved = "column_removed"
+	ChangeColumnChanged = "column_changed"
+)
+
+// Change of the live schema from the expected schema
+type Change struct {
+	Kind   string `json:"kind"`
+	Table  string `json:"table"`
+	Column string `json:"column,omitempty"`
+	// Expected and Actual definition of the changed column, for example `varchar(64) not null`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// String return the change as a short description, for example `column_changed users.name: varchar(64) -> text`
+func (c Change) String() string {
+	name := c.Table
+	if c.Column != "" {
+		name += "." + c.Column
+	}
+	if c.Kind == ChangeColumnChanged {
+		return fmt.Sprintf("%s %s: %s -> %s", c.Kind, name, c.Expected, c.Actual)
+	}
+	return c.Kind + " " + name
+}
+
+// definition of the column to compare and to show in the change
+func (c Column) definition() string {
+	if c.Nullable {
+		return c.Type
+	}
+	return c.Type + " not null"
+}
+
+// list of schema query, every query return table, column, type, max length and nullable ordered by table and position
+var schemaQueries = map[string]string{
+	sqldb.DriverPostgres: `SELECT table_name, column_name, data_type, COALESCE(character_maximum_length, 0), is_nullable = 'YES'
+FROM information_schema.columns
+WHERE table_schema = current_schema()
+ORDER BY table_name, ordinal_position`,
+	sqldb.DriverMySQL: `SELECT table_name, column_name, data_type, COALESCE(character_maximum_length, 0), is_nullable = 'YES'
+FROM information_schema.columns
+WHERE table_schema = DATABASE()
+ORDER BY table_name, ordinal_position`,
+	sqldb.DriverSQLite: `SELECT m.name, p.name, p.type, 0, p."notnull" = 0
+FROM sqlite_master m JOIN pragma_table_info(m.name) p
+WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+ORDER BY m.name, p.cid`,
+}
+
+// Inspect the schema of the leader database from information_schema, or from the table info in sqlite
+// only the tables of the current schema of postgres and the current database of mysql are inspected
+func Inspect(ctx context.Context, db *sqldb.DB) (Schema, error) {
+	driver := sqldb.DriverName(db.Leader().DriverName())
+	query, ok := schemaQueries[driver]
+	if !ok {
+		return Schema{}, fmt.Errorf("%w: %s", sqldb.ErrDriverNotSupported, driver)
+	}
+	rows, err := db.Leader().QueryContext(ctx, query)
+	if err != nil {
+		return Schema{}, fmt.Errorf("migrate: failed to inspect schema: %w", err)
+	}
+	defer rows.Close()
+
+	schema := Schema{}
+	for rows.Next() {
+		var (
+			table, column, dataType string
+			length                  sql.NullInt64
+			nullable                bool
+		)
+		if err := rows.Scan(&table, &column, &dataType, &length, &nullable); err != nil {
+			return Schema{}, fmt.Errorf("migrate: failed to inspect schema: %w", err)
+		}
+		dataType = strings.ToLower(dataType)
+		if length.Int64 > 0 {
+			dataType += "(" + strconv.FormatInt(length.Int64, 10) + ")"
+		}
+		if n := len(schema.Tables); n == 0 || schema.Tables[n-1].Name != table {
+			schema.Tables = append(schema.Tables, Table{Name: table})
+		}
+		t := &schema.Tables[len(schema.Tables)-1]
+		t.Columns = append(t.Columns, Column{Name: column, Type: dataType, Nullable: nullable})
+	}
+	if err := rows.Err(); err != nil {
+		return Schema{}, fmt.Errorf("migrate: failed to inspect schema: %w", err)
+	}
+	return schema, nil
+}
+
+// without return the schema without the tables, the table name can be schema qualified
+func (s Schema) without(tables ...string) Schema {
+	excluded := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		excluded[table[strings.LastIndex(table, ".")+1:]] = true
+	}
+	result := Schema{}
+	for _, table := range s.Tables {
+		if !excluded[table.Name] {
+			result.Tables = append(result.Tables, table)
+		}
+	}
+	return result
+}
+
+// Diff return the changes of the actual schema from the expected schema, sorted by table and column
+// the order of the columns is not compared
+func Diff(expected, actual Schema) []Change {
+	expectedTables := make(map[string]Table, len(expected.Tables))
+	for _, table := range expected.Tables {
+		expectedTables[table.Name] = table
+	}
+	actualTables := make(map[string]Table, len(actual.Tables))
+	for _, table := range actual.Tables {
+		actualTables[table.Name] = table
+	}
+
+	var changes []Change
+	for name, table := range expectedTables {
+		if _, ok := actualTables[name]; !ok {
+			changes = append(changes, Change{Kind: ChangeTableRemoved, Table: name})
+			continue
+		}
+		changes = append(changes, diffColumns(table, actualTables[name])...)
+	}
+	for name := range actualTables {
+		if _, ok := expectedTables[name]; !ok {
+			changes = append(changes, Change{Kind: ChangeTableAdded, Table: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Table != changes[j].Table {
+			return changes[i].Table < changes[j].Table
+		}
+		return changes[i].Column < changes[j].Column
+	})
+	return changes
+}
+
+func diffColumns(expected, actual Table) []Change {
+	actualColumns := make(map[string]Column, len(actual.Columns))
+	for _, column := range actual.Columns {
+		actualColumns[column.Name] = column
+	}
+
+	var changes []Change
+	for _, column := range expected.Columns {
+		a, ok := actualColumns[column.Name]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeColumnRemoved, Table: expected.Name, Column: column.Name, Expected: column.definition()})
+			continue
+		}
+		delete(actualColumns, column.Name)
+		if a.definition() != column.definition() {
+			changes = append(changes, Change{
+				Kind:     ChangeColumnChanged,
+				Table:    expected.Name,
+				Column:   column.Name,
+				Expected: column.definition(),
+				Actual:   a.definition(),
+			})
+		}
+	}
+	for _, column := range actual.Columns {
+		if _, ok := actualColumns[column.Name]; ok {
+			changes = append(changes, Change{Kind: ChangeColumnAdded, Table: actual.Name, Column: column.Name, Actual: column.definition()})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrationFile is the name of migration file, formatted as {version}_{name}.{up|down}.sql
+// for example 20191017070146_users.up.sql, the same format used in database/schema
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is one version of database schema
+type Migration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+}
+
+// Source of migrations
+type Source interface {
+	// Load return all migrations sorted by version
+	Load() ([]Migration, error)
+}
+
+type sourceFunc func() ([]Migration, error)
+
+func (fn sourceFunc) Load() ([]Migration, error) {
+	return fn()
+}
+
+// Dir return migrations from the sql files in the directory
+func Dir(dir string) Source {
+	return FileSystem(http.Dir(dir), "/")
+}
+
+// FileSystem return migrations from the sql files in the dir of the file system
+// use this for migration files bundled in the binary, for example http.FS(embedded) since go 1.16
+func FileSystem(fs http.FileSystem, dir string) Source {
+	return sourceFunc(func() ([]Migration, error) {
+		d, err := fs.Open(dir)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: failed to open migration directory %s: %w", dir, err)
+		}
+		defer d.Close()
+
+		infos, err := d.Readdir(-1)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: failed to read migration directory %s: %w", dir, err)
+		}
+
+		byVersion := make(map[int64]*Migration)
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			// other files like README is ignored
+			match := migrationFile.FindStringSubmatch(info.Name())
+			if match == nil {
+				continue
+			}
+			version, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("migrate: invalid version of %s: %w", info.Name(), err)
+			}
+			content, err := readFile(fs, path.Join(dir, info.Name()))
+			if err != nil {
+				return nil, err
+			}
+
+			m, ok := byVersion[version]
+			if !ok {
+				m = &Migration{Version: version, Name: match[2]}
+				byVersion[version] = m
+			}
+			if m.Name != match[2] {
+				return nil, fmt.Errorf("%w: %d is used by %s and %s", ErrDuplicateVersion, version, m.Name, match[2])
+			}
+			if match[3] == "up" {
+				m.Up = content
+			} else {
+				m.Down = content
+			}
+		}
+
+		migrations := make([]Migration, 0, len(byVersion))
+		for _, m := range byVersion {
+			migrations = append(migrations, *m)
+		}
+		return sortMigrations(migrations)
+	})
+}
+
+func readFile(fs http.FileSystem, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("migrate: failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("migrate: failed to read %s: %w", name, err)
+	}
+	return string(content), nil
+}
+
+// Migrations return the migrations as source, for migrations written in code
+func Migrations(migrations ...Migration) Source {
+	return sourceFunc(func() ([]Migration, error) {
+		list := make([]Migration, len(migrations))
+		copy(list, migrations)
+		return sortMigrations(list)
+	})
+}
+
+// sortMigrations sort the migrations by version and return error when a version is duplicated
+func sortMigrations(migrations []Migration) ([]Migration, error) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for idx, m := range migrations {
+		if idx > 0 && migrations[idx-1].Version == m.Version {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, m.Version)
+		}
+	}
+	return migrations, nil
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDir(t *testing.T) {
+	cases := []struct {
+		name   string
+		files  map[string]string
+		expect []Migration
+		err    error
+	}{
+		{
+			name: "sorted by version",
+			files: map[string]string{
+				"20200505100000_orders.up.sql":   "CREATE TABLE orders();",
+				"20200505100000_orders.down.sql": "DROP TABLE orders;",
+				"20191017070146_users.up.sql":    "CREATE TABLE users();",
+				"README.md":                      "not a migration",
+			},
+			expect: []Migration{
+				{Version: 20191017070146, Name: "users", Up: "CREATE TABLE users();"},
+				{Version: 20200505100000, Name: "orders", Up: "CREATE TABLE orders();", Down: "DROP TABLE orders;"},
+			},
+		},
+		{
+			name: "duplicate version",
+			files: map[string]string{
+				"1_users.up.sql":  "",
+				"1_orders.up.sql": "",
+			},
+			err: ErrDuplicateVersion,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := writeFiles(t, c.files)
+			defer os.RemoveAll(dir)
+
+			migrations, err := Dir(dir).Load()
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if len(migrations) != len(c.expect) {
+				t.Fatalf("expecting %d migrations but got %d", len(c.expect), len(migrations))
+			}
+			for idx, m := range migrations {
+				if m != c.expect[idx] {
+					t.Errorf("expecting migration %+v but got %+v", c.expect[idx], m)
+				}
+			}
+		})
+	}
+}
+
+func TestDirNotExist(t *testing.T) {
+	if _, err := Dir("/does/not/exist").Load(); err == nil {
+		t.Error("expecting error when directory is not exist")
+	}
+}
@@ -0,0 +1,259 @@
+package sqldb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// list of pagination error
+var (
+	ErrKeysetEmpty   = errors.New("sqldb: keyset keys is empty")
+	ErrInvalidCursor = errors.New("sqldb: invalid cursor")
+)
+
+// list of default pagination limit
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 1000
+)
+
+// SortKey is a column of keyset pagination and its direction
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// Asc return ascending sort key of the column
+func Asc(column string) SortKey {
+	return SortKey{Column: column}
+}
+
+// Desc return descending sort key of the column
+func Desc(column string) SortKey {
+	return SortKey{Column: column, Desc: true}
+}
+
+// Keyset paginate the rows by the sort keys instead of OFFSET, so the next page is a range scan of the index
+// the last key must be unique, for example the primary key, and the columns must not be NULL
+//
+//	keyset := sqldb.Keyset{Keys: []sqldb.SortKey{sqldb.Desc("created_at"), sqldb.Desc("id")}, Limit: limit}
+//	where, err := keyset.Where(cursor)
+//	query := db.Rebind("SELECT id, created_at FROM orders WHERE user_id = ? AND " + where.SQL + " " + keyset.Suffix())
+//	err = db.SelectContext(ctx, &orders, query, append([]interface{}{userID}, where.Args...)...)
+//	fetched := len(orders)
+//	orders = orders[:keyset.Trim(fetched)]
+//	next, err := keyset.Next(fetched, orders[len(orders)-1].CreatedAt, orders[len(orders)-1].ID)
+//
+// the columns are written as is to the query, so they must not come from user input
+type Keyset struct {
+	Keys []SortKey
+	// Limit of rows in a page, default to DefaultPageLimit and capped to MaxPageLimit
+	Limit int
+}
+
+// limit return the limit of a page with the default applied
+func (k Keyset) limit() int {
+	if k.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if k.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return k.Limit
+}
+
+func (k Keyset) validate() error {
+	if len(k.Keys) == 0 {
+		return ErrKeysetEmpty
+	}
+	for _, key := range k.Keys {
+		if err := ValidateIdentifier(key.Column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Where return the condition of the rows after the cursor, the condition is always true when the cursor is empty
+// for keys a, b and c the condition is a >= ? AND (a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c > ?))
+// the first comparison is redundant, but let the database use the index of the first key for the range
+func (k Keyset) Where(cursor string) (Expr, error) {
+	if err := k.validate(); err != nil {
+		return Expr{}, err
+	}
+	if cursor == "" {
+		return Expr{SQL: "1 = 1"}, nil
+	}
+	values, err := DecodeCursor(cursor)
+	if err != nil {
+		return Expr{}, err
+	}
+	if len(values) != len(k.Keys) {
+		return Expr{}, fmt.Errorf("%w: expecting %d values but got %d", ErrInvalidCursor, len(k.Keys), len(values))
+	}
+
+	var (
+		args       []interface{}
+		conditions = make([]string, len(k.Keys))
+	)
+	first := k.Keys[0]
+	args = append(args, values[0])
+	for idx, key := range k.Keys {
+		parts := make([]string, 0, idx+1)
+		for prev := 0; prev < idx; prev++ {
+			parts = append(parts, k.Keys[prev].Column+" = ?")
+			args = append(args, values[prev])
+		}
+		parts = append(parts, fmt.Sprintf("%s %s ?", key.Column, key.operator()))
+		args = append(args, values[idx])
+		conditions[idx] = strings.Join(parts, " AND ")
+		if idx > 0 {
+			conditions[idx] = "(" + conditions[idx] + ")"
+		}
+	}
+	operator := ">="
+	if first.Desc {
+		operator = "<="
+	}
+	sql := fmt.Sprintf("%s %s ? AND (%s)", first.Column, operator, strings.Join(conditions, " OR "))
+	return Expr{SQL: sql, Args: args}, nil
+}
+
+func (key SortKey) operator() string {
+	if key.Desc {
+		return "<"
+	}
+	return ">"
+}
+
+// OrderBy return the order of the keys without ORDER BY, for example created_at DESC, id DESC
+func (k Keyset) OrderBy() string {
+	orders := make([]string, len(k.Keys))
+	for idx, key := range k.Keys {
+		orders[idx] = key.Column + " ASC"
+		if key.Desc {
+			orders[idx] = key.Column + " DESC"
+		}
+	}
+	return strings.Join(orders, ", ")
+}
+
+// Suffix return ORDER BY and LIMIT of the query, one more row than the limit is fetched to know whether there is a next page
+func (k Keyset) Suffix() string {
+	return fmt.Sprintf("ORDER BY %s LIMIT %d", k.OrderBy(), k.limit()+1)
+}
+
+// Trim return the number of rows of the page from the number of fetched rows
+func (k Keyset) Trim(fetched int) int {
+	if fetched > k.limit() {
+		return k.limit()
+	}
+	return fetched
+}
+
+// Next return the cursor of the next page from the key values of the last row in the page
+// empty cursor is returned when the number of fetched rows is not more than the limit, so there is no next page
+func (k Keyset) Next(fetched int, values ...interface{}) (string, error) {
+	if fetched <= k.limit() {
+		return "", nil
+	}
+	if len(values) != len(k.Keys) {
+		return "", fmt.Errorf("%w: expecting %d values but got %d", ErrInvalidCursor, len(k.Keys), len(values))
+	}
+	return EncodeCursor(values...)
+}
+
+// cursorValue keep the type of time and bytes, so the value is the same type after the cursor is decoded
+type cursorValue struct {
+	Type  string      `json:"t,omitempty"`
+	Value interface{} `json:"v"`
+}
+
+// list of cursor value type
+const (
+	cursorTime  = "time"
+	cursorBytes = "bytes"
+)
+
+// EncodeCursor encode the key values to url safe opaque cursor
+// the value can be string, number, bool, time.Time or []byte
+func EncodeCursor(values ...interface{}) (string, error) {
+	encoded := make([]cursorValue, len(values))
+	for idx, value := range values {
+		switch v := value.(type) {
+		case time.Time:
+			encoded[idx] = cursorValue{Type: cursorTime, Value: v.Format(time.RFC3339Nano)}
+		case []byte:
+			encoded[idx] = cursorValue{Type: cursorBytes, Value: base64.StdEncoding.EncodeToString(v)}
+		case nil:
+			return "", fmt.Errorf("%w: value %d is nil", ErrInvalidCursor, idx)
+		default:
+			encoded[idx] = cursorValue{Value: v}
+		}
+	}
+	out, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// DecodeCursor decode the cursor to the key values
+// number is decoded as int64 when it is an integer and float64 otherwise
+func DecodeCursor(cursor string) ([]interface{}, error) {
+	out, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	decoder.UseNumber()
+	var encoded []cursorValue
+	if err := decoder.Decode(&encoded); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	values := make([]interface{}, len(encoded))
+	for idx, e := range encoded {
+		value, err := e.decode()
+		if err != nil {
+			return nil, fmt.Errorf("%w: value %d: %v", ErrInvalidCursor, idx, err)
+		}
+		values[idx] = value
+	}
+	return values, nil
+}
+
+func (e cursorValue) decode() (interface{}, error) {
+	switch e.Type {
+	case cursorTime, cursorBytes:
+		s, ok := e.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expecting string but got %T", e.Value)
+		}
+		if e.Type == cursorTime {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+		return base64.StdEncoding.DecodeString(s)
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown type %s", e.Type)
+	}
+
+	switch v := e.Value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case string, bool:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %T", e.Value)
+	}
+}
@@ -0,0 +1,151 @@
+package sqldb
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	values := []interface{}{createdAt, int64(10), 1.5, "abc", true, []byte("raw")}
+	cursor, err := EncodeCursor(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(values) {
+		t.Fatalf("expecting %d values but got %d", len(values), len(decoded))
+	}
+	if !decoded[0].(time.Time).Equal(createdAt) {
+		t.Fatalf("expecting time %v but got %v", createdAt, decoded[0])
+	}
+	if !reflect.DeepEqual(decoded[1:5], values[1:5]) {
+		t.Fatalf("expecting values %v but got %v", values[1:5], decoded[1:5])
+	}
+	if !bytes.Equal(decoded[5].([]byte), []byte("raw")) {
+		t.Fatalf("expecting bytes raw but got %v", decoded[5])
+	}
+
+	for _, invalid := range []string{"not base64!", "bm90IGpzb24", "W3sidCI6InVua25vd24iLCJ2IjoxfV0"} {
+		if _, err := DecodeCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("expecting error %v of %s but got %v", ErrInvalidCursor, invalid, err)
+		}
+	}
+	if _, err := EncodeCursor(nil); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidCursor, err)
+	}
+}
+
+func TestKeysetWhere(t *testing.T) {
+	cursor, err := EncodeCursor("2020-01-01", int64(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		keyset Keyset
+		cursor string
+		sql    string
+		args   []interface{}
+		err    error
+	}{
+		{
+			name:   "first page",
+			keyset: Keyset{Keys: []SortKey{Desc("created_at"), Desc("id")}},
+			sql:    "1 = 1",
+		},
+		{
+			name:   "descending",
+			keyset: Keyset{Keys: []SortKey{Desc("created_at"), Desc("id")}},
+			cursor: cursor,
+			sql:    "created_at <= ? AND (created_at < ? OR (created_at = ? AND id < ?))",
+			args:   []interface{}{"2020-01-01", "2020-01-01", "2020-01-01", int64(7)},
+		},
+		{
+			name:   "mixed direction",
+			keyset: Keyset{Keys: []SortKey{Asc("name"), Desc("id")}},
+			cursor: cursor,
+			sql:    "name >= ? AND (name > ? OR (name = ? AND id < ?))",
+			args:   []interface{}{"2020-01-01", "2020-01-01", "2020-01-01", int64(7)},
+		},
+		{
+			name:   "cursor of other keys",
+			keyset: Keyset{Keys: []SortKey{Asc("id")}},
+			cursor: cursor,
+			err:    ErrInvalidCursor,
+		},
+		{
+			name:   "invalid column",
+			keyset: Keyset{Keys: []SortKey{Asc("id; DROP TABLE users")}},
+			err:    ErrInvalidIdentifier,
+		},
+		{
+			name: "empty keys",
+			err:  ErrKeysetEmpty,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr, err := c.keyset.Where(c.cursor)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expecting error %v but got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if expr.SQL != c.sql {
+				t.Fatalf("expecting sql %s but got %s", c.sql, expr.SQL)
+			}
+			if !reflect.DeepEqual(expr.Args, c.args) {
+				t.Fatalf("expecting args %v but got %v", c.args, expr.Args)
+			}
+		})
+	}
+}
+
+func TestKeysetPage(t *testing.T) {
+	keyset := Keyset{Keys: []SortKey{Desc("created_at"), Asc("id")}, Limit: 2}
+	if suffix := keyset.Suffix(); suffix != "ORDER BY created_at DESC, id ASC LIMIT 3" {
+		t.Fatalf("expecting suffix with limit 3 but got %s", suffix)
+	}
+	if n := keyset.Trim(3); n != 2 {
+		t.Fatalf("expecting 2 rows but got %d", n)
+	}
+	if n := keyset.Trim(1); n != 1 {
+		t.Fatalf("expecting 1 row but got %d", n)
+	}
+
+	next, err := keyset.Next(2, "2020-01-01", int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "" {
+		t.Fatalf("expecting no next page but got %s", next)
+	}
+	next, err = keyset.Next(3, "2020-01-01", int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := DecodeCursor(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{"2020-01-01", int64(1)}) {
+		t.Fatalf("expecting the values of the last row but got %v", values)
+	}
+	if _, err := keyset.Next(3, int64(1)); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidCursor, err)
+	}
+
+	if suffix := (Keyset{Keys: []SortKey{Asc("id")}, Limit: MaxPageLimit * 2}).Suffix(); suffix != "ORDER BY id ASC LIMIT 1001" {
+		t.Fatalf("expecting capped limit but got %s", suffix)
+	}
+}
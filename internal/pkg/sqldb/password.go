@@ -0,0 +1,193 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// list of password error
+var (
+	ErrPasswordAddress = errors.New("sqldb: dsn has no host to authenticate")
+	ErrPasswordUser    = errors.New("sqldb: dsn has no user to authenticate")
+)
+
+// PasswordFunc return the password of new connection to the address and user of the dsn
+// for example short lived iam token of aws rds or gcp cloud sql, see package iam
+type PasswordFunc func(ctx context.Context, address, user string) (string, error)
+
+// passwordConnector open every new connection with the password from PasswordFunc
+// so short lived token is refreshed when the pool open new connection, and open connections are kept
+type passwordConnector struct {
+	driverName string
+	driver     driver.Driver
+	dsn        string
+	address    string
+	user       string
+	password   PasswordFunc
+}
+
+func newPasswordConnector(driverName, dsn string, password PasswordFunc) (*passwordConnector, error) {
+	var drv driver.Driver
+	switch driverName {
+	case DriverPostgres:
+		drv = &pq.Driver{}
+	case DriverMySQL:
+		drv = &mysql.MySQLDriver{}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotSupported, driverName)
+	}
+	return newPasswordConnectorWithDriver(driverName, drv, dsn, password)
+}
+
+func newPasswordConnectorWithDriver(driverName string, drv driver.Driver, dsn string, password PasswordFunc) (*passwordConnector, error) {
+	address, user, err := dsnAddress(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := passwordConnector{
+		driverName: driverName,
+		driver:     drv,
+		dsn:        dsn,
+		address:    address,
+		user:       user,
+		password:   password,
+	}
+	return &c, nil
+}
+
+// Connect open new connection with new password
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.password(ctx, c.address, c.user)
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: failed to get password: %w", err)
+	}
+	dsn, err := withPassword(c.driverName, c.dsn, password)
+	if err != nil {
+		return nil, err
+	}
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+// Driver of the connector
+func (c *passwordConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnAddress return the host:port and user of the dsn
+func dsnAddress(driverName, dsn string) (address, user string, err error) {
+	switch driverName {
+	case DriverMySQL:
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", "", fmt.Errorf("sqldb: invalid mysql dsn: %w", err)
+		}
+		address, user = cfg.Addr, cfg.User
+
+	default:
+		var host, port string
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return "", "", fmt.Errorf("sqldb: invalid postgres dsn: %w", err)
+			}
+			host, port = u.Hostname(), u.Port()
+			user = u.User.Username()
+		} else {
+			params := parsePostgresParams(dsn)
+			host, port, user = params["host"], params["port"], params["user"]
+		}
+		if port == "" {
+			port = "5432"
+		}
+		if host != "" {
+			address = net.JoinHostPort(host, port)
+		}
+	}
+	if address == "" {
+		return "", "", ErrPasswordAddress
+	}
+	if user == "" {
+		return "", "", ErrPasswordUser
+	}
+	return address, user, nil
+}
+
+// withPassword return the dsn with the password replaced
+func withPassword(driverName, dsn, password string) (string, error) {
+	if driverName == DriverMySQL {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", fmt.Errorf("sqldb: invalid mysql dsn: %w", err)
+		}
+		cfg.Passwd = password
+		return cfg.FormatDSN(), nil
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("sqldb: invalid postgres dsn: %w", err)
+		}
+		u.User = url.UserPassword(u.User.Username(), password)
+		return u.String(), nil
+	}
+	// the later key override the earlier key in key=value connection string
+	return strings.TrimSpace(dsn) + " password=" + quotePostgres(password), nil
+}
+
+// parsePostgresParams parse postgres key=value connection string, the value can be single quoted
+func parsePostgresParams(dsn string) map[string]string {
+	params := make(map[string]string)
+	s := []rune(dsn)
+	for i := 0; i < len(s); {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+			i++
+		}
+		start := i
+		for i < len(s) && s[i] != '=' {
+			i++
+		}
+		key := strings.TrimSpace(string(s[start:i]))
+		if i >= len(s) {
+			break
+		}
+		// skip =
+		i++
+		var value strings.Builder
+		if i < len(s) && s[i] == '\'' {
+			i++
+			for i < len(s) && s[i] != '\'' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteRune(s[i])
+				i++
+			}
+			// skip closing quote
+			i++
+		} else {
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != '\n' {
+				value.WriteRune(s[i])
+				i++
+			}
+		}
+		if key != "" {
+			params[key] = value.String()
+		}
+	}
+	return params
+}
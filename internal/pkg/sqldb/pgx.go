@@ -0,0 +1,95 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+func init() {
+	pgxBackend = &backend{
+		open:        openPgx,
+		isDriver:    isPgxDriver,
+		errorDetail: pgxErrorDetail,
+		sendBatch:   sendPgxBatch,
+	}
+}
+
+// openPgx open the database with pgx native connection, the libpq parameters of the dsn are supported, for example sslmode
+// the password of every new connection is replaced by the password function when it is set, for example iam token
+func openPgx(dsn string, password PasswordFunc) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: invalid pgx dsn: %w", err)
+	}
+	if password == nil {
+		return stdlib.OpenDB(*config), nil
+	}
+	address, user, err := dsnAddress(DriverPostgres, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return stdlib.OpenDB(*config, stdlib.OptionBeforeConnect(func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		p, err := password(ctx, address, user)
+		if err != nil {
+			return fmt.Errorf("sqldb: failed to get password: %w", err)
+		}
+		connConfig.Password = p
+		return nil
+	})), nil
+}
+
+func isPgxDriver(d driver.Driver) bool {
+	_, ok := d.(*stdlib.Driver)
+	return ok
+}
+
+func pgxErrorDetail(err error) (ErrorDetail, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ErrorDetail{}, false
+	}
+	return ErrorDetail{
+		Code:       pgErr.Code,
+		Severity:   pgErr.Severity,
+		Message:    pgErr.Message,
+		Detail:     pgErr.Detail,
+		Hint:       pgErr.Hint,
+		Where:      pgErr.Where,
+		Schema:     pgErr.SchemaName,
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		DataType:   pgErr.DataTypeName,
+		Constraint: pgErr.ConstraintName,
+		Position:   int(pgErr.Position),
+	}, true
+}
+
+// sendPgxBatch send the batch with the pgx connection, the queries run in an implicit transaction
+func sendPgxBatch(ctx context.Context, driverConn interface{}, b *Batch) ([]int64, bool, error) {
+	conn, ok := driverConn.(*stdlib.Conn)
+	if !ok {
+		return nil, false, nil
+	}
+	batch := &pgx.Batch{}
+	for _, q := range b.queries {
+		batch.Queue(q.query, q.args...)
+	}
+	results := conn.Conn().SendBatch(ctx, batch)
+	affected := make([]int64, 0, len(b.queries))
+	for range b.queries {
+		tag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			return nil, true, err
+		}
+		affected = append(affected, tag.RowsAffected())
+	}
+	return affected, true, results.Close()
+}
@@ -0,0 +1,255 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+)
+
+// fakePostgres accept the pgx connections with the password prefix and reply the simple queries
+// the statement which contains duplicate is failed with unique_violation
+type fakePostgres struct {
+	listener net.Listener
+	password string
+
+	mu        sync.Mutex
+	passwords []string
+	queries   []string
+}
+
+func newFakePostgres(t *testing.T, password string) *fakePostgres {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakePostgres{listener: listener, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakePostgres) dsn() string {
+	return fmt.Sprintf("postgres://app:dsn-password@%s/orders?sslmode=disable&prefer_simple_protocol=true", f.listener.Addr())
+}
+
+func (f *fakePostgres) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationCleartextPassword{})
+	backend.SetAuthType(pgproto3.AuthTypeCleartextPassword)
+	msg, err := backend.Receive()
+	if err != nil {
+		return
+	}
+	password, _ := msg.(*pgproto3.PasswordMessage)
+	f.mu.Lock()
+	f.passwords = append(f.passwords, password.Password)
+	f.mu.Unlock()
+	if !strings.HasPrefix(password.Password, f.password) {
+		backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"})
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			f.mu.Lock()
+			f.queries = append(f.queries, msg.String)
+			f.mu.Unlock()
+			f.reply(backend, msg.String)
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+// reply every statement of the query, the statements after the failed statement are not executed
+func (f *fakePostgres) reply(backend *pgproto3.Backend, query string) {
+	defer backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if strings.TrimSpace(query) == ";" {
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+	for _, statement := range strings.Split(query, ";") {
+		switch {
+		case strings.Contains(statement, "duplicate"):
+			backend.Send(&pgproto3.ErrorResponse{
+				Severity:       "ERROR",
+				Code:           "23505",
+				Message:        "duplicate key value violates unique constraint",
+				Detail:         "Key (id)=(1) already exists.",
+				SchemaName:     "public",
+				TableName:      "orders",
+				ConstraintName: "orders_pkey",
+				Position:       13,
+			})
+			return
+		case strings.HasPrefix(statement, "INSERT"):
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("INSERT 0 2")})
+		case strings.HasPrefix(statement, "UPDATE"):
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("UPDATE 1")})
+		}
+	}
+}
+
+func (f *fakePostgres) received() ([]string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.passwords...), append([]string(nil), f.queries...)
+}
+
+func TestPgxSendBatch(t *testing.T) {
+	ctx := context.Background()
+	f := newFakePostgres(t, "dsn-password")
+	defer f.listener.Close()
+
+	conn, err := Connect(ctx, DriverPostgres, f.dsn(), &ConnectOptions{Backend: BackendPgx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	b := &Batch{}
+	b.Queue("INSERT INTO orders (id, status) VALUES ($1, $2), ($3, $4)", 1, "new", 2, "new")
+	b.Queue("UPDATE orders SET status = $1 WHERE id = $2", "paid", 1)
+	affected, err := db.SendBatch(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(affected) != 2 || affected[0] != 2 || affected[1] != 1 {
+		t.Fatalf("expecting rows affected [2 1] but got %v", affected)
+	}
+	_, queries := f.received()
+	last := strings.Split(queries[len(queries)-1], ";")
+	if len(last) != 2 || !strings.HasPrefix(last[0], "INSERT INTO orders") || !strings.Contains(last[1], "'paid'") {
+		t.Fatalf("expecting the batch is sent in one query but got %v", queries)
+	}
+
+	b = &Batch{}
+	b.Queue("UPDATE orders SET status = $1 WHERE id = $2", "paid", 2)
+	b.Queue("INSERT INTO orders (id, status) VALUES ($1, $2) -- duplicate", 1, "new")
+	_, err = db.SendBatch(ctx, b)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		t.Fatalf("expecting pgconn error but got %v", err)
+	}
+	detail, ok := PostgresError(err)
+	if !ok {
+		t.Fatal("expecting postgres error of pgx backend")
+	}
+	expectDetail := ErrorDetail{
+		Code:       "23505",
+		Severity:   "ERROR",
+		Message:    "duplicate key value violates unique constraint",
+		Detail:     "Key (id)=(1) already exists.",
+		Schema:     "public",
+		Table:      "orders",
+		Constraint: "orders_pkey",
+		Position:   13,
+	}
+	if detail != expectDetail {
+		t.Fatalf("expecting %+v but got %+v", expectDetail, detail)
+	}
+}
+
+func TestPgxPassword(t *testing.T) {
+	ctx := context.Background()
+	f := newFakePostgres(t, "token-")
+	defer f.listener.Close()
+
+	var (
+		mu     sync.Mutex
+		tokens int
+	)
+	password := func(ctx context.Context, address, user string) (string, error) {
+		if address != f.listener.Addr().String() || user != "app" {
+			return "", fmt.Errorf("unexpected address %s and user %s", address, user)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		tokens++
+		return fmt.Sprintf("token-%d", tokens), nil
+	}
+
+	db, err := Connect(ctx, DriverPostgres, f.dsn(), &ConnectOptions{Backend: BackendPgx, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// every ping open new connection as the idle connection is not kept
+	db.SetMaxIdleConns(0)
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	passwords, _ := f.received()
+	if strings.Join(passwords, ",") != "token-1,token-2" {
+		t.Fatalf("expecting the password of every new connection from the password function but got %v", passwords)
+	}
+}
+
+func TestPgxErrorDetail(t *testing.T) {
+	err := fmt.Errorf("failed to insert: %w", &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23503",
+		Message:        "insert or update violates foreign key constraint",
+		Hint:           "check the user",
+		Where:          "SQL statement",
+		SchemaName:     "public",
+		TableName:      "orders",
+		ColumnName:     "user_id",
+		DataTypeName:   "uuid",
+		ConstraintName: "orders_user_id_fkey",
+		Position:       7,
+	})
+	detail, ok := PostgresError(err)
+	if !ok {
+		t.Fatal("expecting postgres error")
+	}
+	expect := ErrorDetail{
+		Code:       "23503",
+		Severity:   "ERROR",
+		Message:    "insert or update violates foreign key constraint",
+		Hint:       "check the user",
+		Where:      "SQL statement",
+		Schema:     "public",
+		Table:      "orders",
+		Column:     "user_id",
+		DataType:   "uuid",
+		Constraint: "orders_user_id_fkey",
+		Position:   7,
+	}
+	if detail != expect {
+		t.Fatalf("expecting %+v but got %+v", expect, detail)
+	}
+}
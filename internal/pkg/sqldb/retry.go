@@ -0,0 +1,228 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
+
+// default value for query retry policy
+const (
+	DefaultQueryRetryInitialInterval = time.Millisecond * 50
+	DefaultQueryRetryMaxInterval     = time.Second
+)
+
+// NewQueryRetryPolicy return retry policy for queries with short backoff
+// attempts less than 2 disable the retry
+func NewQueryRetryPolicy(attempts int) *retry.Policy {
+	if attempts < 2 {
+		return nil
+	}
+	return &retry.Policy{
+		MaxAttempts:     attempts,
+		InitialInterval: DefaultQueryRetryInitialInterval,
+		MaxInterval:     DefaultQueryRetryMaxInterval,
+		Jitter:          0.2,
+	}
+}
+
+// SetRetryPolicy set the retry policy of Get, Select, Query and Exec, nil disable the retry
+// the error is retried when IsTransient return true, unless the policy has its own Retryable
+// QueryRow is not retried because the error is only returned by Scan
+// queries in transaction are never retried, as the transaction is aborted by the error and must be retried as a whole
+// the policy must be set before the db is used
+func (db *DB) SetRetryPolicy(policy *retry.Policy) {
+	db.retryPolicy = policy
+}
+
+// IsTransient return true when the error of the driver is temporary and the query can be retried
+// for example deadlock, serialization failure and lost connection
+func IsTransient(driverName string, err error) bool {
+	return classify(driverName, err) != classNone
+}
+
+// list of error class of ErrorClass
+const (
+	ErrorClassTimeout        = "timeout"
+	ErrorClassCanceled       = "canceled"
+	ErrorClassConflict       = "conflict"
+	ErrorClassUnavailable    = "unavailable"
+	ErrorClassConnectionLost = "connection_lost"
+	ErrorClassConstraint     = "constraint"
+	ErrorClassQuery          = "query"
+)
+
+// ErrorClass return the class of the query error for metrics, empty when the error is nil
+// error which is not caused by the context, the connection, the conflict or the constraint is classified as query
+// for example syntax error, and the query which return no rows is not an error
+func ErrorClass(driverName string, err error) string {
+	switch {
+	case err == nil || errors.Is(err, sql.ErrNoRows):
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	}
+	switch classify(driverName, err) {
+	case classConflict:
+		return ErrorClassConflict
+	case classUnavailable:
+		return ErrorClassUnavailable
+	case classConnectionLost:
+		return ErrorClassConnectionLost
+	}
+	if isConstraintError(driverName, err) {
+		return ErrorClassConstraint
+	}
+	return ErrorClassQuery
+}
+
+// isConstraintError return true when the query violate unique, foreign key, not null or check constraint
+func isConstraintError(driverName string, err error) bool {
+	switch driverName {
+	case DriverPostgres:
+		if pgErr, ok := PostgresError(err); ok {
+			// integrity_constraint_violation class
+			return pgErr.Class() == "23"
+		}
+	case DriverMySQL:
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) {
+			switch mysqlErr.Number {
+			// duplicate entry, foreign key, column cannot be null and check constraint
+			case 1062, 1451, 1452, 1048, 3819:
+				return true
+			}
+		}
+	case DriverSQLite:
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			return sqliteErr.Code == sqlite3.ErrConstraint
+		}
+	}
+	return false
+}
+
+// errorClass of driver error
+type errorClass int
+
+// list of error class
+const (
+	classNone errorClass = iota
+	// classConflict is deadlock or serialization failure, the query is rolled back by the database
+	classConflict
+	// classUnavailable is connection error before the query is sent to the database
+	classUnavailable
+	// classConnectionLost is connection error in the middle of the query, the query might already be executed
+	classConnectionLost
+)
+
+// unsent return true when the query is known to be not executed or rolled back by the database, so exec can be retried safely
+func (c errorClass) unsent() bool {
+	return c == classConflict || c == classUnavailable
+}
+
+// connection return true when the error is caused by the database connection, not by the query
+func (c errorClass) connection() bool {
+	return c == classUnavailable || c == classConnectionLost
+}
+
+// classify the error of the driver
+func classify(driverName string, err error) errorClass {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return classNone
+	}
+	// database/sql only return bad connection when the query is not sent to the database
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) {
+		return classUnavailable
+	}
+
+	switch driverName {
+	case DriverPostgres:
+		if pgErr, ok := PostgresError(err); ok {
+			switch {
+			// serialization_failure and deadlock_detected
+			case pgErr.Code == "40001" || pgErr.Code == "40P01":
+				return classConflict
+			// too_many_connections and cannot_connect_now
+			case pgErr.Code == "53300" || pgErr.Code == "57P03":
+				return classUnavailable
+			// connection_exception class and admin_shutdown
+			case pgErr.Class() == "08" || pgErr.Code == "57P01":
+				return classConnectionLost
+			}
+			return classNone
+		}
+	case DriverMySQL:
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) {
+			switch mysqlErr.Number {
+			// lock wait timeout and deadlock
+			case 1205, 1213:
+				return classConflict
+			// too many connections
+			case 1040:
+				return classUnavailable
+			}
+			return classNone
+		}
+		if errors.Is(err, mysql.ErrInvalidConn) {
+			return classConnectionLost
+		}
+	case DriverSQLite:
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			// database or table is locked by other writer, the statement is not executed
+			if sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked {
+				return classConflict
+			}
+			return classNone
+		}
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return classConnectionLost
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return classConnectionLost
+	}
+	// some drivers does not wrap the network error
+	if strings.Contains(err.Error(), "connection reset by peer") || strings.Contains(err.Error(), "broken pipe") {
+		return classConnectionLost
+	}
+	return classNone
+}
+
+// withRetry run the query function with the retry policy, exec is only retried when the query is not executed
+func (db *DB) withRetry(ctx context.Context, exec bool, fn func(ctx context.Context) error) error {
+	if db.retryPolicy == nil {
+		return fn(ctx)
+	}
+	policy := *db.retryPolicy
+	retryable := policy.Retryable
+	policy.Retryable = func(err error) bool {
+		// open circuit is not retried, so the query fail fast
+		if errors.Is(err, ErrCircuitOpen) {
+			return false
+		}
+		if retryable != nil {
+			return retryable(err)
+		}
+		class := classify(db.driver, err)
+		return class != classNone && (class.unsent() || !exec)
+	}
+	return retry.Do(ctx, &policy, fn)
+}
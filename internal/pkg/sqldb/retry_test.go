@@ -0,0 +1,192 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		name      string
+		driver    string
+		err       error
+		transient bool
+	}{
+		{name: "nil", driver: DriverPostgres, err: nil},
+		{name: "bad connection", driver: DriverPostgres, err: driver.ErrBadConn, transient: true},
+		{name: "context canceled", driver: DriverPostgres, err: context.Canceled},
+		{name: "postgres serialization", driver: DriverPostgres, err: &pq.Error{Code: "40001"}, transient: true},
+		{name: "postgres deadlock", driver: DriverPostgres, err: fmt.Errorf("wrapped: %w", &pq.Error{Code: "40P01"}), transient: true},
+		{name: "postgres connection failure", driver: DriverPostgres, err: &pq.Error{Code: "08006"}, transient: true},
+		{name: "postgres unique violation", driver: DriverPostgres, err: &pq.Error{Code: "23505"}},
+		{name: "mysql deadlock", driver: DriverMySQL, err: &mysql.MySQLError{Number: 1213}, transient: true},
+		{name: "mysql lock wait timeout", driver: DriverMySQL, err: &mysql.MySQLError{Number: 1205}, transient: true},
+		{name: "mysql duplicate entry", driver: DriverMySQL, err: &mysql.MySQLError{Number: 1062}},
+		{name: "mysql invalid connection", driver: DriverMySQL, err: mysql.ErrInvalidConn, transient: true},
+		{name: "connection reset", driver: DriverMySQL, err: fmt.Errorf("read: %w", syscall.ECONNRESET), transient: true},
+		{name: "unexpected eof", driver: DriverPostgres, err: io.ErrUnexpectedEOF, transient: true},
+		{name: "syntax error", driver: DriverPostgres, err: errors.New("syntax error")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if transient := IsTransient(c.driver, c.err); transient != c.transient {
+				t.Fatalf("expect transient %v but got %v", c.transient, transient)
+			}
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	cases := []struct {
+		name   string
+		driver string
+		err    error
+		class  string
+	}{
+		{name: "nil", driver: DriverPostgres, err: nil},
+		{name: "no rows", driver: DriverPostgres, err: sql.ErrNoRows},
+		{name: "deadline exceeded", driver: DriverPostgres, err: fmt.Errorf("query: %w", context.DeadlineExceeded), class: ErrorClassTimeout},
+		{name: "context canceled", driver: DriverMySQL, err: context.Canceled, class: ErrorClassCanceled},
+		{name: "postgres deadlock", driver: DriverPostgres, err: &pq.Error{Code: "40P01"}, class: ErrorClassConflict},
+		{name: "bad connection", driver: DriverPostgres, err: driver.ErrBadConn, class: ErrorClassUnavailable},
+		{name: "mysql invalid connection", driver: DriverMySQL, err: mysql.ErrInvalidConn, class: ErrorClassConnectionLost},
+		{name: "postgres unique violation", driver: DriverPostgres, err: &pq.Error{Code: "23505"}, class: ErrorClassConstraint},
+		{name: "mysql duplicate entry", driver: DriverMySQL, err: &mysql.MySQLError{Number: 1062}, class: ErrorClassConstraint},
+		{name: "sqlite constraint", driver: DriverSQLite, err: sqlite3.Error{Code: sqlite3.ErrConstraint}, class: ErrorClassConstraint},
+		{name: "syntax error", driver: DriverPostgres, err: &pq.Error{Code: "42601"}, class: ErrorClassQuery},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if class := ErrorClass(c.driver, c.err); class != c.class {
+				t.Fatalf("expecting class %q but got %q", c.class, class)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	deadlock := &pq.Error{Code: "40P01"}
+	reset := fmt.Errorf("read: %w", syscall.ECONNRESET)
+
+	cases := []struct {
+		name   string
+		policy *retry.Policy
+		expect func(leader, follower sqlmock.Sqlmock)
+		run    func(db *DB) error
+		err    bool
+	}{
+		{
+			name:   "exec retried on deadlock",
+			policy: &retry.Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			expect: func(leader, follower sqlmock.Sqlmock) {
+				leader.ExpectExec("UPDATE users").WillReturnError(deadlock)
+				leader.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(db *DB) error {
+				_, err := db.ExecContext(context.Background(), "UPDATE users SET name = 'a'")
+				return err
+			},
+		},
+		{
+			name:   "exec not retried on connection reset",
+			policy: &retry.Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			expect: func(leader, follower sqlmock.Sqlmock) {
+				leader.ExpectExec("UPDATE users").WillReturnError(reset)
+			},
+			run: func(db *DB) error {
+				_, err := db.Exec("UPDATE users SET name = 'a'")
+				return err
+			},
+			err: true,
+		},
+		{
+			name:   "query retried on connection reset",
+			policy: &retry.Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			expect: func(leader, follower sqlmock.Sqlmock) {
+				follower.ExpectQuery("SELECT name").WillReturnError(reset)
+				follower.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a"))
+			},
+			run: func(db *DB) error {
+				var name string
+				return db.Get(&name, "SELECT name FROM users")
+			},
+		},
+		{
+			name:   "not retried without policy",
+			policy: nil,
+			expect: func(leader, follower sqlmock.Sqlmock) {
+				leader.ExpectExec("UPDATE users").WillReturnError(deadlock)
+			},
+			run: func(db *DB) error {
+				_, err := db.Exec("UPDATE users SET name = 'a'")
+				return err
+			},
+			err: true,
+		},
+		{
+			name:   "exhausted",
+			policy: &retry.Policy{MaxAttempts: 2, InitialInterval: time.Millisecond},
+			expect: func(leader, follower sqlmock.Sqlmock) {
+				follower.ExpectQuery("SELECT name").WillReturnError(deadlock)
+				follower.ExpectQuery("SELECT name").WillReturnError(deadlock)
+			},
+			run: func(db *DB) error {
+				var names []string
+				return db.Select(&names, "SELECT name FROM users")
+			},
+			err: true,
+		},
+		{
+			name:   "not retried in transaction",
+			policy: &retry.Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			expect: func(leader, follower sqlmock.Sqlmock) {
+				leader.ExpectBegin()
+				leader.ExpectExec("UPDATE users").WillReturnError(deadlock)
+				leader.ExpectRollback()
+			},
+			run: func(db *DB) error {
+				return db.WithTransaction(context.Background(), func(tx *Tx) error {
+					_, err := tx.Exec("UPDATE users SET name = 'a'")
+					return err
+				})
+			},
+			err: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db, leaderMock, followerMock := newMockDB(t)
+			defer db.Close()
+			db.SetRetryPolicy(c.policy)
+			c.expect(leaderMock, followerMock)
+
+			err := c.run(db)
+			if (err != nil) != c.err {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+			if err := leaderMock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if err := followerMock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package sqldb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type forceLeaderKey struct{}
+
+// ForceLeader return context which route all queries using the context to leader
+// use this to read your own write, as follower might be behind the leader
+func ForceLeader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceLeaderKey{}, true)
+}
+
+// isLeaderForced return true when the context is created by ForceLeader
+func isLeaderForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forceLeaderKey{}).(bool)
+	return forced
+}
+
+// route return the connection for the query
+// read only query go to follower, and everything else go to leader
+func (db *DB) route(ctx context.Context, query string) *sqlx.DB {
+	if isLeaderForced(ctx) || !IsReadQuery(query) {
+		return db.Leader()
+	}
+	return db.Follower()
+}
+
+// IsReadQuery return true when the query is safe to run in follower
+// the query is read only when it is started with SELECT, SHOW or WITH, and doesn't lock or modify any rows
+// for example SELECT ... FOR UPDATE and WITH ... INSERT are not read only
+func IsReadQuery(query string) bool {
+	keyword := firstKeyword(query)
+	if !strings.EqualFold(keyword, "select") && !strings.EqualFold(keyword, "show") && !strings.EqualFold(keyword, "with") {
+		return false
+	}
+
+	var prev string
+	for i := 0; i < len(query); {
+		if !isKeywordChar(query[i]) {
+			i++
+			continue
+		}
+		end := i
+		for end < len(query) && isKeywordChar(query[end]) {
+			end++
+		}
+		word := query[i:end]
+		i = end
+
+		switch {
+		case strings.EqualFold(word, "into"), strings.EqualFold(word, "insert"), strings.EqualFold(word, "delete"):
+			return false
+		case strings.EqualFold(word, "update"), strings.EqualFold(word, "share"):
+			// UPDATE in WITH modify rows, and FOR UPDATE or FOR SHARE lock rows
+			if strings.EqualFold(keyword, "with") || strings.EqualFold(prev, "for") {
+				return false
+			}
+		}
+		prev = word
+	}
+	return true
+}
+
+// firstKeyword return the first keyword of the query, comments and parentheses are skipped
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			idx := strings.Index(query, "\n")
+			if idx < 0 {
+				return ""
+			}
+			query = query[idx+1:]
+		case strings.HasPrefix(query, "/*"):
+			idx := strings.Index(query, "*/")
+			if idx < 0 {
+				return ""
+			}
+			query = query[idx+2:]
+		default:
+			end := 0
+			for end < len(query) && isKeywordChar(query[end]) {
+				end++
+			}
+			return query[:end]
+		}
+	}
+}
+
+func isKeywordChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
@@ -0,0 +1,176 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	cases := []struct {
+		query  string
+		expect bool
+	}{
+		{query: "SELECT id FROM users WHERE id = $1", expect: true},
+		{query: "  select id from users", expect: true},
+		{query: "\n\t(SELECT id FROM users) UNION (SELECT id FROM admins)", expect: true},
+		{query: "-- get user\nSELECT id FROM users", expect: true},
+		{query: "/* get user */ SELECT id FROM users", expect: true},
+		{query: "SHOW search_path", expect: true},
+		{query: "WITH u AS (SELECT id FROM users) SELECT id FROM u", expect: true},
+		{query: "SELECT updated_at, for_update FROM users", expect: true},
+		{query: "SELECT id FROM users WHERE id = $1 FOR UPDATE", expect: false},
+		{query: "SELECT id FROM users WHERE id = $1 for\n\tupdate", expect: false},
+		{query: "SELECT id FROM users FOR SHARE", expect: false},
+		{query: "SELECT id INTO users_backup FROM users", expect: false},
+		{query: "WITH u AS (DELETE FROM users RETURNING id) SELECT id FROM u", expect: false},
+		{query: "INSERT INTO users(id) VALUES($1) RETURNING id", expect: false},
+		{query: "UPDATE users SET name = $1", expect: false},
+		{query: "DELETE FROM users", expect: false},
+		{query: "-- SELECT", expect: false},
+		{query: "", expect: false},
+	}
+
+	for _, c := range cases {
+		if got := IsReadQuery(c.query); got != c.expect {
+			t.Errorf("query %q: expecting read query %v but got %v", c.query, c.expect, got)
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	cases := []struct {
+		name       string
+		ctx        context.Context
+		query      string
+		toFollower bool
+	}{
+		{
+			name:       "select to follower",
+			ctx:        context.Background(),
+			query:      "SELECT id FROM users",
+			toFollower: true,
+		},
+		{
+			name:  "insert returning to leader",
+			ctx:   context.Background(),
+			query: "INSERT INTO users(id) VALUES(1) RETURNING id",
+		},
+		{
+			name:  "force leader",
+			ctx:   ForceLeader(context.Background()),
+			query: "SELECT id FROM users",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db, leaderMock, followerMock := newMockDB(t)
+			mock := leaderMock
+			if c.toFollower {
+				mock = followerMock
+			}
+			mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+			var id int64
+			if err := db.GetContext(c.ctx, &id, c.query); err != nil {
+				t.Fatal(err)
+			}
+			if err := leaderMock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+			if err := followerMock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNamedRoute(t *testing.T) {
+	type user struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	arg := user{ID: 1, Name: "albert"}
+
+	t.Run("named query to follower", func(t *testing.T) {
+		db, leaderMock, followerMock := newMockDB(t)
+		followerMock.ExpectQuery(`SELECT id, name FROM users WHERE id = \$1`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "albert"))
+
+		rows, err := db.NamedQueryContext(context.Background(), "SELECT id, name FROM users WHERE id = :id", arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var got []user
+		for rows.Next() {
+			var u user
+			if err := rows.StructScan(&u); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, u)
+		}
+		if len(got) != 1 || got[0] != arg {
+			t.Fatalf("expecting %v but got %v", arg, got)
+		}
+		if err := leaderMock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		if err := followerMock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("named get and select to follower", func(t *testing.T) {
+		db, leaderMock, followerMock := newMockDB(t)
+		followerMock.ExpectQuery(`SELECT id, name FROM users WHERE id = \$1`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "albert"))
+		followerMock.ExpectQuery(`SELECT id, name FROM users WHERE name = \$1`).WithArgs("albert").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "albert").AddRow(2, "albert"))
+
+		var u user
+		if err := db.NamedGetContext(context.Background(), &u, "SELECT id, name FROM users WHERE id = :id", arg); err != nil {
+			t.Fatal(err)
+		}
+		if u != arg {
+			t.Fatalf("expecting %v but got %v", arg, u)
+		}
+		var users []user
+		if err := db.NamedSelectContext(context.Background(), &users, "SELECT id, name FROM users WHERE name = :name", map[string]interface{}{"name": "albert"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 2 {
+			t.Fatalf("expecting 2 users but got %d", len(users))
+		}
+		if err := leaderMock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		if err := followerMock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("named exec and force leader to leader", func(t *testing.T) {
+		db, leaderMock, followerMock := newMockDB(t)
+		leaderMock.ExpectExec(`UPDATE users SET name = \$1 WHERE id = \$2`).WithArgs("albert", int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		leaderMock.ExpectQuery(`SELECT id, name FROM users WHERE id = \$1`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "albert"))
+
+		if _, err := db.NamedExecContext(context.Background(), "UPDATE users SET name = :name WHERE id = :id", arg); err != nil {
+			t.Fatal(err)
+		}
+		var u user
+		if err := db.NamedGetContext(ForceLeader(context.Background()), &u, "SELECT id, name FROM users WHERE id = :id", arg); err != nil {
+			t.Fatal(err)
+		}
+		if err := leaderMock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		if err := followerMock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
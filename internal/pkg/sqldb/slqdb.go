@@ -5,176 +5,350 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/albertwidi/go-project-example/internal/pkg/breaker"
+	"github.com/albertwidi/go-project-example/internal/pkg/retry"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/microsoft/go-mssqldb"
 )
 
 // list of error
 var (
-	errConfigNil = errors.New("sqldb: config is nil")
+	errConfigNil           = errors.New("sqldb: config is nil")
+	ErrDriverNotRegistered = errors.New("sqldb: driver is not registered")
 )
 
 // DB struct to hold all database connections
 type DB struct {
-	driver   string
-	leader   *sqlx.DB
-	follower *sqlx.DB
+	// next is the round robin counter of followers, placed first for atomic alignment
+	next            uint64
+	driver          string
+	leader          *sqlx.DB
+	leaderMu        sync.RWMutex
+	followers       []*follower
+	followerOptions FollowerOptions
+	retryPolicy     *retry.Policy
+	leaderBreaker   *breaker.Breaker
+	hooks           []Hook
+	middlewares     []Middleware
+	failover        *failover
+	traceOptions    *TraceOptions
+	queryTimeout    time.Duration
+	queryCache      *queryCache
+	stmtCache       *stmtCache
+	stop            chan struct{}
+	closeOnce       sync.Once
 }
 
 // Wrap leader and follower sqlx object to one DB object
 // this is for easier usage, so user doesn't have to specify leader or follower
-// all exec is going to leader, all query is going to follower
+// all exec and transaction is going to leader, read only query is going to follower and other query is going to leader
+// see IsReadQuery for the read only query, and ForceLeader to route read only query to leader
 func Wrap(ctx context.Context, leader, follower *sqlx.DB) (*DB, error) {
-	if leader.DriverName() != follower.DriverName() {
-		return nil, fmt.Errorf("sqldb: leader and follower driver is not matched. leader = %s follower = %s", leader.DriverName(), follower.DriverName())
-	}
-
-	db := DB{
-		driver:   leader.DriverName(),
-		leader:   leader,
-		follower: follower,
-	}
-	return &db, nil
+	return WrapFollowers(ctx, leader, []*sqlx.DB{follower}, nil)
 }
 
 // ConnectOptions to list options when connect to the db
 type ConnectOptions struct {
-	Retry                 int
-	MaxOpenConnections    int
-	MaxIdleConnections    int
+	// Retry is the number of connect attempts, the wait time between attempts is increased exponentially
+	Retry              int
+	MaxOpenConnections int
+	MaxIdleConnections int
+	// ConnectionMaxLifetime close connection which is opened longer than this, zero keep the connection forever
+	// keep it shorter than the server or proxy timeout, for example pgbouncer server_lifetime
 	ConnectionMaxLifetime time.Duration
+	// ConnectionMaxIdleTime close connection which is idle longer than this, zero keep the idle connection forever
+	ConnectionMaxIdleTime time.Duration
+	// TLS of the connection, the dsn is connected as is when nil
+	TLS *TLSConfig
+	// Password of every new connection replace the password of dsn, for example iam token
+	Password PasswordFunc
+	// ClickHouse options of clickhouse connection, for example async insert and compression
+	ClickHouse *ClickHouseOptions
+	// SQLServer options of sqlserver connection, for example encrypt and app name
+	SQLServer *SQLServerOptions
+	// Backend of postgres connection, pq or pgx, default to pq
+	Backend string
 }
 
 // Connect to a new database
+// sqlite use one connection as it allow one writer at a time, and in-memory database is dropped when its connection is closed
+// so the pool settings of the options are not used in sqlite, see SQLiteDSN for the default parameters
 func Connect(ctx context.Context, driver, dsn string, connOpts *ConnectOptions) (*sqlx.DB, error) {
 	opts := connOpts
 	if opts == nil {
 		opts = &ConnectOptions{}
 	}
+	driver = DriverName(driver)
+	if !registered(driver) {
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotRegistered, driver)
+	}
+	if err := ValidateBackend(driver, opts.Backend); err != nil {
+		return nil, err
+	}
+	if driver == DriverSQLite {
+		dsn = SQLiteDSN(dsn)
+	}
+	if opts.ClickHouse != nil {
+		if driver != DriverClickHouse {
+			return nil, fmt.Errorf("sqldb: clickhouse options of %s driver", driver)
+		}
+		clickhouseDSN, err := ClickHouseDSN(dsn, *opts.ClickHouse)
+		if err != nil {
+			return nil, err
+		}
+		dsn = clickhouseDSN
+	}
+	if opts.SQLServer != nil {
+		if driver != DriverSQLServer {
+			return nil, fmt.Errorf("sqldb: sqlserver options of %s driver", driver)
+		}
+		sqlserverDSN, err := SQLServerDSN(dsn, *opts.SQLServer)
+		if err != nil {
+			return nil, err
+		}
+		dsn = sqlserverDSN
+	}
+	if opts.TLS != nil {
+		tlsDSN, err := WithTLS(driver, dsn, *opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		dsn = tlsDSN
+	}
 
-	db, err := connectWithRetry(ctx, driver, dsn, opts.Retry)
+	open := func(ctx context.Context) (*sqlx.DB, error) {
+		return sqlx.ConnectContext(ctx, driver, dsn)
+	}
+	switch {
+	case opts.Backend == BackendPgx:
+		open = func(ctx context.Context) (*sqlx.DB, error) {
+			sqlDB, err := pgxBackend.open(dsn, opts.Password)
+			if err != nil {
+				return nil, err
+			}
+			// postgres driver name keep the bindvar and the postgres queries of sqldb
+			db := sqlx.NewDb(sqlDB, driver)
+			if err := db.PingContext(ctx); err != nil {
+				db.Close()
+				return nil, err
+			}
+			return db, nil
+		}
+	case opts.Password != nil:
+		connector, err := newPasswordConnector(driver, dsn, opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		open = func(ctx context.Context) (*sqlx.DB, error) {
+			db := sqlx.NewDb(sql.OpenDB(connector), driver)
+			if err := db.PingContext(ctx); err != nil {
+				db.Close()
+				return nil, err
+			}
+			return db, nil
+		}
+	}
+
+	db, err := connectWithRetry(ctx, open, opts.Retry)
 	if err != nil {
 		return nil, err
 	}
+	if driver == DriverSQLite {
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+		return db, nil
+	}
 
 	db.SetMaxOpenConns(opts.MaxOpenConnections)
 	db.SetMaxIdleConns(opts.MaxIdleConnections)
 	db.SetConnMaxLifetime(opts.ConnectionMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnectionMaxIdleTime)
 	return db, nil
 }
 
-func connectWithRetry(ctx context.Context, driver, dsn string, retry int) (*sqlx.DB, error) {
-	var (
-		sqlxdb *sqlx.DB
-		err    error
-	)
-
-	if retry == 0 {
-		sqlxdb, err = sqlx.ConnectContext(ctx, driver, dsn)
-		if err != nil {
-			return nil, err
+// registered return true when the driver is registered to database/sql
+func registered(driver string) bool {
+	for _, name := range sql.Drivers() {
+		if name == driver {
+			return true
 		}
-		return sqlxdb, err
 	}
+	return false
+}
 
-	for x := 0; x < retry; x++ {
-		sqlxdb, err = sqlx.ConnectContext(ctx, driver, dsn)
-		if err == nil {
-			break
-		}
-		if x+1 == retry && err != nil {
-			return nil, fmt.Errorf("sqldb: failed connect to database: %s", err.Error())
-		}
-		time.Sleep(time.Second * 3)
+func connectWithRetry(ctx context.Context, open func(ctx context.Context) (*sqlx.DB, error), attempts int) (*sqlx.DB, error) {
+	var sqlxdb *sqlx.DB
+
+	if attempts <= 0 {
+		attempts = 1
 	}
-	return sqlxdb, err
+	policy := retry.Policy{
+		MaxAttempts:     attempts,
+		InitialInterval: time.Second * 3,
+		MaxInterval:     time.Second * 30,
+		Jitter:          0.2,
+	}
+	err := retry.Do(ctx, &policy, func(ctx context.Context) error {
+		var err error
+		sqlxdb, err = open(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: failed connect to database: %w", err)
+	}
+	return sqlxdb, nil
 }
 
 // Close all database connection to leader and replica
 func (db *DB) Close() error {
-	if err := db.leader.Close(); err != nil {
-		return err
+	db.closeOnce.Do(func() {
+		close(db.stop)
+	})
+	if db.stmtCache != nil {
+		db.stmtCache.close()
 	}
-	if err := db.follower.Close(); err != nil {
+	if err := db.Leader().Close(); err != nil {
 		return err
 	}
+	for _, f := range db.followers {
+		if f.leader {
+			continue
+		}
+		if err := f.db.Close(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Leader return leader database connection
+// Leader return leader database connection, all queries to the connection is going to leader
+// the connection is changed when the leader is swapped by the failover, see SetFailover
 func (db *DB) Leader() *sqlx.DB {
+	db.leaderMu.RLock()
+	defer db.leaderMu.RUnlock()
 	return db.leader
 }
 
-// Follower return follower database connection
+// Follower return follower database connection, all queries to the connection is going to follower
+// the follower is picked by the load balancer from healthy followers for every call
+// the connection is the same as leader when the database has no follower or no follower is healthy
 func (db *DB) Follower() *sqlx.DB {
-	return db.follower
+	if f := db.pickFollower(); f != nil {
+		return db.followerDB(f)
+	}
+	return db.Leader()
+}
+
+// Stats of leader and follower connection pool
+type Stats struct {
+	Leader sql.DBStats `json:"leader"`
+	// Follower is the sum of all followers statistics
+	Follower  sql.DBStats     `json:"follower"`
+	Followers []FollowerStats `json:"followers"`
+	// LeaderCircuit is the state of the leader circuit breaker, empty when it has no circuit breaker
+	LeaderCircuit string `json:"leader_circuit,omitempty"`
+	// StmtCache is the statistics of the prepared statement cache, nil when the cache is disabled
+	StmtCache *StmtCacheStats `json:"stmt_cache,omitempty"`
+}
+
+// Stats return connection pool statistics of leader and follower
+func (db *DB) Stats() Stats {
+	stats := Stats{
+		Leader:        db.Leader().Stats(),
+		LeaderCircuit: circuitState(db.leaderBreaker),
+		StmtCache:     db.stmtCacheStats(),
+		Followers:     make([]FollowerStats, 0, len(db.followers)),
+	}
+	for _, f := range db.followers {
+		fstats := db.followerDB(f).Stats()
+		stats.Follower = addStats(stats.Follower, fstats)
+		stats.Followers = append(stats.Followers, FollowerStats{
+			Healthy: f.healthy(),
+			Stale:   f.isStale(),
+			Lag:     f.lastLag(),
+			Circuit: circuitState(f.breaker),
+			Stats:   fstats,
+		})
+	}
+	return stats
 }
 
 // SetMaxIdleConns to sql database
 func (db *DB) SetMaxIdleConns(n int) {
 	db.Leader().SetMaxIdleConns(n)
-	db.Follower().SetMaxIdleConns(n)
+	for _, f := range db.followers {
+		db.followerDB(f).SetMaxIdleConns(n)
+	}
 }
 
 // SetMaxOpenConns to sql database
 func (db *DB) SetMaxOpenConns(n int) {
 	db.Leader().SetMaxOpenConns(n)
-	db.Follower().SetMaxOpenConns(n)
+	for _, f := range db.followers {
+		db.followerDB(f).SetMaxOpenConns(n)
+	}
 }
 
 // SetConnMaxLifetime to sql database
 func (db *DB) SetConnMaxLifetime(t time.Duration) {
 	db.Leader().SetConnMaxLifetime(t)
-	db.Follower().SetConnMaxLifetime(t)
+	for _, f := range db.followers {
+		db.followerDB(f).SetConnMaxLifetime(t)
+	}
 }
 
 // Get return one value in destination using relfection
 func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
-	return db.follower.Get(dest, query, args...)
+	return db.GetContext(context.Background(), dest, query, args...)
 }
 
 // Select return more than one value in destintion using reflection
 func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
-	return db.follower.Select(dest, query, args...)
+	return db.SelectContext(context.Background(), dest, query, args...)
 }
 
 // Query function
 func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return db.follower.Query(query, args...)
+	return db.QueryContext(context.Background(), query, args...)
 }
 
 // NamedQuery function
 func (db *DB) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
-	return db.follower.NamedQuery(query, arg)
+	return db.NamedQueryContext(context.Background(), query, arg)
 }
 
 // QueryRow function
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
-	return db.follower.QueryRow(query, args...)
+	return db.QueryRowContext(context.Background(), query, args...)
 }
 
 // Exec function
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return db.leader.Exec(query, args...)
+	return db.ExecContext(context.Background(), query, args...)
 }
 
 // NamedExec execute query with named parameter
 func (db *DB) NamedExec(query string, arg interface{}) (sql.Result, error) {
-	return db.leader.NamedExec(query, arg)
+	return db.NamedExecContext(context.Background(), query, arg)
 }
 
 // Begin return sql transaction object, begin a transaction
 func (db *DB) Begin() (*sql.Tx, error) {
-	return db.leader.Begin()
+	return db.Leader().Begin()
 }
 
 // Beginx return sqlx transaction object, begin a transaction
 func (db *DB) Beginx() (*sqlx.Tx, error) {
-	return db.leader.Beginx()
+	return db.Leader().Beginx()
 }
 
 // Rebind query
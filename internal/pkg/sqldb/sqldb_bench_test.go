@@ -0,0 +1,184 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// benchDriver is a sql driver which return a fixed row without any network call
+// so the benchmark measure the routing and scanning overhead of the wrapper only
+type benchDriver struct {
+	queries *int64
+}
+
+func (d benchDriver) Open(name string) (driver.Conn, error) {
+	return benchConn{queries: d.queries}, nil
+}
+
+type benchConn struct {
+	queries *int64
+}
+
+func (c benchConn) Prepare(query string) (driver.Stmt, error) {
+	return benchStmt{queries: c.queries}, nil
+}
+
+func (c benchConn) Close() error {
+	return nil
+}
+
+func (c benchConn) Begin() (driver.Tx, error) {
+	return benchTx{}, nil
+}
+
+type benchTx struct{}
+
+func (benchTx) Commit() error {
+	return nil
+}
+
+func (benchTx) Rollback() error {
+	return nil
+}
+
+type benchStmt struct {
+	queries *int64
+}
+
+func (s benchStmt) Close() error {
+	return nil
+}
+
+func (s benchStmt) NumInput() int {
+	return -1
+}
+
+func (s benchStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s benchStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(s.queries, 1)
+	return &benchRows{}, nil
+}
+
+type benchRows struct {
+	done bool
+}
+
+func (r *benchRows) Columns() []string {
+	return []string{"id", "name"}
+}
+
+func (r *benchRows) Close() error {
+	return nil
+}
+
+func (r *benchRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	dest[1] = "name"
+	return nil
+}
+
+var benchDriverID int64
+
+// newBenchDB return wrapped db with different leader and follower, and the query counter of each
+func newBenchDB(b *testing.B) (db *DB, leaderQueries, followerQueries *int64) {
+	b.Helper()
+	leaderQueries, followerQueries = new(int64), new(int64)
+	open := func(queries *int64) *sqlx.DB {
+		name := "sqldb_bench_" + strconv.FormatInt(atomic.AddInt64(&benchDriverID, 1), 10)
+		sql.Register(name, benchDriver{queries: queries})
+		sqldb, err := sql.Open(name, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		return sqlx.NewDb(sqldb, DriverPostgres)
+	}
+	db, err := Wrap(context.Background(), open(leaderQueries), open(followerQueries))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return db, leaderQueries, followerQueries
+}
+
+type benchRow struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func BenchmarkQueryFollower(b *testing.B) {
+	db, leader, follower := newBenchDB(b)
+	defer db.Close()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		row := benchRow{}
+		if err := db.GetContext(ctx, &row, "SELECT id, name FROM bench WHERE id = $1", 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if *leader != 0 || *follower != int64(b.N) {
+		b.Fatalf("expecting all queries routed to follower, leader %d follower %d", *leader, *follower)
+	}
+}
+
+func BenchmarkQueryFollowerParallel(b *testing.B) {
+	db, _, _ := newBenchDB(b)
+	defer db.Close()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			row := benchRow{}
+			if err := db.GetContext(ctx, &row, "SELECT id, name FROM bench WHERE id = $1", 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkExecLeader(b *testing.B) {
+	db, _, _ := newBenchDB(b)
+	defer db.Close()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ExecContext(ctx, "UPDATE bench SET name = $1 WHERE id = $2", "name", 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNamedExecLeader(b *testing.B) {
+	db, _, _ := newBenchDB(b)
+	defer db.Close()
+	ctx := context.Background()
+	row := benchRow{ID: 1, Name: "name"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.NamedExecContext(ctx, "UPDATE bench SET name = :name WHERE id = :id", row); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,34 +3,189 @@ package sqldb
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 // GetContext function
 func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return db.follower.GetContext(ctx, dest, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+	_, err := intercept(ctx, db.middlewares, Query{Query: query, Args: args}, func(ctx context.Context, q Query) (sql.Result, error) {
+		return nil, db.call(ctx, q.Query, len(q.Args), false, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			if stmt, release, ok := db.prepared(ctx, conn, q.Query, len(q.Args)); ok {
+				defer release()
+				return nil, stmt.GetContext(ctx, dest, q.Args...)
+			}
+			return nil, conn.GetContext(ctx, dest, q.Query, q.Args...)
+		})
+	})
+	return err
 }
 
 // SelectContext fuction
 func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return db.follower.SelectContext(ctx, dest, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+	_, err := intercept(ctx, db.middlewares, Query{Query: query, Args: args}, func(ctx context.Context, q Query) (sql.Result, error) {
+		return nil, db.call(ctx, q.Query, len(q.Args), false, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			if stmt, release, ok := db.prepared(ctx, conn, q.Query, len(q.Args)); ok {
+				defer release()
+				return nil, stmt.SelectContext(ctx, dest, q.Args...)
+			}
+			return nil, conn.SelectContext(ctx, dest, q.Query, q.Args...)
+		})
+	})
+	return err
 }
 
 // QueryContext function
+// the query timeout is not canceled when the rows is returned, so the rows can be read until the timeout
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return db.follower.QueryContext(ctx, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx)
+	var rows *sql.Rows
+	_, err := intercept(ctx, db.middlewares, Query{Query: query, Args: args}, func(ctx context.Context, q Query) (sql.Result, error) {
+		return nil, db.call(ctx, q.Query, len(q.Args), false, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			var err error
+			if stmt, release, ok := db.prepared(ctx, conn, q.Query, len(q.Args)); ok {
+				defer release()
+				rows, err = stmt.QueryContext(ctx, q.Args...)
+				return nil, err
+			}
+			rows, err = conn.QueryContext(ctx, q.Query, q.Args...)
+			return nil, err
+		})
+	})
+	if err != nil {
+		cancel()
+	}
+	return rows, err
 }
 
 // QueryRowContext function
+// the error of the query is only returned by Scan, so the query is not retried and the hook and span have no error
+// when a middleware reject the query, Scan return context.Canceled as sql.Row can't hold the error of the middleware
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return db.follower.QueryRowContext(ctx, query, args...)
+	// the row is scanned after return, the timeout context is released when the timeout passed
+	ctx, _ = db.withQueryTimeout(ctx)
+	var row *sql.Row
+	_, err := intercept(ctx, db.middlewares, Query{Query: query, Args: args}, func(ctx context.Context, q Query) (sql.Result, error) {
+		leader := db.Leader()
+		conn := db.route(ctx, q.Query)
+		ctx, span := db.startSpan(ctx, q.Query, conn == leader)
+		start := time.Now()
+		if stmt, release, ok := db.prepared(ctx, conn, q.Query, len(q.Args)); ok {
+			row = stmt.QueryRowContext(ctx, q.Args...)
+			release()
+		} else {
+			row = conn.QueryRowContext(ctx, q.Query, q.Args...)
+		}
+		endSpan(span, nil)
+		if len(db.hooks) > 0 {
+			db.runHooks(ctx, QueryEvent{
+				Query:        q.Query,
+				Args:         len(q.Args),
+				Duration:     time.Since(start),
+				RowsAffected: -1,
+				Leader:       conn == leader,
+			})
+		}
+		return nil, nil
+	})
+	if err != nil || row == nil {
+		return canceledRow(ctx, db.Leader(), query)
+	}
+	return row
 }
 
 // ExecContext function
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return db.leader.ExecContext(ctx, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+	return intercept(ctx, db.middlewares, Query{Query: query, Args: args, Exec: true}, func(ctx context.Context, q Query) (sql.Result, error) {
+		var result sql.Result
+		err := db.call(ctx, q.Query, len(q.Args), true, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			var err error
+			if stmt, release, ok := db.prepared(ctx, conn, q.Query, len(q.Args)); ok {
+				defer release()
+				result, err = stmt.ExecContext(ctx, q.Args...)
+				return result, err
+			}
+			result, err = conn.ExecContext(ctx, q.Query, q.Args...)
+			return result, err
+		})
+		return result, err
+	})
 }
 
 // NamedExecContext function
 func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
-	return db.leader.NamedExecContext(ctx, query, arg)
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+	q := Query{Query: query, Args: []interface{}{arg}, Named: true, Exec: true}
+	return intercept(ctx, db.middlewares, q, func(ctx context.Context, q Query) (sql.Result, error) {
+		var result sql.Result
+		err := db.call(ctx, q.Query, 1, true, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			var err error
+			result, err = conn.NamedExecContext(ctx, q.Query, namedArg(q))
+			return result, err
+		})
+		return result, err
+	})
+}
+
+// NamedQueryContext function
+// the query is routed the same as QueryContext, and the rows can be read until the query timeout
+func (db *DB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	var rows *sqlx.Rows
+	q := Query{Query: query, Args: []interface{}{arg}, Named: true}
+	_, err := intercept(ctx, db.middlewares, q, func(ctx context.Context, q Query) (sql.Result, error) {
+		return nil, db.call(ctx, q.Query, 1, false, func(ctx context.Context, conn *sqlx.DB) (sql.Result, error) {
+			var err error
+			rows, err = conn.NamedQueryContext(ctx, q.Query, namedArg(q))
+			return nil, err
+		})
+	})
+	if err != nil {
+		cancel()
+	}
+	return rows, err
+}
+
+// namedArg return the named parameters of the query, nil when the middleware removed the argument
+func namedArg(q Query) interface{} {
+	if len(q.Args) == 0 {
+		return nil
+	}
+	return q.Args[0]
+}
+
+// NamedGetContext return one value in destination, the named parameters are bound from the struct or map arg
+func (db *DB) NamedGetContext(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	query, args, err := db.bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return db.GetContext(ctx, dest, query, args...)
+}
+
+// NamedSelectContext return more than one value in destination, the named parameters are bound from the struct or map arg
+func (db *DB) NamedSelectContext(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	query, args, err := db.bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return db.SelectContext(ctx, dest, query, args...)
+}
+
+// bindNamed return the query with the bind type of the driver and the arguments of the named parameters
+func (db *DB) bindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	bound, args, err := sqlx.BindNamed(sqlx.BindType(db.driver), query, arg)
+	if err != nil {
+		return "", nil, fmt.Errorf("sqldb: failed to bind named query: %w", err)
+	}
+	return bound, args, nil
 }
@@ -0,0 +1,174 @@
+// Package sqldbtest provide *sqldb.DB backed by sqlmock for testing, so handler tests can assert the queries without a real database
+// the leader and every follower have their own sqlmock, so the test can assert whether the query go to the leader or the follower
+//
+//	mock, err := sqldbtest.New(nil)
+//	mock.Follower(0).ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("gopher"))
+//	mock.Leader().ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+//	handler := newHandler(mock.DB)
+//	...
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+//
+// the queries are also recorded, so the test can check the queries after the handler is called
+package sqldbtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+// Options of the mock
+type Options struct {
+	// Driver of the db for query rebinding and the driver specific query, default to postgres
+	Driver string
+	// Followers is the number of followers with their own sqlmock, default to 1
+	Followers int
+	// LeaderOnly use the leader as the follower, so every query is expected in the leader sqlmock
+	LeaderOnly bool
+	// QueryMatcher of the sqlmock, default to the regular expression matcher of sqlmock
+	QueryMatcher sqlmock.QueryMatcher
+	// FollowerOptions of the db, for example the balancer of the followers
+	FollowerOptions *sqldb.FollowerOptions
+}
+
+// Mock of sqldb.DB
+type Mock struct {
+	DB *sqldb.DB
+
+	leader    sqlmock.Sqlmock
+	followers []sqlmock.Sqlmock
+
+	mu      sync.Mutex
+	queries []sqldb.QueryEvent
+}
+
+// New mock of sqldb.DB, the db has one follower by default
+func New(options *Options) (*Mock, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Driver == "" {
+		opts.Driver = sqldb.DriverPostgres
+	}
+	if opts.Followers <= 0 {
+		opts.Followers = 1
+	}
+
+	m := Mock{}
+	leader, mock, err := newConn(opts)
+	if err != nil {
+		return nil, err
+	}
+	m.leader = mock
+	conns := []*sqlx.DB{leader}
+
+	var followers []*sqlx.DB
+	if !opts.LeaderOnly {
+		for i := 0; i < opts.Followers; i++ {
+			follower, mock, err := newConn(opts)
+			if err != nil {
+				closeAll(conns)
+				return nil, err
+			}
+			m.followers = append(m.followers, mock)
+			followers = append(followers, follower)
+			conns = append(conns, follower)
+		}
+	}
+	db, err := sqldb.WrapFollowers(context.Background(), leader, followers, opts.FollowerOptions)
+	if err != nil {
+		closeAll(conns)
+		return nil, err
+	}
+	db.AddHook(m.record)
+	m.DB = db
+	return &m, nil
+}
+
+// newConn return connection backed by sqlmock
+func newConn(opts Options) (*sqlx.DB, sqlmock.Sqlmock, error) {
+	matcher := opts.QueryMatcher
+	if matcher == nil {
+		matcher = sqlmock.QueryMatcherRegexp
+	}
+	mockdb, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		return nil, nil, err
+	}
+	return sqlx.NewDb(mockdb, opts.Driver), mock, nil
+}
+
+func closeAll(conns []*sqlx.DB) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Leader return the sqlmock of the leader, the exec and the query in transaction always go to the leader
+func (m *Mock) Leader() sqlmock.Sqlmock {
+	return m.leader
+}
+
+// Follower return the sqlmock of the follower by index, the leader is returned when the mock is leader only
+// the read queries are balanced between the followers by the balancer of FollowerOptions
+func (m *Mock) Follower(idx int) sqlmock.Sqlmock {
+	if len(m.followers) == 0 {
+		return m.leader
+	}
+	if idx < 0 || idx >= len(m.followers) {
+		panic(fmt.Sprintf("sqldbtest: follower with index %d does not exists", idx))
+	}
+	return m.followers[idx]
+}
+
+// ExpectationsWereMet return the first error of unmet expectations of the leader and the followers
+func (m *Mock) ExpectationsWereMet() error {
+	if err := m.leader.ExpectationsWereMet(); err != nil {
+		return fmt.Errorf("leader: %w", err)
+	}
+	for idx, follower := range m.followers {
+		if err := follower.ExpectationsWereMet(); err != nil {
+			return fmt.Errorf("follower %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// record the query after every attempt, the query in transaction is not recorded as it is not passed to the hooks
+func (m *Mock) record(ctx context.Context, event sqldb.QueryEvent) {
+	m.mu.Lock()
+	m.queries = append(m.queries, event)
+	m.mu.Unlock()
+}
+
+// Queries return the recorded queries, Leader of the event is false when the query go to the follower
+func (m *Mock) Queries() []sqldb.QueryEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queries := make([]sqldb.QueryEvent, len(m.queries))
+	copy(queries, m.queries)
+	return queries
+}
+
+// Reset the recorded queries
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	m.queries = nil
+	m.mu.Unlock()
+}
+
+// Close the db, the close of the connections is expected so it doesn't fail the expectations of sqlmock
+func (m *Mock) Close() error {
+	m.leader.ExpectClose()
+	for _, follower := range m.followers {
+		follower.ExpectClose()
+	}
+	return m.DB.Close()
+}
@@ -0,0 +1,91 @@
+package sqldbtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb/sqldbtest"
+)
+
+func TestMock(t *testing.T) {
+	mock, err := sqldbtest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.Follower(0).ExpectQuery("SELECT name FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("gopher"))
+	mock.Leader().ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.Leader().ExpectQuery("SELECT name FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("golang"))
+
+	var name string
+	if err := mock.DB.GetContext(ctx, &name, mock.DB.Rebind("SELECT name FROM users WHERE id = ?"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if name != "gopher" {
+		t.Fatalf("expecting gopher from the follower but got %s", name)
+	}
+	if _, err := mock.DB.ExecContext(ctx, "UPDATE users SET name = 'golang' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	// read your own write from the leader
+	if err := mock.DB.GetContext(sqldb.ForceLeader(ctx), &name, "SELECT name FROM users WHERE id = $1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if name != "golang" {
+		t.Fatalf("expecting golang from the leader but got %s", name)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := mock.Queries()
+	if len(queries) != 3 {
+		t.Fatalf("expecting 3 queries but got %+v", queries)
+	}
+	if queries[0].Leader || !queries[1].Leader || !queries[2].Leader {
+		t.Fatalf("unexpected target of the queries %+v", queries)
+	}
+	mock.Reset()
+	if len(mock.Queries()) != 0 {
+		t.Fatal("expecting no queries after reset")
+	}
+	if err := mock.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockLeaderOnly(t *testing.T) {
+	mock, err := sqldbtest.New(&sqldbtest.Options{
+		Driver:       sqldb.DriverMySQL,
+		LeaderOnly:   true,
+		QueryMatcher: sqlmock.QueryMatcherEqual,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	if mock.Follower(0) != mock.Leader() {
+		t.Fatal("expecting the leader is the follower")
+	}
+	mock.Leader().ExpectQuery("SELECT COUNT(*) FROM users WHERE status = ?").
+		WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	var count int
+	if err := mock.DB.GetContext(context.Background(), &count, mock.DB.Rebind("SELECT COUNT(*) FROM users WHERE status = ?"), "active"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expecting 2 but got %d", count)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+package sqldb
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SQLiteMemory is the dsn of in-memory sqlite database, the database is dropped when the db is closed
+const SQLiteMemory = ":memory:"
+
+// SQLiteDSN return the sqlite dsn which enforce foreign keys like postgres and mysql
+// foreign keys is kept when it is set in the dsn, for example file:local.db?_foreign_keys=0
+// the busy timeout of the driver is already 5s when it is not set
+func SQLiteDSN(dsn string) string {
+	rawQuery := ""
+	if idx := strings.Index(dsn, "?"); idx >= 0 {
+		rawQuery = dsn[idx+1:]
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// the driver report the invalid parameters when connecting
+		return dsn
+	}
+	if _, ok := query["_foreign_keys"]; ok {
+		return dsn
+	}
+	if _, ok := query["_fk"]; ok {
+		return dsn
+	}
+	if rawQuery == "" {
+		return strings.TrimSuffix(dsn, "?") + "?_foreign_keys=1"
+	}
+	return dsn + "&_foreign_keys=1"
+}
@@ -0,0 +1,122 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	cases := []struct {
+		dsn    string
+		expect string
+	}{
+		{SQLiteMemory, ":memory:?_foreign_keys=1"},
+		{"file:local.db?cache=shared", "file:local.db?cache=shared&_foreign_keys=1"},
+		{"local.db?_fk=0", "local.db?_fk=0"},
+		{"file:local.db?_foreign_keys=0&mode=ro", "file:local.db?_foreign_keys=0&mode=ro"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.dsn, func(t *testing.T) {
+			if got := SQLiteDSN(c.dsn); got != c.expect {
+				t.Fatalf("expecting %s but got %s", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestSQLiteMemory(t *testing.T) {
+	ctx := context.Background()
+	conn, err := Connect(ctx, "sqlite", SQLiteMemory, &ConnectOptions{MaxOpenConnections: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.DriverName() != DriverSQLite {
+		t.Fatalf("expecting driver %s but got %s", DriverSQLite, conn.DriverName())
+	}
+	if n := conn.Stats().MaxOpenConnections; n != 1 {
+		t.Fatalf("expecting 1 connection but got %d", n)
+	}
+	db, err := WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// every query use the same connection, so the in-memory tables are visible to all queries
+	if _, err := db.ExecContext(ctx, "CREATE TABLE teams (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, team_id INTEGER REFERENCES teams(id), name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (id, team_id, name) VALUES (?, ?, ?)", 1, 100, "a"); err == nil {
+		t.Fatal("expecting foreign key error")
+	}
+
+	if _, err := db.Upsert(ctx, "teams", []string{"id"}, map[string]interface{}{"id": 1, "name": "old"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Upsert(ctx, "teams", []string{"id"}, map[string]interface{}{"id": 1, "name": "new"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := db.GetContext(ctx, &name, "SELECT name FROM teams WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if name != "new" {
+		t.Fatalf("expecting upserted name new but got %s", name)
+	}
+
+	// the batch is reduced to the placeholders limit of sqlite
+	rows := make([][]interface{}, 500)
+	for idx := range rows {
+		rows[idx] = []interface{}{idx + 1, 1, "user"}
+	}
+	inserted, err := db.BulkInsert(ctx, "users", []string{"id", "team_id", "name"}, rows, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 500 || count != 500 {
+		t.Fatalf("expecting 500 inserted rows but got %d and %d", inserted, count)
+	}
+
+	err = db.WithTransaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users"); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("expecting rollback error")
+	}
+	if err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 500 {
+		t.Fatalf("expecting rows are kept after rollback but got %d", count)
+	}
+}
+
+func TestSQLiteClassify(t *testing.T) {
+	if !IsTransient(DriverSQLite, sqlite3.Error{Code: sqlite3.ErrBusy}) {
+		t.Error("expecting busy database is transient")
+	}
+	if IsTransient(DriverSQLite, sqlite3.Error{Code: sqlite3.ErrConstraint}) {
+		t.Error("expecting constraint error is not transient")
+	}
+	if _, err := BuildDSN("sqlite", DSNConfig{Host: "localhost"}); !errors.Is(err, ErrDriverNotSupported) {
+		t.Errorf("expecting %v but got %v", ErrDriverNotSupported, err)
+	}
+	if err := ValidateDSN("sqlite", SQLiteMemory); err != nil {
+		t.Errorf("expecting valid dsn but got %v", err)
+	}
+}
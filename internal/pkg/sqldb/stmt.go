@@ -0,0 +1,196 @@
+package sqldb
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StmtCacheStats of the prepared statement cache, the hit rate is Hits / (Hits + Misses)
+type StmtCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// Size is the number of cached statements of all connections
+	Size int `json:"size"`
+	// MaxSize of the cached statements per connection
+	MaxSize int `json:"max_size"`
+}
+
+// stmtCache of the prepared statements, every connection of the leader and the followers has its own lru
+type stmtCache struct {
+	maxSize int
+
+	mu    sync.Mutex
+	conns map[*sqlx.DB]*stmtLRU
+	stats StmtCacheStats
+}
+
+// stmtLRU of the prepared statements of a connection, the front is the most recently used
+type stmtLRU struct {
+	order *list.List
+	stmts map[string]*list.Element
+}
+
+// cachedStmt is released after the query, the evicted statement is closed when it is no longer used
+type cachedStmt struct {
+	query   string
+	stmt    *sqlx.Stmt
+	refs    int
+	evicted bool
+}
+
+// SetStmtCache cache up to size prepared statements per connection of the leader and the followers
+// so hot queries are not prepared on every call, the least recently used statement is closed when the cache is full
+// only the query with arguments outside of transaction is prepared, as the query without arguments is mostly ddl or
+// multiple statements, and the query with comment is not prepared. the cache is disabled when size is zero, and it must be set before the db is used
+func (db *DB) SetStmtCache(size int) {
+	if size <= 0 {
+		db.stmtCache = nil
+		return
+	}
+	db.stmtCache = &stmtCache{
+		maxSize: size,
+		conns:   make(map[*sqlx.DB]*stmtLRU),
+	}
+}
+
+// stmtCacheStats return the statistics of the statement cache, nil when the cache is disabled
+func (db *DB) stmtCacheStats() *StmtCacheStats {
+	if db.stmtCache == nil {
+		return nil
+	}
+	return db.stmtCache.snapshot()
+}
+
+// prepared return the cached statement of the query in the connection, false is returned when the statement is not used
+// the release function must be called after the query is executed
+func (db *DB) prepared(ctx context.Context, conn *sqlx.DB, query string, args int) (*sqlx.Stmt, func(), bool) {
+	c := db.stmtCache
+	// the query with comment is different for every request
+	if c == nil || args == 0 || hasComment(query) {
+		return nil, nil, false
+	}
+	entry, err := c.acquire(ctx, conn, query)
+	if err != nil {
+		// the query is run without statement, so the error is returned by the query itself
+		return nil, nil, false
+	}
+	return entry.stmt, func() { c.release(entry) }, true
+}
+
+// acquire the statement of the query, the statement is prepared when it is not cached
+func (c *stmtCache) acquire(ctx context.Context, conn *sqlx.DB, query string) (*cachedStmt, error) {
+	c.mu.Lock()
+	if entry, ok := c.get(conn, query); ok {
+		c.stats.Hits++
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// prepare outside of the lock, so a slow prepare doesn't block the other queries
+	stmt, err := conn.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.get(conn, query); ok {
+		// the statement is prepared by another call at the same time
+		stmt.Close()
+		entry.refs++
+		return entry, nil
+	}
+	lru, ok := c.conns[conn]
+	if !ok {
+		lru = &stmtLRU{order: list.New(), stmts: make(map[string]*list.Element)}
+		c.conns[conn] = lru
+	}
+	entry := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	lru.stmts[query] = lru.order.PushFront(entry)
+	for lru.order.Len() > c.maxSize {
+		oldest := lru.order.Back()
+		lru.order.Remove(oldest)
+		evicted := oldest.Value.(*cachedStmt)
+		delete(lru.stmts, evicted.query)
+		c.stats.Evictions++
+		c.evict(evicted)
+	}
+	return entry, nil
+}
+
+// get the cached statement and move it to the front, the lock must be held
+func (c *stmtCache) get(conn *sqlx.DB, query string) (*cachedStmt, bool) {
+	lru, ok := c.conns[conn]
+	if !ok {
+		return nil, false
+	}
+	elem, ok := lru.stmts[query]
+	if !ok {
+		return nil, false
+	}
+	lru.order.MoveToFront(elem)
+	return elem.Value.(*cachedStmt), true
+}
+
+// release the statement after the query, the rows of the query can still be read after the statement is closed
+func (c *stmtCache) release(entry *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// evict close the statement when it is not used, or mark it to be closed on release, the lock must be held
+func (c *stmtCache) evict(entry *cachedStmt) {
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// drop the statements of the connection, for example after the leader is swapped by the failover
+func (c *stmtCache) drop(conn *sqlx.DB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lru, ok := c.conns[conn]
+	if !ok {
+		return
+	}
+	for elem := lru.order.Front(); elem != nil; elem = elem.Next() {
+		c.evict(elem.Value.(*cachedStmt))
+	}
+	delete(c.conns, conn)
+}
+
+// close all statements of every connection
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	conns := make([]*sqlx.DB, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+	for _, conn := range conns {
+		c.drop(conn)
+	}
+}
+
+func (c *stmtCache) snapshot() *StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.MaxSize = c.maxSize
+	for _, lru := range c.conns {
+		stats.Size += lru.order.Len()
+	}
+	return &stats
+}
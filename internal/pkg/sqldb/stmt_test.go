@@ -0,0 +1,152 @@
+package sqldb
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func newStmtCacheDB(t *testing.T, size int) *DB {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := Connect(ctx, DriverSQLite, SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"CREATE TABLE books (id INTEGER PRIMARY KEY, title TEXT)",
+		"INSERT INTO books VALUES (1, 'go'), (2, 'sql'), (3, 'redis')",
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.SetStmtCache(size)
+	return db
+}
+
+func TestStmtCache(t *testing.T) {
+	db := newStmtCacheDB(t, 2)
+	defer db.Close()
+	ctx := context.Background()
+
+	var title string
+	for i := 0; i < 3; i++ {
+		if err := db.GetContext(ctx, &title, "SELECT title FROM books WHERE id = ?", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if title != "go" {
+		t.Fatalf("expecting title go but got %s", title)
+	}
+	stats := db.Stats().StmtCache
+	if stats == nil || stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 || stats.MaxSize != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// the query without arguments is not prepared
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM books").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || db.Stats().StmtCache.Misses != 1 {
+		t.Fatalf("expecting query without arguments is not cached but got %+v", db.Stats().StmtCache)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE books SET title = ? WHERE id = ?", "golang", 1); err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	if err := db.SelectContext(ctx, &titles, "SELECT title FROM books WHERE id <= ? ORDER BY id", 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 2 || titles[0] != "golang" {
+		t.Fatalf("unexpected titles %v", titles)
+	}
+	// the least recently used statement is evicted
+	stats = db.Stats().StmtCache
+	if stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("expecting 1 eviction but got %+v", stats)
+	}
+
+	// the failed query return the error of the query
+	if _, err := db.QueryContext(ctx, "SELECT title FROM missing WHERE id = ?", 1); err == nil {
+		t.Fatal("expecting error but got nil")
+	}
+}
+
+func TestStmtCacheEvictInUse(t *testing.T) {
+	db := newStmtCacheDB(t, 1)
+	defer db.Close()
+	ctx := context.Background()
+	c := db.stmtCache
+	conn := db.Leader()
+
+	inUse, err := c.acquire(ctx, conn, "SELECT title FROM books WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// evict the statement which is still used
+	other, err := c.acquire(ctx, conn, "SELECT id FROM books WHERE title = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.release(other)
+	if !inUse.evicted {
+		t.Fatal("expecting the statement is evicted")
+	}
+	var title string
+	if err := inUse.stmt.QueryRowContext(ctx, 2).Scan(&title); err != nil {
+		t.Fatalf("expecting the evicted statement is not closed while it is used but got %v", err)
+	}
+	if title != "sql" {
+		t.Fatalf("expecting title sql but got %s", title)
+	}
+	c.release(inUse)
+	if err := inUse.stmt.QueryRowContext(ctx, 2).Scan(&title); err == nil {
+		t.Fatal("expecting the evicted statement is closed after release")
+	}
+}
+
+func TestStmtCacheConcurrent(t *testing.T) {
+	db := newStmtCacheDB(t, 2)
+	defer db.Close()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var title string
+			if err := db.GetContext(ctx, &title, "SELECT title FROM books WHERE id = ?", i%3+1); err != nil {
+				errs <- err
+			}
+			// a different query evict the statement which is used by another goroutine
+			if i%5 == 0 {
+				if err := db.GetContext(ctx, &title, "SELECT title FROM books WHERE id = ? AND title != ''", 1); err != nil {
+					errs <- err
+				}
+				if err := db.GetContext(ctx, &title, "SELECT title FROM books WHERE title = ?", "go"); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	db.SetStmtCache(0)
+	if db.Stats().StmtCache != nil {
+		t.Fatal("expecting the cache is disabled")
+	}
+}
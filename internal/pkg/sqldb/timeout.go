@@ -0,0 +1,25 @@
+package sqldb
+
+import (
+	"context"
+	"time"
+)
+
+// SetQueryTimeout set the default timeout of every query, zero disable the timeout
+// the deadline of the query context is kept when it is earlier than the timeout
+// rows returned by Query must be read before the timeout, as the query is canceled when the timeout passed
+// query in transaction is not affected. the timeout must be set before the db is used
+func (db *DB) SetQueryTimeout(timeout time.Duration) {
+	db.queryTimeout = timeout
+}
+
+// withQueryTimeout return the context with the default query timeout
+func (db *DB) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= db.queryTimeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
@@ -0,0 +1,58 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	db := &DB{}
+	db.SetQueryTimeout(time.Second)
+
+	cases := []struct {
+		name     string
+		deadline time.Duration
+		expect   time.Duration
+	}{
+		{name: "no deadline", expect: time.Second},
+		{name: "tighter deadline", deadline: time.Millisecond * 100, expect: time.Millisecond * 100},
+		{name: "looser deadline", deadline: time.Minute, expect: time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.deadline)
+				defer cancel()
+			}
+			ctx, cancel := db.withQueryTimeout(ctx)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatal("expect deadline")
+			}
+			if remaining := time.Until(deadline); remaining > c.expect || remaining < c.expect-time.Millisecond*50 {
+				t.Fatalf("expect deadline in %s but got %s", c.expect, remaining)
+			}
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db, leaderMock, _ := newMockDB(t)
+	defer db.Close()
+	db.SetQueryTimeout(time.Millisecond * 10)
+
+	leaderMock.ExpectExec("UPDATE reports").WillDelayFor(time.Second)
+	start := time.Now()
+	if _, err := db.Exec("UPDATE reports SET total = 1"); err == nil {
+		t.Fatal("expect timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Fatalf("expect query to be canceled but it took %s", elapsed)
+	}
+}
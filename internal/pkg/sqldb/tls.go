@@ -0,0 +1,149 @@
+package sqldb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// list of tls error
+var (
+	ErrTLSNameEmpty   = errors.New("sqldb: tls name is empty")
+	ErrTLSKeyPair     = errors.New("sqldb: tls cert_file and key_file must be set together")
+	ErrTLSServerName  = errors.New("sqldb: tls server_name is not supported by postgres driver, the host of dsn is verified")
+	ErrTLSInvalidCert = errors.New("sqldb: no certificate in tls ca_file")
+)
+
+// TLSConfig of sql connection
+// mysql tls config is registered to the driver by name, postgres tls is passed as ssl parameters of the dsn
+type TLSConfig struct {
+	// Name of the config registered to mysql driver, must be unique per connection, for example payments/leader
+	Name string
+	// CAFile verify the server certificate with the ca, system ca is used when empty
+	CAFile string
+	// CertFile and KeyFile of client certificate for mutual tls
+	CertFile string
+	KeyFile  string
+	// ServerName verified in the server certificate, default to the host of dsn
+	ServerName string
+	// InsecureSkipVerify skip the verification of server certificate
+	InsecureSkipVerify bool
+}
+
+// WithTLS return the dsn which connect with the tls config
+func WithTLS(driver, dsn string, config TLSConfig) (string, error) {
+	if err := ValidateDriver(driver); err != nil {
+		return "", err
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return "", ErrTLSKeyPair
+	}
+	driver = DriverName(driver)
+	if driver == DriverSQLite {
+		return "", fmt.Errorf("%w: tls of %s", ErrDriverNotSupported, driver)
+	}
+	if driver == DriverClickHouse {
+		return withClickHouseTLS(dsn, config)
+	}
+	if driver == DriverSQLServer {
+		return withSQLServerTLS(dsn, config)
+	}
+	if driver == DriverMySQL {
+		return withMySQLTLS(dsn, config)
+	}
+	return withPostgresTLS(dsn, config)
+}
+
+func withMySQLTLS(dsn string, config TLSConfig) (string, error) {
+	if config.Name == "" {
+		return "", ErrTLSNameEmpty
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return "", fmt.Errorf("sqldb: failed to read tls ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return "", ErrTLSInvalidCert
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return "", fmt.Errorf("sqldb: failed to load tls key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("sqldb: invalid mysql dsn: %w", err)
+	}
+	// verify the host of the address when the server name is empty
+	if tlsConfig.ServerName == "" && !tlsConfig.InsecureSkipVerify {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			host = cfg.Addr
+		}
+		tlsConfig.ServerName = host
+	}
+	if err := mysql.RegisterTLSConfig(config.Name, tlsConfig); err != nil {
+		return "", fmt.Errorf("sqldb: failed to register tls config: %w", err)
+	}
+	cfg.TLSConfig = config.Name
+	return cfg.FormatDSN(), nil
+}
+
+func withPostgresTLS(dsn string, config TLSConfig) (string, error) {
+	if config.ServerName != "" {
+		return "", ErrTLSServerName
+	}
+	sslmode := SSLModeVerifyFull
+	if config.InsecureSkipVerify {
+		sslmode = SSLModeRequire
+	}
+	params := []struct{ key, value string }{
+		{"sslmode", sslmode},
+		{"sslrootcert", config.CAFile},
+		{"sslcert", config.CertFile},
+		{"sslkey", config.KeyFile},
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("sqldb: invalid postgres dsn: %w", err)
+		}
+		query := u.Query()
+		for _, param := range params {
+			if param.value != "" {
+				query.Set(param.key, param.value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	// the later key override the earlier key in key=value connection string
+	kvs := []string{strings.TrimSpace(dsn)}
+	for _, param := range params {
+		if param.value != "" {
+			kvs = append(kvs, param.key+"="+quotePostgres(param.value))
+		}
+	}
+	return strings.Join(kvs, " "), nil
+}
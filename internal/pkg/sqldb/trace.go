@@ -0,0 +1,67 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opencensus.io/trace"
+)
+
+// TraceOptions of query tracing
+type TraceOptions struct {
+	// Name of the database in db.name attribute, for example the resource name
+	Name string
+}
+
+// SetTracing create trace span for every query, nil disable the tracing
+// the span has db.system, db.name and the sanitized db.statement attributes, and the status of the query error
+// query in transaction is not traced. tracing must be set before the db is used
+func (db *DB) SetTracing(options *TraceOptions) {
+	db.traceOptions = options
+}
+
+// startSpan start the span of the query, nil span when tracing is disabled
+func (db *DB) startSpan(ctx context.Context, query string, leader bool) (context.Context, *trace.Span) {
+	if db.traceOptions == nil {
+		return ctx, nil
+	}
+	ctx, span := trace.StartSpan(ctx, "sqldb/"+strings.ToUpper(firstKeyword(query)), trace.WithSpanKind(trace.SpanKindClient))
+	// sanitizing is skipped when the span is not sampled
+	if span.IsRecordingEvents() {
+		target := "follower"
+		if leader {
+			target = "leader"
+		}
+		span.AddAttributes(
+			trace.StringAttribute("db.system", dbSystem(db.driver)),
+			trace.StringAttribute("db.name", db.traceOptions.Name),
+			trace.StringAttribute("db.statement", SanitizeQuery(query)),
+			trace.StringAttribute("db.target", target),
+		)
+	}
+	return ctx, span
+}
+
+// endSpan set the status of the span from the query error, no rows is not an error
+func endSpan(span *trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	span.End()
+}
+
+// dbSystem return the db.system attribute of the driver
+func dbSystem(driverName string) string {
+	switch driverName {
+	case DriverPostgres:
+		return "postgresql"
+	case DriverSQLite:
+		return "sqlite"
+	}
+	return driverName
+}
@@ -0,0 +1,73 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.opencensus.io/trace"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestTracing(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	defer trace.UnregisterExporter(recorder)
+
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+	db.SetTracing(&TraceOptions{Name: "users"})
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	leaderMock.ExpectExec("UPDATE users").WillReturnError(errors.New("failed"))
+	followerMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a"))
+
+	db.ExecContext(ctx, "UPDATE users SET name = 'a' WHERE id = $1", 1)
+	var name string
+	if err := db.GetContext(ctx, &name, "SELECT name FROM users WHERE id = 10"); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.spans) != 3 {
+		t.Fatalf("expect 3 spans but got %d", len(recorder.spans))
+	}
+
+	exec := recorder.spans[0]
+	if exec.Name != "sqldb/UPDATE" || exec.ParentSpanID != parent.SpanContext().SpanID {
+		t.Fatalf("unexpected exec span %s", exec.Name)
+	}
+	if exec.Status.Code != trace.StatusCodeUnknown {
+		t.Fatalf("expect error status but got %v", exec.Status)
+	}
+	expect := map[string]interface{}{
+		"db.system":    "postgresql",
+		"db.name":      "users",
+		"db.statement": "UPDATE users SET name = ? WHERE id = $1",
+		"db.target":    "leader",
+	}
+	for key, value := range expect {
+		if exec.Attributes[key] != value {
+			t.Fatalf("expect %s = %v but got %v", key, value, exec.Attributes[key])
+		}
+	}
+
+	query := recorder.spans[1]
+	if query.Name != "sqldb/SELECT" || query.Attributes["db.target"] != "follower" || query.Status.Code != trace.StatusCodeOK {
+		t.Fatalf("unexpected query span %+v", query)
+	}
+}
@@ -0,0 +1,214 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// list of transaction error
+var (
+	ErrSavepointNotSupported = errors.New("sqldb: savepoint is not supported")
+	ErrFollowerUnavailable   = errors.New("sqldb: no follower is available")
+)
+
+// Tx is a transaction in leader database
+// ExecContext, QueryContext, QueryRowContext, GetContext and SelectContext run through the middlewares of the db
+type Tx struct {
+	*sqlx.Tx
+	middlewares []Middleware
+	// savepoints is the number of savepoints created in the transaction, shared with the nested transactions
+	savepoints *int
+}
+
+type txKey struct{}
+
+// WithTx return context which carry the transaction, WithTransaction with the context run in a savepoint of the transaction
+// so the library which need a transaction join the transaction of the caller
+func WithTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext return the transaction of the context created by WithTx
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	return tx, ok && tx != nil
+}
+
+// ExecContext in the transaction
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return intercept(ctx, tx.middlewares, Query{Query: query, Args: args, Exec: true, Tx: true}, func(ctx context.Context, q Query) (sql.Result, error) {
+		return tx.Tx.ExecContext(ctx, q.Query, q.Args...)
+	})
+}
+
+// QueryContext in the transaction
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	_, err := intercept(ctx, tx.middlewares, Query{Query: query, Args: args, Tx: true}, func(ctx context.Context, q Query) (sql.Result, error) {
+		var err error
+		rows, err = tx.Tx.QueryContext(ctx, q.Query, q.Args...)
+		return nil, err
+	})
+	return rows, err
+}
+
+// QueryRowContext in the transaction, Scan return context.Canceled when a middleware reject the query
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	var row *sql.Row
+	_, err := intercept(ctx, tx.middlewares, Query{Query: query, Args: args, Tx: true}, func(ctx context.Context, q Query) (sql.Result, error) {
+		row = tx.Tx.QueryRowContext(ctx, q.Query, q.Args...)
+		return nil, nil
+	})
+	if err != nil || row == nil {
+		return canceledRow(ctx, tx.Tx, query)
+	}
+	return row
+}
+
+// GetContext in the transaction
+func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	_, err := intercept(ctx, tx.middlewares, Query{Query: query, Args: args, Tx: true}, func(ctx context.Context, q Query) (sql.Result, error) {
+		return nil, tx.Tx.GetContext(ctx, dest, q.Query, q.Args...)
+	})
+	return err
+}
+
+// SelectContext in the transaction
+func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	_, err := intercept(ctx, tx.middlewares, Query{Query: query, Args: args, Tx: true}, func(ctx context.Context, q Query) (sql.Result, error) {
+		return nil, tx.Tx.SelectContext(ctx, dest, q.Query, q.Args...)
+	})
+	return err
+}
+
+// Nested run the function in a savepoint of the transaction
+// the savepoint is released when the function return nil, and rolled back to when the function return error or panic
+// so only the queries of the function are rolled back and the transaction can continue
+// the panic is re-thrown after the rollback, and Commit or Rollback must not be called in the function
+// sqlserver use SAVE TRANSACTION, and its savepoint is not released as it is kept until the transaction end
+func (tx *Tx) Nested(ctx context.Context, fn func(tx *Tx) error) error {
+	if driver := tx.DriverName(); driver == DriverClickHouse {
+		return fmt.Errorf("%w: %s", ErrSavepointNotSupported, driver)
+	}
+	if tx.savepoints == nil {
+		tx.savepoints = new(int)
+	}
+	*tx.savepoints++
+	savepoint := fmt.Sprintf("sqldb_savepoint_%d", *tx.savepoints)
+	save, rollback, release := "SAVEPOINT ", "ROLLBACK TO SAVEPOINT ", "RELEASE SAVEPOINT "
+	if tx.DriverName() == DriverSQLServer {
+		save, rollback, release = "SAVE TRANSACTION ", "ROLLBACK TRANSACTION ", ""
+	}
+	if _, err := tx.Tx.ExecContext(ctx, save+savepoint); err != nil {
+		return fmt.Errorf("sqldb: failed to create savepoint: %w", err)
+	}
+	nested := &Tx{Tx: tx.Tx, middlewares: tx.middlewares, savepoints: tx.savepoints}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Tx.ExecContext(ctx, rollback+savepoint)
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		if _, rbErr := tx.Tx.ExecContext(ctx, rollback+savepoint); rbErr != nil {
+			return fmt.Errorf("sqldb: failed to rollback to savepoint: %v: %w", rbErr, err)
+		}
+		return err
+	}
+	if release == "" {
+		return nil
+	}
+	if _, err := tx.Tx.ExecContext(ctx, release+savepoint); err != nil {
+		return fmt.Errorf("sqldb: failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// WithTransaction run the function in a transaction in leader database
+// the transaction is committed when the function return nil, and rolled back when the function return error or panic
+// the panic is re-thrown after the rollback
+// when the context carry a transaction from WithTx, the function run in a savepoint of the transaction, see Tx.Nested
+// ErrCircuitOpen is returned without starting the transaction when the circuit of the leader is open
+func (db *DB) WithTransaction(ctx context.Context, fn func(tx *Tx) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.Nested(ctx, fn)
+	}
+	leader := db.Leader()
+	done, err := db.allow(leader)
+	if err != nil {
+		return err
+	}
+	sqlxTx, err := leader.BeginTxx(ctx, nil)
+	done(err)
+	db.observeLeader(err)
+	if err != nil {
+		return fmt.Errorf("sqldb: failed to begin transaction: %w", err)
+	}
+	tx := &Tx{Tx: sqlxTx, middlewares: db.middlewares}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		db.observeLeader(err)
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("sqldb: failed to rollback transaction: %v: %w", rbErr, err)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		db.observeLeader(err)
+		return fmt.Errorf("sqldb: failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ReadTx run the function in a read only transaction of a follower, so all queries of the function read the same snapshot
+// for example the queries of a report, without adding load to the leader
+// postgres and mysql use repeatable read isolation, and sqlite transaction is always serializable
+// the follower is picked by the load balancer, ErrFollowerUnavailable is returned instead of using the leader when no follower is available
+// the leader is only used when it is the follower of the database, for example no replica is configured, or the context is from ForceLeader
+// the transaction is rolled back after the function return, as it has nothing to commit
+func (db *DB) ReadTx(ctx context.Context, fn func(tx *Tx) error) error {
+	if db.driver == DriverClickHouse {
+		return fmt.Errorf("%w: transaction of %s", ErrDriverNotSupported, db.driver)
+	}
+	if db.driver == DriverSQLServer {
+		// the sqlserver driver reject the read only transaction
+		return fmt.Errorf("%w: read only transaction of %s", ErrDriverNotSupported, db.driver)
+	}
+	conn := db.Leader()
+	if !isLeaderForced(ctx) {
+		f := db.pickFollower()
+		if f == nil {
+			return ErrFollowerUnavailable
+		}
+		conn = db.followerDB(f)
+	}
+	done, err := db.allow(conn)
+	if err != nil {
+		return err
+	}
+	opts := &sql.TxOptions{ReadOnly: true}
+	if db.driver != DriverSQLite {
+		opts.Isolation = sql.LevelRepeatableRead
+	}
+	sqlxTx, err := conn.BeginTxx(ctx, opts)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("sqldb: failed to begin read only transaction: %w", err)
+	}
+	tx := &Tx{Tx: sqlxTx, middlewares: db.middlewares}
+	defer tx.Rollback()
+	return fn(tx)
+}
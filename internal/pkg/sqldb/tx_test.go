@@ -0,0 +1,259 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockDB(t *testing.T) (*DB, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	t.Helper()
+	leaderdb, leaderMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	followerdb, followerMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Wrap(context.Background(), sqlx.NewDb(leaderdb, DriverPostgres), sqlx.NewDb(followerdb, DriverPostgres))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, leaderMock, followerMock
+}
+
+func TestWithTransaction(t *testing.T) {
+	errFn := errors.New("fn error")
+
+	cases := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		fn      func(tx *Tx) error
+		err     error
+		isPanic bool
+	}{
+		{
+			name: "commit",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(tx *Tx) error {
+				_, err := tx.Exec("INSERT INTO users(id) VALUES(1)")
+				return err
+			},
+		},
+		{
+			name: "rollback on error",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(tx *Tx) error {
+				return errFn
+			},
+			err: errFn,
+		},
+		{
+			name: "rollback on panic",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(tx *Tx) error {
+				panic("fn panic")
+			},
+			isPanic: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db, leaderMock, followerMock := newMockDB(t)
+			c.expect(leaderMock)
+
+			func() {
+				defer func() {
+					p := recover()
+					if c.isPanic != (p != nil) {
+						t.Errorf("expecting panic %v but got %v", c.isPanic, p)
+					}
+				}()
+				err := db.WithTransaction(context.Background(), c.fn)
+				if !errors.Is(err, c.err) {
+					t.Errorf("expecting error %v but got %v", c.err, err)
+				}
+			}()
+
+			if err := leaderMock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+			// transaction should never go to follower
+			if err := followerMock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWithTransactionCommitError(t *testing.T) {
+	db, leaderMock, _ := newMockDB(t)
+	errCommit := errors.New("commit error")
+	leaderMock.ExpectBegin()
+	leaderMock.ExpectCommit().WillReturnError(errCommit)
+
+	err := db.WithTransaction(context.Background(), func(tx *Tx) error {
+		return nil
+	})
+	if !errors.Is(err, errCommit) {
+		t.Errorf("expecting error %v but got %v", errCommit, err)
+	}
+}
+
+func TestNested(t *testing.T) {
+	errFn := errors.New("fn error")
+	db, leaderMock, _ := newMockDB(t)
+	leaderMock.ExpectBegin()
+	leaderMock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(1, 1))
+	leaderMock.ExpectExec("SAVEPOINT sqldb_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	leaderMock.ExpectExec("INSERT INTO audits").WillReturnResult(sqlmock.NewResult(1, 1))
+	leaderMock.ExpectExec("ROLLBACK TO SAVEPOINT sqldb_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	leaderMock.ExpectExec("SAVEPOINT sqldb_savepoint_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	leaderMock.ExpectExec("SAVEPOINT sqldb_savepoint_3").WillReturnResult(sqlmock.NewResult(0, 0))
+	leaderMock.ExpectExec("RELEASE SAVEPOINT sqldb_savepoint_3").WillReturnResult(sqlmock.NewResult(0, 0))
+	leaderMock.ExpectExec("RELEASE SAVEPOINT sqldb_savepoint_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	leaderMock.ExpectCommit()
+
+	ctx := context.Background()
+	err := db.WithTransaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO orders(id) VALUES(1)"); err != nil {
+			return err
+		}
+		// the failed audit is rolled back without failing the order
+		err := tx.Nested(ctx, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO audits(id) VALUES(1)"); err != nil {
+				return err
+			}
+			return errFn
+		})
+		if !errors.Is(err, errFn) {
+			t.Errorf("expecting error %v but got %v", errFn, err)
+		}
+		// the library which call WithTransaction with the context of the transaction join the transaction
+		return db.WithTransaction(WithTx(ctx, tx), func(tx *Tx) error {
+			return tx.Nested(ctx, func(tx *Tx) error {
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNestedSQLite(t *testing.T) {
+	ctx := context.Background()
+	conn, err := Connect(ctx, DriverSQLite, SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.WithTransaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (1)"); err != nil {
+			return err
+		}
+		// duplicate id is rolled back to the savepoint, and the transaction continue
+		err := tx.Nested(ctx, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (2)"); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (1)")
+			return err
+		})
+		if err == nil {
+			t.Error("expecting duplicate id error")
+		}
+		func() {
+			defer func() {
+				if p := recover(); p == nil {
+					t.Error("expecting panic is re-thrown")
+				}
+			}()
+			tx.Nested(ctx, func(tx *Tx) error {
+				tx.ExecContext(ctx, "INSERT INTO users VALUES (4)")
+				panic("nested panic")
+			})
+		}()
+		_, err = tx.ExecContext(ctx, "INSERT INTO users VALUES (3)")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	if err := db.SelectContext(ctx, &ids, "SELECT id FROM users ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Fatalf("expecting ids [1 3] but got %v", ids)
+	}
+}
+
+func TestReadTx(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	followerMock.ExpectBegin()
+	followerMock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	followerMock.ExpectQuery("SELECT SUM").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+	followerMock.ExpectRollback()
+
+	ctx := context.Background()
+	var count, sum int
+	err := db.ReadTx(ctx, func(tx *Tx) error {
+		if err := tx.GetContext(ctx, &count, "SELECT COUNT(*) FROM orders"); err != nil {
+			return err
+		}
+		return tx.GetContext(ctx, &sum, "SELECT SUM(amount) FROM orders")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 || sum != 100 {
+		t.Fatalf("expecting count 10 and sum 100 but got %d and %d", count, sum)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	// the report should never go to leader
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// the leader is not used when the follower is unavailable
+	atomic.StoreInt32(&db.followers[0].unhealthy, 1)
+	err = db.ReadTx(ctx, func(tx *Tx) error {
+		return nil
+	})
+	if !errors.Is(err, ErrFollowerUnavailable) {
+		t.Fatalf("expecting error %v but got %v", ErrFollowerUnavailable, err)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
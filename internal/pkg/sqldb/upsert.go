@@ -0,0 +1,173 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// list of upsert error
+var (
+	ErrUpsertKeysEmpty         = errors.New("sqldb: upsert keys is empty")
+	ErrUpsertValuesEmpty       = errors.New("sqldb: upsert values is empty")
+	ErrUpsertColumnNotInValues = errors.New("sqldb: upsert column is not in values")
+	ErrUpsertReturningEmpty    = errors.New("sqldb: upsert returning columns is empty")
+	ErrInvalidIdentifier       = errors.New("sqldb: invalid identifier")
+	ErrReturningNotSupported   = errors.New("sqldb: returning is not supported by the driver")
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ValidateIdentifier return ErrInvalidIdentifier when the name is not a valid table or column name
+// the name can be qualified with the schema, for example public.users
+func ValidateIdentifier(name string) error {
+	if !identifier.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+	return nil
+}
+
+// UpsertOptions of upsert query
+type UpsertOptions struct {
+	// Update is the list of columns updated when the keys already exist, default to all columns except keys
+	// the existing row is not changed when all columns are keys
+	Update []string
+	// Returning is the list of columns returned by UpsertReturning, only supported in postgres
+	Returning []string
+}
+
+// BuildUpsert return the upsert query and its arguments for the driver
+// postgres and sqlite use INSERT ... ON CONFLICT (keys) DO UPDATE, and mysql use INSERT ... ON DUPLICATE KEY UPDATE
+// mysql check conflict on all unique indexes of the table, so the keys is only used to pick the updated columns
+// columns are sorted by name, so the same values always build the same query
+func BuildUpsert(driver, table string, keys []string, values map[string]interface{}, options *UpsertOptions) (string, []interface{}, error) {
+	opts := UpsertOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if err := ValidateDriver(driver); err != nil {
+		return "", nil, err
+	}
+	driver = DriverName(driver)
+	if driver == DriverClickHouse {
+		// rows of the same key are merged in background by ReplacingMergeTree, so the row is inserted instead
+		return "", nil, fmt.Errorf("%w: upsert of %s", ErrDriverNotSupported, driver)
+	}
+	if driver == DriverSQLServer {
+		// sqlserver has no insert on conflict, and MERGE need the lock hint to be safe from the concurrent upsert
+		return "", nil, fmt.Errorf("%w: upsert of %s", ErrDriverNotSupported, driver)
+	}
+	if len(keys) == 0 {
+		return "", nil, ErrUpsertKeysEmpty
+	}
+	if len(values) == 0 {
+		return "", nil, ErrUpsertValuesEmpty
+	}
+	if len(opts.Returning) > 0 && driver != DriverPostgres {
+		return "", nil, fmt.Errorf("%w: %s", ErrReturningNotSupported, driver)
+	}
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, names := range [][]string{{table}, columns, keys, opts.Update, opts.Returning} {
+		for _, name := range names {
+			if !identifier.MatchString(name) {
+				return "", nil, fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+			}
+		}
+	}
+
+	for _, names := range [][]string{keys, opts.Update} {
+		for _, name := range names {
+			if _, ok := values[name]; !ok {
+				return "", nil, fmt.Errorf("%w: %s", ErrUpsertColumnNotInValues, name)
+			}
+		}
+	}
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	update := opts.Update
+	if update == nil {
+		for _, column := range columns {
+			if !isKey[column] {
+				update = append(update, column)
+			}
+		}
+	}
+
+	var (
+		args         = make([]interface{}, len(columns))
+		placeholders = make([]string, len(columns))
+	)
+	for idx, column := range columns {
+		args[idx] = values[column]
+		placeholders[idx] = "?"
+		if driver == DriverPostgres {
+			placeholders[idx] = fmt.Sprintf("$%d", idx+1)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	switch driver {
+	case DriverPostgres, DriverSQLite:
+		fmt.Fprintf(&b, " ON CONFLICT (%s)", strings.Join(keys, ", "))
+		if len(update) == 0 {
+			b.WriteString(" DO NOTHING")
+		} else {
+			sets := make([]string, len(update))
+			for idx, column := range update {
+				sets[idx] = fmt.Sprintf("%s = EXCLUDED.%s", column, column)
+			}
+			fmt.Fprintf(&b, " DO UPDATE SET %s", strings.Join(sets, ", "))
+		}
+		if len(opts.Returning) > 0 {
+			fmt.Fprintf(&b, " RETURNING %s", strings.Join(opts.Returning, ", "))
+		}
+
+	case DriverMySQL:
+		if len(update) == 0 {
+			// mysql has no DO NOTHING, updating the key to itself keep the row unchanged
+			update = keys[:1]
+		}
+		sets := make([]string, len(update))
+		for idx, column := range update {
+			sets[idx] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+		}
+		fmt.Fprintf(&b, " ON DUPLICATE KEY UPDATE %s", strings.Join(sets, ", "))
+	}
+	return b.String(), args, nil
+}
+
+// Upsert insert the values to the table, or update the row when the keys already exist
+// values is keyed by the column name, see BuildUpsert for the query
+func (db *DB) Upsert(ctx context.Context, table string, keys []string, values map[string]interface{}, options *UpsertOptions) (sql.Result, error) {
+	query, args, err := BuildUpsert(db.driver, table, keys, values, options)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, query, args...)
+}
+
+// UpsertReturning upsert the values and scan the Returning columns of the row to dest
+// sql.ErrNoRows is returned when the row already exist and all columns are keys, as the row is not updated
+// ErrReturningNotSupported is returned when the driver is not postgres
+func (db *DB) UpsertReturning(ctx context.Context, dest interface{}, table string, keys []string, values map[string]interface{}, options *UpsertOptions) error {
+	if options == nil || len(options.Returning) == 0 {
+		return ErrUpsertReturningEmpty
+	}
+	query, args, err := BuildUpsert(db.driver, table, keys, values, options)
+	if err != nil {
+		return err
+	}
+	return db.GetContext(ctx, dest, query, args...)
+}
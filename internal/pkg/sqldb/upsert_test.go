@@ -0,0 +1,138 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBuildUpsert(t *testing.T) {
+	values := map[string]interface{}{
+		"id":         1,
+		"name":       "a",
+		"created_at": "now",
+	}
+
+	cases := []struct {
+		name   string
+		driver string
+		keys   []string
+		values map[string]interface{}
+		opts   *UpsertOptions
+		query  string
+		err    error
+	}{
+		{
+			name:   "postgres",
+			driver: DriverPostgres,
+			keys:   []string{"id"},
+			values: values,
+			query:  "INSERT INTO users (created_at, id, name) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET created_at = EXCLUDED.created_at, name = EXCLUDED.name",
+		},
+		{
+			name:   "postgres update and returning",
+			driver: DriverPostgres,
+			keys:   []string{"id"},
+			values: values,
+			opts:   &UpsertOptions{Update: []string{"name"}, Returning: []string{"id", "created_at"}},
+			query:  "INSERT INTO users (created_at, id, name) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name RETURNING id, created_at",
+		},
+		{
+			name:   "postgres all keys",
+			driver: DriverPostgres,
+			keys:   []string{"id"},
+			values: map[string]interface{}{"id": 1},
+			query:  "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING",
+		},
+		{
+			name:   "mysql",
+			driver: DriverMySQL,
+			keys:   []string{"id"},
+			values: values,
+			opts:   &UpsertOptions{Update: []string{"name"}},
+			query:  "INSERT INTO users (created_at, id, name) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)",
+		},
+		{
+			name:   "mysql all keys",
+			driver: DriverMySQL,
+			keys:   []string{"id"},
+			values: map[string]interface{}{"id": 1},
+			query:  "INSERT INTO users (id) VALUES (?) ON DUPLICATE KEY UPDATE id = VALUES(id)",
+		},
+		{
+			name:   "mysql returning",
+			driver: DriverMySQL,
+			keys:   []string{"id"},
+			values: values,
+			opts:   &UpsertOptions{Returning: []string{"id"}},
+			err:    ErrReturningNotSupported,
+		},
+		{
+			name:   "empty keys",
+			driver: DriverPostgres,
+			values: values,
+			err:    ErrUpsertKeysEmpty,
+		},
+		{
+			name:   "key not in values",
+			driver: DriverPostgres,
+			keys:   []string{"email"},
+			values: values,
+			err:    ErrUpsertColumnNotInValues,
+		},
+		{
+			name:   "invalid column",
+			driver: DriverPostgres,
+			keys:   []string{"id"},
+			values: map[string]interface{}{"id": 1, "name; DROP TABLE users": "a"},
+			err:    ErrInvalidIdentifier,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query, args, err := BuildUpsert(c.driver, "users", c.keys, c.values, c.opts)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+			if c.err != nil {
+				return
+			}
+			if query != c.query {
+				t.Fatalf("expect query\n%s\nbut got\n%s", c.query, query)
+			}
+			if len(args) != len(c.values) {
+				t.Fatalf("expect %d args but got %d", len(c.values), len(args))
+			}
+		})
+	}
+}
+
+func TestUpsertReturning(t *testing.T) {
+	db, leaderMock, followerMock := newMockDB(t)
+	defer db.Close()
+
+	leaderMock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name RETURNING id")).
+		WithArgs(1, "a").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	var id int64
+	err := db.UpsertReturning(context.Background(), &id, "users", []string{"id"}, map[string]interface{}{"id": 1, "name": "a"}, &UpsertOptions{
+		Returning: []string{"id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatalf("expect id 1 but got %d", id)
+	}
+	if err := leaderMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := followerMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package supportbundle
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+)
+
+// HandlerOptions of the bundle handler
+type HandlerOptions struct {
+	// Storage to upload the bundle, the bundle is returned in the response when nil
+	Storage *objectstorage.Storage
+	// Prefix of the bundle key in the storage, for example support-bundle/
+	Prefix string
+}
+
+// UploadResponse of the handler when the bundle is uploaded
+type UploadResponse struct {
+	Key      string    `json:"key"`
+	Manifest *Manifest `json:"manifest"`
+}
+
+// Handler create the bundle on POST request
+// the bundle is uploaded to the storage and the key is returned, or returned as attachment when there is no storage
+func Handler(b *Bundle, options *HandlerOptions) http.Handler {
+	opts := HandlerOptions{}
+	if options != nil {
+		opts = *options
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if opts.Storage == nil {
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", "attachment; filename="+Name(b.opts.Now()))
+			// the response is already started, so the error can only be seen as broken archive
+			b.Write(r.Context(), w)
+			return
+		}
+
+		key, manifest, err := b.Upload(r.Context(), opts.Storage, opts.Prefix)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(UploadResponse{Key: key, Manifest: manifest})
+	})
+}
@@ -0,0 +1,57 @@
+package supportbundle
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry of the log
+type Entry struct {
+	Time  time.Time   `json:"time"`
+	Value interface{} `json:"value"`
+}
+
+// Log keep the recent entries in memory, for example the recent slow queries or health checks
+// the oldest entry is dropped when the log is full
+type Log struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewLog return log which keep the last size entries, size less than 1 is set to 1
+func NewLog(size int) *Log {
+	if size < 1 {
+		size = 1
+	}
+	return &Log{
+		now:     time.Now,
+		entries: make([]Entry, size),
+	}
+}
+
+// Append value to the log
+func (l *Log) Append(value interface{}) {
+	l.mu.Lock()
+	l.entries[l.next] = Entry{Time: l.now(), Value: value}
+	l.next++
+	if l.next == len(l.entries) {
+		l.next = 0
+		l.full = true
+	}
+	l.mu.Unlock()
+}
+
+// Entries return the entries from the oldest to the newest
+func (l *Log) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]Entry(nil), l.entries[:l.next]...)
+	}
+	entries := make([]Entry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	return append(entries, l.entries[:l.next]...)
+}
@@ -0,0 +1,207 @@
+// Package supportbundle gather diagnostic of the running program into a single tar.gz file
+// for example to attach the state of the program to an incident ticket
+//
+//	b := supportbundle.New(nil)
+//	b.AddJSON("resources/stats.json", func(ctx context.Context) (interface{}, error) { return resources.Stats(), nil })
+//	key, err := b.Upload(ctx, storage, "support-bundle/")
+//
+// every section is written as a file in the bundle, and failed section is listed in manifest.json instead of failing the bundle
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/buildinfo"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+)
+
+// list of error
+var (
+	ErrSectionNameEmpty = errors.New("supportbundle: section name is empty")
+	ErrDuplicateSection = errors.New("supportbundle: duplicate section")
+)
+
+// ManifestName is the name of the manifest file in the bundle
+const ManifestName = "manifest.json"
+
+// Options of bundle
+type Options struct {
+	// Now is used to get the time of the bundle, default to time.Now
+	Now func() time.Time
+	// NoProfiles skip goroutine and heap profiles
+	NoProfiles bool
+}
+
+// Section write the content of a file in the bundle
+type Section func(ctx context.Context, w io.Writer) error
+
+type section struct {
+	name string
+	fn   Section
+}
+
+// Bundle of diagnostic sections
+type Bundle struct {
+	opts     Options
+	mu       sync.Mutex
+	sections []section
+}
+
+// Manifest of the bundle
+type Manifest struct {
+	CreatedAt time.Time      `json:"created_at"`
+	BuildInfo buildinfo.Info `json:"build_info"`
+	// Sections is the list of section names in the bundle
+	Sections []string `json:"sections"`
+	// Errors of the failed sections keyed by section name, the content of failed section is not in the bundle
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// New bundle, goroutine and heap profiles are added unless NoProfiles is set
+func New(options *Options) *Bundle {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	b := Bundle{opts: opts}
+	if !opts.NoProfiles {
+		// goroutine profile in text with the full stack, and heap profile in pprof format
+		b.Add("profiles/goroutine.txt", Profile("goroutine", 2))
+		b.Add("profiles/heap.pb.gz", Profile("heap", 0))
+	}
+	return &b
+}
+
+// Add section to the bundle, the name is the path of the file in the bundle
+func (b *Bundle) Add(name string, fn Section) error {
+	if name == "" {
+		return ErrSectionNameEmpty
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.sections {
+		if s.name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicateSection, name)
+		}
+	}
+	b.sections = append(b.sections, section{name: name, fn: fn})
+	return nil
+}
+
+// AddJSON add section which write the value returned by fn as indented json
+func (b *Bundle) AddJSON(name string, fn func(ctx context.Context) (interface{}, error)) error {
+	return b.Add(name, func(ctx context.Context, w io.Writer) error {
+		v, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	})
+}
+
+// Profile return section of the runtime profile, for example goroutine, heap or mutex
+// debug is the debug parameter of pprof.Profile.WriteTo, 0 write the profile in pprof format
+func Profile(name string, debug int) Section {
+	return func(ctx context.Context, w io.Writer) error {
+		p := pprof.Lookup(name)
+		if p == nil {
+			return fmt.Errorf("supportbundle: profile %s not found", name)
+		}
+		return p.WriteTo(w, debug)
+	}
+}
+
+// Name return the file name of the bundle created at the time, for example support-bundle-20200102T030405Z.tar.gz
+func Name(t time.Time) string {
+	return fmt.Sprintf("support-bundle-%s.tar.gz", t.UTC().Format("20060102T150405Z"))
+}
+
+// Write the bundle as tar.gz to the writer, and return the manifest of the bundle
+// the sections are run in the order they are added
+func (b *Bundle) Write(ctx context.Context, w io.Writer) (*Manifest, error) {
+	b.mu.Lock()
+	sections := append([]section(nil), b.sections...)
+	b.mu.Unlock()
+
+	now := b.opts.Now()
+	manifest := Manifest{
+		CreatedAt: now.UTC(),
+		BuildInfo: buildinfo.Get(),
+		Errors:    make(map[string]string),
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, s := range sections {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// the section is buffered, so failed section doesn't leave partial file in the bundle
+		var buf bytes.Buffer
+		if err := s.fn(ctx, &buf); err != nil {
+			manifest.Errors[s.name] = err.Error()
+			continue
+		}
+		if err := writeFile(tw, s.name, buf.Bytes(), now); err != nil {
+			return nil, err
+		}
+		manifest.Sections = append(manifest.Sections, s.name)
+	}
+
+	out, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tw, ManifestName, out, now); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func writeFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	header := tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(&header); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// Upload the bundle to the object storage with the prefix, and return the key of the bundle
+func (b *Bundle) Upload(ctx context.Context, storage *objectstorage.Storage, prefix string) (string, *Manifest, error) {
+	var buf bytes.Buffer
+	manifest, err := b.Write(ctx, &buf)
+	if err != nil {
+		return "", nil, err
+	}
+	key := prefix + Name(manifest.CreatedAt)
+	if _, err := storage.UploadByte(ctx, buf.Bytes(), key, &objectstorage.WriteOptions{ContentType: "application/gzip"}); err != nil {
+		return "", nil, fmt.Errorf("supportbundle: failed to upload bundle: %w", err)
+	}
+	return key, manifest, nil
+}
@@ -0,0 +1,150 @@
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+)
+
+// readBundle return the files in the bundle keyed by name
+func readBundle(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = content
+	}
+}
+
+func newTestBundle(t *testing.T) *Bundle {
+	t.Helper()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := New(&Options{Now: func() time.Time { return now }})
+	if err := b.AddJSON("config.json", func(ctx context.Context) (interface{}, error) {
+		return map[string]string{"password": "******"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add("failed.txt", func(ctx context.Context, w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errors.New("section failed")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWrite(t *testing.T) {
+	b := newTestBundle(t)
+	if err := b.Add("config.json", nil); !errors.Is(err, ErrDuplicateSection) {
+		t.Fatalf("expecting error %v but got %v", ErrDuplicateSection, err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := b.Write(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, &buf)
+	for _, name := range []string{"profiles/goroutine.txt", "profiles/heap.pb.gz", "config.json", ManifestName} {
+		if len(files[name]) == 0 {
+			t.Fatalf("expecting %s in the bundle", name)
+		}
+	}
+	if _, ok := files["failed.txt"]; ok {
+		t.Fatal("expecting failed section is not in the bundle")
+	}
+	if !bytes.Contains(files["profiles/goroutine.txt"], []byte("goroutine")) {
+		t.Fatal("expecting goroutine stacks in goroutine profile")
+	}
+
+	var written Manifest
+	if err := json.Unmarshal(files[ManifestName], &written); err != nil {
+		t.Fatal(err)
+	}
+	if written.Errors["failed.txt"] != "section failed" || manifest.Errors["failed.txt"] != "section failed" {
+		t.Fatalf("expecting error of failed section but got %v", written.Errors)
+	}
+	if len(written.Sections) != 3 {
+		t.Fatalf("expecting 3 sections but got %v", written.Sections)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	storage := objectstorage.New(memory.New("bundle"))
+	handler := Handler(newTestBundle(t), &HandlerOptions{Storage: storage, Prefix: "support-bundle/"})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expecting status %d but got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expecting status %d but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp UploadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Key != "support-bundle/support-bundle-20200102T030405Z.tar.gz" {
+		t.Fatalf("expecting key of the bundle time but got %s", resp.Key)
+	}
+	content, err := storage.DownloadByte(context.Background(), resp.Key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := readBundle(t, bytes.NewReader(content)); len(files["config.json"]) == 0 {
+		t.Fatal("expecting config.json in the uploaded bundle")
+	}
+
+	// without storage the bundle is returned in the response
+	rec = httptest.NewRecorder()
+	Handler(newTestBundle(t), nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if files := readBundle(t, rec.Body); len(files[ManifestName]) == 0 {
+		t.Fatal("expecting manifest in the response bundle")
+	}
+}
+
+func TestLog(t *testing.T) {
+	l := NewLog(2)
+	if entries := l.Entries(); len(entries) != 0 {
+		t.Fatalf("expecting empty log but got %v", entries)
+	}
+	l.Append(1)
+	l.Append(2)
+	l.Append(3)
+	entries := l.Entries()
+	if len(entries) != 2 || entries[0].Value != 2 || entries[1].Value != 3 {
+		t.Fatalf("expecting the last 2 entries but got %v", entries)
+	}
+}
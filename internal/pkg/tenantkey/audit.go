@@ -0,0 +1,47 @@
+package tenantkey
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// AuditEntry of the creation or the destruction of a tenant key
+type AuditEntry struct {
+	Tenant    string    `json:"tenant"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// audit record the entry in the transaction of the key change, so the change is never unaudited
+func (k *Keyring) audit(ctx context.Context, tx *sqldb.Tx, entry AuditEntry) error {
+	query := tx.Rebind(fmt.Sprintf("INSERT INTO %s (tenant, action, actor, reason, created_at) VALUES (?, ?, ?, ?, ?)", k.opts.AuditTable))
+	if _, err := tx.ExecContext(ctx, query, entry.Tenant, entry.Action, entry.Actor, entry.Reason, entry.CreatedAt); err != nil {
+		return fmt.Errorf("tenantkey: failed to audit %s of %s: %w", entry.Action, entry.Tenant, err)
+	}
+	return nil
+}
+
+// AuditLog return the audit entries of the tenant, the oldest first
+func (k *Keyring) AuditLog(ctx context.Context, tenant string) ([]AuditEntry, error) {
+	query := k.db.Rebind(fmt.Sprintf("SELECT tenant, action, actor, reason, created_at FROM %s WHERE tenant = ? ORDER BY created_at", k.opts.AuditTable))
+	rows, err := k.db.QueryContext(sqldb.ForceLeader(ctx), query, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("tenantkey: failed to load audit log of %s: %w", tenant, err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		entry := AuditEntry{}
+		if err := rows.Scan(&entry.Tenant, &entry.Action, &entry.Actor, &entry.Reason, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
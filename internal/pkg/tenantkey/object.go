@@ -0,0 +1,41 @@
+package tenantkey
+
+import (
+	"context"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+)
+
+// MetadataTenant is the metadata of the encrypted object which store the tenant of the object
+const MetadataTenant = "tenant"
+
+// UploadByte encrypt the content with the key of the tenant and upload it to the storage
+// the content type of the write options is kept, so the object can be served after it is decrypted
+func (k *Keyring) UploadByte(ctx context.Context, storage *objectstorage.Storage, tenant string, content []byte, key string, writeOptions *objectstorage.WriteOptions) (string, error) {
+	ciphertext, err := k.Encrypt(ctx, tenant, content)
+	if err != nil {
+		return "", err
+	}
+	opts := objectstorage.WriteOptions{}
+	if writeOptions != nil {
+		opts = *writeOptions
+	}
+	// the md5 of the plaintext doesn't match the encrypted object
+	opts.ContentMD5 = nil
+	metadata := make(map[string]string, len(opts.Metadata)+1)
+	for name, value := range opts.Metadata {
+		metadata[name] = value
+	}
+	metadata[MetadataTenant] = tenant
+	opts.Metadata = metadata
+	return storage.UploadByte(ctx, ciphertext, key, &opts)
+}
+
+// DownloadByte download the object of the tenant and decrypt it, ErrKeyDestroyed is returned when the key of the tenant is destroyed
+func (k *Keyring) DownloadByte(ctx context.Context, storage *objectstorage.Storage, tenant, key string) ([]byte, error) {
+	ciphertext, err := storage.DownloadByte(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return k.Decrypt(ctx, tenant, ciphertext)
+}
@@ -0,0 +1,416 @@
+// Package tenantkey encrypt the data of every tenant with its own data key, so the data of a tenant can be crypto-shredded
+// the data keys are wrapped with the master key and stored in sqldb, the unwrapped keys are cached in memory with ttl
+// destroying the key of a tenant make all of its encrypted objects and columns unreadable, for example for gdpr deletion
+//
+//	keyring, err := tenantkey.New(db, &tenantkey.Options{MasterKey: masterKey})
+//	err = keyring.Init(ctx)
+//	ciphertext, err := keyring.Encrypt(ctx, "tenant-a", []byte("john@example.com"))
+//	plaintext, err := keyring.Decrypt(ctx, "tenant-a", ciphertext)
+//	err = keyring.Destroy(ctx, "tenant-a", "admin@example.com", "gdpr request #42")
+package tenantkey
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// default value of options
+const (
+	DefaultTable      = "tenant_keys"
+	DefaultAuditTable = "tenant_key_audits"
+	DefaultCacheTTL   = time.Minute * 5
+)
+
+// list of audit action
+const (
+	ActionCreate  = "create"
+	ActionDestroy = "destroy"
+)
+
+// version of the ciphertext format, the version is the first byte of the ciphertext
+const version byte = 1
+
+// list of error
+var (
+	ErrMasterKeyInvalid   = errors.New("tenantkey: master key must be 32 bytes")
+	ErrTenantEmpty        = errors.New("tenantkey: tenant is empty")
+	ErrKeyNotFound        = errors.New("tenantkey: key of the tenant is not found")
+	ErrKeyDestroyed       = errors.New("tenantkey: key of the tenant is destroyed")
+	ErrCiphertextInvalid  = errors.New("tenantkey: invalid ciphertext")
+	ErrWrappedKeyInvalid  = errors.New("tenantkey: failed to unwrap the key, the master key is different")
+	ErrDestroyActorEmpty  = errors.New("tenantkey: actor of the destroy is empty")
+	ErrDestroyReasonEmpty = errors.New("tenantkey: reason of the destroy is empty")
+)
+
+// Options of keyring
+type Options struct {
+	// MasterKey wrap the data keys of the tenants, it must be 32 bytes and is never stored in the database
+	MasterKey []byte
+	// Table of the wrapped keys, default to tenant_keys
+	Table string
+	// AuditTable of the creation and the destruction of the keys, default to tenant_key_audits
+	AuditTable string
+	// CacheTTL of the unwrapped keys, default to 5 minutes
+	// other instances can decrypt the data of a destroyed tenant until their cache is expired
+	CacheTTL time.Duration
+	// Now is used to get the current time, default to time.Now
+	Now func() time.Time
+	// Rand is the source of the data keys and the nonces, default to crypto/rand
+	Rand io.Reader
+}
+
+// Key of a tenant, the data key itself is never exposed
+type Key struct {
+	Tenant      string     `json:"tenant"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DestroyedAt *time.Time `json:"destroyed_at,omitempty"`
+}
+
+// Destroyed return true when the key is destroyed
+func (k Key) Destroyed() bool {
+	return k.DestroyedAt != nil
+}
+
+// cached data key of a tenant, the key is nil when it is destroyed
+type cached struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+// Keyring of the tenant data keys
+type Keyring struct {
+	db   *sqldb.DB
+	opts Options
+	kek  []byte
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+// New keyring of the tenant data keys
+func New(db *sqldb.DB, options *Options) (*Keyring, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if len(opts.MasterKey) != chacha20poly1305.KeySize {
+		return nil, ErrMasterKeyInvalid
+	}
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.AuditTable == "" {
+		opts.AuditTable = DefaultAuditTable
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Reader
+	}
+
+	k := Keyring{
+		db:    db,
+		opts:  opts,
+		kek:   append([]byte(nil), opts.MasterKey...),
+		cache: make(map[string]cached),
+	}
+	return &k, nil
+}
+
+// Init create the key and the audit table when they don't exist
+func (k *Keyring) Init(ctx context.Context) error {
+	queries := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	tenant VARCHAR(255) PRIMARY KEY,
+	wrapped_key TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	destroyed_at TIMESTAMP NULL
+)`, k.opts.Table),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	tenant VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	actor VARCHAR(255) NOT NULL,
+	reason TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`, k.opts.AuditTable),
+	}
+	for _, query := range queries {
+		if _, err := k.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("tenantkey: failed to create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Encrypt the plaintext with the data key of the tenant, the key is created on the first encryption of the tenant
+// the tenant is authenticated with the ciphertext, so the ciphertext cannot be decrypted as the data of another tenant
+func (k *Keyring) Encrypt(ctx context.Context, tenant string, plaintext []byte) ([]byte, error) {
+	key, err := k.dataKey(ctx, tenant, true)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = version
+	if _, err := io.ReadFull(k.opts.Rand, out[1:]); err != nil {
+		return nil, fmt.Errorf("tenantkey: failed to generate nonce: %w", err)
+	}
+	return aead.Seal(out, out[1:], plaintext, []byte(tenant)), nil
+}
+
+// Decrypt the ciphertext of the tenant, ErrKeyDestroyed is returned when the key of the tenant is destroyed
+func (k *Keyring) Decrypt(ctx context.Context, tenant string, ciphertext []byte) ([]byte, error) {
+	key, err := k.dataKey(ctx, tenant, false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < 1+aead.NonceSize()+aead.Overhead() || ciphertext[0] != version {
+		return nil, ErrCiphertextInvalid
+	}
+	nonce := ciphertext[1 : 1+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[1+aead.NonceSize():], []byte(tenant))
+	if err != nil {
+		return nil, ErrCiphertextInvalid
+	}
+	return plaintext, nil
+}
+
+// EncryptString encrypt the value of a text column, the ciphertext is encoded with base64
+func (k *Keyring) EncryptString(ctx context.Context, tenant, value string) (string, error) {
+	ciphertext, err := k.Encrypt(ctx, tenant, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptString decrypt the value of a text column which is encrypted with EncryptString
+func (k *Keyring) DecryptString(ctx context.Context, tenant, value string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", ErrCiphertextInvalid
+	}
+	plaintext, err := k.Decrypt(ctx, tenant, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Key return the key information of the tenant, ErrKeyNotFound is returned when the tenant never encrypt any data
+func (k *Keyring) Key(ctx context.Context, tenant string) (Key, error) {
+	key, _, err := k.load(ctx, tenant)
+	if errors.Is(err, ErrKeyDestroyed) {
+		return key, nil
+	}
+	return key, err
+}
+
+// Destroy the data key of the tenant, all data encrypted with the key cannot be decrypted anymore
+// the key is kept as a tombstone without the wrapped key, so new data of the tenant cannot be encrypted with a new key by mistake
+// the actor and the reason are recorded in the audit table in the same transaction
+func (k *Keyring) Destroy(ctx context.Context, tenant, actor, reason string) error {
+	if tenant == "" {
+		return ErrTenantEmpty
+	}
+	if actor == "" {
+		return ErrDestroyActorEmpty
+	}
+	if reason == "" {
+		return ErrDestroyReasonEmpty
+	}
+
+	now := k.opts.Now()
+	err := k.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		query := tx.Rebind(fmt.Sprintf("UPDATE %s SET wrapped_key = '', destroyed_at = ? WHERE tenant = ? AND destroyed_at IS NULL", k.opts.Table))
+		result, err := tx.ExecContext(ctx, query, now, tenant)
+		if err != nil {
+			return fmt.Errorf("tenantkey: failed to destroy the key of %s: %w", tenant, err)
+		}
+		destroyed, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if destroyed == 0 {
+			// the tenant without key is destroyed with an empty tombstone, so no key is created for the tenant afterward
+			query, args, err := sqldb.BuildUpsert(tx.DriverName(), k.opts.Table, []string{"tenant"}, map[string]interface{}{
+				"tenant":       tenant,
+				"wrapped_key":  "",
+				"created_at":   now,
+				"destroyed_at": now,
+			}, &sqldb.UpsertOptions{Update: []string{}})
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("tenantkey: failed to destroy the key of %s: %w", tenant, err)
+			}
+		}
+		return k.audit(ctx, tx, AuditEntry{Tenant: tenant, Action: ActionDestroy, Actor: actor, Reason: reason, CreatedAt: now})
+	})
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.cache[tenant] = cached{expiresAt: now.Add(k.opts.CacheTTL)}
+	k.mu.Unlock()
+	return nil
+}
+
+// Forget remove the cached data key of the tenant, for example after the key is destroyed by another instance
+func (k *Keyring) Forget(tenant string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.cache, tenant)
+}
+
+// dataKey return the unwrapped data key of the tenant from the cache or the database
+// the key is created when create is true and the tenant has no key yet
+func (k *Keyring) dataKey(ctx context.Context, tenant string, create bool) ([]byte, error) {
+	if tenant == "" {
+		return nil, ErrTenantEmpty
+	}
+	now := k.opts.Now()
+	k.mu.Lock()
+	c, ok := k.cache[tenant]
+	k.mu.Unlock()
+	if ok && now.Before(c.expiresAt) {
+		if c.key == nil {
+			return nil, fmt.Errorf("%w: %s", ErrKeyDestroyed, tenant)
+		}
+		return c.key, nil
+	}
+
+	_, key, err := k.load(ctx, tenant)
+	if errors.Is(err, ErrKeyNotFound) && create {
+		key, err = k.create(ctx, tenant)
+	}
+	if err != nil && !errors.Is(err, ErrKeyDestroyed) {
+		return nil, err
+	}
+	// the destroyed key is cached as nil, so the destroyed tenant doesn't query the database on every call
+	k.mu.Lock()
+	k.cache[tenant] = cached{key: key, expiresAt: now.Add(k.opts.CacheTTL)}
+	k.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// load the key of the tenant from the leader and unwrap it
+func (k *Keyring) load(ctx context.Context, tenant string) (Key, []byte, error) {
+	var (
+		info        = Key{Tenant: tenant}
+		wrapped     string
+		destroyedAt sql.NullTime
+	)
+	query := k.db.Rebind(fmt.Sprintf("SELECT wrapped_key, created_at, destroyed_at FROM %s WHERE tenant = ?", k.opts.Table))
+	err := k.db.QueryRowContext(sqldb.ForceLeader(ctx), query, tenant).Scan(&wrapped, &info.CreatedAt, &destroyedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Key{}, nil, fmt.Errorf("%w: %s", ErrKeyNotFound, tenant)
+	}
+	if err != nil {
+		return Key{}, nil, fmt.Errorf("tenantkey: failed to load the key of %s: %w", tenant, err)
+	}
+	if destroyedAt.Valid {
+		info.DestroyedAt = &destroyedAt.Time
+		return info, nil, fmt.Errorf("%w: %s", ErrKeyDestroyed, tenant)
+	}
+	key, err := k.unwrap(tenant, wrapped)
+	if err != nil {
+		return Key{}, nil, err
+	}
+	return info, key, nil
+}
+
+// create a new data key of the tenant, the key of another instance is used when both create the key at the same time
+func (k *Keyring) create(ctx context.Context, tenant string) ([]byte, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(k.opts.Rand, key); err != nil {
+		return nil, fmt.Errorf("tenantkey: failed to generate key: %w", err)
+	}
+	wrapped, err := k.wrap(tenant, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := k.opts.Now()
+	err = k.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		query, args, err := sqldb.BuildUpsert(tx.DriverName(), k.opts.Table, []string{"tenant"}, map[string]interface{}{
+			"tenant":      tenant,
+			"wrapped_key": wrapped,
+			"created_at":  now,
+		}, &sqldb.UpsertOptions{Update: []string{}})
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("tenantkey: failed to create the key of %s: %w", tenant, err)
+		}
+		if created, err := result.RowsAffected(); err != nil || created == 0 {
+			return err
+		}
+		return k.audit(ctx, tx, AuditEntry{Tenant: tenant, Action: ActionCreate, Actor: "tenantkey", Reason: "first encryption", CreatedAt: now})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// load the stored key, it is the key of another instance when the key is created concurrently
+	_, stored, err := k.load(ctx, tenant)
+	return stored, err
+}
+
+// wrap the data key with the master key, the tenant is authenticated so the wrapped key cannot be moved to another tenant
+func (k *Keyring) wrap(tenant string, key []byte) (string, error) {
+	aead, err := chacha20poly1305.NewX(k.kek)
+	if err != nil {
+		return "", err
+	}
+	out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(key)+aead.Overhead())
+	if _, err := io.ReadFull(k.opts.Rand, out); err != nil {
+		return "", fmt.Errorf("tenantkey: failed to generate nonce: %w", err)
+	}
+	out = aead.Seal(out, out, key, []byte(tenant))
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// unwrap the data key with the master key
+func (k *Keyring) unwrap(tenant, wrapped string) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(k.kek)
+	if err != nil {
+		return nil, err
+	}
+	in, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(in) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: %s", ErrWrappedKeyInvalid, tenant)
+	}
+	key, err := aead.Open(nil, in[:aead.NonceSize()], in[aead.NonceSize():], []byte(tenant))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrWrappedKeyInvalid, tenant)
+	}
+	return key, nil
+}
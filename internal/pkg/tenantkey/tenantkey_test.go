@@ -0,0 +1,220 @@
+package tenantkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+var testMasterKey = bytes.Repeat([]byte{7}, 32)
+
+func newTestKeyring(t *testing.T, now *time.Time) (*Keyring, *sqldb.DB) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := sqldb.Connect(ctx, sqldb.DriverSQLite, sqldb.SQLiteMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqldb.WrapFollowers(ctx, conn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := New(db, &Options{
+		MasterKey: testMasterKey,
+		CacheTTL:  time.Minute,
+		Now:       func() time.Time { return *now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// init can be called again
+	if err := k.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return k, db
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil, nil); !errors.Is(err, ErrMasterKeyInvalid) {
+		t.Fatalf("expecting error %v but got %v", ErrMasterKeyInvalid, err)
+	}
+	if _, err := New(nil, &Options{MasterKey: []byte("short")}); !errors.Is(err, ErrMasterKeyInvalid) {
+		t.Fatalf("expecting error %v but got %v", ErrMasterKeyInvalid, err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	k, db := newTestKeyring(t, &now)
+
+	ciphertext, err := k.Encrypt(ctx, "tenant-a", []byte("john@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, []byte("john")) {
+		t.Fatal("expecting the plaintext is encrypted")
+	}
+	plaintext, err := k.Decrypt(ctx, "tenant-a", ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "john@example.com" {
+		t.Fatalf("expecting john@example.com but got %s", plaintext)
+	}
+
+	// the ciphertext of a tenant cannot be decrypted as another tenant
+	if _, err := k.Encrypt(ctx, "tenant-b", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Decrypt(ctx, "tenant-b", ciphertext); !errors.Is(err, ErrCiphertextInvalid) {
+		t.Fatalf("expecting error %v but got %v", ErrCiphertextInvalid, err)
+	}
+	if _, err := k.Decrypt(ctx, "tenant-c", ciphertext); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expecting error %v but got %v", ErrKeyNotFound, err)
+	}
+	if _, err := k.Decrypt(ctx, "tenant-a", ciphertext[:10]); !errors.Is(err, ErrCiphertextInvalid) {
+		t.Fatalf("expecting error %v but got %v", ErrCiphertextInvalid, err)
+	}
+	if _, err := k.Encrypt(ctx, "", nil); !errors.Is(err, ErrTenantEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrTenantEmpty, err)
+	}
+
+	// the wrapped key is stored, so another keyring with the same master key can decrypt the data
+	other, err := New(db, &Options{MasterKey: testMasterKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err = other.Decrypt(ctx, "tenant-a", ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "john@example.com" {
+		t.Fatalf("expecting john@example.com but got %s", plaintext)
+	}
+	wrong, err := New(db, &Options{MasterKey: bytes.Repeat([]byte{8}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Decrypt(ctx, "tenant-a", ciphertext); !errors.Is(err, ErrWrappedKeyInvalid) {
+		t.Fatalf("expecting error %v but got %v", ErrWrappedKeyInvalid, err)
+	}
+
+	column, err := k.EncryptString(ctx, "tenant-a", "+62812345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := k.DecryptString(ctx, "tenant-a", column)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "+62812345678" {
+		t.Fatalf("expecting +62812345678 but got %s", value)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	k, db := newTestKeyring(t, &now)
+	other, err := New(db, &Options{MasterKey: testMasterKey, CacheTTL: time.Minute, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := objectstorage.New(memory.New("test"))
+	if _, err := k.UploadByte(ctx, storage, "tenant-a", []byte("invoice"), "tenant-a/invoice.pdf", &objectstorage.WriteOptions{ContentType: "application/pdf"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := storage.DownloadByte(ctx, "tenant-a/invoice.pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(raw, []byte("invoice")) {
+		t.Fatal("expecting the object is encrypted")
+	}
+	column, err := k.EncryptString(ctx, "tenant-a", "john")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// warm the cache of the other instance
+	if _, err := other.DecryptString(ctx, "tenant-a", column); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := k.Destroy(ctx, "tenant-a", "", "gdpr"); !errors.Is(err, ErrDestroyActorEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrDestroyActorEmpty, err)
+	}
+	if err := k.Destroy(ctx, "tenant-a", "admin", ""); !errors.Is(err, ErrDestroyReasonEmpty) {
+		t.Fatalf("expecting error %v but got %v", ErrDestroyReasonEmpty, err)
+	}
+	now = now.Add(time.Second)
+	if err := k.Destroy(ctx, "tenant-a", "admin", "gdpr request"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.DownloadByte(ctx, storage, "tenant-a", "tenant-a/invoice.pdf"); !errors.Is(err, ErrKeyDestroyed) {
+		t.Fatalf("expecting error %v but got %v", ErrKeyDestroyed, err)
+	}
+	if _, err := k.DecryptString(ctx, "tenant-a", column); !errors.Is(err, ErrKeyDestroyed) {
+		t.Fatalf("expecting error %v but got %v", ErrKeyDestroyed, err)
+	}
+	// no new key is created for the destroyed tenant
+	if _, err := k.Encrypt(ctx, "tenant-a", []byte("new")); !errors.Is(err, ErrKeyDestroyed) {
+		t.Fatalf("expecting error %v but got %v", ErrKeyDestroyed, err)
+	}
+	// the key is gone from the database
+	var wrapped string
+	if err := db.QueryRowContext(ctx, "SELECT wrapped_key FROM tenant_keys WHERE tenant = ?", "tenant-a").Scan(&wrapped); err != nil {
+		t.Fatal(err)
+	}
+	if wrapped != "" {
+		t.Fatalf("expecting empty wrapped key but got %s", wrapped)
+	}
+
+	// the other instance decrypt until the cache is expired or forgotten
+	if _, err := other.DecryptString(ctx, "tenant-a", column); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := other.DecryptString(ctx, "tenant-a", column); !errors.Is(err, ErrKeyDestroyed) {
+		t.Fatalf("expecting error %v but got %v", ErrKeyDestroyed, err)
+	}
+
+	key, err := k.Key(ctx, "tenant-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Destroyed() || !key.DestroyedAt.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("expecting the key is destroyed at %s but got %v", now.Add(-time.Minute), key.DestroyedAt)
+	}
+
+	// the tenant without key can be destroyed too
+	if err := k.Destroy(ctx, "tenant-b", "admin", "gdpr request"); err != nil {
+		t.Fatal(err)
+	}
+	k.Forget("tenant-b")
+	if _, err := k.Encrypt(ctx, "tenant-b", []byte("new")); !errors.Is(err, ErrKeyDestroyed) {
+		t.Fatalf("expecting error %v but got %v", ErrKeyDestroyed, err)
+	}
+
+	entries, err := k.AuditLog(ctx, "tenant-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expecting 2 audit entries but got %d", len(entries))
+	}
+	if entries[0].Action != ActionCreate || entries[1].Action != ActionDestroy || entries[1].Actor != "admin" || entries[1].Reason != "gdpr request" {
+		t.Fatalf("unexpected audit entries %+v", entries)
+	}
+}
@@ -151,82 +151,74 @@ func (r *Repository) Create(ctx context.Context, invoice *invoiceentity.Invoice)
 	var details []string
 
 	// create database transaction for creating invoice and invoice detail
-	tx, err := r.db.Leader().BeginTxx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+	return r.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		// create the detail first
+		for idx, invD := range invoice.Details {
+			invoiceDetail := Detail{
+				InvoiceID:    invD.InvoiceID,
+				Amount:       invD.Amount,
+				Discount:     invD.Discount,
+				ItemName:     invD.ItemName,
+				ItemQuantity: invD.ItemQuantity,
+				Description:  invD.Description,
+				CreatedAt:    time.Now(),
+				CreatedBy:    invD.CreatedBy,
+				IsTest:       invD.IsTest,
+			}
+			qDetail, args, err := r.db.BindNamed(createNewInvoiceDetailQuery, invoiceDetail)
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, qDetail, args)
+			if err != nil {
+				return err
+			}
+			// only return 1 result: ID
+			if rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err != nil {
+					return err
+				}
+				details = append(details, id)
+				// set invoice details id
+				invoice.Details[idx].ID = id
+			}
 		}
-	}()
 
-	// create the detail first
-	for idx, invD := range invoice.Details {
-		invoiceDetail := Detail{
-			InvoiceID:    invD.InvoiceID,
-			Amount:       invD.Amount,
-			Discount:     invD.Discount,
-			ItemName:     invD.ItemName,
-			ItemQuantity: invD.ItemQuantity,
-			Description:  invD.Description,
-			CreatedAt:    time.Now(),
-			CreatedBy:    invD.CreatedBy,
-			IsTest:       invD.IsTest,
+		// insert the invoice
+		inv := Invoice{
+			Number:        invoice.Number,
+			OrderID:       invoice.OrderID,
+			InvoiceFrom:   invoice.InvoiceFrom,
+			InvoiceTo:     invoice.InvoiceTo,
+			Type:          invoice.Type,
+			Total:         invoice.Total,
+			DiscountTotal: invoice.DiscountTotal,
+			GrandTotal:    invoice.GrandTotal,
+			Details:       details,
+			Status:        invoice.Status,
+			Description:   invoice.Description,
+			DueDate:       invoice.DueDate,
+			CreatedAt:     time.Now(),
+			CreatedBy:     invoice.CreatedBy,
+			IsTest:        invoice.IsTest,
 		}
-		qDetail, args, err := r.db.BindNamed(createNewInvoiceDetailQuery, invoiceDetail)
+		qInv, args, err := r.db.BindNamed(createNewInvoiceQuery, inv)
 		if err != nil {
 			return err
 		}
-
-		rows, err := tx.QueryContext(ctx, qDetail, args)
+		rows, err := tx.QueryContext(ctx, qInv, args)
 		if err != nil {
 			return err
 		}
-		// only return 1 result: ID
 		if rows.Next() {
-			var id string
-			if err := rows.Scan(&id); err != nil {
+			if err := rows.Scan(invoice.ID); err != nil {
 				return err
 			}
-			details = append(details, id)
-			// set invoice details id
-			invoice.Details[idx].ID = id
 		}
-	}
-
-	// insert the invoice
-	inv := Invoice{
-		Number:        invoice.Number,
-		OrderID:       invoice.OrderID,
-		InvoiceFrom:   invoice.InvoiceFrom,
-		InvoiceTo:     invoice.InvoiceTo,
-		Type:          invoice.Type,
-		Total:         invoice.Total,
-		DiscountTotal: invoice.DiscountTotal,
-		GrandTotal:    invoice.GrandTotal,
-		Details:       details,
-		Status:        invoice.Status,
-		Description:   invoice.Description,
-		DueDate:       invoice.DueDate,
-		CreatedAt:     time.Now(),
-		CreatedBy:     invoice.CreatedBy,
-		IsTest:        invoice.IsTest,
-	}
-	qInv, args, err := r.db.BindNamed(createNewInvoiceQuery, inv)
-	if err != nil {
-		return err
-	}
-	rows, err := tx.QueryContext(ctx, qInv, args)
-	if err != nil {
-		return err
-	}
-	if rows.Next() {
-		if err := rows.Scan(invoice.ID); err != nil {
-			return err
-		}
-	}
-	return tx.Commit()
+		return nil
+	})
 }
 
 const (
@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	entity "github.com/albertwidi/go-project-example/internal/entity/notification"
+	"github.com/albertwidi/go-project-example/internal/pkg/redis"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// Repository of notification
+type Repository struct {
+	db    *sqldb.DB
+	redis redis.Redis
+}
+
+// New notification repository
+func New(db *sqldb.DB, redis redis.Redis) *Repository {
+	r := Repository{
+		db:    db,
+		redis: redis,
+	}
+	return &r
+}
+
+// GetPreferences return all channel preferences of a user
+func (r *Repository) GetPreferences(ctx context.Context, userID int64) ([]entity.Preference, error) {
+	query := `SELECT user_id, channel, enabled, quiet_hours_start, quiet_hours_end, time_zone, created_at, updated_at
+	FROM notification_preferences
+	WHERE user_id = $1`
+
+	var prefs []entity.Preference
+	if err := r.db.SelectContext(ctx, &prefs, query, userID); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// SavePreference create or update user preference for a channel
+func (r *Repository) SavePreference(ctx context.Context, pref entity.Preference) error {
+	values := map[string]interface{}{
+		"user_id":           pref.UserID,
+		"channel":           pref.Channel,
+		"enabled":           pref.Enabled,
+		"quiet_hours_start": pref.QuietHoursStart,
+		"quiet_hours_end":   pref.QuietHoursEnd,
+		"time_zone":         pref.TimeZone,
+		"created_at":        pref.CreatedAt,
+		"updated_at":        pref.UpdatedAt,
+	}
+	_, err := r.db.Upsert(ctx, "notification_preferences", []string{"user_id", "channel"}, values, &sqldb.UpsertOptions{
+		Update: []string{"enabled", "quiet_hours_start", "quiet_hours_end", "time_zone", "updated_at"},
+	})
+	return err
+}
+
+func createDedupKey(userID, channel, key string) string {
+	return strings.Join([]string{"notification_dedup", userID, channel, key}, ":")
+}
+
+// AcquireDedup return true if the key is not yet acquired within the window
+// the key will be automatically released after the window passed
+func (r *Repository) AcquireDedup(ctx context.Context, userID int64, channel entity.Channel, key string, window time.Duration) (bool, error) {
+	dedupKey := createDedupKey(strconv.FormatInt(userID, 10), string(channel), key)
+	resp, err := r.redis.SetNX(ctx, dedupKey, 1, int(window.Seconds()))
+	if err != nil {
+		if r.redis.IsErrNil(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return resp == 1, nil
+}
+
+// ReleaseDedup release the acquired key before the window passed, for example when the delivery is failed
+func (r *Repository) ReleaseDedup(ctx context.Context, userID int64, channel entity.Channel, key string) error {
+	dedupKey := createDedupKey(strconv.FormatInt(userID, 10), string(channel), key)
+	_, err := r.redis.Delete(ctx, dedupKey)
+	return err
+}
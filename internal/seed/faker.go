@@ -0,0 +1,93 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	firstNames = []string{"Agus", "Budi", "Citra", "Dewi", "Eko", "Fitri", "Gilang", "Hana", "Indra", "Joko", "Kartika", "Lestari", "Maya", "Nanda", "Putri", "Rizky", "Sari", "Teguh", "Wulan", "Yusuf"}
+	lastNames  = []string{"Pratama", "Saputra", "Wijaya", "Santoso", "Hidayat", "Kusuma", "Nugroho", "Setiawan", "Halim", "Siregar", "Lubis", "Wibowo"}
+	itemNames  = []string{"Kost Room Monthly", "Kost Room Weekly", "Laundry Package", "Cleaning Service", "Electricity Token", "Water Gallon", "Parking Monthly", "Wifi Package"}
+)
+
+const hashAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// Faker generate fake data from a seeded random source
+// faker with the same seed always generate the same sequence of data
+type Faker struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewFaker return faker with the seed
+func NewFaker(seed int64) *Faker {
+	f := Faker{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+	return &f
+}
+
+// Intn return random number in [0, n)
+func (f *Faker) Intn(n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Intn(n)
+}
+
+// Int64Range return random number in [min, max]
+func (f *Faker) Int64Range(min, max int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return min + f.rand.Int63n(max-min+1)
+}
+
+// Pick return random element of the list
+func (f *Faker) Pick(list []string) string {
+	return list[f.Intn(len(list))]
+}
+
+// FullName return random full name
+func (f *Faker) FullName() string {
+	return f.Pick(firstNames) + " " + f.Pick(lastNames)
+}
+
+// PhoneNumber return random indonesian mobile phone number
+func (f *Faker) PhoneNumber() string {
+	return fmt.Sprintf("08%d%09d", 11+f.Intn(89), f.Intn(1000000000))
+}
+
+// Email return random email of the name, the domain is always example.com
+func (f *Faker) Email(name string) string {
+	local := strings.ToLower(strings.Join(strings.Fields(name), "."))
+	return fmt.Sprintf("%s.%d@example.com", local, f.Intn(10000))
+}
+
+// HashID return random lowercase alphanumeric string with length n
+func (f *Faker) HashID(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = hashAlphabet[f.Intn(len(hashAlphabet))]
+	}
+	return string(b)
+}
+
+// UUID return random uuid version 4
+func (f *Faker) UUID() string {
+	b := make([]byte, 16)
+	f.mu.Lock()
+	f.rand.Read(b)
+	f.mu.Unlock()
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Birthday return random birthday of someone between 18 and 60 years old at now
+func (f *Faker) Birthday(now time.Time) time.Time {
+	days := f.Int64Range(18*365, 60*365)
+	return now.AddDate(0, 0, -int(days)).Truncate(time.Hour * 24)
+}
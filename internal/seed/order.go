@@ -0,0 +1,98 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	orderentity "github.com/albertwidi/go-project-example/internal/entity/order"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// BuildOrder return order of the user with one to three fake details without writing it to database
+// the totals are calculated from the details after the overrides are applied
+func (s *Seeder) BuildOrder(userID int64, overrides ...func(o *orderentity.Order)) orderentity.Order {
+	now := s.now()
+	o := orderentity.Order{
+		ID:               s.faker.UUID(),
+		UserID:           userID,
+		OrderType:        int32(orderentity.TypeBooking),
+		Status:           orderentity.StatusCreated,
+		IsRefundable:     true,
+		RefundableBefore: now.Add(time.Hour * 24),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	count := 1 + s.faker.Intn(3)
+	for i := 0; i < count; i++ {
+		var (
+			price    = s.faker.Int64Range(10, 500) * 1000
+			quantity = s.faker.Int64Range(1, 3)
+		)
+		o.Details = append(o.Details, orderentity.Detail{
+			ID:             s.faker.UUID(),
+			ItemID:         s.faker.UUID(),
+			ItemName:       s.faker.Pick(itemNames),
+			ItemPrice:      price,
+			ItemQuantity:   quantity,
+			ItemPriceTotal: price * quantity,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+
+	for _, override := range overrides {
+		override(&o)
+	}
+	o.IsTest = true
+	calculateOrder(&o)
+	return o
+}
+
+// calculateOrder set the order id to the details and calculate the totals
+func calculateOrder(o *orderentity.Order) {
+	o.Total, o.DiscountTotal, o.RefundTotal, o.CashbackTotal = 0, 0, 0, 0
+	for i := range o.Details {
+		d := &o.Details[i]
+		d.OrderID = o.ID
+		d.IsTest = true
+		d.Total = d.ItemPriceTotal - d.DiscountAmount - d.RefundAmount
+
+		o.Total += d.ItemPriceTotal
+		o.DiscountTotal += d.DiscountAmount
+		o.RefundTotal += d.RefundAmount
+		o.CashbackTotal += d.CashbackAmount
+	}
+	o.GrandTotal = o.Total - o.DiscountTotal - o.RefundTotal
+}
+
+// CreateOrder build the order and write the order and its details to database in a transaction
+func (s *Seeder) CreateOrder(ctx context.Context, userID int64, overrides ...func(o *orderentity.Order)) (orderentity.Order, error) {
+	o := s.BuildOrder(userID, overrides...)
+
+	orderQuery := `INSERT INTO orders(id, user_id, order_type, total, discount_total, refund_total, cashback_total, grand_total, status, is_refundable, refundable_before, created_at, updated_at, is_test)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	detailQuery := `INSERT INTO order_details(id, order_id, item_id, item_name, item_price, item_quantity, item_price_total, discount_amount, refund_amount, cashback_amount, total, created_at, updated_at, is_test)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	err := s.db.WithTransaction(ctx, func(tx *sqldb.Tx) error {
+		_, err := tx.ExecContext(ctx, tx.Rebind(orderQuery), o.ID, o.UserID, o.OrderType, o.Total, o.DiscountTotal, o.RefundTotal, o.CashbackTotal, o.GrandTotal, o.Status, o.IsRefundable, o.RefundableBefore, o.CreatedAt, o.UpdatedAt, o.IsTest)
+		if err != nil {
+			return fmt.Errorf("seed: failed to create order: %w", err)
+		}
+
+		for _, d := range o.Details {
+			_, err := tx.ExecContext(ctx, tx.Rebind(detailQuery), d.ID, d.OrderID, d.ItemID, d.ItemName, d.ItemPrice, d.ItemQuantity, d.ItemPriceTotal, d.DiscountAmount, d.RefundAmount, d.CashbackAmount, d.Total, d.CreatedAt, d.UpdatedAt, d.IsTest)
+			if err != nil {
+				return fmt.Errorf("seed: failed to create order detail: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return o, err
+	}
+	return o, nil
+}
@@ -0,0 +1,66 @@
+// Package seed provide declarative factories of core entities for integration tests and debug scenarios
+// every factory build the entity with fake data from a seeded faker, then override the fields with the given functions
+// so the same seed and the same sequence of calls always produce the same data
+//
+// entity created by seed is always marked as test data
+package seed
+
+import (
+	"errors"
+	"time"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+)
+
+// list of error
+var (
+	ErrDBNil      = errors.New("seed: database is nil")
+	ErrStorageNil = errors.New("seed: object storage is nil")
+)
+
+// Options of seeder
+type Options struct {
+	// Seed of the faker, default to 1
+	Seed int64
+	// Now return the time of created_at and updated_at, default to time.Now
+	Now func() time.Time
+}
+
+// Seeder create entities to database and object storage
+type Seeder struct {
+	db      *sqldb.DB
+	storage *objectstorage.Storage
+	faker   *Faker
+	now     func() time.Time
+}
+
+// New seeder, storage can be nil if the seeded entity doesn't need object storage
+func New(db *sqldb.DB, storage *objectstorage.Storage, options *Options) (*Seeder, error) {
+	if db == nil {
+		return nil, ErrDBNil
+	}
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	s := Seeder{
+		db:      db,
+		storage: storage,
+		faker:   NewFaker(opts.Seed),
+		now:     opts.Now,
+	}
+	return &s, nil
+}
+
+// Faker return the faker used by the seeder
+func (s *Seeder) Faker() *Faker {
+	return s.faker
+}
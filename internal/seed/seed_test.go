@@ -0,0 +1,158 @@
+package seed
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	orderentity "github.com/albertwidi/go-project-example/internal/entity/order"
+	userentity "github.com/albertwidi/go-project-example/internal/entity/user"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage/memory"
+	"github.com/albertwidi/go-project-example/internal/pkg/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+var testNow = time.Date(2020, 5, 5, 10, 0, 0, 0, time.UTC)
+
+func newTestSeeder(t *testing.T, seed int64) (*Seeder, sqlmock.Sqlmock, *objectstorage.Storage) {
+	t.Helper()
+	mockdb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := sqlx.NewDb(mockdb, sqldb.DriverPostgres)
+	wrapped, err := sqldb.Wrap(context.Background(), db, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := objectstorage.New(memory.New("seed"))
+	s, err := New(wrapped, storage, &Options{
+		Seed: seed,
+		Now:  func() time.Time { return testNow },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, mock, storage
+}
+
+func TestDeterministic(t *testing.T) {
+	s1, _, _ := newTestSeeder(t, 42)
+	s2, _, _ := newTestSeeder(t, 42)
+	s3, _, _ := newTestSeeder(t, 43)
+
+	u1, u2, u3 := s1.BuildUser(), s2.BuildUser(), s3.BuildUser()
+	if !reflect.DeepEqual(u1, u2) {
+		t.Errorf("expecting same user with the same seed, got %+v and %+v", u1, u2)
+	}
+	if reflect.DeepEqual(u1, u3) {
+		t.Errorf("expecting different user with different seed, got %+v", u1)
+	}
+
+	o1, o2 := s1.BuildOrder(1), s2.BuildOrder(1)
+	if !reflect.DeepEqual(o1, o2) {
+		t.Errorf("expecting same order with the same seed, got %+v and %+v", o1, o2)
+	}
+}
+
+func TestBuildUserOverride(t *testing.T) {
+	s, _, _ := newTestSeeder(t, 1)
+	u := s.BuildUser(func(u *userentity.User) {
+		u.PhoneNumber = "081200000000"
+		u.IsTest = false
+	})
+	if u.PhoneNumber != "081200000000" {
+		t.Errorf("expecting phone number to be overridden, got %s", u.PhoneNumber)
+	}
+	if !u.IsTest {
+		t.Error("expecting seeded user is always test user")
+	}
+	if len(u.HashID) != 8 {
+		t.Errorf("expecting hash id length 8, got %s", u.HashID)
+	}
+	if !u.CreatedAt.Equal(testNow) {
+		t.Errorf("expecting created at %s, got %s", testNow, u.CreatedAt)
+	}
+}
+
+func TestBuildOrderTotal(t *testing.T) {
+	s, _, _ := newTestSeeder(t, 1)
+	o := s.BuildOrder(10, func(o *orderentity.Order) {
+		o.Details = []orderentity.Detail{
+			{ItemPrice: 1000, ItemQuantity: 2, ItemPriceTotal: 2000, DiscountAmount: 500},
+			{ItemPrice: 3000, ItemQuantity: 1, ItemPriceTotal: 3000, RefundAmount: 1000},
+		}
+	})
+	if o.Total != 5000 || o.DiscountTotal != 500 || o.RefundTotal != 1000 {
+		t.Errorf("invalid totals: %+v", o)
+	}
+	if o.GrandTotal != 3500 {
+		t.Errorf("expecting grand total 3500, got %d", o.GrandTotal)
+	}
+	for _, d := range o.Details {
+		if d.OrderID != o.ID {
+			t.Errorf("expecting order id %s in detail, got %s", o.ID, d.OrderID)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	s, mock, _ := newTestSeeder(t, 1)
+	mock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := s.CreateUser(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateUserBio(t *testing.T) {
+	s, mock, storage := newTestSeeder(t, 1)
+	mock.ExpectExec("INSERT INTO users_bio").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	bio, err := s.CreateUserBio(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bio.Avatar != "seed/avatar/user-1.png" {
+		t.Errorf("unexpected avatar key %s", bio.Avatar)
+	}
+	if _, err := storage.DownloadByte(ctx, bio.Avatar, nil); err != nil {
+		t.Errorf("expecting avatar uploaded, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	s, _, _ := newTestSeeder(t, 1)
+	o := s.BuildOrder(1)
+
+	// the same seed build the same order, so the number of details is known
+	s, mock, _ := newTestSeeder(t, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	for range o.Details {
+		mock.ExpectExec("INSERT INTO order_details").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	created, err := s.CreateOrder(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o, created) {
+		t.Errorf("expecting %+v, got %+v", o, created)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
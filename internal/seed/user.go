@@ -0,0 +1,124 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	userentity "github.com/albertwidi/go-project-example/internal/entity/user"
+	"github.com/albertwidi/go-project-example/internal/pkg/objectstorage"
+)
+
+// list of default user value
+const (
+	UserStatusActive = 1
+	UserTypeDefault  = userentity.Type(1)
+)
+
+// BuildUser return user with fake data without writing it to database
+func (s *Seeder) BuildUser(overrides ...func(u *userentity.User)) userentity.User {
+	var (
+		now  = s.now()
+		name = s.faker.FullName()
+	)
+	u := userentity.User{
+		ID:          s.faker.UUID(),
+		HashID:      userentity.Hash(s.faker.HashID(8)),
+		UserStatus:  UserStatusActive,
+		UserType:    UserTypeDefault,
+		PhoneNumber: s.faker.PhoneNumber(),
+		Email:       s.faker.Email(name),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, override := range overrides {
+		override(&u)
+	}
+	u.IsTest = true
+	return u
+}
+
+// CreateUser build the user and write it to database
+func (s *Seeder) CreateUser(ctx context.Context, overrides ...func(u *userentity.User)) (userentity.User, error) {
+	u := s.BuildUser(overrides...)
+	query := `INSERT INTO users(id, hash_id, user_type, user_status, phone_number, email, created_at, updated_at, is_test)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(query), u.ID, u.HashID, u.UserType, u.UserStatus, u.PhoneNumber, u.Email, u.CreatedAt, u.UpdatedAt, u.IsTest)
+	if err != nil {
+		return u, fmt.Errorf("seed: failed to create user: %w", err)
+	}
+	return u, nil
+}
+
+// BuildUserBio return bio of the user with fake data without writing it to database
+func (s *Seeder) BuildUserBio(userID string, overrides ...func(bio *userentity.Bio)) userentity.Bio {
+	now := s.now()
+	bio := userentity.Bio{
+		UserID:    userID,
+		FullName:  s.faker.FullName(),
+		Birthday:  s.faker.Birthday(now),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, override := range overrides {
+		override(&bio)
+	}
+	bio.IsTest = true
+	return bio
+}
+
+// CreateUserBio build the bio, upload the avatar to object storage and write the bio to database
+// the avatar is not uploaded when it is set by the overrides
+func (s *Seeder) CreateUserBio(ctx context.Context, userID string, overrides ...func(bio *userentity.Bio)) (userentity.Bio, error) {
+	bio := s.BuildUserBio(userID, overrides...)
+	if bio.Avatar == "" {
+		avatar, err := s.uploadAvatar(ctx, userID)
+		if err != nil {
+			return bio, err
+		}
+		bio.Avatar = avatar
+	}
+
+	query := `INSERT INTO users_bio(user_id, full_name, occupation, gender, birthday, avatar, created_at, updated_at, is_test)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(query), bio.UserID, bio.FullName, "", 0, bio.Birthday, bio.Avatar, bio.CreatedAt, bio.UpdatedAt, bio.IsTest)
+	if err != nil {
+		return bio, fmt.Errorf("seed: failed to create user bio: %w", err)
+	}
+	return bio, nil
+}
+
+// uploadAvatar upload a single color png as the avatar of the user and return the key of the object
+func (s *Seeder) uploadAvatar(ctx context.Context, userID string) (string, error) {
+	if s.storage == nil {
+		return "", ErrStorageNil
+	}
+
+	c := color.RGBA{
+		R: uint8(s.faker.Intn(256)),
+		G: uint8(s.faker.Intn(256)),
+		B: uint8(s.faker.Intn(256)),
+		A: 255,
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	buff := bytes.NewBuffer(nil)
+	if err := png.Encode(buff, img); err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("seed/avatar/%s.png", userID)
+	if _, err := s.storage.UploadByte(ctx, buff.Bytes(), key, &objectstorage.WriteOptions{ContentType: "image/png"}); err != nil {
+		return "", fmt.Errorf("seed: failed to upload avatar: %w", err)
+	}
+	return key, nil
+}
@@ -5,6 +5,8 @@ import (
 	"net"
 	"net/http"
 
+	"github.com/albertwidi/go-project-example/internal/pkg/buildinfo"
+	"github.com/albertwidi/go-project-example/internal/pkg/graceful"
 	"github.com/albertwidi/go-project-example/internal/pkg/router"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -13,10 +15,23 @@ type adminServer struct {
 	address    string
 	httpServer *http.Server
 	listener   net.Listener
+	// handlers registered by HandleAdmin
+	handlers []adminHandler
+}
+
+type adminHandler struct {
+	pattern string
+	handler http.Handler
+}
+
+// HandleAdmin register the handler to the admin server, for example a diagnostic endpoint
+// this must be called before Run
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	s.admin.handlers = append(s.admin.handlers, adminHandler{pattern: pattern, handler: handler})
 }
 
 func (s *Server) newAdminServer(address string) (*adminServer, error) {
-	listener, err := net.Listen("tcp", address)
+	listener, err := graceful.ListenAddress(address)
 	if err != nil {
 		return nil, err
 	}
@@ -45,4 +60,8 @@ func (adm *adminServer) Shutdown(ctx context.Context) error {
 
 func (adm *adminServer) registerHandler(r *router.Router) {
 	r.Handle("/metrics", promhttp.Handler())
+	r.Handle("/debug/buildinfo", buildinfo.Handler())
+	for _, h := range adm.handlers {
+		r.Handle(h.pattern, h.handler)
+	}
 }
@@ -0,0 +1,242 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	entity "github.com/albertwidi/go-project-example/internal/entity/notification"
+	"github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/usecase/notification"
+	"github.com/albertwidi/go-project-example/internal/xerrors"
+)
+
+// list of preview format
+const (
+	FormatHTML = "html"
+	FormatText = "text"
+)
+
+// list of error
+var (
+	ErrSendDisabled        = errors.New("email: send is disabled, sender or allowlist is empty")
+	ErrRecipientNotAllowed = errors.New("email: recipient is not in the allowlist")
+	ErrFormatInvalid       = errors.New("email: format must be html or text")
+)
+
+// Options of email handler
+type Options struct {
+	// Sender of the preview email, the preview cannot be sent when nil
+	Sender notification.Adapter
+	// Allowlist of recipient which can receive the preview, the address or the domain, for example @example.com
+	// the preview cannot be sent when empty
+	Allowlist []string
+}
+
+// Handler for email template preview
+type Handler struct {
+	templates *notification.EmailTemplate
+	sender    notification.Adapter
+	allowlist []string
+}
+
+// New handler for email template preview
+func New(templates *notification.EmailTemplate, options *Options) *Handler {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	h := Handler{
+		templates: templates,
+		sender:    opts.Sender,
+	}
+	for _, allowed := range opts.Allowlist {
+		h.allowlist = append(h.allowlist, strings.ToLower(strings.TrimSpace(allowed)))
+	}
+	return &h
+}
+
+// TemplateInfo of email template, used to list the templates
+type TemplateInfo struct {
+	Name   string                 `json:"name"`
+	Sample map[string]interface{} `json:"sample"`
+}
+
+// PreviewRequest of email template
+type PreviewRequest struct {
+	Template string `json:"template"`
+	// Data of the template, the keys override the sample data of the template
+	Data map[string]interface{} `json:"data"`
+}
+
+// SendRequest of email template preview
+type SendRequest struct {
+	PreviewRequest
+	To string `json:"to"`
+}
+
+// SendResponse of email template preview
+type SendResponse struct {
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	MessageID string `json:"message_id"`
+}
+
+// List all email templates with the sample data
+func (h *Handler) List(rctx *context.RequestContext) error {
+	names := h.templates.Names()
+	infos := make([]TemplateInfo, 0, len(names))
+	for _, name := range names {
+		sample, err := h.templates.Sample(name)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, TemplateInfo{Name: name, Sample: sample})
+	}
+	_, err := rctx.JSON().Data(infos).Write()
+	return err
+}
+
+// Preview render the template with the sample data and write the html or text output
+// this is for the browser, so the designer can refresh the page after the template is changed
+func (h *Handler) Preview(rctx *context.RequestContext) error {
+	const op xerrors.Op = "debug/email/preview"
+
+	query := rctx.Request().URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = FormatHTML
+	}
+	if format != FormatHTML && format != FormatText {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, ErrFormatInvalid))
+	}
+
+	rendered, err := h.render(PreviewRequest{Template: query.Get("template")})
+	if err != nil {
+		return h.writeError(rctx, xerrors.New(op, kindOf(err), err))
+	}
+
+	w := rctx.ResponseWriter()
+	body := rendered.HTML
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if format == FormatText {
+		body = rendered.Text
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write([]byte(body))
+	return err
+}
+
+// Render the template with the supplied data and return the subject, html and text output
+func (h *Handler) Render(rctx *context.RequestContext) error {
+	const op xerrors.Op = "debug/email/render"
+
+	req := PreviewRequest{}
+	if err := rctx.DecodeJSON(&req); err != nil {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, err))
+	}
+	rendered, err := h.render(req)
+	if err != nil {
+		return h.writeError(rctx, xerrors.New(op, kindOf(err), err))
+	}
+	_, err = rctx.JSON().Data(rendered).Write()
+	return err
+}
+
+// Send the rendered template to the allowlisted recipient
+func (h *Handler) Send(rctx *context.RequestContext) error {
+	const op xerrors.Op = "debug/email/send"
+
+	req := SendRequest{}
+	if err := rctx.DecodeJSON(&req); err != nil {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, err))
+	}
+	if h.sender == nil || len(h.allowlist) == 0 {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindUnauthorized, ErrSendDisabled))
+	}
+	to, ok := h.recipient(req.To)
+	if !ok {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindUnauthorized, fmt.Errorf("%w: %s", ErrRecipientNotAllowed, req.To)))
+	}
+	rendered, err := h.render(req.PreviewRequest)
+	if err != nil {
+		return h.writeError(rctx, xerrors.New(op, kindOf(err), err))
+	}
+
+	message := rendered.HTML
+	if message == "" {
+		message = rendered.Text
+	}
+	messageID, err := h.sender.Send(rctx.Context(), entity.Delivery{
+		Channel:   entity.ChannelEmail,
+		Recipient: to,
+		Title:     rendered.Subject,
+		Message:   message,
+		Status:    entity.DeliveryStatusPending,
+	})
+	if err != nil {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindInternalError, err))
+	}
+	_, err = rctx.JSON().Data(SendResponse{To: to, Subject: rendered.Subject, MessageID: messageID}).Write()
+	return err
+}
+
+// render the template with the sample data, overridden by the request data
+func (h *Handler) render(req PreviewRequest) (notification.RenderedEmail, error) {
+	sample, err := h.templates.Sample(req.Template)
+	if err != nil {
+		return notification.RenderedEmail{}, err
+	}
+	data := make(map[string]interface{}, len(sample)+len(req.Data))
+	for key, value := range sample {
+		data[key] = value
+	}
+	for key, value := range req.Data {
+		data[key] = value
+	}
+	return h.templates.Execute(req.Template, data)
+}
+
+// recipient return the parsed address when it is a single bare address in the allowlist
+// display name, angle brackets, quoted local part and lists are rejected, so the domain checked is the domain which receive the email
+func (h *Handler) recipient(to string) (string, bool) {
+	to = strings.TrimSpace(to)
+	addr, err := mail.ParseAddress(to)
+	if err != nil || addr.Name != "" || addr.Address != to {
+		return "", false
+	}
+	address := strings.ToLower(addr.Address)
+	at := strings.LastIndex(address, "@")
+	if at <= 0 {
+		return "", false
+	}
+	for _, allowed := range h.allowlist {
+		if allowed == address || allowed == address[at:] {
+			return addr.Address, true
+		}
+	}
+	return "", false
+}
+
+// kindOf return the error kind of render error, the template fail to execute because of the data
+func kindOf(err error) xerrors.Kind {
+	if errors.Is(err, notification.ErrEmailTemplateNotFound) {
+		return xerrors.KindNotFound
+	}
+	return xerrors.KindBadRequest
+}
+
+func (h *Handler) writeError(rctx *context.RequestContext, err error) error {
+	_, werr := rctx.JSON().Error(err, &response.JSONError{
+		Title:   "Email Preview Failed",
+		Message: err.Error(),
+	}).Write()
+	if werr != nil {
+		return werr
+	}
+	return err
+}
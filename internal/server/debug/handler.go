@@ -4,23 +4,75 @@ package debug
 
 import (
 	"github.com/albertwidi/go-project-example/internal/pkg/router"
-	"github.com/albertwidi/go-project-example/internal/server/debug/user"
+	"github.com/albertwidi/go-project-example/internal/server/debug/email"
+	"github.com/albertwidi/go-project-example/internal/server/debug/scenario"
 )
 
 // Handlers of debug server
 type Handlers struct {
-	user *user.Handler
+	scenario *scenario.Handler
+	// email is nil when no email template is loaded
+	email *email.Handler
 }
 
 func (s *Server) registerHandlers(r *router.Router) {
-	// swagger:route GET /user/login/bypass bypass user login
-	// Bypassing user login
-	// This will bypass user login
-	// Only ued in development
+	// swagger:route GET /scenarios list debug scenarios
+	// List debug scenarios
+	// This will list all registered scenarios and whether the scenario is enabled
+	// Only used in development
+	//	Produces:
+	//	- application/json
+	//	Schemes: http
+	r.Get("/scenarios", s.handlers.scenario.List)
+	// swagger:route POST /scenarios/run run debug scenario
+	// Run debug scenario
+	// This will run the scenario with the parameters, every run is written to the audit log
+	// Only used in development
+	//	Consumes:
+	//	- application/json
+	//	Produces:
+	//	- application/json
+	//	Schemes: http
+	r.Post("/scenarios/run", s.handlers.scenario.Run)
+
+	if s.handlers.email == nil {
+		return
+	}
+	// swagger:route GET /emails/templates list email templates
+	// List email templates
+	// This will list all loaded email templates with the sample data
+	// Only used in development
+	//	Produces:
+	//	- application/json
+	//	Schemes: http
+	r.Get("/emails/templates", s.handlers.email.List)
+	// swagger:route GET /emails/preview preview email template
+	// Preview email template
+	// This will render the template with the sample data as html or text, to be opened in the browser
+	// Only used in development
+	//	Produces:
+	//	- text/html
+	//	- text/plain
+	//	Schemes: http
+	r.Get("/emails/preview", s.handlers.email.Preview)
+	// swagger:route POST /emails/render render email template
+	// Render email template
+	// This will render the template with the supplied data and return the subject, html and text output
+	// Only used in development
+	//	Consumes:
+	//	- application/json
+	//	Produces:
+	//	- application/json
+	//	Schemes: http
+	r.Post("/emails/render", s.handlers.email.Render)
+	// swagger:route POST /emails/send send email template preview
+	// Send email template preview
+	// This will render the template and send it to the recipient in the allowlist
+	// Only used in development
 	//	Consumes:
 	//	- application/json
 	//	Produces:
 	//	- application/json
 	//	Schemes: http
-	r.Get("/user/login/bypass", s.handlers.user.BypassLogin)
+	r.Post("/emails/send", s.handlers.email.Send)
 }
@@ -0,0 +1,81 @@
+package scenario
+
+import (
+	"errors"
+
+	"github.com/albertwidi/go-project-example/debug/scenario"
+	"github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/http/response"
+	"github.com/albertwidi/go-project-example/internal/xerrors"
+)
+
+// HeaderActor is the header of the one who run the scenario, written to the audit log
+const HeaderActor = "X-Debug-Actor"
+
+// Handler for debug scenario
+type Handler struct {
+	registry *scenario.Registry
+}
+
+// New handler for debug scenario
+func New(registry *scenario.Registry) *Handler {
+	h := Handler{
+		registry: registry,
+	}
+	return &h
+}
+
+// RunRequest of scenario
+type RunRequest struct {
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params"`
+}
+
+// List all scenarios
+func (h *Handler) List(rctx *context.RequestContext) error {
+	_, err := rctx.JSON().Data(h.registry.List()).Write()
+	return err
+}
+
+// Run the scenario
+func (h *Handler) Run(rctx *context.RequestContext) error {
+	const op xerrors.Op = "debug/scenario/run"
+
+	req := RunRequest{}
+	if err := rctx.DecodeJSON(&req); err != nil {
+		return h.writeError(rctx, xerrors.New(op, xerrors.KindBadRequest, err))
+	}
+
+	actor := rctx.RequestHeader().Get(HeaderActor)
+	if actor == "" {
+		actor = rctx.Request().RemoteAddr
+	}
+
+	result, err := h.registry.Run(rctx.Context(), req.Name, actor, req.Params)
+	if err != nil {
+		kind := xerrors.KindInternalError
+		switch {
+		case errors.Is(err, scenario.ErrNotFound):
+			kind = xerrors.KindNotFound
+		case errors.Is(err, scenario.ErrDisabled):
+			kind = xerrors.KindUnauthorized
+		case errors.Is(err, scenario.ErrParamRequired), errors.Is(err, scenario.ErrParamUnknown):
+			kind = xerrors.KindBadRequest
+		}
+		return h.writeError(rctx, xerrors.New(op, kind, err))
+	}
+
+	_, err = rctx.JSON().Data(result).Write()
+	return err
+}
+
+func (h *Handler) writeError(rctx *context.RequestContext, err error) error {
+	_, werr := rctx.JSON().Error(err, &response.JSONError{
+		Title:   "Scenario Failed",
+		Message: err.Error(),
+	}).Write()
+	if werr != nil {
+		return werr
+	}
+	return err
+}
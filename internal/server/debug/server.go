@@ -9,9 +9,12 @@ import (
 	"net"
 	"net/http"
 
-	"github.com/albertwidi/go-project-example/debug/user"
+	"github.com/albertwidi/go-project-example/debug/scenario"
+	"github.com/albertwidi/go-project-example/internal/pkg/graceful"
 	"github.com/albertwidi/go-project-example/internal/pkg/router"
-	userhandler "github.com/albertwidi/go-project-example/internal/server/debug/user"
+	emailhandler "github.com/albertwidi/go-project-example/internal/server/debug/email"
+	scenariohandler "github.com/albertwidi/go-project-example/internal/server/debug/scenario"
+	"github.com/albertwidi/go-project-example/internal/usecase/notification"
 )
 
 // Server struct
@@ -25,20 +28,39 @@ type Server struct {
 
 // Usecases of debug server
 type Usecases struct {
-	user *user.DebugUsecase
+	Scenarios *scenario.Registry
+	// EmailTemplates of the email preview, the email routes are not registered when nil
+	EmailTemplates *notification.EmailTemplate
+	// EmailSender send the email preview, the preview cannot be sent when nil
+	EmailSender notification.Adapter
+}
+
+// Options of debug server
+type Options struct {
+	// EmailAllowlist is the list of address or domain which can receive the email preview, for example @example.com
+	EmailAllowlist []string
 }
 
 // New server
-func New(address string, usecases Usecases) (*Server, error) {
-	listener, err := net.Listen("tcp", address)
+func New(address string, usecases Usecases, options *Options) (*Server, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	listener, err := graceful.ListenAddress(address)
 	if err != nil {
 		return nil, err
 	}
 
 	// init all handlers
-	userHandler := userhandler.New(usecases.user)
 	handlers := Handlers{
-		user: userHandler,
+		scenario: scenariohandler.New(usecases.Scenarios),
+	}
+	if usecases.EmailTemplates != nil {
+		handlers.email = emailhandler.New(usecases.EmailTemplates, &emailhandler.Options{
+			Sender:    usecases.EmailSender,
+			Allowlist: opts.EmailAllowlist,
+		})
 	}
 	s := Server{
 		address:    address,
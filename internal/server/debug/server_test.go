@@ -0,0 +1,115 @@
+package debug
+
+import (
+	"context"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/debug/scenario"
+	entity "github.com/albertwidi/go-project-example/internal/entity/notification"
+	"github.com/albertwidi/go-project-example/internal/kothak/kothaktest"
+	"github.com/albertwidi/go-project-example/internal/pkg/log/logger/std"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	emailhandler "github.com/albertwidi/go-project-example/internal/server/debug/email"
+	scenariohandler "github.com/albertwidi/go-project-example/internal/server/debug/scenario"
+	"github.com/albertwidi/go-project-example/internal/server/servertest"
+	"github.com/albertwidi/go-project-example/internal/usecase/notification"
+)
+
+// emailSender record the sent email preview
+type emailSender struct {
+	sent []entity.Delivery
+}
+
+func (s *emailSender) Send(ctx context.Context, delivery entity.Delivery) (string, error) {
+	s.sent = append(s.sent, delivery)
+	return "preview-1", nil
+}
+
+func TestServerContract(t *testing.T) {
+	logger, err := std.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := scenario.New(logger, &scenario.Options{Enabled: []string{"echo"}})
+	err = registry.Register(
+		scenario.Scenario{
+			Name:        "echo",
+			Description: "return the message",
+			Params:      []scenario.Param{{Name: "message", Required: true}},
+			Run: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				return params, nil
+			},
+		},
+		scenario.Scenario{
+			Name:        "disabled",
+			Description: "scenario which is not enabled",
+			Run: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				return nil, nil
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := notification.NewEmailTemplate(map[string]notification.EmailTemplateFile{
+		"welcome": {
+			Subject: "Welcome {{.Name}}",
+			HTML:    "<p>Hi {{.Name}}, your code is <b>{{.Code}}</b></p>",
+			Text:    "Hi {{.Name}}, your code is {{.Code}}",
+			Sample:  map[string]interface{}{"Name": "John", "Code": "1234"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &emailSender{}
+
+	register := func(r *router.Router, resources *kothaktest.Kothak) error {
+		s := Server{
+			handlers: Handlers{
+				scenario: scenariohandler.New(registry),
+				email: emailhandler.New(templates, &emailhandler.Options{
+					Sender:    sender,
+					Allowlist: []string{"@example.com"},
+				}),
+			},
+		}
+		s.registerHandlers(r)
+		return nil
+	}
+	ts := servertest.New(t, register, &servertest.Options{
+		Spec: "../../../docs/api/debug.swagger.yaml",
+	})
+	defer ts.Close()
+
+	cases := []string{
+		"list_scenarios",
+		"run_scenario",
+		"run_disabled_scenario",
+		"run_missing_param",
+		"list_email_templates",
+		"preview_email",
+		"preview_email_text",
+		"render_email",
+		"render_missing_email",
+		"send_email",
+		"send_email_not_allowed",
+		"send_email_display_name",
+		"send_email_quoted",
+	}
+	for _, c := range cases {
+		t.Run(c, func(t *testing.T) {
+			ts.Golden(c)
+		})
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0].Recipient != "designer@example.com" {
+		t.Errorf("expecting one preview is sent to the allowlisted recipient, got %v", sender.sent)
+	}
+
+	coverage := ts.CheckCoverage()
+	if len(coverage.Uncovered) > 0 {
+		t.Errorf("expecting all routes are covered, got %s", coverage)
+	}
+}
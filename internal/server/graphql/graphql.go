@@ -0,0 +1,187 @@
+// Package graphql serve graphql schema in the same router as rest handlers, so graphql requests pass the same auth and metrics middlewares
+// the schema is executed by Executor, which is implemented by the graphql library of the service, for example
+//
+//	schema := graphqlgo.MustParseSchema(sdl, &resolver{})
+//	executor := graphql.ExecutorFunc(func(ctx context.Context, req graphql.Request) *graphql.Response {
+//		resp := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+//		...
+//	})
+//	graphql.Register(r, "/graphql", graphql.New(executor, &graphql.Options{Loaders: loaders}))
+//
+// resolvers get the request context with RequestContext(ctx), and the per-request dataloader with dataloader.For(ctx, name)
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/dataloader"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// list of error
+var (
+	ErrQueryEmpty         = errors.New("graphql: query is empty")
+	ErrMutationNotAllowed = errors.New("graphql: mutation is only allowed with POST")
+	ErrBodyTooLarge       = errors.New("graphql: request body is too large")
+)
+
+// Request of graphql
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Error of graphql response
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Response of graphql
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []Error         `json:"errors,omitempty"`
+}
+
+// Executor execute the graphql request with the schema
+type Executor interface {
+	Execute(ctx context.Context, req Request) *Response
+}
+
+// ExecutorFunc is a function which implement Executor
+type ExecutorFunc func(ctx context.Context, req Request) *Response
+
+// Execute the graphql request
+func (fn ExecutorFunc) Execute(ctx context.Context, req Request) *Response {
+	return fn(ctx, req)
+}
+
+type requestContextKey struct{}
+
+// WithRequestContext return context with the request context
+func WithRequestContext(ctx context.Context, rctx *requestcontext.RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rctx)
+}
+
+// RequestContext return the request context of the resolver context
+func RequestContext(ctx context.Context) (*requestcontext.RequestContext, bool) {
+	rctx, ok := ctx.Value(requestContextKey{}).(*requestcontext.RequestContext)
+	return rctx, ok
+}
+
+// Options of graphql handler
+type Options struct {
+	// Loaders is created for every request, see dataloader.For
+	Loaders map[string]dataloader.Factory
+	// MaxBodyBytes of the request, default to 1MB
+	MaxBodyBytes int64
+}
+
+// Handler of graphql
+type Handler struct {
+	executor Executor
+	opts     Options
+}
+
+// New graphql handler of the schema executor
+func New(executor Executor, options *Options) *Handler {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+
+	h := Handler{
+		executor: executor,
+		opts:     opts,
+	}
+	return &h
+}
+
+// Register the graphql handler to the router in the path, for GET and POST
+// the middlewares of the router are used, and the middlewares is chained after them
+func Register(r *router.Router, path string, h *Handler, middlewares ...router.MiddlewareFunc) {
+	group := router.NewChainedMiddleware(r, middlewares...)
+	group.Get(path, h.Handle)
+	group.Post(path, h.Handle)
+}
+
+// Handle the graphql request
+// query is read from the url in GET request, and from the json body in POST request
+func (h *Handler) Handle(rctx *requestcontext.RequestContext) error {
+	req, err := h.decode(rctx.Request())
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrMutationNotAllowed) {
+			status = http.StatusMethodNotAllowed
+		}
+		return h.write(rctx, status, &Response{Errors: []Error{{Message: err.Error()}}})
+	}
+
+	ctx := WithRequestContext(rctx.Context(), rctx)
+	if h.opts.Loaders != nil {
+		ctx = dataloader.WithLoaders(ctx, dataloader.NewLoaders(h.opts.Loaders))
+	}
+	resp := h.executor.Execute(ctx, req)
+	if resp == nil {
+		resp = &Response{}
+	}
+	// execution error is returned in the errors field with 200 OK
+	return h.write(rctx, http.StatusOK, resp)
+}
+
+func (h *Handler) decode(r *http.Request) (Request, error) {
+	req := Request{}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, err
+			}
+		}
+		if req.Query != "" && isMutation(req.Query, req.OperationName) {
+			return req, ErrMutationNotAllowed
+		}
+
+	default:
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, h.opts.MaxBodyBytes+1))
+		if err != nil {
+			return req, err
+		}
+		if int64(len(body)) > h.opts.MaxBodyBytes {
+			return req, ErrBodyTooLarge
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req, err
+		}
+	}
+	if req.Query == "" {
+		return req, ErrQueryEmpty
+	}
+	return req, nil
+}
+
+func (h *Handler) write(rctx *requestcontext.RequestContext, status int, resp *Response) error {
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	w := rctx.ResponseWriter()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	requestcontext "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/dataloader"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+func TestIsMutation(t *testing.T) {
+	cases := []struct {
+		query         string
+		operationName string
+		expect        bool
+	}{
+		{query: `{ user(id: 1) { name } }`, expect: false},
+		{query: `query GetUser($id: ID!) { user(id: $id) { name } }`, expect: false},
+		{query: `mutation { createUser(name: "a") { id } }`, expect: true},
+		{query: "# mutation in comment\n{ user { name } }", expect: false},
+		{query: `{ search(text: "mutation { x }") { id } }`, expect: false},
+		{query: `query A { a } mutation B { b }`, operationName: "A", expect: false},
+		{query: `query A { a } mutation B { b }`, operationName: "B", expect: true},
+		{query: `fragment F on User { name } mutation M @client { m { ...F } }`, expect: true},
+		{query: `subscription { events { id } }`, expect: true},
+	}
+	for _, c := range cases {
+		if got := isMutation(c.query, c.operationName); got != c.expect {
+			t.Errorf("%s (%s): expecting %v but got %v", c.query, c.operationName, c.expect, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]string
+	)
+	loaders := map[string]dataloader.Factory{
+		"users": func() *dataloader.Loader {
+			return dataloader.New(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+				mu.Lock()
+				batches = append(batches, keys)
+				mu.Unlock()
+				values := make(map[string]interface{})
+				for _, key := range keys {
+					values[key] = "user-" + key
+				}
+				return values, nil
+			}, nil)
+		},
+	}
+	// the executor resolve a list of users with the dataloader
+	executor := ExecutorFunc(func(ctx context.Context, req Request) *Response {
+		rctx, ok := RequestContext(ctx)
+		if !ok {
+			return &Response{Errors: []Error{{Message: "no request context"}}}
+		}
+		users, err := dataloader.For(ctx, "users")
+		if err != nil {
+			return &Response{Errors: []Error{{Message: err.Error()}}}
+		}
+		names, _ := users.LoadMany(ctx, []string{"1", "2", "1"})
+		data, _ := json.Marshal(map[string]interface{}{
+			"users":         names,
+			"authorization": rctx.RequestHeader().Get("Authorization"),
+		})
+		return &Response{Data: data}
+	})
+
+	r := router.New(":0", nil)
+	r.Use(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(rctx *requestcontext.RequestContext) error {
+			rctx.ResponseWriter().Header().Set("X-Middleware", "1")
+			return next(rctx)
+		}
+	})
+	Register(r, "/graphql", New(executor, &Options{Loaders: loaders, MaxBodyBytes: 128}))
+
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ users { name } }"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expecting %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	expect := `{"data":{"authorization":"Bearer token","users":["user-1","user-2","user-1"]}}`
+	if w.Body.String() != expect {
+		t.Errorf("expecting %s but got %s", expect, w.Body.String())
+	}
+	if w.Header().Get("X-Middleware") != "1" {
+		t.Error("expecting graphql request to pass the router middleware")
+	}
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("expecting users to be loaded in one batch but got %v", batches)
+	}
+
+	cases := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{
+			name:   "query with GET",
+			req:    httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape("{ users { name } }"), nil),
+			status: http.StatusOK,
+		},
+		{
+			name:   "mutation with GET",
+			req:    httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape("mutation { createUser { id } }"), nil),
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "empty query",
+			req:    httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{}`)),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid json",
+			req:    httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":`)),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "body too large",
+			req:    httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(bytes.Repeat([]byte(" "), 256))),
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if w := do(c.req); w.Code != c.status {
+				t.Errorf("expecting %d but got %d: %s", c.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package graphql
+
+// isMutation return true when the operation executed by the query is a mutation or subscription
+// the operation is picked by the operationName, or the first operation in the query
+// the query is not validated, invalid query is rejected by the executor
+func isMutation(query, operationName string) bool {
+	type operation struct {
+		kind string
+		name string
+	}
+	var (
+		operations []operation
+		// header is true after operation type or fragment keyword, until its selection set
+		header bool
+		named  bool
+		depth  int
+		parens int
+	)
+	for idx := 0; idx < len(query); idx++ {
+		c := query[idx]
+		switch {
+		case c == '#':
+			// comment until the end of line
+			for idx < len(query) && query[idx] != '\n' {
+				idx++
+			}
+		case c == '"':
+			// skip string, block string is skipped as three strings
+			for idx++; idx < len(query) && query[idx] != '"'; idx++ {
+				if query[idx] == '\\' {
+					idx++
+				}
+			}
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case c == '{':
+			if depth == 0 {
+				if !header {
+					// shorthand query without operation type
+					operations = append(operations, operation{kind: "query"})
+				}
+				header = false
+			}
+			depth++
+		case c == '}':
+			depth--
+		case c == '@' || c == '$':
+			// skip directive and variable name
+			for idx+1 < len(query) && isNameChar(query[idx+1]) {
+				idx++
+			}
+		case depth == 0 && parens == 0 && isNameStart(c):
+			start := idx
+			for idx+1 < len(query) && isNameChar(query[idx+1]) {
+				idx++
+			}
+			word := query[start : idx+1]
+			if header {
+				// the first name after the keyword is the operation name
+				if !named {
+					operations[len(operations)-1].name = word
+					named = true
+				}
+				continue
+			}
+			switch word {
+			case "query", "mutation", "subscription", "fragment":
+				operations = append(operations, operation{kind: word})
+				header, named = true, false
+			}
+		}
+	}
+
+	for _, op := range operations {
+		if op.kind == "fragment" {
+			continue
+		}
+		if operationName == "" || op.name == operationName {
+			return op.kind == "mutation" || op.kind == "subscription"
+		}
+	}
+	return false
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/albertwidi/go-project-example/internal/pkg/graceful"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// list of error
+var (
+	ErrServerNameEmpty = errors.New("server: name is empty")
+	ErrDuplicateServer = errors.New("server: duplicate server")
+	ErrTLSKeyPair      = errors.New("server: tls cert_file and key_file are required")
+)
+
+// HTTPOptions of named http server
+type HTTPOptions struct {
+	// Middlewares of the server, chained after the middlewares of all servers
+	Middlewares []router.MiddlewareFunc
+	// TLS of the server, plain http is served when nil
+	TLS *tls.Config
+	// Register the handlers of the server
+	Register func(r *router.Router)
+}
+
+// HTTPServer is a named http server, for example public, internal or admin
+// every server has its own listener, middlewares and tls, and is run and shutdown by Server
+type HTTPServer struct {
+	name       string
+	address    string
+	opts       HTTPOptions
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+// NewHTTPServer listen to the address, the listener is inherited on graceful restart
+func NewHTTPServer(name, address string, options *HTTPOptions) (*HTTPServer, error) {
+	if name == "" {
+		return nil, ErrServerNameEmpty
+	}
+	opts := HTTPOptions{}
+	if options != nil {
+		opts = *options
+	}
+
+	listener, err := graceful.ListenAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	s := HTTPServer{
+		name:       name,
+		address:    address,
+		opts:       opts,
+		listener:   listener,
+		httpServer: &http.Server{TLSConfig: opts.TLS},
+	}
+	return &s, nil
+}
+
+// Name of the server
+func (s *HTTPServer) Name() string {
+	return s.name
+}
+
+// Run http server
+func (s *HTTPServer) Run(middlewares ...router.MiddlewareFunc) error {
+	r := router.New(s.address, nil)
+	r.Use(middlewares...)
+	r.Use(s.opts.Middlewares...)
+	if s.opts.Register != nil {
+		s.opts.Register(r)
+	}
+	s.httpServer.Handler = r
+
+	listener := s.listener
+	if s.opts.TLS != nil {
+		listener = tls.NewListener(listener, s.opts.TLS)
+	}
+	err := s.httpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown http server, the listener is closed even when the server is not running
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	s.listener.Close()
+	return err
+}
+
+// TLSOptions of http server
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile verify the client certificate with the ca, client certificate is not requested when empty
+	ClientCAFile string
+}
+
+// NewTLSConfig load the certificates of the options, nil is returned when the cert and key file are empty
+func NewTLSConfig(options TLSOptions) (*tls.Config, error) {
+	if options.CertFile == "" && options.KeyFile == "" {
+		if options.ClientCAFile != "" {
+			return nil, ErrTLSKeyPair
+		}
+		return nil, nil
+	}
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, ErrTLSKeyPair
+	}
+
+	cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("server: failed to load tls key pair: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if options.ClientCAFile != "" {
+		ca, err := ioutil.ReadFile(options.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("server: failed to read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("server: no certificate in client ca %s", options.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	requestctx "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+// writeCertificate write self signed certificate of localhost to the dir
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCertificate(t, dir)
+
+	cases := []struct {
+		name       string
+		options    TLSOptions
+		nilConfig  bool
+		clientAuth tls.ClientAuthType
+		err        error
+	}{
+		{name: "plain http", nilConfig: true},
+		{name: "tls", options: TLSOptions{CertFile: certFile, KeyFile: keyFile}},
+		{name: "mutual tls", options: TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}, clientAuth: tls.RequireAndVerifyClientCert},
+		{name: "no key file", options: TLSOptions{CertFile: certFile}, err: ErrTLSKeyPair},
+		{name: "client ca without key pair", options: TLSOptions{ClientCAFile: certFile}, err: ErrTLSKeyPair},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config, err := NewTLSConfig(c.options)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expect error %v but got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if (config == nil) != c.nilConfig {
+				t.Fatalf("expect nil config %t but got %v", c.nilConfig, config)
+			}
+			if config != nil && config.ClientAuth != c.clientAuth {
+				t.Fatalf("expect client auth %v but got %v", c.clientAuth, config.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server-http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCertificate(t, dir)
+	tlsConfig, err := NewTLSConfig(TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the middlewares of all servers run before the middlewares of the server
+	var calls []string
+	middleware := func(name string) router.MiddlewareFunc {
+		return func(next router.HandlerFunc) router.HandlerFunc {
+			return func(rctx *requestctx.RequestContext) error {
+				calls = append(calls, name)
+				return next(rctx)
+			}
+		}
+	}
+	s, err := NewHTTPServer("internal", "127.0.0.1:0", &HTTPOptions{
+		Middlewares: []router.MiddlewareFunc{middleware("internal")},
+		TLS:         tlsConfig,
+		Register: func(r *router.Router) {
+			r.Get("/ping", func(rctx *requestctx.RequestContext) error {
+				_, err := rctx.ResponseWriter().Write([]byte("pong"))
+				return err
+			})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.Run(middleware("all"))
+	}()
+
+	cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + s.listener.Addr().String() + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "pong" {
+		t.Fatalf("expect pong but got %s", body)
+	}
+	if len(calls) != 2 || calls[0] != "all" || calls[1] != "internal" {
+		t.Fatalf("unexpected middleware calls %v", calls)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("expect nil error after shutdown but got %v", err)
+	}
+}
+
+func TestNewDuplicateServer(t *testing.T) {
+	first, err := NewHTTPServer("public", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.listener.Close()
+	second, err := NewHTTPServer("public", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.listener.Close()
+
+	if _, err := New("127.0.0.1:0", first, second); !errors.Is(err, ErrDuplicateServer) {
+		t.Fatalf("expect error %v but got %v", ErrDuplicateServer, err)
+	}
+}
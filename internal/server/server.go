@@ -2,12 +2,14 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	requestctx "github.com/albertwidi/go-project-example/internal/pkg/context"
 	httpmisc "github.com/albertwidi/go-project-example/internal/pkg/http/misc"
 	httpmonitoring "github.com/albertwidi/go-project-example/internal/pkg/http/monitoring"
 	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/pkg/safego"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -20,41 +22,105 @@ type Runner interface {
 
 // Server configuration
 type Server struct {
-	runners []Runner
-	errChan chan error
+	runners     []Runner
+	errChan     chan error
+	middlewares []router.MiddlewareFunc
+	metrics     *Metrics
+	admin       *adminServer
+}
 
-	// prometheus vector object for metrics
-	countervec      *prometheus.CounterVec
-	durationhist    *prometheus.HistogramVec
-	requestsizehist *prometheus.HistogramVec
+// Use middlewares in all servers, the middlewares are chained after metrics middleware
+// this must be called before Run
+func (s *Server) Use(middlewares ...router.MiddlewareFunc) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// Middlewares return the middlewares chain of all servers, metrics middleware is always the first
+func (s *Server) Middlewares() []router.MiddlewareFunc {
+	return append([]router.MiddlewareFunc{s.Metrics}, s.middlewares...)
 }
 
 // Run the server
 func (s *Server) Run() chan error {
+	middlewares := s.Middlewares()
 	for _, r := range s.runners {
-		go func(r Runner) {
-			if err := r.Run(s.Metrics); err != nil {
+		runner := r
+		errChan := safego.Go(context.Background(), "server/run", func(ctx context.Context) error {
+			return runner.Run(middlewares...)
+		})
+		// forward the error to server error channel, including panic from the runner
+		safego.Go(context.Background(), "server/errors", func(ctx context.Context) error {
+			if err := <-errChan; err != nil {
 				s.errChan <- err
 			}
-		}(r)
+			return nil
+		})
 	}
 	return s.errChan
 }
 
 // Shutdown the server
-// TODO: check whether one of the runners return error when shutting down
+// every runner is shutdown even when one of them failed, and the first error is returned
 func (s *Server) Shutdown(ctx context.Context) error {
 	// send nil error to get the server out of the loop
 	s.errChan <- nil
 
+	var err error
 	for _, r := range s.runners {
-		r.Shutdown(ctx)
+		if shutdownErr := r.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
 	}
-	return nil
+	return err
 }
 
 // New server
 func New(adminServerAddress string, runners ...Runner) (*Server, error) {
+	// named runner must be unique, for example http servers
+	names := make(map[string]bool)
+	for _, r := range runners {
+		named, ok := r.(interface{ Name() string })
+		if !ok {
+			continue
+		}
+		if names[named.Name()] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateServer, named.Name())
+		}
+		names[named.Name()] = true
+	}
+
+	metrics, err := NewMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	s := Server{
+		errChan: make(chan error, 1),
+		metrics: metrics,
+		runners: runners,
+	}
+
+	if s.runners == nil {
+		s.runners = []Runner{}
+	}
+	adm, err := s.newAdminServer(adminServerAddress)
+	if err != nil {
+		return nil, err
+	}
+	s.admin = adm
+	s.runners = append(s.runners, adm)
+	return &s, nil
+}
+
+// Metrics of http requests
+type Metrics struct {
+	// prometheus vector object for metrics
+	countervec      *prometheus.CounterVec
+	durationhist    *prometheus.HistogramVec
+	requestsizehist *prometheus.HistogramVec
+}
+
+// NewMetrics create and register http request metrics to the registerer
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
 	// initialize monitoring metrics
 	countervec := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -63,7 +129,7 @@ func New(adminServerAddress string, runners ...Runner) (*Server, error) {
 		},
 		[]string{"address", "code", "method", "path"},
 	)
-	err := prometheus.DefaultRegisterer.Register(countervec)
+	err := registerer.Register(countervec)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +140,7 @@ func New(adminServerAddress string, runners ...Runner) (*Server, error) {
 		},
 		[]string{"address", "code", "method", "path"},
 	)
-	err = prometheus.DefaultRegisterer.Register(durationhist)
+	err = registerer.Register(durationhist)
 	if err != nil {
 		return nil, err
 	}
@@ -85,31 +151,25 @@ func New(adminServerAddress string, runners ...Runner) (*Server, error) {
 		},
 		[]string{"address", "code", "method", "path"},
 	)
-	err = prometheus.DefaultRegisterer.Register(requestsizehist)
+	err = registerer.Register(requestsizehist)
 	if err != nil {
 		return nil, err
 	}
-	s := Server{
-		errChan:         make(chan error, 1),
+	m := Metrics{
 		countervec:      countervec,
 		durationhist:    durationhist,
 		requestsizehist: requestsizehist,
-		runners:         runners,
 	}
-
-	if s.runners == nil {
-		s.runners = []Runner{}
-	}
-	adm, err := s.newAdminServer(adminServerAddress)
-	if err != nil {
-		return nil, err
-	}
-	s.runners = append(s.runners, adm)
-	return &s, nil
+	return &m, nil
 }
 
 // Metrics is a middleware for metrics monitoring
 func (s *Server) Metrics(next router.HandlerFunc) router.HandlerFunc {
+	return s.metrics.Middleware(next)
+}
+
+// Middleware for metrics monitoring
+func (m *Metrics) Middleware(next router.HandlerFunc) router.HandlerFunc {
 	return func(rctx *requestctx.RequestContext) error {
 		now := time.Now()
 		err := next(rctx)
@@ -125,9 +185,9 @@ func (s *Server) Metrics(next router.HandlerFunc) router.HandlerFunc {
 		if ok {
 			httpStatus = d.Status()
 		}
-		s.countervec.WithLabelValues(address, httpmisc.SanitizeCode(httpStatus), requestMethod, handlerName).Inc()
-		s.durationhist.WithLabelValues(address, httpmisc.SanitizeCode(httpStatus), requestMethod, handlerName).Observe(duration)
-		s.requestsizehist.WithLabelValues(address, httpmisc.SanitizeCode(httpStatus), requestMethod, handlerName).Observe(float64(requestSize))
+		m.countervec.WithLabelValues(address, httpmisc.SanitizeCode(httpStatus), requestMethod, handlerName).Inc()
+		m.durationhist.WithLabelValues(address, httpmisc.SanitizeCode(httpStatus), requestMethod, handlerName).Observe(duration)
+		m.requestsizehist.WithLabelValues(address, httpmisc.SanitizeCode(httpStatus), requestMethod, handlerName).Observe(float64(requestSize))
 		return err
 	}
 }
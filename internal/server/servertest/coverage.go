@@ -0,0 +1,163 @@
+package servertest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var specMethods = map[string]bool{
+	"get":     true,
+	"put":     true,
+	"post":    true,
+	"delete":  true,
+	"options": true,
+	"head":    true,
+	"patch":   true,
+}
+
+// Route of http handler, the path is the path template, for example /users/{id}
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Coverage of routes compared to the spec
+type Coverage struct {
+	// Covered routes are in the spec and called in the test
+	Covered []Route
+	// Uncovered routes are in the spec but never called in the test
+	Uncovered []Route
+	// Undocumented routes are registered in the router but not in the spec
+	Undocumented []Route
+}
+
+// Percentage of the spec routes which are called in the test
+func (c Coverage) Percentage() float64 {
+	total := len(c.Covered) + len(c.Uncovered)
+	if total == 0 {
+		return 0
+	}
+	return float64(len(c.Covered)) / float64(total) * 100
+}
+
+// String return the report of the coverage
+func (c Coverage) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "route coverage: %.1f%% (%d/%d)\n", c.Percentage(), len(c.Covered), len(c.Covered)+len(c.Uncovered))
+	for _, route := range c.Uncovered {
+		fmt.Fprintf(&b, "  uncovered: %s\n", route)
+	}
+	for _, route := range c.Undocumented {
+		fmt.Fprintf(&b, "  undocumented: %s\n", route)
+	}
+	return b.String()
+}
+
+type spec struct {
+	Paths map[string]map[string]interface{} `yaml:"paths"`
+}
+
+// LoadSpec return the routes in OpenAPI or swagger spec file, json is parsed as yaml
+func LoadSpec(path string) ([]Route, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := spec{}
+	if err := yaml.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("servertest: invalid spec %s: %w", path, err)
+	}
+
+	var routes []Route
+	for path, operations := range s.Paths {
+		for method := range operations {
+			if !specMethods[strings.ToLower(method)] {
+				continue
+			}
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path})
+		}
+	}
+	sortRoutes(routes)
+	return routes, nil
+}
+
+// registeredRoutes return routes registered in the router
+func (s *Server) registeredRoutes() []Route {
+	var routes []Route
+	for _, r := range s.router.Routes() {
+		path, err := r.GetPathTemplate()
+		if err != nil {
+			continue
+		}
+		methods, err := r.GetMethods()
+		if err != nil {
+			continue
+		}
+		for _, method := range methods {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path})
+		}
+	}
+	sortRoutes(routes)
+	return routes
+}
+
+// Coverage of the routes called in the test compared to the spec
+func (s *Server) Coverage() (Coverage, error) {
+	coverage := Coverage{}
+	if s.options.Spec == "" {
+		return coverage, fmt.Errorf("servertest: spec is not set")
+	}
+	specRoutes, err := LoadSpec(s.options.Spec)
+	if err != nil {
+		return coverage, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documented := make(map[Route]bool, len(specRoutes))
+	for _, route := range specRoutes {
+		documented[route] = true
+		if s.called[route] > 0 {
+			coverage.Covered = append(coverage.Covered, route)
+		} else {
+			coverage.Uncovered = append(coverage.Uncovered, route)
+		}
+	}
+	for _, route := range s.registeredRoutes() {
+		if !documented[route] {
+			coverage.Undocumented = append(coverage.Undocumented, route)
+		}
+	}
+	return coverage, nil
+}
+
+// CheckCoverage log the coverage report and fail the test when a registered route is not in the spec
+func (s *Server) CheckCoverage() Coverage {
+	s.t.Helper()
+	coverage, err := s.Coverage()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Log(coverage.String())
+	if len(coverage.Undocumented) > 0 {
+		s.t.Errorf("servertest: %d routes are not documented in %s", len(coverage.Undocumented), s.options.Spec)
+	}
+	return coverage
+}
+
+func sortRoutes(routes []Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+}
@@ -0,0 +1,145 @@
+package servertest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvUpdateGolden is the environment variable to write the actual response to the golden files instead of comparing them
+// for example UPDATE_GOLDEN=1 go test ./...
+const EnvUpdateGolden = "UPDATE_GOLDEN"
+
+// list of golden file extension
+const (
+	ExtRequest  = ".request"
+	ExtResponse = ".golden"
+)
+
+// Golden send the request from name.request file and compare the response with name.golden file
+// the request file is formatted as:
+//
+//	POST /scenarios/run
+//	Content-Type: application/json
+//
+//	{"name": "create_test_user"}
+func (s *Server) Golden(name string) {
+	s.t.Helper()
+	path := filepath.Join(s.options.GoldenDir, name+ExtRequest)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		s.t.Fatalf("servertest: failed to read request file: %v", err)
+	}
+	req, err := ParseRequest(content)
+	if err != nil {
+		s.t.Fatalf("servertest: invalid request file %s: %v", path, err)
+	}
+	s.GoldenRequest(name, req)
+}
+
+// GoldenRequest send the request and compare the response with name.golden file
+func (s *Server) GoldenRequest(name string, req *http.Request) {
+	s.t.Helper()
+	resp := s.Do(req)
+	actual, err := s.dumpResponse(resp)
+	if err != nil {
+		s.t.Fatalf("servertest: failed to read response: %v", err)
+	}
+
+	path := filepath.Join(s.options.GoldenDir, name+ExtResponse)
+	if os.Getenv(EnvUpdateGolden) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			s.t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			s.t.Fatalf("servertest: failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	expect, err := ioutil.ReadFile(path)
+	if err != nil {
+		s.t.Fatalf("servertest: failed to read golden file, run with %s=1 to create it: %v", EnvUpdateGolden, err)
+	}
+	if !bytes.Equal(expect, actual) {
+		s.t.Errorf("servertest: response of %s is not equal to %s\nexpect:\n%s\nactual:\n%s", name, path, expect, actual)
+	}
+}
+
+// dumpResponse write the status, golden headers and body of the response
+// json body is indented so the golden file is readable and stable
+func (s *Server) dumpResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	buff := bytes.NewBuffer(nil)
+	fmt.Fprintf(buff, "%d %s\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+	for _, header := range s.options.GoldenHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			fmt.Fprintf(buff, "%s: %s\n", http.CanonicalHeaderKey(header), value)
+		}
+	}
+	buff.WriteString("\n")
+
+	indented := bytes.NewBuffer(nil)
+	if err := json.Indent(indented, bytes.TrimSpace(body), "", "  "); err == nil {
+		body = indented.Bytes()
+	}
+	buff.Write(bytes.TrimSpace(body))
+	buff.WriteString("\n")
+	return buff.Bytes(), nil
+}
+
+// ParseRequest parse the request file content
+// the first line is the method and path, followed by headers, an empty line, and the body
+func ParseRequest(content []byte) (*http.Request, error) {
+	var (
+		reader  = bufio.NewReader(bytes.NewReader(content))
+		headers = http.Header{}
+	)
+
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return nil, fmt.Errorf("request line is empty")
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid request line %q", strings.TrimSpace(line))
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+		headers.Add(strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:]))
+		if err != nil {
+			break
+		}
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(strings.ToUpper(fields[0]), fields[1], bytes.NewReader(bytes.TrimSpace(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+	return req, nil
+}
@@ -0,0 +1,148 @@
+// Package servertest provide contract testing harness for http handlers
+// the handlers are served through the same middleware chain as the server, with resources from kothaktest
+//
+// the harness support golden-file assertions of request and response, and record which routes are called
+// so the coverage of the routes can be compared to the OpenAPI spec
+package servertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/kothak/kothaktest"
+	requestctx "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+	"github.com/albertwidi/go-project-example/internal/server"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Address of the test router, used as address label in metrics
+const Address = "servertest"
+
+// RegisterFunc register handlers to the router, resources can be used to create the handler dependencies
+type RegisterFunc func(r *router.Router, resources *kothaktest.Kothak) error
+
+// Options of test server
+type Options struct {
+	// Resources of kothaktest, passed to the register function
+	Resources kothaktest.Options
+	// Middlewares chained after metrics middleware, same as server.Use
+	Middlewares []router.MiddlewareFunc
+	// Spec is the OpenAPI or swagger spec file, in yaml or json, to check the coverage of the routes
+	Spec string
+	// GoldenDir is the directory of golden files, default to testdata
+	GoldenDir string
+	// GoldenHeaders is the list of response header written to golden file, default to Content-Type
+	GoldenHeaders []string
+}
+
+// Server for testing
+type Server struct {
+	t         testing.TB
+	options   Options
+	router    *router.Router
+	server    *httptest.Server
+	resources *kothaktest.Kothak
+	registry  *prometheus.Registry
+
+	mu     sync.Mutex
+	called map[Route]int
+}
+
+// New test server, the server is closed when Close is called
+func New(t testing.TB, register RegisterFunc, options *Options) *Server {
+	t.Helper()
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.GoldenDir == "" {
+		opts.GoldenDir = "testdata"
+	}
+	if len(opts.GoldenHeaders) == 0 {
+		opts.GoldenHeaders = []string{"Content-Type"}
+	}
+
+	resources, err := kothaktest.New(opts.Resources)
+	if err != nil {
+		t.Fatalf("servertest: failed to create resources: %v", err)
+	}
+
+	// metrics is registered to its own registry, so more than one test server can be created
+	registry := prometheus.NewRegistry()
+	metrics, err := server.NewMetrics(registry)
+	if err != nil {
+		resources.Close()
+		t.Fatalf("servertest: failed to create metrics: %v", err)
+	}
+
+	s := Server{
+		t:         t,
+		options:   opts,
+		router:    router.New(Address, nil),
+		resources: resources,
+		registry:  registry,
+		called:    make(map[Route]int),
+	}
+	// record the route before the chain, so requests rejected by middleware are also covered
+	s.router.Use(s.record, metrics.Middleware)
+	s.router.Use(opts.Middlewares...)
+	if err := register(s.router, resources); err != nil {
+		resources.Close()
+		t.Fatalf("servertest: failed to register handlers: %v", err)
+	}
+	s.server = httptest.NewServer(s.router)
+	return &s
+}
+
+func (s *Server) record(next router.HandlerFunc) router.HandlerFunc {
+	return func(rctx *requestctx.RequestContext) error {
+		route := Route{
+			Method: strings.ToUpper(rctx.Request().Method),
+			Path:   rctx.RequestHandler(),
+		}
+		s.mu.Lock()
+		s.called[route]++
+		s.mu.Unlock()
+		return next(rctx)
+	}
+}
+
+// URL of the test server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Resources return kothaktest resources to set expectations of the handler dependencies
+func (s *Server) Resources() *kothaktest.Kothak {
+	return s.resources
+}
+
+// Registry return prometheus registry of the server metrics
+func (s *Server) Registry() *prometheus.Registry {
+	return s.registry
+}
+
+// Do send the request to the test server, request url without host is sent to the test server
+func (s *Server) Do(req *http.Request) *http.Response {
+	s.t.Helper()
+	if req.URL.Host == "" {
+		req.URL.Scheme = "http"
+		req.URL.Host = strings.TrimPrefix(s.server.URL, "http://")
+	}
+	req.RequestURI = ""
+	resp, err := s.server.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("servertest: failed to send request %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	return resp
+}
+
+// Close the test server and the resources
+func (s *Server) Close() {
+	s.server.Close()
+	s.resources.Close()
+}
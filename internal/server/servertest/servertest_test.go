@@ -0,0 +1,81 @@
+package servertest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/albertwidi/go-project-example/internal/kothak/kothaktest"
+	requestctx "github.com/albertwidi/go-project-example/internal/pkg/context"
+	"github.com/albertwidi/go-project-example/internal/pkg/router"
+)
+
+func TestParseRequest(t *testing.T) {
+	content := "POST /users/1?debug=1\nContent-Type: application/json\nX-Actor: test\n\n{\"name\": \"test\"}\n"
+	req, err := ParseRequest([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost || req.URL.Path != "/users/1" || req.URL.Query().Get("debug") != "1" {
+		t.Errorf("invalid request line, got %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("X-Actor") != "test" {
+		t.Errorf("expecting header X-Actor, got %v", req.Header)
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != `{"name": "test"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	if _, err := ParseRequest([]byte("GET\n")); err == nil {
+		t.Error("expecting error for request line without path")
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	spec := filepath.Join(dir, "spec.json")
+	content := `{"paths": {"/users/{id}": {"get": {}, "delete": {}, "parameters": []}}}`
+	if err := ioutil.WriteFile(spec, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(rctx *requestctx.RequestContext) error {
+		_, err := rctx.JSON().Data("ok").Write()
+		return err
+	}
+	register := func(r *router.Router, resources *kothaktest.Kothak) error {
+		r.Get("/users/{id}", handler)
+		r.Post("/users", handler)
+		return nil
+	}
+	s := New(t, register, &Options{Spec: spec, GoldenDir: dir})
+	defer s.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/10", nil)
+	s.Do(req).Body.Close()
+
+	coverage, err := s.Coverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Coverage{
+		Covered:      []Route{{Method: "GET", Path: "/users/{id}"}},
+		Uncovered:    []Route{{Method: "DELETE", Path: "/users/{id}"}},
+		Undocumented: []Route{{Method: "POST", Path: "/users"}},
+	}
+	if !reflect.DeepEqual(expect, coverage) {
+		t.Errorf("expecting %+v, got %+v", expect, coverage)
+	}
+	if coverage.Percentage() != 50 {
+		t.Errorf("expecting 50%% coverage, got %f", coverage.Percentage())
+	}
+}
@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+)
+
+// list of email template error
+var (
+	ErrEmailTemplateNotFound  = errors.New("email_template: template not found")
+	ErrEmailTemplateDuplicate = errors.New("email_template: template already exists")
+)
+
+// EmailTemplateFile to store email template in a file
+// the file is a json object of template name to the template, for example {"welcome": {"subject": "Welcome {{.Name}}"}}
+type EmailTemplateFile struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+	// Sample data to render the template in preview, for example {"Name": "John"}
+	Sample map[string]interface{} `json:"sample"`
+}
+
+// EmailGoTemplate for storing information about email template as go template
+// html is escaped for html, subject and text are rendered as is
+type EmailGoTemplate struct {
+	Subject *template.Template
+	HTML    *htmltemplate.Template
+	Text    *template.Template
+	Sample  map[string]interface{}
+}
+
+// RenderedEmail is the output of email template
+type RenderedEmail struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// EmailTemplate for notification
+type EmailTemplate struct {
+	templates map[string]EmailGoTemplate
+}
+
+// NewEmailTemplate for email notification template
+func NewEmailTemplate(templates map[string]EmailTemplateFile) (*EmailTemplate, error) {
+	goTemplates := make(map[string]EmailGoTemplate, len(templates))
+
+	for name, file := range templates {
+		var err error
+		subject := template.New("subject" + name)
+		subject, err = subject.Parse(file.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("email_template: %s: subject: %w", name, err)
+		}
+
+		html := htmltemplate.New("html" + name)
+		html, err = html.Parse(file.HTML)
+		if err != nil {
+			return nil, fmt.Errorf("email_template: %s: html: %w", name, err)
+		}
+
+		text := template.New("text" + name)
+		text, err = text.Parse(file.Text)
+		if err != nil {
+			return nil, fmt.Errorf("email_template: %s: text: %w", name, err)
+		}
+
+		goTemplates[name] = EmailGoTemplate{
+			Subject: subject,
+			HTML:    html,
+			Text:    text,
+			Sample:  file.Sample,
+		}
+	}
+
+	t := EmailTemplate{
+		templates: goTemplates,
+	}
+	return &t, nil
+}
+
+// LoadEmailTemplate load all json template files in the directory and its sub directories
+// template name must be unique across the files
+func LoadEmailTemplate(dir string) (*EmailTemplate, error) {
+	files := make(map[string]EmailTemplateFile)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		out, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		templates := make(map[string]EmailTemplateFile)
+		if err := json.Unmarshal(out, &templates); err != nil {
+			return fmt.Errorf("email_template: %s: %w", path, err)
+		}
+		for name, file := range templates {
+			if _, ok := files[name]; ok {
+				return fmt.Errorf("%w: %s in %s", ErrEmailTemplateDuplicate, name, path)
+			}
+			files[name] = file
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewEmailTemplate(files)
+}
+
+// Names of all templates sorted by name
+func (et *EmailTemplate) Names() []string {
+	names := make([]string, 0, len(et.templates))
+	for name := range et.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sample data of the template
+func (et *EmailTemplate) Sample(name string) (map[string]interface{}, error) {
+	t, ok := et.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEmailTemplateNotFound, name)
+	}
+	return t.Sample, nil
+}
+
+// Execute email template with the data
+func (et *EmailTemplate) Execute(name string, data interface{}) (RenderedEmail, error) {
+	t, ok := et.templates[name]
+	if !ok {
+		return RenderedEmail{}, fmt.Errorf("%w: %s", ErrEmailTemplateNotFound, name)
+	}
+
+	var (
+		rendered RenderedEmail
+		buffer   bytes.Buffer
+	)
+	if err := t.Subject.Execute(&buffer, data); err != nil {
+		return RenderedEmail{}, err
+	}
+	rendered.Subject = buffer.String()
+
+	buffer.Reset()
+	if err := t.HTML.Execute(&buffer, data); err != nil {
+		return RenderedEmail{}, err
+	}
+	rendered.HTML = buffer.String()
+
+	buffer.Reset()
+	if err := t.Text.Execute(&buffer, data); err != nil {
+		return RenderedEmail{}, err
+	}
+	rendered.Text = buffer.String()
+	return rendered, nil
+}
@@ -0,0 +1,219 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	entity "github.com/albertwidi/go-project-example/internal/entity/notification"
+)
+
+// Adapter to send a delivery through a channel, for example email, sms or push
+// the adapter return the message id given by the provider
+type Adapter interface {
+	Send(ctx context.Context, delivery entity.Delivery) (string, error)
+}
+
+// StatusCallback is invoked every time a delivery status is changed
+type StatusCallback func(ctx context.Context, delivery entity.Delivery)
+
+type preferenceRepo interface {
+	GetPreferences(ctx context.Context, userID int64) ([]entity.Preference, error)
+	SavePreference(ctx context.Context, pref entity.Preference) error
+}
+
+type dedupRepo interface {
+	AcquireDedup(ctx context.Context, userID int64, channel entity.Channel, key string, window time.Duration) (bool, error)
+	ReleaseDedup(ctx context.Context, userID int64, channel entity.Channel, key string) error
+}
+
+// errAdapterNil is returned when nil adapter is registered
+var errAdapterNil = errors.New("notification: adapter is nil")
+
+type idGenerator interface {
+	Ulid() string
+}
+
+// RouterOptions of notification router
+type RouterOptions struct {
+	// DedupWindow is the window where the same dedup key will not be sent twice
+	// default to 10 minutes
+	DedupWindow time.Duration
+}
+
+// Router route notification to user preferred channels
+type Router struct {
+	prefrepo  preferenceRepo
+	deduprepo dedupRepo
+	ulid      idGenerator
+	adapters  map[entity.Channel]Adapter
+	options   RouterOptions
+
+	mu        sync.RWMutex
+	callbacks []StatusCallback
+
+	// to help testing
+	now func() time.Time
+}
+
+// NewRouter create a new notification router
+func NewRouter(prefrepo preferenceRepo, deduprepo dedupRepo, ulid idGenerator, adapters map[entity.Channel]Adapter, options *RouterOptions) (*Router, error) {
+	opts := RouterOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.DedupWindow == 0 {
+		opts.DedupWindow = time.Minute * 10
+	}
+
+	for channel, adapter := range adapters {
+		if err := channel.Validate(); err != nil {
+			return nil, fmt.Errorf("notification: invalid adapter channel %s: %w", channel, err)
+		}
+		if adapter == nil {
+			return nil, errAdapterNil
+		}
+	}
+
+	r := Router{
+		prefrepo:  prefrepo,
+		deduprepo: deduprepo,
+		ulid:      ulid,
+		adapters:  adapters,
+		options:   opts,
+		now:       time.Now,
+	}
+	return &r, nil
+}
+
+// OnStatus register callback to track delivery status
+func (r *Router) OnStatus(callback StatusCallback) {
+	r.mu.Lock()
+	r.callbacks = append(r.callbacks, callback)
+	r.mu.Unlock()
+}
+
+// UpdateStatus of a delivery, this is used by provider callback
+// for example when nexmo notify the sms is delivered to the user
+func (r *Router) UpdateStatus(ctx context.Context, delivery entity.Delivery, status entity.DeliveryStatus) {
+	delivery.Status = status
+	delivery.UpdatedAt = r.now()
+	r.notify(ctx, delivery)
+}
+
+// SavePreference of user
+func (r *Router) SavePreference(ctx context.Context, pref entity.Preference) error {
+	if err := pref.Channel.Validate(); err != nil {
+		return err
+	}
+
+	now := r.now()
+	if pref.CreatedAt.IsZero() {
+		pref.CreatedAt = now
+	}
+	pref.UpdatedAt = now
+	return r.prefrepo.SavePreference(ctx, pref)
+}
+
+// Route message to all channels preferred by the user
+// channel without preference is treated as enabled without quiet hours
+func (r *Router) Route(ctx context.Context, message entity.RouteMessage) ([]entity.Delivery, error) {
+	if err := message.Validate(); err != nil {
+		return nil, err
+	}
+
+	prefs, err := r.prefrepo.GetPreferences(ctx, message.UserID)
+	if err != nil {
+		return nil, err
+	}
+	prefMap := make(map[entity.Channel]entity.Preference, len(prefs))
+	for _, p := range prefs {
+		prefMap[p.Channel] = p
+	}
+
+	var (
+		deliveries = make([]entity.Delivery, 0, len(message.Recipients))
+		errs       []error
+		now        = r.now()
+	)
+
+	for channel, recipient := range message.Recipients {
+		delivery := entity.Delivery{
+			ID:        r.ulid.Ulid(),
+			UserID:    message.UserID,
+			Channel:   channel,
+			Purpose:   message.Purpose,
+			Recipient: recipient,
+			Title:     message.Title,
+			Message:   message.Message,
+			Status:    entity.DeliveryStatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		status, err := r.deliver(ctx, &delivery, prefMap, message)
+		if err != nil {
+			delivery.Error = err.Error()
+			errs = append(errs, err)
+		}
+		delivery.Status = status
+		delivery.UpdatedAt = r.now()
+		r.notify(ctx, delivery)
+		deliveries = append(deliveries, delivery)
+	}
+
+	if len(errs) > 0 {
+		return deliveries, fmt.Errorf("%w: %v", entity.ErrDeliveryFailed, errs[0])
+	}
+	return deliveries, nil
+}
+
+func (r *Router) deliver(ctx context.Context, delivery *entity.Delivery, prefs map[entity.Channel]entity.Preference, message entity.RouteMessage) (entity.DeliveryStatus, error) {
+	pref, ok := prefs[delivery.Channel]
+	if ok {
+		if !pref.Enabled {
+			return entity.DeliveryStatusSkippedPreference, nil
+		}
+		if !message.Purpose.IsUrgent() && pref.InQuietHours(r.now()) {
+			return entity.DeliveryStatusSkippedQuietHours, nil
+		}
+	}
+
+	adapter, ok := r.adapters[delivery.Channel]
+	if !ok {
+		return entity.DeliveryStatusFailed, fmt.Errorf("%w: %s", entity.ErrAdapterNotFound, delivery.Channel)
+	}
+
+	if message.DedupKey != "" {
+		acquired, err := r.deduprepo.AcquireDedup(ctx, message.UserID, delivery.Channel, message.DedupKey, r.options.DedupWindow)
+		if err != nil {
+			return entity.DeliveryStatusFailed, err
+		}
+		if !acquired {
+			return entity.DeliveryStatusSkippedDuplicate, nil
+		}
+	}
+
+	providerMessageID, err := adapter.Send(ctx, *delivery)
+	if err != nil {
+		// release the dedup key, so the retry of the failed delivery is not skipped as duplicate
+		if message.DedupKey != "" {
+			if releaseErr := r.deduprepo.ReleaseDedup(ctx, message.UserID, delivery.Channel, message.DedupKey); releaseErr != nil {
+				return entity.DeliveryStatusFailed, fmt.Errorf("%w, and failed to release dedup key: %v", err, releaseErr)
+			}
+		}
+		return entity.DeliveryStatusFailed, err
+	}
+	delivery.ProviderMessageID = providerMessageID
+	return entity.DeliveryStatusSent, nil
+}
+
+func (r *Router) notify(ctx context.Context, delivery entity.Delivery) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, callback := range r.callbacks {
+		callback(ctx, delivery)
+	}
+}
@@ -0,0 +1,212 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	entity "github.com/albertwidi/go-project-example/internal/entity/notification"
+)
+
+type fakePreferenceRepo struct {
+	prefs []entity.Preference
+}
+
+func (f *fakePreferenceRepo) GetPreferences(ctx context.Context, userID int64) ([]entity.Preference, error) {
+	return f.prefs, nil
+}
+
+func (f *fakePreferenceRepo) SavePreference(ctx context.Context, pref entity.Preference) error {
+	f.prefs = append(f.prefs, pref)
+	return nil
+}
+
+// fakeDedupRepo keep the acquired keys until they are released, the window is not expired in the test
+type fakeDedupRepo struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (f *fakeDedupRepo) key(userID int64, channel entity.Channel, key string) string {
+	return fmt.Sprintf("%d:%s:%s", userID, channel, key)
+}
+
+func (f *fakeDedupRepo) AcquireDedup(ctx context.Context, userID int64, channel entity.Channel, key string, window time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.keys == nil {
+		f.keys = make(map[string]bool)
+	}
+	k := f.key(userID, channel, key)
+	if f.keys[k] {
+		return false, nil
+	}
+	f.keys[k] = true
+	return true, nil
+}
+
+func (f *fakeDedupRepo) ReleaseDedup(ctx context.Context, userID int64, channel entity.Channel, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.keys, f.key(userID, channel, key))
+	return nil
+}
+
+type fakeULID struct{}
+
+func (fakeULID) Ulid() string {
+	return "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+}
+
+// fakeAdapter return the errors in order, and the message id after the errors
+type fakeAdapter struct {
+	errs []error
+	sent int
+}
+
+func (f *fakeAdapter) Send(ctx context.Context, delivery entity.Delivery) (string, error) {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return "", err
+	}
+	f.sent++
+	return "provider-" + string(delivery.Channel), nil
+}
+
+func TestRoute(t *testing.T) {
+	// 23:00 in UTC is in the quiet hours 22:00 - 06:00
+	now := time.Date(2020, 1, 1, 23, 0, 0, 0, time.UTC)
+	quietHours := entity.Preference{UserID: 1, Channel: entity.ChannelSMS, Enabled: true, QuietHoursStart: 22 * 60, QuietHoursEnd: 6 * 60}
+	errProvider := errors.New("provider unavailable")
+
+	type attempt struct {
+		status entity.DeliveryStatus
+		err    error
+	}
+	cases := []struct {
+		name     string
+		prefs    []entity.Preference
+		purpose  entity.Purpose
+		channel  entity.Channel
+		dedupKey string
+		sendErrs []error
+		// attempts of the same message, the message is routed again after every attempt
+		attempts []attempt
+	}{
+		{
+			name:     "without preference",
+			channel:  entity.ChannelEmail,
+			attempts: []attempt{{status: entity.DeliveryStatusSent}},
+		},
+		{
+			name:     "disabled channel",
+			prefs:    []entity.Preference{{UserID: 1, Channel: entity.ChannelEmail, Enabled: false}},
+			channel:  entity.ChannelEmail,
+			attempts: []attempt{{status: entity.DeliveryStatusSkippedPreference}},
+		},
+		{
+			name:     "quiet hours",
+			prefs:    []entity.Preference{quietHours},
+			purpose:  entity.PurposePromotion,
+			channel:  entity.ChannelSMS,
+			attempts: []attempt{{status: entity.DeliveryStatusSkippedQuietHours}},
+		},
+		{
+			name:     "urgent in quiet hours",
+			prefs:    []entity.Preference{quietHours},
+			purpose:  entity.PurposeAuthenticationOTP,
+			channel:  entity.ChannelSMS,
+			attempts: []attempt{{status: entity.DeliveryStatusSent}},
+		},
+		{
+			name:     "missing adapter",
+			channel:  entity.ChannelPush,
+			attempts: []attempt{{status: entity.DeliveryStatusFailed, err: entity.ErrAdapterNotFound}},
+		},
+		{
+			name:     "duplicate",
+			channel:  entity.ChannelEmail,
+			dedupKey: "order:1:paid",
+			attempts: []attempt{{status: entity.DeliveryStatusSent}, {status: entity.DeliveryStatusSkippedDuplicate}},
+		},
+		{
+			name:     "retry of failed send",
+			channel:  entity.ChannelEmail,
+			dedupKey: "order:1:paid",
+			sendErrs: []error{errProvider},
+			attempts: []attempt{
+				{status: entity.DeliveryStatusFailed, err: errProvider},
+				{status: entity.DeliveryStatusSent},
+				{status: entity.DeliveryStatusSkippedDuplicate},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			adapter := &fakeAdapter{errs: c.sendErrs}
+			adapters := map[entity.Channel]Adapter{entity.ChannelEmail: adapter, entity.ChannelSMS: adapter}
+			router, err := NewRouter(&fakePreferenceRepo{prefs: c.prefs}, &fakeDedupRepo{}, fakeULID{}, adapters, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			router.now = func() time.Time { return now }
+			var notified []entity.Delivery
+			router.OnStatus(func(ctx context.Context, delivery entity.Delivery) {
+				notified = append(notified, delivery)
+			})
+
+			for idx, a := range c.attempts {
+				deliveries, err := router.Route(context.Background(), entity.RouteMessage{
+					UserID:     1,
+					Purpose:    c.purpose,
+					Message:    "your order is paid",
+					Recipients: map[entity.Channel]string{c.channel: "recipient"},
+					DedupKey:   c.dedupKey,
+				})
+				if a.err == nil && err != nil {
+					t.Fatalf("attempt %d: expecting nil error but got %v", idx, err)
+				}
+				if a.err != nil && (!errors.Is(err, entity.ErrDeliveryFailed) || !strings.Contains(err.Error(), a.err.Error())) {
+					t.Fatalf("attempt %d: expecting error %v but got %v", idx, a.err, err)
+				}
+				if len(deliveries) != 1 || deliveries[0].Status != a.status {
+					t.Fatalf("attempt %d: expecting status %d but got %+v", idx, a.status, deliveries)
+				}
+				if a.err != nil && deliveries[0].Error == "" {
+					t.Fatalf("attempt %d: expecting the error of the delivery", idx)
+				}
+				if notified[len(notified)-1].Status != a.status {
+					t.Fatalf("attempt %d: expecting the callback with status %d but got %+v", idx, a.status, notified)
+				}
+			}
+			if len(notified) != len(c.attempts) {
+				t.Fatalf("expecting %d status callbacks but got %d", len(c.attempts), len(notified))
+			}
+		})
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	router, err := NewRouter(&fakePreferenceRepo{}, &fakeDedupRepo{}, fakeULID{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	router.now = func() time.Time { return now }
+	var notified []entity.Delivery
+	router.OnStatus(func(ctx context.Context, delivery entity.Delivery) {
+		notified = append(notified, delivery)
+	})
+
+	router.UpdateStatus(context.Background(), entity.Delivery{ID: "1", Status: entity.DeliveryStatusSent}, entity.DeliveryStatusDelivered)
+	if len(notified) != 1 || notified[0].Status != entity.DeliveryStatusDelivered || !notified[0].UpdatedAt.Equal(now) {
+		t.Fatalf("expecting delivered callback but got %+v", notified)
+	}
+}